@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/server"
+)
+
+var CmdCancel = &cli.Command{
+	Name: "cancel",
+	Description: cli.Description{
+		Short: "Cancel an update that is in progress",
+		Long: strings.Join([]string{
+			"Cancel the update that is holding the lock on your app, like a deploy that is stuck.",
+			"",
+			"```bash frame=\"none\"",
+			"sst cancel --stage production",
+			"```",
+			"",
+			"If the update is running on this machine it is interrupted, the same as pressing `ctrl+c`, and it cleans up after itself.",
+			"",
+			"Otherwise, like when a CI job was killed, the lock is broken once you confirm. Make sure the update is not still running somewhere else since two updates running at once can corrupt your state.",
+			"",
+			"Either way the update is marked as interrupted in the history of the stage.",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		serverFlag,
+		{
+			Name: "yes",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Skip interactive confirmation",
+				Long:  "Skip the confirmation prompt before the lock is broken.",
+			},
+		},
+	},
+	Run: CmdCancelRun,
+}
+
+// cancelTimeout is how long to wait for an interrupted update to release the
+// lock before offering to break it.
+const cancelTimeout = 2 * time.Minute
+
+type cancelResult struct {
+	App      string `json:"app"`
+	Stage    string `json:"stage"`
+	Command  string `json:"command"`
+	UpdateID string `json:"updateID"`
+	// Method is "interrupt" if the update was stopped and "unlock" if the
+	// lock was broken
+	Method string `json:"method"`
+}
+
+func CmdCancelRun(c *cli.Cli) error {
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+
+	lock, err := provider.GetLock(p.Backend(), p.App().Name, p.App().Stage)
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the lock of stage "+p.App().Stage)
+	}
+	if lock == nil {
+		return util.NewReadableError(nil, "Nothing to cancel, stage "+p.App().Stage+" is not locked")
+	}
+	result := &cancelResult{
+		App:      p.App().Name,
+		Stage:    p.App().Stage,
+		Command:  lock.Command,
+		UpdateID: lock.UpdateID,
+	}
+
+	addr, token, err := discoverServer(c, p.PathConfig(), p.App().Stage)
+	if err == nil {
+		err = server.Cancel(c.Context, addr, token)
+	}
+	if err == nil {
+		color.New(color.FgWhite).Printf("   Interrupting `sst %s`...\n", lock.Command)
+		released, err := waitForUnlock(c, p, cancelTimeout)
+		if err != nil {
+			return err
+		}
+		if released {
+			result.Method = "interrupt"
+			output.Result(result)
+			color.New(color.FgGreen, color.Bold).Print("✓ ")
+			color.New(color.FgWhite).Printf(" Cancelled `sst %s` on %s / %s\n", lock.Command, p.App().Name, p.App().Stage)
+			return nil
+		}
+		color.New(color.FgYellow, color.Bold).Print("! ")
+		color.New(color.FgWhite).Printf(" `sst %s` did not stop after %s\n", lock.Command, cancelTimeout)
+	} else if !errors.Is(err, server.ErrServerNotFound) && !errors.Is(err, server.ErrNothingToCancel) {
+		slog.Info("could not reach server", "err", err)
+	}
+
+	ok, err := confirm(c,
+		fmt.Sprintf("Break the lock held by `sst %s` since %s?", lock.Command, lock.Created.Local().Format(time.RFC822)),
+		"Pass in --yes to break the lock without a confirmation prompt",
+	)
+	if err != nil || !ok {
+		return err
+	}
+	err = interruptUpdate(p, lock, "cancelled with `sst cancel`")
+	if err != nil {
+		return util.NewReadableError(err, "Could not record the cancelled update")
+	}
+	err = p.Cancel()
+	if err != nil {
+		return util.NewReadableError(err, "Could not break the lock")
+	}
+	result.Method = "unlock"
+	output.Result(result)
+	color.New(color.FgGreen, color.Bold).Print("✓ ")
+	color.New(color.FgWhite).Printf(" Broke the lock on %s / %s\n", p.App().Name, p.App().Stage)
+	return nil
+}
+
+func waitForUnlock(c *cli.Cli, p *project.Project, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		select {
+		case <-c.Context.Done():
+			return false, c.Context.Err()
+		case <-time.After(time.Second):
+		}
+		lock, err := provider.GetLock(p.Backend(), p.App().Name, p.App().Stage)
+		if err != nil {
+			return false, err
+		}
+		if lock == nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// interruptUpdate records an update that will never finish on its own, with
+// the state as it was last pushed as its checkpoint.
+func interruptUpdate(p *project.Project, lock *provider.LockInfo, reason string) error {
+	summary, err := provider.GetSummary(p.Backend(), p.App().Name, p.App().Stage, lock.UpdateID)
+	if err != nil {
+		return err
+	}
+	if summary == nil {
+		summary = &provider.Summary{
+			Version:     version,
+			UpdateID:    lock.UpdateID,
+			TimeStarted: lock.Created.UTC().Format(time.RFC3339),
+		}
+	}
+	summary.TimeCompleted = time.Now().UTC().Format(time.RFC3339)
+	summary.Interrupted = true
+	summary.Errors = append(summary.Errors, provider.SummaryError{
+		Message: reason,
+	})
+	err = provider.PutSummary(p.Backend(), p.App().Name, p.App().Stage, lock.UpdateID, *summary)
+	if err != nil {
+		return err
+	}
+	_, err = p.PullState()
+	if err != nil {
+		if errors.Is(err, provider.ErrStateNotFound) {
+			return nil
+		}
+		return err
+	}
+	return p.PushState(lock.UpdateID)
+}
@@ -71,6 +71,12 @@ func New(ctx context.Context, cancel context.CancelFunc, root *Command, version
 	if cliParseError != nil {
 		return nil, cli.PrintHelp()
 	}
+	if _, err := cli.logLevel(); err != nil {
+		return nil, err
+	}
+	if _, err := cli.logFormat(); err != nil {
+		return nil, err
+	}
 	cli.configureLog()
 	return cli, nil
 }
@@ -182,6 +188,7 @@ type Argument struct {
 	Name        string      `json:"name"`
 	Required    bool        `json:"required"`
 	Description Description `json:"description"`
+	Complete    Completer   `json:"-"`
 }
 
 type Description struct {
@@ -207,6 +214,7 @@ type Flag struct {
 	Name        string      `json:"name"`
 	Type        string      `json:"type"`
 	Description Description `json:"description"`
+	Complete    Completer   `json:"-"`
 }
 
 type CommandPath []Command
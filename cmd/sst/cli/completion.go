@@ -0,0 +1,133 @@
+package cli
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Completer returns the values a flag or argument can take. words are the
+// words typed after the program name, including the one being completed.
+type Completer func(words []string) []string
+
+// Complete returns the candidates for the last word in words.
+func Complete(root *Command, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	current := words[len(words)-1]
+	path := CommandPath{*root}
+	positional := 0
+	var pending *Flag
+	for _, word := range words[:len(words)-1] {
+		if pending != nil {
+			pending = nil
+			continue
+		}
+		if strings.HasPrefix(word, "--") {
+			name, _, inline := strings.Cut(strings.TrimPrefix(word, "--"), "=")
+			if f := path.flag(name); f != nil && f.Type == "string" && !inline {
+				pending = f
+			}
+			continue
+		}
+		last := path[len(path)-1]
+		if child := last.child(word); child != nil && positional == 0 {
+			path = append(path, *child)
+			continue
+		}
+		positional++
+	}
+
+	candidates := []string{}
+	switch {
+	case pending != nil:
+		if pending.Complete != nil {
+			candidates = pending.Complete(words)
+		}
+	case strings.HasPrefix(current, "-"):
+		for _, cmd := range path {
+			for _, f := range cmd.Flags {
+				candidates = append(candidates, "--"+f.Name)
+			}
+		}
+	default:
+		last := path[len(path)-1]
+		if len(last.Children) > 0 && positional == 0 {
+			for _, child := range last.Children {
+				if !child.Hidden {
+					candidates = append(candidates, child.Name)
+				}
+			}
+		} else if positional < len(last.Args) && last.Args[positional].Complete != nil {
+			candidates = last.Args[positional].Complete(words)
+		}
+	}
+
+	result := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, current) {
+			result = append(result, candidate)
+		}
+	}
+	return result
+}
+
+func (c CommandPath) flag(name string) *Flag {
+	for _, cmd := range c {
+		for i := range cmd.Flags {
+			if cmd.Flags[i].Name == name {
+				return &cmd.Flags[i]
+			}
+		}
+	}
+	return nil
+}
+
+func (c *Command) child(name string) *Command {
+	for _, child := range c.Children {
+		if child.Name == name {
+			return child
+		}
+	}
+	return nil
+}
+
+// FlagValue returns the value of a string flag from the words being completed.
+func FlagValue(words []string, name string) string {
+	for i, word := range words {
+		if value, ok := strings.CutPrefix(word, "--"+name+"="); ok {
+			return value
+		}
+		if word == "--"+name && i+1 < len(words) {
+			return words[i+1]
+		}
+	}
+	return ""
+}
+
+// CompletionScript returns the script that hooks completions for the program
+// into shell. The shell calls back into `<program> __complete <words>`.
+func CompletionScript(program string, shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return fmt.Sprintf(`_%[1]s() {
+  local IFS=$'\n'
+  COMPREPLY=($(%[1]s __complete "${COMP_WORDS[@]:1:$COMP_CWORD}" 2>/dev/null))
+}
+complete -o default -F _%[1]s %[1]s
+`, program), nil
+	case "zsh":
+		return fmt.Sprintf(`#compdef %[1]s
+_%[1]s() {
+  local -a candidates
+  candidates=("${(@f)$(%[1]s __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+  compadd -a candidates
+}
+compdef _%[1]s %[1]s
+`, program), nil
+	case "fish":
+		return fmt.Sprintf(`complete -c %[1]s -f -a '(%[1]s __complete (commandline -opc)[2..-1] (commandline -ct) 2>/dev/null)'
+`, program), nil
+	}
+	return "", fmt.Errorf("unsupported shell: %s", shell)
+}
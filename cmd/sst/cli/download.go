@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/briandowns/spinner"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/global"
+)
+
+// TrackDownloads shows the progress of the downloads running at the same
+// time after the suffix of spin, until the returned function is called.
+func (c *Cli) TrackDownloads(spin *spinner.Spinner, suffix string) func() {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := bus.Subscribe[*global.DownloadEvent](ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		names := []string{}
+		running := map[string]*global.DownloadEvent{}
+		for evt := range events {
+			if _, ok := running[evt.Name]; !ok {
+				names = append(names, evt.Name)
+			}
+			running[evt.Name] = evt
+			parts := []string{}
+			for _, name := range names {
+				if progress := running[name]; !progress.Finished {
+					parts = append(parts, formatDownload(progress))
+				}
+			}
+			spin.Lock()
+			spin.Suffix = suffix
+			if len(parts) > 0 {
+				spin.Suffix += " " + strings.Join(parts, ", ")
+			}
+			spin.Unlock()
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		spin.Lock()
+		spin.Suffix = suffix
+		spin.Unlock()
+	}
+}
+
+func formatDownload(evt *global.DownloadEvent) string {
+	if evt.Total > 0 {
+		return fmt.Sprintf("%s %d%%", evt.Name, evt.Done*100/evt.Total)
+	}
+	return fmt.Sprintf("%s %.1fMB", evt.Name, float64(evt.Done)/1024/1024)
+}
@@ -1,10 +1,14 @@
 package cli
 
 import (
+	"errors"
 	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -12,6 +16,7 @@ import (
 	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/flag"
 	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/telemetry"
 )
 
 var logFile = (func() *os.File {
@@ -22,11 +27,24 @@ var logFile = (func() *os.File {
 	return logFile
 })()
 
+// Discover finds the sst.config.ts of the app, the one picked with --app in
+// a workspace.
+func (c *Cli) Discover() (string, error) {
+	if name := c.String("app"); name != "" {
+		return project.DiscoverApp(name)
+	}
+	return project.Discover()
+}
+
 func (c *Cli) InitProject() (*project.Project, error) {
 	slog.Info("initializing project", "version", c.version)
 
-	cfgPath, err := project.Discover()
+	cfgPath, err := c.Discover()
 	if err != nil {
+		var readable *util.ReadableError
+		if errors.As(err, &readable) {
+			return nil, err
+		}
 		return nil, util.NewReadableError(err, "Could not find sst.config.ts")
 	}
 
@@ -35,11 +53,23 @@ func (c *Cli) InitProject() (*project.Project, error) {
 		return nil, util.NewReadableError(err, "Could not find stage")
 	}
 
+	if concurrency := c.String("build-concurrency"); concurrency != "" {
+		parsed, err := strconv.Atoi(concurrency)
+		if err != nil || parsed < 1 {
+			return nil, util.NewReadableError(nil, "The build concurrency must be a number greater than 0")
+		}
+		// the platform reads it from the environment for the builds it runs
+		flag.SST_BUILD_CONCURRENCY = concurrency
+		os.Setenv("SST_BUILD_CONCURRENCY", concurrency)
+	}
+
+	_, span := telemetry.Span(c.Context, "config.eval")
 	p, err := project.New(&project.ProjectConfig{
 		Version: c.version,
 		Stage:   stage,
 		Config:  cfgPath,
 	})
+	telemetry.EndSpan(span, err)
 	if err != nil {
 		return nil, err
 	}
@@ -52,12 +82,19 @@ func (c *Cli) InitProject() (*project.Project, error) {
 
 	sstLog := p.PathLog("sst")
 	logPath := p.PathLog("")
-	os.RemoveAll(logPath)
+	cleanLogs(logPath)
 	os.MkdirAll(logPath, 0755)
-	nextLogFile, err := os.Create(sstLog)
+	// every session logs to its own file so a command run next to `sst dev`
+	// does not overwrite its log, sst.log points at the latest one
+	sessionLog := filepath.Join(logPath, filepath.Base(logFile.Name()))
+	nextLogFile, err := os.Create(sessionLog)
 	if err != nil {
 		return nil, util.NewReadableError(err, "Could not create log file")
 	}
+	os.Remove(sstLog)
+	if err := os.Symlink(filepath.Base(sessionLog), sstLog); err != nil {
+		slog.Error("failed to link the session log", "err", err)
+	}
 	_, err = io.Copy(nextLogFile, logFile)
 	if err != nil {
 		return nil, util.NewReadableError(err, "Could not copy log file")
@@ -70,6 +107,10 @@ func (c *Cli) InitProject() (*project.Project, error) {
 	logFile = nextLogFile
 	c.configureLog()
 
+	if err := p.CheckLockfile(); err != nil {
+		return nil, err
+	}
+
 	spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 	defer spin.Stop()
 	if !p.CheckPlatform(c.version) {
@@ -84,14 +125,24 @@ func (c *Cli) InitProject() (*project.Project, error) {
 	if p.NeedsInstall() {
 		spin.Suffix = "  Installing providers..."
 		spin.Start()
+		stopTracking := c.TrackDownloads(spin, spin.Suffix)
+		_, span := telemetry.Span(c.Context, "providers.install")
 		err = p.Install()
+		telemetry.EndSpan(span, err)
+		stopTracking()
 		if err != nil {
 			return nil, util.NewReadableError(err, "Could not install dependencies")
 		}
 	}
 
-	if err := p.LoadHome(); err != nil {
-		return nil, err
+	// offline mode never talks to the providers so there is no home to load
+	if !c.Bool("offline") {
+		_, span := telemetry.Span(c.Context, "providers.load")
+		err := p.LoadHome()
+		telemetry.EndSpan(span, err)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	app := p.App()
@@ -101,15 +152,75 @@ func (c *Cli) InitProject() (*project.Project, error) {
 	return p, nil
 }
 
+const sessionLogsKeep = 10
+
+// cleanLogs removes the logs of the previous command but keeps the latest
+// session logs, the ones of a session that is still running included.
+func cleanLogs(dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	sessions := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if strings.HasPrefix(name, "sst-") && strings.HasSuffix(name, ".log") {
+			sessions = append(sessions, name)
+			continue
+		}
+		os.RemoveAll(filepath.Join(dir, name))
+	}
+	// the names start with the time the session started
+	sort.Strings(sessions)
+	for len(sessions) >= sessionLogsKeep {
+		os.Remove(filepath.Join(dir, sessions[0]))
+		sessions = sessions[1:]
+	}
+}
+
+func (c *Cli) logLevel() (slog.Level, error) {
+	value := c.String("log-level")
+	if value == "" {
+		value = flag.SST_LOG_LEVEL
+	}
+	if value == "" {
+		return slog.LevelInfo, nil
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		return level, util.NewReadableError(err, "Invalid log level "+value+", expected debug, info, warn, or error")
+	}
+	return level, nil
+}
+
+func (c *Cli) logFormat() (string, error) {
+	value := c.String("log-format")
+	if value == "" {
+		value = flag.SST_LOG_FORMAT
+	}
+	switch value {
+	case "", "text":
+		return "text", nil
+	case "json":
+		return "json", nil
+	}
+	return "", util.NewReadableError(nil, "Invalid log format "+value+", expected text or json")
+}
+
 func (c *Cli) configureLog() {
 	writers := []io.Writer{logFile}
 	if c.Bool("print-logs") || flag.SST_PRINT_LOGS {
 		writers = append(writers, os.Stderr)
 	}
 	writer := io.MultiWriter(writers...)
-	slog.SetDefault(
-		slog.New(slog.NewTextHandler(writer, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		})),
-	)
+	// invalid values are reported by New
+	level, _ := c.logLevel()
+	options := &slog.HandlerOptions{
+		Level: level,
+	}
+	var handler slog.Handler = slog.NewTextHandler(writer, options)
+	if format, _ := c.logFormat(); format == "json" {
+		handler = slog.NewJSONHandler(writer, options)
+	}
+	slog.SetDefault(slog.New(handler))
 }
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+var CmdCompletion = &cli.Command{
+	Name: "completion",
+	Description: cli.Description{
+		Short: "Generate shell completions",
+		Long: strings.Join([]string{
+			"Print the script that sets up completions for the CLI in your shell.",
+			"",
+			"For bash, add this to your `~/.bashrc`.",
+			"",
+			"```bash frame=\"none\"",
+			"source <(sst completion bash)",
+			"```",
+			"",
+			"For zsh, add this to your `~/.zshrc` after `compinit`.",
+			"",
+			"```bash frame=\"none\"",
+			"source <(sst completion zsh)",
+			"```",
+			"",
+			"For fish, save it to your completions directory.",
+			"",
+			"```bash frame=\"none\"",
+			"sst completion fish > ~/.config/fish/completions/sst.fish",
+			"```",
+			"",
+			"Along with commands and flags, this completes the stages of your app for `--stage` and the names of your secrets.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "shell",
+			Required: true,
+			Complete: func([]string) []string {
+				return []string{"bash", "zsh", "fish"}
+			},
+			Description: cli.Description{
+				Short: "The shell to generate completions for",
+				Long:  "The shell to generate completions for, one of `bash`, `zsh`, or `fish`.",
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		script, err := cli.CompletionScript("sst", c.Positional(0))
+		if err != nil {
+			return util.NewReadableError(err, "Unsupported shell "+c.Positional(0)+", use one of bash, zsh or fish")
+		}
+		fmt.Print(script)
+		return nil
+	},
+}
+
+// completionProject loads just enough of the project in the current directory
+// to read from its home.
+func completionProject(words []string) (*project.Project, error) {
+	cfgPath, err := project.Discover()
+	if err != nil {
+		return nil, err
+	}
+	stage := cli.FlagValue(words, "stage")
+	if stage == "" {
+		stage = os.Getenv("SST_STAGE")
+	}
+	if stage == "" {
+		stage = project.LoadPersonalStage(cfgPath)
+	}
+	if stage == "" {
+		return nil, fmt.Errorf("no stage")
+	}
+	p, err := project.New(&project.ProjectConfig{
+		Version: version,
+		Stage:   stage,
+		Config:  cfgPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, p.LoadHome()
+}
+
+func completeStages(words []string) []string {
+	p, err := completionProject(words)
+	if err != nil {
+		return nil
+	}
+	stages, err := provider.ListStages(p.Backend(), p.App().Name)
+	if err != nil {
+		return nil
+	}
+	sort.Strings(stages)
+	return stages
+}
+
+func completeSecrets(words []string) []string {
+	p, err := completionProject(words)
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, stage := range []string{p.App().Stage, ""} {
+		secrets, err := p.Secrets().GetSecrets(p.App().Name, stage)
+		if err != nil {
+			continue
+		}
+		for name := range secrets {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+)
+
+var CmdPrintConfig = &cli.Command{
+	Name: "print-config",
+	Description: cli.Description{
+		Short: "Print the config of your app for a stage",
+		Long: strings.Join([]string{
+			"Print what the `app` function of your `sst.config.ts` returns for a stage, with the `sst.config.<stage>.ts` of the stage merged over it.",
+			"",
+			"```bash frame=\"none\"",
+			"sst print-config --stage production",
+			"```",
+			"",
+			"It only evaluates the config, nothing is installed or deployed and no credentials are needed.",
+		}, "\n"),
+	},
+	Run: func(c *cli.Cli) error {
+		cfgPath, err := c.Discover()
+		if err != nil {
+			return err
+		}
+		stage, err := c.Stage(cfgPath)
+		if err != nil {
+			return util.NewReadableError(err, "Could not find stage")
+		}
+		p, err := project.New(&project.ProjectConfig{
+			Version: version,
+			Stage:   stage,
+			Config:  cfgPath,
+		})
+		if err != nil {
+			return err
+		}
+		output.Result(p.App())
+		if output.Enabled() {
+			return nil
+		}
+		if override := p.PathConfigOverride(); override != "" {
+			fmt.Fprintln(os.Stderr, ui.TEXT_DIM.Render("Merged "+filepath.Base(override)+" over "+filepath.Base(cfgPath)))
+		}
+		data, err := json.MarshalIndent(p.App(), "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/dev"
+	"github.com/sst/ion/cmd/sst/mosaic/docker"
+	"github.com/sst/ion/cmd/sst/mosaic/watcher"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/path"
+)
+
+// CmdContainer runs the image of a service in its pane of the multiplexer. It
+// is rebuilt when its files change and created again when its links do.
+func CmdContainer(c *cli.Cli) error {
+	name := c.Positional(0)
+	cfgPath, err := c.Discover()
+	if err != nil {
+		return err
+	}
+	url, token, err := discoverServer(c, "", "")
+	if err != nil {
+		return err
+	}
+	evts, err := dev.Connect(c.Context, &dev.ConnectInput{
+		URL:     url,
+		Token:   token,
+		Version: version,
+		Types:   []interface{}{project.CompleteEvent{}, watcher.FileChangedEvent{}},
+		OnError: func(err *dev.DecodeError) {
+			slog.Error("could not decode event from server", "err", err)
+		},
+		OnDisconnect: func(err error) {
+			if err != nil {
+				slog.Error("lost connection to server", "err", err)
+			}
+		},
+	})
+	var mismatch *dev.VersionMismatchError
+	if errors.As(err, &mismatch) {
+		return versionMismatchError(mismatch)
+	}
+	if err != nil {
+		return err
+	}
+
+	container := &docker.Container{
+		Name:   name,
+		ID:     docker.ContainerName(os.Getenv("SST_STAGE"), name),
+		Root:   path.ResolveRootDir(cfgPath),
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	defer container.Remove()
+	env := map[string]string{}
+	changed := []string{}
+	var debounce <-chan time.Time
+	timeout := time.Hour * 24
+	// one timer for the whole loop, so file changes and exits do not push the
+	// renewal of the credentials back
+	renew := time.NewTimer(timeout)
+	defer renew.Stop()
+
+	start := func(build bool) {
+		if build {
+			fmt.Println("[building]")
+			if err := container.Build(c.Context); err != nil {
+				fmt.Println("[build failed]", err)
+				return
+			}
+		}
+		if err := container.Run(c.Context, env); err != nil {
+			fmt.Println("[failed to start]", err)
+		}
+	}
+
+	for {
+		select {
+		case <-c.Context.Done():
+			return nil
+		case <-container.Done():
+			fmt.Println("\n[container exited]")
+			container.Stop()
+		case <-renew.C:
+			renew.Reset(timeout)
+			// there is nothing to restart before the first deploy
+			if container.Config == nil {
+				continue
+			}
+			// renew the credentials of the service
+			nextEnv, err := dev.Env(c.Context, "name="+name, url, token)
+			if err != nil {
+				return err
+			}
+			env = nextEnv
+			fmt.Println("\n[restarting]")
+			start(false)
+		case <-debounce:
+			debounce = nil
+			if container.Config == nil {
+				continue
+			}
+			files, rebuild := container.Plan(changed)
+			changed = []string{}
+			if rebuild {
+				fmt.Println("\n[rebuilding]")
+				start(true)
+				continue
+			}
+			if len(files) > 0 {
+				fmt.Println("\n[restarting]")
+				if err := container.Restart(c.Context, files); err != nil {
+					fmt.Println("[failed to sync]", err)
+				}
+			}
+		case unknown, ok := <-evts:
+			if !ok {
+				return nil
+			}
+			switch evt := unknown.(type) {
+			case *watcher.FileChangedEvent:
+				changed = append(changed, evt.Path)
+				debounce = time.After(time.Millisecond * 300)
+			case *project.CompleteEvent:
+				match, ok := evt.Devs[name]
+				if !ok || match.Docker == nil {
+					continue
+				}
+				nextEnv, err := dev.Env(c.Context, "name="+name, url, token)
+				if err != nil {
+					return err
+				}
+				if _, ok := nextEnv["AWS_ACCESS_KEY_ID"]; ok {
+					timeout = time.Minute * 45
+				}
+				// the credentials were just fetched
+				renew.Reset(timeout)
+				if !reflect.DeepEqual(container.Config, match.Docker) {
+					container.Config = match.Docker
+					env = nextEnv
+					start(true)
+					continue
+				}
+				if container.EnvChanged(nextEnv) {
+					env = nextEnv
+					fmt.Println("\n[restarting]")
+					start(false)
+				}
+			}
+		}
+	}
+}
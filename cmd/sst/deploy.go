@@ -4,9 +4,12 @@ import (
 	"strings"
 
 	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/hooks"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
 	"github.com/sst/ion/pkg/server"
 	"golang.org/x/sync/errgroup"
 )
@@ -17,43 +20,111 @@ func CmdDeploy(c *cli.Cli) error {
 		return err
 	}
 	defer p.Cleanup()
-
-	target := []string{}
-	if c.String("target") != "" {
-		target = strings.Split(c.String("target"), ",")
+	if err := confirmUnprotect(c, p.App().Stage); err != nil {
+		return err
 	}
 
 	var wg errgroup.Group
 	defer wg.Wait()
 	out := make(chan interface{})
 	defer close(out)
-	ui := ui.New(c.Context)
+	opts := []ui.Option{}
+	if c.Bool("profile") {
+		opts = append(opts, ui.WithProfile)
+	}
+	ui := ui.New(c.Context, opts...)
 	s, err := server.New()
 	if err != nil {
 		return err
 	}
+	// sst cancel interrupts the update the same way ctrl+c does
+	s.OnCancel = c.Cancel
 	wg.Go(func() error {
 		defer c.Cancel()
 		return s.Start(c.Context, p)
 	})
+	wg.Go(func() error {
+		return hooks.Start(c.Context, p)
+	})
+	report := newGithubReport(p, "deploy")
 	events := bus.SubscribeAll()
 	defer close(events)
 	wg.Go(func() error {
 		for evt := range events {
 			ui.Event(evt)
+			report.event(evt)
+			if complete, ok := evt.(*project.CompleteEvent); ok && !complete.Old {
+				output.Result(newStackResult(p, complete))
+			}
 		}
 		return nil
 	})
 	defer ui.Destroy()
 	defer c.Cancel()
 	err = p.Run(c.Context, &project.StackInput{
-		Command:    "deploy",
-		Target:     target,
-		ServerPort: s.Port,
-		Verbose:    c.Bool("verbose"),
+		Command:          "deploy",
+		Target:           parseTarget(c),
+		TargetDependents: !c.Bool("skip-dependents"),
+		ServerPort:       s.Port,
+		ServerToken:      s.Token,
+		Verbose:          c.Bool("verbose"),
+		ForceUnprotect:   c.Bool("force-unprotect"),
 	})
 	if err != nil {
 		return err
 	}
 	return nil
 }
+
+var targetFlag = cli.Flag{
+	Name: "target",
+	Type: "string",
+	Description: cli.Description{
+		Short: "Comma separated list of components or URNs",
+		Long:  "Comma separated list of component names or URNs to target. Names can be globs like `Api*` and URNs can have `*` wildcards.",
+	},
+}
+
+var targetDependentsFlag = cli.Flag{
+	Name: "target-dependents",
+	Type: "bool",
+	Description: cli.Description{
+		Short: "Include resources that depend on the targets",
+		Long:  "Also include the resources that depend on the targets passed in with `--target`.",
+	},
+}
+
+var skipDependentsFlag = cli.Flag{
+	Name: "skip-dependents",
+	Type: "bool",
+	Description: cli.Description{
+		Short: "Leave out the resources that depend on the targets",
+		Long:  "Only deploy the targets passed in with `--target` and what they depend on, not the resources that depend on them.",
+	},
+}
+
+func parseTarget(c *cli.Cli) []string {
+	if c.String("target") == "" {
+		return []string{}
+	}
+	return strings.Split(c.String("target"), ",")
+}
+
+// stackResult is the --json result of the commands that run the stack.
+type stackResult struct {
+	App     string                   `json:"app"`
+	Stage   string                   `json:"stage"`
+	Outputs map[string]interface{}   `json:"outputs"`
+	Errors  []project.Error          `json:"errors"`
+	Timings *provider.SummaryTimings `json:"timings,omitempty"`
+}
+
+func newStackResult(p *project.Project, complete *project.CompleteEvent) *stackResult {
+	return &stackResult{
+		App:     p.App().Name,
+		Stage:   p.App().Stage,
+		Outputs: complete.Outputs,
+		Errors:  complete.Errors,
+		Timings: complete.Timings,
+	}
+}
@@ -10,24 +10,110 @@ import (
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/server"
 	"github.com/yalp/jsonpath"
 	"golang.org/x/sync/errgroup"
 )
 
+type diffChange struct {
+	URN        string         `json:"urn"`
+	Op         apitype.OpType `json:"op"`
+	Properties []diffProperty `json:"properties"`
+	output     *apitype.ResOutputsEvent
+}
+
+type diffProperty struct {
+	Path  string           `json:"path"`
+	Kind  apitype.DiffKind `json:"kind"`
+	Value interface{}      `json:"value,omitempty"`
+}
+
+type diffSummary struct {
+	Create  int `json:"create"`
+	Update  int `json:"update"`
+	Replace int `json:"replace"`
+	Delete  int `json:"delete"`
+}
+
+func (s *diffSummary) add(op apitype.OpType) {
+	switch op {
+	case apitype.OpCreate, apitype.OpImport:
+		s.Create++
+	case apitype.OpUpdate:
+		s.Update++
+	case apitype.OpReplace:
+		s.Replace++
+	case apitype.OpDelete:
+		s.Delete++
+	}
+}
+
+func (s *diffSummary) String() string {
+	parts := []string{}
+	if s.Create > 0 {
+		parts = append(parts, fmt.Sprintf("%d to create", s.Create))
+	}
+	if s.Update > 0 {
+		parts = append(parts, fmt.Sprintf("%d to update", s.Update))
+	}
+	if s.Replace > 0 {
+		parts = append(parts, fmt.Sprintf("%d to replace", s.Replace))
+	}
+	if s.Delete > 0 {
+		parts = append(parts, fmt.Sprintf("%d to delete", s.Delete))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func diffChanges(outputs []*apitype.ResOutputsEvent) ([]*diffChange, *diffSummary) {
+	changes := []*diffChange{}
+	summary := &diffSummary{}
+	for _, output := range outputs {
+		switch output.Metadata.Op {
+		case apitype.OpImport, apitype.OpDelete, apitype.OpReplace, apitype.OpUpdate, apitype.OpCreate:
+		default:
+			continue
+		}
+		summary.add(output.Metadata.Op)
+		change := &diffChange{
+			URN:        string(output.Metadata.URN),
+			Op:         output.Metadata.Op,
+			Properties: []diffProperty{},
+			output:     output,
+		}
+		sorted := make([]string, 0, len(output.Metadata.DetailedDiff))
+		for path := range output.Metadata.DetailedDiff {
+			sorted = append(sorted, path)
+		}
+		sort.Strings(sorted)
+		for _, path := range sorted {
+			value, _ := jsonpath.Read(output.Metadata.New.Outputs, "$."+path)
+			if path == "__provider" {
+				value = "code changed"
+			}
+			change.Properties = append(change.Properties, diffProperty{
+				Path:  strings.TrimSpace(path),
+				Kind:  output.Metadata.DetailedDiff[path].Kind,
+				Value: value,
+			})
+		}
+		changes = append(changes, change)
+	}
+	return changes, summary
+}
+
 func CmdDiff(c *cli.Cli) error {
+	if c.Bool("refresh") {
+		return CmdDriftRun(c)
+	}
 	p, err := c.InitProject()
 	if err != nil {
 		return err
 	}
 	defer p.Cleanup()
 
-	target := []string{}
-	if c.String("target") != "" {
-		target = strings.Split(c.String("target"), ",")
-	}
-
 	var wg errgroup.Group
 	defer wg.Wait()
 	outputs := []*apitype.ResOutputsEvent{}
@@ -41,11 +127,13 @@ func CmdDiff(c *cli.Cli) error {
 		return s.Start(c.Context, p)
 	})
 
+	report := newGithubReport(p, "diff")
 	events := bus.SubscribeAll()
 	defer close(events)
 	wg.Go(func() error {
 		for evt := range events {
 			u.Event(evt)
+			report.event(evt)
 			switch evt := evt.(type) {
 			case *apitype.ResOutputsEvent:
 				outputs = append(outputs, evt)
@@ -56,76 +144,59 @@ func CmdDiff(c *cli.Cli) error {
 	defer u.Destroy()
 	defer c.Cancel()
 	err = p.Run(c.Context, &project.StackInput{
-		Command:    "diff",
-		ServerPort: s.Port,
-		Dev:        c.Bool("dev"),
-		Target:     target,
-		Verbose:    c.Bool("verbose"),
+		Command:          "diff",
+		ServerPort:       s.Port,
+		ServerToken:      s.Token,
+		Dev:              c.Bool("dev"),
+		Target:           parseTarget(c),
+		Verbose:          c.Bool("verbose"),
+		TargetDependents: c.Bool("target-dependents"),
 	})
 	if err != nil {
 		return err
 	}
-	if len(outputs) == 0 {
+	changes, summary := diffChanges(outputs)
+	output.Result(map[string]interface{}{
+		"changes": changes,
+		"summary": summary,
+	})
+	printDiff(u, changes, summary)
+	return nil
+}
+
+func printDiff(u *ui.UI, changes []*diffChange, summary *diffSummary) {
+	if len(changes) == 0 {
 		fmt.Println(
 			ui.TEXT_HIGHLIGHT_BOLD.Render("➜"),
 			ui.TEXT_NORMAL_BOLD.Render(" No changes"),
 		)
 		fmt.Println()
-		return nil
+		return
 	}
-	for _, output := range outputs {
+	for _, change := range changes {
 		icon := ""
-		if output.Metadata.Op == apitype.OpImport {
+		switch change.Op {
+		case apitype.OpImport, apitype.OpReplace, apitype.OpCreate:
 			icon = ui.TEXT_SUCCESS_BOLD.Render("+")
-		}
-		if output.Metadata.Op == apitype.OpDelete {
+		case apitype.OpDelete:
 			icon = ui.TEXT_DANGER_BOLD.Render("-")
-		}
-		if output.Metadata.Op == apitype.OpReplace {
-			icon = ui.TEXT_SUCCESS_BOLD.Render("+")
-		}
-		if output.Metadata.Op == apitype.OpUpdate {
+		case apitype.OpUpdate:
 			icon = ui.TEXT_WARNING_BOLD.Render("*")
 		}
-		if output.Metadata.Op == apitype.OpCreate {
-			icon = ui.TEXT_SUCCESS_BOLD.Render("+")
-		}
-		if icon == "" {
-			continue
-		}
 
-		fmt.Println(icon, "", ui.TEXT_NORMAL_BOLD.Render(u.FormatURN(output.Metadata.URN)))
-		sorted := make([]string, 0, len(output.Metadata.DetailedDiff))
-		for path := range output.Metadata.DetailedDiff {
-			sorted = append(sorted, path)
-		}
-		sort.Strings(sorted)
-		for _, path := range sorted {
-			diff := output.Metadata.DetailedDiff[path]
+		fmt.Println(icon, "", ui.TEXT_NORMAL_BOLD.Render(u.FormatURN(change.output.Metadata.URN)))
+		for _, property := range change.Properties {
 			label := ""
-			if diff.Kind == apitype.DiffUpdate {
+			switch property.Kind {
+			case apitype.DiffUpdate, apitype.DiffUpdateReplace:
 				label = ui.TEXT_WARNING_BOLD.Render("*")
-			}
-			if diff.Kind == apitype.DiffDelete {
+			case apitype.DiffDelete, apitype.DiffDeleteReplace:
 				label = ui.TEXT_DANGER_BOLD.Render("-")
-			}
-			if diff.Kind == apitype.DiffAdd {
-				label = ui.TEXT_SUCCESS_BOLD.Render("+")
-			}
-			if diff.Kind == apitype.DiffAddReplace {
+			case apitype.DiffAdd, apitype.DiffAddReplace:
 				label = ui.TEXT_SUCCESS_BOLD.Render("+")
 			}
-			if diff.Kind == apitype.DiffUpdateReplace {
-				label = ui.TEXT_WARNING_BOLD.Render("*")
-			}
-			if diff.Kind == apitype.DiffDeleteReplace {
-				label = ui.TEXT_DANGER_BOLD.Render("-")
-			}
-			fmt.Print("   ", label+" ", strings.TrimSpace(path))
-			value, _ := jsonpath.Read(output.Metadata.New.Outputs, "$."+path)
-			if path == "__provider" {
-				value = "code changed"
-			}
+			fmt.Print("   ", label+" ", property.Path)
+			value := property.Value
 			if value != nil {
 				formatted := ""
 				switch value.(type) {
@@ -149,5 +220,9 @@ func CmdDiff(c *cli.Cli) error {
 		}
 		fmt.Println()
 	}
-	return nil
+	fmt.Println(
+		ui.TEXT_HIGHLIGHT_BOLD.Render("➜"),
+		ui.TEXT_NORMAL_BOLD.Render(" "+summary.String()),
+	)
+	fmt.Println()
 }
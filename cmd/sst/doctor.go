@@ -0,0 +1,335 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/global"
+	"github.com/sst/ion/pkg/network"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/server"
+)
+
+var CmdDoctor = &cli.Command{
+	Name: "doctor",
+	Description: cli.Description{
+		Short: "Check your environment for problems",
+		Long: strings.Join([]string{
+			"Run a set of checks on your machine and your app and print how to fix anything that is wrong.",
+			"",
+			"```bash frame=\"none\"",
+			"sst doctor --stage production",
+			"```",
+			"",
+			"It checks the CLI dependencies, that the platform in `.sst/platform` matches the CLI, your provider credentials, access to the state of your app, any leftover locks or servers, your clock, and your network.",
+			"",
+			"Pass in `--json` and attach the output when reporting a bug.",
+		}, "\n"),
+	},
+	Run: CmdDoctorRun,
+}
+
+const (
+	doctorOK   = "ok"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+)
+
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Fix     string `json:"fix,omitempty"`
+}
+
+func CmdDoctorRun(c *cli.Cli) error {
+	checks := []*doctorCheck{
+		{Name: "CLI", Status: doctorOK, Message: fmt.Sprintf("sst %s on %s/%s", version, runtime.GOOS, runtime.GOARCH)},
+		doctorDependencies(),
+		doctorNode(),
+	}
+	// loading the project upgrades the platform, so it is checked first
+	cfgPath, _ := c.Discover()
+	var platform *doctorCheck
+	if cfgPath != "" {
+		platform = doctorPlatform(cfgPath)
+		checks = append(checks, platform)
+	}
+	p, err := c.InitProject()
+	if err != nil {
+		checks = append(checks, &doctorCheck{
+			Name:    "Project",
+			Status:  doctorFail,
+			Message: err.Error(),
+			Fix:     "Run this in the directory of your sst.config.ts and check that your provider credentials are set",
+		})
+	} else {
+		defer p.Cleanup()
+		checks = append(checks, &doctorCheck{
+			Name:    "Project",
+			Status:  doctorOK,
+			Message: fmt.Sprintf("%s / %s using the %s home", p.App().Name, p.App().Stage, p.App().Home),
+		})
+		if platform != nil && platform.Status == doctorFail && project.InstalledPlatform(cfgPath) == project.PlatformVersion(version) {
+			platform.Status = doctorWarn
+			platform.Fix = "It was upgraded when the project was loaded just now"
+		}
+		checks = append(checks, doctorCredentials(c.Context, p)...)
+		checks = append(checks, doctorState(p))
+	}
+	checks = append(checks, doctorServers(c.Context))
+	checks = append(checks, doctorClock(c.Context))
+	checks = append(checks, doctorNetwork(c.Context))
+
+	output.Result(checks)
+	failed := 0
+	for _, check := range checks {
+		switch check.Status {
+		case doctorOK:
+			color.New(color.FgGreen, color.Bold).Print("✓ ")
+		case doctorWarn:
+			color.New(color.FgYellow, color.Bold).Print("! ")
+		case doctorFail:
+			failed++
+			color.New(color.FgRed, color.Bold).Print("✕ ")
+		}
+		color.New(color.FgWhite, color.Bold).Printf(" %-14s", check.Name)
+		color.New(color.FgWhite).Println(check.Message)
+		if check.Fix != "" {
+			color.New(color.FgHiBlack).Println("                 ↳ " + check.Fix)
+		}
+	}
+	if failed > 0 {
+		fmt.Println()
+		return util.NewReadableError(nil, fmt.Sprintf("%d checks failed", failed))
+	}
+	return nil
+}
+
+func doctorDependencies() *doctorCheck {
+	missing := []string{}
+	if global.NeedsPulumi() {
+		missing = append(missing, "pulumi")
+	}
+	if global.NeedsBun() {
+		missing = append(missing, "bun")
+	}
+	if len(missing) > 0 {
+		return &doctorCheck{
+			Name:    "Dependencies",
+			Status:  doctorFail,
+			Message: strings.Join(missing, " and ") + " missing or outdated in " + global.BinPath(),
+			Fix:     "Run any command without SST_SKIP_DEPENDENCY_CHECK set to install them",
+		}
+	}
+	return &doctorCheck{Name: "Dependencies", Status: doctorOK, Message: "pulumi and bun installed in " + global.BinPath()}
+}
+
+func doctorPlatform(cfgPath string) *doctorCheck {
+	installed := project.InstalledPlatform(cfgPath)
+	want := project.PlatformVersion(version)
+	if installed == "" {
+		return &doctorCheck{
+			Name:    "Platform",
+			Status:  doctorWarn,
+			Message: "not installed in .sst/platform",
+			Fix:     "It is installed the first time a command loads the project",
+		}
+	}
+	if installed != want {
+		return &doctorCheck{
+			Name:    "Platform",
+			Status:  doctorFail,
+			Message: fmt.Sprintf(".sst/platform is %s but the CLI is %s", installed, want),
+			Fix:     "Run `sst install`, or remove .sst/platform and run any command",
+		}
+	}
+	return &doctorCheck{Name: "Platform", Status: doctorOK, Message: ".sst/platform matches the CLI"}
+}
+
+func doctorNode() *doctorCheck {
+	path, err := exec.LookPath("node")
+	if err != nil {
+		return &doctorCheck{
+			Name:    "Node",
+			Status:  doctorWarn,
+			Message: "node not found in PATH",
+			Fix:     "Install Node.js, it is needed to run Node functions in dev",
+		}
+	}
+	out, err := exec.Command(path, "--version").Output()
+	if err != nil {
+		return &doctorCheck{Name: "Node", Status: doctorWarn, Message: "could not run " + path + ": " + err.Error()}
+	}
+	return &doctorCheck{Name: "Node", Status: doctorOK, Message: strings.TrimSpace(string(out)) + " at " + path}
+}
+
+func doctorCredentials(ctx context.Context, p *project.Project) []*doctorCheck {
+	checks := []*doctorCheck{}
+	if prov, ok := p.Provider("aws"); ok {
+		cfg := prov.(*provider.AwsProvider).Config()
+		identity, err := sts.NewFromConfig(cfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+		if err != nil {
+			checks = append(checks, &doctorCheck{
+				Name:    "AWS",
+				Status:  doctorFail,
+				Message: err.Error(),
+				Fix:     "Check your AWS credentials, if you are using SSO run `aws sso login`",
+			})
+		} else {
+			checks = append(checks, &doctorCheck{
+				Name:    "AWS",
+				Status:  doctorOK,
+				Message: fmt.Sprintf("%s in %s", aws.ToString(identity.Arn), cfg.Region),
+			})
+		}
+	}
+	if _, ok := p.Provider("cloudflare"); ok {
+		checks = append(checks, &doctorCheck{Name: "Cloudflare", Status: doctorOK, Message: "credentials loaded"})
+	}
+	if prov, ok := p.Provider("gcp"); ok {
+		gcp := prov.(*provider.GcpProvider)
+		checks = append(checks, &doctorCheck{Name: "GCP", Status: doctorOK, Message: fmt.Sprintf("%s in %s", gcp.Project(), gcp.Region())})
+	}
+	if prov, ok := p.Provider("azure"); ok {
+		azure := prov.(*provider.AzureProvider)
+		checks = append(checks, &doctorCheck{Name: "Azure", Status: doctorOK, Message: fmt.Sprintf("subscription %s in %s", azure.Subscription(), azure.Location())})
+	}
+	return checks
+}
+
+func doctorState(p *project.Project) *doctorCheck {
+	lock, err := provider.GetLock(p.Backend(), p.App().Name, p.App().Stage)
+	if err != nil {
+		return &doctorCheck{
+			Name:    "State",
+			Status:  doctorFail,
+			Message: "could not read the state: " + err.Error(),
+			Fix:     "Check that your credentials can access the bootstrap bucket of the " + p.App().Home + " home",
+		}
+	}
+	if lock != nil && lock.Expired() {
+		return &doctorCheck{
+			Name:    "State",
+			Status:  doctorWarn,
+			Message: fmt.Sprintf("locked by `sst %s` but its lease expired at %s", lock.Command, lock.Expires.Local().Format(time.RFC822)),
+			Fix:     "The next update takes it over, or run `sst unlock` to clear it",
+		}
+	}
+	if lock != nil {
+		return &doctorCheck{
+			Name:    "State",
+			Status:  doctorWarn,
+			Message: fmt.Sprintf("locked by `sst %s` since %s", lock.Command, lock.Created.Local().Format(time.RFC822)),
+			Fix:     "If nothing is running against this stage, run `sst unlock --force`",
+		}
+	}
+	return &doctorCheck{Name: "State", Status: doctorOK, Message: "readable and not locked"}
+}
+
+func doctorServers(ctx context.Context) *doctorCheck {
+	processes, err := server.ListProcesses()
+	if err != nil {
+		return &doctorCheck{Name: "Servers", Status: doctorWarn, Message: err.Error()}
+	}
+	live := []string{}
+	stale := 0
+	for _, proc := range processes {
+		if _, err := server.Probe(ctx, proc); err != nil {
+			server.RemoveProcess(proc)
+			stale++
+			continue
+		}
+		live = append(live, filepath.Dir(proc.Config)+" / "+proc.Stage)
+	}
+	message := fmt.Sprintf("%d running", len(live))
+	if len(live) > 0 {
+		message += ": " + strings.Join(live, ", ")
+	}
+	if stale > 0 {
+		message += fmt.Sprintf(", cleaned up %d left behind by servers that crashed", stale)
+	}
+	return &doctorCheck{Name: "Servers", Status: doctorOK, Message: message}
+}
+
+// doctorClock compares the local clock to the one on AWS, requests are
+// rejected once they are more than 5 minutes apart.
+func doctorClock(ctx context.Context) *doctorCheck {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodHead, "https://sts.amazonaws.com", nil)
+	res, err := network.Client(0).Do(req)
+	if err != nil {
+		return &doctorCheck{Name: "Clock", Status: doctorWarn, Message: "could not check: " + err.Error()}
+	}
+	res.Body.Close()
+	remote, err := http.ParseTime(res.Header.Get("Date"))
+	if err != nil {
+		return &doctorCheck{Name: "Clock", Status: doctorWarn, Message: "could not check: " + err.Error()}
+	}
+	skew := time.Since(remote).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	switch {
+	case skew > 5*time.Minute:
+		return &doctorCheck{Name: "Clock", Status: doctorFail, Message: fmt.Sprintf("off by %s", skew), Fix: "Sync your system clock, AWS rejects requests from clocks that are off by more than 5 minutes"}
+	case skew > time.Minute:
+		return &doctorCheck{Name: "Clock", Status: doctorWarn, Message: fmt.Sprintf("off by %s", skew), Fix: "Sync your system clock"}
+	}
+	return &doctorCheck{Name: "Clock", Status: doctorOK, Message: "in sync"}
+}
+
+func doctorNetwork(ctx context.Context) *doctorCheck {
+	if err := network.Check(); err != nil {
+		return &doctorCheck{
+			Name:    "Network",
+			Status:  doctorFail,
+			Message: "could not load the ca bundle: " + err.Error(),
+			Fix:     "Point SST_CA_BUNDLE or NODE_EXTRA_CA_CERTS at a file of PEM certificates",
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	client := network.Client(0)
+	failed := []string{}
+	for _, url := range []string{
+		"https://registry.npmjs.org",
+		"https://api.github.com",
+		"https://sts.amazonaws.com",
+	} {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+		res, err := client.Do(req)
+		if err != nil {
+			failed = append(failed, url)
+			continue
+		}
+		res.Body.Close()
+	}
+	if len(failed) > 0 {
+		return &doctorCheck{
+			Name:    "Network",
+			Status:  doctorFail,
+			Message: "could not reach " + strings.Join(failed, ", "),
+			Fix:     "Check your connection, HTTPS_PROXY if you are behind a proxy, and SST_CA_BUNDLE if it uses a private certificate authority",
+		}
+	}
+	message := "npm, GitHub, and AWS are reachable"
+	if bundle := network.Bundle(); bundle != "" {
+		message += ", trusting the certificates in " + bundle
+	}
+	return &doctorCheck{Name: "Network", Status: doctorOK, Message: message}
+}
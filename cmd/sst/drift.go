@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/server"
+	"github.com/yalp/jsonpath"
+	"golang.org/x/sync/errgroup"
+)
+
+var CmdDrift = &cli.Command{
+	Name: "drift",
+	Description: cli.Description{
+		Short: "Check for resources changed outside of SST",
+		Long: strings.Join([]string{
+			"Compare the resources in your cloud provider to the state of your app and list the ones that were changed outside of SST, like in the console.",
+			"",
+			"```bash frame=\"none\"",
+			"sst drift --stage production",
+			"```",
+			"",
+			"Nothing is changed, not even the state. Run `sst refresh` to update the state to match, or `sst deploy` to put the resources back.",
+			"",
+			"It exits with an error if anything drifted, so it can run on a schedule in CI. Pass in `--json` to get the drifted resources and their properties.",
+			"",
+			"```bash frame=\"none\"",
+			"sst drift --json",
+			"```",
+			"",
+			"This is the same as `sst diff --refresh`.",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		targetFlag,
+	},
+	Run: CmdDriftRun,
+}
+
+type driftProperty struct {
+	Path     string           `json:"path"`
+	Kind     apitype.DiffKind `json:"kind"`
+	Expected interface{}      `json:"expected,omitempty"`
+	Actual   interface{}      `json:"actual,omitempty"`
+}
+
+type driftChange struct {
+	URN string `json:"urn"`
+	// Deleted is set when the resource no longer exists
+	Deleted    bool            `json:"deleted"`
+	Properties []driftProperty `json:"properties"`
+	output     *apitype.ResOutputsEvent
+}
+
+// driftChanges picks the resources a refresh would change out of the events
+// of a refresh preview.
+func driftChanges(outputs []*apitype.ResOutputsEvent) []*driftChange {
+	changes := []*driftChange{}
+	for _, output := range outputs {
+		meta := output.Metadata
+		deleted := meta.Op == apitype.OpDelete || (meta.Op == apitype.OpRefresh && meta.New == nil && meta.Old != nil)
+		if !deleted && len(meta.DetailedDiff) == 0 && len(meta.Diffs) == 0 {
+			continue
+		}
+		change := &driftChange{
+			URN:        string(meta.URN),
+			Deleted:    deleted,
+			Properties: []driftProperty{},
+			output:     output,
+		}
+		paths := map[string]apitype.DiffKind{}
+		for path, diff := range meta.DetailedDiff {
+			paths[path] = diff.Kind
+		}
+		for _, path := range meta.Diffs {
+			if _, ok := paths[path]; !ok {
+				paths[path] = apitype.DiffUpdate
+			}
+		}
+		sorted := make([]string, 0, len(paths))
+		for path := range paths {
+			sorted = append(sorted, path)
+		}
+		sort.Strings(sorted)
+		for _, path := range sorted {
+			property := driftProperty{Path: strings.TrimSpace(path), Kind: paths[path]}
+			if meta.Old != nil {
+				property.Expected, _ = jsonpath.Read(meta.Old.Outputs, "$."+path)
+			}
+			if meta.New != nil {
+				property.Actual, _ = jsonpath.Read(meta.New.Outputs, "$."+path)
+			}
+			change.Properties = append(change.Properties, property)
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func CmdDriftRun(c *cli.Cli) error {
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+
+	var wg errgroup.Group
+	defer wg.Wait()
+	outputs := []*apitype.ResOutputsEvent{}
+	u := ui.New(c.Context)
+	s, err := server.New()
+	if err != nil {
+		return err
+	}
+	wg.Go(func() error {
+		defer c.Cancel()
+		return s.Start(c.Context, p)
+	})
+
+	events := bus.SubscribeAll()
+	defer close(events)
+	wg.Go(func() error {
+		for evt := range events {
+			u.Event(evt)
+			switch evt := evt.(type) {
+			case *apitype.ResOutputsEvent:
+				outputs = append(outputs, evt)
+			}
+		}
+		return nil
+	})
+	defer u.Destroy()
+	defer c.Cancel()
+	err = p.Run(c.Context, &project.StackInput{
+		Command:     "drift",
+		ServerPort:  s.Port,
+		ServerToken: s.Token,
+		Target:      parseTarget(c),
+		Verbose:     c.Bool("verbose"),
+	})
+	if err != nil {
+		return err
+	}
+	changes := driftChanges(outputs)
+	output.Result(map[string]interface{}{
+		"drifted": changes,
+	})
+	printDrift(u, changes)
+	if len(changes) > 0 {
+		return util.NewReadableError(nil, fmt.Sprintf("%d resources drifted", len(changes)))
+	}
+	return nil
+}
+
+func printDrift(u *ui.UI, changes []*driftChange) {
+	if len(changes) == 0 {
+		fmt.Println(
+			ui.TEXT_HIGHLIGHT_BOLD.Render("➜"),
+			ui.TEXT_NORMAL_BOLD.Render(" No drift"),
+		)
+		fmt.Println()
+		return
+	}
+	for _, change := range changes {
+		if change.Deleted {
+			fmt.Println(ui.TEXT_DANGER_BOLD.Render("-"), "", ui.TEXT_NORMAL_BOLD.Render(u.FormatURN(change.output.Metadata.URN)), ui.TEXT_DIM.Render("deleted"))
+			fmt.Println()
+			continue
+		}
+		fmt.Println(ui.TEXT_WARNING_BOLD.Render("*"), "", ui.TEXT_NORMAL_BOLD.Render(u.FormatURN(change.output.Metadata.URN)))
+		for _, property := range change.Properties {
+			fmt.Println("   "+ui.TEXT_WARNING_BOLD.Render("*")+" "+property.Path, ui.TEXT_DIM.Render(driftValue(property.Expected)+" ➜ "+driftValue(property.Actual)))
+		}
+		fmt.Println()
+	}
+}
+
+func driftValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "(none)"
+	case string:
+		return value
+	}
+	bytes, _ := json.Marshal(value)
+	return string(bytes)
+}
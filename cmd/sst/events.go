@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/server"
+)
+
+var CmdEvents = &cli.Command{
+	Name: "events",
+	Description: cli.Description{
+		Short: "Inspect recorded events",
+		Long: strings.Join([]string{
+			"Inspect the events recorded while running a command.",
+			"",
+			"Every event is written to `.sst/log/events-<timestamp>.ndjson`. This is useful for figuring out what happened in a run that failed in CI.",
+		}, "\n"),
+	},
+	Children: []*cli.Command{
+		{
+			Name: "replay",
+			Description: cli.Description{
+				Short: "Replay a recorded run",
+				Long: strings.Join([]string{
+					"Render the events in a recorded file the same way they were shown when the command ran.",
+					"",
+					"```bash frame=\"none\"",
+					"sst events replay .sst/log/events-20240101T120000.000.ndjson",
+					"```",
+				}, "\n"),
+			},
+			Args: []cli.Argument{
+				{
+					Name:     "file",
+					Required: true,
+					Description: cli.Description{
+						Short: "The file to replay",
+						Long:  "The file to replay.",
+					},
+				},
+			},
+			Run: CmdEventsReplay,
+		},
+	},
+}
+
+func CmdEventsReplay(c *cli.Cli) error {
+	file, err := os.Open(c.Positional(0))
+	if err != nil {
+		return util.NewReadableError(err, "Could not open "+c.Positional(0))
+	}
+	defer file.Close()
+
+	types := map[string]reflect.Type{}
+	for _, evt := range append(append([]interface{}{}, stackEvents...), functionEvents...) {
+		types[bus.Topic(evt)] = reflect.TypeOf(evt)
+	}
+
+	u := ui.New(c.Context)
+	defer u.Destroy()
+	reader := bufio.NewReader(file)
+	for {
+		line, err := reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			var entry server.JournalEntry
+			if err := json.Unmarshal(line, &entry); err != nil {
+				return util.NewReadableError(err, "Could not read "+c.Positional(0)+", is it an event journal?")
+			}
+			if t, ok := types[entry.Type]; ok {
+				target := reflect.New(t).Interface()
+				if err := json.Unmarshal(entry.Event, target); err != nil {
+					slog.Error("could not decode event", "type", entry.Type, "err", err)
+				} else {
+					u.Event(target)
+				}
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+	return nil
+}
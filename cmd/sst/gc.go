@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/server"
+	"golang.org/x/sync/errgroup"
+)
+
+var CmdStateGC = &cli.Command{
+	Name: "gc",
+	Description: cli.Description{
+		Short: "Clean up orphaned resources in the state",
+		Long: strings.Join([]string{
+			"Find the resources in the state of your app that no deploy will clean up and remove them from the state.",
+			"",
+			"These are resources whose parent is gone, old copies of replaced resources that could not be deleted, and operations left behind by an update that was interrupted.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state gc",
+			"```",
+			"",
+			"The orphans are listed before anything is changed. Only the state is changed, the resources are left in your cloud provider. Pass in `--delete` to delete them there too, along with anything that depends on them.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state gc --delete",
+			"```",
+			"",
+			"Operations of an interrupted update and old copies of replaced resources are only removed from the state, check your cloud provider for them.",
+			"",
+			"A backup of the state is written to `.sst/backup` before it is changed.",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		stateYesFlag,
+		{
+			Name: "delete",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Delete the resources too",
+				Long:  "Delete the orphaned resources in your cloud provider, not just in the state.",
+			},
+		},
+	},
+	Run: CmdStateGCRun,
+}
+
+func CmdStateGCRun(c *cli.Cli) error {
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+
+	state, err := p.ReadState()
+	if err != nil {
+		return util.NewReadableError(err, "Could not read state")
+	}
+	orphans := state.Orphans()
+	output.Result(orphans)
+	if len(orphans) == 0 {
+		color.New(color.FgGreen, color.Bold).Print("✓ ")
+		color.New(color.FgWhite).Println(" No orphaned resources")
+		return nil
+	}
+	for _, orphan := range orphans {
+		color.New(color.FgRed, color.Bold).Print("- ")
+		color.New(color.FgWhite, color.Bold).Print(orphan.URN)
+		color.New(color.FgHiBlack).Println("  " + orphan.Reason)
+	}
+	fmt.Println()
+
+	if c.Bool("delete") {
+		targets := []string{}
+		for _, orphan := range orphans {
+			if !orphan.Pending && !orphan.Replaced {
+				targets = append(targets, orphan.URN)
+			}
+		}
+		if len(targets) > 0 {
+			ok, err := confirm(c, fmt.Sprintf("Delete %d orphaned resources?", len(targets)), "Pass in --yes to delete without a confirmation prompt")
+			if err != nil || !ok {
+				return err
+			}
+			err = gcDelete(c, p, targets)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return editProjectState(c, p, fmt.Sprintf("Remove %d orphaned resources from the state", len(orphans)), func(p *project.Project, state *project.State) error {
+		state.RemoveOrphans(state.Orphans())
+		return nil
+	})
+}
+
+// gcDelete removes the targets with the engine so the resources are deleted
+// in the cloud provider along with their state.
+func gcDelete(c *cli.Cli, p *project.Project, targets []string) error {
+	var wg errgroup.Group
+	defer wg.Wait()
+	u := ui.New(c.Context)
+	s, err := server.New()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(c.Context)
+	defer cancel()
+	wg.Go(func() error {
+		defer cancel()
+		return s.Start(ctx, p)
+	})
+	events := bus.SubscribeAll()
+	defer close(events)
+	wg.Go(func() error {
+		for evt := range events {
+			u.Event(evt)
+		}
+		return nil
+	})
+	defer u.Destroy()
+	return p.Run(ctx, &project.StackInput{
+		Command:     "remove",
+		Target:      targets,
+		ServerPort:  s.Port,
+		ServerToken: s.Token,
+		Verbose:     c.Bool("verbose"),
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/pkg/cli/github"
+	"github.com/sst/ion/pkg/project"
+)
+
+// githubReport turns the events of a stack command into annotations and the
+// job summary when running in GitHub Actions.
+type githubReport struct {
+	p       *project.Project
+	command string
+	outputs []*apitype.ResOutputsEvent
+}
+
+func newGithubReport(p *project.Project, command string) *githubReport {
+	return &githubReport{p: p, command: command}
+}
+
+func (r *githubReport) event(evt interface{}) {
+	if !github.Enabled() {
+		return
+	}
+	switch evt := evt.(type) {
+	case *apitype.ResOutputsEvent:
+		r.outputs = append(r.outputs, evt)
+	case *apitype.DiagnosticEvent:
+		if evt.Severity == "warning" {
+			github.Annotate(&github.Annotation{
+				Level:   github.LevelWarning,
+				Title:   annotationTitle(evt.URN),
+				Message: strings.TrimSpace(evt.Message),
+			})
+		}
+	case *project.BuildFailedEvent:
+		github.Annotate(&github.Annotation{
+			Level:   github.LevelError,
+			Title:   "Build failed",
+			Message: evt.Error,
+		})
+	case *project.CompleteEvent:
+		if evt.Old {
+			return
+		}
+		for _, item := range evt.Errors {
+			github.Annotate(&github.Annotation{
+				Level:   github.LevelError,
+				Title:   annotationTitle(item.URN),
+				Message: strings.Join(append([]string{item.Message}, item.Help...), "\n"),
+			})
+		}
+		github.Result(r.summary(evt))
+	}
+}
+
+func (r *githubReport) summary(complete *project.CompleteEvent) *github.Summary {
+	summary := &github.Summary{
+		Command: r.command,
+		App:     r.p.App().Name,
+		Stage:   r.p.App().Stage,
+		Changes: []github.Change{},
+		Outputs: map[string]interface{}{},
+		Errors:  []string{},
+	}
+	changes, _ := diffChanges(r.outputs)
+	for _, change := range changes {
+		urn := resource.URN(change.URN)
+		summary.Changes = append(summary.Changes, github.Change{
+			Op:   string(change.Op),
+			Name: urn.Name(),
+			Type: string(urn.Type()),
+		})
+	}
+	for key, value := range complete.Outputs {
+		if secret, ok := value.(map[string]interface{}); ok && secret["ciphertext"] != nil {
+			continue
+		}
+		summary.Outputs[key] = value
+	}
+	for _, item := range complete.Errors {
+		summary.Errors = append(summary.Errors, item.Message)
+	}
+	return summary
+}
+
+func annotationTitle(urn string) string {
+	if urn == "" {
+		return ""
+	}
+	return resource.URN(urn).Name()
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+)
+
+var CmdGraph = &cli.Command{
+	Name: "graph",
+	Description: cli.Description{
+		Short: "Print the dependency graph of your app",
+		Long: strings.Join([]string{
+			"Print the components of your app and how they depend on each other, as they were last deployed.",
+			"",
+			"```bash frame=\"none\"",
+			"sst graph --stage production | dot -Tsvg > graph.svg",
+			"```",
+			"",
+			"An arrow points from a component to the one it depends on. Use `--resources` to show every resource, grouped by the component it belongs to.",
+			"",
+			"Use `--format` to pick the output.",
+			"",
+			"- `dot`: for [Graphviz](https://graphviz.org), the default.",
+			"- `mermaid`: a flowchart you can paste in Markdown on GitHub.",
+			"- `json`: the groups and edges of the graph.",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "format",
+			Type: "string",
+			Complete: func([]string) []string {
+				return []string{"dot", "mermaid", "json"}
+			},
+			Description: cli.Description{
+				Short: "The format to print in",
+				Long:  "The format to print the graph in, one of `dot`, `mermaid`, or `json`.",
+			},
+		},
+		{
+			Name: "resources",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Show every resource",
+				Long:  "Show every resource instead of only the top level components.",
+			},
+		},
+	},
+	Run: CmdGraphRun,
+}
+
+func CmdGraphRun(c *cli.Cli) error {
+	format := c.String("format")
+	if format == "" {
+		format = "dot"
+	}
+	if format != "dot" && format != "mermaid" && format != "json" {
+		return util.NewReadableError(nil, "Invalid --format "+format+", use one of dot, mermaid or json")
+	}
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+	state, err := p.ReadState()
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the state of stage "+p.App().Stage)
+	}
+	graph := project.NewGraph(state.Resources(), c.Bool("resources"))
+	output.Result(graph)
+	switch format {
+	case "dot":
+		fmt.Print(graph.DOT())
+	case "mermaid":
+		fmt.Print(graph.Mermaid())
+	case "json":
+		data, err := json.MarshalIndent(graph, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+var CmdHistory = &cli.Command{
+	Name: "history",
+	Description: cli.Description{
+		Short: "Show what past updates did",
+		Long: strings.Join([]string{
+			"Show the receipts of past updates of your app.",
+			"",
+			"A receipt is written to `.sst/receipts` at the end of every deploy, remove, and refresh, except for the ones of `sst dev`. It has the deploy ID, the git commit, a hash of your config, the resources that changed, the outputs, how long it took, and who ran it.",
+			"",
+			"Set `history.receipts` in your `sst.config.ts` to also upload them next to the checkpoints of the state, so they can be read from other machines. Set `history.retain` to only keep the receipts of the newest updates, like the checkpoints.",
+		}, "\n"),
+	},
+	Children: []*cli.Command{
+		CmdHistoryShow,
+	},
+}
+
+var CmdHistoryShow = &cli.Command{
+	Name: "show",
+	Description: cli.Description{
+		Short: "Print the receipt of an update",
+		Long: strings.Join([]string{
+			"Print the receipt of an update as JSON.",
+			"",
+			"```bash frame=\"none\"",
+			"sst history show <id> --stage production",
+			"```",
+			"",
+			"The id is the deploy ID of the update, or the id of its checkpoint in `sst state history`.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "id",
+			Required: true,
+			Description: cli.Description{
+				Short: "The update to show",
+				Long:  "The deploy ID of the update, or the id of its checkpoint.",
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		id := c.Positional(0)
+		receipt, err := p.ReadReceipt(id)
+		if errors.Is(err, provider.ErrReceiptNotFound) {
+			history, historyErr := provider.ListHistory(p.Backend(), p.App().Name, p.App().Stage)
+			if historyErr != nil {
+				return util.NewReadableError(historyErr, "Could not list the checkpoints of stage "+p.App().Stage)
+			}
+			if selected := findCheckpoint(history, id); selected != nil {
+				receipt, err = p.ReadReceipt(selected.UpdateID)
+			}
+		}
+		if err != nil {
+			return receiptError(p, id, err)
+		}
+		output.Result(receipt)
+		data, err := json.MarshalIndent(receipt, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+func receiptError(p *project.Project, id string, err error) error {
+	if errors.Is(err, provider.ErrReceiptNotFound) {
+		message := "No receipt for " + id + " in stage " + p.App().Stage
+		if !p.App().History.Receipts {
+			message += ". Receipts are only on this machine unless `history.receipts` is set in your config"
+		}
+		return util.NewReadableError(err, message)
+	}
+	return util.NewReadableError(err, "Could not read the receipt of "+id)
+}
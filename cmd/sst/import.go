@@ -0,0 +1,91 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project"
+)
+
+var CmdImport = &cli.Command{
+	Name: "import",
+	Description: cli.Description{
+		Short: "Import an existing resource",
+		Long: strings.Join([]string{
+			"Import an existing cloud resource into the state of your app, as the resource a component would create.",
+			"",
+			"```bash frame=\"none\"",
+			"sst import sst.aws.Bucket MyBucket my-existing-bucket",
+			"```",
+			"",
+			"Then add the component with the same name to your `sst.config.ts`. The settings of the imported resource are printed so you can set them through the component's `transform` and the next deploy does not change it.",
+			"",
+			"The following components can be imported: " + strings.Join(project.ImportableTypes(), ", ") + ".",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "type",
+			Required: true,
+			Description: cli.Description{
+				Short: "The type of the component",
+				Long:  "The type of the component, like `sst.aws.Bucket`.",
+			},
+		},
+		{
+			Name:     "name",
+			Required: true,
+			Description: cli.Description{
+				Short: "The name of the component",
+				Long:  "The name of the component in your `sst.config.ts`.",
+			},
+		},
+		{
+			Name:     "id",
+			Required: true,
+			Description: cli.Description{
+				Short: "The ID of the resource",
+				Long:  "The ID of the cloud resource, like the name of a bucket or a table.",
+			},
+		},
+	},
+	Run: CmdImportRun,
+}
+
+func CmdImportRun(c *cli.Cli) error {
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+
+	result, err := p.Import(c.Context, &project.ImportOptions{
+		Type: c.Positional(0),
+		Name: c.Positional(1),
+		ID:   c.Positional(2),
+	})
+	if errors.Is(err, project.ErrImportUnsupported) {
+		return util.NewReadableError(err, "Cannot import "+c.Positional(0)+", try one of: "+strings.Join(project.ImportableTypes(), ", "))
+	}
+	if errors.Is(err, project.ErrImportExists) {
+		return util.NewReadableError(err, c.Positional(1)+" already exists in stage "+p.App().Stage)
+	}
+	if err != nil {
+		return util.NewReadableError(err, "Could not import "+c.Positional(2)+": "+err.Error())
+	}
+
+	color.New(color.FgGreen, color.Bold).Print("✓ ")
+	color.New(color.FgWhite).Print(" Imported: ")
+	color.New(color.FgWhite, color.Bold).Println(c.Positional(1))
+	fmt.Println()
+	fmt.Println("   Set these in `transform." + result.Transform + "` of " + c.Positional(1) + " so the next deploy does not change it:")
+	fmt.Println()
+	for _, line := range strings.Split(strings.TrimSpace(result.Code), "\n") {
+		fmt.Println("   " + line)
+	}
+	return nil
+}
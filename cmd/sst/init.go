@@ -1,13 +1,11 @@
 package main
 
 import (
-	"bufio"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/exec"
 	"slices"
-	"strings"
 	"time"
 
 	"github.com/briandowns/spinner"
@@ -16,6 +14,7 @@ import (
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/template"
 )
 
 func CmdInit(cli *cli.Cli) error {
@@ -48,109 +47,45 @@ func CmdInit(cli *cli.Cli) error {
 	}
 	fmt.Print("\033[?25h")
 
-	var template string
-
-	hints := []string{}
-	files, err := os.ReadDir(".")
+	selected, err := template.Detect(".")
 	if err != nil {
 		return err
 	}
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	detected := selected != nil
+	if name := cli.String("template"); name != "" {
+		selected, err = template.Get(name)
+		if err != nil {
+			return util.NewReadableError(err, "Unknown template "+name+", see `sst init --help` for the list")
+		}
+	}
+	if selected == nil {
+		selected, err = template.Get("vanilla")
+		if err != nil {
+			return err
 		}
-		hints = append(hints, file.Name())
 	}
 
 	color.New(color.FgBlue, color.Bold).Print(">")
 	switch {
-	case slices.ContainsFunc(hints, func(s string) bool { return strings.HasPrefix(s, "next.config") }):
-		fmt.Println("  Next.js detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - modify the tsconfig.json")
-		fmt.Println("   - add sst to package.json")
-		template = "nextjs"
-		break
-
-	case slices.ContainsFunc(hints, func(s string) bool { return strings.HasPrefix(s, "astro.config") }):
-		fmt.Println("  Astro detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - modify the tsconfig.json")
-		fmt.Println("   - add sst to package.json")
-		template = "astro"
-		break
-
-	case slices.ContainsFunc(hints, func(s string) bool { return strings.HasPrefix(s, "app.config") }):
-		fmt.Println("  SolidStart detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - add sst to package.json")
-		template = "solid-start"
-		break
-
-	case slices.ContainsFunc(hints, func(s string) bool { return strings.HasPrefix(s, "nuxt.config") }):
-		fmt.Println("  Nuxt detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - add sst to package.json")
-		template = "nuxt"
-		break
-
-	case slices.ContainsFunc(hints, func(s string) bool { return strings.HasPrefix(s, "svelte.config") }):
-		fmt.Println("  SvelteKit detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - add sst to package.json")
-		template = "svelte-kit"
-		break
-
-	case slices.ContainsFunc(hints, func(s string) bool {
-		return strings.HasPrefix(s, "remix.config") ||
-			(strings.HasPrefix(s, "vite.config") && fileContains(s, "@remix-run/dev"))
-	}):
-		fmt.Println("  Remix detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - add sst to package.json")
-		template = "remix"
-		break
-
-	case slices.ContainsFunc(hints, func(s string) bool {
-		return (strings.HasPrefix(s, "vite.config") && fileContains(s, "@analogjs/platform"))
-	}):
-		fmt.Println("  Analog detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - add sst to package.json")
-		template = "analog"
-		break
-
-	case slices.ContainsFunc(hints, func(s string) bool { return strings.HasPrefix(s, "angular.json") }):
-		fmt.Println("  Angular detected. This will...")
-		fmt.Println("   - create an sst.config.ts")
-		fmt.Println("   - add sst to package.json")
-		template = "angular"
-		break
-
-	case slices.Contains(hints, "package.json"):
-		fmt.Println("  JS project detected. This will...")
-		fmt.Println("   - use the JS template")
-		fmt.Println("   - create an sst.config.ts")
-		template = "js"
-		break
-
+	case cli.String("template") != "":
+		fmt.Println("  Using the " + selected.Title + " template. This will...")
+	case detected:
+		fmt.Println("  " + selected.Title + " detected. This will...")
 	default:
 		fmt.Println("  No frontend detected. This will...")
-		fmt.Println("   - use the vanilla template")
-		fmt.Println("   - create an sst.config.ts")
-		template = "vanilla"
-		break
 	}
-	fmt.Println()
-
-	p := promptui.Select{
-		Items:        []string{"Yes", "No"},
-		Label:        "‏‏‎ ‎Continue",
-		HideSelected: true,
-		HideHelp:     true,
+	err = printChanges(selected)
+	if err != nil {
+		return err
 	}
 
 	if !cli.Bool("yes") {
+		p := promptui.Select{
+			Items:        []string{"Yes", "Use a different template", "No"},
+			Label:        "‏‏‎ ‎Continue",
+			HideSelected: true,
+			HideHelp:     true,
+		}
 		_, confirm, err := p.Run()
 		if err != nil {
 			return util.NewReadableError(err, "")
@@ -158,18 +93,35 @@ func CmdInit(cli *cli.Cli) error {
 		if confirm == "No" {
 			return nil
 		}
+		if confirm == "Use a different template" {
+			selected, err = pickTemplate(selected)
+			if err != nil {
+				return err
+			}
+			color.New(color.FgBlue, color.Bold).Print(">")
+			fmt.Println("  This will...")
+			err = printChanges(selected)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
 	color.New(color.FgGreen, color.Bold).Print("✓")
-	color.New(color.FgWhite).Println("  Template:", template)
+	color.New(color.FgWhite).Println("  Template:", selected.Name)
 	fmt.Println()
 
 	home := "aws"
-	if template == "vanilla" || template == "js" {
-		p = promptui.Select{
+	if len(selected.Homes) > 1 && !cli.Bool("yes") {
+		homes := []string{}
+		for key := range selected.Homes {
+			homes = append(homes, key)
+		}
+		slices.Sort(homes)
+		p := promptui.Select{
 			Label:        "‏‏‎ ‎Where do you want to deploy your app? You can change this later",
 			HideSelected: true,
-			Items:        []string{"aws", "cloudflare"},
+			Items:        homes,
 			HideHelp:     true,
 		}
 		_, home, err = p.Run()
@@ -181,11 +133,7 @@ func CmdInit(cli *cli.Cli) error {
 		fmt.Println()
 	}
 
-	if template == "js" {
-		template = "js-" + home
-	}
-
-	instructions, err := project.Create(template, home)
+	instructions, err := template.Create(selected.Dir(home), home)
 	if err != nil {
 		return err
 	}
@@ -215,17 +163,8 @@ func CmdInit(cli *cli.Cli) error {
 		return err
 	}
 
-	if _, err := os.Stat("package-lock.json"); err == nil {
-		cmd = exec.Command("npm", "install")
-	}
-	if _, err := os.Stat("yarn.lock"); err == nil {
-		cmd = exec.Command("yarn", "install")
-	}
-	if _, err := os.Stat("pnpm-lock.yaml"); err == nil {
-		cmd = exec.Command("pnpm", "install")
-	}
-	if _, err := os.Stat("bun.lockb"); err == nil {
-		cmd = exec.Command("bun", "install")
+	if manager := template.DetectPackageManager("."); manager != "" {
+		cmd = exec.Command(manager, "install")
 	}
 	if cmd != nil {
 		spin.Suffix = "  Installing dependencies..."
@@ -255,23 +194,49 @@ func CmdInit(cli *cli.Cli) error {
 	return nil
 }
 
-func fileContains(filePath string, str string) bool {
-	file, err := os.Open(filePath)
+func printChanges(selected *template.Template) error {
+	changes, err := selected.Changes("aws")
 	if err != nil {
-		return false
+		return err
+	}
+	if len(selected.Homes) > 0 {
+		fmt.Println("   - use the " + selected.Name + " template")
+	}
+	for _, change := range changes {
+		fmt.Println("   - " + change)
 	}
-	defer file.Close()
+	fmt.Println()
+	return nil
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		if strings.Contains(scanner.Text(), str) {
-			return true
+func pickTemplate(current *template.Template) (*template.Template, error) {
+	templates, err := template.List()
+	if err != nil {
+		return nil, err
+	}
+	items := []string{}
+	cursor := 0
+	for i, item := range templates {
+		label := item.Title
+		if item.Description != "" {
+			label += " - " + item.Description
+		}
+		if item.Name == current.Name {
+			cursor = i
 		}
+		items = append(items, label)
 	}
-
-	if err := scanner.Err(); err != nil {
-		return false
+	p := promptui.Select{
+		Label:        "‏‏‎ ‎Which template do you want to use?",
+		Items:        items,
+		CursorPos:    cursor,
+		Size:         len(items),
+		HideSelected: true,
+		HideHelp:     true,
 	}
-
-	return false
+	index, _, err := p.Run()
+	if err != nil {
+		return nil, util.NewReadableError(err, "")
+	}
+	return templates[index], nil
 }
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project/path"
+	"github.com/sst/ion/pkg/runtime"
+)
+
+var CmdInspect = &cli.Command{
+	Name: "inspect",
+	Description: cli.Description{
+		Short: "Generate VS Code launch configurations for your functions",
+		Long: strings.Join([]string{
+			"Write a launch configuration to `.vscode/launch.json` for every function that `sst dev --inspect` has run, so VS Code can attach its debugger to them.",
+			"",
+			"```bash frame=\"none\"",
+			"sst inspect",
+			"```",
+			"",
+			"With `--inspect`, the Node.js, Python, and Java functions that `sst dev` runs listen for a debugger. Each function gets a port of its own, starting with `9229`, and keeps it across sessions.",
+			"",
+			"The configurations are named `SST: <function>`, along with an `SST: All functions` compound that attaches to all of them. Running this again replaces them and keeps the rest of the file, but any comments in it are removed.",
+		}, "\n"),
+	},
+	Run: CmdInspectRun,
+}
+
+const inspectPrefix = "SST: "
+
+func CmdInspectRun(c *cli.Cli) error {
+	cfgPath, err := c.Discover()
+	if err != nil {
+		return err
+	}
+	inspections, err := runtime.LoadInspections(cfgPath)
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the debugger ports of your functions")
+	}
+	if len(inspections) == 0 {
+		return util.NewReadableError(nil, "No functions have been run with a debugger yet. Start `sst dev --inspect` first.")
+	}
+
+	launchPath := filepath.Join(path.ResolveRootDir(cfgPath), ".vscode", "launch.json")
+	launch := map[string]interface{}{
+		"version": "0.2.0",
+	}
+	data, err := os.ReadFile(launchPath)
+	if err == nil {
+		err = json.Unmarshal(stripJSONComments(data), &launch)
+		if err != nil {
+			return util.NewReadableError(err, "Could not parse "+launchPath)
+		}
+	}
+
+	configurations := []interface{}{}
+	existing, _ := launch["configurations"].([]interface{})
+	for _, item := range existing {
+		if config, ok := item.(map[string]interface{}); ok {
+			if name, _ := config["name"].(string); strings.HasPrefix(name, inspectPrefix) {
+				continue
+			}
+		}
+		configurations = append(configurations, item)
+	}
+	functionIDs := make([]string, 0, len(inspections))
+	for functionID := range inspections {
+		functionIDs = append(functionIDs, functionID)
+	}
+	sort.Strings(functionIDs)
+	names := []string{}
+	for _, functionID := range functionIDs {
+		config := map[string]interface{}{}
+		for key, value := range inspections[functionID].Config {
+			config[key] = value
+		}
+		config["name"] = inspectPrefix + functionID
+		configurations = append(configurations, config)
+		names = append(names, inspectPrefix+functionID)
+	}
+	launch["configurations"] = configurations
+
+	compounds := []interface{}{}
+	existing, _ = launch["compounds"].([]interface{})
+	for _, item := range existing {
+		if compound, ok := item.(map[string]interface{}); ok {
+			if name, _ := compound["name"].(string); strings.HasPrefix(name, inspectPrefix) {
+				continue
+			}
+		}
+		compounds = append(compounds, item)
+	}
+	compounds = append(compounds, map[string]interface{}{
+		"name":           inspectPrefix + "All functions",
+		"configurations": names,
+	})
+	launch["compounds"] = compounds
+
+	data, err = json.MarshalIndent(launch, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(launchPath), 0755)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(launchPath, append(data, '\n'), 0644)
+	if err != nil {
+		return util.NewReadableError(err, "Could not write "+launchPath)
+	}
+	output.Result(map[string]interface{}{
+		"path":           launchPath,
+		"configurations": names,
+	})
+	color.New(color.FgGreen, color.Bold).Print("✓ ")
+	color.New(color.FgWhite).Print(" Added ", len(names), " launch configurations to: ")
+	color.New(color.FgWhite, color.Bold).Println(filepath.Join(".vscode", "launch.json"))
+	return nil
+}
+
+// stripJSONComments removes the comments and trailing commas VS Code allows
+// in launch.json so it can be parsed as JSON.
+func stripJSONComments(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		char := data[i]
+		if inString {
+			result = append(result, char)
+			if char == '\\' && i+1 < len(data) {
+				i++
+				result = append(result, data[i])
+			} else if char == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case char == '"':
+			inString = true
+		case char == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			continue
+		case char == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+			continue
+		case char == '}' || char == ']':
+			// drop a comma that only has whitespace after it
+			end := len(result) - 1
+			for end >= 0 && strings.ContainsRune(" \t\r\n", rune(result[end])) {
+				end--
+			}
+			if end >= 0 && result[end] == ',' {
+				result = append(result[:end], result[end+1:]...)
+			}
+		}
+		result = append(result, char)
+	}
+	return result
+}
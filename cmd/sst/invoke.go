@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/aws"
+	"github.com/sst/ion/cmd/sst/mosaic/capture"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/server"
+)
+
+var CmdInvoke = &cli.Command{
+	Name: "invoke",
+	Description: cli.Description{
+		Short: "Invoke a function",
+		Long: strings.Join([]string{
+			"Invoke a function with a payload and print its response and logs.",
+			"",
+			"```bash frame=\"none\"",
+			"sst invoke MyFunction --payload '{\"hello\":\"world\"}'",
+			"```",
+			"",
+			"The function is looked up by the name of its component in the state of the stage. If a component has more than one function, like an API, pass in the name of the one to invoke.",
+			"",
+			"The payload can also be read from a file with `--payload-file`, or be a sample event of another service with `--event`.",
+			"",
+			"```bash frame=\"none\"",
+			"sst invoke MyFunction --event " + strings.Join(aws.EventTemplates(), "|"),
+			"```",
+			"",
+			"By default the deployed function is invoked. Pass in `--local` to run it through `sst dev` instead, so it runs your local code and prints its logs.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "function",
+			Required: true,
+			Description: cli.Description{
+				Short: "The function to invoke",
+				Long:  "The name of the function component to invoke.",
+			},
+		},
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "payload",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The payload to invoke with",
+				Long:  "The JSON payload to invoke the function with. Defaults to `{}`.",
+			},
+		},
+		{
+			Name: "payload-file",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Read the payload from a file",
+				Long:  "Read the JSON payload to invoke the function with from a file.",
+			},
+		},
+		{
+			Name: "event",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Invoke with a sample event",
+				Long:  "Invoke the function with a sample event, one of " + strings.Join(aws.EventTemplates(), ", ") + ".",
+			},
+		},
+		{
+			Name: "local",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Run the local code through sst dev",
+				Long:  "Invoke the function through the server of `sst dev`, so it runs your local code.",
+			},
+		},
+		serverFlag,
+	},
+	Run: CmdInvokeRun,
+}
+
+func CmdInvokeRun(c *cli.Cli) error {
+	payload, err := invokePayload(c)
+	if err != nil {
+		return err
+	}
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+	prov, ok := p.Provider("aws")
+	if !ok {
+		return util.NewReadableError(nil, "Invoke is only available for apps that use the aws provider")
+	}
+	complete, err := p.GetCompleted(c.Context)
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the state of stage "+p.App().Stage)
+	}
+	fn, err := findFunction(complete, c.Positional(0))
+	if err != nil {
+		return err
+	}
+
+	var result *capture.Result
+	if c.Bool("local") || c.String("server") != "" {
+		url, token, err := discoverServer(c, p.PathConfig(), p.App().Stage)
+		if errors.Is(err, server.ErrServerNotFound) {
+			return util.NewReadableError(err, "Start `sst dev` first to invoke the local code")
+		}
+		if err != nil {
+			return err
+		}
+		result, err = capture.Invoke(c.Context, fn.FunctionID, fn.Name, payload, url, token)
+		if err != nil {
+			return util.NewReadableError(err, "Could not invoke "+fn.FunctionID+": "+err.Error())
+		}
+	} else {
+		client := lambda.NewFromConfig(prov.(*provider.AwsProvider).Config())
+		result, err = capture.Lambda(c.Context, client, fn.Name, payload)
+		if err != nil {
+			return util.NewReadableError(err, "Could not invoke "+fn.FunctionID+": "+err.Error())
+		}
+	}
+	output.Result(result)
+
+	for _, line := range result.Logs {
+		color.New(color.FgHiBlack).Println(line)
+	}
+	if len(result.Logs) > 0 {
+		fmt.Println()
+	}
+	if result.Error != "" {
+		color.New(color.FgRed, color.Bold).Print("✕ ")
+		color.New(color.FgWhite).Printf(" %s failed:\n", fn.FunctionID)
+	} else {
+		color.New(color.FgGreen, color.Bold).Print("✓ ")
+		color.New(color.FgWhite).Printf(" %s responded:\n", fn.FunctionID)
+	}
+	var pretty interface{}
+	if json.Unmarshal(result.Output, &pretty) == nil {
+		data, _ := json.MarshalIndent(pretty, "", "  ")
+		fmt.Println(string(data))
+	}
+	return nil
+}
+
+func invokePayload(c *cli.Cli) ([]byte, error) {
+	var payload []byte
+	set := 0
+	if value := c.String("payload"); value != "" {
+		payload = []byte(value)
+		set++
+	}
+	if path := c.String("payload-file"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, util.NewReadableError(err, "Could not read "+path)
+		}
+		payload = data
+		set++
+	}
+	if name := c.String("event"); name != "" {
+		data, err := aws.EventTemplate(name)
+		if err != nil {
+			return nil, util.NewReadableError(err, "Unknown --event "+name+", use one of "+strings.Join(aws.EventTemplates(), ", "))
+		}
+		payload = data
+		set++
+	}
+	if set > 1 {
+		return nil, util.NewReadableError(nil, "Only pass in one of --payload, --payload-file, or --event")
+	}
+	if payload == nil {
+		return []byte("{}"), nil
+	}
+	if !json.Valid(payload) {
+		return nil, util.NewReadableError(nil, "The payload is not valid JSON")
+	}
+	return payload, nil
+}
+
+// findFunction resolves name to a single deployed function, either a
+// function component or a component with only one function in it.
+func findFunction(complete *project.CompleteEvent, name string) (aws.DeployedFunction, error) {
+	matches := aws.Functions(complete, name)
+	for _, item := range matches {
+		if item.FunctionID == name {
+			return item, nil
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return aws.DeployedFunction{}, util.NewReadableError(nil, "No function found named "+name)
+	case 1:
+		return matches[0], nil
+	}
+	ids := []string{}
+	for _, item := range matches {
+		ids = append(ids, item.FunctionID)
+	}
+	sort.Strings(ids)
+	return aws.DeployedFunction{}, util.NewReadableError(nil, name+" has more than one function, pass in one of "+strings.Join(ids, ", "))
+}
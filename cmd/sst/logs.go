@@ -0,0 +1,127 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/aws"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/project/provider"
+	"golang.org/x/sync/errgroup"
+)
+
+var CmdLogs = &cli.Command{
+	Name: "logs",
+	Description: cli.Description{
+		Short: "Tail the logs of your functions",
+		Long: strings.Join([]string{
+			"Tail the CloudWatch logs of the functions deployed to a stage.",
+			"",
+			"```bash frame=\"none\"",
+			"sst logs --stage production",
+			"```",
+			"",
+			"Pass in the name of a component to only show the logs of the functions in it.",
+			"",
+			"```bash frame=\"none\"",
+			"sst logs MyApi --since 1h --filter ERROR",
+			"```",
+			"",
+			"The `--filter` flag takes a CloudWatch Logs filter pattern. Log lines that are JSON are pretty printed.",
+			"",
+			"Stack traces of Node functions are mapped back to your source with the source maps kept from the last deploy on this machine.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name: "component",
+			Description: cli.Description{
+				Short: "The component to show the logs for",
+				Long:  "The name of the component to show the logs for. Defaults to all functions.",
+			},
+		},
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "since",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Show logs since this time",
+				Long:  "Show logs newer than a duration like `15m` or a timestamp like `2024-01-01T12:00:00Z`. Defaults to `10m`.",
+			},
+		},
+		{
+			Name: "filter",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Only show matching logs",
+				Long:  "Only show log lines that match a CloudWatch Logs filter pattern.",
+			},
+		},
+	},
+	Run: CmdLogsRun,
+}
+
+func CmdLogsRun(c *cli.Cli) error {
+	since, err := parseSince(c.String("since"))
+	if err != nil {
+		return util.NewReadableError(err, "Invalid --since, use a duration like 15m or a timestamp like 2024-01-01T12:00:00Z")
+	}
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+	prov, ok := p.Provider("aws")
+	if !ok {
+		return util.NewReadableError(nil, "Logs are only available for apps that use the aws provider")
+	}
+	complete, err := p.GetCompleted(c.Context)
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the state of stage "+p.App().Stage)
+	}
+	component := c.Positional(0)
+	groups := aws.LogGroups(complete, component)
+	if len(groups) == 0 {
+		if component != "" {
+			return util.NewReadableError(nil, "No functions found in "+component)
+		}
+		return util.NewReadableError(nil, "No functions found in stage "+p.App().Stage)
+	}
+
+	u := ui.New(c.Context, ui.WithDev)
+	defer u.Destroy()
+	var wg errgroup.Group
+	events := bus.Subscribe[*aws.FunctionLogEvent](c.Context, bus.Topic(&aws.FunctionLogEvent{}))
+	wg.Go(func() error {
+		for evt := range events {
+			u.Event(evt)
+		}
+		return nil
+	})
+	wg.Go(func() error {
+		defer c.Cancel()
+		return aws.Tail(c.Context, prov.(*provider.AwsProvider).Config(), aws.TailInput{
+			Groups:     groups,
+			Since:      since,
+			Filter:     c.String("filter"),
+			Sourcemaps: filepath.Join(p.PathWorkingDir(), "sourcemaps"),
+			Root:       p.PathRoot(),
+		})
+	})
+	return wg.Wait()
+}
+
+func parseSince(input string) (time.Time, error) {
+	if input == "" {
+		input = "10m"
+	}
+	if duration, err := time.ParseDuration(input); err == nil {
+		return time.Now().Add(-duration), nil
+	}
+	return time.Parse(time.RFC3339, input)
+}
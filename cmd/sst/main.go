@@ -17,21 +17,33 @@ import (
 	"github.com/nrednav/cuid2"
 
 	"github.com/briandowns/spinner"
-	"github.com/fatih/color"
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/errors"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/github"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/flag"
 	"github.com/sst/ion/pkg/global"
+	"github.com/sst/ion/pkg/network"
 	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/project/template"
 	"github.com/sst/ion/pkg/telemetry"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/errgroup"
 )
 
 var version = "dev"
 
 func main() {
+	// called by the shell on every tab so keep it quiet and fast
+	if len(os.Args) > 1 && os.Args[1] == "__complete" {
+		for _, candidate := range cli.Complete(root, os.Args[2:]) {
+			fmt.Println(candidate)
+		}
+		return
+	}
 	// check if node_modules/.bin/sst exists
 	nodeModulesBinPath := filepath.Join("node_modules", ".bin", "sst")
 	binary, _ := os.Executable()
@@ -49,6 +61,7 @@ func main() {
 		}
 		return
 	}
+	network.Install()
 	telemetry.SetVersion(version)
 	defer telemetry.Close()
 	telemetry.Track("cli.start", map[string]interface{}{
@@ -70,21 +83,30 @@ func main() {
 			if msg != "" {
 				ui.Error(readableErr.Error())
 			}
+			if msg == "" && readableErr.Unwrap() != nil {
+				msg = readableErr.Unwrap().Error()
+			}
+			output.Finish(fmt.Errorf("%s", msg))
+			github.Finish(fmt.Errorf("%s", msg))
 		} else {
 			slog.Error("exited with error", "err", err)
 			// check if context cancelled error
 			if err != context.Canceled {
 				ui.Error("Unexpected error occurred. Please run with --print-logs or check .sst/log/sst.log if available.")
 			}
+			output.Finish(err)
+			github.Finish(err)
 		}
 		telemetry.Close()
 		os.Exit(1)
 		return
 	}
+	output.Finish(nil)
+	github.Finish(nil)
 	telemetry.Track("cli.success", map[string]interface{}{})
 }
 
-func run() error {
+func run() (err error) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	interruptChannel := make(chan os.Signal, 1)
@@ -98,6 +120,30 @@ func run() error {
 	if err != nil {
 		return err
 	}
+	names := []string{}
+	for _, cmd := range c.Path()[1:] {
+		names = append(names, cmd.Name)
+	}
+	if c.Bool("json") {
+		output.Enable(strings.Join(names, " "))
+	}
+	switch c.String("output") {
+	case "github":
+		github.Enable(strings.Join(names, " "))
+	case "":
+		if github.Detect() {
+			github.Enable(strings.Join(names, " "))
+		}
+	default:
+		return util.NewReadableError(nil, "Invalid --output "+c.String("output")+", the only one is github")
+	}
+	stopTracing := telemetry.StartTracing(ctx)
+	defer stopTracing()
+	var span trace.Span
+	c.Context, span = telemetry.Span(c.Context, strings.Join(append([]string{"sst"}, names...), " "))
+	defer func() {
+		telemetry.EndSpan(span, err)
+	}()
 	_, err = user.Current()
 	if err != nil {
 		return err
@@ -106,21 +152,26 @@ func run() error {
 	if !flag.SST_SKIP_DEPENDENCY_CHECK {
 		spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 		spin.Suffix = "  Updating dependencies..."
+		var wg errgroup.Group
+		needed := false
 		if global.NeedsPulumi() {
-			spin.Start()
-			err := global.InstallPulumi()
-			if err != nil {
-				return err
-			}
+			needed = true
+			wg.Go(global.InstallPulumi)
 		}
 		if global.NeedsBun() {
+			needed = true
+			wg.Go(global.InstallBun)
+		}
+		if needed {
+			stopTracking := c.TrackDownloads(spin, spin.Suffix)
 			spin.Start()
-			err := global.InstallBun()
+			err := wg.Wait()
+			stopTracking()
+			spin.Stop()
 			if err != nil {
 				return err
 			}
 		}
-		spin.Stop()
 	}
 	return c.Run()
 }
@@ -202,8 +253,9 @@ var root = &cli.Command{
 	},
 	Flags: []cli.Flag{
 		{
-			Name: "stage",
-			Type: "string",
+			Name:     "stage",
+			Type:     "string",
+			Complete: completeStages,
 			Description: cli.Description{
 				Short: "The stage to deploy to",
 				Long: strings.Join([]string{
@@ -235,6 +287,35 @@ var root = &cli.Command{
 				}, "\n"),
 			},
 		},
+		{
+			Name: "app",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The app of the workspace to run on",
+				Long: strings.Join([]string{
+					"Pick one of the apps of a workspace, a repo with an `sst.workspace.json` at its root that lists the directories of its apps.",
+					"",
+					"```json title=\"sst.workspace.json\"",
+					"{",
+					"  \"apps\": [\"apps/*\"],",
+					"  \"providers\": {",
+					"    \"aws\": { \"region\": \"us-east-1\" }",
+					"  }",
+					"}",
+					"```",
+					"",
+					"Apps are named after their directory, so this deploys the app in `apps/api` from anywhere in the repo.",
+					"",
+					"```bash frame=\"none\"",
+					"sst deploy --app api",
+					"```",
+					"",
+					"The `providers` are shared by all the apps, and an app can read the outputs of another one in the same stage with `sst.workspace.output`.",
+					"",
+					"Running `sst dev` at the root of the workspace starts the apps listed in its `dev`, or all of them, together. Pick some with `--app api,web`.",
+				}, "\n"),
+			},
+		},
 		{
 			Name: "verbose",
 			Type: "bool",
@@ -253,6 +334,64 @@ var root = &cli.Command{
 				}, "\n"),
 			},
 		},
+		{
+			Name: "json",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Print machine readable output",
+				Long: strings.Join([]string{
+					"",
+					"Print machine readable output to stdout, one JSON object per line.",
+					"",
+					"```bash",
+					"sst [command] --json",
+					"```",
+					"",
+					"Each line has a `kind` of `event`, `result`, or `error`, the `command` that was run, and a `version` for the format. Events are printed as they happen, with their `type` and `data`. The last line is the `result` of the command with its `data`, or the `error` it failed with.",
+					"",
+					"Everything else is printed to stderr.",
+					"",
+				}, "\n"),
+			},
+		},
+		{
+			Name: "output",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Print output for a CI provider",
+				Long: strings.Join([]string{
+					"",
+					"Print output for a CI provider, only `github` is supported.",
+					"",
+					"```bash",
+					"sst deploy --output github",
+					"```",
+					"",
+					"Errors and warnings from resources and builds are printed as annotations of the workflow run, and `deploy`, `diff`, and `remove` add their changes and outputs to the job summary.",
+					"",
+					"This is on by default when `GITHUB_ACTIONS` is set.",
+					"",
+				}, "\n"),
+			},
+		},
+		{
+			Name: "build-concurrency",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Number of functions to build at once",
+				Long: strings.Join([]string{
+					"",
+					"The number of functions and sites that are built at the same time. Defaults to 4.",
+					"",
+					"```bash",
+					"sst deploy --build-concurrency 8",
+					"```",
+					"",
+					"It can also be set using the `SST_BUILD_CONCURRENCY` environment variable.",
+					"",
+				}, "\n"),
+			},
+		},
 		{
 			Name: "print-logs",
 			Type: "bool",
@@ -272,6 +411,44 @@ var root = &cli.Command{
 					"```",
 					"This is useful when running in a CI environment.",
 					"",
+					"Every session logs to its own file in `.sst/log/`, and `.sst/log/sst.log` points",
+					"at the latest one.",
+					"",
+				}, "\n"),
+			},
+		},
+		{
+			Name: "log-level",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The level of the logs",
+				Long: strings.Join([]string{
+					"",
+					"Only write logs of this level and above, one of `debug`, `info`, `warn`, or `error`.",
+					"Defaults to `info`.",
+					"",
+					"```bash",
+					"sst [command] --log-level debug",
+					"```",
+					"It can also be set using the `SST_LOG_LEVEL` environment variable.",
+					"",
+				}, "\n"),
+			},
+		},
+		{
+			Name: "log-format",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The format of the logs",
+				Long: strings.Join([]string{
+					"",
+					"Write the logs as `text` or as `json`, one object per line. Defaults to `text`.",
+					"",
+					"```bash",
+					"sst [command] --log-format json --print-logs",
+					"```",
+					"It can also be set using the `SST_LOG_FORMAT` environment variable.",
+					"",
 				}, "\n"),
 			},
 		},
@@ -311,10 +488,34 @@ var root = &cli.Command{
 					"```bash frame=\"none\"",
 					"sst init --yes",
 					"```",
+					"",
+					"Or pick the template yourself.",
+					"",
+					"```bash frame=\"none\"",
+					"sst init --template api",
+					"```",
+					"",
+					"The templates are `nextjs`, `astro`, `solid-start`, `nuxt`, `svelte-kit`, `remix`, `analog`, `angular`, `api`, `js`, and `vanilla`.",
 				}, "\n"),
 			},
 			Run: CmdInit,
 			Flags: []cli.Flag{
+				{
+					Name: "template",
+					Type: "string",
+					Complete: func([]string) []string {
+						templates, _ := template.List()
+						names := []string{}
+						for _, item := range templates {
+							names = append(names, item.Name)
+						}
+						return names
+					},
+					Description: cli.Description{
+						Short: "The template to use",
+						Long:  "Use this template instead of the one detected for the current directory.",
+					},
+				},
 				{
 					Name: "yes",
 					Type: "bool",
@@ -325,6 +526,20 @@ var root = &cli.Command{
 				},
 			},
 		},
+		{
+			Name:   "container",
+			Hidden: true,
+			Run:    CmdContainer,
+			Args: []cli.Argument{
+				{
+					Name:     "name",
+					Required: true,
+					Description: cli.Description{
+						Short: "The service to run",
+					},
+				},
+			},
+		},
 		{
 			Name:   "ui",
 			Hidden: true,
@@ -338,6 +553,7 @@ var root = &cli.Command{
 						Long:  "Filter events.",
 					},
 				},
+				serverFlag,
 			},
 		},
 		{
@@ -363,6 +579,7 @@ var root = &cli.Command{
 					"4. Run the dev mode for components that have `dev.autostart` enabled",
 					"   - Components like `Service` and frontends like `Nextjs`, `Remix`, `Astro`, `StaticSite`, etc.",
 					"   - It starts their `dev.command` in a separate pane",
+					"   - Or for a `Service` with `dev.docker`, runs its image and rebuilds it when its files change",
 					"   - And loads any [linked resources](/docs/linking) in the environment",
 					"",
 					"The multiplexer makes it so that you won't have to start your frontend or",
@@ -399,13 +616,97 @@ var root = &cli.Command{
 					"```",
 					"",
 					"By wrapping your command, it'll load your [linked resources](/docs/linking) in the",
-					"environment.",
+					"environment and restart it when they change. If it is run in the directory of a",
+					"component, it gets the links of that component. Otherwise it gets all of them, like",
+					"[`sst shell`](#shell).",
+					"",
+					"If the `sst dev` it connects to was started by another version of the CLI, it is",
+					"stopped and started again in the background with this version. Its output goes to",
+					"`.sst/log/server.log`.",
 					"",
 					"To pass in a flag to the command, use `--`.",
 					"",
 					"```bash frame=\"none\"",
 					"sst dev -- next dev --turbo",
 					"```",
+					"",
+					"To run `sst dev` without a terminal, like in CI or from an editor, pass in `--no-ui`.",
+					"It skips the multiplexer and prints every event of the session to stdout as a line of",
+					"JSON, like the builds and invocations of your functions, their logs, and the progress",
+					"of the deploy. Like in `basic` mode, the dev commands of your components are not started.",
+					"",
+					"```bash frame=\"none\"",
+					"sst dev --no-ui",
+					"```",
+					"",
+					"`--json` and `--mode json` do the same.",
+					"",
+					"To have the dev server stop itself once nothing has been connected to it for a while,",
+					"pass in `--idle-timeout` with a duration. It can also be set with the",
+					"`SST_SERVER_IDLE_TIMEOUT` environment variable.",
+					"",
+					"```bash frame=\"none\"",
+					"sst dev --idle-timeout 30m",
+					"```",
+					"",
+					"If `sst dev` is running on another machine, pass in `--server` to connect to it. This",
+					"shows its output and lets you run commands against it without starting a server locally.",
+					"",
+					"```bash frame=\"none\"",
+					"SST_SERVER_TOKEN=<token> sst dev --server=https://devbox:14557",
+					"```",
+					"",
+					"The server also serves Prometheus metrics on `/metrics`, like the duration of builds and",
+					"their cache hits, the count, latency, and errors of invocations, the connected clients,",
+					"and the duration of deploys. It needs the token in `.sst/<stage>.server.token` as a",
+					"bearer token, the `authorization` of a Prometheus scrape config.",
+					"",
+					"For editor integrations, `/status` returns the state of the session as JSON with the same",
+					"token: the deploy in progress, the state of every resource, how the last deploy went, and",
+					"the clients connected to the server. It is kept up to date from the events of `/stream`.",
+					"",
+					"```bash frame=\"none\"",
+					"curl -H \"Authorization: Bearer $(cat .sst/dev.server.token)\" http://localhost:13557/status",
+					"```",
+					"",
+					"The dev server also serves a dashboard you can open in the browser, with the",
+					"invocations of your functions, their logs, and the resources of your app as they are",
+					"deployed. Its url is printed when `sst dev` starts, or pass in `--ui` to open it.",
+					"",
+					"```bash frame=\"none\"",
+					"sst dev --ui",
+					"```",
+					"",
+					"To work without a connection to your cloud provider, pass in `--offline`. Nothing is",
+					"deployed and your functions run locally instead of behind the deployed Lambda functions.",
+					"",
+					"```bash frame=\"none\"",
+					"sst dev --offline",
+					"```",
+					"",
+					"Offline mode runs your app with mocked resources to find its functions, environment",
+					"variables, and API routes, so it needs no deploy or cloud account. Secrets are read from",
+					"`SST_SECRET_<name>` and the sops file of the stage, the ones that are not set are empty.",
+					"It starts a server on `http://localhost:13560`, or the port in `--offline-port`, that serves:",
+					"",
+					"- The Lambda invoke API at `/2015-03-31/functions/<name>/invocations`, so you can point the AWS SDK at it",
+					"- Function URLs at `/url/<component>/`",
+					"- The routes of your `ApiGatewayV2` components at `/api/<component>/`",
+					"",
+					"Functions are built when they are first invoked and rebuilt when their files change.",
+					"Other resources like buckets or queues are not emulated, their linked values are the ones",
+					"they are created with so the ones only known after a deploy are missing.",
+					"",
+					"To debug your functions, pass in `--inspect`. The Node.js, Python, and Java functions",
+					"then listen for a debugger, each on a port of its own starting with `9229`. The port is",
+					"printed in the functions pane when the function starts.",
+					"",
+					"```bash frame=\"none\"",
+					"sst dev --inspect",
+					"```",
+					"",
+					"Only one instance of a function listens at a time, so concurrent invocations run without",
+					"the debugger. Run [`sst inspect`](#inspect) to add launch configurations for VS Code.",
 				}, "\n"),
 			},
 			Flags: []cli.Flag{
@@ -414,9 +715,58 @@ var root = &cli.Command{
 					Type: "string",
 					Description: cli.Description{
 						Short: "Use mode=basic to turn off multiplexer",
-						Long:  "Defaults to using the multiplexer or `mosaic` mode. Use `basic` to turn it off.",
+						Long:  "Defaults to using the multiplexer or `mosaic` mode. Use `basic` to turn it off, it is the default on Windows. Use `json` to run without a UI and write the events to stdout, like `--no-ui`.",
+					},
+				},
+				{
+					Name: "offline",
+					Type: "bool",
+					Description: cli.Description{
+						Short: "Run functions locally without deploying",
+						Long:  "Run your functions locally with mocked resources, without deploying or connecting to your cloud provider.",
 					},
 				},
+				{
+					Name: "inspect",
+					Type: "bool",
+					Description: cli.Description{
+						Short: "Start functions with a debugger",
+						Long:  "Start your functions with a debugger listening, each function on a port of its own.",
+					},
+				},
+				{
+					Name: "ui",
+					Type: "bool",
+					Description: cli.Description{
+						Short: "Open the dashboard in the browser",
+						Long:  "Open the dashboard of the dev server in your browser once it is up.",
+					},
+				},
+				{
+					Name: "no-ui",
+					Type: "bool",
+					Description: cli.Description{
+						Short: "Run without a UI",
+						Long:  "Run without the multiplexer or any other UI and write the events of the session to stdout as NDJSON, one record per line like `--json`. Use this in scripts and CI.",
+					},
+				},
+				{
+					Name: "idle-timeout",
+					Type: "string",
+					Description: cli.Description{
+						Short: "Stop the server when nothing is connected",
+						Long:  "Stop the dev server once nothing has been connected to it for this long, as a duration like `30m`. Defaults to `SST_SERVER_IDLE_TIMEOUT`, or never.",
+					},
+				},
+				{
+					Name: "offline-port",
+					Type: "string",
+					Description: cli.Description{
+						Short: "Port of the offline server",
+						Long:  "The port the offline server listens on. Defaults to `13560`.",
+					},
+				},
+				serverFlag,
 			},
 			Args: []cli.Argument{
 				{
@@ -445,6 +795,12 @@ var root = &cli.Command{
 						Short: "Use -- to pass flags to the command",
 					},
 				},
+				{
+					Content: "sst dev --offline",
+					Description: cli.Description{
+						Short: "Run your functions locally without deploying",
+					},
+				},
 			},
 			Run: CmdMosaic,
 		},
@@ -459,7 +815,7 @@ var root = &cli.Command{
 					"For resources like your sites and functions; it first builds them and then deploys the generated assets.",
 					"",
 					"Since the build processes for some of these resources, like Next.js, take a lot of memory, the concurrency is limited by default of 4.",
-					"You can change this by setting the `SST_BUILD_CONCURRENCY` environment variable.",
+					"You can change this with the `--build-concurrency` flag or by setting the `SST_BUILD_CONCURRENCY` environment variable.",
 					"",
 					"```bash frame=\"none\"",
 					"SST_BUILD_CONCURRENCY=8 sst deploy",
@@ -471,27 +827,52 @@ var root = &cli.Command{
 					"You can turn down the build concurrency if you are running out of memory in CI.",
 					":::",
 					"",
+					"Functions are not built again if their source files, lockfiles, and build options have not changed since the last deploy on this machine.",
+					"To share these builds between CI runs, set `SST_BUILD_CACHE_BUCKET` to an S3 bucket, optionally followed by a prefix.",
+					"",
+					"```bash frame=\"none\"",
+					"SST_BUILD_CACHE_BUCKET=my-bucket/sst-cache sst deploy",
+					"```",
+					"",
+					"Set `SST_NO_BUILD_CACHE` to always build them.",
+					"",
+					"Sites are not built again and functions are not zipped again if their files, inputs, and environment have not changed since they were last deployed. The hashes are kept with the state of the stage, a machine without the output of the last build still builds.",
+					"Set `SST_NO_INCREMENTAL` to always build and zip them.",
+					"",
 					"Optionally, deploy your app to a specific stage.",
 					"",
 					"```bash frame=\"none\"",
 					"sst deploy --stage production",
 					"```",
-					"Optionally, deploy specific resources by passing in a list of their URNs.",
-					"You can get the URN of a resource from the [Console](/docs/console/#resources).",
+					"Optionally, deploy specific components by passing in a list of their names.",
+					"The resources they are made of, the resources they depend on, and the resources that depend on them are deployed with them.",
 					"",
 					"```bash frame=\"none\"",
-					"sst deploy --target urn:pulumi:prod::www::sst:aws:Astro::Astro,urn:pulumi:prod::www::sst:aws:Bucket::Assets",
+					"sst deploy --target Astro,Assets",
 					"```",
+					"",
+					"Names can be globs like `Api*`. You can also pass in URNs, which can have `*` wildcards.",
+					"You can get the URN of a resource from the [Console](/docs/console/#resources).",
+					"",
+					"Pass in `--skip-dependents` to leave out the resources that depend on the targets.",
+					"",
+					"Every deploy ends with how long it took to evaluate the config, set up the providers, build and run the update.",
+					"Pass in `--profile` to also see how long each function took to build and the slowest resources.",
+					"The timings are saved with the deploy, `sst state history` shows them.",
 				}, "\n"),
 			},
 			Flags: []cli.Flag{
+				targetFlag,
+				skipDependentsFlag,
 				{
-					Name: "target",
+					Name: "profile",
+					Type: "bool",
 					Description: cli.Description{
-						Short: "Comma separated list of target URNs",
-						Long:  "Comma separated list of target URNs.",
+						Short: "Show where the time of the deploy went",
+						Long:  "Show how long each function took to build and the slowest resource operations of the deploy.",
 					},
 				},
+				forceUnprotectFlag,
 			},
 			Examples: []cli.Example{
 				{
@@ -535,14 +916,25 @@ var root = &cli.Command{
 					"```",
 					"",
 					"This is useful because in dev mode, you app is deployed a little differently.",
+					"",
+					"To use the diff in CI, pass in `--json`. The result has the changes and a summary.",
+					"",
+					"```bash frame=\"none\"",
+					"sst diff --json",
+					"```",
+					"",
+					"To see what was changed outside of SST instead, pass in `--refresh`. This is the same as `sst drift`.",
 				}, "\n"),
 			},
 			Flags: []cli.Flag{
+				targetFlag,
+				targetDependentsFlag,
 				{
-					Name: "target",
+					Name: "refresh",
+					Type: "bool",
 					Description: cli.Description{
-						Short: "Comma separated list of target URNs",
-						Long:  "Comma separated list of target URNs.",
+						Short: "Check for drift",
+						Long:  "Compare the resources in your cloud provider to the state instead of to your app, like `sst drift`.",
 					},
 				},
 				{
@@ -634,7 +1026,7 @@ var root = &cli.Command{
 				spin.Suffix = "  Adding provider..."
 				spin.Start()
 				defer spin.Stop()
-				cfgPath, err := project.Discover()
+				cfgPath, err := cli.Discover()
 				if err != nil {
 					return err
 				}
@@ -699,10 +1091,43 @@ var root = &cli.Command{
 					"Behind the scenes, it installs the packages for your providers and adds the providers to your globals.",
 					"",
 					"If you don't have a version specified for your providers in your `sst.config.ts`, it'll install their latest versions.",
+					"",
+					"The versions it installs are written to an `sst.lock` next to your `sst.config.ts`, along with the version of the CLI. Commit it, the next `sst install` installs the same versions on every machine instead of the latest ones. Use `sst update` to move to newer versions.",
+					"",
+					"Pass in `--prefetch` to also download the Pulumi plugins of your providers, so the first deploy doesn't have to.",
+					"",
+					"```bash frame=\"none\"",
+					"sst install --prefetch",
+					"```",
+					"",
+					"The plugins are shared by all your projects. They are downloaded a few at a time, and a download that was interrupted picks up where it left off. Their downloads are kept in a cache by their checksum and checked before they are used again. In CI, set `SST_PLUGIN_CACHE` to a directory that is kept between runs to reuse them.",
+					"",
+					"If your machine can't reach the public registries, point the CLI at a mirror in `~/.config/sst/registry.json`.",
+					"",
+					"```json title=\"registry.json\"",
+					"{",
+					"  \"mirror\": \"https://mirror.example.com/sst\",",
+					"  \"headers\": { \"Authorization\": \"Bearer <token>\" }",
+					"}",
+					"```",
+					"",
+					"Or set `SST_REGISTRY_MIRROR` and `SST_REGISTRY_TOKEN`. The providers, their plugins, Pulumi, and Bun are then downloaded from the mirror, which serves an npm registry under `/npm`, the plugins under `/plugins`, Pulumi under `/pulumi`, and Bun under `/bun`. Every archive is checked against the `checksums.txt` at the root of the mirror. With a mirror, `sst install` always downloads the plugins.",
+					"",
+					"Behind a proxy, set `HTTPS_PROXY`, and `NO_PROXY` for the hosts to reach directly. If it uses a private certificate authority, set `SST_CA_BUNDLE` to a file with its certificates in PEM. They are trusted along with the ones of your system, by the CLI and the Node processes it starts. `NODE_EXTRA_CA_CERTS` is used if `SST_CA_BUNDLE` is not set.",
 				}, "\n"),
 			},
+			Flags: []cli.Flag{
+				{
+					Name: "prefetch",
+					Type: "bool",
+					Description: cli.Description{
+						Short: "Download the provider plugins",
+						Long:  "Download the Pulumi plugins of the providers ahead of the first deploy.",
+					},
+				},
+			},
 			Run: func(cli *cli.Cli) error {
-				cfgPath, err := project.Discover()
+				cfgPath, err := cli.Discover()
 				if err != nil {
 					return err
 				}
@@ -721,6 +1146,10 @@ var root = &cli.Command{
 					return err
 				}
 
+				if err := p.CheckLockfile(); err != nil {
+					return err
+				}
+
 				spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
 				defer spin.Stop()
 				spin.Suffix = "  Installing providers..."
@@ -736,11 +1165,56 @@ var root = &cli.Command{
 				if err != nil {
 					return err
 				}
+				if cli.Bool("prefetch") {
+					spin.Suffix = "  Downloading provider plugins..."
+					stopTracking := cli.TrackDownloads(spin, spin.Suffix)
+					plugins, err := p.PrefetchPlugins(cli.Context)
+					stopTracking()
+					if err != nil {
+						return util.NewReadableError(err, "Could not download the provider plugins: "+err.Error())
+					}
+					spin.Stop()
+					ui.Success(fmt.Sprintf("Installed providers and %d plugins", len(plugins)))
+					return nil
+				}
 				spin.Stop()
 				ui.Success("Installed providers")
 				return nil
 			},
 		},
+		{
+			Name: "update",
+			Description: cli.Description{
+				Short: "Update the providers in sst.lock",
+				Long: strings.Join([]string{
+					"Installs the latest versions of your providers and writes them to `sst.lock`.",
+					"",
+					"```bash frame=\"none\"",
+					"sst update",
+					"```",
+					"",
+					"Pass in the name of a provider to only update that one.",
+					"",
+					"```bash frame=\"none\"",
+					"sst update aws",
+					"```",
+					"",
+					"Providers that have a `version` in your `sst.config.ts` stay at that version.",
+					"",
+					"The `sst.lock` also records the version of the CLI that installed it. Other versions of the CLI refuse to run the app until you switch back, or run `sst update` to lock the one you are using.",
+				}, "\n"),
+			},
+			Args: []cli.Argument{
+				{
+					Name: "provider",
+					Description: cli.Description{
+						Short: "The provider to update",
+						Long:  "The provider to update, all of them if it is not set.",
+					},
+				},
+			},
+			Run: CmdUpdate,
+		},
 		{
 			Name: "secret",
 			Description: cli.Description{
@@ -766,7 +1240,11 @@ var root = &cli.Command{
 				CmdSecretSet,
 				CmdSecretRemove,
 				CmdSecretLoad,
+				CmdSecretExport,
 				CmdSecretList,
+				CmdSecretHistory,
+				CmdSecretRollback,
+				CmdSecretRotate,
 			},
 		},
 		{
@@ -788,6 +1266,14 @@ var root = &cli.Command{
 						Long:  "Target to run against.",
 					},
 				},
+				{
+					Name: "role",
+					Type: "string",
+					Description: cli.Description{
+						Short: "Use the AWS permissions of a function",
+						Long:  "Run the command with temporary AWS credentials for the role of this function, in place of your own. It works for stages running in `sst dev`, where the roles of functions trust your account.",
+					},
+				},
 			},
 			Description: cli.Description{
 				Short: "Run a command with linked resources",
@@ -827,6 +1313,14 @@ var root = &cli.Command{
 					"sst shell -- node my-script.js --arg1 --arg2",
 					"```",
 					"",
+					"The secrets of the stage are set too, as `SST_SECRET_<name>`, like they are in the `run` function of your `sst.config.ts`.",
+					"",
+					"To check what a function is allowed to do, run the command with the permissions of its role.",
+					"",
+					"```bash frame=\"none\" frame=\"none\"",
+					"sst shell --role MyFunction -- aws s3 ls",
+					"```",
+					"",
 					"If no command is passed in, it opens a shell session with the linked resources.",
 					"",
 					"```bash frame=\"none\" frame=\"none\"",
@@ -870,17 +1364,13 @@ var root = &cli.Command{
 					"```bash frame=\"none\"",
 					"sst remove --target urn:pulumi:prod::www::sst:aws:Astro::Astro,urn:pulumi:prod::www::sst:aws:Bucket::Assets",
 					"```",
+					"",
+					"It refuses to remove resources that are protected, by the `protect` policy in your `sst.config.ts` or the `protect` option of a component. Pass in `--force-unprotect` to remove them anyway.",
 				}, "\n"),
 			},
 			Flags: []cli.Flag{
-				{
-					Name: "target",
-					Type: "string",
-					Description: cli.Description{
-						Short: "Comma separated list of target URNs",
-						Long:  "Comma separated list of target URNs.",
-					},
-				},
+				targetFlag,
+				forceUnprotectFlag,
 			},
 			Run: CmdRemove,
 		},
@@ -891,27 +1381,30 @@ var root = &cli.Command{
 				Long: strings.Join([]string{
 					"When you run `sst deploy`, it acquires a lock on your state file to prevent concurrent deploys.",
 					"",
-					"However, if something unexpectedly kills the `sst deploy` process, or if you manage to run `sst deploy` concurrently, the lock might not be released.",
+					"The lock is renewed while the deploy is running. If something unexpectedly kills the `sst deploy` process, the lock expires after a couple of minutes and the next deploy takes it over.",
+					"",
+					"You can run `sst unlock` to release a lock that has expired, or one left behind by an older version of the CLI.",
+					"",
+					"To break a lock that is still being renewed, pass in `--force`. Make sure the update holding it is not running, two updates running at once can corrupt your state.",
+					"",
+					"```bash frame=\"none\"",
+					"sst unlock --force",
+					"```",
 					"",
-					"This should not usually happen, but it can prevent you from deploying. You can run `sst unlock` to release the lock.",
+					"Who broke the lock is recorded in the history of the stage.",
 				}, "\n"),
 			},
-			Run: func(c *cli.Cli) error {
-				p, err := c.InitProject()
-				if err != nil {
-					return err
-				}
-				defer p.Cleanup()
-
-				err = p.Cancel()
-				if err != nil {
-					return err
-				}
-				color.New(color.FgGreen, color.Bold).Print("✓ ")
-				color.New(color.FgWhite).Print(" Unlocked the app state for: ")
-				color.New(color.FgWhite, color.Bold).Println(p.App().Name, "/", p.App().Stage)
-				return nil
+			Flags: []cli.Flag{
+				{
+					Name: "force",
+					Type: "bool",
+					Description: cli.Description{
+						Short: "Break a lock that is still held",
+						Long:  "Break the lock even if the update holding it is still renewing it.",
+					},
+				},
 			},
+			Run: CmdUnlock,
 		},
 		CmdVersion,
 		{
@@ -924,6 +1417,17 @@ var root = &cli.Command{
 					"```bash frame=\"none\"",
 					"sst upgrade 0.10",
 					"```",
+					"",
+					"Upgrades come from the stable channel by default. Pass in `--channel` to get beta or edge releases instead, or `--version` to stay on a major or minor version.",
+					"",
+					"```bash frame=\"none\"",
+					"sst upgrade --channel beta",
+					"sst upgrade --version 3.1",
+					"```",
+					"",
+					"The channel and version are saved and used by later upgrades. Pass in `--version latest` to remove the pin.",
+					"",
+					"The downloaded release is checked against the checksums published with it before it is installed.",
 				}, "\n"),
 			},
 			Args: cli.ArgumentList{
@@ -931,7 +1435,28 @@ var root = &cli.Command{
 					Name: "version",
 					Description: cli.Description{
 						Short: "A version to upgrade to",
-						Long:  "A version to upgrade to.",
+						Long:  "A version to upgrade to. A partial version, like 0.10, upgrades to the latest release that matches it.",
+					},
+				},
+			},
+			Flags: []cli.Flag{
+				{
+					Name: "channel",
+					Type: "string",
+					Description: cli.Description{
+						Short: "The release channel",
+						Long:  "The release channel to upgrade from, one of `stable`, `beta`, or `edge`.",
+					},
+					Complete: func([]string) []string {
+						return []string{global.ChannelStable, global.ChannelBeta, global.ChannelEdge}
+					},
+				},
+				{
+					Name: "version",
+					Type: "string",
+					Description: cli.Description{
+						Short: "Pin to a version",
+						Long:  "Pin upgrades to a major or minor version, like `3` or `3.1`. Pass in `latest` to remove the pin.",
 					},
 				},
 			},
@@ -951,6 +1476,19 @@ var root = &cli.Command{
 					"This is completely optional and can be disabled at any time.",
 					"",
 					"You can also opt-out by setting an environment variable: `SST_TELEMETRY_DISABLED=1` or `DO_NOT_TRACK=1`.",
+					"",
+					"---",
+					"",
+					"Separately, the CLI can trace where the time of a command goes with OpenTelemetry. Set",
+					"`OTEL_EXPORTER_OTLP_ENDPOINT` to your collector and it exports spans over OTLP for",
+					"evaluating the config, installing providers, builds, and each resource operation.",
+					"",
+					"```bash frame=\"none\"",
+					"OTEL_EXPORTER_OTLP_ENDPOINT=http://localhost:4318 sst deploy",
+					"```",
+					"",
+					"The spans of a deploy have a `sst.deploy.id` attribute, the same id as the events of",
+					"the deploy and its checkpoint in `sst state history`. Nothing is sent to SST.",
 				}, "\n"),
 			},
 			Children: []*cli.Command{
@@ -1026,24 +1564,30 @@ var root = &cli.Command{
 				}, "\n"),
 			},
 			Flags: []cli.Flag{
-				{
-					Name: "target",
-					Type: "string",
-					Description: cli.Description{
-						Short: "Comma separated list of target URNs",
-						Long:  "Comma separated list of target URNs.",
-					},
-				},
+				targetFlag,
 			},
 			Run: CmdRefresh,
 		},
 		{
-			Name:   "state",
-			Hidden: true,
+			Name: "state",
 			Description: cli.Description{
 				Short: "Manage state of your deployment",
+				Long: strings.Join([]string{
+					"Inspect and make changes to the state of your app.",
+					"",
+					"The `rm`, `mv`, and `restore` commands back up the state to `.sst/backup` before changing it.",
+				}, "\n"),
 			},
 			Children: []*cli.Command{
+				CmdStateList,
+				CmdStateShow,
+				CmdStateRemove,
+				CmdStateMove,
+				CmdStateHistory,
+				CmdStateRestore,
+				CmdStateEncrypt,
+				CmdStateExport,
+				CmdStateGC,
 				{
 					Name: "edit",
 					Description: cli.Description{
@@ -1099,5 +1643,23 @@ var root = &cli.Command{
 		CmdCert,
 		CmdTunnel,
 		CmdDiagnostic,
+		CmdServer,
+		CmdPs,
+		CmdEvents,
+		CmdLogs,
+		CmdImport,
+		CmdOutput,
+		CmdTypes,
+		CmdDoctor,
+		CmdGraph,
+		CmdPrintConfig,
+		CmdHistory,
+		CmdRollback,
+		CmdCancel,
+		CmdDrift,
+		CmdInspect,
+		CmdReplay,
+		CmdInvoke,
+		CmdCompletion,
 	},
 }
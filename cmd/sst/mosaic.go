@@ -1,27 +1,40 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net"
 	"os"
 	"os/exec"
 	"path/filepath"
+	goruntime "runtime"
+	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
 	"github.com/kballard/go-shellquote"
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/aws"
+	"github.com/sst/ion/cmd/sst/mosaic/capture"
 	"github.com/sst/ion/cmd/sst/mosaic/cloudflare"
 	"github.com/sst/ion/cmd/sst/mosaic/deployer"
 	"github.com/sst/ion/cmd/sst/mosaic/dev"
+	"github.com/sst/ion/cmd/sst/mosaic/hooks"
 	"github.com/sst/ion/cmd/sst/mosaic/multiplexer"
+	"github.com/sst/ion/cmd/sst/mosaic/offline"
 	"github.com/sst/ion/cmd/sst/mosaic/socket"
 	"github.com/sst/ion/cmd/sst/mosaic/watcher"
 	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/flag"
 	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
 	"github.com/sst/ion/pkg/runtime"
 	"github.com/sst/ion/pkg/server"
 	"golang.org/x/sync/errgroup"
@@ -38,17 +51,52 @@ func CmdMosaic(c *cli.Cli) error {
 			args = append(args, strings.Fields(arg)...)
 		}
 		slog.Info("dev mode with target", "args", c.Arguments())
-		cfgPath, err := project.Discover()
-		stage, err := c.Stage(cfgPath)
-		if err != nil {
-			return err
+		var cfgPath, stage string
+		if c.String("server") == "" {
+			var err error
+			cfgPath, err = c.Discover()
+			if err != nil {
+				return err
+			}
+			stage, err = c.Stage(cfgPath)
+			if err != nil {
+				return err
+			}
 		}
-		url, err := server.Discover(cfgPath, stage)
+		url, token, err := discoverServer(c, cfgPath, stage)
 		if err != nil {
 			return err
 		}
 		slog.Info("found server", "url", url)
-		evts, err := dev.Stream(c.Context, url, project.CompleteEvent{})
+		input := &dev.ConnectInput{
+			URL:     url,
+			Token:   token,
+			Version: version,
+			Types:   []interface{}{project.CompleteEvent{}},
+			OnError: func(err *dev.DecodeError) {
+				slog.Error("could not decode event from server", "err", err)
+			},
+			OnDisconnect: func(err error) {
+				if err != nil {
+					slog.Error("lost connection to server", "err", err)
+				}
+			},
+		}
+		evts, err := dev.Connect(c.Context, input)
+		var mismatch *dev.VersionMismatchError
+		if errors.As(err, &mismatch) {
+			// a server that was found through SST_SERVER or --server is not
+			// ours to restart
+			if c.String("server") != "" || os.Getenv("SST_SERVER") != "" {
+				return versionMismatchError(mismatch)
+			}
+			fmt.Println("[restarting sst dev, it was started by another version]")
+			input.URL, input.Token, err = restartServer(c, cfgPath, stage)
+			if err != nil {
+				return err
+			}
+			evts, err = dev.Connect(c.Context, input)
+		}
 		if err != nil {
 			return err
 		}
@@ -70,8 +118,15 @@ func CmdMosaic(c *cli.Cli) error {
 		for {
 			select {
 			case <-c.Context.Done():
+				// on windows it is in its own process group, the ctrl+c in the
+				// console does not reach it
+				if cmd != nil && goruntime.GOOS == "windows" {
+					util.InterruptProcess(cmd.Process)
+					<-processExited
+				}
 				return nil
 			case <-processExited:
+				cmd = nil
 				c.Cancel()
 				continue
 			case <-time.After(timeout):
@@ -89,7 +144,7 @@ func CmdMosaic(c *cli.Cli) error {
 				if os.Getenv("SST_CHILD") != "" {
 					query = "name=" + os.Getenv("SST_CHILD")
 				}
-				nextEnv, err := dev.Env(c.Context, query, url)
+				nextEnv, err := dev.Env(c.Context, query, url, token)
 				if err != nil {
 					return err
 				}
@@ -98,7 +153,7 @@ func CmdMosaic(c *cli.Cli) error {
 				}
 				if diff(env, nextEnv) {
 					if cmd != nil {
-						cmd.Process.Signal(syscall.SIGINT)
+						util.InterruptProcess(cmd.Process)
 						<-processExited
 						fmt.Println("\n[restarting]")
 					}
@@ -113,6 +168,10 @@ func CmdMosaic(c *cli.Cli) error {
 					cmd.Stdin = os.Stdin
 					cmd.Stdout = os.Stdout
 					cmd.Stderr = os.Stderr
+					// so it can be sent a ctrl+break when the env changes
+					if goruntime.GOOS == "windows" {
+						util.SetProcessGroupID(cmd)
+					}
 					cmd.Start()
 					go func() {
 						cmd.Wait()
@@ -124,26 +183,53 @@ func CmdMosaic(c *cli.Cli) error {
 		}
 	}
 
+	if c.String("server") != "" {
+		return CmdUI(c)
+	}
+
 	if os.Getenv("SST_SERVER") != "" {
 		return util.NewReadableError(nil, "The dev command for this process does not look right. Check your dev script in package.json to make sure it is simply starting your process and not running `sst dev`. More info here: https://sst.dev/docs/reference/cli/#dev")
 	}
 
+	if workspace, apps, err := workspaceDevApps(c); err != nil {
+		return err
+	} else if workspace != nil {
+		return CmdWorkspaceDev(c, workspace, apps)
+	}
+
 	p, err := c.InitProject()
 	if err != nil {
 		return err
 	}
 	os.Setenv("SST_STAGE", p.App().Stage)
 	slog.Info("mosaic", "project", p.PathRoot())
+	if c.Bool("inspect") {
+		if err := p.Runtime.EnableInspect(); err != nil {
+			return util.NewReadableError(err, "Could not read the debugger ports of your functions")
+		}
+	}
 
 	wg.Go(func() error {
 		defer c.Cancel()
-		return watcher.Start(c.Context, p.PathRoot())
+		return watcher.Start(c.Context, p.PathRoot(), p.App().Watch.Ignore)
 	})
 
 	server, err := server.New()
 	if err != nil {
 		return err
 	}
+	server.Version = version
+	// the flag wins over the environment variable
+	idle := c.String("idle-timeout")
+	if idle == "" {
+		idle = flag.SST_SERVER_IDLE_TIMEOUT
+	}
+	if idle != "" {
+		server.IdleTimeout, err = time.ParseDuration(idle)
+		if err != nil {
+			return util.NewReadableError(err, fmt.Sprintf("Invalid idle timeout %q, expected a duration like 30m", idle))
+		}
+	}
 
 	wg.Go(func() error {
 		defer c.Cancel()
@@ -156,22 +242,42 @@ func CmdMosaic(c *cli.Cli) error {
 	})
 
 	wg.Go(func() error {
-		evts := bus.Subscribe(&runtime.BuildInput{})
-		for {
-			select {
-			case <-c.Context.Done():
-				return nil
-			case evt := <-evts:
-				switch evt := evt.(type) {
-				case *runtime.BuildInput:
-					p.Runtime.AddTarget(evt)
-				}
-			}
+		for evt := range bus.Subscribe[*runtime.BuildInput](c.Context) {
+			p.Runtime.AddTarget(evt)
 		}
+		return nil
 	})
 
 	os.Setenv("SST_SERVER", fmt.Sprintf("http://localhost:%v", server.Port))
+	os.Setenv("SST_SERVER_TOKEN", server.Token)
+	offlineMode := c.Bool("offline")
+	if offlineMode {
+		port := offline.DefaultPort
+		if c.String("offline-port") != "" {
+			port, err = strconv.Atoi(c.String("offline-port"))
+			if err != nil {
+				return util.NewReadableError(err, "The offline port must be a number")
+			}
+		}
+		wg.Go(func() error {
+			defer c.Cancel()
+			return offline.Start(c.Context, p, server, port)
+		})
+		wg.Go(func() error {
+			return capture.Start(c.Context, server, capture.LocalInvoker(fmt.Sprintf("http://localhost:%d", port)))
+		})
+	} else {
+		if prov, ok := p.Provider("aws"); ok {
+			client := lambda.NewFromConfig(prov.(*provider.AwsProvider).Config())
+			wg.Go(func() error {
+				return capture.Start(c.Context, server, capture.LambdaInvoker(client))
+			})
+		}
+	}
 	for name, a := range p.App().Providers {
+		if offlineMode {
+			break
+		}
 		args := a
 		switch name {
 		case "aws":
@@ -192,25 +298,117 @@ func CmdMosaic(c *cli.Cli) error {
 		return server.Start(c.Context, p)
 	})
 
+	if c.Bool("ui") {
+		wg.Go(func() error {
+			addr := fmt.Sprintf("http://localhost:%v", server.Port)
+			// the browser is opened once the server answers
+			for i := 0; i < 100; i++ {
+				if conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%v", server.Port)); err == nil {
+					conn.Close()
+					util.OpenBrowser(dev.DashboardURL(addr, server.Token))
+					return nil
+				}
+				select {
+				case <-c.Context.Done():
+					return nil
+				case <-time.After(100 * time.Millisecond):
+				}
+			}
+			slog.Info("server did not come up, not opening the dashboard")
+			return nil
+		})
+	}
+
 	currentExecutable, _ := os.Executable()
 
 	mode := c.String("mode")
+	// with --json there is no one to look at the multiplexer
+	if output.Enabled() || c.Bool("no-ui") {
+		mode = "json"
+	}
+	// running headless is the same as --json, the events go to stdout
+	if mode == "json" && !output.Enabled() {
+		output.Enable("dev")
+	}
+	// the multiplexer runs processes in a pty, which windows does not have
+	if mode == "" && goruntime.GOOS == "windows" {
+		mode = "basic"
+	}
 	if mode == "" {
-		multi := multiplexer.New(c.Context)
+		layout := p.App().Dev.UI
+		overrides := map[string][]string{}
+		for action, keys := range layout.Keys {
+			overrides[action] = keys
+		}
+		keys, err := multiplexer.ParseKeys(overrides)
+		if err != nil {
+			return util.NewReadableError(err, "The keys in dev.ui are not valid: "+err.Error())
+		}
+		restarts := map[string]multiplexer.Restart{}
+		for key, restart := range layout.Restart {
+			restarts[key], err = multiplexer.ParseRestart(restart.Policy, restart.Retries, restart.Backoff)
+			if err != nil {
+				return util.NewReadableError(err, fmt.Sprintf("The restart policy of %s in dev.ui is not valid: %v", key, err))
+			}
+		}
+		options := []multiplexer.Option{
+			multiplexer.WithOrder(layout.Panes),
+			multiplexer.WithKeys(keys),
+			multiplexer.WithState(filepath.Join(p.PathWorkingDir(), "multiplexer.json")),
+			multiplexer.WithRestarts(restarts),
+		}
+		if layout.SidebarWidth > 0 {
+			options = append(options, multiplexer.WithSidebarWidth(layout.SidebarWidth))
+		}
+		multi := multiplexer.New(c.Context, options...)
+		hidden := map[string]bool{}
+		for _, key := range layout.Hide {
+			hidden[key] = true
+		}
+		addProcess := func(key string, args []string, icon string, title string, cwd string, killable bool, autostart bool, env ...string) {
+			if hidden[key] {
+				return
+			}
+			multi.AddProcess(key, args, icon, title, cwd, killable, autostart, env...)
+		}
+		// the tunnel is added again with every deploy, like its process it is
+		// only watched once
+		var watchingTunnel sync.Once
 		multiEnv := append(
 			c.Env(),
 			fmt.Sprintf("SST_SERVER=http://localhost:%v", server.Port),
+			"SST_SERVER_TOKEN="+server.Token,
 			"SST_STAGE="+p.App().Stage,
 		)
-		multi.AddProcess("deploy", []string{currentExecutable, "ui", "--filter=sst"}, "⑆", "SST", "", false, true, multiEnv...)
-		multi.AddProcess("function", []string{currentExecutable, "ui", "--filter=function"}, "λ", "Functions", "", false, true, multiEnv...)
+		if !offlineMode {
+			addProcess("deploy", []string{currentExecutable, "ui", "--filter=sst"}, "⑆", "SST", "", false, true, multiEnv...)
+		}
+		addProcess("function", []string{currentExecutable, "ui", "--filter=function"}, "λ", "Functions", "", false, true, append([]string{"SST_MULTIPLEXER=true"}, multiEnv...)...)
+		for name, proc := range layout.Processes {
+			words, err := shellquote.Split(proc.Command)
+			if err != nil || len(words) == 0 {
+				return util.NewReadableError(err, fmt.Sprintf("The command of the %s process in dev.ui is not valid", name))
+			}
+			title := proc.Title
+			if title == "" {
+				title = name
+			}
+			env := multiEnv
+			// wrapped so it gets its links and restarts when they change,
+			// there are none to wait for offline
+			if !offlineMode {
+				words = append([]string{currentExecutable, "dev", "--"}, words...)
+				env = append([]string{"SST_CHILD=" + name}, multiEnv...)
+			}
+			addProcess(name, words, "→", title, filepath.Join(cwd, proc.Directory), true, proc.Autostart == nil || *proc.Autostart, env...)
+		}
 		wg.Go(func() error {
 			defer c.Cancel()
 			multi.Start()
 			return nil
 		})
 		wg.Go(func() error {
-			evts := bus.Subscribe(&project.CompleteEvent{})
+			evts := bus.Subscribe[any](c.Context, bus.Topic(&project.CompleteEvent{}))
 			defer c.Cancel()
 			for {
 				select {
@@ -220,16 +418,29 @@ func CmdMosaic(c *cli.Cli) error {
 					switch evt := unknown.(type) {
 					case *project.CompleteEvent:
 						for _, d := range evt.Devs {
+							title := d.Title
+							if title == "" {
+								title = d.Name
+							}
+							if d.Docker != nil {
+								addProcess(
+									d.Name,
+									[]string{currentExecutable, "container", d.Name},
+									"→",
+									title,
+									cwd,
+									true,
+									d.Autostart,
+									multiEnv...,
+								)
+								continue
+							}
 							if d.Command == "" {
 								continue
 							}
 							dir := filepath.Join(cwd, d.Directory)
 							words, _ := shellquote.Split(d.Command)
-							title := d.Title
-							if title == "" {
-								title = d.Name
-							}
-							multi.AddProcess(
+							addProcess(
 								d.Name,
 								append([]string{currentExecutable, "dev", "--"}, words...),
 								"→",
@@ -241,7 +452,10 @@ func CmdMosaic(c *cli.Cli) error {
 							)
 						}
 						for range evt.Tunnels {
-							multi.AddProcess("tunnel", []string{currentExecutable, "tunnel", "--stage", p.App().Stage}, "⇌", "Tunnel", "", true, true, os.Environ()...)
+							addProcess("tunnel", []string{currentExecutable, "tunnel", "--stage", p.App().Stage}, "⇌", "Tunnel", "", true, true, os.Environ()...)
+							watchingTunnel.Do(func() {
+								go watchTunnel(c.Context, p.App().Name, p.App().Stage)
+							})
 						}
 						break
 					}
@@ -250,9 +464,15 @@ func CmdMosaic(c *cli.Cli) error {
 		})
 	}
 
+	if !offlineMode {
+		wg.Go(func() error {
+			defer c.Cancel()
+			return deployer.Start(c.Context, p, server)
+		})
+	}
+
 	wg.Go(func() error {
-		defer c.Cancel()
-		return deployer.Start(c.Context, p, server)
+		return hooks.Start(c.Context, p)
 	})
 
 	if mode == "basic" {
@@ -261,12 +481,31 @@ func CmdMosaic(c *cli.Cli) error {
 		})
 	}
 
+	if mode == "json" {
+		wg.Go(func() error {
+			return streamEvents(c.Context)
+		})
+	}
+
 	err = wg.Wait()
 	slog.Info("done mosaic", "err", err)
 	return err
 
 }
 
+// streamEvents writes every event of the session as a JSON record, events
+// that cannot be encoded are left out so they do not end the stream.
+func streamEvents(ctx context.Context) error {
+	for evt := range bus.Subscribe[any](ctx) {
+		if _, err := json.Marshal(evt); err != nil {
+			slog.Info("skipping event", "type", bus.Topic(evt), "err", err)
+			continue
+		}
+		output.Event(evt)
+	}
+	return nil
+}
+
 func diff(a map[string]string, b map[string]string) bool {
 	if len(a) != len(b) {
 		return true
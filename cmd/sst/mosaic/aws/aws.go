@@ -28,6 +28,7 @@ import (
 	"github.com/sst/ion/cmd/sst/mosaic/watcher"
 	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/metrics"
 	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/project/provider"
 	"github.com/sst/ion/pkg/runtime"
@@ -46,6 +47,21 @@ type FunctionInvokedEvent struct {
 	WorkerID   string
 	RequestID  string
 	Input      []byte
+	// Context has the Lambda-Runtime- headers the invocation was sent with,
+	// like the deadline and the ARN of the function
+	Context map[string]string
+}
+
+// InvocationContext picks the Lambda-Runtime- headers out of the response to
+// a next invocation request.
+func InvocationContext(header http.Header) map[string]string {
+	result := map[string]string{}
+	for name := range header {
+		if strings.HasPrefix(name, "Lambda-Runtime-") {
+			result[name] = header.Get(name)
+		}
+	}
+	return result
 }
 
 type FunctionResponseEvent struct {
@@ -64,6 +80,14 @@ type FunctionErrorEvent struct {
 	Trace        []string `json:"trace"`
 }
 
+// FunctionInitEvent is published when a deployed function connects, with its
+// environment.
+type FunctionInitEvent struct {
+	FunctionID string
+	WorkerID   string
+	Env        []string
+}
+
 type FunctionBuildEvent struct {
 	FunctionID string
 	Errors     []string
@@ -74,6 +98,8 @@ type FunctionLogEvent struct {
 	WorkerID   string
 	RequestID  string
 	Line       string
+	// Time is set for lines read from CloudWatch by `sst logs`
+	Time time.Time
 }
 
 var ErrIoTDelay = fmt.Errorf("iot not available")
@@ -209,7 +235,7 @@ func Start(
 	workerResponseChan := make(chan workerResponse, 1000)
 	workerShutdownChan := make(chan *WorkerInfo, 1000)
 
-	evts := bus.Subscribe(&watcher.FileChangedEvent{}, &project.CompleteEvent{}, &runtime.BuildInput{})
+	evts := bus.Subscribe[any](ctx, bus.Topics(&watcher.FileChangedEvent{}, &project.CompleteEvent{}, &runtime.BuildInput{})...)
 
 	if token := mqttClient.Subscribe(prefix+"/+/init", 1, func(c MQTT.Client, m MQTT.Message) {
 		slog.Info("iot", "topic", m.Topic())
@@ -228,7 +254,7 @@ func Start(
 	slog.Info("connected to iot")
 
 	go func() {
-		evts := bus.Subscribe(&FunctionLogEvent{}, &FunctionInvokedEvent{}, &FunctionResponseEvent{}, &FunctionErrorEvent{}, &FunctionBuildEvent{})
+		evts := bus.Subscribe[any](ctx, bus.Topics(&FunctionLogEvent{}, &FunctionInvokedEvent{}, &FunctionResponseEvent{}, &FunctionErrorEvent{}, &FunctionBuildEvent{})...)
 		logs := map[string]*os.File{}
 
 		getLog := func(functionID string, requestID string) *os.File {
@@ -344,6 +370,15 @@ func Start(
 			return true
 		}
 
+		// when each request was handed to a worker, for the invocation metrics
+		invoked := map[string]time.Time{}
+		observe := func(functionID, requestID string) {
+			if start, ok := invoked[requestID]; ok {
+				metrics.InvocationDuration.WithLabelValues(functionID).Observe(time.Since(start).Seconds())
+				delete(invoked, requestID)
+			}
+		}
+
 		for {
 			select {
 			case <-ctx.Done():
@@ -359,17 +394,21 @@ func Start(
 				}
 				if evt.path[len(evt.path)-1] == "next" {
 					info.CurrentRequestID = evt.response.Header.Get("lambda-runtime-aws-request-id")
+					invoked[info.CurrentRequestID] = time.Now()
+					metrics.Invocations.WithLabelValues(info.FunctionID).Inc()
 					bus.Publish(&FunctionInvokedEvent{
 						FunctionID: info.FunctionID,
 						WorkerID:   info.WorkerID,
 						RequestID:  info.CurrentRequestID,
 						Input:      responseBody,
+						Context:    InvocationContext(evt.response.Header),
 					})
 					topic := prefix + "/" + info.WorkerID + "/ack"
 					slog.Info("acking", "topic", topic)
 					mqttClient.Publish(topic, 1, false, []byte{1}).Wait()
 				}
 				if evt.path[len(evt.path)-1] == "response" {
+					observe(info.FunctionID, evt.path[len(evt.path)-2])
 					bus.Publish(&FunctionResponseEvent{
 						FunctionID: info.FunctionID,
 						WorkerID:   info.WorkerID,
@@ -378,6 +417,8 @@ func Start(
 					})
 				}
 				if evt.path[len(evt.path)-1] == "error" {
+					observe(info.FunctionID, evt.path[len(evt.path)-2])
+					metrics.InvocationErrors.WithLabelValues(info.FunctionID).Inc()
 					fee := &FunctionErrorEvent{
 						FunctionID: info.FunctionID,
 						WorkerID:   info.WorkerID,
@@ -463,6 +504,11 @@ func Start(
 					continue
 				}
 				workerEnv[workerID] = payload.Env
+				bus.Publish(&FunctionInitEvent{
+					FunctionID: payload.FunctionID,
+					WorkerID:   workerID,
+					Env:        payload.Env,
+				})
 				if ok := run(payload.FunctionID, workerID); !ok {
 					result, _ := http.Post("http://"+server+workerID+"/runtime/init/error", "application/json", strings.NewReader(`{"errorMessage":"Function failed to build"}`))
 					defer result.Body.Close()
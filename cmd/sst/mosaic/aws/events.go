@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// eventTemplates are sample events of the services that usually invoke a
+// function, to test it with.
+var eventTemplates = map[string]string{
+	"api": `{
+  "version": "2.0",
+  "routeKey": "$default",
+  "rawPath": "/",
+  "rawQueryString": "",
+  "headers": {
+    "accept": "*/*",
+    "content-type": "application/json",
+    "host": "example.execute-api.us-east-1.amazonaws.com",
+    "user-agent": "sst"
+  },
+  "requestContext": {
+    "accountId": "123456789012",
+    "apiId": "example",
+    "domainName": "example.execute-api.us-east-1.amazonaws.com",
+    "domainPrefix": "example",
+    "http": {
+      "method": "GET",
+      "path": "/",
+      "protocol": "HTTP/1.1",
+      "sourceIp": "127.0.0.1",
+      "userAgent": "sst"
+    },
+    "requestId": "example-request-id",
+    "routeKey": "$default",
+    "stage": "$default",
+    "time": "01/Jan/2024:00:00:00 +0000",
+    "timeEpoch": 1704067200000
+  },
+  "isBase64Encoded": false
+}`,
+	"sqs": `{
+  "Records": [
+    {
+      "messageId": "059f36b4-87a3-44ab-83d2-661975830a7d",
+      "receiptHandle": "AQEBwJnKyrHigUMZj6rYigCgxlaS3SLy0a",
+      "body": "{\"hello\":\"world\"}",
+      "attributes": {
+        "ApproximateReceiveCount": "1",
+        "SentTimestamp": "1704067200000",
+        "SenderId": "123456789012",
+        "ApproximateFirstReceiveTimestamp": "1704067200001"
+      },
+      "messageAttributes": {},
+      "md5OfBody": "fbc24bcc7a1794758fc1327fcfebdaf6",
+      "eventSource": "aws:sqs",
+      "eventSourceARN": "arn:aws:sqs:us-east-1:123456789012:example",
+      "awsRegion": "us-east-1"
+    }
+  ]
+}`,
+	"s3": `{
+  "Records": [
+    {
+      "eventVersion": "2.1",
+      "eventSource": "aws:s3",
+      "awsRegion": "us-east-1",
+      "eventTime": "2024-01-01T00:00:00.000Z",
+      "eventName": "ObjectCreated:Put",
+      "userIdentity": {
+        "principalId": "EXAMPLE"
+      },
+      "requestParameters": {
+        "sourceIPAddress": "127.0.0.1"
+      },
+      "responseElements": {
+        "x-amz-request-id": "EXAMPLE123456789",
+        "x-amz-id-2": "EXAMPLE123/5678abcdefghijklambdaisawesome/mnopqrstuvwxyzABCDEFGH"
+      },
+      "s3": {
+        "s3SchemaVersion": "1.0",
+        "configurationId": "example",
+        "bucket": {
+          "name": "example-bucket",
+          "ownerIdentity": {
+            "principalId": "EXAMPLE"
+          },
+          "arn": "arn:aws:s3:::example-bucket"
+        },
+        "object": {
+          "key": "example.txt",
+          "size": 1024,
+          "eTag": "0123456789abcdef0123456789abcdef",
+          "sequencer": "0A1B2C3D4E5F678901"
+        }
+      }
+    }
+  ]
+}`,
+}
+
+// EventTemplates returns the names of the sample events.
+func EventTemplates() []string {
+	result := []string{}
+	for name := range eventTemplates {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// EventTemplate returns the sample event with name.
+func EventTemplate(name string) ([]byte, error) {
+	match, ok := eventTemplates[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown event %q, use one of %s", name, strings.Join(EventTemplates(), ", "))
+	}
+	return []byte(match), nil
+}
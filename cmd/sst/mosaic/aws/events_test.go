@@ -0,0 +1,21 @@
+package aws
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEventTemplates(t *testing.T) {
+	for _, name := range EventTemplates() {
+		data, err := EventTemplate(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(data) {
+			t.Errorf("Event %s is not valid JSON", name)
+		}
+	}
+	if _, err := EventTemplate("missing"); err == nil {
+		t.Errorf("Expected an error for an unknown event")
+	}
+}
@@ -0,0 +1,265 @@
+package aws
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/js"
+	"github.com/sst/ion/pkg/project"
+)
+
+// LogGroup is the CloudWatch log group of a deployed function.
+type LogGroup struct {
+	FunctionID string
+	Name       string
+}
+
+// LogGroups finds the log groups of the deployed functions in complete. If
+// component is set only functions that are, or are part of, the component
+// with that name are returned.
+func LogGroups(complete *project.CompleteEvent, component string) []LogGroup {
+	result := []LogGroup{}
+	for _, item := range Functions(complete, component) {
+		result = append(result, LogGroup{FunctionID: item.FunctionID, Name: item.LogGroup})
+	}
+	return result
+}
+
+// DeployedFunction is a Lambda function in the state of a stage.
+type DeployedFunction struct {
+	// FunctionID is the name of the Function component it belongs to
+	FunctionID string
+	Name       string
+	LogGroup   string
+}
+
+// Functions finds the deployed functions in complete, the same way as
+// LogGroups.
+func Functions(complete *project.CompleteEvent, component string) []DeployedFunction {
+	resources := map[resource.URN]apitype.ResourceV3{}
+	for _, item := range complete.Resources {
+		resources[item.URN] = item
+	}
+	result := []DeployedFunction{}
+	for _, item := range complete.Resources {
+		if item.Type != "aws:lambda/function:Function" {
+			continue
+		}
+		functionID := item.URN.Name()
+		matched := component == ""
+		for urn := item.URN; urn != ""; urn = resources[urn].Parent {
+			parent := resources[urn]
+			if parent.Type == "sst:aws:Function" && functionID == item.URN.Name() {
+				functionID = urn.Name()
+			}
+			if urn.Name() == component && parent.Type != "pulumi:pulumi:Stack" {
+				matched = true
+			}
+		}
+		if !matched {
+			continue
+		}
+		name, _ := item.Outputs["name"].(string)
+		group := "/aws/lambda/" + name
+		if logging, ok := item.Outputs["loggingConfig"].(map[string]interface{}); ok {
+			if match, ok := logging["logGroup"].(string); ok && match != "" {
+				group = match
+			}
+		}
+		result = append(result, DeployedFunction{FunctionID: functionID, Name: name, LogGroup: group})
+	}
+	return result
+}
+
+type TailInput struct {
+	Groups []LogGroup
+	Since  time.Time
+	// Filter is a CloudWatch Logs filter pattern
+	Filter string
+	// Sourcemaps is the directory with the source maps of every function,
+	// stack traces are mapped back to the source with them
+	Sourcemaps string
+	Root       string
+}
+
+const tailInterval = time.Second
+
+// Tail polls the log groups for new log events and publishes them as
+// FunctionLogEvent until ctx is cancelled.
+func Tail(ctx context.Context, cfg aws.Config, input TailInput) error {
+	client := cloudwatchlogs.NewFromConfig(cfg)
+	start := map[string]int64{}
+	seen := map[string]map[string]struct{}{}
+	symbols := &symbolicator{dir: input.Sourcemaps, root: input.Root, maps: map[string]*js.SourceMap{}}
+	for _, group := range input.Groups {
+		start[group.Name] = input.Since.UnixMilli()
+		seen[group.Name] = map[string]struct{}{}
+	}
+	for {
+		for _, group := range input.Groups {
+			err := tailGroup(ctx, client, group, input.Filter, start, seen, symbols)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(tailInterval):
+		}
+	}
+}
+
+func tailGroup(ctx context.Context, client *cloudwatchlogs.Client, group LogGroup, filter string, start map[string]int64, seen map[string]map[string]struct{}, symbols *symbolicator) error {
+	params := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName: aws.String(group.Name),
+		StartTime:    aws.Int64(start[group.Name]),
+	}
+	if filter != "" {
+		params.FilterPattern = aws.String(filter)
+	}
+	paginator := cloudwatchlogs.NewFilterLogEventsPaginator(client, params)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			// a function that has never been invoked has no log group yet
+			if strings.Contains(err.Error(), "ResourceNotFoundException") {
+				slog.Info("log group not found", "group", group.Name)
+				return nil
+			}
+			return err
+		}
+		for _, event := range page.Events {
+			timestamp := aws.ToInt64(event.Timestamp)
+			id := aws.ToString(event.EventId)
+			// the next poll starts at the latest timestamp so events at that
+			// exact millisecond are returned again
+			if timestamp > start[group.Name] {
+				start[group.Name] = timestamp
+				seen[group.Name] = map[string]struct{}{}
+			}
+			if _, ok := seen[group.Name][id]; ok {
+				continue
+			}
+			seen[group.Name][id] = struct{}{}
+			requestID, line := parseLogLine(aws.ToString(event.Message))
+			bus.Publish(&FunctionLogEvent{
+				FunctionID: group.FunctionID,
+				WorkerID:   aws.ToString(event.LogStreamName),
+				RequestID:  requestID,
+				Line:       symbols.symbolicate(group.FunctionID, line),
+				Time:       time.UnixMilli(timestamp),
+			})
+		}
+	}
+	return nil
+}
+
+// parseLogLine pulls the request ID out of a Lambda log line and pretty
+// prints the message if it is JSON. Lines are either in the text format,
+// `<timestamp>\t<request id>\t<level>\t<message>`, or the JSON format.
+func parseLogLine(message string) (string, string) {
+	message = strings.TrimRight(message, "\n")
+	var structured map[string]interface{}
+	if json.Unmarshal([]byte(message), &structured) == nil {
+		requestID, _ := structured["requestId"].(string)
+		if inner, ok := structured["message"]; ok && requestID != "" {
+			if text, ok := inner.(string); ok {
+				return requestID, prettyJSON(text)
+			}
+			formatted, _ := json.MarshalIndent(inner, "", "  ")
+			return requestID, string(formatted)
+		}
+		return requestID, prettyJSON(message)
+	}
+	parts := strings.SplitN(message, "\t", 4)
+	if len(parts) == 4 {
+		if _, err := time.Parse(time.RFC3339, parts[0]); err == nil {
+			return parts[1], parts[2] + " " + prettyJSON(parts[3])
+		}
+	}
+	return "", message
+}
+
+func prettyJSON(input string) string {
+	trimmed := strings.TrimSpace(input)
+	if !strings.HasPrefix(trimmed, "{") && !strings.HasPrefix(trimmed, "[") {
+		return input
+	}
+	var out bytes.Buffer
+	if json.Indent(&out, []byte(trimmed), "", "  ") != nil {
+		return input
+	}
+	return out.String()
+}
+
+// stackFrame matches a location in the code of a function, like in
+// `at handler (file:///var/task/src/index.mjs:3:9)`.
+var stackFrame = regexp.MustCompile(`(?:file://)?/var/task/([^\s:()"']+):(\d+):(\d+)`)
+
+// symbolicator maps the locations in stack traces back to the source with
+// the source maps kept when the functions were deployed.
+type symbolicator struct {
+	dir  string
+	root string
+	// maps is keyed by the path of the source map, nil if it can't be read
+	maps map[string]*js.SourceMap
+}
+
+func (s *symbolicator) symbolicate(functionID string, line string) string {
+	if s.dir == "" || !strings.Contains(line, "/var/task/") {
+		return line
+	}
+	return stackFrame.ReplaceAllStringFunc(line, func(frame string) string {
+		match := stackFrame.FindStringSubmatch(frame)
+		mapFile := filepath.Join(s.dir, functionID, filepath.FromSlash(match[1])+".map")
+		sourceMap := s.load(mapFile)
+		if sourceMap == nil {
+			return frame
+		}
+		lineNumber, _ := strconv.Atoi(match[2])
+		column, _ := strconv.Atoi(match[3])
+		position, ok := sourceMap.Find(lineNumber-1, column-1)
+		if !ok {
+			return frame
+		}
+		source := filepath.Join(filepath.Dir(mapFile), filepath.FromSlash(position.Source))
+		if rel, err := filepath.Rel(s.root, source); err == nil {
+			source = rel
+		}
+		return fmt.Sprintf("%s:%d:%d", filepath.ToSlash(source), position.Line+1, position.Column+1)
+	})
+}
+
+func (s *symbolicator) load(file string) *js.SourceMap {
+	if sourceMap, ok := s.maps[file]; ok {
+		return sourceMap
+	}
+	var sourceMap *js.SourceMap
+	data, err := os.ReadFile(file)
+	if err == nil {
+		sourceMap, err = js.ParseSourceMap(data)
+		if err != nil {
+			slog.Error("failed to parse source map", "file", file, "err", err)
+		}
+	}
+	s.maps[file] = sourceMap
+	return sourceMap
+}
@@ -0,0 +1,278 @@
+package capture
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sst/ion/cmd/sst/mosaic/aws"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/server"
+)
+
+// Capacity is how many invocations are kept, the oldest ones are dropped
+// first.
+const Capacity = 100
+
+var ErrInvocationNotFound = fmt.Errorf("invocation not found")
+
+// Invocation is a captured invocation of a function, with everything needed
+// to run it again.
+type Invocation struct {
+	ID         string            `json:"id"`
+	FunctionID string            `json:"functionID"`
+	Time       time.Time         `json:"time"`
+	Duration   time.Duration     `json:"duration"`
+	Context    map[string]string `json:"context,omitempty"`
+	Input      json.RawMessage   `json:"input"`
+	Output     json.RawMessage   `json:"output,omitempty"`
+	Error      *Error            `json:"error,omitempty"`
+	Logs       []string          `json:"logs"`
+	// Done is set once the function responded or failed
+	Done bool `json:"done"`
+}
+
+type Error struct {
+	Type    string   `json:"type"`
+	Message string   `json:"message"`
+	Trace   []string `json:"trace,omitempty"`
+}
+
+// Result is the response to a replayed invocation.
+type Result struct {
+	// ID is the request ID of the invocation when it was captured
+	ID     string          `json:"id,omitempty"`
+	Output json.RawMessage `json:"output"`
+	// Error is the type of the error the function failed with
+	Error string   `json:"error,omitempty"`
+	Logs  []string `json:"logs,omitempty"`
+}
+
+// Invoker invokes a function with payload, name is its deployed name if it
+// is known.
+type Invoker func(ctx context.Context, functionID string, name string, payload []byte) (*Result, error)
+
+type store struct {
+	mu          sync.Mutex
+	invocations []*Invocation
+	names       map[string]string
+}
+
+func newStore() *store {
+	return &store{
+		invocations: []*Invocation{},
+		names:       map[string]string{},
+	}
+}
+
+// raw keeps payloads that are JSON as they are, anything else becomes a
+// string.
+func raw(data []byte) json.RawMessage {
+	if len(data) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return json.RawMessage(data)
+	}
+	encoded, _ := json.Marshal(string(data))
+	return json.RawMessage(encoded)
+}
+
+func (s *store) find(id string) *Invocation {
+	for index := len(s.invocations) - 1; index >= 0; index-- {
+		if s.invocations[index].ID == id {
+			return s.invocations[index]
+		}
+	}
+	return nil
+}
+
+func (s *store) handle(unknown interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch evt := unknown.(type) {
+	case *aws.FunctionInitEvent:
+		for _, item := range evt.Env {
+			if name, ok := strings.CutPrefix(item, "AWS_LAMBDA_FUNCTION_NAME="); ok {
+				s.names[evt.FunctionID] = name
+			}
+		}
+	case *aws.FunctionInvokedEvent:
+		s.invocations = append(s.invocations, &Invocation{
+			ID:         evt.RequestID,
+			FunctionID: evt.FunctionID,
+			Time:       time.Now(),
+			Context:    evt.Context,
+			Input:      raw(evt.Input),
+			Logs:       []string{},
+		})
+		if len(s.invocations) > Capacity {
+			s.invocations = s.invocations[len(s.invocations)-Capacity:]
+		}
+	case *aws.FunctionLogEvent:
+		if match := s.find(evt.RequestID); match != nil {
+			match.Logs = append(match.Logs, evt.Line)
+		}
+	case *aws.FunctionResponseEvent:
+		if match := s.find(evt.RequestID); match != nil {
+			match.Output = raw(evt.Output)
+			match.Duration = time.Since(match.Time)
+			match.Done = true
+		}
+	case *aws.FunctionErrorEvent:
+		if match := s.find(evt.RequestID); match != nil {
+			match.Error = &Error{
+				Type:    evt.ErrorType,
+				Message: evt.ErrorMessage,
+				Trace:   evt.Trace,
+			}
+			match.Duration = time.Since(match.Time)
+			match.Done = true
+		}
+	}
+}
+
+// list returns the invocations, the newest first.
+func (s *store) list(functionID string) []*Invocation {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := []*Invocation{}
+	for index := len(s.invocations) - 1; index >= 0; index-- {
+		item := s.invocations[index]
+		if functionID != "" && item.FunctionID != functionID {
+			continue
+		}
+		copied := *item
+		copied.Logs = append([]string{}, item.Logs...)
+		result = append(result, &copied)
+	}
+	return result
+}
+
+func (s *store) replay(ctx context.Context, invoke Invoker, id string, payload []byte) (*Result, error) {
+	s.mu.Lock()
+	match := s.find(id)
+	var functionID string
+	if match != nil {
+		functionID = match.FunctionID
+		if len(payload) == 0 {
+			payload = match.Input
+		}
+	}
+	s.mu.Unlock()
+	if match == nil {
+		return nil, ErrInvocationNotFound
+	}
+	return s.invoke(ctx, invoke, functionID, "", payload)
+}
+
+// invoke runs the function with payload and attaches the logs of the
+// invocation it captured, which are the ones of the local code. The deployed
+// name is used until the function has started in this session.
+func (s *store) invoke(ctx context.Context, invoke Invoker, functionID string, name string, payload []byte) (*Result, error) {
+	s.mu.Lock()
+	if match, ok := s.names[functionID]; ok {
+		name = match
+	}
+	s.mu.Unlock()
+	started := time.Now()
+	result, err := invoke(ctx, functionID, name, payload)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range s.invocations {
+		if item.FunctionID == functionID && !item.Time.Before(started) {
+			result.ID = item.ID
+			result.Logs = append([]string{}, item.Logs...)
+			break
+		}
+	}
+	return result, nil
+}
+
+// Start captures the invocations of the session and serves them on
+// /api/invocations. Replaying one invokes the function again with invoke, so
+// it runs the current code. Functions can also be invoked with a new payload
+// on /api/invoke.
+func Start(ctx context.Context, s *server.Server, invoke Invoker) error {
+	store := newStore()
+	evts := bus.Subscribe[any](ctx, bus.Topics(
+		&aws.FunctionInitEvent{},
+		&aws.FunctionInvokedEvent{},
+		&aws.FunctionLogEvent{},
+		&aws.FunctionResponseEvent{},
+		&aws.FunctionErrorEvent{},
+	)...)
+
+	s.Mux.HandleFunc("/api/invocations", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, store.list(r.URL.Query().Get("function")))
+	})
+	s.Mux.HandleFunc("/api/invoke", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		payload, err := readBody(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		query := r.URL.Query()
+		result, err := store.invoke(r.Context(), invoke, query.Get("function"), query.Get("name"), payload)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	})
+	s.Mux.HandleFunc("/api/invocations/", func(w http.ResponseWriter, r *http.Request) {
+		id, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/invocations/"), "/")
+		switch {
+		case action == "" && r.Method == http.MethodGet:
+			for _, item := range store.list("") {
+				if item.ID == id {
+					writeJSON(w, http.StatusOK, item)
+					return
+				}
+			}
+			http.Error(w, ErrInvocationNotFound.Error(), http.StatusNotFound)
+		case action == "replay" && r.Method == http.MethodPost:
+			// the payload can be edited by sending a new one
+			payload, err := readBody(r)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			result, err := store.replay(r.Context(), invoke, id, payload)
+			if errors.Is(err, ErrInvocationNotFound) {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+			writeJSON(w, http.StatusOK, result)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	for evt := range evts {
+		store.handle(evt)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,101 @@
+package capture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/sst/ion/cmd/sst/mosaic/aws"
+)
+
+func TestHandle(t *testing.T) {
+	s := newStore()
+	s.handle(&aws.FunctionInitEvent{FunctionID: "Api", Env: []string{"AWS_LAMBDA_FUNCTION_NAME=app-dev-Api"}})
+	s.handle(&aws.FunctionInvokedEvent{FunctionID: "Api", RequestID: "1", Input: []byte(`{"path":"/"}`)})
+	s.handle(&aws.FunctionLogEvent{FunctionID: "Api", RequestID: "1", Line: "hello"})
+	s.handle(&aws.FunctionResponseEvent{FunctionID: "Api", RequestID: "1", Output: []byte("plain")})
+	s.handle(&aws.FunctionInvokedEvent{FunctionID: "Cron", RequestID: "2"})
+	s.handle(&aws.FunctionErrorEvent{FunctionID: "Cron", RequestID: "2", ErrorType: "TypeError", ErrorMessage: "boom"})
+
+	if s.names["Api"] != "app-dev-Api" {
+		t.Errorf("Unexpected name %q", s.names["Api"])
+	}
+	all := s.list("")
+	if len(all) != 2 || all[0].ID != "2" || all[1].ID != "1" {
+		t.Fatalf("Expected the newest invocation first, got %v", all)
+	}
+	api := all[1]
+	if !api.Done || api.Error != nil || string(api.Output) != `"plain"` || string(api.Input) != `{"path":"/"}` {
+		t.Errorf("Unexpected invocation %+v", api)
+	}
+	if len(api.Logs) != 1 || api.Logs[0] != "hello" {
+		t.Errorf("Unexpected logs %v", api.Logs)
+	}
+	if cron := all[0]; !cron.Done || cron.Error == nil || cron.Error.Type != "TypeError" {
+		t.Errorf("Unexpected invocation %+v", cron)
+	}
+	if filtered := s.list("Api"); len(filtered) != 1 || filtered[0].ID != "1" {
+		t.Errorf("Unexpected filtered invocations %v", filtered)
+	}
+}
+
+func TestCapacity(t *testing.T) {
+	s := newStore()
+	for i := 0; i < Capacity+10; i++ {
+		s.handle(&aws.FunctionInvokedEvent{FunctionID: "Api", RequestID: fmt.Sprint(i)})
+	}
+	all := s.list("")
+	if len(all) != Capacity {
+		t.Fatalf("Expected %d invocations, got %d", Capacity, len(all))
+	}
+	if all[len(all)-1].ID != "10" {
+		t.Errorf("Expected the oldest invocations to be dropped, got %s", all[len(all)-1].ID)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	s := newStore()
+	s.handle(&aws.FunctionInitEvent{FunctionID: "Api", Env: []string{"AWS_LAMBDA_FUNCTION_NAME=app-dev-Api"}})
+	s.handle(&aws.FunctionInvokedEvent{FunctionID: "Api", RequestID: "1", Input: []byte(`{"a":1}`)})
+
+	var got []string
+	invoke := func(ctx context.Context, functionID string, name string, payload []byte) (*Result, error) {
+		got = []string{functionID, name, string(payload)}
+		return &Result{Output: payload}, nil
+	}
+	if _, err := s.replay(context.Background(), invoke, "1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(got) != `[Api app-dev-Api {"a":1}]` {
+		t.Errorf("Unexpected invoke %v", got)
+	}
+	if _, err := s.replay(context.Background(), invoke, "1", []byte(`{"a":2}`)); err != nil {
+		t.Fatal(err)
+	}
+	if got[2] != `{"a":2}` {
+		t.Errorf("Expected the payload to be replaced, got %s", got[2])
+	}
+	if _, err := s.replay(context.Background(), invoke, "missing", nil); !errors.Is(err, ErrInvocationNotFound) {
+		t.Errorf("Expected ErrInvocationNotFound, got %v", err)
+	}
+}
+
+func TestInvoke(t *testing.T) {
+	s := newStore()
+	invoke := func(ctx context.Context, functionID string, name string, payload []byte) (*Result, error) {
+		if name != "app-dev-Api" {
+			t.Errorf("Unexpected name %q", name)
+		}
+		s.handle(&aws.FunctionInvokedEvent{FunctionID: functionID, RequestID: "1", Input: payload})
+		s.handle(&aws.FunctionLogEvent{FunctionID: functionID, RequestID: "1", Line: "local"})
+		return &Result{Output: []byte(`"ok"`)}, nil
+	}
+	result, err := s.invoke(context.Background(), invoke, "Api", "app-dev-Api", []byte(`{}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ID != "1" || len(result.Logs) != 1 || result.Logs[0] != "local" {
+		t.Errorf("Expected the captured logs to be attached, got %+v", result)
+	}
+}
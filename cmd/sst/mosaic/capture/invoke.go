@@ -0,0 +1,150 @@
+package capture
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/aws/aws-sdk-go-v2/service/lambda/types"
+	"github.com/sst/ion/pkg/server"
+)
+
+func readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+	defer r.Body.Close()
+	return io.ReadAll(r.Body)
+}
+
+// LambdaInvoker invokes the deployed function. In dev it forwards the
+// invocation back to the local worker, so it runs the local code with the
+// permissions of the function.
+func LambdaInvoker(client *lambda.Client) Invoker {
+	return func(ctx context.Context, functionID string, name string, payload []byte) (*Result, error) {
+		if name == "" {
+			return nil, fmt.Errorf("the deployed name of %s is not known yet", functionID)
+		}
+		return Lambda(ctx, client, name, payload)
+	}
+}
+
+// Lambda invokes the deployed function with name and returns its response
+// with the tail of its logs.
+func Lambda(ctx context.Context, client *lambda.Client, name string, payload []byte) (*Result, error) {
+	result, err := client.Invoke(ctx, &lambda.InvokeInput{
+		FunctionName: &name,
+		Payload:      payload,
+		LogType:      types.LogTypeTail,
+	})
+	if err != nil {
+		return nil, err
+	}
+	output := &Result{Output: raw(result.Payload)}
+	if result.FunctionError != nil {
+		output.Error = *result.FunctionError
+	}
+	if result.LogResult != nil {
+		logs, err := base64.StdEncoding.DecodeString(*result.LogResult)
+		if err == nil {
+			output.Logs = strings.Split(strings.TrimRight(string(logs), "\n"), "\n")
+		}
+	}
+	return output, nil
+}
+
+// LocalInvoker invokes the function through the invoke API of offline mode
+// at base.
+func LocalInvoker(base string) Invoker {
+	return func(ctx context.Context, functionID string, name string, payload []byte) (*Result, error) {
+		endpoint := strings.TrimSuffix(base, "/") + "/2015-03-31/functions/" + url.PathEscape(functionID) + "/invocations"
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("invoke failed with %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+		}
+		return &Result{
+			Output: raw(body),
+			Error:  resp.Header.Get("X-Amz-Function-Error"),
+		}, nil
+	}
+}
+
+func request(ctx context.Context, method string, path string, body []byte, address string, token string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, server.BaseURL(address)+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	server.Authorize(req, token)
+	resp, err := server.HttpClient(address).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrInvocationNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%s", strings.TrimSpace(string(message)))
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// List returns the invocations captured by the server of `sst dev`, the
+// newest first.
+func List(ctx context.Context, functionID string, address string, token string) ([]*Invocation, error) {
+	var result []*Invocation
+	err := request(ctx, http.MethodGet, "/api/invocations?function="+url.QueryEscape(functionID), nil, address, token, &result)
+	return result, err
+}
+
+func Get(ctx context.Context, id string, address string, token string) (*Invocation, error) {
+	var result Invocation
+	err := request(ctx, http.MethodGet, "/api/invocations/"+url.PathEscape(id), nil, address, token, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Replay runs a captured invocation again, with payload instead of the
+// captured one if it is set.
+func Replay(ctx context.Context, id string, payload []byte, address string, token string) (*Result, error) {
+	var result Result
+	err := request(ctx, http.MethodPost, "/api/invocations/"+url.PathEscape(id)+"/replay", payload, address, token, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Invoke runs a function through the server of `sst dev`, so it runs the
+// local code. Name is its deployed name.
+func Invoke(ctx context.Context, functionID string, name string, payload []byte, address string, token string) (*Result, error) {
+	var result Result
+	query := url.Values{"function": {functionID}, "name": {name}}
+	err := request(ctx, http.MethodPost, "/api/invoke?"+query.Encode(), payload, address, token, &result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -39,7 +39,7 @@ func Start(ctx context.Context, proj *project.Project, args map[string]interface
 		return util.NewReadableError(nil, "Cloudflare provider not found in project configuration")
 	}
 	api := prov.(*provider.CloudflareProvider).Api()
-	evts := bus.Subscribe(&project.CompleteEvent{}, &watcher.FileChangedEvent{}, &runtime.BuildInput{})
+	evts := bus.Subscribe[any](ctx, bus.Topics(&project.CompleteEvent{}, &watcher.FileChangedEvent{}, &runtime.BuildInput{})...)
 	builds := map[string]*runtime.BuildOutput{}
 	targets := map[string]*runtime.BuildInput{}
 	type tailRef struct {
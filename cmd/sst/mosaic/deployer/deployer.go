@@ -21,7 +21,7 @@ type DeployFailedEvent struct {
 func Start(ctx context.Context, p *project.Project, server *server.Server) error {
 	defer slog.Info("deployer done")
 	watchedFiles := make(map[string]bool)
-	events := bus.Subscribe(ctx, &watcher.FileChangedEvent{}, &DeployRequestedEvent{}, &project.BuildSuccessEvent{})
+	events := bus.Subscribe[any](ctx, bus.Topics(&watcher.FileChangedEvent{}, &DeployRequestedEvent{}, &project.BuildSuccessEvent{})...)
 	for {
 		slog.Info("deployer waiting for trigger")
 		select {
@@ -37,9 +37,10 @@ func Start(ctx context.Context, p *project.Project, server *server.Server) error
 				if evt, ok := evt.(*watcher.FileChangedEvent); !ok || watchedFiles[evt.Path] {
 					slog.Info("deployer deploying")
 					err := p.Run(ctx, &project.StackInput{
-						Command:    "deploy",
-						Dev:        true,
-						ServerPort: server.Port,
+						Command:     "deploy",
+						Dev:         true,
+						ServerPort:  server.Port,
+						ServerToken: server.Token,
 					})
 					if err != nil {
 						transformed := errors.Transform(err)
@@ -0,0 +1,29 @@
+package dev
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"strings"
+)
+
+// The dashboard is a page in the browser with what the terminal ui shows,
+// the invocations and logs of the functions and the resources of the
+// deploys. It streams /stream and reads /status like any other client.
+//
+//go:embed dashboard
+var dashboard embed.FS
+
+const DashboardPath = "/ui/"
+
+// DashboardURL is the address to open the dashboard of the server at addr
+// with. The token is passed in the fragment so it is never sent in a request
+// or written to the logs of a proxy.
+func DashboardURL(addr string, token string) string {
+	return strings.TrimSuffix(addr, "/") + DashboardPath + "#token=" + token
+}
+
+func handleDashboard() http.Handler {
+	sub, _ := fs.Sub(dashboard, "dashboard")
+	return http.StripPrefix(DashboardPath, http.FileServer(http.FS(sub)))
+}
@@ -0,0 +1,28 @@
+package dev
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDashboard(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle(DashboardPath, handleDashboard())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + DashboardPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("Expected the page, got %s %s", resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	if url := DashboardURL("http://localhost:13557/", "secret"); url != "http://localhost:13557/ui/#token=secret" {
+		t.Fatalf("Unexpected url %s", url)
+	}
+}
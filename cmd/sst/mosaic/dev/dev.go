@@ -7,7 +7,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"reflect"
+	"sort"
 
 	"github.com/sst/ion/cmd/sst/mosaic/deployer"
 	"github.com/sst/ion/pkg/bus"
@@ -16,58 +16,45 @@ import (
 	"golang.org/x/sync/errgroup"
 )
 
-type Message struct {
-	Type  string          `json:"type"`
-	Event json.RawMessage `json:"event"`
+// Envelope is the unit written to /stream. Kind says whether it carries an
+// event or is part of the connection itself, Type is the name of the event.
+type Envelope struct {
+	Version int             `json:"version"`
+	Kind    string          `json:"kind"`
+	Seq     uint64          `json:"seq,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	Event   json.RawMessage `json:"event,omitempty"`
 }
 
+// EnvelopeVersion is bumped whenever the shape of Envelope changes in a way
+// older clients cannot read.
+const EnvelopeVersion = 1
+
+const (
+	KindEvent     = "event"
+	KindHeartbeat = "heartbeat"
+	KindShutdown  = "shutdown"
+)
+
 func Start(ctx context.Context, p *project.Project, server *server.Server) error {
 	var complete *project.CompleteEvent
 	var wg errgroup.Group
 	wg.Go(func() error {
-		evts := bus.Subscribe(&project.CompleteEvent{})
-		for {
-			select {
-			case <-ctx.Done():
-				return nil
-			case evt := <-evts:
-				complete = evt.(*project.CompleteEvent)
-			}
+		for evt := range bus.Subscribe[*project.CompleteEvent](ctx) {
+			complete = evt
 		}
+		return nil
 	})
 
-	server.Mux.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Add("content-type", "application/x-ndjson")
-		w.WriteHeader(http.StatusOK)
-		slog.Info("subscribed", "addr", r.RemoteAddr)
-		flusher, _ := w.(http.Flusher)
-		flusher.Flush()
-		ctx := r.Context()
-		events := bus.SubscribeAll()
-		if complete != nil {
-			go func() {
-				events <- complete
-			}()
-		}
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case event := <-events:
-				t := reflect.TypeOf(event)
-				if t.Kind() == reflect.Ptr {
-					t = t.Elem()
-				}
-				bytes, _ := json.Marshal(event)
-				data, _ := json.Marshal(&Message{
-					Type:  t.String(),
-					Event: json.RawMessage(bytes),
-				})
-				w.Write(data)
-				flusher.Flush()
-			}
-		}
+	hub := newHub(defaultReplaySize)
+	hub.status = newStatus(p.App().Name, p.App().Stage)
+	wg.Go(func() error {
+		hub.run(ctx)
+		return nil
 	})
+	server.Mux.HandleFunc("/stream", handleStream(hub, server.Track))
+	server.Mux.HandleFunc("/status", handleStatus(hub, hub.status))
+	server.Mux.Handle(DashboardPath, handleDashboard())
 
 	server.Mux.HandleFunc(("/api/deploy"), func(w http.ResponseWriter, r *http.Request) {
 		slog.Info("deploy requested")
@@ -77,94 +64,80 @@ func Start(ctx context.Context, p *project.Project, server *server.Server) error
 	server.Mux.HandleFunc("/api/env", func(w http.ResponseWriter, r *http.Request) {
 		directory := r.URL.Query().Get("directory")
 		name := r.URL.Query().Get("name")
+		if complete == nil {
+			http.Error(w, "not deployed yet", http.StatusServiceUnavailable)
+			return
+		}
 		cwd, _ := os.Getwd()
-		for _, d := range complete.Devs {
-			full := filepath.Join(cwd, d.Directory)
-			slog.Info("matching dev", "full", full, "directory", directory)
-			if (directory != "" && full == directory) || (name != "" && d.Name == name) {
-				env, err := p.EnvFor(ctx, complete, d.Name)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				body, err := json.Marshal(env)
-				if err != nil {
-					http.Error(w, err.Error(), http.StatusInternalServerError)
-					return
-				}
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusOK)
-				w.Write(body)
-				return
-			}
+		match, ok := findDev(complete, cwd, directory, name)
+		if !ok {
+			match, ok = processDev(p, complete, directory, name)
+		}
+		if !ok {
+			slog.Info("dev not found", "directory", directory)
+			http.Error(w, "dev not found", http.StatusNotFound)
+			return
 		}
-		slog.Info("dev not found", "directory", directory)
-		http.Error(w, "dev not found", http.StatusNotFound)
-		return
+		env, err := p.EnvForDev(ctx, complete, match)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body, err := json.Marshal(env)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write(body)
 	})
 
 	return wg.Wait()
 }
 
-func Stream(ctx context.Context, url string, types ...interface{}) (chan any, error) {
-	out := make(chan any)
-	req, err := http.NewRequestWithContext(ctx, "GET", url+"/stream", nil)
-	if err != nil {
-		return nil, err
-	}
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+func findDev(complete *project.CompleteEvent, cwd string, directory string, name string) (project.Dev, bool) {
+	for _, d := range complete.Devs {
+		full := filepath.Join(cwd, d.Directory)
+		slog.Info("matching dev", "full", full, "directory", directory)
+		if (directory != "" && full == directory) || (name != "" && d.Name == name) {
+			return d, true
+		}
 	}
-	decoder := json.NewDecoder(resp.Body)
+	return project.Dev{}, false
+}
 
-	registry := map[string]reflect.Type{}
-	for _, v := range types {
-		t := reflect.TypeOf(v)
-		if t.Kind() == reflect.Ptr {
-			t = t.Elem()
+// processDev is the dev of a process that is not a component. The processes
+// in dev.ui get the resources in their link, and commands wrapped with
+// `sst dev` anywhere else get all of them like `sst shell`.
+func processDev(p *project.Project, complete *project.CompleteEvent, directory string, name string) (project.Dev, bool) {
+	result := project.Dev{Name: name}
+	if name != "" {
+		proc, ok := p.App().Dev.UI.Processes[name]
+		if !ok {
+			return result, false
 		}
-		name := t.String()
-		registry[name] = t
+		result.Links = proc.Link
 	}
-
-	go func() {
-		defer close(out)
-		defer resp.Body.Close()
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			default:
-				var msg Message
-				err := decoder.Decode(&msg)
-				if err != nil {
-					return
-				}
-				prototype, ok := registry[msg.Type]
-				if !ok {
-					continue
-				}
-				target := reflect.New(prototype).Interface()
-				err = json.Unmarshal(msg.Event, target)
-				if err != nil {
-					continue
-				}
-				out <- target
-			}
+	if name == "" && directory == "" {
+		return result, false
+	}
+	if len(result.Links) == 0 {
+		for link := range complete.Links {
+			result.Links = append(result.Links, link)
 		}
-	}()
-
-	return out, nil
+		sort.Strings(result.Links)
+	}
+	return result, true
 }
 
-func Env(ctx context.Context, query string, url string) (map[string]string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", url+"/api/env?"+query, nil)
+func Env(ctx context.Context, query string, url string, token string) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", server.BaseURL(url)+"/api/env?"+query, nil)
 	if err != nil {
 		return nil, err
 	}
-	resp, err := http.DefaultClient.Do(req)
+	server.Authorize(req, token)
+	resp, err := server.HttpClient(url).Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -177,12 +150,13 @@ func Env(ctx context.Context, query string, url string) (map[string]string, erro
 	return result, nil
 }
 
-func Deploy(ctx context.Context, url string) error {
-	req, err := http.NewRequestWithContext(ctx, "POST", url+"/api/deploy", nil)
+func Deploy(ctx context.Context, url string, token string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", server.BaseURL(url)+"/api/deploy", nil)
 	if err != nil {
 		return err
 	}
-	_, err = http.DefaultClient.Do(req)
+	server.Authorize(req, token)
+	_, err = server.HttpClient(url).Do(req)
 	if err != nil {
 		return err
 	}
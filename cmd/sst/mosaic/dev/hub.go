@@ -0,0 +1,165 @@
+package dev
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/sst/ion/pkg/bus"
+)
+
+// hub owns the single bus subscription backing /stream. Every event is given
+// a sequence number and kept in a ring buffer so clients that connect late,
+// or reconnect, can be brought up to date before receiving live events.
+type hub struct {
+	mu       sync.Mutex
+	session  string
+	seq      uint64
+	size     int
+	ring     []*Envelope
+	snapshot *Envelope
+	clients  map[*client]struct{}
+	// status is updated with every event before it goes out
+	status *status
+}
+
+const defaultReplaySize = 1000
+
+// Overflow policies decide what happens when a client is not keeping up and
+// its queue is full. Either way the other clients are not held up.
+const (
+	OverflowDropOldest = "drop-oldest"
+	OverflowDisconnect = "disconnect"
+)
+
+const clientQueueSize = 1000
+
+// StreamDropEvent is published when events could not be delivered to a slow
+// /stream client. Dropped is how many events the client has missed so far.
+type StreamDropEvent struct {
+	Addr         string
+	Dropped      uint64
+	Disconnected bool
+}
+
+type client struct {
+	addr     string
+	overflow string
+	queue    chan *Envelope
+	// kicked is closed when the client is disconnected for falling behind
+	kicked    chan struct{}
+	dropped   uint64
+	connected time.Time
+}
+
+func newHub(size int) *hub {
+	return &hub{
+		session: fmt.Sprintf("%x", time.Now().UnixNano()),
+		size:    size,
+		ring:    make([]*Envelope, 0, size),
+		clients: map[*client]struct{}{},
+	}
+}
+
+func (h *hub) run(ctx context.Context) {
+	events := bus.SubscribeAll()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event := <-events:
+			if h.status != nil {
+				h.status.apply(event)
+			}
+			h.publish(event)
+		}
+	}
+}
+
+func (h *hub) publish(event interface{}) {
+	msg, err := encode(event)
+	if err != nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.seq++
+	msg.Seq = h.seq
+	if len(h.ring) == h.size {
+		copy(h.ring, h.ring[1:])
+		h.ring = h.ring[:h.size-1]
+	}
+	h.ring = append(h.ring, msg)
+	// the latest complete event describes the current state of the app so it
+	// is always replayed, even once it has been pushed out of the ring
+	if msg.Type == completeEventType {
+		h.snapshot = msg
+	}
+	for c := range h.clients {
+		select {
+		case c.queue <- msg:
+			continue
+		default:
+		}
+		if c.overflow == OverflowDisconnect {
+			// the event that did not fit is the one that is dropped
+			c.dropped++
+			delete(h.clients, c)
+			close(c.kicked)
+			slog.Warn("stream client too slow, disconnecting", "addr", c.addr)
+			go bus.Publish(&StreamDropEvent{Addr: c.addr, Dropped: c.dropped, Disconnected: true})
+			continue
+		}
+		// only publish sends to the queue and it holds the lock, so once the
+		// oldest message is taken there is room for this one
+		select {
+		case <-c.queue:
+		default:
+		}
+		c.queue <- msg
+		c.dropped++
+		if c.dropped == 1 || c.dropped%clientQueueSize == 0 {
+			slog.Warn("stream client too slow, dropping events", "addr", c.addr, "dropped", c.dropped)
+			go bus.Publish(&StreamDropEvent{Addr: c.addr, Dropped: c.dropped})
+		}
+	}
+}
+
+// subscribe returns the buffered events newer than after followed by a
+// client whose queue receives live events. after is only honoured if session
+// matches the current hub, otherwise sequence numbers are from a previous
+// server.
+func (h *hub) subscribe(session string, after uint64, addr string, overflow string) ([]*Envelope, *client, func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if session != h.session {
+		after = 0
+	}
+	replay := []*Envelope{}
+	if h.snapshot != nil && h.snapshot.Seq > after && (len(h.ring) == 0 || h.ring[0].Seq > h.snapshot.Seq) {
+		replay = append(replay, h.snapshot)
+	}
+	for _, msg := range h.ring {
+		if msg.Seq > after {
+			replay = append(replay, msg)
+		}
+	}
+	if overflow != OverflowDisconnect {
+		overflow = OverflowDropOldest
+	}
+	c := &client{
+		addr:      addr,
+		overflow:  overflow,
+		queue:     make(chan *Envelope, clientQueueSize),
+		kicked:    make(chan struct{}),
+		connected: time.Now(),
+	}
+	h.clients[c] = struct{}{}
+	return replay, c, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		delete(h.clients, c)
+	}
+}
@@ -0,0 +1,68 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/sst/ion/pkg/project"
+)
+
+type testEvent struct {
+	Value int
+}
+
+func TestHubReplay(t *testing.T) {
+	h := newHub(3)
+	h.publish(&project.CompleteEvent{})
+	for i := 0; i < 4; i++ {
+		h.publish(&testEvent{Value: i})
+	}
+
+	replay, _, unsubscribe := h.subscribe("", 0, "", "")
+	defer unsubscribe()
+	if len(replay) != 4 {
+		t.Fatalf("Expected 4 replayed events, got %v", len(replay))
+	}
+	if replay[0].Type != completeEventType {
+		t.Errorf("Expected snapshot first, got %v", replay[0].Type)
+	}
+	for i, msg := range replay[1:] {
+		if msg.Seq != uint64(i+3) {
+			t.Errorf("Expected seq %v, got %v", i+3, msg.Seq)
+		}
+	}
+
+	replay, _, unsubscribe = h.subscribe(h.session, 4, "", "")
+	defer unsubscribe()
+	if len(replay) != 1 || replay[0].Seq != 5 {
+		t.Errorf("Expected only seq 5 after resuming, got %v", replay)
+	}
+}
+
+func TestHubOverflow(t *testing.T) {
+	h := newHub(1)
+	_, slow, unsubscribe := h.subscribe("", 0, "slow", OverflowDropOldest)
+	defer unsubscribe()
+	_, kicked, unsubscribe := h.subscribe("", 0, "kicked", OverflowDisconnect)
+	defer unsubscribe()
+	for i := 0; i < clientQueueSize+1; i++ {
+		h.publish(&testEvent{Value: i})
+	}
+
+	select {
+	case <-kicked.kicked:
+	default:
+		t.Errorf("Expected client to be disconnected")
+	}
+	if len(slow.queue) != clientQueueSize {
+		t.Fatalf("Expected full queue, got %v", len(slow.queue))
+	}
+	if msg := <-slow.queue; msg.Seq != 2 {
+		t.Errorf("Expected oldest event to be dropped, got seq %v", msg.Seq)
+	}
+	if kicked.dropped != 1 {
+		t.Errorf("Expected the disconnected client to miss 1 event, got %v", kicked.dropped)
+	}
+	if slow.dropped != 1 {
+		t.Errorf("Expected 1 dropped event, got %v", slow.dropped)
+	}
+}
@@ -0,0 +1,283 @@
+package dev
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/pkg/project"
+)
+
+// Status is what GET /status returns, so editors can show if a deploy is
+// running and how the last one went without replaying the stream. It is
+// built from the events the hub publishes to /stream, so it agrees with what
+// the clients of the stream have seen.
+type Status struct {
+	App   string `json:"app"`
+	Stage string `json:"stage"`
+	// Operation is the stack command in progress, nil when there is none
+	Operation  *StatusOperation  `json:"operation"`
+	Resources  []*StatusResource `json:"resources"`
+	LastDeploy *StatusDeploy     `json:"lastDeploy"`
+	Clients    []StatusClient    `json:"clients"`
+}
+
+type StatusOperation struct {
+	Command  string    `json:"command"`
+	DeployID string    `json:"deployID"`
+	Dev      bool      `json:"dev"`
+	Started  time.Time `json:"started"`
+}
+
+const (
+	ResourceReady   = "ready"
+	ResourcePending = "pending"
+	ResourceFailed  = "failed"
+	ResourceDeleted = "deleted"
+)
+
+type StatusResource struct {
+	URN   string `json:"urn"`
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Op is the step of the current or the last deploy, like create or same
+	Op    string `json:"op,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+const (
+	DeploySuccess = "success"
+	DeployFailure = "failure"
+)
+
+type StatusDeploy struct {
+	Command  string          `json:"command"`
+	DeployID string          `json:"deployID"`
+	Status   string          `json:"status"`
+	Started  time.Time       `json:"started"`
+	Finished time.Time       `json:"finished"`
+	Changes  map[string]int  `json:"changes"`
+	Errors   []project.Error `json:"errors"`
+}
+
+type StatusClient struct {
+	Addr      string    `json:"addr"`
+	Connected time.Time `json:"connected"`
+	Dropped   uint64    `json:"dropped"`
+}
+
+// status keeps the snapshot up to date as events come in.
+type status struct {
+	mu        sync.Mutex
+	app       string
+	stage     string
+	operation *StatusOperation
+	resources map[string]*StatusResource
+	order     []string
+	// state has the resources of the last complete event
+	state   map[string]bool
+	changes map[string]int
+	last    *StatusDeploy
+}
+
+func newStatus(app string, stage string) *status {
+	return &status{
+		app:       app,
+		stage:     stage,
+		resources: map[string]*StatusResource{},
+		state:     map[string]bool{},
+		changes:   map[string]int{},
+	}
+}
+
+func (s *status) resource(urn string) *StatusResource {
+	item, ok := s.resources[urn]
+	if !ok {
+		parsed := resource.URN(urn)
+		item = &StatusResource{URN: urn, Type: string(parsed.Type()), Name: parsed.Name()}
+		s.resources[urn] = item
+		s.order = append(s.order, urn)
+	}
+	return item
+}
+
+func (s *status) apply(event interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch evt := event.(type) {
+	case *project.StackCommandEvent:
+		s.operation = &StatusOperation{
+			Command:  evt.Command,
+			DeployID: evt.DeployID,
+			Dev:      evt.Dev,
+			Started:  time.Now(),
+		}
+		s.changes = map[string]int{}
+		order := s.order
+		s.order = nil
+		for _, urn := range order {
+			item := s.resources[urn]
+			// a failed create is only kept until the next deploy
+			if !s.state[urn] {
+				delete(s.resources, urn)
+				continue
+			}
+			s.order = append(s.order, urn)
+			item.State = ResourceReady
+			item.Op = ""
+			item.Error = ""
+		}
+	case *apitype.ResourcePreEvent:
+		item := s.resource(evt.Metadata.URN)
+		item.State = ResourcePending
+		item.Op = string(evt.Metadata.Op)
+		item.Error = ""
+	case *apitype.ResOutputsEvent:
+		item := s.resource(evt.Metadata.URN)
+		item.Op = string(evt.Metadata.Op)
+		item.State = ResourceReady
+		if strings.HasPrefix(item.Op, "delete") || strings.HasPrefix(item.Op, "discard") {
+			item.State = ResourceDeleted
+		}
+		s.changes[item.Op]++
+	case *apitype.ResOpFailedEvent:
+		item := s.resource(evt.Metadata.URN)
+		item.Op = string(evt.Metadata.Op)
+		item.State = ResourceFailed
+	case *apitype.DiagnosticEvent:
+		if evt.Severity == "error" && evt.URN != "" {
+			if item, ok := s.resources[evt.URN]; ok && item.Error == "" {
+				item.Error = strings.TrimSpace(evt.Message)
+			}
+		}
+	case *project.BuildFailedEvent:
+		s.finish(DeployFailure, []project.Error{{Message: evt.Error}})
+	case *project.CompleteEvent:
+		s.complete(evt)
+	}
+}
+
+// complete replaces the resources with the ones in the state, keeping the
+// steps and errors of the deploy that got there.
+func (s *status) complete(evt *project.CompleteEvent) {
+	previous, order := s.resources, s.order
+	s.resources = map[string]*StatusResource{}
+	s.state = map[string]bool{}
+	s.order = nil
+	for _, item := range evt.Resources {
+		s.state[string(item.URN)] = true
+		next := s.resource(string(item.URN))
+		next.State = ResourceReady
+		if old, ok := previous[string(item.URN)]; ok && !evt.Old {
+			next.Op = old.Op
+			if old.State == ResourceFailed {
+				next.State = ResourceFailed
+				next.Error = old.Error
+			}
+		}
+	}
+	// a create that failed is not in the state but is kept to show its error
+	for _, urn := range order {
+		if old := previous[urn]; old.State == ResourceFailed && !evt.Old {
+			if _, ok := s.resources[urn]; !ok {
+				*s.resource(urn) = *old
+			}
+		}
+	}
+	for _, item := range evt.Errors {
+		if next, ok := s.resources[item.URN]; ok {
+			next.State = ResourceFailed
+			next.Error = item.Message
+		}
+	}
+	// the state of a previous run is sent when the server starts, it is not
+	// a deploy of its own
+	if evt.Old {
+		return
+	}
+	result := DeploySuccess
+	if len(evt.Errors) > 0 || !evt.Finished {
+		result = DeployFailure
+	}
+	s.finish(result, evt.Errors)
+	if s.last != nil && s.last.DeployID == "" {
+		s.last.DeployID = evt.DeployID
+	}
+}
+
+func (s *status) finish(result string, errors []project.Error) {
+	deploy := &StatusDeploy{
+		Status:   result,
+		Finished: time.Now(),
+		Changes:  s.changes,
+		Errors:   errors,
+	}
+	if deploy.Errors == nil {
+		deploy.Errors = []project.Error{}
+	}
+	if s.operation != nil {
+		deploy.Command = s.operation.Command
+		deploy.DeployID = s.operation.DeployID
+		deploy.Started = s.operation.Started
+	}
+	s.last = deploy
+	s.operation = nil
+	s.changes = map[string]int{}
+}
+
+func (s *status) snapshot() *Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := &Status{
+		App:       s.app,
+		Stage:     s.stage,
+		Resources: []*StatusResource{},
+		Clients:   []StatusClient{},
+	}
+	if s.operation != nil {
+		operation := *s.operation
+		result.Operation = &operation
+	}
+	for _, urn := range s.order {
+		item := *s.resources[urn]
+		result.Resources = append(result.Resources, &item)
+	}
+	if s.last != nil {
+		last := *s.last
+		result.LastDeploy = &last
+	}
+	return result
+}
+
+// clientStatus lists the clients connected to the stream.
+func (h *hub) clientStatus() []StatusClient {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	result := []StatusClient{}
+	for c := range h.clients {
+		result = append(result, StatusClient{Addr: c.addr, Connected: c.connected, Dropped: c.dropped})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Connected.Before(result[j].Connected)
+	})
+	return result
+}
+
+func handleStatus(hub *hub, status *status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		result := status.snapshot()
+		result.Clients = hub.clientStatus()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
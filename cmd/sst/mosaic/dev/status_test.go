@@ -0,0 +1,66 @@
+package dev
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/pkg/project"
+)
+
+func TestStatus(t *testing.T) {
+	urn := func(name string) string {
+		return "urn:pulumi:dev::app::aws:s3/bucketV2:BucketV2::" + name
+	}
+	step := func(name string, op apitype.OpType) apitype.StepEventMetadata {
+		return apitype.StepEventMetadata{URN: urn(name), Op: op}
+	}
+	s := newStatus("app", "dev")
+	s.apply(&project.CompleteEvent{Old: true, Resources: []apitype.ResourceV3{{URN: resource.URN(urn("Assets"))}}})
+	if snapshot := s.snapshot(); snapshot.LastDeploy != nil || len(snapshot.Resources) != 1 || snapshot.Resources[0].State != ResourceReady {
+		t.Fatalf("Expected the old state without a deploy, got %+v", snapshot)
+	}
+
+	s.apply(&project.StackCommandEvent{Command: "deploy", DeployID: "abc", Dev: true})
+	s.apply(&apitype.ResourcePreEvent{Metadata: step("Assets", apitype.OpUpdate)})
+	s.apply(&apitype.ResourcePreEvent{Metadata: step("Uploads", apitype.OpCreate)})
+	s.apply(&apitype.ResOutputsEvent{Metadata: step("Assets", apitype.OpUpdate)})
+	snapshot := s.snapshot()
+	if snapshot.Operation == nil || snapshot.Operation.DeployID != "abc" || !snapshot.Operation.Dev {
+		t.Fatalf("Expected the deploy in progress, got %+v", snapshot.Operation)
+	}
+	if snapshot.Resources[0].State != ResourceReady || snapshot.Resources[1].State != ResourcePending || snapshot.Resources[1].Name != "Uploads" {
+		t.Errorf("Expected Assets updated and Uploads pending, got %+v %+v", snapshot.Resources[0], snapshot.Resources[1])
+	}
+
+	s.apply(&apitype.ResOpFailedEvent{Metadata: step("Uploads", apitype.OpCreate)})
+	s.apply(&apitype.DiagnosticEvent{URN: urn("Uploads"), Severity: "error", Message: "bucket exists\n"})
+	s.apply(&project.CompleteEvent{
+		Finished:  true,
+		Resources: []apitype.ResourceV3{{URN: resource.URN(urn("Assets"))}},
+		Errors:    []project.Error{{Message: "bucket exists", URN: urn("Uploads")}},
+	})
+	snapshot = s.snapshot()
+	if snapshot.Operation != nil {
+		t.Errorf("Expected no operation after the deploy, got %+v", snapshot.Operation)
+	}
+	last := snapshot.LastDeploy
+	if last == nil || last.Status != DeployFailure || last.DeployID != "abc" || last.Changes["update"] != 1 || len(last.Errors) != 1 {
+		t.Fatalf("Expected a failed deploy with one update, got %+v", last)
+	}
+	if len(snapshot.Resources) != 2 || snapshot.Resources[0].Op != "update" {
+		t.Fatalf("Expected the resources of the state and the failed one, got %+v", snapshot.Resources)
+	}
+	if failed := snapshot.Resources[1]; failed.State != ResourceFailed || failed.Error != "bucket exists" {
+		t.Errorf("Expected Uploads to have failed, got %+v", failed)
+	}
+
+	s.apply(&project.StackCommandEvent{Command: "deploy", DeployID: "def"})
+	if resources := s.snapshot().Resources; len(resources) != 1 || resources[0].Error != "" {
+		t.Errorf("Expected the failed create to be dropped by the next deploy, got %+v", resources)
+	}
+	s.apply(&project.BuildFailedEvent{Error: "syntax error"})
+	if last := s.snapshot().LastDeploy; last.DeployID != "def" || last.Status != DeployFailure || last.Errors[0].Message != "syntax error" {
+		t.Errorf("Expected the build failure as the last deploy, got %+v", last)
+	}
+}
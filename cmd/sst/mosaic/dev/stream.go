@@ -0,0 +1,724 @@
+package dev
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sst/ion/pkg/network"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/server"
+)
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin:       func(r *http.Request) bool { return true },
+	EnableCompression: true,
+}
+
+// Control messages are sent by websocket clients back to the server over the
+// same connection the events are streamed on.
+type Control struct {
+	Type  string   `json:"type"`
+	Types []string `json:"types,omitempty"`
+}
+
+const ControlSubscribe = "subscribe"
+
+const SessionHeader = "sst-stream-session"
+
+// A heartbeat is written to every stream at heartbeatInterval regardless of
+// the filter so clients can tell a quiet stream apart from a dead one.
+const heartbeatInterval = 5 * time.Second
+const defaultHeartbeatTimeout = 3 * heartbeatInterval
+
+var heartbeat = &Envelope{Version: EnvelopeVersion, Kind: KindHeartbeat}
+
+// shutdown is the last envelope written to a stream when the server is
+// stopping so clients do not try to reconnect.
+var shutdown = &Envelope{Version: EnvelopeVersion, Kind: KindShutdown}
+
+var completeEventType = reflect.TypeOf(project.CompleteEvent{}).String()
+
+func encode(event interface{}) (*Envelope, error) {
+	t := reflect.TypeOf(event)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	bytes, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		Version: EnvelopeVersion,
+		Kind:    KindEvent,
+		Type:    t.String(),
+		Event:   json.RawMessage(bytes),
+	}, nil
+}
+
+// filter restricts which event types are written to a client. An empty
+// filter matches everything.
+type filter map[string]bool
+
+func parseFilter(raw string) filter {
+	result := filter{}
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			result[name] = true
+		}
+	}
+	return result
+}
+
+func (f filter) match(name string) bool {
+	return len(f) == 0 || f[name]
+}
+
+func subscribe(hub *hub, r *http.Request) ([]*Envelope, *client, func()) {
+	query := r.URL.Query()
+	after, _ := strconv.ParseUint(query.Get("after"), 10, 64)
+	return hub.subscribe(query.Get("session"), after, r.RemoteAddr, query.Get("overflow"))
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		if strings.TrimSpace(strings.Split(encoding, ";")[0]) == "gzip" {
+			return true
+		}
+	}
+	return false
+}
+
+// handleStream serves events from hub. track is called for every connected
+// client and the function it returns once that client goes away.
+func handleStream(hub *hub, track func() func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer track()()
+		if websocket.IsWebSocketUpgrade(r) {
+			streamSocket(w, r, hub)
+			return
+		}
+		w.Header().Add("content-type", "application/x-ndjson")
+		w.Header().Set(SessionHeader, hub.session)
+		flusher, _ := w.(http.Flusher)
+		var out io.Writer = w
+		flush := flusher.Flush
+		// every message is flushed through the gzip writer so the newline
+		// framing survives compression
+		if acceptsGzip(r) {
+			w.Header().Set("Content-Encoding", "gzip")
+			gz := gzip.NewWriter(w)
+			defer gz.Close()
+			out = gz
+			flush = func() {
+				gz.Flush()
+				flusher.Flush()
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		slog.Info("subscribed", "addr", r.RemoteAddr)
+		flush()
+		ctx := r.Context()
+		types := parseFilter(r.URL.Query().Get("types"))
+		replay, client, unsubscribe := subscribe(hub, r)
+		defer unsubscribe()
+		write := func(msg *Envelope) {
+			if msg.Kind == KindEvent && !types.match(msg.Type) {
+				return
+			}
+			data, err := json.Marshal(msg)
+			if err != nil {
+				return
+			}
+			out.Write(append(data, '\n'))
+		}
+		for _, msg := range replay {
+			write(msg)
+		}
+		flush()
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				write(shutdown)
+				flush()
+				return
+			case <-client.kicked:
+				return
+			case <-ticker.C:
+				write(heartbeat)
+				flush()
+			case msg := <-client.queue:
+				write(msg)
+				flush()
+			}
+		}
+	}
+}
+
+func streamSocket(w http.ResponseWriter, r *http.Request, hub *hub) {
+	ws, err := upgrader.Upgrade(w, r, http.Header{
+		SessionHeader: []string{hub.session},
+	})
+	if err != nil {
+		slog.Error("stream upgrade failed", "err", err)
+		return
+	}
+	defer ws.Close()
+	slog.Info("subscribed", "addr", r.RemoteAddr, "transport", "websocket")
+
+	var mu sync.RWMutex
+	types := parseFilter(r.URL.Query().Get("types"))
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			var msg Control
+			if err := ws.ReadJSON(&msg); err != nil {
+				slog.Info("stream socket closed", "addr", r.RemoteAddr, "err", err)
+				return
+			}
+			switch msg.Type {
+			case ControlSubscribe:
+				next := parseFilter(strings.Join(msg.Types, ","))
+				mu.Lock()
+				types = next
+				mu.Unlock()
+				slog.Info("stream filter updated", "addr", r.RemoteAddr, "types", msg.Types)
+			default:
+				slog.Info("unknown stream control message", "type", msg.Type)
+			}
+		}
+	}()
+
+	write := func(msg *Envelope) error {
+		mu.RLock()
+		skip := msg.Kind == KindEvent && !types.match(msg.Type)
+		mu.RUnlock()
+		if skip {
+			return nil
+		}
+		return ws.WriteJSON(msg)
+	}
+	replay, client, unsubscribe := subscribe(hub, r)
+	defer unsubscribe()
+	for _, msg := range replay {
+		if err := write(msg); err != nil {
+			return
+		}
+	}
+	ticker := time.NewTicker(heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			write(shutdown)
+			return
+		case <-closed:
+			return
+		case <-client.kicked:
+			return
+		case <-ticker.C:
+			if err := write(heartbeat); err != nil {
+				return
+			}
+		case msg := <-client.queue:
+			if err := write(msg); err != nil {
+				return
+			}
+		}
+	}
+}
+
+type registry map[string]reflect.Type
+
+func newRegistry(types ...interface{}) registry {
+	result := registry{}
+	for _, v := range types {
+		t := reflect.TypeOf(v)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		result[t.String()] = t
+	}
+	return result
+}
+
+func (r registry) names() []string {
+	result := make([]string, 0, len(r))
+	for name := range r {
+		result = append(result, name)
+	}
+	return result
+}
+
+// decode returns nil for events that were not asked for.
+func (r registry) decode(msg *Envelope) (any, error) {
+	prototype, ok := r[msg.Type]
+	if !ok {
+		return nil, nil
+	}
+	target := reflect.New(prototype).Interface()
+	err := json.Unmarshal(msg.Event, target)
+	if err != nil {
+		return nil, &DecodeError{Type: msg.Type, Payload: msg.Event, Err: err}
+	}
+	return target, nil
+}
+
+func socketURL(url string) string {
+	return "ws" + strings.TrimPrefix(url, "http") + "/stream"
+}
+
+var ErrServerUnavailable = errors.New("server never came up")
+var ErrStreamDropped = errors.New("stream dropped")
+var ErrHeartbeatTimeout = errors.New("no heartbeat from server")
+var ErrServerShutdown = errors.New("server shut down")
+
+// DecodeError is passed to OnError when something read from the stream could
+// not be decoded. The stream carries on with the next envelope.
+type DecodeError struct {
+	// Type is the event type if the envelope itself could be read
+	Type    string
+	Payload []byte
+	Err     error
+}
+
+func (e *DecodeError) Error() string {
+	if e.Type == "" {
+		return fmt.Sprintf("could not decode envelope: %v", e.Err)
+	}
+	return fmt.Sprintf("could not decode %s: %v", e.Type, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// VersionMismatchError is returned when the server was started by a different
+// version of the CLI. Its events may not decode into this version's types.
+type VersionMismatchError struct {
+	Client string
+	// Server is empty for a server too old to report its version
+	Server string
+}
+
+func (e *VersionMismatchError) Error() string {
+	if e.Server == "" {
+		return fmt.Sprintf("server is running an older version than %s", e.Client)
+	}
+	return fmt.Sprintf("server is running version %s but this is version %s", e.Server, e.Client)
+}
+
+// ConnectError is returned when the stream could not be established or
+// re-established. Reason is either ErrServerUnavailable or ErrStreamDropped.
+type ConnectError struct {
+	Reason   error
+	Attempts int
+	Err      error
+}
+
+func (e *ConnectError) Error() string {
+	return fmt.Sprintf("%v after %d attempts: %v", e.Reason, e.Attempts, e.Err)
+}
+
+func (e *ConnectError) Unwrap() []error {
+	return []error{e.Reason, e.Err}
+}
+
+type ConnectInput struct {
+	URL   string
+	Token string
+	Types []interface{}
+	// Version is the version of this CLI. If set, Connect refuses to stream
+	// from a server started by a different version.
+	Version string
+	// Filter is the list of event type names the server should send. It
+	// defaults to the names of Types since anything else is discarded.
+	Filter []string
+	// Overflow tells the server what to do if this client falls behind, either
+	// OverflowDropOldest or OverflowDisconnect. Defaults to OverflowDropOldest.
+	Overflow string
+	// MaxAttempts bounds how many times a connection is attempted before
+	// giving up, both initially and after the stream drops. Defaults to 5.
+	MaxAttempts int
+	// HeartbeatTimeout is how long the stream can go without receiving
+	// anything, heartbeats included, before it is treated as dead and
+	// reconnected. Defaults to 15 seconds.
+	HeartbeatTimeout time.Duration
+	// OnError is called for anything on the stream that could not be
+	// decoded instead of silently dropping it.
+	OnError func(err *DecodeError)
+	// OnDisconnect is called once when the stream stops for good. The error
+	// is nil if the context was cancelled and ErrServerShutdown if the server
+	// was stopped.
+	OnDisconnect func(err error)
+}
+
+const defaultMaxAttempts = 5
+
+func backoff(attempt int) time.Duration {
+	delay := 100 * time.Millisecond << attempt
+	if delay > 5*time.Second || delay <= 0 {
+		delay = 5 * time.Second
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay) / 2))
+	return delay/2 + jitter
+}
+
+// source reads envelopes off a connection. A *DecodeError means only that
+// envelope was unreadable, any other error ends the connection.
+type source interface {
+	Next() (*Envelope, error)
+	Close() error
+}
+
+func unmarshal(data []byte) (*Envelope, error) {
+	var msg Envelope
+	err := json.Unmarshal(data, &msg)
+	if err != nil {
+		return nil, &DecodeError{Payload: data, Err: err}
+	}
+	if msg.Version > EnvelopeVersion {
+		return nil, &DecodeError{Type: msg.Type, Payload: data, Err: fmt.Errorf("unsupported envelope version %d", msg.Version)}
+	}
+	return &msg, nil
+}
+
+type socketSource struct {
+	ws *websocket.Conn
+}
+
+func (s *socketSource) Next() (*Envelope, error) {
+	_, data, err := s.ws.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	return unmarshal(data)
+}
+
+func (s *socketSource) Close() error {
+	return s.ws.Close()
+}
+
+type httpSource struct {
+	body   io.ReadCloser
+	reader *bufio.Reader
+}
+
+func (s *httpSource) Next() (*Envelope, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		return unmarshal(line)
+	}
+}
+
+func (s *httpSource) Close() error {
+	return s.body.Close()
+}
+
+// cursor tracks the last event received so a reconnecting client only gets
+// replayed what it missed and can drop anything it has already seen.
+type cursor struct {
+	session string
+	seq     uint64
+}
+
+func query(input *ConnectInput, cursor *cursor) string {
+	values := url.Values{}
+	if len(input.Filter) > 0 {
+		values.Set("types", strings.Join(input.Filter, ","))
+	}
+	if input.Overflow != "" {
+		values.Set("overflow", input.Overflow)
+	}
+	if cursor.session != "" {
+		values.Set("session", cursor.session)
+		values.Set("after", strconv.FormatUint(cursor.seq, 10))
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	return "?" + values.Encode()
+}
+
+func (c *cursor) reset(session string) {
+	if session != c.session {
+		c.session = session
+		c.seq = 0
+	}
+}
+
+// seen reports whether msg was already delivered and advances the cursor.
+func (c *cursor) seen(msg *Envelope) bool {
+	if msg.Seq == 0 {
+		return false
+	}
+	if msg.Seq <= c.seq {
+		return true
+	}
+	c.seq = msg.Seq
+	return false
+}
+
+func handshake(ctx context.Context, input *ConnectInput) error {
+	if input.Version == "" {
+		return nil
+	}
+	version, err := server.FetchVersion(ctx, input.URL, input.Token)
+	if errors.Is(err, server.ErrVersionUnknown) {
+		return &VersionMismatchError{Client: input.Version}
+	}
+	if err != nil {
+		return err
+	}
+	if version != input.Version {
+		return &VersionMismatchError{Client: input.Version, Server: version}
+	}
+	return nil
+}
+
+func open(ctx context.Context, input *ConnectInput, cursor *cursor) (source, error) {
+	if err := handshake(ctx, input); err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	if input.Token != "" {
+		header.Set("Authorization", "Bearer "+input.Token)
+	}
+	base := server.BaseURL(input.URL)
+	dialer := *websocket.DefaultDialer
+	dialer.NetDialContext = server.DialContext(input.URL)
+	dialer.Proxy = network.Proxy
+	dialer.TLSClientConfig = network.TLSConfig()
+	dialer.EnableCompression = true
+	// a server without websocket support answers with the ndjson stream, which
+	// the dialer only gives up reading when the handshake times out
+	dialer.HandshakeTimeout = heartbeatInterval
+	ws, resp, err := dialer.DialContext(ctx, socketURL(base)+query(input, cursor), header)
+	if err == nil {
+		cursor.reset(resp.Header.Get(SessionHeader))
+		go func() {
+			<-ctx.Done()
+			ws.Close()
+		}()
+		return &socketSource{ws: ws}, nil
+	}
+	slog.Info("websocket stream unavailable, falling back to http", "err", err)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", base+"/stream"+query(input, cursor), nil)
+	if err != nil {
+		return nil, err
+	}
+	server.Authorize(req, input.Token)
+	// setting this explicitly turns off the transport's own decompression so
+	// it is handled below
+	req.Header.Set("Accept-Encoding", "gzip")
+	resp, err = server.HttpClient(input.URL).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	cursor.reset(resp.Header.Get(SessionHeader))
+	var reader io.Reader = resp.Body
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		reader = gz
+	}
+	return &httpSource{
+		body:   resp.Body,
+		reader: bufio.NewReader(reader),
+	}, nil
+}
+
+func dial(ctx context.Context, input *ConnectInput, cursor *cursor, reason error) (source, error) {
+	var err error
+	for attempt := 0; attempt < input.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			delay := backoff(attempt - 1)
+			slog.Info("retrying stream connection", "attempt", attempt+1, "delay", delay, "err", err)
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+		var src source
+		src, err = open(ctx, input, cursor)
+		if err == nil {
+			return src, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		var mismatch *VersionMismatchError
+		if errors.As(err, &mismatch) {
+			return nil, err
+		}
+	}
+	return nil, &ConnectError{
+		Reason:   reason,
+		Attempts: input.MaxAttempts,
+		Err:      err,
+	}
+}
+
+// Connect subscribes to events from a running dev server. It prefers a
+// websocket connection so the requested types can be filtered server side and
+// falls back to the newline delimited http stream when that is not available.
+// If the stream drops it is re-established with backoff until MaxAttempts is
+// exhausted, at which point the returned channel is closed.
+func Connect(ctx context.Context, input *ConnectInput) (chan any, error) {
+	if input.MaxAttempts <= 0 {
+		input.MaxAttempts = defaultMaxAttempts
+	}
+	if input.HeartbeatTimeout <= 0 {
+		input.HeartbeatTimeout = defaultHeartbeatTimeout
+	}
+	registry := newRegistry(input.Types...)
+	if len(input.Filter) == 0 {
+		input.Filter = registry.names()
+	}
+	cursor := &cursor{}
+	src, err := dial(ctx, input, cursor, ErrServerUnavailable)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan any)
+	go func() {
+		defer close(out)
+		var result error
+		defer func() {
+			if input.OnDisconnect != nil {
+				input.OnDisconnect(result)
+			}
+		}()
+		for {
+			err := pump(ctx, src, input, registry, cursor, out)
+			src.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			if errors.Is(err, ErrServerShutdown) {
+				result = err
+				return
+			}
+			slog.Info("stream dropped, reconnecting", "err", err)
+			src, err = dial(ctx, input, cursor, ErrStreamDropped)
+			if err != nil {
+				if ctx.Err() == nil {
+					result = err
+				}
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// pump reads from src until it fails. A half open connection never fails on
+// its own so src is closed if nothing arrives within timeout.
+func pump(ctx context.Context, src source, input *ConnectInput, registry registry, cursor *cursor, out chan any) error {
+	timeout := input.HeartbeatTimeout
+	alive := make(chan struct{}, 1)
+	done := make(chan struct{})
+	defer close(done)
+	var dead atomic.Bool
+	go func() {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-alive:
+				timer.Reset(timeout)
+			case <-timer.C:
+				dead.Store(true)
+				src.Close()
+				return
+			}
+		}
+	}()
+	for {
+		msg, err := src.Next()
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			reportDecodeError(input, decodeErr)
+			continue
+		}
+		if err != nil {
+			if dead.Load() {
+				return ErrHeartbeatTimeout
+			}
+			return err
+		}
+		select {
+		case alive <- struct{}{}:
+		default:
+		}
+		switch msg.Kind {
+		case KindHeartbeat:
+			continue
+		case KindShutdown:
+			return ErrServerShutdown
+		}
+		if cursor.seen(msg) {
+			continue
+		}
+		target, err := registry.decode(msg)
+		if errors.As(err, &decodeErr) {
+			reportDecodeError(input, decodeErr)
+			continue
+		}
+		if target == nil {
+			continue
+		}
+		select {
+		case out <- target:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func reportDecodeError(input *ConnectInput, err *DecodeError) {
+	slog.Debug("stream decode failed", "err", err, "payload", string(err.Payload))
+	if input.OnError != nil {
+		input.OnError(err)
+	}
+}
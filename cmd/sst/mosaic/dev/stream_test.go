@@ -0,0 +1,38 @@
+package dev
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/sst/ion/pkg/server"
+)
+
+func TestHandshake(t *testing.T) {
+	version := ""
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if version == "" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(server.VersionInfo{Version: version})
+	}))
+	defer srv.Close()
+	input := &ConnectInput{URL: srv.URL, Version: "1.0.0"}
+
+	var mismatch *VersionMismatchError
+	if err := handshake(context.Background(), input); !errors.As(err, &mismatch) || mismatch.Server != "" {
+		t.Fatalf("Expected a mismatch for a server without /version, got %v", err)
+	}
+	version = "0.9.0"
+	if err := handshake(context.Background(), input); !errors.As(err, &mismatch) || mismatch.Server != "0.9.0" {
+		t.Fatalf("Expected a mismatch with 0.9.0, got %v", err)
+	}
+	version = "1.0.0"
+	if err := handshake(context.Background(), input); err != nil {
+		t.Fatal(err)
+	}
+}
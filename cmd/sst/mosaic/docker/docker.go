@@ -0,0 +1,208 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project"
+)
+
+// Container runs the image of a service in dev. It is created again when the
+// image or its environment changes, and restarted when files are synced into
+// it.
+type Container struct {
+	// Name is the service, ID the name of the container in docker
+	Name   string
+	ID     string
+	Root   string
+	Config *project.DevDocker
+	Stdout io.Writer
+	Stderr io.Writer
+
+	env  map[string]string
+	cmd  *exec.Cmd
+	done chan struct{}
+}
+
+var nameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// ContainerName is the name of the container of a service, it is the same
+// across sessions so leftovers of a previous one can be removed.
+func ContainerName(stage string, name string) string {
+	return "sst-dev-" + nameInvalid.ReplaceAllString(strings.ToLower(stage+"-"+name), "-")
+}
+
+func (c *Container) context() string {
+	if filepath.IsAbs(c.Config.Context) {
+		return c.Config.Context
+	}
+	return filepath.Join(c.Root, c.Config.Context)
+}
+
+func (c *Container) dockerfile() string {
+	dockerfile := c.Config.Dockerfile
+	if dockerfile == "" {
+		dockerfile = "Dockerfile"
+	}
+	if filepath.IsAbs(dockerfile) {
+		return dockerfile
+	}
+	return filepath.Join(c.context(), dockerfile)
+}
+
+func (c *Container) docker(ctx context.Context, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdout = c.Stdout
+	cmd.Stderr = c.Stderr
+	return cmd
+}
+
+// Build builds the image, images that are not built locally are pulled by
+// docker run.
+func (c *Container) Build(ctx context.Context) error {
+	if c.Config.Context == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("Docker is needed to run %s in dev", c.Name)
+	}
+	slog.Info("building container", "name", c.Name, "image", c.Config.Image)
+	return c.docker(ctx, buildArgs(c.Config, c.context(), c.dockerfile())...).Run()
+}
+
+func buildArgs(config *project.DevDocker, context string, dockerfile string) []string {
+	args := []string{"build", "--tag", config.Image, "--file", dockerfile}
+	keys := make([]string, 0, len(config.Args))
+	for key := range config.Args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		args = append(args, "--build-arg", key+"="+config.Args[key])
+	}
+	return append(args, context)
+}
+
+func runArgs(config *project.DevDocker, name string, env map[string]string) []string {
+	args := []string{"run", "--name", name, "--add-host", "host.docker.internal:host-gateway"}
+	for _, port := range config.Ports {
+		args = append(args, "--publish", port)
+	}
+	keys := make([]string, 0, len(env))
+	for key := range env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	args = append(args, util.DockerEnvArgs(keys)...)
+	return append(args, config.Image)
+}
+
+// Run creates the container with env and attaches to it. A container that is
+// already running is removed first.
+func (c *Container) Run(ctx context.Context, env map[string]string) error {
+	c.Remove()
+	c.env = env
+	cmd := c.docker(ctx, runArgs(c.Config, c.ID, env)...)
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	return c.attach(cmd)
+}
+
+func (c *Container) attach(cmd *exec.Cmd) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	c.cmd = cmd
+	c.done = make(chan struct{})
+	go func(done chan struct{}) {
+		cmd.Wait()
+		close(done)
+	}(c.done)
+	return nil
+}
+
+// Done is closed when the container exits, it is nil while there is none.
+func (c *Container) Done() <-chan struct{} {
+	if c.cmd == nil {
+		return nil
+	}
+	return c.done
+}
+
+// Restart copies files into the container and restarts it, files maps the
+// files on this machine to their path in the container.
+func (c *Container) Restart(ctx context.Context, files map[string]string) error {
+	for source, dest := range files {
+		if err := c.docker(ctx, "cp", source, c.ID+":"+dest).Run(); err != nil {
+			return err
+		}
+	}
+	c.Stop()
+	return c.attach(c.docker(ctx, "start", "--attach", c.ID))
+}
+
+// Stop stops the container and waits for it to exit.
+func (c *Container) Stop() {
+	if c.cmd == nil {
+		return
+	}
+	exec.Command("docker", "stop", "--time", "5", c.ID).Run()
+	<-c.done
+	c.cmd = nil
+}
+
+// Remove stops and removes the container.
+func (c *Container) Remove() {
+	c.Stop()
+	exec.Command("docker", "rm", "--force", c.ID).Run()
+}
+
+// Plan decides what to do with the changed files. The image is rebuilt when
+// the Dockerfile changes or nothing is synced, otherwise the files in the
+// context are mapped to their path in the container.
+func (c *Container) Plan(changed []string) (map[string]string, bool) {
+	files := map[string]string{}
+	if c.Config.Context == "" {
+		return files, false
+	}
+	for _, file := range changed {
+		rel, err := filepath.Rel(c.context(), file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if c.Config.Sync == "" || file == c.dockerfile() || filepath.Base(file) == ".dockerignore" {
+			return nil, true
+		}
+		files[file] = path.Join(c.Config.Sync, filepath.ToSlash(rel))
+	}
+	return files, false
+}
+
+// EnvChanged is true when the container has to be created again to get env.
+func (c *Container) EnvChanged(env map[string]string) bool {
+	if len(env) != len(c.env) {
+		return true
+	}
+	for key, value := range env {
+		// credentials alone do not restart it, they are renewed on a timer
+		if strings.HasPrefix(key, "AWS_") {
+			continue
+		}
+		if c.env[key] != value {
+			return true
+		}
+	}
+	return false
+}
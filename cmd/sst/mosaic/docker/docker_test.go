@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/sst/ion/pkg/project"
+)
+
+func TestPlan(t *testing.T) {
+	c := &Container{
+		Root:   "/app",
+		Config: &project.DevDocker{Context: "service", Sync: "/srv"},
+	}
+	files, rebuild := c.Plan([]string{"/app/service/src/index.js", "/app/web/page.tsx"})
+	if rebuild {
+		t.Fatalf("Expected no rebuild")
+	}
+	if len(files) != 1 || files["/app/service/src/index.js"] != "/srv/src/index.js" {
+		t.Errorf("Unexpected files %v", files)
+	}
+	if _, rebuild := c.Plan([]string{"/app/service/Dockerfile"}); !rebuild {
+		t.Errorf("Expected a rebuild when the Dockerfile changes")
+	}
+
+	c.Config.Sync = ""
+	if _, rebuild := c.Plan([]string{"/app/service/src/index.js"}); !rebuild {
+		t.Errorf("Expected a rebuild without sync")
+	}
+	if _, rebuild := c.Plan([]string{"/app/servicex/index.js"}); rebuild {
+		t.Errorf("Expected files outside of the context to be ignored")
+	}
+
+	c.Config.Context = ""
+	if _, rebuild := c.Plan([]string{"/app/service/src/index.js"}); rebuild {
+		t.Errorf("Expected images that are not built to be left alone")
+	}
+}
+
+func TestArgs(t *testing.T) {
+	config := &project.DevDocker{
+		Image: "sst-dev-web",
+		Args:  map[string]string{"B": "2", "A": "1"},
+		Ports: []string{"3000:3000"},
+	}
+	build := buildArgs(config, "/app", "/app/Dockerfile")
+	expected := []string{"build", "--tag", "sst-dev-web", "--file", "/app/Dockerfile", "--build-arg", "A=1", "--build-arg", "B=2", "/app"}
+	if !slices.Equal(build, expected) {
+		t.Errorf("Expected %v, got %v", expected, build)
+	}
+	run := runArgs(config, "sst-dev-dev-web", map[string]string{"SST_RESOURCE_App": "{}"})
+	expected = []string{"run", "--name", "sst-dev-dev-web", "--add-host", "host.docker.internal:host-gateway", "--publish", "3000:3000", "--env", "SST_RESOURCE_App", "sst-dev-web"}
+	if !slices.Equal(run, expected) {
+		t.Errorf("Expected %v, got %v", expected, run)
+	}
+	if name := ContainerName("Dev", "My Web"); name != "sst-dev-dev-my-web" {
+		t.Errorf("Unexpected name %q", name)
+	}
+}
@@ -29,6 +29,7 @@ func Transform(err error) error {
 	readable := []error{
 		project.ErrBuildFailed,
 		project.ErrVersionMismatch,
+		project.ErrTargetNotFound,
 	}
 
 	for compare, msg := range mapping {
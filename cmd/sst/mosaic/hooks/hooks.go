@@ -0,0 +1,133 @@
+package hooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sst/ion/cmd/sst/mosaic/aws"
+	"github.com/sst/ion/cmd/sst/mosaic/deployer"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/project"
+)
+
+// names returns what a hook can be configured with for event. Along with
+// the topic, like "aws.FunctionBuildEvent", the common events have friendlier
+// names.
+func names(event interface{}) []string {
+	result := []string{bus.Topic(event)}
+	switch evt := event.(type) {
+	case *project.CompleteEvent:
+		if !evt.Finished {
+			break
+		}
+		if len(evt.Errors) > 0 {
+			result = append(result, "stack.update.failed")
+		} else {
+			result = append(result, "stack.update.complete")
+		}
+	case *deployer.DeployFailedEvent:
+		result = append(result, "stack.update.failed")
+	case *aws.FunctionBuildEvent:
+		if len(evt.Errors) > 0 {
+			result = append(result, "function.build.failed")
+		} else {
+			result = append(result, "function.build.complete")
+		}
+	case *aws.FunctionInvokedEvent:
+		result = append(result, "function.invoked")
+	case *aws.FunctionErrorEvent:
+		result = append(result, "function.error")
+	}
+	return result
+}
+
+// match returns the configured hooks that apply to event. Patterns can use
+// wildcards like "function.*".
+func match(hooks map[string]string, event interface{}) []string {
+	result := []string{}
+	for pattern, command := range hooks {
+		for _, name := range names(event) {
+			if ok, _ := path.Match(pattern, name); ok {
+				result = append(result, command)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// env has the linked resources from the last deploy so hooks can use the
+// same SST_RESOURCE_ variables as the rest of the app.
+func env(p *project.Project, complete *project.CompleteEvent) []string {
+	result := os.Environ()
+	if complete != nil {
+		for name, link := range complete.Links {
+			value, _ := json.Marshal(link.Properties)
+			result = append(result, "SST_RESOURCE_"+name+"="+string(value))
+		}
+	}
+	result = append(result, fmt.Sprintf(`SST_RESOURCE_App={"name": "%s", "stage": "%s" }`, p.App().Name, p.App().Stage))
+	return result
+}
+
+func shell(command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.Command("cmd", "/C", command)
+	}
+	return exec.Command("sh", "-c", command)
+}
+
+func run(p *project.Project, command string, event interface{}, env []string) {
+	input, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("hook could not encode event", "command", command, "err", err)
+		return
+	}
+	cmd := shell(command)
+	cmd.Dir = p.PathRoot()
+	cmd.Env = env
+	cmd.Stdin = strings.NewReader(string(input))
+	output, err := cmd.CombinedOutput()
+	slog.Info("hook ran", "command", command, "output", string(output), "err", err)
+}
+
+// Start runs the commands in the hooks section of the app config when the
+// events they are mapped to are published. Each gets the event as JSON on
+// stdin. Hooks that are still running when ctx is done are waited on rather
+// than killed since the last events of a deploy are usually the interesting
+// ones.
+func Start(ctx context.Context, p *project.Project) error {
+	hooks := p.App().Hooks
+	if len(hooks) == 0 {
+		return nil
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	var complete *project.CompleteEvent
+	for event := range bus.Subscribe[any](ctx) {
+		if evt, ok := event.(*project.CompleteEvent); ok {
+			complete = evt
+		}
+		commands := match(hooks, event)
+		if len(commands) == 0 {
+			continue
+		}
+		env := env(p, complete)
+		for _, command := range commands {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				run(p, command, event, env)
+			}()
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package multiplexer
 
 import (
+	"fmt"
 	"slices"
 	"sort"
 	"strings"
@@ -35,41 +36,60 @@ func (s *Multiplexer) draw() {
 		}
 		title := views.NewTextBar()
 		title.SetStyle(style)
-		title.SetLeft(" "+item.icon+" "+item.title, tcell.StyleDefault)
+		text := " " + item.icon + " " + item.title
+		if item.restarts > 0 {
+			text += fmt.Sprintf(" ↻%d", item.restarts)
+		}
+		title.SetLeft(text, tcell.StyleDefault)
 		s.stack.AddWidget(title, 0)
 	}
 	s.stack.AddWidget(views.NewSpacer(), 1)
 
+	keys := s.options.Keys
 	hotkeys := map[string]string{}
 	if selected != nil && selected.killable && !s.focused {
 		if !selected.dead {
-			hotkeys["x"] = "kill"
-			hotkeys["enter"] = "focus"
+			hotkeys[keys.label(ActionKill)] = "kill"
+			hotkeys[keys.label(ActionSelect)] = "focus"
 		}
 
 		if selected.dead {
-			hotkeys["enter"] = "start"
+			hotkeys[keys.label(ActionSelect)] = "start"
 		}
 	}
 	if !s.focused {
-		hotkeys["j/k/↓/↑"] = "up/down"
+		hotkeys[keys.label(ActionDown, ActionUp)] = "up/down"
 	}
 	if s.focused {
-		hotkeys["ctrl-z"] = "sidebar"
+		hotkeys[keys.label(ActionSidebar)] = "sidebar"
 	}
 	if selected != nil && selected.isScrolling() && (s.focused || !selected.killable) {
-		hotkeys["enter"] = "reset"
+		hotkeys[keys.label(ActionSelect)] = "reset"
 	}
 	if selected != nil && selected.vt.HasSelection() {
-		hotkeys["enter"] = "copy"
+		hotkeys[keys.label(ActionSelect)] = "copy"
+	}
+	hotkeys[keys.label(ActionScrollUp, ActionScrollDown)] = "scroll"
+	if selected != nil && !s.focused {
+		hotkeys[keys.label(ActionPause)] = "pause"
+		hotkeys[keys.label(ActionSearch)] = "search"
+	}
+	if s.view != nil {
+		hotkeys = map[string]string{
+			keys.label(ActionSearch):                     "search",
+			keys.label(ActionNext, ActionPrevious):       "next/prev",
+			keys.label(ActionLevel):                      "level",
+			keys.label(ActionFunction):                   "function",
+			keys.label(ActionScrollUp, ActionScrollDown): "scroll",
+			keys.label(ActionResume):                     "resume",
+		}
 	}
-	hotkeys["ctrl-u/d"] = "scroll"
 	// sort hotkeys
-	keys := make([]string, 0, len(hotkeys))
+	sorted := make([]string, 0, len(hotkeys))
 	for key := range hotkeys {
-		keys = append(keys, key)
+		sorted = append(sorted, key)
 	}
-	slices.SortFunc(keys, func(i, j string) int {
+	slices.SortFunc(sorted, func(i, j string) int {
 		ilength := utf8.RuneCountInString(i)
 		jlength := utf8.RuneCountInString(j)
 		if ilength != jlength {
@@ -77,7 +97,7 @@ func (s *Multiplexer) draw() {
 		}
 		return strings.Compare(i, j)
 	})
-	for _, key := range keys {
+	for _, key := range sorted {
 		label := hotkeys[key]
 		title := views.NewTextBar()
 		title.SetStyle(tcell.StyleDefault.Foreground(tcell.ColorGray))
@@ -88,7 +108,13 @@ func (s *Multiplexer) draw() {
 	s.stack.Draw()
 	borderStyle := tcell.StyleDefault.Foreground(tcell.ColorGray)
 	for i := 0; i < s.height; i++ {
-		s.screen.SetContent(SIDEBAR_WIDTH-1, i, '│', nil, borderStyle)
+		s.screen.SetContent(s.sidebar-1, i, '│', nil, borderStyle)
+	}
+
+	if s.view != nil {
+		s.view.draw(s.main, keys)
+		s.screen.HideCursor()
+		return
 	}
 
 	// render virtual terminal
@@ -96,7 +122,7 @@ func (s *Multiplexer) draw() {
 		selected.vt.Draw()
 		if s.focused {
 			y, x, _, _ := selected.vt.Cursor()
-			s.screen.ShowCursor(SIDEBAR_WIDTH+1+x, y+PAD_HEIGHT)
+			s.screen.ShowCursor(s.sidebar+1+x, y+PAD_HEIGHT)
 		}
 		if !s.focused {
 			s.screen.HideCursor()
@@ -137,16 +163,7 @@ func (s *Multiplexer) sort() {
 	}
 	key := s.selectedProcess().key
 	sort.Slice(s.processes, func(i, j int) bool {
-		if s.processes[i].killable && !s.processes[j].killable {
-			return false
-		}
-		if !s.processes[i].dead && s.processes[j].dead {
-			return true
-		}
-		if s.processes[i].dead && !s.processes[j].dead {
-			return false
-		}
-		return len(s.processes[i].title) < len(s.processes[j].title)
+		return s.less(s.processes[i], s.processes[j])
 	})
 	for i, p := range s.processes {
 		if p.key == key {
@@ -156,6 +173,28 @@ func (s *Multiplexer) sort() {
 	}
 }
 
+// less orders the processes in the sidebar. Running processes come before
+// the stopped ones, then the ones in the configured order.
+func (s *Multiplexer) less(a *process, b *process) bool {
+	if a.dead != b.dead {
+		return !a.dead
+	}
+	rank := func(p *process) int {
+		index := slices.Index(s.options.Order, p.key)
+		if index == -1 {
+			return len(s.options.Order)
+		}
+		return index
+	}
+	if rank(a) != rank(b) {
+		return rank(a) < rank(b)
+	}
+	if a.killable != b.killable {
+		return !a.killable
+	}
+	return len(a.title) < len(b.title)
+}
+
 func (s *Multiplexer) selectedProcess() *process {
 	if s.selected >= len(s.processes) {
 		return nil
@@ -0,0 +1,178 @@
+package multiplexer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// The actions that keys can be bound to.
+const (
+	ActionUp         = "up"
+	ActionDown       = "down"
+	ActionSelect     = "select"
+	ActionKill       = "kill"
+	ActionSidebar    = "sidebar"
+	ActionScrollUp   = "scrollUp"
+	ActionScrollDown = "scrollDown"
+	ActionQuit       = "quit"
+	ActionPause      = "pause"
+	ActionSearch     = "search"
+	ActionNext       = "next"
+	ActionPrevious   = "previous"
+	ActionLevel      = "level"
+	ActionFunction   = "function"
+	ActionResume     = "resume"
+)
+
+var defaultKeys = map[string][]string{
+	ActionUp:         {"k", "up"},
+	ActionDown:       {"j", "down"},
+	ActionSelect:     {"enter"},
+	ActionKill:       {"x"},
+	ActionSidebar:    {"ctrl-z"},
+	ActionScrollUp:   {"ctrl-u"},
+	ActionScrollDown: {"ctrl-d"},
+	ActionQuit:       {"ctrl-c"},
+	ActionPause:      {"p"},
+	ActionSearch:     {"/"},
+	ActionNext:       {"n"},
+	ActionPrevious:   {"N"},
+	ActionLevel:      {"l"},
+	ActionFunction:   {"f"},
+	ActionResume:     {"esc"},
+}
+
+var namedKeys = map[string]tcell.Key{
+	"enter":     tcell.KeyEnter,
+	"up":        tcell.KeyUp,
+	"down":      tcell.KeyDown,
+	"left":      tcell.KeyLeft,
+	"right":     tcell.KeyRight,
+	"tab":       tcell.KeyTab,
+	"esc":       tcell.KeyEscape,
+	"backspace": tcell.KeyBackspace2,
+	"home":      tcell.KeyHome,
+	"end":       tcell.KeyEnd,
+	"pgup":      tcell.KeyPgUp,
+	"pgdn":      tcell.KeyPgDn,
+}
+
+var keyLabels = map[string]string{
+	"up":   "↑",
+	"down": "↓",
+}
+
+type binding struct {
+	key tcell.Key
+	r   rune
+}
+
+// Keymap maps the keys of the multiplexer to actions.
+type Keymap struct {
+	bindings map[binding]string
+	keys     map[string][]string
+}
+
+func parseKey(input string) (binding, error) {
+	input = strings.TrimSpace(input)
+	// single keys are case sensitive so N can be bound apart from n
+	runes := []rune(input)
+	if len(runes) == 1 {
+		return binding{key: tcell.KeyRune, r: runes[0]}, nil
+	}
+	input = strings.ToLower(input)
+	if key, ok := namedKeys[input]; ok {
+		return binding{key: key}, nil
+	}
+	if input == "space" {
+		return binding{key: tcell.KeyRune, r: ' '}, nil
+	}
+	if letter, ok := strings.CutPrefix(input, "ctrl-"); ok && len(letter) == 1 && letter[0] >= 'a' && letter[0] <= 'z' {
+		return binding{key: tcell.KeyCtrlA + tcell.Key(letter[0]-'a')}, nil
+	}
+	return binding{}, fmt.Errorf("unknown key %q", input)
+}
+
+// ParseKeys returns the default keymap with the keys of the actions in
+// overrides replaced.
+func ParseKeys(overrides map[string][]string) (*Keymap, error) {
+	result := &Keymap{
+		bindings: map[binding]string{},
+		keys:     map[string][]string{},
+	}
+	for action, keys := range defaultKeys {
+		result.keys[action] = keys
+	}
+	for action, keys := range overrides {
+		if _, ok := defaultKeys[action]; !ok {
+			return nil, fmt.Errorf("unknown action %q", action)
+		}
+		result.keys[action] = keys
+	}
+	actions := make([]string, 0, len(result.keys))
+	for action := range result.keys {
+		actions = append(actions, action)
+	}
+	sort.Strings(actions)
+	for _, action := range actions {
+		for _, key := range result.keys[action] {
+			parsed, err := parseKey(key)
+			if err != nil {
+				return nil, err
+			}
+			if existing, ok := result.bindings[parsed]; ok {
+				return nil, fmt.Errorf("%q is bound to both %s and %s", key, existing, action)
+			}
+			result.bindings[parsed] = action
+		}
+	}
+	return result, nil
+}
+
+func (k *Keymap) action(evt *tcell.EventKey) string {
+	match := binding{key: evt.Key()}
+	if evt.Key() == tcell.KeyRune {
+		match.r = evt.Rune()
+	}
+	return k.bindings[match]
+}
+
+// label is how the keys of the actions are shown in the sidebar, like
+// j/k/↓/↑ for down and up.
+func (k *Keymap) label(actions ...string) string {
+	keys := []string{}
+	for index := 0; ; index++ {
+		found := false
+		for _, action := range actions {
+			if index < len(k.keys[action]) {
+				key := k.keys[action][index]
+				if len([]rune(key)) > 1 {
+					key = strings.ToLower(key)
+				}
+				if label, ok := keyLabels[key]; ok {
+					key = label
+				}
+				keys = append(keys, key)
+				found = true
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	if len(keys) > 1 {
+		shared := true
+		for _, key := range keys {
+			shared = shared && strings.HasPrefix(key, "ctrl-")
+		}
+		if shared {
+			for index := range keys[1:] {
+				keys[index+1] = strings.TrimPrefix(keys[index+1], "ctrl-")
+			}
+		}
+	}
+	return strings.Join(keys, "/")
+}
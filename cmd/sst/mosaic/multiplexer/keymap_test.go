@@ -0,0 +1,73 @@
+package multiplexer
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+func TestParseKeys(t *testing.T) {
+	keys, err := ParseKeys(map[string][]string{
+		ActionKill:     {"d"},
+		ActionScrollUp: {"ctrl-b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		evt      *tcell.EventKey
+		expected string
+	}{
+		{tcell.NewEventKey(tcell.KeyRune, 'd', tcell.ModNone), ActionKill},
+		{tcell.NewEventKey(tcell.KeyRune, 'x', tcell.ModNone), ""},
+		{tcell.NewEventKey(tcell.KeyRune, 'j', tcell.ModNone), ActionDown},
+		{tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone), ActionUp},
+		{tcell.NewEventKey(tcell.KeyCtrlB, 0, tcell.ModCtrl), ActionScrollUp},
+		{tcell.NewEventKey(tcell.KeyCtrlU, 0, tcell.ModCtrl), ""},
+		{tcell.NewEventKey(tcell.KeyEnter, 0, tcell.ModNone), ActionSelect},
+		{tcell.NewEventKey(tcell.KeyRune, 'n', tcell.ModNone), ActionNext},
+		{tcell.NewEventKey(tcell.KeyRune, 'N', tcell.ModNone), ActionPrevious},
+	}
+	for _, test := range tests {
+		if action := keys.action(test.evt); action != test.expected {
+			t.Errorf("%v: expected %q, got %q", test.evt.Name(), test.expected, action)
+		}
+	}
+	if label := keys.label(ActionDown, ActionUp); label != "j/k/↓/↑" {
+		t.Errorf("Unexpected label %q", label)
+	}
+	if label := keys.label(ActionScrollUp, ActionScrollDown); label != "ctrl-b/d" {
+		t.Errorf("Unexpected label %q", label)
+	}
+
+	if _, err := ParseKeys(map[string][]string{"jump": {"g"}}); err == nil {
+		t.Errorf("Expected an error for an unknown action")
+	}
+	if _, err := ParseKeys(map[string][]string{ActionKill: {"j"}}); err == nil {
+		t.Errorf("Expected an error for a key bound twice")
+	}
+	if _, err := ParseKeys(map[string][]string{ActionKill: {"ctrl-shift"}}); err == nil {
+		t.Errorf("Expected an error for an unknown key")
+	}
+}
+
+func TestProcessOrder(t *testing.T) {
+	s := &Multiplexer{options: &Options{Order: []string{"function", "web"}}}
+	processes := []*process{
+		{key: "deploy", title: "SST"},
+		{key: "api", title: "Api", killable: true},
+		{key: "stopped", title: "Stopped", killable: true, dead: true},
+		{key: "web", title: "Web", killable: true},
+		{key: "function", title: "Functions"},
+	}
+	sort.Slice(processes, func(i, j int) bool {
+		return s.less(processes[i], processes[j])
+	})
+	expected := []string{"function", "web", "deploy", "api", "stopped"}
+	for index, key := range expected {
+		if processes[index].key != key {
+			t.Errorf("Expected %v at %v, got %v", key, index, processes[index].key)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package multiplexer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/gdamore/tcell/v2/views"
+	tcellterm "github.com/sst/ion/cmd/sst/mosaic/multiplexer/tcell-term"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+)
+
+// logView is a paused copy of the output of a process that can be searched
+// and filtered while the process keeps running.
+type logView struct {
+	rows []tcellterm.Row
+	tags []ui.Tag
+	// query is highlighted and typing is set while it is being entered
+	query  string
+	typing bool
+	// function only shows the rows of one function
+	function string
+	// levels is the lowest level shown for all functions under "" and for
+	// each function, as an index into ui.Levels
+	levels map[string]int
+	// offset is the first row that is shown, -1 sticks to the bottom
+	offset int
+	// match is the row of the current match
+	match int
+}
+
+func newLogView(rows []tcellterm.Row) *logView {
+	result := &logView{
+		rows:   rows,
+		tags:   make([]ui.Tag, len(rows)),
+		levels: map[string]int{},
+		offset: -1,
+		match:  -1,
+	}
+	for index, row := range rows {
+		result.tags[index] = ui.ParseTag(row.Tag)
+	}
+	return result
+}
+
+func levelIndex(level string) int {
+	return slices.Index(ui.Levels, level)
+}
+
+func (v *logView) minLevel(functionID string) int {
+	return max(v.levels[""], v.levels[functionID])
+}
+
+// visible returns the rows that pass the filters.
+func (v *logView) visible() []int {
+	result := []int{}
+	for index, tag := range v.tags {
+		if v.function != "" && tag.FunctionID != v.function {
+			continue
+		}
+		if level := levelIndex(tag.Level); level != -1 && level < v.minLevel(tag.FunctionID) {
+			continue
+		}
+		result = append(result, index)
+	}
+	return result
+}
+
+// functions are the functions that have rows, in the order they first
+// appear.
+func (v *logView) functions() []string {
+	result := []string{}
+	for _, tag := range v.tags {
+		if tag.FunctionID != "" && !slices.Contains(result, tag.FunctionID) {
+			result = append(result, tag.FunctionID)
+		}
+	}
+	return result
+}
+
+// nextFunction cycles the function filter through every function and back to
+// all of them.
+func (v *logView) nextFunction() {
+	functions := v.functions()
+	index := slices.Index(functions, v.function)
+	if index+1 >= len(functions) {
+		v.function = ""
+	} else {
+		v.function = functions[index+1]
+	}
+	v.offset = -1
+	v.match = -1
+}
+
+// nextLevel raises the lowest level shown for the current function filter,
+// back to showing everything after errors.
+func (v *logView) nextLevel() {
+	v.levels[v.function] = (v.levels[v.function] + 1) % len(ui.Levels)
+	v.offset = -1
+	v.match = -1
+}
+
+// matches returns the columns where the query starts in the text of a row.
+func matches(text string, query string) []int {
+	result := []int{}
+	if query == "" {
+		return result
+	}
+	runes := []rune(strings.ToLower(text))
+	needle := []rune(strings.ToLower(query))
+	for start := 0; start+len(needle) <= len(runes); start++ {
+		if slices.Equal(runes[start:start+len(needle)], needle) {
+			result = append(result, start)
+		}
+	}
+	return result
+}
+
+// matching returns the positions in visible of the rows that match the
+// query.
+func (v *logView) matching(visible []int) []int {
+	result := []int{}
+	for position, index := range visible {
+		if len(matches(v.rows[index].Text(), v.query)) > 0 {
+			result = append(result, position)
+		}
+	}
+	return result
+}
+
+// jump moves to the next match in direction, 1 is down and -1 is up, and
+// scrolls it into view.
+func (v *logView) jump(direction int, height int) bool {
+	visible := v.visible()
+	found := v.matching(visible)
+	if len(found) == 0 {
+		return false
+	}
+	current := slices.Index(visible, v.match)
+	next := -1
+	if current == -1 {
+		// start from the bottom like less does with ?
+		next = found[len(found)-1]
+	} else if direction > 0 {
+		for _, position := range found {
+			if position > current {
+				next = position
+				break
+			}
+		}
+		if next == -1 {
+			next = found[0]
+		}
+	} else {
+		for index := len(found) - 1; index >= 0; index-- {
+			if found[index] < current {
+				next = found[index]
+				break
+			}
+		}
+		if next == -1 {
+			next = found[len(found)-1]
+		}
+	}
+	v.match = visible[next]
+	top := v.top(len(visible), height)
+	if next < top || next >= top+height {
+		v.offset = max(0, next-height/2)
+	}
+	return true
+}
+
+// top is the position of the first row that is shown.
+func (v *logView) top(visible int, height int) int {
+	bottom := max(0, visible-height)
+	if v.offset == -1 || v.offset > bottom {
+		return bottom
+	}
+	return v.offset
+}
+
+func (v *logView) scroll(offset int, height int) {
+	visible := len(v.visible())
+	top := v.top(visible, height) + offset
+	if top >= max(0, visible-height) {
+		v.offset = -1
+		return
+	}
+	v.offset = max(0, top)
+}
+
+// status is the line at the bottom of the view.
+func (v *logView) status(visible int, keys *Keymap) string {
+	if v.typing {
+		return "/" + v.query
+	}
+	parts := []string{"PAUSED"}
+	if v.query != "" {
+		count := len(v.matching(v.visible()))
+		parts = append(parts, fmt.Sprintf("/%s %d matching", v.query, count))
+	}
+	if v.function != "" {
+		parts = append(parts, "function "+v.function)
+	}
+	if level := v.minLevel(v.function); level > 0 {
+		parts = append(parts, ui.Levels[level]+" and up")
+	}
+	parts = append(parts, fmt.Sprintf("%d/%d lines", visible, len(v.rows)))
+	parts = append(parts, keys.label(ActionResume)+" to resume")
+	return strings.Join(parts, "  ")
+}
+
+func (v *logView) draw(surface views.View, keys *Keymap) {
+	width, height := surface.Size()
+	if height < 2 {
+		return
+	}
+	height--
+	visible := v.visible()
+	top := v.top(len(visible), height)
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			surface.SetContent(x, y, ' ', nil, tcell.StyleDefault)
+		}
+		if top+y >= len(visible) {
+			continue
+		}
+		index := visible[top+y]
+		row := v.rows[index]
+		highlighted := map[int]bool{}
+		for _, start := range matches(row.Text(), v.query) {
+			for column := start; column < start+len([]rune(v.query)); column++ {
+				highlighted[column] = true
+			}
+		}
+		for x, cell := range row.Cells {
+			if x >= width {
+				break
+			}
+			style := cell.Style
+			if highlighted[x] {
+				style = tcell.StyleDefault.Background(tcell.ColorYellow).Foreground(tcell.ColorBlack)
+				if index == v.match {
+					style = style.Background(tcell.ColorOrange)
+				}
+			}
+			surface.SetContent(x, y, cell.Rune, cell.Combining, style)
+		}
+	}
+	status := []rune(v.status(len(visible), keys))
+	style := tcell.StyleDefault.Reverse(true)
+	for x := 0; x < width; x++ {
+		content := ' '
+		if x < len(status) {
+			content = status[x]
+		}
+		surface.SetContent(x, height, content, nil, style)
+	}
+}
+
+// pause freezes the output of the selected process in a log view.
+func (s *Multiplexer) pause() {
+	selected := s.selectedProcess()
+	if selected == nil {
+		return
+	}
+	s.view = newLogView(selected.vt.Rows())
+	s.screen.HideCursor()
+	s.draw()
+}
+
+func (s *Multiplexer) resume() {
+	s.view = nil
+	s.draw()
+	s.screen.Sync()
+}
+
+func (s *Multiplexer) viewHeight() int {
+	_, height := s.main.Size()
+	return height - 1
+}
+
+// viewKey handles the keys while paused. The query takes every key while it
+// is typed.
+func (s *Multiplexer) viewKey(evt *tcell.EventKey) {
+	v := s.view
+	height := s.viewHeight()
+	defer s.draw()
+	if v.typing {
+		switch evt.Key() {
+		case tcell.KeyEnter:
+			v.typing = false
+			v.match = -1
+			v.jump(-1, height)
+		case tcell.KeyEscape:
+			v.typing = false
+			v.query = ""
+		case tcell.KeyBackspace, tcell.KeyBackspace2:
+			if runes := []rune(v.query); len(runes) > 0 {
+				v.query = string(runes[:len(runes)-1])
+			}
+		case tcell.KeyRune:
+			v.query += string(evt.Rune())
+		}
+		return
+	}
+	switch s.options.Keys.action(evt) {
+	case ActionResume, ActionPause:
+		s.resume()
+	case ActionSearch:
+		v.typing = true
+		v.query = ""
+	case ActionNext:
+		v.jump(1, height)
+	case ActionPrevious:
+		v.jump(-1, height)
+	case ActionLevel:
+		v.nextLevel()
+	case ActionFunction:
+		v.nextFunction()
+	case ActionUp:
+		v.scroll(-1, height)
+	case ActionDown:
+		v.scroll(1, height)
+	case ActionScrollUp:
+		v.scroll(-(height/2 + 1), height)
+	case ActionScrollDown:
+		v.scroll(height/2+1, height)
+	}
+}
@@ -0,0 +1,104 @@
+package multiplexer
+
+import (
+	"slices"
+	"testing"
+
+	tcellterm "github.com/sst/ion/cmd/sst/mosaic/multiplexer/tcell-term"
+)
+
+func row(text string, tag string) tcellterm.Row {
+	result := tcellterm.Row{Tag: tag}
+	for _, char := range text {
+		result.Cells = append(result.Cells, tcellterm.Cell{Rune: char})
+	}
+	return result
+}
+
+func TestLogViewFilters(t *testing.T) {
+	v := newLogView([]tcellterm.Row{
+		row("deploying", ""),
+		row("debug a", "function=a&level=debug"),
+		row("info a", "function=a&level=info"),
+		row("invoked b", "function=b"),
+		row("debug b", "function=b&level=debug"),
+		row("error b", "function=b&level=error"),
+	})
+	texts := func() []string {
+		result := []string{}
+		for _, index := range v.visible() {
+			result = append(result, v.rows[index].Text())
+		}
+		return result
+	}
+	if len(v.visible()) != 6 {
+		t.Fatalf("Expected every row, got %v", texts())
+	}
+
+	v.nextLevel()
+	expected := []string{"deploying", "info a", "invoked b", "error b"}
+	if !slices.Equal(texts(), expected) {
+		t.Errorf("Expected %v, got %v", expected, texts())
+	}
+
+	v.nextFunction()
+	v.nextFunction()
+	if v.function != "b" {
+		t.Fatalf("Expected function b, got %q", v.function)
+	}
+	expected = []string{"invoked b", "error b"}
+	if !slices.Equal(texts(), expected) {
+		t.Errorf("Expected %v, got %v", expected, texts())
+	}
+	v.nextLevel()
+	v.nextLevel()
+	expected = []string{"invoked b", "error b"}
+	if !slices.Equal(texts(), expected) {
+		t.Errorf("Expected %v, got %v", expected, texts())
+	}
+	if v.minLevel("a") != 1 {
+		t.Errorf("Expected the level of a to stay at info")
+	}
+
+	v.nextFunction()
+	if v.function != "" {
+		t.Errorf("Expected every function after the last one, got %q", v.function)
+	}
+}
+
+func TestLogViewSearch(t *testing.T) {
+	if found := matches("Error: ERROR", "error"); !slices.Equal(found, []int{0, 7}) {
+		t.Errorf("Unexpected matches %v", found)
+	}
+	rows := []tcellterm.Row{}
+	for index := 0; index < 20; index++ {
+		text := "line"
+		if index%5 == 0 {
+			text = "match"
+		}
+		rows = append(rows, row(text, ""))
+	}
+	v := newLogView(rows)
+	v.query = "MATCH"
+	v.match = -1
+	if !v.jump(-1, 4) || v.match != 15 {
+		t.Fatalf("Expected the last match, got %v", v.match)
+	}
+	if v.jump(-1, 4); v.match != 10 {
+		t.Errorf("Expected the previous match, got %v", v.match)
+	}
+	if top := v.top(len(v.visible()), 4); top > 10 || top+4 <= 10 {
+		t.Errorf("Expected the match to be scrolled into view, top is %v", top)
+	}
+	if v.jump(1, 4); v.match != 15 {
+		t.Errorf("Expected the next match, got %v", v.match)
+	}
+	if v.jump(1, 4); v.match != 0 {
+		t.Errorf("Expected to wrap to the first match, got %v", v.match)
+	}
+
+	v.query = "missing"
+	if v.jump(1, 4) {
+		t.Errorf("Expected no match")
+	}
+}
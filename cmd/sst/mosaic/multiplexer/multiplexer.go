@@ -15,6 +15,7 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/gdamore/tcell/v2/views"
 	tcellterm "github.com/sst/ion/cmd/sst/mosaic/multiplexer/tcell-term"
+	"github.com/sst/ion/pkg/bus"
 )
 
 var PAD_HEIGHT = 0
@@ -32,15 +33,77 @@ type Multiplexer struct {
 	root      *views.ViewPort
 	main      *views.ViewPort
 	stack     *views.BoxLayout
+	options   *Options
+	sidebar   int
 
 	dragging bool
+	resizing bool
 	click    *tcell.EventMouse
+	// view is set while the output of the selected process is paused
+	view *logView
 }
 
-func New(ctx context.Context) *Multiplexer {
+type Options struct {
+	// Order has the keys of the processes that are listed first, in order
+	Order []string
+	// SidebarWidth is used until the sidebar is resized
+	SidebarWidth int
+	Keys         *Keymap
+	// State is the file the layout is kept in once it is changed in the
+	// multiplexer
+	State string
+	// Restarts has the restart policy of the processes by key, they are not
+	// restarted by default
+	Restarts map[string]Restart
+}
+
+type Option func(*Options)
+
+func WithOrder(keys []string) Option {
+	return func(opts *Options) {
+		opts.Order = keys
+	}
+}
+
+func WithSidebarWidth(width int) Option {
+	return func(opts *Options) {
+		opts.SidebarWidth = width
+	}
+}
+
+func WithKeys(keys *Keymap) Option {
+	return func(opts *Options) {
+		opts.Keys = keys
+	}
+}
+
+func WithState(path string) Option {
+	return func(opts *Options) {
+		opts.State = path
+	}
+}
+
+func WithRestarts(restarts map[string]Restart) Option {
+	return func(opts *Options) {
+		opts.Restarts = restarts
+	}
+}
+
+func New(ctx context.Context, options ...Option) *Multiplexer {
 	result := &Multiplexer{}
 	result.ctx = ctx
 	result.processes = []*process{}
+	result.options = &Options{SidebarWidth: SIDEBAR_WIDTH}
+	for _, option := range options {
+		option(result.options)
+	}
+	if result.options.Keys == nil {
+		result.options.Keys, _ = ParseKeys(nil)
+	}
+	result.sidebar = result.options.SidebarWidth
+	if state, ok := loadState(result.options.State); ok && state.SidebarWidth > 0 {
+		result.sidebar = state.SidebarWidth
+	}
 	result.screen, _ = tcell.NewScreen()
 	result.screen.Init()
 	result.screen.EnableMouse()
@@ -59,14 +122,15 @@ func New(ctx context.Context) *Multiplexer {
 }
 
 func (s *Multiplexer) mainRect() (int, int) {
-	return s.width - SIDEBAR_WIDTH + 1, s.height
+	return s.width - s.sidebar + 1, s.height
 }
 
 func (s *Multiplexer) resize(width int, height int) {
 	s.width = width
 	s.height = height
-	s.root.Resize(PAD_WIDTH, PAD_HEIGHT, SIDEBAR_WIDTH, height-PAD_HEIGHT*2)
-	s.main.Resize(PAD_WIDTH+SIDEBAR_WIDTH+PAD_WIDTH+1, PAD_HEIGHT, width-PAD_WIDTH-SIDEBAR_WIDTH-PAD_WIDTH-PAD_WIDTH-1, height-PAD_HEIGHT*2)
+	s.sidebar = clampSidebar(s.sidebar, width)
+	s.root.Resize(PAD_WIDTH, PAD_HEIGHT, s.sidebar, height-PAD_HEIGHT*2)
+	s.main.Resize(PAD_WIDTH+s.sidebar+PAD_WIDTH+1, PAD_HEIGHT, width-PAD_WIDTH-s.sidebar-PAD_WIDTH-PAD_WIDTH-1, height-PAD_HEIGHT*2)
 	mw, mh := s.main.Size()
 	for _, p := range s.processes {
 		p.vt.Resize(mw, mh)
@@ -119,6 +183,7 @@ func (s *Multiplexer) Start() {
 						killable: evt.Killable,
 						env:      evt.Env,
 						dead:     !evt.Autostart,
+						restart:  s.options.Restarts[evt.Key],
 					}
 					term := tcellterm.New()
 					term.SetSurface(s.main)
@@ -139,6 +204,15 @@ func (s *Multiplexer) Start() {
 					break
 
 				case *tcell.EventMouse:
+					if s.view != nil && evt.Buttons()&(tcell.WheelUp|tcell.WheelDown) != 0 {
+						offset := 3
+						if evt.Buttons()&tcell.WheelUp != 0 {
+							offset = -3
+						}
+						s.view.scroll(offset, s.viewHeight())
+						s.draw()
+						return
+					}
 					if evt.Buttons()&tcell.WheelUp != 0 {
 						s.scrollUp(3)
 						return
@@ -148,6 +222,11 @@ func (s *Multiplexer) Start() {
 						return
 					}
 					if evt.Buttons() == tcell.ButtonNone {
+						if s.resizing {
+							s.resizing = false
+							saveState(s.options.State, &State{SidebarWidth: s.sidebar})
+							return
+						}
 						if s.dragging && selected != nil {
 							s.copy()
 						}
@@ -156,7 +235,16 @@ func (s *Multiplexer) Start() {
 					}
 					if evt.Buttons()&tcell.ButtonPrimary != 0 {
 						x, y := evt.Position()
-						if x < SIDEBAR_WIDTH && !s.dragging {
+						// dragging the border resizes the sidebar
+						if s.resizing || (x == s.sidebar-1 && !s.dragging) {
+							s.resizing = true
+							s.sidebar = x + 1
+							s.resize(s.width, s.height)
+							s.draw()
+							s.screen.Sync()
+							return
+						}
+						if x < s.sidebar && !s.dragging {
 							alive := 0
 							for _, p := range s.processes {
 								if !p.dead {
@@ -175,10 +263,11 @@ func (s *Multiplexer) Start() {
 								return
 							}
 							s.selected = y
+							s.view = nil
 							s.blur()
 							return
 						}
-						if x > SIDEBAR_WIDTH {
+						if x > s.sidebar && s.view == nil {
 							if !s.dragging && s.click != nil && time.Since(s.click.When()) < time.Millisecond*500 {
 								oldX, oldY := s.click.Position()
 								if oldX == x && oldY == y {
@@ -190,7 +279,7 @@ func (s *Multiplexer) Start() {
 								}
 							}
 							s.click = evt
-							offsetX := x - SIDEBAR_WIDTH - 1
+							offsetX := x - s.sidebar - 1
 							if s.dragging {
 								selected.vt.SelectEnd(offsetX, y)
 							}
@@ -212,7 +301,7 @@ func (s *Multiplexer) Start() {
 					return
 
 				case *tcellterm.EventRedraw:
-					if selected != nil && selected.vt == evt.VT() {
+					if s.view == nil && selected != nil && selected.vt == evt.VT() {
 						selected.vt.Draw()
 						s.screen.Show()
 					}
@@ -222,7 +311,27 @@ func (s *Multiplexer) Start() {
 					for index, proc := range s.processes {
 						if proc.vt == evt.VT() {
 							if !proc.dead {
-								proc.vt.Start(exec.Command("echo", "\n[process exited]"))
+								code := evt.ExitCode()
+								delay, restart := proc.exited(code)
+								bus.Publish(&ProcessExitedEvent{
+									Key:      proc.key,
+									ExitCode: code,
+									Killed:   proc.killed,
+									Restart:  restart,
+									Delay:    delay,
+								})
+								message := "\n[process exited]"
+								if code > 0 {
+									message = fmt.Sprintf("\n[process exited with code %d]", code)
+								}
+								if restart {
+									message = strings.TrimSuffix(message, "]") + fmt.Sprintf(", restarting in %v]", delay)
+									key := proc.key
+									time.AfterFunc(delay, func() {
+										s.screen.PostEvent(&eventRestart{key: key})
+									})
+								}
+								proc.vt.Start(exec.Command("echo", message))
 								proc.dead = true
 								s.sort()
 								if index == s.selected {
@@ -234,46 +343,47 @@ func (s *Multiplexer) Start() {
 					s.draw()
 					return
 
-				case *tcell.EventKey:
-					switch evt.Key() {
-					case 256:
-						switch evt.Rune() {
-						case 'j':
-							if !s.focused {
-								s.move(1)
-								return
-							}
-						case 'k':
-							if !s.focused {
-								s.move(-1)
-								return
-							}
-						case 'x':
-							if selected.killable && !selected.dead && !s.focused {
-								selected.Kill()
-							}
+				case *eventRestart:
+					for _, proc := range s.processes {
+						if proc.key == evt.key && proc.dead && proc.pending {
+							proc.restartNow()
+							s.sort()
+							s.draw()
 						}
-					case tcell.KeyUp:
+					}
+					return
+
+				case *tcell.EventKey:
+					if s.view != nil && s.options.Keys.action(evt) != ActionQuit {
+						s.viewKey(evt)
+						return
+					}
+					switch s.options.Keys.action(evt) {
+					case ActionUp:
 						if !s.focused {
 							s.move(-1)
 							return
 						}
-					case tcell.KeyDown:
+					case ActionDown:
 						if !s.focused {
 							s.move(1)
 							return
 						}
-					case tcell.KeyCtrlU:
+					case ActionKill:
+						if selected.killable && !selected.dead && !s.focused {
+							selected.Kill()
+						}
+					case ActionScrollUp:
 						if selected != nil {
 							s.scrollUp(s.height/2 + 1)
 							return
 						}
-					case tcell.KeyCtrlD:
+					case ActionScrollDown:
 						if selected != nil {
 							s.scrollDown(s.height/2 + 1)
 							return
 						}
-					case tcell.KeyEnter:
+					case ActionSelect:
 						if selected != nil && selected.vt.HasSelection() {
 							s.copy()
 							selected.vt.ClearSelection()
@@ -298,7 +408,7 @@ func (s *Multiplexer) Start() {
 							}
 							return
 						}
-					case tcell.KeyCtrlC:
+					case ActionQuit:
 						if !s.focused {
 							pid := os.Getpid()
 							process, _ := os.FindProcess(pid)
@@ -306,11 +416,27 @@ func (s *Multiplexer) Start() {
 							shouldBreak = true
 							return
 						}
-					case tcell.KeyCtrlZ:
+					case ActionSidebar:
 						if s.focused {
 							s.blur()
 							return
 						}
+					case ActionPause:
+						if !s.focused {
+							s.pause()
+							return
+						}
+					case ActionSearch:
+						if !s.focused {
+							s.pause()
+							// there is nothing to search without a process
+							if s.view == nil {
+								return
+							}
+							s.view.typing = true
+							s.draw()
+							return
+						}
 					}
 
 					if selected != nil && s.focused && !selected.isScrolling() {
@@ -2,9 +2,11 @@ package multiplexer
 
 import (
 	"os/exec"
+	"time"
 
 	"github.com/gdamore/tcell/v2"
 	tcellterm "github.com/sst/ion/cmd/sst/mosaic/multiplexer/tcell-term"
+	"github.com/sst/ion/pkg/bus"
 )
 
 type vterm struct {
@@ -23,6 +25,39 @@ type process struct {
 	vt       *tcellterm.VT
 	dead     bool
 	cmd      *exec.Cmd
+
+	restart Restart
+	// restarts counts the automatic restarts, attempts the ones since the
+	// process last ran for a while
+	restarts int
+	attempts int
+	started  time.Time
+	// killed is set when the process is stopped from the sidebar, so it is
+	// not restarted
+	killed bool
+	// pending is set while the process waits to be restarted
+	pending bool
+}
+
+// ProcessStartedEvent is published when a process in the multiplexer starts.
+type ProcessStartedEvent struct {
+	Key      string
+	Restarts int
+}
+
+// ProcessExitedEvent is published when a process in the multiplexer exits.
+// Delay is how long it waits before it is restarted, if it is.
+type ProcessExitedEvent struct {
+	Key      string
+	ExitCode int
+	Killed   bool
+	Restart  bool
+	Delay    time.Duration
+}
+
+type eventRestart struct {
+	tcell.EventTime
+	key string
 }
 
 type EventProcess struct {
@@ -51,21 +86,55 @@ func (s *Multiplexer) AddProcess(key string, args []string, icon string, title s
 }
 
 func (p *process) start() error {
+	p.attempts = 0
+	p.vt.Clear()
+	return p.run()
+}
+
+// restartNow starts a process again after it crashed, its output is kept so
+// the crash can still be read.
+func (p *process) restartNow() error {
+	p.restarts++
+	return p.run()
+}
+
+func (p *process) run() error {
 	p.cmd = exec.Command(p.args[0], p.args[1:]...)
 	p.cmd.Env = p.env
 	if p.dir != "" {
 		p.cmd.Dir = p.dir
 	}
-	p.vt.Clear()
+	p.killed = false
+	p.pending = false
 	err := p.vt.Start(p.cmd)
 	if err != nil {
 		return err
 	}
 	p.dead = false
+	p.started = time.Now()
+	bus.Publish(&ProcessStartedEvent{Key: p.key, Restarts: p.restarts})
 	return nil
 }
 
+// exited decides if a process is restarted after it exits with code.
+func (p *process) exited(code int) (time.Duration, bool) {
+	if p.killed {
+		return 0, false
+	}
+	if time.Since(p.started) > stableAfter {
+		p.attempts = 0
+	}
+	delay, ok := p.restart.next(code, p.attempts)
+	if ok {
+		p.attempts++
+		p.pending = true
+	}
+	return delay, ok
+}
+
 func (p *process) Kill() {
+	p.killed = true
+	p.pending = false
 	p.vt.Close()
 }
 
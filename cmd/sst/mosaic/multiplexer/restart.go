@@ -0,0 +1,88 @@
+package multiplexer
+
+import (
+	"fmt"
+	"time"
+)
+
+// The restart policies of a process.
+const (
+	RestartNever     = "never"
+	RestartAlways    = "always"
+	RestartOnFailure = "on-failure"
+)
+
+// Restart is when a process that exits is started again. It waits Backoff
+// before the first restart and twice as long for each one after it, up to
+// maxBackoff.
+type Restart struct {
+	Policy string
+	// Retries is how many times in a row a failing process is restarted with
+	// on-failure, without a limit if it is 0
+	Retries int
+	Backoff time.Duration
+}
+
+const (
+	defaultRetries = 5
+	defaultBackoff = time.Second
+	maxBackoff     = time.Second * 30
+	// stableAfter is how long a process has to run for its retries to be
+	// reset
+	stableAfter = time.Minute
+)
+
+// ParseRestart checks a restart policy from the config. Retries and backoff
+// are optional.
+func ParseRestart(policy string, retries *int, backoff string) (Restart, error) {
+	result := Restart{
+		Policy:  policy,
+		Retries: defaultRetries,
+		Backoff: defaultBackoff,
+	}
+	switch policy {
+	case "", RestartNever:
+		result.Policy = RestartNever
+	case RestartAlways:
+		result.Retries = 0
+	case RestartOnFailure:
+	default:
+		return result, fmt.Errorf("unknown restart policy %q, use always, on-failure, or never", policy)
+	}
+	if retries != nil {
+		if *retries < 0 {
+			return result, fmt.Errorf("retries cannot be negative")
+		}
+		result.Retries = *retries
+	}
+	if backoff != "" {
+		duration, err := time.ParseDuration(backoff)
+		if err != nil || duration < 0 {
+			return result, fmt.Errorf("backoff %q is not a duration like 1s", backoff)
+		}
+		result.Backoff = duration
+	}
+	return result, nil
+}
+
+// next returns how long to wait before restarting a process that exited
+// with code after attempts restarts in a row, or false if it stays stopped.
+func (r Restart) next(code int, attempts int) (time.Duration, bool) {
+	switch r.Policy {
+	case RestartAlways:
+	case RestartOnFailure:
+		if code == 0 {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+	if r.Retries > 0 && attempts >= r.Retries {
+		return 0, false
+	}
+	delay := r.Backoff
+	for i := 0; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxBackoff), true
+}
@@ -0,0 +1,60 @@
+package multiplexer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRestart(t *testing.T) {
+	three := 3
+	restart, err := ParseRestart(RestartOnFailure, &three, "2s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := restart.next(0, 0); ok {
+		t.Errorf("Expected no restart after a clean exit")
+	}
+	for attempts, expected := range []time.Duration{2 * time.Second, 4 * time.Second, 8 * time.Second} {
+		if delay, ok := restart.next(1, attempts); !ok || delay != expected {
+			t.Errorf("Expected a restart in %v after %v attempts, got %v %v", expected, attempts, delay, ok)
+		}
+	}
+	if _, ok := restart.next(1, 3); ok {
+		t.Errorf("Expected no restart after the retries")
+	}
+
+	restart, _ = ParseRestart(RestartAlways, nil, "")
+	if delay, ok := restart.next(0, 20); !ok || delay != maxBackoff {
+		t.Errorf("Expected a restart in %v, got %v %v", maxBackoff, delay, ok)
+	}
+	restart, _ = ParseRestart("", nil, "")
+	if _, ok := restart.next(1, 0); ok {
+		t.Errorf("Expected no restart by default")
+	}
+
+	if _, err := ParseRestart("sometimes", nil, ""); err == nil {
+		t.Errorf("Expected an error for an unknown policy")
+	}
+	if _, err := ParseRestart(RestartAlways, nil, "soon"); err == nil {
+		t.Errorf("Expected an error for an invalid backoff")
+	}
+}
+
+func TestProcessExited(t *testing.T) {
+	restart, _ := ParseRestart(RestartOnFailure, nil, "")
+	p := &process{restart: restart, started: time.Now()}
+	if _, ok := p.exited(1); !ok || !p.pending || p.attempts != 1 {
+		t.Errorf("Expected a pending restart")
+	}
+	p.killed = true
+	p.pending = false
+	if _, ok := p.exited(1); ok {
+		t.Errorf("Expected a killed process to stay stopped")
+	}
+	p.killed = false
+	p.started = time.Now().Add(-2 * stableAfter)
+	p.attempts = 4
+	if delay, ok := p.exited(1); !ok || delay != defaultBackoff {
+		t.Errorf("Expected the retries to start over, got %v %v", delay, ok)
+	}
+}
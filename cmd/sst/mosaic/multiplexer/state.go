@@ -0,0 +1,52 @@
+package multiplexer
+
+import (
+	"encoding/json"
+	"log/slog"
+	"os"
+)
+
+// State is the layout of the multiplexer that is changed from within it, it
+// is kept for the next session.
+type State struct {
+	SidebarWidth int `json:"sidebarWidth"`
+}
+
+const minSidebar = 10
+
+func loadState(path string) (*State, bool) {
+	if path == "" {
+		return nil, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var result State
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false
+	}
+	return &result, true
+}
+
+func saveState(path string, state *State) {
+	if path == "" {
+		return
+	}
+	data, _ := json.Marshal(state)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		slog.Error("failed to save multiplexer state", "err", err)
+	}
+}
+
+// clampSidebar keeps the sidebar wide enough for the titles and leaves room
+// for the process.
+func clampSidebar(sidebar int, width int) int {
+	if sidebar > width/2 {
+		sidebar = width / 2
+	}
+	if sidebar < minSidebar {
+		sidebar = minSidebar
+	}
+	return sidebar
+}
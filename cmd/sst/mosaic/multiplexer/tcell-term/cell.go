@@ -8,6 +8,7 @@ type cell struct {
 	width     int
 	attrs     tcell.Style
 	wrapped   bool
+	tag       string
 }
 
 func (c *cell) rune() rune {
@@ -25,6 +26,7 @@ func (c *cell) erase(s tcell.Style) {
 	_, bg, _ := s.Decompose()
 	c.content = 0
 	c.attrs = tcell.StyleDefault.Background(bg)
+	c.tag = ""
 }
 
 // selectiveErase removes the cell content, but keeps the attributes
@@ -7,6 +7,8 @@ import (
 type cursor struct {
 	attrs tcell.Style
 	style tcell.CursorStyle
+	// tag is set with OSC 7770 and copied to the cells that are printed
+	tag string
 
 	// position
 	row row    // 0-indexed
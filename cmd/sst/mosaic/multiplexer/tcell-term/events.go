@@ -35,6 +35,12 @@ type EventRedraw struct {
 // EventClosed is emitted when the terminal exits
 type EventClosed struct {
 	*EventTerminal
+	exitCode int
+}
+
+// ExitCode is the exit code of the command, -1 if it was killed by a signal
+func (ev *EventClosed) ExitCode() int {
+	return ev.exitCode
 }
 
 // EventTitle is emitted when the terminal's title changes
@@ -4,6 +4,10 @@ import (
 	"strings"
 )
 
+// oscTag is a private sequence that tags the text printed after it, so rows
+// can be told apart without parsing them. An empty tag ends it.
+const oscTag = "7770"
+
 func (vt *VT) osc(data string) {
 	selector, val, found := cutString(data, ";")
 	if !found {
@@ -22,6 +26,8 @@ func (vt *VT) osc(data string) {
 			vt.cursor.attrs = vt.cursor.attrs.Url(url)
 			vt.cursor.attrs = vt.cursor.attrs.UrlId(id)
 		}
+	case oscTag:
+		vt.cursor.tag = val
 	}
 }
 
@@ -0,0 +1,63 @@
+package tcellterm
+
+import (
+	"strings"
+
+	"github.com/gdamore/tcell/v2"
+)
+
+// Cell is the content of one column of a Row.
+type Cell struct {
+	Rune      rune
+	Combining []rune
+	Style     tcell.Style
+}
+
+// Row is a line of the terminal and the tag it was printed with.
+type Row struct {
+	Cells []Cell
+	Tag   string
+}
+
+// Text is the content of the row, one rune for each cell.
+func (r Row) Text() string {
+	var sb strings.Builder
+	for _, cell := range r.Cells {
+		sb.WriteRune(cell.Rune)
+	}
+	return sb.String()
+}
+
+// Rows returns a copy of the scrollback followed by the screen, without the
+// empty rows at the bottom of the screen.
+func (vt *VT) Rows() []Row {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+	result := make([]Row, 0, len(vt.primaryScrollback)+len(vt.activeScreen))
+	for _, cols := range vt.primaryScrollback {
+		result = append(result, newRow(cols))
+	}
+	screen := make([]Row, 0, len(vt.activeScreen))
+	for _, cols := range vt.activeScreen {
+		screen = append(screen, newRow(cols))
+	}
+	for len(screen) > 0 && strings.TrimSpace(screen[len(screen)-1].Text()) == "" {
+		screen = screen[:len(screen)-1]
+	}
+	return append(result, screen...)
+}
+
+func newRow(cols []cell) Row {
+	result := Row{Cells: make([]Cell, len(cols))}
+	for index, cell := range cols {
+		result.Cells[index] = Cell{
+			Rune:      cell.rune(),
+			Combining: cell.combining,
+			Style:     cell.attrs,
+		}
+		if result.Tag == "" {
+			result.Tag = cell.tag
+		}
+	}
+	return result
+}
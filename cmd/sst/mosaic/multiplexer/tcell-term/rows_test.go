@@ -0,0 +1,26 @@
+package tcellterm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRows(t *testing.T) {
+	vt := New()
+	vt.Resize(4, 3)
+	vt.osc("7770;function=Api")
+	vt.print('a')
+	vt.print('b')
+	vt.osc("7770;")
+	vt.lf()
+	vt.cr()
+	vt.print('c')
+
+	rows := vt.Rows()
+	assert.Equal(t, 2, len(rows))
+	assert.Equal(t, "ab  ", rows[0].Text())
+	assert.Equal(t, "function=Api", rows[0].Tag)
+	assert.Equal(t, "c   ", rows[1].Text())
+	assert.Equal(t, "", rows[1].Tag)
+}
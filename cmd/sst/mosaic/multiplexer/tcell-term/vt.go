@@ -166,21 +166,14 @@ func (vt *VT) Start(cmd *exec.Cmd) error {
 		Cols: uint16(w),
 		Rows: uint16(h),
 	}
-	vt.pty, err = pty.StartWithAttrs(
-		cmd,
-		&winsize,
-		&syscall.SysProcAttr{
-			Setsid:  true,
-			Setctty: true,
-			Ctty:    1,
-		})
+	vt.pty, err = pty.StartWithAttrs(cmd, &winsize, ptyAttrs())
 	if err != nil {
 		return err
 	}
 
 	vt.Resize(w, h)
 	vt.parser = NewParser(vt.pty)
-	go func() {
+	go func(cmd *exec.Cmd) {
 		defer vt.recover()
 		for {
 			select {
@@ -190,8 +183,10 @@ func (vt *VT) Start(cmd *exec.Cmd) error {
 				seq := vt.parser.Next()
 				switch seq := seq.(type) {
 				case EOF:
+					cmd.Wait()
 					vt.eventHandler(&EventClosed{
 						EventTerminal: newEventTerminal(vt),
+						exitCode:      cmd.ProcessState.ExitCode(),
 					})
 					return
 				default:
@@ -199,7 +194,7 @@ func (vt *VT) Start(cmd *exec.Cmd) error {
 				}
 			}
 		}
-	}()
+	}(cmd)
 	return nil
 }
 
@@ -396,6 +391,7 @@ func (vt *VT) print(r rune) {
 		content: r,
 		width:   w,
 		attrs:   vt.cursor.attrs,
+		tag:     vt.cursor.tag,
 	}
 
 	vt.activeScreen[rw][col] = cell
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package tcellterm
+
+import "syscall"
+
+// ptyAttrs makes the pty the controlling terminal of a new session, so the
+// process gets the signals of the terminal.
+func ptyAttrs() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{
+		Setsid:  true,
+		Setctty: true,
+		Ctty:    1,
+	}
+}
@@ -0,0 +1,8 @@
+package tcellterm
+
+import "syscall"
+
+// there are no ptys on windows, starting one fails with pty.ErrUnsupported
+func ptyAttrs() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{}
+}
@@ -0,0 +1,347 @@
+package offline
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func (e *emulator) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/2015-03-31/functions/", e.invokeAPI)
+	mux.HandleFunc("/url/", func(w http.ResponseWriter, r *http.Request) {
+		functionID, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/url/"), "/")
+		fn, ok := e.lookup(functionID)
+		if !ok || !fn.URL {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		e.serveHTTP(w, r, functionID, "$default", "/"+path, nil)
+	})
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		api, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+		route, params, ok := matchRoute(e.routes, api, r.Method, "/"+path)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"message": "Not Found"})
+			return
+		}
+		e.serveHTTP(w, r, route.FunctionID, route.Key, "/"+path, params)
+	})
+	return mux
+}
+
+// invokeAPI serves the Invoke action of the Lambda API so the AWS SDK can be
+// pointed at the offline server.
+func (e *emulator) invokeAPI(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/2015-03-31/functions/"), "/invocations")
+	if !ok || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	// the name can also be the arn of the function
+	if index := strings.LastIndex(name, ":function:"); index != -1 {
+		name = name[index+len(":function:"):]
+	}
+	payload, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"Type": "User", "message": err.Error()})
+		return
+	}
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	if _, ok := e.lookup(name); !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{
+			"Type":    "User",
+			"message": "Function not found: " + name,
+		})
+		return
+	}
+	if r.Header.Get("X-Amz-Invocation-Type") == "Event" {
+		go func() {
+			_, err := e.invoke(e.ctx, name, payload)
+			if err != nil {
+				slog.Error("async invocation failed", "function", name, "err", err)
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	match, err := e.invoke(r.Context(), name, payload)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"Type": "Service", "message": err.Error()})
+		return
+	}
+	if match.failed {
+		w.Header().Set("X-Amz-Function-Error", "Unhandled")
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(match.output)
+}
+
+func (e *emulator) serveHTTP(w http.ResponseWriter, r *http.Request, functionID string, routeKey string, path string, params map[string]string) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+		return
+	}
+	payload, err := json.Marshal(httpEvent(r, routeKey, path, params, body))
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"message": err.Error()})
+		return
+	}
+	match, err := e.invoke(r.Context(), functionID, payload)
+	if err != nil {
+		slog.Error("invocation failed", "functionID", functionID, "err", err)
+		writeJSON(w, http.StatusBadGateway, map[string]string{"message": "Bad Gateway"})
+		return
+	}
+	if match.failed {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"message": "Internal Server Error"})
+		return
+	}
+	response, err := parseResponse(match.output)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"message": "Bad Gateway"})
+		return
+	}
+	for key, value := range response.headers {
+		w.Header().Set(key, value)
+	}
+	for _, cookie := range response.cookies {
+		w.Header().Add("Set-Cookie", cookie)
+	}
+	w.WriteHeader(response.status)
+	w.Write(response.body)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// httpEvent is the version 2.0 payload that API Gateway and function URLs
+// send to functions.
+func httpEvent(r *http.Request, routeKey string, path string, params map[string]string, body []byte) map[string]interface{} {
+	headers := map[string]string{}
+	for key, values := range r.Header {
+		if strings.EqualFold(key, "Cookie") {
+			continue
+		}
+		headers[strings.ToLower(key)] = strings.Join(values, ",")
+	}
+	headers["host"] = r.Host
+	cookies := []string{}
+	for _, cookie := range r.Cookies() {
+		cookies = append(cookies, cookie.Name+"="+cookie.Value)
+	}
+	query := map[string]string{}
+	for key, values := range r.URL.Query() {
+		query[key] = strings.Join(values, ",")
+	}
+	now := time.Now()
+	requestID := requestID()
+	result := map[string]interface{}{
+		"version":        "2.0",
+		"routeKey":       routeKey,
+		"rawPath":        path,
+		"rawQueryString": r.URL.RawQuery,
+		"headers":        headers,
+		"requestContext": map[string]interface{}{
+			"accountId":    "offline",
+			"apiId":        "offline",
+			"domainName":   r.Host,
+			"domainPrefix": strings.Split(r.Host, ".")[0],
+			"http": map[string]interface{}{
+				"method":    r.Method,
+				"path":      path,
+				"protocol":  r.Proto,
+				"sourceIp":  strings.Split(r.RemoteAddr, ":")[0],
+				"userAgent": r.UserAgent(),
+			},
+			"requestId": requestID,
+			"routeKey":  routeKey,
+			"stage":     "$default",
+			"time":      now.UTC().Format("02/Jan/2006:15:04:05 -0700"),
+			"timeEpoch": now.UnixMilli(),
+		},
+		"isBase64Encoded": false,
+	}
+	if len(cookies) > 0 {
+		result["cookies"] = cookies
+	}
+	if len(query) > 0 {
+		result["queryStringParameters"] = query
+	}
+	if len(params) > 0 {
+		result["pathParameters"] = params
+	}
+	if len(body) > 0 {
+		if isText(r.Header.Get("Content-Type")) && utf8.Valid(body) {
+			result["body"] = string(body)
+		} else {
+			result["body"] = base64.StdEncoding.EncodeToString(body)
+			result["isBase64Encoded"] = true
+		}
+	}
+	return result
+}
+
+func isText(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	if contentType == "" || strings.HasPrefix(contentType, "text/") {
+		return true
+	}
+	for _, match := range []string{"json", "xml", "javascript", "x-www-form-urlencoded", "graphql"} {
+		if strings.Contains(contentType, match) {
+			return true
+		}
+	}
+	return false
+}
+
+type response struct {
+	status  int
+	headers map[string]string
+	cookies []string
+	body    []byte
+}
+
+// parseResponse turns what the function returned into a response the way
+// API Gateway does. Anything without a statusCode is the body.
+func parseResponse(output []byte) (*response, error) {
+	var parsed struct {
+		StatusCode      *int              `json:"statusCode"`
+		Headers         map[string]string `json:"headers"`
+		Cookies         []string          `json:"cookies"`
+		Body            *string           `json:"body"`
+		IsBase64Encoded bool              `json:"isBase64Encoded"`
+	}
+	if err := json.Unmarshal(output, &parsed); err != nil || parsed.StatusCode == nil {
+		return &response{
+			status:  http.StatusOK,
+			headers: map[string]string{"Content-Type": "application/json"},
+			body:    output,
+		}, nil
+	}
+	result := &response{
+		status:  *parsed.StatusCode,
+		headers: parsed.Headers,
+		cookies: parsed.Cookies,
+	}
+	if result.headers == nil {
+		result.headers = map[string]string{}
+	}
+	if parsed.Body != nil {
+		result.body = []byte(*parsed.Body)
+		if parsed.IsBase64Encoded {
+			decoded, err := base64.StdEncoding.DecodeString(*parsed.Body)
+			if err != nil {
+				return nil, errors.Join(fmt.Errorf("body is not valid base64"), err)
+			}
+			result.body = decoded
+		}
+	}
+	return result, nil
+}
+
+// matchRoute finds the route of api for a request the way API Gateway does,
+// the most specific route wins and $default is the last resort.
+func matchRoute(routes []Route, api string, method string, path string) (Route, map[string]string, bool) {
+	type candidate struct {
+		route  Route
+		params map[string]string
+		rank   []int
+	}
+	candidates := []candidate{}
+	segments := splitPath(path)
+	for _, route := range routes {
+		if route.Api != api {
+			continue
+		}
+		if route.Key == "$default" {
+			candidates = append(candidates, candidate{route: route, rank: []int{0}})
+			continue
+		}
+		routeMethod, routePath, ok := strings.Cut(route.Key, " ")
+		if !ok || (routeMethod != "ANY" && !strings.EqualFold(routeMethod, method)) {
+			continue
+		}
+		params, literals, greedy, ok := matchPath(splitPath(routePath), segments)
+		if !ok {
+			continue
+		}
+		rank := []int{1, 1, literals, len(splitPath(routePath)), 1}
+		if greedy {
+			rank[1] = 0
+		}
+		if routeMethod == "ANY" {
+			rank[4] = 0
+		}
+		candidates = append(candidates, candidate{route: route, params: params, rank: rank})
+	}
+	if len(candidates) == 0 {
+		return Route{}, nil, false
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].rank, candidates[j].rank
+		for index := 0; index < len(a) && index < len(b); index++ {
+			if a[index] != b[index] {
+				return a[index] > b[index]
+			}
+		}
+		return len(a) > len(b)
+	})
+	return candidates[0].route, candidates[0].params, true
+}
+
+// matchPath matches the segments of a path to a route like /users/{id} or
+// /files/{proxy+}. It returns the path parameters, how many segments were
+// literals, and whether a greedy parameter matched.
+func matchPath(route []string, segments []string) (map[string]string, int, bool, bool) {
+	params := map[string]string{}
+	literals := 0
+	for index, part := range route {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "+}") {
+			if index == len(segments) || index != len(route)-1 {
+				return nil, 0, false, false
+			}
+			params[part[1:len(part)-2]] = strings.Join(segments[index:], "/")
+			return params, literals, true, true
+		}
+		if index >= len(segments) {
+			return nil, 0, false, false
+		}
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			params[part[1:len(part)-1]] = segments[index]
+			continue
+		}
+		if part != segments[index] {
+			return nil, 0, false, false
+		}
+		literals++
+	}
+	if len(route) != len(segments) {
+		return nil, 0, false, false
+	}
+	return params, literals, false, true
+}
+
+func splitPath(path string) []string {
+	result := []string{}
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
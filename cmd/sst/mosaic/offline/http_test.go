@@ -0,0 +1,94 @@
+package offline
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMatchRoute(t *testing.T) {
+	routes := []Route{
+		{Api: "Api", Key: "$default", FunctionID: "Default"},
+		{Api: "Api", Key: "GET /users/{id}", FunctionID: "User"},
+		{Api: "Api", Key: "GET /users/me", FunctionID: "Me"},
+		{Api: "Api", Key: "ANY /users/{proxy+}", FunctionID: "Users"},
+		{Api: "Api", Key: "POST /users/{id}", FunctionID: "Update"},
+		{Api: "Other", Key: "GET /users/{id}", FunctionID: "Other"},
+	}
+	tests := []struct {
+		method, path, expected string
+		params                 map[string]string
+	}{
+		{"GET", "/users/me", "Me", map[string]string{}},
+		{"GET", "/users/42", "User", map[string]string{"id": "42"}},
+		{"POST", "/users/42", "Update", map[string]string{"id": "42"}},
+		{"DELETE", "/users/42", "Users", map[string]string{"proxy": "42"}},
+		{"GET", "/users/42/posts", "Users", map[string]string{"proxy": "42/posts"}},
+		{"GET", "/users", "Default", nil},
+		{"GET", "/", "Default", nil},
+	}
+	for _, test := range tests {
+		route, params, ok := matchRoute(routes, "Api", test.method, test.path)
+		if !ok || route.FunctionID != test.expected {
+			t.Errorf("%s %s: expected %v, got %v", test.method, test.path, test.expected, route.FunctionID)
+			continue
+		}
+		if len(params) != len(test.params) {
+			t.Errorf("%s %s: expected params %v, got %v", test.method, test.path, test.params, params)
+		}
+		for key, value := range test.params {
+			if params[key] != value {
+				t.Errorf("%s %s: expected %v=%v, got %v", test.method, test.path, key, value, params[key])
+			}
+		}
+	}
+	if _, _, ok := matchRoute(routes, "Missing", "GET", "/"); ok {
+		t.Errorf("Expected no route for an unknown api")
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	result, err := parseResponse([]byte(`{"statusCode":201,"headers":{"x-test":"1"},"cookies":["a=1"],"body":"aGVsbG8=","isBase64Encoded":true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.status != 201 || result.headers["x-test"] != "1" || len(result.cookies) != 1 || string(result.body) != "hello" {
+		t.Errorf("Unexpected response %+v", result)
+	}
+
+	result, err = parseResponse([]byte(`{"message":"hi"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.status != 200 || string(result.body) != `{"message":"hi"}` || result.headers["Content-Type"] != "application/json" {
+		t.Errorf("Expected the output to be the body, got %+v", result)
+	}
+
+	if _, err := parseResponse([]byte(`{"statusCode":200,"body":"!","isBase64Encoded":true}`)); err == nil {
+		t.Errorf("Expected an error for invalid base64")
+	}
+}
+
+func TestHTTPEvent(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://localhost:13560/api/Api/users/42?a=1&a=2", strings.NewReader(`{"name":"x"}`))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("Cookie", "session=abc")
+	evt := httpEvent(r, "POST /users/{id}", "/users/42", map[string]string{"id": "42"}, []byte(`{"name":"x"}`))
+	if evt["rawPath"] != "/users/42" || evt["rawQueryString"] != "a=1&a=2" || evt["body"] != `{"name":"x"}` || evt["isBase64Encoded"] != false {
+		t.Errorf("Unexpected event %v", evt)
+	}
+	if query := evt["queryStringParameters"].(map[string]string); query["a"] != "1,2" {
+		t.Errorf("Expected joined query values, got %v", query)
+	}
+	if cookies := evt["cookies"].([]string); len(cookies) != 1 || cookies[0] != "session=abc" {
+		t.Errorf("Expected cookies, got %v", cookies)
+	}
+	if _, ok := evt["headers"].(map[string]string)["cookie"]; ok {
+		t.Errorf("Expected the cookie header to be moved to cookies")
+	}
+
+	evt = httpEvent(r, "$default", "/", nil, []byte{0xff, 0x00})
+	if evt["isBase64Encoded"] != true || evt["body"] != "/wA=" {
+		t.Errorf("Expected a base64 body, got %v", evt["body"])
+	}
+}
@@ -0,0 +1,235 @@
+package offline
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/runtime"
+	"github.com/sst/ion/pkg/server"
+)
+
+// Manifest is what offline mode knows about the functions of an app. It is
+// built by running the app with mocked resources, so nothing has to be
+// deployed first. It is only kept in memory, the targets have the values of
+// the linked resources.
+type Manifest struct {
+	Functions map[string]*Function `json:"functions"`
+	Routes    []Route              `json:"routes"`
+}
+
+type Function struct {
+	Target *runtime.BuildInput `json:"target"`
+	// Name is the name the function would be deployed with
+	Name string `json:"name"`
+	// Env is the environment the function would be deployed with, without
+	// the key of its linked resources
+	Env []string `json:"env"`
+	// URL is set if the function has a function URL
+	URL bool `json:"url"`
+}
+
+// Route is an API Gateway route to a function.
+type Route struct {
+	// Api is the name of the component, its routes are served under /api/<Api>
+	Api string `json:"api"`
+	// Key is the route key, like `GET /users/{id}` or `$default`
+	Key        string `json:"key"`
+	FunctionID string `json:"functionID"`
+}
+
+// the links are passed in as variables instead of the encrypted file
+var skipEnv = map[string]bool{
+	"SST_KEY":      true,
+	"SST_KEY_FILE": true,
+}
+
+// BuildManifest runs the app with mocked resources and collects the targets
+// the functions add to the runtime while it does.
+func BuildManifest(ctx context.Context, p *project.Project, s *server.Server) (*Manifest, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	targets := bus.Subscribe[*runtime.BuildInput](ctx, bus.Topic(&runtime.BuildInput{}))
+	// the components call the server while the app runs
+	for {
+		conn, err := net.Dial("tcp", fmt.Sprintf("localhost:%d", s.Port))
+		if err == nil {
+			conn.Close()
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	resources, err := p.Mock(ctx, &project.MockInput{
+		ServerPort:  s.Port,
+		ServerToken: s.Token,
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Functions: map[string]*Function{}}
+	// the rpc calls of the app are answered before it exits
+	for done := false; !done; {
+		select {
+		case target := <-targets:
+			target.EncryptionKey = ""
+			manifest.function(target.FunctionID).Target = target
+		default:
+			done = true
+		}
+	}
+	routes, urls := routesFrom(resources)
+	manifest.Routes = routes
+	for functionID, env := range envFrom(resources) {
+		manifest.function(functionID).Env = env
+	}
+	for functionID, name := range namesFrom(resources) {
+		manifest.function(functionID).Name = name
+	}
+	for functionID, item := range manifest.Functions {
+		item.URL = urls[functionID]
+	}
+	return manifest, nil
+}
+
+func (m *Manifest) function(functionID string) *Function {
+	match, ok := m.Functions[functionID]
+	if !ok {
+		match = &Function{}
+		m.Functions[functionID] = match
+	}
+	return match
+}
+
+// component returns the name of the closest parent of item of kind, or the
+// name of item if there is none.
+func component(byURN map[resource.URN]apitype.ResourceV3, item apitype.ResourceV3, kind string) string {
+	for urn := item.Parent; urn != ""; urn = byURN[urn].Parent {
+		if string(byURN[urn].Type) == kind {
+			return urn.Name()
+		}
+	}
+	return item.URN.Name()
+}
+
+func byURN(resources []apitype.ResourceV3) map[resource.URN]apitype.ResourceV3 {
+	result := map[resource.URN]apitype.ResourceV3{}
+	for _, item := range resources {
+		result[item.URN] = item
+	}
+	return result
+}
+
+// envFrom returns the environment variables of the Lambda functions by the
+// sst:aws:Function they belong to.
+func envFrom(resources []apitype.ResourceV3) map[string][]string {
+	parents := byURN(resources)
+	result := map[string][]string{}
+	for _, item := range resources {
+		if item.Type != "aws:lambda/function:Function" {
+			continue
+		}
+		environment, _ := item.Outputs["environment"].(map[string]interface{})
+		variables, _ := environment["variables"].(map[string]interface{})
+		env := []string{}
+		for key, value := range variables {
+			if value, ok := value.(string); ok && !skipEnv[key] {
+				env = append(env, key+"="+value)
+			}
+		}
+		sort.Strings(env)
+		result[component(parents, item, "sst:aws:Function")] = env
+	}
+	return result
+}
+
+func namesFrom(resources []apitype.ResourceV3) map[string]string {
+	parents := byURN(resources)
+	result := map[string]string{}
+	for _, item := range resources {
+		if item.Type != "aws:lambda/function:Function" {
+			continue
+		}
+		if name, ok := item.Outputs["name"].(string); ok {
+			result[component(parents, item, "sst:aws:Function")] = name
+		}
+	}
+	return result
+}
+
+// routesFrom finds the API Gateway routes and function URLs of the
+// functions. Functions are named after their sst:aws:Function component like
+// the targets of the runtime.
+func routesFrom(resources []apitype.ResourceV3) ([]Route, map[string]bool) {
+	parents := byURN(resources)
+	functionsByArn := map[string]string{}
+	functionsByName := map[string]string{}
+	apis := map[string]string{}
+	integrations := map[string]string{}
+	for _, item := range resources {
+		switch item.Type {
+		case "aws:lambda/function:Function":
+			functionID := component(parents, item, "sst:aws:Function")
+			if arn, ok := item.Outputs["arn"].(string); ok {
+				functionsByArn[arn] = functionID
+			}
+			if name, ok := item.Outputs["name"].(string); ok {
+				functionsByName[name] = functionID
+			}
+		case "aws:apigatewayv2/api:Api":
+			apis[string(item.ID)] = component(parents, item, "sst:aws:ApiGatewayV2")
+		}
+	}
+	for _, item := range resources {
+		if item.Type != "aws:apigatewayv2/integration:Integration" {
+			continue
+		}
+		uri, _ := item.Outputs["integrationUri"].(string)
+		// the uri is either the arn of the function or the invocation arn
+		// that ends with it
+		uri = strings.TrimSuffix(uri, "/invocations")
+		if index := strings.LastIndex(uri, "/functions/"); index != -1 {
+			uri = uri[index+len("/functions/"):]
+		}
+		if functionID, ok := functionsByArn[uri]; ok {
+			integrations[string(item.ID)] = functionID
+		}
+	}
+	routes := []Route{}
+	urls := map[string]bool{}
+	for _, item := range resources {
+		switch item.Type {
+		case "aws:apigatewayv2/route:Route":
+			target, _ := item.Outputs["target"].(string)
+			functionID, ok := integrations[strings.TrimPrefix(target, "integrations/")]
+			if !ok {
+				continue
+			}
+			apiID, _ := item.Outputs["apiId"].(string)
+			key, _ := item.Outputs["routeKey"].(string)
+			routes = append(routes, Route{Api: apis[apiID], Key: key, FunctionID: functionID})
+		case "aws:lambda/functionUrl:FunctionUrl":
+			name, _ := item.Outputs["functionName"].(string)
+			if functionID, ok := functionsByName[name]; ok {
+				urls[functionID] = true
+			}
+		}
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Api != routes[j].Api {
+			return routes[i].Api < routes[j].Api
+		}
+		return routes[i].Key < routes[j].Key
+	})
+	return routes, urls
+}
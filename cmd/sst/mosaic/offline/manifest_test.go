@@ -0,0 +1,79 @@
+package offline
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestRoutesFrom(t *testing.T) {
+	urn := func(kind, name string) resource.URN {
+		return resource.URN("urn:pulumi:dev::app::" + kind + "::" + name)
+	}
+	resources := []apitype.ResourceV3{
+		{URN: urn("sst:aws:ApiGatewayV2", "Api"), Type: "sst:aws:ApiGatewayV2"},
+		{URN: urn("aws:apigatewayv2/api:Api", "ApiApi"), Type: "aws:apigatewayv2/api:Api", ID: "abc", Parent: urn("sst:aws:ApiGatewayV2", "Api")},
+		{URN: urn("sst:aws:Function", "ApiRouteHandler"), Type: "sst:aws:Function", Parent: urn("sst:aws:ApiGatewayV2", "Api")},
+		{
+			URN:     urn("aws:lambda/function:Function", "ApiRouteHandlerFunction"),
+			Type:    "aws:lambda/function:Function",
+			Parent:  urn("sst:aws:Function", "ApiRouteHandler"),
+			Outputs: map[string]interface{}{"arn": "arn:aws:lambda:us-east-1:1:function:handler", "name": "handler"},
+		},
+		{
+			URN:     urn("aws:apigatewayv2/integration:Integration", "Integration"),
+			Type:    "aws:apigatewayv2/integration:Integration",
+			ID:      "int1",
+			Outputs: map[string]interface{}{"integrationUri": "arn:aws:apigateway:us-east-1:lambda:path/2015-03-31/functions/arn:aws:lambda:us-east-1:1:function:handler/invocations"},
+		},
+		{
+			URN:     urn("aws:apigatewayv2/route:Route", "Route"),
+			Type:    "aws:apigatewayv2/route:Route",
+			Outputs: map[string]interface{}{"apiId": "abc", "routeKey": "GET /", "target": "integrations/int1"},
+		},
+		{
+			URN:     urn("aws:lambda/functionUrl:FunctionUrl", "Url"),
+			Type:    "aws:lambda/functionUrl:FunctionUrl",
+			Outputs: map[string]interface{}{"functionName": "handler"},
+		},
+	}
+	routes, urls := routesFrom(resources)
+	if len(routes) != 1 || routes[0] != (Route{Api: "Api", Key: "GET /", FunctionID: "ApiRouteHandler"}) {
+		t.Errorf("Unexpected routes %v", routes)
+	}
+	if !urls["ApiRouteHandler"] {
+		t.Errorf("Expected a function url, got %v", urls)
+	}
+}
+
+func TestEnvFrom(t *testing.T) {
+	urn := func(kind, name string) resource.URN {
+		return resource.URN("urn:pulumi:dev::app::" + kind + "::" + name)
+	}
+	resources := []apitype.ResourceV3{
+		{URN: urn("sst:aws:Function", "Handler"), Type: "sst:aws:Function"},
+		{
+			URN:    urn("aws:lambda/function:Function", "HandlerFunction"),
+			Type:   "aws:lambda/function:Function",
+			Parent: urn("sst:aws:Function", "Handler"),
+			Outputs: map[string]interface{}{
+				"name": "app-dev-Handler",
+				"environment": map[string]interface{}{
+					"variables": map[string]interface{}{
+						"SST_KEY":      "secret",
+						"SST_KEY_FILE": "resource.enc",
+						"TABLE":        "users",
+					},
+				},
+			},
+		},
+	}
+	env := envFrom(resources)
+	if len(env["Handler"]) != 1 || env["Handler"][0] != "TABLE=users" {
+		t.Errorf("Unexpected env %v", env)
+	}
+	if names := namesFrom(resources); names["Handler"] != "app-dev-Handler" {
+		t.Errorf("Unexpected names %v", names)
+	}
+}
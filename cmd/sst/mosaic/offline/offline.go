@@ -0,0 +1,417 @@
+package offline
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sst/ion/cmd/sst/mosaic/aws"
+	"github.com/sst/ion/cmd/sst/mosaic/watcher"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/runtime"
+	"github.com/sst/ion/pkg/server"
+)
+
+const DefaultPort = 13560
+
+var ErrNoFunctions = fmt.Errorf("the app has no functions to run offline")
+var ErrFunctionNotFound = fmt.Errorf("function not found")
+
+// invocationTimeout is the longest a deployed function can run for
+const invocationTimeout = 15 * time.Minute
+
+type invocation struct {
+	requestID string
+	payload   []byte
+	result    chan result
+}
+
+type result struct {
+	output []byte
+	// failed is set when output is the error the function returned
+	failed bool
+}
+
+type function struct {
+	*Function
+	id string
+	// queue has the invocations that are waiting for the worker
+	queue chan *invocation
+	// start serializes building and starting the worker
+	start sync.Mutex
+
+	// the rest is guarded by the mutex of the emulator
+	build    *runtime.BuildOutput
+	worker   runtime.Worker
+	workerID string
+	current  string
+	inflight map[string]*invocation
+}
+
+// emulator runs functions locally without AWS. It implements the Lambda
+// runtime API for the workers of `sst dev`, and invokes them for the Lambda
+// invoke API and the HTTP routes of the app.
+type emulator struct {
+	ctx       context.Context
+	p         *project.Project
+	server    string
+	mu        sync.Mutex
+	functions map[string]*function
+	// names has the name every function would be deployed with, for the
+	// invoke API
+	names   map[string]string
+	workers map[string]*function
+	routes  []Route
+}
+
+// Start serves the functions of the app on port until ctx is done. The
+// runtime API is served by s since that is where workers connect.
+func Start(ctx context.Context, p *project.Project, s *server.Server, port int) error {
+	manifest, err := BuildManifest(ctx, p, s)
+	if err != nil {
+		return util.NewReadableError(err, "Could not run your app offline: "+err.Error())
+	}
+	if len(manifest.Functions) == 0 {
+		return util.NewReadableError(ErrNoFunctions, "There are no functions in your app to run offline")
+	}
+	e := &emulator{
+		ctx:       ctx,
+		p:         p,
+		server:    fmt.Sprintf("localhost:%d/lambda/", s.Port),
+		functions: map[string]*function{},
+		names:     map[string]string{},
+		workers:   map[string]*function{},
+		routes:    manifest.Routes,
+	}
+	for functionID, item := range manifest.Functions {
+		if item.Target == nil {
+			continue
+		}
+		p.Runtime.AddTarget(item.Target)
+		e.functions[functionID] = &function{
+			Function: item,
+			id:       functionID,
+			queue:    make(chan *invocation, 1000),
+			inflight: map[string]*invocation{},
+		}
+		if item.Name != "" {
+			e.names[item.Name] = functionID
+		}
+	}
+	s.Mux.HandleFunc("/lambda/", e.runtimeAPI)
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", port))
+	if err != nil {
+		return util.NewReadableError(err, fmt.Sprintf("Could not start the offline server on port %d", port))
+	}
+	httpServer := &http.Server{Handler: e.mux()}
+	go func() {
+		<-ctx.Done()
+		httpServer.Close()
+	}()
+	go e.watch(ctx)
+	bus.Publish(&StartedEvent{URL: fmt.Sprintf("http://localhost:%d", port), Routes: manifest.Routes})
+	slog.Info("offline server started", "port", port)
+	err = httpServer.Serve(listener)
+	if err == http.ErrServerClosed {
+		err = nil
+	}
+	e.stop()
+	return err
+}
+
+// StartedEvent is published once the offline server is listening.
+type StartedEvent struct {
+	URL    string
+	Routes []Route
+}
+
+func (e *emulator) watch(ctx context.Context) {
+	evts := bus.Subscribe[*watcher.FileChangedEvent](ctx, bus.Topic(&watcher.FileChangedEvent{}))
+	for evt := range evts {
+		e.mu.Lock()
+		for _, fn := range e.functions {
+			if fn.build == nil || !e.p.Runtime.ShouldRebuild(fn.Target.Runtime, fn.id, evt.Path) {
+				continue
+			}
+			// the next invocation builds it again
+			fn.build = nil
+			e.stopWorker(fn, "The function was stopped to rebuild it")
+		}
+		e.mu.Unlock()
+	}
+}
+
+func (e *emulator) stop() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, fn := range e.functions {
+		e.stopWorker(fn, "sst dev was stopped")
+	}
+}
+
+// stopWorker stops the worker of fn and fails the invocations it had, it
+// expects the mutex to be held.
+func (e *emulator) stopWorker(fn *function, reason string) {
+	if fn.worker == nil {
+		return
+	}
+	slog.Info("stopping", "workerID", fn.workerID, "functionID", fn.id)
+	fn.worker.Stop()
+	delete(e.workers, fn.workerID)
+	fn.worker = nil
+	fn.workerID = ""
+	for requestID, inv := range fn.inflight {
+		inv.result <- result{output: errorPayload("Runtime.ExitError", reason), failed: true}
+		delete(fn.inflight, requestID)
+	}
+}
+
+// ensure builds the function if it has to and starts its worker. Both run
+// with the context of the emulator, the worker outlives the request that
+// started it.
+func (e *emulator) ensure(fn *function) error {
+	fn.start.Lock()
+	defer fn.start.Unlock()
+	e.mu.Lock()
+	running := fn.worker != nil
+	build := fn.build
+	e.mu.Unlock()
+	if running {
+		return nil
+	}
+
+	if build == nil {
+		var err error
+		build, err = e.p.Runtime.Build(e.ctx, fn.Target)
+		if err != nil {
+			bus.Publish(&aws.FunctionBuildEvent{FunctionID: fn.id, Errors: []string{err.Error()}})
+			return err
+		}
+		bus.Publish(&aws.FunctionBuildEvent{FunctionID: fn.id, Errors: build.Errors})
+		if len(build.Errors) > 0 {
+			return fmt.Errorf("function %s failed to build", fn.id)
+		}
+	}
+
+	workerID := fn.id + "-" + util.RandomString(8)
+	e.mu.Lock()
+	fn.build = build
+	e.workers[workerID] = fn
+	e.mu.Unlock()
+	worker, err := e.p.Runtime.Run(e.ctx, &runtime.RunInput{
+		CfgPath:    e.p.PathConfig(),
+		Runtime:    fn.Target.Runtime,
+		Server:     e.server + workerID,
+		WorkerID:   workerID,
+		FunctionID: fn.id,
+		Build:      build,
+		Env:        e.env(fn),
+	})
+	if err != nil {
+		e.mu.Lock()
+		delete(e.workers, workerID)
+		e.mu.Unlock()
+		return err
+	}
+	e.mu.Lock()
+	fn.worker = worker
+	fn.workerID = workerID
+	e.mu.Unlock()
+
+	go func() {
+		scanner := bufio.NewScanner(worker.Logs())
+		for scanner.Scan() {
+			e.mu.Lock()
+			requestID := fn.current
+			e.mu.Unlock()
+			bus.Publish(&aws.FunctionLogEvent{
+				FunctionID: fn.id,
+				WorkerID:   workerID,
+				RequestID:  requestID,
+				Line:       scanner.Text(),
+			})
+		}
+		slog.Info("worker died", "workerID", workerID)
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if fn.workerID == workerID {
+			e.stopWorker(fn, "The function exited before it responded")
+		}
+	}()
+	return nil
+}
+
+// env is the environment the function would be deployed with. The linked
+// resources are passed in as variables, they are resolved when the app is
+// run so they are never written to disk.
+func (e *emulator) env(fn *function) []string {
+	result := append([]string{}, fn.Env...)
+	defaults := map[string]string{
+		"SST_FUNCTION_ID":  fn.id,
+		"SST_APP":          e.p.App().Name,
+		"SST_STAGE":        e.p.App().Stage,
+		"SST_RESOURCE_App": fmt.Sprintf(`{"name":%q,"stage":%q}`, e.p.App().Name, e.p.App().Stage),
+	}
+	for name, value := range fn.Target.Links {
+		defaults["SST_RESOURCE_"+name] = string(value)
+	}
+	for _, item := range fn.Env {
+		key, _, _ := strings.Cut(item, "=")
+		delete(defaults, key)
+	}
+	for key, value := range defaults {
+		result = append(result, key+"="+value)
+	}
+	return result
+}
+
+func (e *emulator) lookup(name string) (*function, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if functionID, ok := e.names[name]; ok {
+		name = functionID
+	}
+	fn, ok := e.functions[name]
+	return fn, ok
+}
+
+// invoke runs the function with payload and waits for it to respond.
+func (e *emulator) invoke(ctx context.Context, functionID string, payload []byte) (result, error) {
+	fn, ok := e.lookup(functionID)
+	if !ok {
+		return result{}, ErrFunctionNotFound
+	}
+	if err := e.ensure(fn); err != nil {
+		return result{}, err
+	}
+	inv := &invocation{
+		requestID: requestID(),
+		payload:   payload,
+		result:    make(chan result, 1),
+	}
+	ctx, cancel := context.WithTimeout(ctx, invocationTimeout)
+	defer cancel()
+	select {
+	case fn.queue <- inv:
+	case <-ctx.Done():
+		return result{}, ctx.Err()
+	}
+	select {
+	case match := <-inv.result:
+		return match, nil
+	case <-ctx.Done():
+		return result{}, ctx.Err()
+	}
+}
+
+// runtimeAPI serves /lambda/<workerID>/runtime/... for the workers.
+func (e *emulator) runtimeAPI(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.TrimPrefix(r.URL.Path, "/lambda/"), "/")
+	if len(path) < 3 || path[1] != "runtime" {
+		http.NotFound(w, r)
+		return
+	}
+	e.mu.Lock()
+	fn, ok := e.workers[path[0]]
+	e.mu.Unlock()
+	if !ok {
+		http.Error(w, "unknown worker", http.StatusNotFound)
+		return
+	}
+	workerID := path[0]
+	rest := path[2:]
+	switch {
+	case len(rest) == 2 && rest[0] == "invocation" && rest[1] == "next":
+		var inv *invocation
+		select {
+		case inv = <-fn.queue:
+		case <-r.Context().Done():
+			return
+		}
+		e.mu.Lock()
+		fn.inflight[inv.requestID] = inv
+		fn.current = inv.requestID
+		e.mu.Unlock()
+		w.Header().Set("Lambda-Runtime-Aws-Request-Id", inv.requestID)
+		w.Header().Set("Lambda-Runtime-Deadline-Ms", fmt.Sprint(time.Now().Add(invocationTimeout).UnixMilli()))
+		w.Header().Set("Lambda-Runtime-Invoked-Function-Arn", "arn:aws:lambda:offline:000000000000:function:"+fn.id)
+		w.Header().Set("Lambda-Runtime-Trace-Id", "Root=1-"+inv.requestID)
+		bus.Publish(&aws.FunctionInvokedEvent{
+			FunctionID: fn.id,
+			WorkerID:   workerID,
+			RequestID:  inv.requestID,
+			Input:      inv.payload,
+			Context:    aws.InvocationContext(w.Header()),
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(inv.payload)
+	case len(rest) == 3 && rest[0] == "invocation" && (rest[2] == "response" || rest[2] == "error"):
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		requestID := rest[1]
+		e.mu.Lock()
+		inv, ok := fn.inflight[requestID]
+		delete(fn.inflight, requestID)
+		e.mu.Unlock()
+		if !ok {
+			http.Error(w, "unknown request", http.StatusNotFound)
+			return
+		}
+		if rest[2] == "response" {
+			bus.Publish(&aws.FunctionResponseEvent{
+				FunctionID: fn.id,
+				WorkerID:   workerID,
+				RequestID:  requestID,
+				Output:     body,
+			})
+		} else {
+			evt := &aws.FunctionErrorEvent{
+				FunctionID: fn.id,
+				WorkerID:   workerID,
+				RequestID:  requestID,
+			}
+			json.Unmarshal(body, evt)
+			bus.Publish(evt)
+		}
+		inv.result <- result{output: body, failed: rest[2] == "error"}
+		w.WriteHeader(http.StatusAccepted)
+	case len(rest) == 2 && rest[0] == "init" && rest[1] == "error":
+		body, _ := io.ReadAll(r.Body)
+		evt := &aws.FunctionErrorEvent{FunctionID: fn.id, WorkerID: workerID}
+		json.Unmarshal(body, evt)
+		bus.Publish(evt)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func errorPayload(errorType string, message string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"errorType":    errorType,
+		"errorMessage": message,
+	})
+	return data
+}
+
+// requestID is a random UUID like the ones Lambda uses.
+func requestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 	"github.com/sst/ion/cmd/sst/mosaic/deployer"
 	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/runtime"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
@@ -28,6 +29,7 @@ type footer struct {
 	pending   []*apitype.ResourcePreEvent
 	skipped   int
 	cancelled bool
+	builds    *runtime.BuildProgressEvent
 
 	spinner int
 
@@ -147,6 +149,7 @@ func (m *footer) Reset() {
 	m.complete = nil
 	m.summary = false
 	m.cancelled = false
+	m.builds = nil
 }
 
 func (m *footer) Update(msg any) {
@@ -195,6 +198,8 @@ func (m *footer) Update(msg any) {
 		if msg.Metadata.Op != apitype.OpSame && msg.Metadata.Op != apitype.OpRead {
 			m.pending = append(m.pending, msg)
 		}
+	case *runtime.BuildProgressEvent:
+		m.builds = msg
 	case *apitype.SummaryEvent:
 		m.summary = true
 	case *apitype.ResOutputsEvent:
@@ -256,6 +261,9 @@ func (m *footer) View(width int) string {
 		}
 		result = append(result, fmt.Sprintf("%s  %-11s %s", spinner, label, m.formatURN(r.Metadata.URN)))
 	}
+	if m.builds != nil && m.builds.Done < m.builds.Total {
+		result = append(result, fmt.Sprintf("%s  %-11s %s", spinner, "Building", TEXT_DIM.Render(fmt.Sprintf("%d of %d functions", m.builds.Done, m.builds.Total))))
+	}
 	label := "Finalizing"
 	if !m.summary {
 		if m.mode == ProgressModeDiff {
@@ -0,0 +1,103 @@
+package ui
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/mosaic/aws"
+	"github.com/sst/ion/pkg/runtime"
+)
+
+// The lines of function events are tagged with an OSC 7770 sequence when the
+// ui runs in the multiplexer, so it can filter them by function and level.
+const (
+	tagStart = "\x1b]7770;"
+	tagEnd   = "\x07"
+)
+
+func WithTags(u *Options) {
+	u.Tags = true
+}
+
+// Tag is how the multiplexer reads the tag of a line back.
+type Tag struct {
+	FunctionID string
+	Level      string
+}
+
+func ParseTag(input string) Tag {
+	values, _ := url.ParseQuery(input)
+	return Tag{
+		FunctionID: values.Get("function"),
+		Level:      values.Get("level"),
+	}
+}
+
+func (t Tag) String() string {
+	if t.FunctionID == "" && t.Level == "" {
+		return ""
+	}
+	values := url.Values{}
+	if t.FunctionID != "" {
+		values.Set("function", t.FunctionID)
+	}
+	if t.Level != "" {
+		values.Set("level", t.Level)
+	}
+	return values.Encode()
+}
+
+func eventTag(unknown interface{}) Tag {
+	switch evt := unknown.(type) {
+	case *aws.FunctionInvokedEvent:
+		return Tag{FunctionID: evt.FunctionID}
+	case *aws.FunctionResponseEvent:
+		return Tag{FunctionID: evt.FunctionID}
+	case *aws.FunctionLogEvent:
+		return Tag{FunctionID: evt.FunctionID, Level: LogLevel(evt.Line)}
+	case *aws.FunctionErrorEvent:
+		return Tag{FunctionID: evt.FunctionID, Level: "error"}
+	case *aws.FunctionBuildEvent:
+		if len(evt.Errors) > 0 {
+			return Tag{FunctionID: evt.FunctionID, Level: "error"}
+		}
+		return Tag{FunctionID: evt.FunctionID}
+	case *runtime.InspectEvent:
+		return Tag{FunctionID: evt.FunctionID}
+	}
+	return Tag{}
+}
+
+// Levels are the log levels from the least to the most severe.
+var Levels = []string{"debug", "info", "warn", "error"}
+
+var levelAliases = map[string]string{
+	"trace":   "debug",
+	"debug":   "debug",
+	"info":    "info",
+	"warn":    "warn",
+	"warning": "warn",
+	"error":   "error",
+	"fatal":   "error",
+}
+
+var (
+	// a level field in JSON or logfmt, like "level":"debug" or level=debug
+	levelField = regexp.MustCompile(`(?i)"?(?:level|lvl|severity)"?\s*[:=]\s*"?([a-z]+)`)
+	// a level word at the start, like DEBUG, [debug] or the Lambda format of
+	// timestamp, request id, and level separated by tabs
+	levelWord = regexp.MustCompile(`(?i)^(?:\S+\t\S+\t)?[\[(]?(trace|debug|info|warning|warn|error|fatal)[\])]?(?:[\s:]|$)`)
+)
+
+// LogLevel guesses the level of a log line, it is empty if there is none.
+func LogLevel(line string) string {
+	line = strings.TrimSpace(line)
+	if match := levelWord.FindStringSubmatch(line); match != nil {
+		return levelAliases[strings.ToLower(match[1])]
+	}
+	if match := levelField.FindStringSubmatch(line); match != nil {
+		return levelAliases[strings.ToLower(match[1])]
+	}
+	return ""
+}
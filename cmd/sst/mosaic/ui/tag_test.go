@@ -0,0 +1,33 @@
+package ui
+
+import "testing"
+
+func TestLogLevel(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{"DEBUG connecting", "debug"},
+		{"[warn] slow query", "warn"},
+		{"2024-01-01T00:00:00.000Z\tabc-123\tERROR\tfailed", "error"},
+		{`{"level":"info","msg":"started"}`, "info"},
+		{"time=1 level=warning msg=retry", "warn"},
+		{"hello world", ""},
+		{"information is not a level", ""},
+	}
+	for _, test := range tests {
+		if level := LogLevel(test.line); level != test.expected {
+			t.Errorf("%q: expected %q, got %q", test.line, test.expected, level)
+		}
+	}
+}
+
+func TestTag(t *testing.T) {
+	tag := Tag{FunctionID: "MyFunction", Level: "debug"}
+	if parsed := ParseTag(tag.String()); parsed != tag {
+		t.Errorf("Expected %v, got %v", tag, parsed)
+	}
+	if (Tag{}).String() != "" {
+		t.Errorf("Expected an empty tag")
+	}
+}
@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+func formatTiming(duration time.Duration) string {
+	return duration.Round(100 * time.Millisecond).String()
+}
+
+func (u *UI) printTimings(timings *provider.SummaryTimings) {
+	u.println(
+		TEXT_DIM.Render(fmt.Sprintf(
+			"   Took %s: config %s, providers %s, build %s, engine %s",
+			formatTiming(timings.Total),
+			formatTiming(timings.Config),
+			formatTiming(timings.Providers),
+			formatTiming(timings.Build),
+			formatTiming(timings.Engine),
+		)),
+	)
+}
+
+// printProfile is the detail view of sst deploy --profile.
+func (u *UI) printProfile(timings *provider.SummaryTimings) {
+	u.blank()
+	u.println(TEXT_NORMAL_BOLD.Render("   Profile"))
+	for _, phase := range []struct {
+		name     string
+		duration time.Duration
+	}{
+		{"Config", timings.Config},
+		{"Providers", timings.Providers},
+		{"Build", timings.Build},
+		{"Engine", timings.Engine},
+		{"Total", timings.Total},
+	} {
+		u.println(
+			TEXT_DIM_BOLD.Render(fmt.Sprintf("   %-12s", phase.name)),
+			TEXT_NORMAL.Render(formatTiming(phase.duration)),
+		)
+	}
+	if len(timings.Functions) > 0 {
+		u.blank()
+		u.println(TEXT_NORMAL_BOLD.Render("   Functions"))
+		for _, item := range timings.Functions {
+			u.println(
+				TEXT_DIM_BOLD.Render(fmt.Sprintf("   %-12s", formatTiming(item.Duration))),
+				TEXT_NORMAL.Render(u.functionName(item.Name)),
+				TEXT_DIM.Render(" "+item.Op),
+			)
+		}
+	}
+	if len(timings.Resources) > 0 {
+		u.blank()
+		u.println(TEXT_NORMAL_BOLD.Render("   Slowest resources"))
+		for _, item := range timings.Resources {
+			u.println(
+				TEXT_DIM_BOLD.Render(fmt.Sprintf("   %-12s", formatTiming(item.Duration))),
+				TEXT_NORMAL.Render(u.FormatURN(item.Name)),
+				TEXT_DIM.Render(" "+item.Op),
+			)
+		}
+	}
+}
@@ -20,7 +20,12 @@ import (
 	"github.com/sst/ion/cmd/sst/mosaic/cloudflare"
 	"github.com/sst/ion/cmd/sst/mosaic/deployer"
 	"github.com/sst/ion/cmd/sst/mosaic/ui/common"
+	"github.com/sst/ion/cmd/sst/mosaic/watcher"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/runtime"
+	"github.com/sst/ion/pkg/server"
 
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -55,12 +60,19 @@ type UI struct {
 	hasHeader  bool
 	options    *Options
 	log        *os.File
+	// tag is the tag of the lines of the event being printed
+	tag Tag
 }
 
 type Options struct {
 	Silent bool
 	Log    *os.File
 	Dev    bool
+	Tags   bool
+	// Profile prints where the time of an update went after it completes
+	Profile bool
+	// Dashboard is the url of the dashboard of the dev server
+	Dashboard string
 }
 
 type Option func(*Options)
@@ -73,6 +85,16 @@ func WithDev(u *Options) {
 	u.Dev = true
 }
 
+func WithProfile(u *Options) {
+	u.Profile = true
+}
+
+func WithDashboard(url string) Option {
+	return func(opts *Options) {
+		opts.Dashboard = url
+	}
+}
+
 func WithLog(file *os.File) Option {
 	return func(opts *Options) {
 		opts.Log = file
@@ -114,16 +136,19 @@ func (u *UI) printf(tmpl string, args ...interface{}) {
 func (u *UI) println(args ...interface{}) {
 	u.buffer = append(u.buffer, args...)
 	line := fmt.Sprint(u.buffer...)
+	if u.log != nil {
+		stripped := ansi.Strip(line)
+		u.log.WriteString(stripped + "\n")
+	}
+	if tag := u.tag.String(); u.options.Tags && tag != "" {
+		line = tagStart + tag + tagEnd + line + tagStart + tagEnd
+	}
 	if u.footer == nil {
 		fmt.Println(line)
 	}
 	if u.footer != nil {
 		u.footer.Send(lineMsg(line))
 	}
-	if u.log != nil {
-		stripped := ansi.Strip(line)
-		u.log.WriteString(stripped + "\n")
-	}
 	u.buffer = []interface{}{}
 	u.hasBlank = false
 }
@@ -145,9 +170,16 @@ func (u *UI) reset() {
 }
 
 func (u *UI) Event(unknown interface{}) {
+	if output.Enabled() {
+		output.Event(unknown)
+	}
 	if u.footer != nil {
 		defer u.footer.Send(unknown)
 	}
+	if u.options.Tags {
+		u.tag = eventTag(unknown)
+		defer func() { u.tag = Tag{} }()
+	}
 	switch evt := unknown.(type) {
 
 	case *common.StdoutEvent:
@@ -163,6 +195,15 @@ func (u *UI) Event(unknown interface{}) {
 		u.printEvent(u.getColor(evt.WorkerID), "Done", formattedDuration)
 
 	case *aws.FunctionLogEvent:
+		if !evt.Time.IsZero() {
+			// each log stream is a function instance so name it the first time
+			if _, ok := u.workerTime[evt.WorkerID]; !ok {
+				u.workerTime[evt.WorkerID] = evt.Time
+				u.printEvent(u.getColor(evt.WorkerID), TEXT_NORMAL_BOLD.Render(fmt.Sprintf("%-11s", "Logs")), u.functionName(evt.FunctionID))
+			}
+			u.printEvent(u.getColor(evt.WorkerID), evt.Time.Local().Format("15:04:05"), strings.Split(evt.Line, "\n")...)
+			return
+		}
 		duration := time.Since(u.workerTime[evt.WorkerID]).Round(time.Millisecond)
 		formattedDuration := fmt.Sprintf("%.9s", fmt.Sprintf("+%v", duration))
 		u.printEvent(u.getColor(evt.WorkerID), formattedDuration, evt.Line)
@@ -177,6 +218,9 @@ func (u *UI) Event(unknown interface{}) {
 		}
 		u.printEvent(TEXT_SUCCESS, "Build", u.functionName(evt.FunctionID))
 
+	case *runtime.InspectEvent:
+		u.printEvent(u.getColor(evt.WorkerID), TEXT_NORMAL_BOLD.Render(fmt.Sprintf("%-11s", "Debugger")), fmt.Sprintf("%s listening on 127.0.0.1:%d", u.functionName(evt.FunctionID), evt.Port))
+
 	case *aws.FunctionErrorEvent:
 		u.printEvent(u.getColor(evt.WorkerID), TEXT_DANGER.Render(fmt.Sprintf("%-11s", "Error")), u.functionName(evt.FunctionID))
 		u.printEvent(u.getColor(evt.WorkerID), "", evt.ErrorMessage)
@@ -229,6 +273,12 @@ func (u *UI) Event(unknown interface{}) {
 		}
 		u.blank()
 
+	case *project.SecretRotationEvent:
+		u.printEvent(TEXT_WARNING, "Secrets", strings.Join(evt.Names, ", ")+" are past due for rotation, run `sst secret rotate`")
+
+	case *project.SecretConflictEvent:
+		u.printEvent(TEXT_WARNING, "Secrets", strings.Join(evt.Names, ", ")+" are set in "+evt.File+" and with `sst secret set`, the values in "+evt.File+" are used")
+
 	case *project.BuildFailedEvent:
 		u.reset()
 		u.printEvent(TEXT_DANGER, "Error", evt.Error)
@@ -337,6 +387,56 @@ func (u *UI) Event(unknown interface{}) {
 			u.println(strings.TrimRightFunc(ansi.Strip(evt.Message), unicode.IsSpace))
 		}
 
+	case *provider.AwsLoginEvent:
+		u.printEvent(TEXT_WARNING, "AWS", "Your SSO session for "+evt.Profile+" expired, open "+evt.URL+" and confirm the code "+evt.Code+" to continue")
+
+	case *provider.AwsLoginCompleteEvent:
+		u.printEvent(TEXT_SUCCESS, "AWS", "Logged in to "+evt.Profile)
+
+	case *provider.CredentialsHealthEvent:
+		switch evt.Status {
+		case provider.CredentialsExpiring:
+			u.printEvent(TEXT_WARNING, "Credentials", "The "+evt.Provider+" credentials expire in "+time.Until(evt.ExpiresAt).Round(time.Second).String())
+		case provider.CredentialsRefreshed:
+			u.printEvent(TEXT_SUCCESS, "Credentials", "Refreshed the "+evt.Provider+" credentials")
+		case provider.CredentialsFailed:
+			u.printEvent(TEXT_DANGER, "Credentials", "Could not refresh the "+evt.Provider+" credentials: "+evt.Error)
+		}
+
+	case *watcher.WatcherStatsEvent:
+		if evt.Failed > 0 {
+			u.printEvent(TEXT_WARNING, "Watcher", fmt.Sprintf("Could not watch %d of %d directories, changes to them will not be picked up. Ignore them with watch.ignore or raise fs.inotify.max_user_watches.", evt.Failed, evt.Dirs+evt.Failed))
+		}
+
+	case *server.LogEvent:
+		message := evt.Message
+		for _, key := range []string{"err", "error"} {
+			if value, ok := evt.Attrs[key]; ok {
+				message += ": " + value
+			}
+		}
+		if evt.Level == slog.LevelError.String() {
+			u.printEvent(TEXT_DANGER, "Error", message)
+			break
+		}
+		u.printEvent(TEXT_WARNING, "Warning", message)
+
+	case *project.TunnelStatusEvent:
+		switch evt.State {
+		case "connected":
+			if evt.Reconnects > 0 {
+				u.printEvent(TEXT_SUCCESS, "Tunnel", "Reconnected")
+			}
+		case "reconnecting":
+			u.printEvent(TEXT_WARNING, "Tunnel", "Connection lost, reconnecting: "+evt.Error)
+		case "stopped":
+			message := "Stopped"
+			if evt.Error != "" {
+				message += ": " + evt.Error
+			}
+			u.printEvent(TEXT_DANGER, "Tunnel", message)
+		}
+
 	case *project.ProviderDownloadEvent:
 		u.printEvent(TEXT_INFO, "Info", "Downloading provider "+evt.Name+" v"+evt.Version)
 		break
@@ -449,6 +549,12 @@ func (u *UI) Event(unknown interface{}) {
 				}
 			}
 		}
+		if evt.Timings != nil {
+			u.printTimings(evt.Timings)
+			if u.options.Profile {
+				u.printProfile(evt.Timings)
+			}
+		}
 		u.blank()
 	case *cloudflare.WorkerBuildEvent:
 		if len(evt.Errors) > 0 {
@@ -577,6 +683,12 @@ func (u *UI) header(version, app, stage string) {
 			TEXT_DIM.Render("https://console.sst.dev/local/"+app+"/"+stage),
 		)
 	}
+	if u.options.Dashboard != "" {
+		u.println(
+			TEXT_NORMAL_BOLD.Render(fmt.Sprintf("   %-12s", "Dashboard:")),
+			TEXT_DIM.Render(u.options.Dashboard),
+		)
+	}
 	u.blank()
 	u.hasHeader = true
 }
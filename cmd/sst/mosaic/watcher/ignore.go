@@ -0,0 +1,122 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	ignore "github.com/sabhiram/go-gitignore"
+)
+
+// DefaultIgnore is always ignored, before the .gitignore files and the
+// patterns in watch.ignore so they can be negated there.
+var DefaultIgnore = []string{
+	".*/",
+	"node_modules/",
+	"__snapshots__/",
+	"*.snap",
+	"coverage/",
+	"*.log",
+	".DS_Store",
+	"*.swp",
+	"*~",
+	// build output
+	"dist/",
+	"build/",
+	"out/",
+	"target/",
+	"cdk.out/",
+	"__pycache__/",
+	"*.pyc",
+}
+
+// envFiles are watched even though they are usually in .gitignore.
+var envFiles = []string{"!.env", "!.env.*"}
+
+type matcher struct {
+	root      string
+	patterns  []string
+	gitignore []string
+	ignore    *ignore.GitIgnore
+}
+
+func newMatcher(root string, patterns []string) *matcher {
+	m := &matcher{
+		root:     root,
+		patterns: patterns,
+	}
+	m.compile()
+	return m
+}
+
+func (m *matcher) compile() {
+	lines := append([]string{}, DefaultIgnore...)
+	lines = append(lines, m.gitignore...)
+	lines = append(lines, envFiles...)
+	lines = append(lines, m.patterns...)
+	m.ignore = ignore.CompileIgnoreLines(lines...)
+}
+
+// AddGitignore adds the patterns of the .gitignore in dir, if it has one.
+func (m *matcher) AddGitignore(dir string) bool {
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(m.root, dir)
+	if err != nil {
+		return false
+	}
+	m.gitignore = append(m.gitignore, gitignoreLines(filepath.ToSlash(rel), string(data))...)
+	m.compile()
+	return true
+}
+
+// gitignoreLines makes the patterns of the .gitignore in dir, relative to
+// the root, match from the root.
+func gitignoreLines(dir string, data string) []string {
+	result := []string{}
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, " \r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if dir == "." {
+			result = append(result, line)
+			continue
+		}
+		negate := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(line, "!")
+		// a pattern with a slash before its end is anchored to its directory
+		if strings.Contains(strings.TrimSuffix(line, "/"), "/") {
+			line = "/" + dir + "/" + strings.TrimPrefix(line, "/")
+		} else {
+			line = "/" + dir + "/**/" + line
+		}
+		if negate {
+			line = "!" + line
+		}
+		result = append(result, line)
+	}
+	return result
+}
+
+// Ignored matches path, relative to the root or absolute, the way git matches
+// a .gitignore.
+func (m *matcher) Ignored(path string, dir bool) bool {
+	if filepath.IsAbs(path) {
+		rel, err := filepath.Rel(m.root, path)
+		if err != nil {
+			return false
+		}
+		path = rel
+	}
+	if path == "." {
+		return false
+	}
+	path = filepath.ToSlash(path)
+	if dir {
+		path += "/"
+	}
+	return m.ignore.MatchesPath(path)
+}
@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMatcher(t *testing.T) {
+	m := newMatcher("/app", []string{"generated/", "!.storybook/", "*.gen.ts"})
+	for _, tc := range []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{"/app", true, false},
+		{"/app/src", true, false},
+		{"/app/src/index.ts", false, false},
+		{"/app/.git", true, true},
+		{"/app/infra/.terraform", true, true},
+		{"/app/.storybook", true, false},
+		{"/app/packages/web/node_modules", true, true},
+		{"/app/src/__snapshots__", true, true},
+		{"/app/src/index.test.ts.snap", false, true},
+		{"/app/generated", true, true},
+		{"/app/packages/api/generated", true, true},
+		{"/app/src/schema.gen.ts", false, true},
+		{"/app/.env", false, false},
+		{"src/index.ts", false, false},
+	} {
+		if got := m.Ignored(tc.path, tc.dir); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestMatcherGitignore(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, ".gitignore"), []byte("# generated\n*.gen.go\n.env\ntmp/\n"), 0644)
+	web := filepath.Join(root, "packages", "web")
+	os.MkdirAll(web, 0755)
+	os.WriteFile(filepath.Join(web, ".gitignore"), []byte("generated\n/public/assets\n!keep.gen.go\n"), 0644)
+
+	m := newMatcher(root, []string{"!out/"})
+	if !m.AddGitignore(root) || !m.AddGitignore(web) {
+		t.Fatal("Expected both .gitignore files to be read")
+	}
+	if m.AddGitignore(filepath.Join(root, "packages")) {
+		t.Error("Expected no .gitignore in packages")
+	}
+	for _, tc := range []struct {
+		path string
+		dir  bool
+		want bool
+	}{
+		{"api/schema.gen.go", false, true},
+		{"api/schema.go", false, false},
+		{"tmp", true, true},
+		{".env", false, false},
+		{"packages/web/src/generated", true, true},
+		{"packages/api/generated", true, false},
+		{"packages/web/public/assets", true, true},
+		{"packages/web/src/public/assets", true, false},
+		{"packages/web/keep.gen.go", false, false},
+		{"out", true, false},
+		{"dist", true, true},
+	} {
+		if got := m.Ignored(tc.path, tc.dir); got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.path, got, tc.want)
+		}
+	}
+}
@@ -2,61 +2,111 @@ package watcher
 
 import (
 	"context"
+	"io/fs"
 	"log/slog"
-	"os"
 	"path/filepath"
-	"strings"
 	"time"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/metrics"
 )
 
 type FileChangedEvent struct {
 	Path string
 }
 
-func Start(ctx context.Context, root string) error {
-	defer slog.Info("watcher done")
-	slog.Info("starting watcher", "root", root)
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		return err
-	}
-	err = watcher.AddWith(root)
-	if err != nil {
-		return err
-	}
-	ignoreSubstrings := []string{"node_modules"}
+// WatcherStatsEvent is what the watcher registered, published once it is
+// watching and again when directories are added. It is there to debug a
+// watcher that uses too much cpu or misses changes.
+type WatcherStatsEvent struct {
+	Root string
+	// Dirs is how many directories are watched, Skipped how many were left
+	// out by the ignore patterns and Failed how many could not be watched,
+	// like when the limit of inotify watches is reached
+	Dirs       int
+	Skipped    int
+	Failed     int
+	Gitignores int
+	Duration   time.Duration
+}
+
+// batchWindow is how long changes are collected before they are published,
+// tools that write many files at once trigger a single rebuild.
+const batchWindow = 100 * time.Millisecond
 
-	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+type watch struct {
+	watcher *fsnotify.Watcher
+	matcher *matcher
+	stats   WatcherStatsEvent
+}
+
+// add watches dir and the directories under it that are not ignored, the
+// .gitignore of each of them is read on the way.
+func (w *watch) add(dir string) error {
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
-			return err
-		}
-		if info.IsDir() {
-			if strings.HasPrefix(info.Name(), ".") {
+			// a directory that can not be read is not watched
+			if entry != nil && entry.IsDir() && path != dir {
+				w.stats.Failed++
 				return filepath.SkipDir
 			}
-			for _, substring := range ignoreSubstrings {
-				if strings.Contains(path, substring) {
-					return filepath.SkipDir
-				}
-			}
-			slog.Info("watching", "path", path)
-			err = watcher.Add(path)
-			if err != nil {
-				return err
+			return err
+		}
+		if !entry.IsDir() {
+			return nil
+		}
+		if w.matcher.Ignored(path, true) {
+			w.stats.Skipped++
+			return filepath.SkipDir
+		}
+		if w.matcher.AddGitignore(path) {
+			w.stats.Gitignores++
+		}
+		slog.Debug("watching", "path", path)
+		if err := w.watcher.Add(path); err != nil {
+			if w.stats.Failed == 0 {
+				slog.Error("failed to watch", "path", path, "err", err)
 			}
+			w.stats.Failed++
+			return nil
 		}
+		w.stats.Dirs++
 		return nil
 	})
+}
+
+// Start watches root for changes. Directories matching ignore, the defaults
+// or a .gitignore, are not watched at all and changes to files matching them
+// are dropped.
+func Start(ctx context.Context, root string, ignore []string) error {
+	defer slog.Info("watcher done")
+	slog.Info("starting watcher", "root", root)
+	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		return err
 	}
+	defer watcher.Close()
+	w := &watch{
+		watcher: watcher,
+		matcher: newMatcher(root, ignore),
+		stats:   WatcherStatsEvent{Root: root},
+	}
+	start := time.Now()
+	if err := w.add(root); err != nil {
+		return err
+	}
+	w.stats.Duration = time.Since(start)
+	slog.Info("watcher ready", "dirs", w.stats.Dirs, "skipped", w.stats.Skipped, "failed", w.stats.Failed, "duration", w.stats.Duration)
+	stats := w.stats
+	bus.Publish(&stats)
 
 	headFile := filepath.Join(root, ".git/HEAD")
 	watcher.Add(headFile)
-	limiter := map[string]time.Time{}
+	pending := map[string]bool{}
+	order := []string{}
+	flush := time.NewTimer(0)
+	<-flush.C
 	for {
 		select {
 		case event, ok := <-watcher.Events:
@@ -66,15 +116,41 @@ func Start(ctx context.Context, root string) error {
 			if event.Name == headFile {
 				return nil
 			}
+			if event.Op&fsnotify.Create != 0 && !w.matcher.Ignored(event.Name, true) {
+				// new directories are not watched by the ones they are in
+				before := w.stats.Dirs
+				if err := w.add(event.Name); err == nil && w.stats.Dirs > before {
+					stats := w.stats
+					bus.Publish(&stats)
+				}
+			}
+			if w.matcher.Ignored(event.Name, false) {
+				continue
+			}
 			if event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
-				slog.Info("ignoring file event", "path", event.Name, "op", event.Op)
+				slog.Debug("ignoring file event", "path", event.Name, "op", event.Op)
 				continue
 			}
 			slog.Info("file event", "path", event.Name, "op", event.Op)
-			if time.Since(limiter[event.Name]) > 500*time.Millisecond {
-				limiter[event.Name] = time.Now()
-				bus.Publish(&FileChangedEvent{Path: event.Name})
+			if len(pending) == 0 {
+				flush.Reset(batchWindow)
+			}
+			if !pending[event.Name] {
+				pending[event.Name] = true
+				order = append(order, event.Name)
+			}
+		case <-flush.C:
+			for _, path := range order {
+				metrics.WatcherEvents.Inc()
+				bus.Publish(&FileChangedEvent{Path: path})
+			}
+			pending = map[string]bool{}
+			order = nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
 			}
+			slog.Error("watcher error", "err", err)
 		case <-ctx.Done():
 			return nil
 		}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+)
+
+var CmdOutput = &cli.Command{
+	Name: "output",
+	Description: cli.Description{
+		Short: "Print the outputs of your app",
+		Long: strings.Join([]string{
+			"Print the outputs returned from the `run` function of your `sst.config.ts`, as they were last deployed.",
+			"",
+			"```bash frame=\"none\"",
+			"sst output --stage production",
+			"```",
+			"",
+			"Use `--format` to print them in a format other tools can read.",
+			"",
+			"- `json`: a JSON object, the default.",
+			"- `dotenv`: `KEY=value` lines for a `.env` file.",
+			"- `shell`: `export KEY='value'` lines to `eval` in a shell.",
+			"- `github`: lines to append to `$GITHUB_OUTPUT` in GitHub Actions.",
+			"",
+			"```bash frame=\"none\"",
+			"sst output --format github >> $GITHUB_OUTPUT",
+			"```",
+			"",
+			"Or pass in `--key` to print a single value in scripts.",
+			"",
+			"```bash frame=\"none\"",
+			"curl $(sst output --key api)",
+			"```",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "format",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The format to print in",
+				Long:  "The format to print the outputs in, one of `json`, `dotenv`, `shell`, or `github`.",
+			},
+		},
+		{
+			Name: "key",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Print a single output",
+				Long:  "Print only the value of this output.",
+			},
+		},
+	},
+	Run: CmdOutputRun,
+}
+
+func CmdOutputRun(c *cli.Cli) error {
+	format := c.String("format")
+	if format == "" {
+		format = "json"
+	}
+	if format != "json" && format != "dotenv" && format != "shell" && format != "github" {
+		return util.NewReadableError(nil, "Invalid --format "+format+", use one of json, dotenv, shell or github")
+	}
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+	complete, err := p.GetCompleted(c.Context)
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the state of stage "+p.App().Stage)
+	}
+	outputs := complete.Outputs
+
+	if key := c.String("key"); key != "" {
+		value, ok := outputs[key]
+		if !ok {
+			return util.NewReadableError(nil, "No output named "+key+" in stage "+p.App().Stage)
+		}
+		output.Result(value)
+		fmt.Println(outputString(value))
+		return nil
+	}
+
+	output.Result(outputs)
+	if format == "json" {
+		data, err := json.MarshalIndent(outputs, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+	keys := make([]string, 0, len(outputs))
+	for key := range outputs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := outputString(outputs[key])
+		switch format {
+		case "dotenv":
+			fmt.Printf("%s=%s\n", envKey(key), strings.ReplaceAll(value, "\n", "\\n"))
+		case "shell":
+			fmt.Printf("export %s='%s'\n", envKey(key), strings.ReplaceAll(value, "'", `'\''`))
+		case "github":
+			if strings.Contains(value, "\n") {
+				delimiter := "SST_OUTPUT_" + envKey(key)
+				fmt.Printf("%s<<%s\n%s\n%s\n", key, delimiter, value, delimiter)
+				continue
+			}
+			fmt.Printf("%s=%s\n", key, value)
+		}
+	}
+	return nil
+}
+
+// outputString prints strings as is and everything else as JSON.
+func outputString(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
+
+var invalidEnvKey = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+func envKey(key string) string {
+	return invalidEnvKey.ReplaceAllString(key, "_")
+}
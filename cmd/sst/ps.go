@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/server"
+)
+
+var CmdPs = &cli.Command{
+	Name: "ps",
+	Description: cli.Description{
+		Short: "List running servers",
+		Long: strings.Join([]string{
+			"List the servers started by `sst dev` across all your projects.",
+			"",
+			"For each server it prints the project path, stage, process ID, uptime, and the number of connected clients.",
+			"",
+			"Pass in `--kill` to stop all of them.",
+			"",
+			"```bash frame=\"none\"",
+			"sst ps --kill",
+			"```",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "kill",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Stop the listed servers",
+				Long:  "Stop all the listed servers.",
+			},
+		},
+	},
+	Run: CmdPsRun,
+}
+
+func CmdPsRun(c *cli.Cli) error {
+	processes, err := server.ListProcesses()
+	if err != nil {
+		return err
+	}
+	live := []*server.Process{}
+	for _, proc := range processes {
+		probed, err := server.Probe(c.Context, proc)
+		if err != nil {
+			// the server died without cleaning up after itself
+			server.RemoveProcess(proc)
+			continue
+		}
+		live = append(live, probed)
+	}
+	output.Result(live)
+	if len(live) == 0 {
+		fmt.Println("No servers running")
+		return nil
+	}
+
+	if c.Bool("kill") {
+		for _, proc := range live {
+			token, _ := proc.Token()
+			err := server.Stop(c.Context, proc.Addr, token)
+			if err != nil {
+				color.New(color.FgRed, color.Bold).Print("✕ ")
+				color.New(color.FgWhite).Println(" Could not stop", filepath.Dir(proc.Config), "/", proc.Stage+":", err)
+				continue
+			}
+			color.New(color.FgGreen, color.Bold).Print("✓ ")
+			color.New(color.FgWhite).Print(" Stopped: ")
+			color.New(color.FgWhite, color.Bold).Println(filepath.Dir(proc.Config), "/", proc.Stage)
+		}
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(w, "PROJECT\tSTAGE\tPID\tUPTIME\tCLIENTS")
+	for _, proc := range live {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%d\t%s\t%d\n",
+			filepath.Dir(proc.Config),
+			proc.Stage,
+			proc.PID,
+			time.Since(proc.Started).Round(time.Second),
+			proc.Clients,
+		)
+	}
+	return w.Flush()
+}
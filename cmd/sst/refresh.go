@@ -1,11 +1,10 @@
 package main
 
 import (
-	"strings"
-
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/server"
 	"golang.org/x/sync/errgroup"
@@ -18,11 +17,6 @@ func CmdRefresh(c *cli.Cli) error {
 	}
 	defer p.Cleanup()
 
-	target := []string{}
-	if c.String("target") != "" {
-		target = strings.Split(c.String("target"), ",")
-	}
-
 	var wg errgroup.Group
 	defer wg.Wait()
 	ui := ui.New(c.Context)
@@ -31,6 +25,9 @@ func CmdRefresh(c *cli.Cli) error {
 	wg.Go(func() error {
 		for evt := range events {
 			ui.Event(evt)
+			if complete, ok := evt.(*project.CompleteEvent); ok && !complete.Old {
+				output.Result(newStackResult(p, complete))
+			}
 		}
 		return nil
 	})
@@ -38,6 +35,7 @@ func CmdRefresh(c *cli.Cli) error {
 	if err != nil {
 		return err
 	}
+	s.OnCancel = c.Cancel
 	wg.Go(func() error {
 		defer c.Cancel()
 		return s.Start(c.Context, p)
@@ -45,10 +43,11 @@ func CmdRefresh(c *cli.Cli) error {
 	defer ui.Destroy()
 	defer c.Cancel()
 	err = p.Run(c.Context, &project.StackInput{
-		Command:    "refresh",
-		Target:     target,
-		ServerPort: s.Port,
-		Verbose:    c.Bool("verbose"),
+		Command:     "refresh",
+		Target:      parseTarget(c),
+		ServerPort:  s.Port,
+		ServerToken: s.Token,
+		Verbose:     c.Bool("verbose"),
 	})
 	if err != nil {
 		return err
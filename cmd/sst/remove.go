@@ -1,26 +1,66 @@
 package main
 
 import (
+	"bufio"
+	"os"
 	"strings"
 
+	"github.com/manifoldco/promptui"
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/server"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/term"
 )
 
+var forceUnprotectFlag = cli.Flag{
+	Name: "force-unprotect",
+	Type: "bool",
+	Description: cli.Description{
+		Short: "Delete protected resources",
+		Long:  "Delete resources even if they are protected. You are asked to type the name of the stage to confirm, pipe it in when there is no terminal.",
+	},
+}
+
+// confirmUnprotect asks the user to type the name of the stage before its
+// protected resources can be deleted.
+func confirmUnprotect(c *cli.Cli, stage string) error {
+	if !c.Bool("force-unprotect") {
+		return nil
+	}
+	refusal := "The name of the stage did not match, nothing was changed"
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if strings.TrimSpace(line) != stage {
+			return util.NewReadableError(nil, refusal)
+		}
+		return nil
+	}
+	prompt := promptui.Prompt{
+		Label: "‏‏‎ ‎This can delete protected resources, type " + stage + " to confirm",
+	}
+	result, err := prompt.Run()
+	if err != nil {
+		return util.NewReadableError(err, "")
+	}
+	if strings.TrimSpace(result) != stage {
+		return util.NewReadableError(nil, refusal)
+	}
+	return nil
+}
+
 func CmdRemove(c *cli.Cli) error {
 	p, err := c.InitProject()
 	if err != nil {
 		return err
 	}
 	defer p.Cleanup()
-
-	target := []string{}
-	if c.String("target") != "" {
-		target = strings.Split(c.String("target"), ",")
+	if err := confirmUnprotect(c, p.App().Stage); err != nil {
+		return err
 	}
 
 	var wg errgroup.Group
@@ -30,25 +70,33 @@ func CmdRemove(c *cli.Cli) error {
 	if err != nil {
 		return err
 	}
+	s.OnCancel = c.Cancel
 	wg.Go(func() error {
 		defer c.Cancel()
 		return s.Start(c.Context, p)
 	})
+	report := newGithubReport(p, "remove")
 	events := bus.SubscribeAll()
 	defer close(events)
 	wg.Go(func() error {
 		for evt := range events {
 			ui.Event(evt)
+			report.event(evt)
+			if complete, ok := evt.(*project.CompleteEvent); ok && !complete.Old {
+				output.Result(newStackResult(p, complete))
+			}
 		}
 		return nil
 	})
 	defer ui.Destroy()
 	defer c.Cancel()
 	err = p.Run(c.Context, &project.StackInput{
-		Command:    "remove",
-		Target:     target,
-		ServerPort: s.Port,
-		Verbose:    c.Bool("verbose"),
+		Command:        "remove",
+		Target:         parseTarget(c),
+		ServerPort:     s.Port,
+		ServerToken:    s.Token,
+		Verbose:        c.Bool("verbose"),
+		ForceUnprotect: c.Bool("force-unprotect"),
 	})
 	if err != nil {
 		return err
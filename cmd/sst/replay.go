@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/capture"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/server"
+)
+
+var CmdReplay = &cli.Command{
+	Name: "replay",
+	Description: cli.Description{
+		Short: "Replay an invocation captured by sst dev",
+		Long: strings.Join([]string{
+			"List the recent invocations of your functions in `sst dev` and run them again against your current code.",
+			"",
+			"```bash frame=\"none\"",
+			"sst replay",
+			"```",
+			"",
+			"This lists the last " + fmt.Sprint(capture.Capacity) + " invocations with their ID, function, and status. Pass in the ID of one to invoke the function again with the same payload and print the response.",
+			"",
+			"```bash frame=\"none\"",
+			"sst replay 8e5f1c2a-3b4d-4e6f-9a1b-2c3d4e5f6a7b",
+			"```",
+			"",
+			"The function is invoked through AWS, so it runs locally with the same permissions as the original invocation. In offline mode it is invoked through the offline server instead.",
+			"",
+			"To change the payload before replaying it, pass in `--payload` with the new one, or `--show` to print the captured payload, context, response, and logs.",
+			"",
+			"The invocations are kept by the server of `sst dev` and are gone once it stops.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name: "id",
+			Description: cli.Description{
+				Short: "The invocation to replay",
+				Long:  "The ID of the invocation to replay, as listed by `sst replay`.",
+			},
+		},
+	},
+	Flags: []cli.Flag{
+		serverFlag,
+		{
+			Name: "function",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Only list the invocations of a function",
+				Long:  "Only list the invocations of the function with this ID.",
+			},
+		},
+		{
+			Name: "payload",
+			Type: "string",
+			Description: cli.Description{
+				Short: "Replay with a different payload",
+				Long:  "Replay the invocation with this JSON payload instead of the captured one.",
+			},
+		},
+		{
+			Name: "show",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Print the invocation instead of replaying it",
+				Long:  "Print the captured payload, context, response, and logs of the invocation instead of replaying it.",
+			},
+		},
+	},
+	Run: CmdReplayRun,
+}
+
+func CmdReplayRun(c *cli.Cli) error {
+	var cfgPath, stage string
+	if c.String("server") == "" {
+		var err error
+		cfgPath, err = c.Discover()
+		if err != nil {
+			return err
+		}
+		stage, err = c.Stage(cfgPath)
+		if err != nil {
+			return err
+		}
+	}
+	url, token, err := discoverServer(c, cfgPath, stage)
+	if errors.Is(err, server.ErrServerNotFound) {
+		return util.NewReadableError(err, "Start `sst dev` first, the invocations are captured by it")
+	}
+	if err != nil {
+		return err
+	}
+
+	id := c.Positional(0)
+	if id == "" {
+		invocations, err := capture.List(c.Context, c.String("function"), url, token)
+		if err != nil {
+			return err
+		}
+		output.Result(invocations)
+		if len(invocations) == 0 {
+			fmt.Println("No invocations captured yet")
+			return nil
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "ID\tFUNCTION\tTIME\tSTATUS\tDURATION")
+		for _, item := range invocations {
+			status := "pending"
+			duration := "-"
+			if item.Done {
+				status = "ok"
+				duration = item.Duration.Round(time.Millisecond).String()
+			}
+			if item.Error != nil {
+				status = "error"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", item.ID, item.FunctionID, item.Time.Format(time.TimeOnly), status, duration)
+		}
+		return w.Flush()
+	}
+
+	if c.Bool("show") {
+		invocation, err := capture.Get(c.Context, id, url, token)
+		if errors.Is(err, capture.ErrInvocationNotFound) {
+			return util.NewReadableError(err, "No invocation "+id+" was captured, run `sst replay` to list them")
+		}
+		if err != nil {
+			return err
+		}
+		output.Result(invocation)
+		data, _ := json.MarshalIndent(invocation, "", "  ")
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var payload []byte
+	if value := c.String("payload"); value != "" {
+		if !json.Valid([]byte(value)) {
+			return util.NewReadableError(nil, "The payload is not valid JSON")
+		}
+		payload = []byte(value)
+	}
+	result, err := capture.Replay(c.Context, id, payload, url, token)
+	if errors.Is(err, capture.ErrInvocationNotFound) {
+		return util.NewReadableError(err, "No invocation "+id+" was captured, run `sst replay` to list them")
+	}
+	if err != nil {
+		return util.NewReadableError(err, "Could not replay the invocation: "+err.Error())
+	}
+	output.Result(result)
+	for _, line := range result.Logs {
+		color.New(color.FgHiBlack).Println(line)
+	}
+	if len(result.Logs) > 0 {
+		fmt.Println()
+	}
+	if result.Error != "" {
+		color.New(color.FgRed, color.Bold).Print("✕ ")
+		color.New(color.FgWhite).Println(" Replayed, the function failed:")
+	} else {
+		color.New(color.FgGreen, color.Bold).Print("✓ ")
+		color.New(color.FgWhite).Println(" Replayed:")
+	}
+	var pretty interface{}
+	if json.Unmarshal(result.Output, &pretty) == nil {
+		data, _ := json.MarshalIndent(pretty, "", "  ")
+		fmt.Println(string(data))
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/server"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/term"
+)
+
+var CmdRollback = &cli.Command{
+	Name: "rollback",
+	Description: cli.Description{
+		Short: "Roll back to a previous deploy",
+		Long: strings.Join([]string{
+			"Deploy your app back to the state it was in after a previous update.",
+			"",
+			"A checkpoint of the state is saved at the end of every update. Run this without a checkpoint to pick one.",
+			"",
+			"```bash frame=\"none\"",
+			"sst rollback --stage production",
+			"```",
+			"",
+			"The changes are shown before anything is deployed. Pass in `--dry-run` to stop there.",
+			"",
+			"```bash frame=\"none\"",
+			"sst rollback <checkpoint> --dry-run",
+			"```",
+			"",
+			"Resources are deployed with the inputs they had in the checkpoint, your `sst.config.ts` is not used. Files they reference, like the code of a function, are read from where they were built so run `sst deploy` once you've fixed the code.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name: "checkpoint",
+			Description: cli.Description{
+				Short: "The checkpoint to roll back to",
+				Long:  "The id of the checkpoint, or the id of the update that created it.",
+			},
+		},
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "dry-run",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Only show the changes",
+				Long:  "Show the changes a rollback would make without deploying them.",
+			},
+		},
+		{
+			Name: "yes",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Skip interactive confirmation",
+				Long:  "Skip the confirmation prompt before the rollback is deployed.",
+			},
+		},
+	},
+	Run: CmdRollbackRun,
+}
+
+// rollbackLimit is how many recent checkpoints are offered to pick from.
+const rollbackLimit = 20
+
+type rollbackCheckpoint struct {
+	provider.Checkpoint
+	Summary *provider.Summary `json:"summary,omitempty"`
+}
+
+func (c *rollbackCheckpoint) label() string {
+	label := c.Time.Local().Format("2006-01-02 15:04:05") + "  " + c.UpdateID
+	if c.Summary != nil {
+		label += fmt.Sprintf("  %d created, %d updated, %d deleted", c.Summary.ResourceCreated, c.Summary.ResourceUpdated, c.Summary.ResourceDeleted)
+		if len(c.Summary.Errors) > 0 {
+			label += fmt.Sprintf(", %d errors", len(c.Summary.Errors))
+		}
+		if c.Summary.Timings != nil {
+			label += ", took " + c.Summary.Timings.Total.Round(time.Second).String()
+		}
+	}
+	return label
+}
+
+// findCheckpoint matches id against the id of a checkpoint or the update that
+// created it.
+func findCheckpoint(history []provider.Checkpoint, id string) *provider.Checkpoint {
+	for i := range history {
+		if history[i].ID == id || history[i].UpdateID == id {
+			return &history[i]
+		}
+	}
+	return nil
+}
+
+func CmdRollbackRun(c *cli.Cli) error {
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+
+	history, err := provider.ListHistory(p.Backend(), p.App().Name, p.App().Stage)
+	if err != nil {
+		return util.NewReadableError(err, "Could not list the checkpoints of stage "+p.App().Stage)
+	}
+	if len(history) == 0 {
+		return util.NewReadableError(nil, "There are no checkpoints for stage "+p.App().Stage)
+	}
+
+	var selected *provider.Checkpoint
+	if id := c.Positional(0); id != "" {
+		selected = findCheckpoint(history, id)
+		if selected == nil {
+			return util.NewReadableError(nil, "No checkpoint "+id+" in stage "+p.App().Stage)
+		}
+	} else {
+		recent := []*rollbackCheckpoint{}
+		for _, item := range history[:min(len(history), rollbackLimit)] {
+			summary, _ := provider.GetSummary(p.Backend(), p.App().Name, p.App().Stage, item.UpdateID)
+			recent = append(recent, &rollbackCheckpoint{Checkpoint: item, Summary: summary})
+		}
+		if !term.IsTerminal(int(os.Stdin.Fd())) || output.Enabled() {
+			output.Result(recent)
+			for _, item := range recent {
+				fmt.Println(item.ID + "  " + item.label())
+			}
+			return nil
+		}
+		labels := []string{}
+		for i, item := range recent {
+			label := item.label()
+			if i == 0 {
+				label += "  (current)"
+			}
+			labels = append(labels, label)
+		}
+		prompt := promptui.Select{
+			Items:        labels,
+			Label:        "‏‏‎ ‎Roll back to",
+			HideSelected: true,
+			HideHelp:     true,
+			Size:         10,
+		}
+		index, _, err := prompt.Run()
+		if err != nil {
+			return util.NewReadableError(err, "")
+		}
+		selected = &recent[index].Checkpoint
+	}
+
+	var wg errgroup.Group
+	defer wg.Wait()
+	outputs := []*apitype.ResOutputsEvent{}
+	u := ui.New(c.Context)
+	s, err := server.New()
+	if err != nil {
+		return err
+	}
+	s.OnCancel = c.Cancel
+	wg.Go(func() error {
+		defer c.Cancel()
+		return s.Start(c.Context, p)
+	})
+	events := bus.SubscribeAll()
+	defer close(events)
+	wg.Go(func() error {
+		for evt := range events {
+			u.Event(evt)
+			switch evt := evt.(type) {
+			case *apitype.ResOutputsEvent:
+				outputs = append(outputs, evt)
+			case *project.CompleteEvent:
+				if !evt.Old && !c.Bool("dry-run") {
+					output.Result(newStackResult(p, evt))
+				}
+			}
+		}
+		return nil
+	})
+	defer u.Destroy()
+	defer c.Cancel()
+
+	input := &project.StackInput{
+		Command:     "diff",
+		Rollback:    selected.ID,
+		ServerPort:  s.Port,
+		ServerToken: s.Token,
+		Verbose:     c.Bool("verbose"),
+	}
+	err = p.Run(c.Context, input)
+	if err != nil {
+		return err
+	}
+	changes, summary := diffChanges(outputs)
+	printDiff(u, changes, summary)
+	if c.Bool("dry-run") || len(changes) == 0 {
+		output.Result(map[string]interface{}{
+			"checkpoint": selected,
+			"changes":    changes,
+			"summary":    summary,
+		})
+		return nil
+	}
+
+	ok, err := confirm(c, "Roll back to "+selected.Time.Local().Format(time.RFC822)+"?", "Pass in --yes to roll back without a confirmation prompt")
+	if err != nil || !ok {
+		return err
+	}
+
+	input.Command = "deploy"
+	err = p.Run(c.Context, input)
+	if err != nil {
+		return err
+	}
+	color.New(color.FgGreen, color.Bold).Print("✓ ")
+	color.New(color.FgWhite).Println(" Rolled back to " + selected.ID)
+	return nil
+}
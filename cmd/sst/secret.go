@@ -2,22 +2,94 @@ package main
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/fatih/color"
+	"github.com/joho/godotenv"
+	"github.com/manifoldco/promptui"
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/dev"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/project/provider"
 	"github.com/sst/ion/pkg/server"
 	"golang.org/x/sync/errgroup"
+	"golang.org/x/term"
 )
 
+var secretRevealFlag = cli.Flag{
+	Name: "reveal",
+	Type: "string",
+	Description: cli.Description{
+		Short: "Show the value of these secrets",
+		Long:  "Show the full value of these secrets, separated by commas. You are asked to confirm first, so it only works in a terminal.",
+	},
+	Complete: completeSecrets,
+}
+
+// maskSecret hides a secret in output that can end up in scrollback or CI
+// logs. Short values are hidden fully since a few characters would give most
+// of them away, and so is everything when stdout is not a terminal.
+func maskSecret(value string, reveal bool) string {
+	if reveal {
+		return value
+	}
+	runes := []rune(value)
+	if len(runes) < 8 || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return "********"
+	}
+	return string(runes[:2]) + "****" + string(runes[len(runes)-2:])
+}
+
+// revealSecrets returns the secrets passed in to --reveal after the user
+// confirms, --yes does not skip it.
+func revealSecrets(c *cli.Cli) (map[string]bool, error) {
+	result := map[string]bool{}
+	for _, name := range strings.Split(c.String("reveal"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			result[name] = true
+		}
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return nil, util.NewReadableError(nil, "Secrets can only be revealed in a terminal")
+	}
+	names := make([]string, 0, len(result))
+	for name := range result {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	prompt := promptui.Select{
+		Items:        []string{"Yes", "No"},
+		Label:        "‏‏‎ ‎Print the value of " + strings.Join(names, ", ") + "?",
+		HideSelected: true,
+		HideHelp:     true,
+	}
+	_, answer, err := prompt.Run()
+	if err != nil {
+		return nil, util.NewReadableError(err, "")
+	}
+	if answer != "Yes" {
+		return map[string]bool{}, nil
+	}
+	return result, nil
+}
+
 var CmdSecretList = &cli.Command{
 	Name: "list",
 	Description: cli.Description{
@@ -36,8 +108,19 @@ var CmdSecretList = &cli.Command{
 			"```bash frame=\"none\" frame=\"none\"",
 			"sst secret list --fallback",
 			"```",
+			"",
+			"The values are masked to their first and last two characters, and hidden completely when the output is not a terminal, like in CI. Pass in `--reveal` to show the value of a secret, you are asked to confirm first.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret list --reveal StripeSecret",
+			"```",
+			"",
+			"Use `sst secret export` to get all the values, like for a backup.",
+			"",
+			"Secrets declared in a `secrets.<stage>.sops.yaml` file next to your `sst.config.ts` are listed too. They are decrypted with the `sops` CLI and take precedence over the secrets you set for the stage, the ones set to something else are reported as conflicts.",
 		}, "\n"),
 	},
+	Flags: []cli.Flag{secretRevealFlag},
 	Examples: []cli.Example{
 		{
 			Content: "sst secret list --stage production",
@@ -52,13 +135,13 @@ var CmdSecretList = &cli.Command{
 			return err
 		}
 		defer p.Cleanup()
-		backend := p.Backend()
+		store := p.Secrets()
 		secrets := map[string]string{}
 		fallback := map[string]string{}
 		wg := errgroup.Group{}
 		if !c.Bool("fallback") {
 			wg.Go(func() error {
-				secrets, err = provider.GetSecrets(backend, p.App().Name, p.App().Stage)
+				secrets, err = store.GetSecrets(p.App().Name, p.App().Stage)
 				if err != nil {
 					return err
 				}
@@ -66,7 +149,7 @@ var CmdSecretList = &cli.Command{
 			})
 		}
 		wg.Go(func() error {
-			fallback, err = provider.GetSecrets(backend, p.App().Name, "")
+			fallback, err = store.GetSecrets(p.App().Name, "")
 			if err != nil {
 				return err
 			}
@@ -75,9 +158,42 @@ var CmdSecretList = &cli.Command{
 		if err := wg.Wait(); err != nil {
 			return err
 		}
-		if len(secrets) == 0 && len(fallback) == 0 {
+		declared := map[string]string{}
+		conflicts := []string{}
+		if !c.Bool("fallback") {
+			loaded, err := p.SopsSecrets(p.App().Stage)
+			if err != nil {
+				return util.NewReadableError(err, err.Error())
+			}
+			if loaded != nil {
+				declared = loaded
+				_, conflicts = project.MergeSecrets(declared, secrets)
+				for name := range declared {
+					delete(secrets, name)
+				}
+			}
+		}
+		if len(secrets) == 0 && len(fallback) == 0 && len(declared) == 0 {
 			return util.NewReadableError(nil, "No secrets found")
 		}
+		reveal, err := revealSecrets(c)
+		if err != nil {
+			return err
+		}
+		masked := func(secrets map[string]string) map[string]string {
+			result := map[string]string{}
+			for key, value := range secrets {
+				result[key] = maskSecret(value, reveal[key])
+			}
+			return result
+		}
+		fallback, secrets, declared = masked(fallback), masked(secrets), masked(declared)
+		output.Result(map[string]interface{}{
+			"fallback":  fallback,
+			"secrets":   secrets,
+			"declared":  declared,
+			"conflicts": conflicts,
+		})
 		if len(fallback) > 0 {
 			color.White("# fallback")
 			for key, value := range fallback {
@@ -90,6 +206,16 @@ var CmdSecretList = &cli.Command{
 				fmt.Println(key + "=" + value)
 			}
 		}
+		if len(declared) > 0 {
+			color.White("# %s", filepath.Base(p.PathSopsSecrets(p.App().Stage)))
+			for key, value := range declared {
+				fmt.Println(key + "=" + value)
+			}
+		}
+		for _, name := range conflicts {
+			color.New(color.FgYellow, color.Bold).Print("! ")
+			color.New(color.FgWhite).Println(" " + name + " is also set with `sst secret set`, the value in the file is used")
+		}
 		return nil
 	},
 }
@@ -123,6 +249,14 @@ var CmdSecretLoad = &cli.Command{
 			"```bash frame=\"none\" frame=\"none\"",
 			"sst secret load ./secrets.env --fallback",
 			"```",
+			"",
+			"Pass in `--dry-run` to see which secrets would be added or changed first.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret load ./prod.env --stage production --dry-run",
+			"```",
+			"",
+			"Use `-` to read the file from stdin.",
 		}, "\n"),
 	},
 	Args: []cli.Argument{
@@ -149,57 +283,177 @@ var CmdSecretLoad = &cli.Command{
 			},
 		},
 	},
+	Flags: []cli.Flag{
+		{
+			Name: "dry-run",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Only show the secrets that would change",
+				Long:  "Show the secrets that would be added or changed without setting them.",
+			},
+		},
+	},
 	Run: func(c *cli.Cli) error {
 		filePath := c.Positional(0)
+		var reader io.Reader = os.Stdin
+		if filePath != "-" {
+			file, err := os.Open(filePath)
+			if err != nil {
+				return util.NewReadableError(err, fmt.Sprintf("Could not open file %s", filePath))
+			}
+			defer file.Close()
+			reader = file
+		}
+		loaded, err := godotenv.Parse(reader)
+		if err != nil {
+			return util.NewReadableError(err, fmt.Sprintf("Could not parse file %s", filePath))
+		}
+		keys := make([]string, 0, len(loaded))
+		invalid := []string{}
+		for key := range loaded {
+			keys = append(keys, key)
+			if !secretName.MatchString(key) {
+				invalid = append(invalid, key)
+			}
+		}
+		sort.Strings(keys)
+		if len(invalid) > 0 {
+			sort.Strings(invalid)
+			return util.NewReadableError(nil, "Secret names must start with a capital letter and contain only letters and numbers: "+strings.Join(invalid, ", "))
+		}
+
 		p, err := c.InitProject()
 		if err != nil {
 			return err
 		}
 		defer p.Cleanup()
-		backend := p.Backend()
+		store := p.Secrets()
 		stage := p.App().Stage
 		if c.Bool("fallback") {
 			stage = ""
 		}
-		secrets, err := provider.GetSecrets(backend, p.App().Name, stage)
+		secrets, err := store.GetSecrets(p.App().Name, stage)
 		if err != nil {
 			return util.NewReadableError(err, "Could not get secrets")
 		}
-		file, err := os.Open(filePath)
-		if err != nil {
-			return util.NewReadableError(err, fmt.Sprintf("Could not open file %s", filePath))
-		}
-		defer file.Close()
 
-		scanner := bufio.NewScanner(file)
-		for scanner.Scan() {
-			line := scanner.Text()
-			if strings.HasPrefix(line, "#") {
-				continue
-			}
-			parts := strings.SplitN(line, "=", 2)
-			if len(parts) == 2 {
-				ui.Success(fmt.Sprintf("Setting %s", parts[0]))
-				key := strings.TrimSpace(parts[0])
-				value := strings.TrimSpace(parts[1])
-				secrets[key] = value
+		added := []string{}
+		changed := []string{}
+		for _, key := range keys {
+			previous, ok := secrets[key]
+			if !ok {
+				added = append(added, key)
+				fmt.Println(ui.TEXT_SUCCESS_BOLD.Render("+"), "", key)
+			} else if previous != loaded[key] {
+				changed = append(changed, key)
+				fmt.Println(ui.TEXT_WARNING_BOLD.Render("*"), "", key)
 			}
+			secrets[key] = loaded[key]
+		}
+		output.Result(map[string]interface{}{
+			"added":   added,
+			"changed": changed,
+		})
+		if len(added)+len(changed) == 0 {
+			ui.Success("No secrets changed")
+			return nil
+		}
+		if c.Bool("dry-run") {
+			fmt.Println()
+			ui.Success(fmt.Sprintf("%d to add, %d to change. Run without --dry-run to set them.", len(added), len(changed)))
+			return nil
 		}
-		err = provider.PutSecrets(backend, p.App().Name, stage, secrets)
+		err = store.PutSecrets(p.App().Name, stage, secrets)
 		if err != nil {
 			return util.NewReadableError(err, "Could not set secret")
 		}
-		url, _ := server.Discover(p.PathConfig(), p.App().Stage)
-		if url != "" {
-			dev.Deploy(c.Context, url)
+		fmt.Println()
+		suffix := deployDev(c, p)
+		ui.Success(fmt.Sprintf("Added %d and changed %d secrets.%s", len(added), len(changed), suffix))
+		return nil
+	},
+}
+
+var CmdSecretExport = &cli.Command{
+	Name: "export",
+	Description: cli.Description{
+		Short: "Print all secrets to a file",
+		Long: strings.Join([]string{
+			"Print the secrets of a stage in a format that `sst secret load` can read.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret export --stage production > prod.env",
+			"```",
+			"",
+			"Use this to copy the secrets of one stage to another.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret export --stage production | sst secret load - --stage staging",
+			"```",
+			"",
+			"Use `--format json` to print them as a JSON object instead.",
+			"",
+			":::caution",
+			"The secrets are printed in plain text. Don't commit the file.",
+			":::",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "format",
+			Type: "string",
+			Complete: func([]string) []string {
+				return []string{"dotenv", "json"}
+			},
+			Description: cli.Description{
+				Short: "The format to print in",
+				Long:  "The format to print the secrets in, one of `dotenv` or `json`.",
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		format := c.String("format")
+		if format == "" {
+			format = "dotenv"
+		}
+		if format != "dotenv" && format != "json" {
+			return util.NewReadableError(nil, "Invalid --format "+format+", use one of dotenv or json")
+		}
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		stage := p.App().Stage
+		if c.Bool("fallback") {
+			stage = ""
+		}
+		secrets, err := p.Secrets().GetSecrets(p.App().Name, stage)
+		if err != nil {
+			return util.NewReadableError(err, "Could not get secrets")
+		}
+		output.Result(secrets)
+		if format == "json" {
+			data, err := json.MarshalIndent(secrets, "", "  ")
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(data))
 			return nil
 		}
-
-		ui.Success("Run \"sst deploy\" to update.")
+		data, err := godotenv.Marshal(secrets)
+		if err != nil {
+			return err
+		}
+		if data != "" {
+			fmt.Println(data)
+		}
 		return nil
 	},
 }
 
+var secretName = regexp.MustCompile(`^[A-Z][a-zA-Z0-9_]*$`)
+
 var CmdSecretSet = &cli.Command{
 	Name: "set",
 	Description: cli.Description{
@@ -265,6 +519,7 @@ var CmdSecretSet = &cli.Command{
 		{
 			Name:     "name",
 			Required: true,
+			Complete: completeSecrets,
 			Description: cli.Description{
 				Short: "The name of the secret",
 				Long:  "The name of the secret.",
@@ -327,7 +582,7 @@ var CmdSecretSet = &cli.Command{
 				}
 			}
 		}
-		if !regexp.MustCompile(`^[A-Z][a-zA-Z0-9_]*$`).MatchString(key) {
+		if !secretName.MatchString(key) {
 			return util.NewReadableError(nil, "Secret names must start with a capital letter and contain only letters and numbers")
 		}
 		p, err := c.InitProject()
@@ -339,28 +594,28 @@ var CmdSecretSet = &cli.Command{
 		if c.Bool("fallback") {
 			stage = ""
 		}
-		backend := p.Backend()
-		secrets, err := provider.GetSecrets(backend, p.App().Name, stage)
+		store := p.Secrets()
+		secrets, err := store.GetSecrets(p.App().Name, stage)
 		if err != nil {
 			return util.NewReadableError(err, "Could not get secrets")
 		}
 		secrets[key] = value
-		err = provider.PutSecrets(backend, p.App().Name, stage, secrets)
+		err = store.PutSecrets(p.App().Name, stage, secrets)
 		if err != nil {
 			return util.NewReadableError(err, "Could not set secret")
 		}
-		url, _ := server.Discover(p.PathConfig(), p.App().Stage)
-		suffix := " Run \"sst deploy\" to update."
-		if url != "" {
-			suffix = ""
-			dev.Deploy(c.Context, url)
-		}
+		suffix := deployDev(c, p)
 
 		if c.Bool("fallback") {
 			ui.Success(fmt.Sprintf("Set fallback value for \"%s\".%s", key, suffix))
 			return nil
 		}
 		ui.Success(fmt.Sprintf("Set \"%s\" for stage \"%s\".%s", key, p.App().Stage, suffix))
+		declared, _ := p.SopsSecrets(stage)
+		if current, ok := declared[key]; ok && current != value {
+			color.New(color.FgYellow, color.Bold).Print("! ")
+			color.New(color.FgWhite).Println(" " + key + " is also declared in " + filepath.Base(p.PathSopsSecrets(stage)) + ", the value in the file is used")
+		}
 		return nil
 	},
 }
@@ -395,6 +650,7 @@ var CmdSecretRemove = &cli.Command{
 		{
 			Name:     "name",
 			Required: true,
+			Complete: completeSecrets,
 			Description: cli.Description{
 				Short: "The name of the secret",
 				Long:  "The name of the secret.",
@@ -422,12 +678,12 @@ var CmdSecretRemove = &cli.Command{
 			return err
 		}
 		defer p.Cleanup()
-		backend := p.Backend()
+		store := p.Secrets()
 		stage := p.App().Stage
 		if c.Bool("fallback") {
 			stage = ""
 		}
-		secrets, err := provider.GetSecrets(backend, p.App().Name, stage)
+		secrets, err := store.GetSecrets(p.App().Name, stage)
 		if err != nil {
 			return util.NewReadableError(err, "Could not get secrets")
 		}
@@ -436,16 +692,11 @@ var CmdSecretRemove = &cli.Command{
 			return util.NewReadableError(nil, fmt.Sprintf("Secret \"%s\" does not exist", key))
 		}
 		delete(secrets, key)
-		err = provider.PutSecrets(backend, p.App().Name, stage, secrets)
+		err = store.PutSecrets(p.App().Name, stage, secrets)
 		if err != nil {
 			return util.NewReadableError(err, "Could not set secret")
 		}
-		url, _ := server.Discover(p.PathConfig(), p.App().Stage)
-		suffix := " Run \"sst deploy\" to update."
-		if url != "" {
-			suffix = ""
-			dev.Deploy(c.Context, url)
-		}
+		suffix := deployDev(c, p)
 		if c.Bool("fallback") {
 			ui.Success(fmt.Sprintf("Removed fallback value for \"%s\".%s", key, suffix))
 			return nil
@@ -454,3 +705,405 @@ var CmdSecretRemove = &cli.Command{
 		return nil
 	},
 }
+
+var CmdSecretHistory = &cli.Command{
+	Name: "history",
+	Description: cli.Description{
+		Short: "List the versions of a secret",
+		Long: strings.Join([]string{
+			"List every value a secret has had in a stage, newest first, with who set it and the deploys that used it.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret history StripeSecret --stage production",
+			"```",
+			"",
+			"A version is recorded every time a secret is set or removed. Roll back to one with `sst secret rollback`.",
+			"",
+			"For secrets kept in `ssm`, `secretsmanager`, or `vault`, only the version the backend gave the value is recorded, the values stay in the backend and are read from it. Their versions are gone once a secret is removed from `ssm` or `secretsmanager`, and KV v1 in `vault` keeps none.",
+			"",
+			"The values are masked like in `sst secret list`, pass in `--reveal` with the name of the secret to show them.",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{secretRevealFlag},
+	Args: []cli.Argument{
+		{
+			Name:     "name",
+			Required: true,
+			Complete: completeSecrets,
+			Description: cli.Description{
+				Short: "The name of the secret",
+				Long:  "The name of the secret.",
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		key := c.Positional(0)
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		stage := p.App().Stage
+		if c.Bool("fallback") {
+			stage = ""
+		}
+		history, err := provider.GetSecretHistory(p.Backend(), p.App().Name, stage)
+		if err != nil {
+			return util.NewReadableError(err, "Could not get the history of the secrets")
+		}
+		versions := history[key]
+		if len(versions) == 0 {
+			return util.NewReadableError(nil, fmt.Sprintf("Secret \"%s\" has no history", key))
+		}
+		reveal, err := revealSecrets(c)
+		if err != nil {
+			return err
+		}
+
+		// the fallback secrets are not recorded in the deploys that use them
+		usedBy := map[int][]string{}
+		if stage != "" {
+			checkpoints, err := provider.ListHistory(p.Backend(), p.App().Name, stage)
+			if err != nil && !errors.Is(err, provider.ErrListUnsupported) {
+				return util.NewReadableError(err, "Could not list the deploys of stage "+stage)
+			}
+			for _, checkpoint := range checkpoints {
+				summary, _ := provider.GetSummary(p.Backend(), p.App().Name, stage, checkpoint.UpdateID)
+				if summary == nil {
+					continue
+				}
+				if version, ok := summary.Secrets[key]; ok {
+					usedBy[version] = append(usedBy[version], checkpoint.UpdateID)
+				}
+			}
+		}
+
+		type result struct {
+			provider.SecretVersion
+			Deploys []string `json:"deploys"`
+		}
+		store := p.Secrets()
+		results := []result{}
+		for i := len(versions) - 1; i >= 0; i-- {
+			version := versions[i]
+			value := version.Value
+			var valueErr error
+			if reveal[key] && !version.Removed {
+				// the backend of the secret may only keep the value
+				value, valueErr = project.SecretVersionValue(store, p.App().Name, stage, key, version)
+			}
+			version.Value = maskSecret(value, reveal[key] && valueErr == nil)
+			results = append(results, result{SecretVersion: version, Deploys: usedBy[version.Version]})
+			color.New(color.FgWhite, color.Bold).Printf("v%d", version.Version)
+			when := "before versions were kept"
+			if !version.Time.IsZero() {
+				when = version.Time.Local().Format("2006-01-02 15:04:05")
+			}
+			color.New(color.FgHiBlack).Print("  " + when)
+			if version.Holder != "" {
+				color.New(color.FgHiBlack).Print("  " + version.Holder)
+			}
+			if i == len(versions)-1 {
+				color.New(color.FgGreen).Print("  (current)")
+			}
+			fmt.Println()
+			if version.Removed {
+				color.New(color.FgRed).Println("   removed")
+			} else {
+				fmt.Println("   " + version.Value)
+			}
+			if version.Native != "" {
+				color.New(color.FgHiBlack).Println("   version " + version.Native + " in the backend")
+			}
+			if valueErr != nil {
+				color.New(color.FgHiBlack).Println("   could not read the value: " + valueErr.Error())
+			}
+			if deploys := usedBy[version.Version]; len(deploys) > 0 {
+				color.New(color.FgHiBlack).Printf("   used by %d deploys, the latest was %s\n", len(deploys), deploys[0])
+			}
+		}
+		output.Result(results)
+		return nil
+	},
+}
+
+var CmdSecretRollback = &cli.Command{
+	Name: "rollback",
+	Description: cli.Description{
+		Short: "Roll a secret back to a previous version",
+		Long: strings.Join([]string{
+			"Set a secret back to the value it had in a previous version.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret rollback StripeSecret --to 3",
+			"```",
+			"",
+			"The change is shown before it is made, and it is recorded as a new version so it can be rolled back too. Get the versions with `sst secret history`.",
+			"",
+			"Rolling back to a version where the secret was removed removes it.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "name",
+			Required: true,
+			Complete: completeSecrets,
+			Description: cli.Description{
+				Short: "The name of the secret",
+				Long:  "The name of the secret.",
+			},
+		},
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "to",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The version to roll back to",
+				Long:  "The version to roll back to, as listed by `sst secret history`.",
+			},
+		},
+		{
+			Name: "yes",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Skip interactive confirmation",
+				Long:  "Skip the confirmation prompt before the secret is changed.",
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		key := c.Positional(0)
+		target, err := strconv.Atoi(strings.TrimPrefix(c.String("to"), "v"))
+		if err != nil {
+			return util.NewReadableError(nil, "Pass in the version to roll back to with --to")
+		}
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		stage := p.App().Stage
+		if c.Bool("fallback") {
+			stage = ""
+		}
+		history, err := provider.GetSecretHistory(p.Backend(), p.App().Name, stage)
+		if err != nil {
+			return util.NewReadableError(err, "Could not get the history of the secrets")
+		}
+		var selected *provider.SecretVersion
+		for i, version := range history[key] {
+			if version.Version == target {
+				selected = &history[key][i]
+			}
+		}
+		if selected == nil {
+			return util.NewReadableError(nil, fmt.Sprintf("Secret \"%s\" has no version %d", key, target))
+		}
+
+		store := p.Secrets()
+		secrets, err := store.GetSecrets(p.App().Name, stage)
+		if err != nil {
+			return util.NewReadableError(err, "Could not get secrets")
+		}
+		value := ""
+		if !selected.Removed {
+			value, err = project.SecretVersionValue(store, p.App().Name, stage, key, *selected)
+			if err != nil {
+				return util.NewReadableError(err, fmt.Sprintf("Could not read version %d of \"%s\": %s", target, key, err))
+			}
+		}
+		current, exists := secrets[key]
+		if (selected.Removed && !exists) || (!selected.Removed && exists && current == value) {
+			ui.Success(fmt.Sprintf("\"%s\" is already at version %d.", key, target))
+			return nil
+		}
+		if exists {
+			color.New(color.FgRed).Println("- " + maskSecret(current, false))
+		}
+		if !selected.Removed {
+			color.New(color.FgGreen).Println("+ " + maskSecret(value, false))
+		}
+		fmt.Println()
+		ok, err := confirm(c, fmt.Sprintf("Roll back \"%s\" to version %d?", key, target), "Pass in --yes to roll back without a confirmation prompt")
+		if err != nil || !ok {
+			return err
+		}
+
+		if selected.Removed {
+			delete(secrets, key)
+		} else {
+			secrets[key] = value
+		}
+		err = store.PutSecrets(p.App().Name, stage, secrets)
+		if err != nil {
+			return util.NewReadableError(err, "Could not set secret")
+		}
+		suffix := deployDev(c, p)
+		ui.Success(fmt.Sprintf("Rolled back \"%s\" to version %d.%s", key, target, suffix))
+		return nil
+	},
+}
+
+var CmdSecretRotate = &cli.Command{
+	Name: "rotate",
+	Description: cli.Description{
+		Short: "Rotate secrets that are due",
+		Long: strings.Join([]string{
+			"Rotate the secrets of a stage that are past due with the hooks in your `sst.config.ts`.",
+			"",
+			"```js title=\"sst.config.ts\"",
+			"secrets: {",
+			"  rotation: {",
+			"    StripeSecret: {",
+			"      interval: \"30 days\",",
+			"      command: \"./scripts/rotate-stripe.sh\"",
+			"    }",
+			"  }",
+			"}",
+			"```",
+			"",
+			"The hook gets the current value and returns the new one, it runs in the root of your app. A command gets `SST_SECRET_NAME`, `SST_SECRET_VALUE`, `SST_APP`, and `SST_STAGE` and prints the new value. A Lambda function gets them as `name`, `value`, `app`, and `stage` and returns the new value.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret rotate --stage production",
+			"```",
+			"",
+			"Pass in a name to rotate a secret even if it is not due yet.",
+			"",
+			"```bash frame=\"none\"",
+			"sst secret rotate StripeSecret",
+			"```",
+			"",
+			"`sst deploy` warns about the secrets that are past due, so this can run on a schedule in CI with `--yes`.",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "name",
+			Complete: completeSecrets,
+			Description: cli.Description{
+				Short: "The name of the secret",
+				Long:  "The name of a secret to rotate even if it is not due.",
+			},
+		},
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "yes",
+			Type: "bool",
+			Description: cli.Description{
+				Short: "Skip interactive confirmation",
+				Long:  "Skip the confirmation prompt before the secrets are rotated.",
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		if c.Bool("fallback") {
+			return util.NewReadableError(nil, "Fallback secrets cannot be rotated")
+		}
+		rotation := map[string]project.SecretRotation{}
+		if p.App().Secrets != nil {
+			rotation = p.App().Secrets.Rotation
+		}
+		if len(rotation) == 0 {
+			return util.NewReadableError(nil, "No secrets have rotation set up in sst.config.ts")
+		}
+		stage := p.App().Stage
+		store := p.Secrets()
+		secrets, err := store.GetSecrets(p.App().Name, stage)
+		if err != nil {
+			return util.NewReadableError(err, "Could not get secrets")
+		}
+		history, err := provider.GetSecretHistory(p.Backend(), p.App().Name, stage)
+		if err != nil {
+			return util.NewReadableError(err, "Could not get the history of the secrets")
+		}
+		statuses, err := project.RotationStatuses(rotation, secrets, history, time.Now())
+		if err != nil {
+			return util.NewReadableError(err, err.Error())
+		}
+
+		names := []string{}
+		if name := c.Positional(0); name != "" {
+			if _, ok := secrets[name]; !ok {
+				return util.NewReadableError(nil, fmt.Sprintf("Secret \"%s\" does not exist", name))
+			}
+			names = append(names, name)
+		} else {
+			for _, status := range statuses {
+				when := "rotated " + status.Changed.Local().Format("2006-01-02")
+				if status.Changed.IsZero() {
+					when = "never rotated"
+				}
+				if status.Due {
+					color.New(color.FgYellow, color.Bold).Print("! ")
+					names = append(names, status.Name)
+				} else {
+					color.New(color.FgGreen, color.Bold).Print("✓ ")
+				}
+				color.New(color.FgWhite, color.Bold).Print(" " + status.Name)
+				color.New(color.FgHiBlack).Println("  " + when + ", due " + status.Deadline.Local().Format("2006-01-02"))
+			}
+			fmt.Println()
+		}
+		output.Result(map[string]interface{}{
+			"statuses": statuses,
+			"rotating": names,
+		})
+		if len(names) == 0 {
+			ui.Success("No secrets are due for rotation.")
+			return nil
+		}
+		ok, err := confirm(c, fmt.Sprintf("Rotate %s?", strings.Join(names, ", ")), "Pass in --yes to rotate without a confirmation prompt")
+		if err != nil || !ok {
+			return err
+		}
+
+		for _, name := range names {
+			value, err := p.RotateSecret(name, secrets[name])
+			if err != nil {
+				return util.NewReadableError(err, err.Error())
+			}
+			secrets[name] = value
+			// one at a time so a failing hook does not lose the ones before it
+			err = store.PutSecrets(p.App().Name, stage, secrets)
+			if err != nil {
+				return util.NewReadableError(err, "Could not set secret")
+			}
+			color.New(color.FgGreen, color.Bold).Print("✓ ")
+			color.New(color.FgWhite).Println(" Rotated " + name)
+		}
+		suffix := deployDev(c, p)
+		ui.Success(fmt.Sprintf("Rotated %d secrets.%s", len(names), suffix))
+		return nil
+	},
+}
+
+// deployDev asks the `sst dev` of the stage, if one is running, to deploy the
+// changed secrets. It returns what to tell the user to do otherwise.
+func deployDev(c *cli.Cli, p *project.Project) string {
+	suffix := " Run \"sst deploy\" to update."
+	url, err := server.Discover(p.PathConfig(), p.App().Stage)
+	if err != nil {
+		if !errors.Is(err, server.ErrServerNotFound) {
+			slog.Error("could not find the dev server", "err", err)
+		}
+		return suffix
+	}
+	token, err := server.DiscoverToken(p.PathConfig(), p.App().Stage)
+	if err != nil {
+		slog.Error("could not read the token of the dev server, not deploying", "err", err)
+		return suffix
+	}
+	if err := dev.Deploy(c.Context, url, token); err != nil {
+		slog.Error("could not ask the dev server to deploy", "err", err)
+		return suffix
+	}
+	return ""
+}
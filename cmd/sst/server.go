@@ -0,0 +1,205 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/dev"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/server"
+)
+
+var CmdServer = &cli.Command{
+	Name: "server",
+	Description: cli.Description{
+		Short: "Manage the server for a stage",
+		Long: strings.Join([]string{
+			"Manage the server that `sst dev` runs for a stage.",
+		}, "\n"),
+	},
+	Children: []*cli.Command{
+		{
+			Name: "stop",
+			Description: cli.Description{
+				Short: "Stop the server for a stage",
+				Long: strings.Join([]string{
+					"Stop the server that `sst dev` is running for a stage.",
+					"",
+					"Connected clients are disconnected, any in progress deploy is cancelled and its lock on the state is released.",
+					"",
+					"```bash frame=\"none\"",
+					"sst server stop --stage production",
+					"```",
+				}, "\n"),
+			},
+			Run: CmdServerStop,
+		},
+	},
+}
+
+func CmdServerStop(c *cli.Cli) error {
+	cfgPath, err := c.Discover()
+	if err != nil {
+		return err
+	}
+	stage, err := c.Stage(cfgPath)
+	if err != nil {
+		return err
+	}
+	addr, err := server.Discover(cfgPath, stage)
+	if errors.Is(err, server.ErrServerNotFound) {
+		return util.NewReadableError(err, "No server is running for stage: "+stage)
+	}
+	if err != nil {
+		return err
+	}
+	token, err := server.DiscoverToken(cfgPath, stage)
+	if err != nil {
+		return err
+	}
+	err = server.Stop(c.Context, addr, token)
+	if err != nil {
+		return util.NewReadableError(err, "Could not stop the server at "+addr)
+	}
+	if !waitForStop(cfgPath, stage) {
+		return util.NewReadableError(nil, "The server for stage "+stage+" did not shut down in time")
+	}
+	color.New(color.FgGreen, color.Bold).Print("✓ ")
+	color.New(color.FgWhite).Print(" Stopped the server for: ")
+	color.New(color.FgWhite, color.Bold).Println(stage)
+	return nil
+}
+
+// waitForStop waits for a server that was asked to stop, the discovery files
+// are removed once it has drained.
+func waitForStop(cfgPath string, stage string) bool {
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if _, err := server.Discover(cfgPath, stage); errors.Is(err, server.ErrServerNotFound) {
+			return true
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return false
+}
+
+// versionMismatchError is the readable error for a server that was started by
+// another version of the CLI.
+func versionMismatchError(err *dev.VersionMismatchError) error {
+	running := "an older version"
+	if err.Server != "" {
+		running = "version " + err.Server
+	}
+	return util.NewReadableError(err, fmt.Sprintf("This is version %s of the CLI but `sst dev` is running %s. Restart `sst dev` to use the same version.", err.Client, running))
+}
+
+// restartServer replaces a server of the stage that was started by another
+// version of the CLI. It is stopped and `sst dev` is started again in the
+// background with this version, its output goes to .sst/log/server.log. It
+// returns the address and token of the new server once it is up.
+func restartServer(c *cli.Cli, cfgPath string, stage string) (string, string, error) {
+	addr, err := server.Discover(cfgPath, stage)
+	if err != nil {
+		return "", "", err
+	}
+	token, err := server.DiscoverToken(cfgPath, stage)
+	if err != nil {
+		return "", "", err
+	}
+	err = server.Stop(c.Context, addr, token)
+	if err != nil {
+		return "", "", util.NewReadableError(err, "Could not stop the server at "+addr+" that is running another version of the CLI")
+	}
+	if !waitForStop(cfgPath, stage) {
+		return "", "", util.NewReadableError(nil, "The server for stage "+stage+" is running another version of the CLI and did not shut down in time")
+	}
+
+	logDir := project.ResolveLogDir(cfgPath)
+	if err := os.MkdirAll(logDir, 0755); err != nil {
+		return "", "", err
+	}
+	logFile, err := os.Create(filepath.Join(logDir, "server.log"))
+	if err != nil {
+		return "", "", err
+	}
+	defer logFile.Close()
+	executable, err := os.Executable()
+	if err != nil {
+		return "", "", err
+	}
+	cmd := exec.Command(executable, "dev", "--json", "--stage", stage)
+	cmd.Dir = filepath.Dir(cfgPath)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	// the server outlives the command that started it
+	util.SetProcessGroupID(cmd)
+	if err := cmd.Start(); err != nil {
+		return "", "", util.NewReadableError(err, "Could not start a new server for stage "+stage)
+	}
+	exited := make(chan struct{})
+	go func() {
+		cmd.Wait()
+		close(exited)
+	}()
+
+	deadline := time.Now().Add(2 * time.Minute)
+	for time.Now().Before(deadline) {
+		addr, err := server.Discover(cfgPath, stage)
+		if err == nil {
+			token, err := server.DiscoverToken(cfgPath, stage)
+			if err == nil {
+				return addr, token, nil
+			}
+		}
+		select {
+		case <-c.Context.Done():
+			return "", "", c.Context.Err()
+		case <-exited:
+			return "", "", util.NewReadableError(nil, "The new server for stage "+stage+" exited, check "+logFile.Name())
+		case <-time.After(250 * time.Millisecond):
+		}
+	}
+	return "", "", util.NewReadableError(nil, "The new server for stage "+stage+" did not come up in time, check "+logFile.Name())
+}
+
+// discoverServer finds the server to connect to, either the one passed in
+// with --server or the one `sst dev` is running for the stage locally.
+func discoverServer(c *cli.Cli, cfgPath string, stage string) (string, string, error) {
+	if remote := c.String("server"); remote != "" {
+		addr, err := server.Remote(remote)
+		if err != nil {
+			return "", "", util.NewReadableError(err, "Invalid --server address: "+remote)
+		}
+		token := os.Getenv("SST_SERVER_TOKEN")
+		if token == "" {
+			return "", "", util.NewReadableError(nil, "Set SST_SERVER_TOKEN to the token of the remote server. It is in `.sst/<stage>.server.token` on the machine running `sst dev`.")
+		}
+		return addr, token, nil
+	}
+	addr, err := server.Discover(cfgPath, stage)
+	if err != nil {
+		return "", "", err
+	}
+	token, err := server.DiscoverToken(cfgPath, stage)
+	if err != nil {
+		return "", "", err
+	}
+	return addr, token, nil
+}
+
+var serverFlag = cli.Flag{
+	Name: "server",
+	Type: "string",
+	Description: cli.Description{
+		Short: "Connect to a server on another machine",
+		Long:  "Connect to the server of an `sst dev` session running on another machine, as `host:port` or an `https://` url. Its token is read from `SST_SERVER_TOKEN`.",
+	},
+}
@@ -1,15 +1,21 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/project/provider"
 )
 
@@ -50,6 +56,14 @@ func CmdShell(c *cli.Cli) error {
 	if err != nil {
 		return err
 	}
+	// the shell still works for anyone who cannot read the secrets of the
+	// stage, it just has no SST_SECRET_* variables
+	secrets, err := p.SecretEnv()
+	if err != nil {
+		slog.Warn("failed to read secrets", "err", err)
+		fmt.Fprintln(os.Stderr, ui.TEXT_WARNING_BOLD.Render("Warning: ")+"Could not read the secrets of stage "+p.App().Stage+", the shell will not have them: "+err.Error())
+		secrets = map[string]string{}
+	}
 	target := c.String("target")
 	if target != "" {
 		cmd.Env = append(cmd.Env, c.Env()...)
@@ -57,6 +71,9 @@ func CmdShell(c *cli.Cli) error {
 		if err != nil {
 			return err
 		}
+		for key, value := range secrets {
+			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+		}
 		for key, value := range env {
 			cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
 		}
@@ -75,6 +92,9 @@ func CmdShell(c *cli.Cli) error {
 			env[fmt.Sprintf("SST_RESOURCE_%s", resource)] = string(jsonValue)
 		}
 		env["SST_RESOURCE_App"] = fmt.Sprintf(`{"name": "%s", "stage": "%s" }`, p.App().Name, p.App().Stage)
+		for key, value := range secrets {
+			env[key] = value
+		}
 
 		aws, ok := p.Provider("aws")
 		if ok {
@@ -86,6 +106,12 @@ func CmdShell(c *cli.Cli) error {
 			if err != nil {
 				return err
 			}
+			if function := c.String("role"); function != "" {
+				creds, err = assumeFunctionRole(c.Context, cfg, complete, function)
+				if err != nil {
+					return err
+				}
+			}
 			cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_ACCESS_KEY_ID=%s", creds.AccessKeyID))
 			cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_SECRET_ACCESS_KEY=%s", creds.SecretAccessKey))
 			cmd.Env = append(cmd.Env, fmt.Sprintf("AWS_SESSION_TOKEN=%s", creds.SessionToken))
@@ -107,3 +133,26 @@ func CmdShell(c *cli.Cli) error {
 	}
 	return nil
 }
+
+// assumeFunctionRole gets credentials for the role of a function, so the
+// command has its permissions. Function roles only trust the account while
+// the stage is running in `sst dev`, or if the config adds it.
+func assumeFunctionRole(ctx context.Context, cfg aws.Config, complete *project.CompleteEvent, function string) (aws.Credentials, error) {
+	role, err := project.FunctionRole(complete.Resources, function)
+	if err != nil {
+		return aws.Credentials{}, util.NewReadableError(err, "Could not find the role of "+function+", --role takes the name of a function in your app")
+	}
+	result, err := sts.NewFromConfig(cfg).AssumeRole(ctx, &sts.AssumeRoleInput{
+		RoleArn:         aws.String(role),
+		RoleSessionName: aws.String("sst-shell"),
+		DurationSeconds: aws.Int32(3600),
+	})
+	if err != nil {
+		return aws.Credentials{}, util.NewReadableError(err, "Could not assume the role of "+function+". Its role only trusts your account while the stage is running in `sst dev`.")
+	}
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(result.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(result.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(result.Credentials.SessionToken),
+	}, nil
+}
@@ -1,6 +1,23 @@
 package main
 
-import "github.com/sst/ion/cmd/sst/cli"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/manifoldco/promptui"
+	"github.com/nrednav/cuid2"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+	"golang.org/x/term"
+)
 
 var CmdStateCopy = &cli.Command{
 	Name: "copy",
@@ -19,3 +36,459 @@ var CmdStateCopy = &cli.Command{
 		return nil
 	},
 }
+
+var stateYesFlag = cli.Flag{
+	Name: "yes",
+	Type: "bool",
+	Description: cli.Description{
+		Short: "Skip interactive confirmation",
+		Long:  "Skip the confirmation prompt before the state is changed.",
+	},
+}
+
+var CmdStateList = &cli.Command{
+	Name: "list",
+	Description: cli.Description{
+		Short: "List the resources in the state",
+		Long: strings.Join([]string{
+			"List the URN of every resource in the state of your app.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state list --stage production",
+			"```",
+		}, "\n"),
+	},
+	Run: func(c *cli.Cli) error {
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		state, err := p.ReadState()
+		if err != nil {
+			return util.NewReadableError(err, "Could not read state")
+		}
+		urns := []string{}
+		for _, item := range state.Resources() {
+			urns = append(urns, string(item.URN))
+			fmt.Println(item.URN)
+		}
+		output.Result(urns)
+		return nil
+	},
+}
+
+var CmdStateShow = &cli.Command{
+	Name: "show",
+	Description: cli.Description{
+		Short: "Show a resource in the state",
+		Long: strings.Join([]string{
+			"Print a resource in the state of your app as JSON.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state show <urn>",
+			"```",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "urn",
+			Required: true,
+			Description: cli.Description{
+				Short: "The URN of the resource",
+				Long:  "The URN of the resource, as printed by `sst state list`.",
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		state, err := p.ReadState()
+		if err != nil {
+			return util.NewReadableError(err, "Could not read state")
+		}
+		item, err := state.Find(c.Positional(0))
+		if err != nil {
+			return util.NewReadableError(err, "Could not find resource: "+c.Positional(0))
+		}
+		output.Result(item)
+		data, err := json.MarshalIndent(item, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(data))
+		return nil
+	},
+}
+
+var CmdStateRemove = &cli.Command{
+	Name: "rm",
+	Description: cli.Description{
+		Short: "Remove a resource from the state",
+		Long: strings.Join([]string{
+			"Remove a resource from the state of your app without deleting it.",
+			"",
+			"This is useful if a resource was removed outside of SST. Resources that other resources still depend on cannot be removed.",
+			"",
+			"A backup of the state is written to `.sst/backup` before it is changed.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state rm <urn>",
+			"```",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "urn",
+			Required: true,
+			Description: cli.Description{
+				Short: "The URN of the resource",
+				Long:  "The URN of the resource, as printed by `sst state list`.",
+			},
+		},
+	},
+	Flags: []cli.Flag{stateYesFlag},
+	Run: func(c *cli.Cli) error {
+		urn := c.Positional(0)
+		return editState(c, "Remove "+urn, func(p *project.Project, state *project.State) error {
+			return state.Remove(urn)
+		})
+	},
+}
+
+var CmdStateMove = &cli.Command{
+	Name: "mv",
+	Description: cli.Description{
+		Short: "Rename a resource in the state",
+		Long: strings.Join([]string{
+			"Change the URN of a resource in the state of your app and update everything that references it.",
+			"",
+			"This is useful after renaming a component so it is not replaced on the next deploy.",
+			"",
+			"A backup of the state is written to `.sst/backup` before it is changed.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state mv <from> <to>",
+			"```",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "from",
+			Required: true,
+			Description: cli.Description{
+				Short: "The current URN of the resource",
+			},
+		},
+		{
+			Name:     "to",
+			Required: true,
+			Description: cli.Description{
+				Short: "The new URN of the resource",
+			},
+		},
+	},
+	Flags: []cli.Flag{stateYesFlag},
+	Run: func(c *cli.Cli) error {
+		from := c.Positional(0)
+		to := c.Positional(1)
+		return editState(c, "Rename "+from+" to "+to, func(p *project.Project, state *project.State) error {
+			return state.Move(from, to)
+		})
+	},
+}
+
+var CmdStateHistory = &cli.Command{
+	Name: "history",
+	Description: cli.Description{
+		Short: "List the checkpoints of the state",
+		Long: strings.Join([]string{
+			"List the checkpoints of the state of your app, newest first.",
+			"",
+			"A checkpoint is saved at the end of every update. Set `history.retain` in your `sst.config.ts` to limit how many are kept.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state history --stage production",
+			"```",
+		}, "\n"),
+	},
+	Run: func(c *cli.Cli) error {
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+
+		history, err := provider.ListHistory(p.Backend(), p.App().Name, p.App().Stage)
+		if err != nil {
+			return util.NewReadableError(err, "Could not list the checkpoints of stage "+p.App().Stage)
+		}
+		result := []*rollbackCheckpoint{}
+		for _, item := range history {
+			summary, _ := provider.GetSummary(p.Backend(), p.App().Name, p.App().Stage, item.UpdateID)
+			result = append(result, &rollbackCheckpoint{Checkpoint: item, Summary: summary})
+		}
+		output.Result(result)
+		for _, item := range result {
+			color.New(color.FgWhite, color.Bold).Print(item.ID)
+			color.New(color.FgHiBlack).Println("  " + item.label())
+		}
+		return nil
+	},
+}
+
+var CmdStateRestore = &cli.Command{
+	Name: "restore",
+	Description: cli.Description{
+		Short: "Restore the state to a checkpoint",
+		Long: strings.Join([]string{
+			"Replace the state of your app with a checkpoint from `sst state history`.",
+			"",
+			"Only the state is changed, nothing is deployed. Use this when the state has been corrupted. To deploy your app as it was in a checkpoint, use `sst rollback`.",
+			"",
+			"A backup of the state is written to `.sst/backup` before it is changed.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state restore <checkpoint>",
+			"```",
+		}, "\n"),
+	},
+	Args: []cli.Argument{
+		{
+			Name:     "checkpoint",
+			Required: true,
+			Description: cli.Description{
+				Short: "The checkpoint to restore",
+				Long:  "The id of the checkpoint, or the id of the update that created it.",
+			},
+		},
+	},
+	Flags: []cli.Flag{stateYesFlag},
+	Run: func(c *cli.Cli) error {
+		id := c.Positional(0)
+		return editState(c, "Restore the state to "+id, func(p *project.Project, state *project.State) error {
+			history, err := provider.ListHistory(p.Backend(), p.App().Name, p.App().Stage)
+			if err != nil {
+				return err
+			}
+			selected := findCheckpoint(history, id)
+			if selected == nil {
+				return fmt.Errorf("%w: %s", provider.ErrCheckpointNotFound, id)
+			}
+			checkpoint, err := p.ReadCheckpoint(selected.ID)
+			if err != nil {
+				return err
+			}
+			color.New(color.FgWhite).Printf("   %d resources now, %d in the checkpoint from %s\n", len(state.Resources()), len(checkpoint.Resources()), selected.Time.Local().Format(time.RFC822))
+			state.Restore(checkpoint)
+			return nil
+		})
+	},
+}
+
+var CmdStateExport = &cli.Command{
+	Name: "export",
+	Description: cli.Description{
+		Short: "Export the state for Pulumi or Terraform",
+		Long: strings.Join([]string{
+			"Print the state of your app in a format that other tools can import, so your resources can be managed without recreating them.",
+			"",
+			"With `--format pulumi`, the default, it is a stack export that can be imported with `pulumi stack import`.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state export > stack.json",
+			"pulumi stack import --file stack.json",
+			"```",
+			"",
+			"The secrets in it are encrypted with the passphrase of the stage, set `PULUMI_CONFIG_PASSPHRASE` to it to read them. Your components are in it too, so you need their code in your Pulumi program.",
+			"",
+			"With `--format terraform`, it is a set of Terraform `import` blocks for the resources that have a Terraform equivalent.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state export --format terraform > imports.tf",
+			"terraform plan -generate-config-out=generated.tf",
+			"```",
+			"",
+			"With `--format terraform-state`, it is a Terraform state with a stub for each of these resources, for when you would rather not run the import. The stubs only have the id of the resource, refresh the state to read the rest.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state export --format terraform-state > terraform.tfstate",
+			"terraform apply -refresh-only",
+			"```",
+			"",
+			"The Terraform types are worked out from the Pulumi ones, check them before you apply.",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "format",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The format to export to",
+				Long:  "The format to export to, `pulumi`, `terraform` or `terraform-state`. Defaults to `pulumi`.",
+			},
+			Complete: func([]string) []string {
+				return []string{"pulumi", "terraform", "terraform-state"}
+			},
+		},
+	},
+	Run: func(c *cli.Cli) error {
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+		state, err := p.ReadState()
+		if err != nil {
+			return util.NewReadableError(err, "Could not read state")
+		}
+		switch c.String("format") {
+		case "", "pulumi":
+			data, err := state.ExportPulumi()
+			if err != nil {
+				return err
+			}
+			output.Result(json.RawMessage(data))
+			fmt.Println(string(data))
+		case "terraform":
+			result := state.ExportTerraform()
+			output.Result(result)
+			fmt.Print(result)
+		case "terraform-state":
+			data, err := state.ExportTerraformState()
+			if err != nil {
+				return err
+			}
+			output.Result(json.RawMessage(data))
+			fmt.Println(string(data))
+		default:
+			return util.NewReadableError(nil, "Unknown format "+c.String("format")+", use pulumi, terraform or terraform-state")
+		}
+		return nil
+	},
+}
+
+var CmdStateEncrypt = &cli.Command{
+	Name: "encrypt",
+	Description: cli.Description{
+		Short: "Encrypt the existing state",
+		Long: strings.Join([]string{
+			"Encrypt the state and every checkpoint of a stage that were stored before `encryption` was set in your `sst.config.ts`.",
+			"",
+			"```bash frame=\"none\"",
+			"sst state encrypt --stage production",
+			"```",
+			"",
+			"New state is encrypted as it is stored so this only needs to be run once per stage. It is also how you switch an encrypted stage from `SST_STATE_PASSPHRASE` to a KMS key, and how state from before `SST_STATE_PASSPHRASE` was needed is encrypted with it.",
+		}, "\n"),
+	},
+	Run: func(c *cli.Cli) error {
+		p, err := c.InitProject()
+		if err != nil {
+			return err
+		}
+		defer p.Cleanup()
+
+		updateID := cuid2.Generate()
+		err = p.Lock(updateID, "encrypt")
+		if err != nil {
+			return util.NewReadableError(err, "Could not lock state")
+		}
+		defer p.Unlock()
+
+		count, err := provider.EncryptStage(p.Backend(), p.App().Name, p.App().Stage)
+		if err != nil {
+			if errors.Is(err, provider.ErrEncryptionDisabled) {
+				return util.NewReadableError(err, "Set `encryption` in your sst.config.ts to encrypt the state")
+			}
+			return util.NewReadableError(err, "Could not encrypt the state")
+		}
+		output.Result(map[string]interface{}{"encrypted": count})
+		color.New(color.FgGreen, color.Bold).Print("✓ ")
+		color.New(color.FgWhite).Printf(" Encrypted the state and %d checkpoints of %s / %s\n", max(count-1, 0), p.App().Name, p.App().Stage)
+		return nil
+	},
+}
+
+// editState applies fn to the state under a lock and, once confirmed, backs
+// up the original state and pushes the result.
+func editState(c *cli.Cli, action string, fn func(p *project.Project, state *project.State) error) error {
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+	return editProjectState(c, p, action, fn)
+}
+
+func editProjectState(c *cli.Cli, p *project.Project, action string, fn func(p *project.Project, state *project.State) error) error {
+	var parsed provider.Summary
+	parsed.Version = version
+	parsed.UpdateID = cuid2.Generate()
+	parsed.TimeStarted = time.Now().UTC().Format(time.RFC3339)
+	err := p.Lock(parsed.UpdateID, "edit")
+	if err != nil {
+		return util.NewReadableError(err, "Could not lock state")
+	}
+	defer p.Unlock()
+	defer func() {
+		parsed.TimeCompleted = time.Now().UTC().Format(time.RFC3339)
+		provider.PutSummary(p.Backend(), p.App().Name, p.App().Stage, parsed.UpdateID, parsed)
+	}()
+
+	state, err := p.ReadState()
+	if err != nil {
+		return util.NewReadableError(err, "Could not read state")
+	}
+	err = fn(p, state)
+	if err != nil {
+		return util.NewReadableError(err, err.Error())
+	}
+
+	ok, err := confirm(c, action+"?", "Pass in --yes to change the state without a confirmation prompt")
+	if err != nil || !ok {
+		return err
+	}
+
+	backup, err := state.Backup(p.PathBackup())
+	if err != nil {
+		return util.NewReadableError(err, "Could not back up state")
+	}
+	err = state.Save()
+	if err != nil {
+		return util.NewReadableError(err, "Could not write state")
+	}
+	err = p.PushState(parsed.UpdateID)
+	if err != nil {
+		return util.NewReadableError(err, "Could not push state")
+	}
+	color.New(color.FgGreen, color.Bold).Print("✓ ")
+	color.New(color.FgWhite).Println(" " + action)
+	color.New(color.FgHiBlack).Println("   Backup: " + backup)
+	return nil
+}
+
+// confirm asks the user to confirm label unless --yes is passed in. It fails
+// with refusal when there is no terminal to ask in.
+func confirm(c *cli.Cli, label string, refusal string) (bool, error) {
+	if c.Bool("yes") {
+		return true, nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false, util.NewReadableError(nil, refusal)
+	}
+	prompt := promptui.Select{
+		Items:        []string{"Yes", "No"},
+		Label:        "‏‏‎ ‎" + label,
+		HideSelected: true,
+		HideHelp:     true,
+	}
+	_, result, err := prompt.Run()
+	if err != nil {
+		return false, util.NewReadableError(err, "")
+	}
+	return result == "Yes", nil
+}
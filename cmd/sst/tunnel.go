@@ -1,17 +1,22 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"os/user"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/tunnel"
 	"golang.org/x/sync/errgroup"
@@ -54,8 +59,16 @@ var CmdTunnel = &cli.Command{
 			"sst tunnel --stage production",
 			"```",
 			"",
+			"This can run next to the tunnel of `sst dev`, each stage gets its own tunnel and",
+			"`sst tunnel list` shows the ones that are running. The ranges of the stages can't",
+			"overlap, since traffic to a range can only be routed to one of them.",
+			"",
 			"This needs a network interface on your local machine. You can create this",
 			"with the `sst tunnel install` command.",
+			"",
+			"If your network does not allow SSH to the bastion, set `tunnel: \"ssm\"` on the VPC.",
+			"The tunnel then goes through AWS Systems Manager Session Manager, so the bastion needs",
+			"no open ports. This needs the AWS CLI and its Session Manager plugin installed.",
 		}, "\n"),
 	},
 	Run: func(c *cli.Cli) error {
@@ -77,23 +90,48 @@ var CmdTunnel = &cli.Command{
 		for _, item := range state.Tunnels {
 			tun = item
 		}
+		if err := tunnel.CheckRoutes(proj.App().Name, proj.App().Stage, tun.Subnets); err != nil {
+			return err
+		}
 		subnets := strings.Join(tun.Subnets, ",")
-		// run as root
-		tunnelCmd := exec.CommandContext(
-			c.Context,
+		args := []string{
 			"sudo", "-n", "-E",
 			tunnel.BINARY_PATH, "tunnel", "start",
+			"--app", proj.App().Name,
+			"--stage", proj.App().Stage,
 			"--subnets", subnets,
-			"--host", tun.IP,
 			"--user", tun.Username,
 			"--print-logs",
-		)
-		tunnelCmd.Env = append(
+		}
+		env := append(
 			os.Environ(),
 			"SST_SKIP_LOCAL=true",
 			"SST_SKIP_DEPENDENCY_CHECK=true",
 			"SSH_PRIVATE_KEY="+tun.PrivateKey,
 		)
+		if tun.Transport != "ssm" {
+			args = append(args, "--host", tun.IP)
+		} else {
+			if err := tunnel.CheckSSM(); err != nil {
+				return err
+			}
+			// the ssm session runs as the user with the credentials of the app,
+			// the tunnel only gets the local port it forwards
+			projEnv := proj.Env()
+			port, err := tunnel.ForwardSSM(c.Context, tun.Instance, tun.Region, append(
+				os.Environ(),
+				"AWS_ACCESS_KEY_ID="+projEnv["SST_AWS_ACCESS_KEY_ID"],
+				"AWS_SECRET_ACCESS_KEY="+projEnv["SST_AWS_SECRET_ACCESS_KEY"],
+				"AWS_SESSION_TOKEN="+projEnv["SST_AWS_SESSION_TOKEN"],
+			))
+			if err != nil {
+				return util.NewReadableError(err, "Could not start the ssm session to "+tun.Instance+": "+err.Error())
+			}
+			args = append(args, "--transport", "ssm", "--host", "127.0.0.1", "--port", strconv.Itoa(port))
+		}
+		// run as root
+		tunnelCmd := exec.CommandContext(c.Context, args[0], args[1:]...)
+		tunnelCmd.Env = env
 		tunnelCmd.Stdout = os.Stdout
 		util.SetProcessGroupID(tunnelCmd)
 		util.SetProcessCancel(tunnelCmd)
@@ -101,7 +139,11 @@ var CmdTunnel = &cli.Command{
 		fmt.Println(ui.TEXT_HIGHLIGHT_BOLD.Render("Tunnel"))
 		fmt.Println()
 		fmt.Print(ui.TEXT_HIGHLIGHT_BOLD.Render("➜"))
-		fmt.Println(ui.TEXT_NORMAL.Render("  Forwarding ranges"))
+		if tun.Transport == "ssm" {
+			fmt.Println(ui.TEXT_NORMAL.Render("  Forwarding ranges through SSM to " + tun.Instance))
+		} else {
+			fmt.Println(ui.TEXT_NORMAL.Render("  Forwarding ranges"))
+		}
 		for _, subnet := range tun.Subnets {
 			fmt.Println(ui.TEXT_DIM.Render("   " + subnet))
 		}
@@ -117,6 +159,92 @@ var CmdTunnel = &cli.Command{
 		return nil
 	},
 	Children: []*cli.Command{
+		{
+			Name: "status",
+			Description: cli.Description{
+				Short: "Show the status of the tunnel",
+				Long: strings.Join([]string{
+					"Show the status of the tunnel.",
+					"",
+					"```bash frame=\"none\"",
+					"sst tunnel status",
+					"```",
+					"",
+					"The tunnel checks its connection to the bastion host every few seconds. If it drops,",
+					"like after your machine was asleep, it reconnects on its own. This shows if it is",
+					"connected, how many times it had to reconnect, and the last error.",
+				}, "\n"),
+			},
+			Run: func(c *cli.Cli) error {
+				cfgPath, err := c.Discover()
+				if err != nil {
+					return err
+				}
+				stage, err := c.Stage(cfgPath)
+				if err != nil {
+					return err
+				}
+				proj, err := project.New(&project.ProjectConfig{
+					Version: version,
+					Config:  cfgPath,
+					Stage:   stage,
+				})
+				if err != nil {
+					return err
+				}
+				status, err := tunnel.Find(proj.App().Name, stage)
+				if err != nil {
+					return err
+				}
+				if output.Enabled() {
+					output.Result(status)
+					return nil
+				}
+				if status == nil {
+					return util.NewReadableError(nil, "There is no tunnel for the "+stage+" stage. Start it with `sst tunnel` or `sst dev`")
+				}
+				printTunnel(status)
+				return nil
+			},
+		},
+		{
+			Name: "list",
+			Description: cli.Description{
+				Short: "List the running tunnels",
+				Long: strings.Join([]string{
+					"List the tunnels running on this machine.",
+					"",
+					"```bash frame=\"none\"",
+					"sst tunnel list",
+					"```",
+					"",
+					"You can run a tunnel for each stage you are working on, even across apps. Every",
+					"tunnel gets its own network interface and forwards the ranges of its VPC. The",
+					"ranges of the tunnels can't overlap, so give the VPCs different CIDR blocks.",
+				}, "\n"),
+			},
+			Run: func(c *cli.Cli) error {
+				all, err := tunnel.List()
+				if err != nil {
+					return err
+				}
+				if output.Enabled() {
+					output.Result(all)
+					return nil
+				}
+				if len(all) == 0 {
+					fmt.Println(ui.TEXT_DIM.Render("No tunnels are running"))
+					return nil
+				}
+				for i, status := range all {
+					if i > 0 {
+						fmt.Println()
+					}
+					printTunnel(status)
+				}
+				return nil
+			},
+		},
 		{
 			Name: "install",
 			Description: cli.Description{
@@ -164,6 +292,14 @@ var CmdTunnel = &cli.Command{
 			},
 			Hidden: true,
 			Flags: []cli.Flag{
+				{
+					Name: "app",
+					Type: "string",
+					Description: cli.Description{
+						Short: "The app the tunnel is for",
+						Long:  "The app the tunnel is for",
+					},
+				},
 				{
 					Name: "subnets",
 					Type: "string",
@@ -196,6 +332,14 @@ var CmdTunnel = &cli.Command{
 						Long:  "The user to use for the tunnel",
 					},
 				},
+				{
+					Name: "transport",
+					Type: "string",
+					Description: cli.Description{
+						Short: "How to reach the bastion, ssh or ssm",
+						Long:  "How to reach the bastion, ssh or ssm",
+					},
+				},
 			},
 			Run: func(c *cli.Cli) error {
 				subnets := strings.Split(c.String("subnets"), ",")
@@ -206,23 +350,33 @@ var CmdTunnel = &cli.Command{
 					port = "22"
 				}
 				slog.Info("starting tunnel", "subnet", subnets, "host", host, "port", port)
+				status, err := tunnel.Claim(c.String("app"), c.String("stage"), subnets)
+				if err != nil {
+					return err
+				}
+				defer status.Release()
 				var wg errgroup.Group
 				wg.Go(func() error {
 					defer c.Cancel()
+					key := []byte(os.Getenv("SSH_PRIVATE_KEY"))
+					if c.String("transport") == "ssm" {
+						return tunnel.StartSSMProxy(c.Context, status, user, host+":"+port, key)
+					}
 					return tunnel.StartProxy(
 						c.Context,
+						status,
 						user,
 						host+":"+port,
-						[]byte(os.Getenv("SSH_PRIVATE_KEY")),
+						key,
 					)
 				})
-				err := tunnel.Start(subnets...)
+				err = tunnel.Start(status, subnets...)
 				if err != nil {
 					return err
 				}
-				slog.Info("tunnel started")
+				slog.Info("tunnel started", "interface", status.Interface())
 				<-c.Context.Done()
-				tunnel.Stop()
+				tunnel.Stop(status)
 				err = wg.Wait()
 				if err != nil {
 					slog.Error("failed to start tunnel", "error", err)
@@ -232,3 +386,62 @@ var CmdTunnel = &cli.Command{
 		},
 	},
 }
+
+func printTunnel(status *tunnel.Status) {
+	style := ui.TEXT_SUCCESS_BOLD
+	switch status.State {
+	case tunnel.StateConnecting, tunnel.StateReconnecting:
+		style = ui.TEXT_WARNING_BOLD
+	case tunnel.StateStopped:
+		style = ui.TEXT_DANGER_BOLD
+	}
+	fmt.Println(style.Render("●") + "  " + ui.TEXT_NORMAL.Render(status.Key()+" "+status.State) + " " + ui.TEXT_DIM.Render("over "+status.Transport+" since "+status.Since.Format(time.Kitchen)))
+	fmt.Println("   " + ui.TEXT_DIM.Render(status.Interface()+" → "+strings.Join(status.Subnets, ", ")))
+	if status.Reconnects > 0 {
+		fmt.Println("   " + ui.TEXT_DIM.Render(fmt.Sprintf("Reconnected %d times", status.Reconnects)))
+	}
+	if status.Error != "" {
+		fmt.Println("   " + ui.TEXT_DIM.Render(status.Error))
+	}
+}
+
+// watchTunnel publishes the status of the tunnel started by `sst dev` so the
+// sst pane shows when it drops and reconnects.
+func watchTunnel(ctx context.Context, app, stage string) {
+	start := time.Now()
+	last := ""
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		status, err := tunnel.Find(app, stage)
+		if err != nil {
+			continue
+		}
+		// the slot is released when the tunnel exits
+		if status == nil {
+			if last != "" && last != tunnel.StateStopped {
+				last = tunnel.StateStopped
+				bus.Publish(&project.TunnelStatusEvent{State: tunnel.StateStopped})
+			}
+			continue
+		}
+		if status.State == last {
+			continue
+		}
+		// left over from an earlier tunnel
+		if last == "" && status.Since.Before(start) {
+			continue
+		}
+		last = status.State
+		bus.Publish(&project.TunnelStatusEvent{
+			State:      status.State,
+			Error:      status.Error,
+			Reconnects: status.Reconnects,
+		})
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project/common"
+	"github.com/sst/ion/pkg/types"
+	"github.com/sst/ion/pkg/types/dotenv"
+	"github.com/sst/ion/pkg/types/golang"
+	"github.com/sst/ion/pkg/types/python"
+	"github.com/sst/ion/pkg/types/rust"
+)
+
+// typesDefaultOut is where the bindings of each language are written if
+// --out is not set, relative to the current directory.
+var typesDefaultOut = map[string]string{
+	"go":     filepath.Join("sstresource", "resource.go"),
+	"python": "sst_resource.py",
+	"rust":   "sst_resource.rs",
+}
+
+var CmdTypes = &cli.Command{
+	Name: "types",
+	Description: cli.Description{
+		Short: "Generate the types of your linked resources",
+		Long: strings.Join([]string{
+			"Generate the types of the resources your app links, as they were last deployed.",
+			"",
+			"Without `--lang` it writes the same files a deploy does, like the `sst-env.d.ts` next to each `package.json`.",
+			"",
+			"```bash frame=\"none\"",
+			"sst types",
+			"```",
+			"",
+			"With `--lang` it writes bindings for services that are not in TypeScript. They read each resource from its `SST_RESOURCE_` environment variable into a typed value.",
+			"",
+			"- `go`: a package with a function for each resource, to `sstresource/resource.go`.",
+			"- `python`: a module with a `Resource` class, to `sst_resource.py`.",
+			"- `rust`: a module with a function for each resource, to `sst_resource.rs`. It needs the `serde` and `serde_json` crates.",
+			"",
+			"```bash frame=\"none\"",
+			"sst types --lang go --out internal/resource/resource.go",
+			"```",
+			"",
+			"A `.env.sst` template with the variables is written next to them. Run your service with `sst shell` to have them set.",
+		}, "\n"),
+	},
+	Flags: []cli.Flag{
+		{
+			Name: "lang",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The language of the bindings",
+				Long:  "The language to generate bindings for, one of `go`, `python`, or `rust`.",
+			},
+			Complete: func([]string) []string {
+				return []string{"go", "python", "rust"}
+			},
+		},
+		{
+			Name: "out",
+			Type: "string",
+			Description: cli.Description{
+				Short: "The file to write the bindings to",
+				Long:  "The file to write the bindings to. For `go` the name of its directory is the name of the package.",
+			},
+		},
+	},
+	Run: CmdTypesRun,
+}
+
+func CmdTypesRun(c *cli.Cli) error {
+	lang := c.String("lang")
+	if _, ok := typesDefaultOut[lang]; lang != "" && !ok {
+		return util.NewReadableError(nil, "Invalid --lang "+lang+", use one of go, python or rust")
+	}
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+	complete, err := p.GetCompleted(c.Context)
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the state of stage "+p.App().Stage)
+	}
+	links := common.Links{}
+	for name, link := range complete.Links {
+		links[name] = link
+	}
+	if lang == "" {
+		if err := types.Generate(p.PathConfig(), links); err != nil {
+			return err
+		}
+		output.Result(map[string]interface{}{"resources": len(links)})
+		ui.Success("Generated types for " + fmt.Sprint(len(links)) + " resources")
+		return nil
+	}
+	if _, ok := links["App"]; !ok {
+		links["App"] = common.Link{Properties: map[string]interface{}{
+			"name":  p.App().Name,
+			"stage": p.App().Stage,
+		}}
+	}
+
+	out := c.String("out")
+	if out == "" {
+		out = typesDefaultOut[lang]
+	}
+	var data []byte
+	switch lang {
+	case "go":
+		data, err = golang.Bindings(goPackage(filepath.Dir(out)), links)
+		if err != nil {
+			return err
+		}
+	case "python":
+		data = python.Bindings(links)
+	case "rust":
+		data = rust.Bindings(links)
+	}
+	if err := os.MkdirAll(filepath.Dir(out), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		return util.NewReadableError(err, "Could not write "+out)
+	}
+	env := filepath.Join(filepath.Dir(out), ".env.sst")
+	if err := os.WriteFile(env, dotenv.Template(links), 0644); err != nil {
+		return util.NewReadableError(err, "Could not write "+env)
+	}
+	output.Result(map[string]interface{}{
+		"file":      out,
+		"env":       env,
+		"resources": len(links),
+	})
+	ui.Success("Generated " + lang + " bindings for " + fmt.Sprint(len(links)) + " resources in " + out)
+	return nil
+}
+
+var invalidPackage = regexp.MustCompile(`[^a-z0-9_]`)
+
+// goPackage is the package name for the directory of the bindings.
+func goPackage(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+	name := invalidPackage.ReplaceAllString(strings.ToLower(filepath.Base(abs)), "")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		return "sstresource"
+	}
+	return name
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"os"
 
 	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
 	"github.com/sst/ion/cmd/sst/cli"
@@ -12,12 +13,48 @@ import (
 	"github.com/sst/ion/cmd/sst/mosaic/dev"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"github.com/sst/ion/cmd/sst/mosaic/ui/common"
+	"github.com/sst/ion/cmd/sst/mosaic/watcher"
 	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/runtime"
 	"github.com/sst/ion/pkg/server"
 )
 
+// functionEvents and stackEvents are the events the ui renders in the
+// function and sst panes.
+var functionEvents = []interface{}{
+	cloudflare.WorkerBuildEvent{},
+	cloudflare.WorkerUpdatedEvent{},
+	cloudflare.WorkerInvokedEvent{},
+	aws.FunctionInvokedEvent{},
+	aws.FunctionResponseEvent{},
+	aws.FunctionErrorEvent{},
+	aws.FunctionLogEvent{},
+	aws.FunctionBuildEvent{},
+	runtime.InspectEvent{},
+}
+
+var stackEvents = []interface{}{
+	common.StdoutEvent{},
+	deployer.DeployFailedEvent{},
+	project.StackCommandEvent{},
+	project.ConcurrentUpdateEvent{},
+	project.BuildFailedEvent{},
+	apitype.ResourcePreEvent{},
+	apitype.ResOpFailedEvent{},
+	apitype.ResOutputsEvent{},
+	apitype.DiagnosticEvent{},
+	project.CompleteEvent{},
+	provider.AwsLoginEvent{},
+	provider.AwsLoginCompleteEvent{},
+	provider.CredentialsHealthEvent{},
+	project.TunnelStatusEvent{},
+	server.LogEvent{},
+	watcher.WatcherStatsEvent{},
+}
+
 func CmdUI(c *cli.Cli) error {
-	url, err := server.Discover("", "")
+	url, token, err := discoverServer(c, "", "")
 	if err != nil {
 		return err
 	}
@@ -27,6 +64,13 @@ func CmdUI(c *cli.Cli) error {
 	opts := []ui.Option{
 		ui.WithDev,
 	}
+	if os.Getenv("SST_MULTIPLEXER") != "" {
+		opts = append(opts, ui.WithTags)
+	}
+	// the dashboard is only reachable from a browser over tcp
+	if !server.IsLocal(url) {
+		opts = append(opts, ui.WithDashboard(dev.DashboardURL(url, token)))
+	}
 	if filter == "function" || filter == "" {
 		if err != nil {
 			return err
@@ -37,41 +81,33 @@ func CmdUI(c *cli.Cli) error {
 			fmt.Println(ui.TEXT_DIM.Render("Waiting for invocations..."))
 			fmt.Println()
 		}
-		types = append(types,
-			cloudflare.WorkerBuildEvent{},
-			cloudflare.WorkerUpdatedEvent{},
-			cloudflare.WorkerInvokedEvent{},
-			aws.FunctionInvokedEvent{},
-			aws.FunctionResponseEvent{},
-			aws.FunctionErrorEvent{},
-			aws.FunctionLogEvent{},
-			aws.FunctionBuildEvent{},
-		)
+		types = append(types, functionEvents...)
 	}
 	if filter == "sst" || filter == "" {
 		u = ui.New(c.Context, ui.WithDev)
-		types = append(types,
-			common.StdoutEvent{},
-			deployer.DeployFailedEvent{},
-			project.StackCommandEvent{},
-			project.ConcurrentUpdateEvent{},
-			project.StackCommandEvent{},
-			project.BuildFailedEvent{},
-			apitype.ResourcePreEvent{},
-			apitype.ResOpFailedEvent{},
-			apitype.ResOutputsEvent{},
-			apitype.DiagnosticEvent{},
-			project.CompleteEvent{},
-		)
+		types = append(types, stackEvents...)
 	}
-	evts, err := dev.Stream(c.Context, url, types...)
+	evts, err := dev.Connect(c.Context, &dev.ConnectInput{
+		URL:     url,
+		Token:   token,
+		Version: version,
+		Types:   types,
+		OnError: func(err *dev.DecodeError) {
+			slog.Error("could not decode event from server", "err", err)
+		},
+		OnDisconnect: func(err error) {
+			if err != nil {
+				slog.Error("lost connection to server", "err", err)
+			}
+		},
+	})
 	if err != nil {
 		return err
 	}
 	u = ui.New(c.Context, opts...)
 	slog.Info("initialized ui")
 	if filter == "sst" || filter == "" {
-		err = dev.Deploy(c.Context, url)
+		err = dev.Deploy(c.Context, url, token)
 	}
 	if err != nil {
 		return err
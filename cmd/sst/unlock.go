@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+func CmdUnlock(c *cli.Cli) error {
+	p, err := c.InitProject()
+	if err != nil {
+		return err
+	}
+	defer p.Cleanup()
+
+	lock, err := provider.GetLock(p.Backend(), p.App().Name, p.App().Stage)
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the lock of stage "+p.App().Stage)
+	}
+	if lock != nil && !lock.Expires.IsZero() && !lock.Expired() {
+		if !c.Bool("force") {
+			holder := lock.Holder
+			if holder == "" {
+				holder = "an unknown machine"
+			}
+			return util.NewReadableError(nil, fmt.Sprintf(
+				"The lock is held by `sst %s` on %s and was renewed %s ago. Pass in --force to break it anyway.",
+				lock.Command,
+				holder,
+				time.Until(lock.Expires.Add(-provider.LockLease)).Abs().Round(time.Second),
+			))
+		}
+		err = interruptUpdate(p, lock, "lock broken by "+provider.LockHolder()+" with `sst unlock --force`")
+		if err != nil {
+			return util.NewReadableError(err, "Could not record the broken lock")
+		}
+	}
+
+	err = p.Cancel()
+	if err != nil {
+		return err
+	}
+	color.New(color.FgGreen, color.Bold).Print("✓ ")
+	color.New(color.FgWhite).Print(" Unlocked the app state for: ")
+	color.New(color.FgWhite, color.Bold).Println(p.App().Name, "/", p.App().Stage)
+	return nil
+}
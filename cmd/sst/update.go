@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/briandowns/spinner"
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+)
+
+func CmdUpdate(c *cli.Cli) error {
+	cfgPath, err := c.Discover()
+	if err != nil {
+		return err
+	}
+	stage, err := c.Stage(cfgPath)
+	if err != nil {
+		return err
+	}
+	p, err := project.New(&project.ProjectConfig{
+		Version: version,
+		Config:  cfgPath,
+		Stage:   stage,
+	})
+	if err != nil {
+		return err
+	}
+
+	spin := spinner.New(spinner.CharSets[14], 100*time.Millisecond)
+	defer spin.Stop()
+	spin.Suffix = "  Updating providers..."
+	if !output.Enabled() {
+		spin.Start()
+	}
+	if !p.CheckPlatform(version) {
+		err := p.CopyPlatform(version)
+		if err != nil {
+			return err
+		}
+	}
+	names := []string{}
+	if name := c.Positional(0); name != "" {
+		names = append(names, name)
+	}
+	changes, err := p.Update(names...)
+	if err != nil {
+		return util.NewReadableError(err, "Could not update the providers: "+err.Error())
+	}
+	spin.Stop()
+
+	if output.Enabled() {
+		output.Result(changes)
+		return nil
+	}
+	for _, change := range changes {
+		fmt.Print(ui.TEXT_SUCCESS_BOLD.Render(ui.IconCheck) + "  ")
+		if change.Old == "" {
+			fmt.Println(ui.TEXT_NORMAL.Render(change.Name) + " " + ui.TEXT_DIM.Render(change.New))
+			continue
+		}
+		fmt.Println(ui.TEXT_NORMAL.Render(change.Name) + " " + ui.TEXT_DIM.Render(change.Old+" → "+change.New))
+	}
+	if len(changes) == 0 {
+		fmt.Println(ui.TEXT_SUCCESS_BOLD.Render(ui.IconCheck) + "  " + ui.TEXT_NORMAL.Render("The providers are up to date"))
+	}
+	fmt.Println("   " + ui.TEXT_DIM.Render("Locked to sst v"+version+" in sst.lock"))
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -9,16 +10,36 @@ import (
 	"github.com/fatih/color"
 	"github.com/sst/ion/cmd/sst/cli"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
+	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/global"
 )
 
 func CmdUpgrade(c *cli.Cli) error {
+	config, err := global.LoadUpgradeConfig()
+	if err != nil {
+		return util.NewReadableError(err, "Could not read the upgrade settings")
+	}
+	if channel := c.String("channel"); channel != "" {
+		config.Channel = channel
+	}
+	switch pin := c.String("version"); pin {
+	case "":
+	case "latest":
+		config.Version = ""
+	default:
+		config.Version = pin
+	}
+
 	if os.Getenv("npm_config_user_agent") != "" {
 		updated, err := global.UpgradeNode(
 			version,
 			c.Positional(0),
+			config,
 		)
 		if err != nil {
+			return upgradeError(err)
+		}
+		if err := config.Save(); err != nil {
 			return err
 		}
 		hasAny := false
@@ -60,17 +81,41 @@ func CmdUpgrade(c *cli.Cli) error {
 	newVersion, err := global.Upgrade(
 		version,
 		c.Positional(0),
+		config,
 	)
 	if err != nil {
+		return upgradeError(err)
+	}
+	if err := config.Save(); err != nil {
 		return err
 	}
 	newVersion = strings.TrimPrefix(newVersion, "v")
 	fmt.Print(ui.TEXT_SUCCESS_BOLD.Render(ui.IconCheck))
 	if newVersion == version {
 		color.New(color.FgWhite).Printf("  Already on latest %s\n", version)
+		color.New(color.FgHiBlack).Println("   " + upgradeTarget(config))
 	} else {
 		color.New(color.FgWhite).Printf("  Upgraded %s ➜ ", version)
 		color.New(color.FgCyan, color.Bold).Println(newVersion)
 	}
 	return nil
 }
+
+func upgradeTarget(config *global.UpgradeConfig) string {
+	if config.Version != "" {
+		return fmt.Sprintf("%s channel, pinned to %s", config.Channel, config.Version)
+	}
+	return config.Channel + " channel"
+}
+
+func upgradeError(err error) error {
+	switch {
+	case errors.Is(err, global.ErrUnknownChannel):
+		return util.NewReadableError(err, "Unknown channel, use stable, beta, or edge")
+	case errors.Is(err, global.ErrNoVersion):
+		return util.NewReadableError(err, strings.ToUpper(err.Error()[:1])+err.Error()[1:])
+	case errors.Is(err, global.ErrChecksumMismatch):
+		return util.NewReadableError(err, "The downloaded release does not match its checksum, nothing was installed")
+	}
+	return err
+}
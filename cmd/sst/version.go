@@ -5,6 +5,7 @@ import (
 
 	"github.com/pulumi/pulumi/sdk/v3"
 	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/pkg/cli/output"
 	"github.com/sst/ion/pkg/global"
 )
 
@@ -15,6 +16,11 @@ var CmdVersion = &cli.Command{
 		Long:  `Prints the current version of the CLI.`,
 	},
 	Run: func(cli *cli.Cli) error {
+		output.Result(map[string]interface{}{
+			"version": version,
+			"pulumi":  sdk.Version.String(),
+			"config":  global.ConfigDir(),
+		})
 		fmt.Println("sst", version)
 		if cli.Bool("verbose") {
 			fmt.Println("pulumi", sdk.Version)
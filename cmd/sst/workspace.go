@@ -0,0 +1,86 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sst/ion/cmd/sst/cli"
+	"github.com/sst/ion/cmd/sst/mosaic/multiplexer"
+	"github.com/sst/ion/internal/fs"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/cli/output"
+	"github.com/sst/ion/pkg/project"
+)
+
+// workspaceDevApps returns the apps `sst dev` runs together, if it runs
+// several. That is when more than one is picked with --app, or when it is run
+// at the root of a workspace that is not an app itself.
+func workspaceDevApps(c *cli.Cli) (*project.Workspace, []project.WorkspaceApp, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+	names := c.String("app")
+	if names != "" && !strings.Contains(names, ",") {
+		return nil, nil, nil
+	}
+	workspace, err := project.DiscoverWorkspace(cwd)
+	if err != nil || workspace == nil {
+		return nil, nil, err
+	}
+	if names == "" {
+		if cwd != workspace.Root || fs.Exists(filepath.Join(cwd, "sst.config.ts")) {
+			return nil, nil, nil
+		}
+		apps, err := workspace.DevApps()
+		if err != nil {
+			return nil, nil, err
+		}
+		return workspace, apps, nil
+	}
+	apps := []project.WorkspaceApp{}
+	for _, name := range strings.Split(names, ",") {
+		app, err := workspace.Find(strings.TrimSpace(name))
+		if err != nil {
+			return nil, nil, err
+		}
+		apps = append(apps, *app)
+	}
+	return workspace, apps, nil
+}
+
+// CmdWorkspaceDev runs `sst dev` for each of the apps in a pane of its own,
+// all in the same stage. The multiplexer can not be nested so they run in
+// basic mode.
+func CmdWorkspaceDev(c *cli.Cli, workspace *project.Workspace, apps []project.WorkspaceApp) error {
+	if c.String("mode") != "" || output.Enabled() {
+		return util.NewReadableError(nil, "Running several apps together needs the multiplexer, run `sst dev --app <name>` for each of them instead")
+	}
+	if len(apps) == 0 {
+		return util.NewReadableError(nil, "There are no apps in the workspace")
+	}
+	stage, err := c.Stage(apps[0].Config)
+	if err != nil {
+		return util.NewReadableError(err, "Could not find stage")
+	}
+	currentExecutable, _ := os.Executable()
+	if err := os.MkdirAll(filepath.Join(workspace.Root, ".sst"), 0755); err != nil {
+		return err
+	}
+	multi := multiplexer.New(c.Context, multiplexer.WithState(filepath.Join(workspace.Root, ".sst", "multiplexer.json")))
+	for _, app := range apps {
+		multi.AddProcess(
+			app.Name,
+			[]string{currentExecutable, "dev", "--app", app.Name, "--stage", stage, "--mode", "basic"},
+			"⑆",
+			app.Name,
+			filepath.Dir(app.Config),
+			true,
+			true,
+			c.Env()...,
+		)
+	}
+	multi.Start()
+	return nil
+}
@@ -0,0 +1,8 @@
+package fs
+
+import "golang.org/x/sys/unix"
+
+// cloneFile makes dest a copy-on-write clone of src, apfs supports them.
+func cloneFile(src, dest string) error {
+	return unix.Clonefile(src, dest, unix.CLONE_NOFOLLOW)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package fs
+
+import "errors"
+
+func cloneFile(src, dest string) error {
+	return errors.ErrUnsupported
+}
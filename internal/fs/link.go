@@ -0,0 +1,69 @@
+package fs
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+)
+
+// LinkTree recreates the files of src in dest without copying their data
+// where the filesystem allows it. Files are cloned where copy-on-write is
+// supported and hardlinked otherwise, they are only copied as a last resort,
+// like across devices. Files that mutable matches are never hardlinked, they
+// are changed in place and src would change with them. Symlinks are
+// recreated as they are.
+func LinkTree(src, dest string, mutable func(rel string) bool) error {
+	return filepath.WalkDir(src, func(path string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dest, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+		// like the bins of node_modules, they point somewhere relative to
+		// themselves
+		if entry.Type()&iofs.ModeSymlink != 0 {
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		}
+		if err := cloneFile(path, target); err == nil {
+			return nil
+		}
+		if !mutable(rel) {
+			if err := os.Link(path, target); err == nil {
+				return nil
+			}
+		}
+		return copyFile(path, target)
+	})
+}
+
+func copyFile(src, dest string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,37 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLinkTree(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "store")
+	os.MkdirAll(filepath.Join(src, "src", "components"), 0755)
+	os.WriteFile(filepath.Join(src, "src", "components", "index.ts"), []byte("export {}"), 0644)
+	os.WriteFile(filepath.Join(src, "package.json"), []byte(`{"dependencies":{}}`), 0644)
+	os.MkdirAll(filepath.Join(src, "node_modules", ".bin"), 0755)
+	os.Symlink("../tsc/bin/tsc", filepath.Join(src, "node_modules", ".bin", "tsc"))
+
+	dest := filepath.Join(t.TempDir(), "platform")
+	err := LinkTree(src, dest, func(rel string) bool {
+		return rel == "package.json"
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dest, "src", "components", "index.ts"))
+	if err != nil || string(data) != "export {}" {
+		t.Errorf("Unexpected linked file %q %v", data, err)
+	}
+
+	if link, err := os.Readlink(filepath.Join(dest, "node_modules", ".bin", "tsc")); err != nil || link != "../tsc/bin/tsc" {
+		t.Errorf("Expected the symlink to be recreated, got %q %v", link, err)
+	}
+
+	os.WriteFile(filepath.Join(dest, "package.json"), []byte(`{"dependencies":{"@pulumi/aws":"6.0.0"}}`), 0644)
+	if data, _ := os.ReadFile(filepath.Join(src, "package.json")); string(data) != `{"dependencies":{}}` {
+		t.Errorf("Expected changing a mutable file to leave the source alone, got %s", data)
+	}
+}
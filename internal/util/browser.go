@@ -0,0 +1,25 @@
+package util
+
+import (
+	"log/slog"
+	"os/exec"
+	"runtime"
+)
+
+// OpenBrowser opens url in the default browser. It does not wait for it and
+// a failure is only logged, the url is printed for the user as well.
+func OpenBrowser(url string) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+	err := cmd.Start()
+	if err != nil {
+		slog.Info("could not open browser", "err", err)
+	}
+}
@@ -2,6 +2,7 @@ package util
 
 import (
 	"crypto/rand"
+	"strings"
 	"sync"
 )
 
@@ -94,3 +95,16 @@ func (m *SyncMap[K, V]) Range(f func(key K, value V) bool) {
 	m.m.Range(func(key, value any) bool { return f(key.(K), value.(V)) })
 }
 func (m *SyncMap[K, V]) Store(key K, value V) { m.m.Store(key, value) }
+
+// DockerEnvArgs returns the arguments that pass the variables in env, as
+// NAME=value, to a container. Only the names are passed so the values are not
+// in the arguments of the process, docker reads them from its own
+// environment.
+func DockerEnvArgs(env []string) []string {
+	args := make([]string, 0, len(env)*2)
+	for _, item := range env {
+		name, _, _ := strings.Cut(item, "=")
+		args = append(args, "--env", name)
+	}
+	return args
+}
@@ -0,0 +1,28 @@
+//go:build !windows
+
+package util
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// InterruptProcess asks the process to stop, like a ctrl+c would.
+func InterruptProcess(process *os.Process) error {
+	return process.Signal(syscall.SIGINT)
+}
+
+// ProcessAlive reports if a process with the pid is running.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// EPERM means it is alive but belongs to another user, like root
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
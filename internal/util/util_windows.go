@@ -1,23 +1,37 @@
 package util
 
 import (
+	"errors"
 	"os"
 	"os/exec"
+	"strconv"
 	"syscall"
+	"time"
+
+	"golang.org/x/sys/windows"
 )
 
-// https://github.com/go-cmd/cmd/blob/master/cmd_windows.go
+// windows has no process groups to signal, taskkill stops the process and
+// the ones it started.
 func TerminateProcess(pid int) error {
-	p, err := os.FindProcess(pid)
-	if err != nil {
+	err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(pid)).Run()
+	if err == nil {
+		return nil
+	}
+	p, findErr := os.FindProcess(pid)
+	if findErr != nil {
 		return err
 	}
 	return p.Kill()
 }
 
-// https://github.com/go-cmd/cmd/blob/master/cmd_windows.go
+// the new process group keeps a ctrl+c in the console from reaching the
+// cmd, it is stopped with TerminateProcess like on the other platforms or
+// with InterruptProcess, a ctrl+break can be sent to the group.
 func SetProcessGroupID(cmd *exec.Cmd) {
-	cmd.SysProcAttr = &syscall.SysProcAttr{}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		CreationFlags: windows.CREATE_NEW_PROCESS_GROUP,
+	}
 }
 
 func SetProcessCancel(cmd *exec.Cmd) {
@@ -25,3 +39,52 @@ func SetProcessCancel(cmd *exec.Cmd) {
 		return TerminateProcess(cmd.Process.Pid)
 	}
 }
+
+// interruptTimeout is how long a process has to exit after a ctrl+break
+// before it is terminated.
+const interruptTimeout = 10 * time.Second
+
+// InterruptProcess asks the process to stop with a ctrl+break, the closest
+// windows has to a SIGINT. It only reaches a process started with
+// SetProcessGroupID. One that has not exited after interruptTimeout is
+// terminated.
+func InterruptProcess(process *os.Process) error {
+	pid := process.Pid
+	err := windows.GenerateConsoleCtrlEvent(windows.CTRL_BREAK_EVENT, uint32(pid))
+	if err != nil {
+		return TerminateProcess(pid)
+	}
+	go func() {
+		deadline := time.Now().Add(interruptTimeout)
+		for ProcessAlive(pid) {
+			if time.Now().After(deadline) {
+				TerminateProcess(pid)
+				return
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}()
+	return nil
+}
+
+// stillActive is the exit code GetExitCodeProcess reports for a process that
+// has not exited.
+const stillActive = 259
+
+// ProcessAlive reports if a process with the pid is running.
+func ProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	handle, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// the process belongs to another user, like an elevated tunnel
+		return errors.Is(err, windows.ERROR_ACCESS_DENIED)
+	}
+	defer windows.CloseHandle(handle)
+	var code uint32
+	if err := windows.GetExitCodeProcess(handle, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
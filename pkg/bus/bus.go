@@ -1,33 +1,98 @@
 package bus
 
 import (
+	"context"
 	"log/slog"
+	"path"
 	"reflect"
 	"sync"
 )
 
 var (
 	bus = &EventBus{
-		subscribers: make(map[reflect.Type][]chan interface{}),
-		all:         make([]chan interface{}, 0),
+		all: make([]chan interface{}, 0),
 	}
 )
 
 type EventBus struct {
-	subscribers map[reflect.Type][]chan interface{}
 	mu          sync.RWMutex
 	all         []chan interface{}
+	subscribers []*subscriber
 }
 
-func Subscribe(eventTypes ...interface{}) <-chan interface{} {
-	bus.mu.Lock()
-	defer bus.mu.Unlock()
+type subscriber struct {
+	match   func(event interface{}) bool
+	deliver func(event interface{})
+}
 
-	ch := make(chan interface{}, 10_000)
-	for _, eventType := range eventTypes {
-		t := reflect.TypeOf(eventType)
-		bus.subscribers[t] = append(bus.subscribers[t], ch)
+// Topic is the name events are matched against, the type name without the
+// pointer. For example "project.CompleteEvent".
+func Topic(event interface{}) string {
+	t := reflect.TypeOf(event)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
 	}
+	return t.String()
+}
+
+// Topics returns the topics of each of the example events.
+func Topics(events ...interface{}) []string {
+	result := make([]string, 0, len(events))
+	for _, event := range events {
+		result = append(result, Topic(event))
+	}
+	return result
+}
+
+func matchTopic(patterns []string, topic string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, topic); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribe returns a channel of every published event that is a T. If any
+// topics are passed in, events also have to match one of them. Topics can
+// contain wildcards like "project.*". The channel is closed once ctx is done.
+func Subscribe[T any](ctx context.Context, topics ...string) <-chan T {
+	ch := make(chan T, 10_000)
+	sub := &subscriber{
+		match: func(event interface{}) bool {
+			if _, ok := event.(T); !ok {
+				return false
+			}
+			return matchTopic(topics, Topic(event))
+		},
+		deliver: func(event interface{}) {
+			select {
+			case ch <- event.(T):
+			case <-ctx.Done():
+			}
+		},
+	}
+	bus.mu.Lock()
+	bus.subscribers = append(bus.subscribers, sub)
+	bus.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		bus.mu.Lock()
+		for i, item := range bus.subscribers {
+			if item == sub {
+				bus.subscribers = append(bus.subscribers[:i], bus.subscribers[i+1:]...)
+				break
+			}
+		}
+		bus.mu.Unlock()
+		// Publish holds the read lock while delivering so nothing can be
+		// sending on ch anymore
+		close(ch)
+	}()
 	return ch
 }
 
@@ -46,10 +111,9 @@ func Publish(event interface{}) {
 	bus.mu.RLock()
 	defer bus.mu.RUnlock()
 
-	// Send to type-specific subscribers
-	if chans, found := bus.subscribers[t]; found {
-		for _, ch := range chans {
-			ch <- event
+	for _, sub := range bus.subscribers {
+		if sub.match(event) {
+			sub.deliver(event)
 		}
 	}
 
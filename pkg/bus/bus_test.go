@@ -0,0 +1,41 @@
+package bus
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type alphaEvent struct{ Value int }
+type betaEvent struct{ Value int }
+
+func TestSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	typed := Subscribe[*alphaEvent](ctx)
+	wildcard := Subscribe[any](ctx, "bus.beta*")
+
+	Publish(&betaEvent{Value: 1})
+	Publish(&alphaEvent{Value: 2})
+
+	if evt := <-typed; evt.Value != 2 {
+		t.Errorf("Expected alpha event, got %v", evt)
+	}
+	if evt, ok := (<-wildcard).(*betaEvent); !ok || evt.Value != 1 {
+		t.Errorf("Expected beta event, got %v", evt)
+	}
+	select {
+	case evt := <-wildcard:
+		t.Errorf("Expected no more events, got %v", evt)
+	default:
+	}
+
+	cancel()
+	select {
+	case _, ok := <-typed:
+		if ok {
+			t.Errorf("Expected channel to be closed")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("Expected channel to be closed after cancel")
+	}
+}
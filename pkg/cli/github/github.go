@@ -0,0 +1,221 @@
+// Package github writes the output of the CLI for GitHub Actions, when it
+// runs in a workflow or with --output github.
+//
+// Errors and warnings are printed as workflow commands so they show up as
+// annotations on the run, and the result of the command is appended to the
+// job summary as Markdown.
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelNotice  = "notice"
+)
+
+type Annotation struct {
+	Level   string
+	Title   string
+	Message string
+}
+
+// Change is a resource the command changed, or would change for a diff.
+type Change struct {
+	Op   string
+	Name string
+	Type string
+}
+
+// Summary is the result of a command as it is shown in the job summary.
+type Summary struct {
+	Command string
+	App     string
+	Stage   string
+	Changes []Change
+	Outputs map[string]interface{}
+	Errors  []string
+}
+
+var (
+	mu      sync.Mutex
+	enabled bool
+	command string
+	result  *Summary
+)
+
+// Detect reports if the CLI is running in a GitHub Actions workflow.
+func Detect() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+func Enable(cmd string) {
+	mu.Lock()
+	defer mu.Unlock()
+	enabled = true
+	command = cmd
+}
+
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return enabled
+}
+
+// Annotate prints the annotation on stderr, which keeps stdout clean for
+// --json. The runner picks up workflow commands from both.
+func Annotate(annotation *Annotation) {
+	if !Enabled() {
+		return
+	}
+	fmt.Fprintln(os.Stderr, annotation.String())
+}
+
+func (a *Annotation) String() string {
+	properties := ""
+	if a.Title != "" {
+		properties = " title=" + escapeProperty(a.Title)
+	}
+	return "::" + a.Level + properties + "::" + escapeData(stripColor(a.Message))
+}
+
+// Result sets the summary of the command, it is written by Finish.
+func Result(summary *Summary) {
+	mu.Lock()
+	defer mu.Unlock()
+	result = summary
+}
+
+// Finish appends the summary to the file in $GITHUB_STEP_SUMMARY, with err if
+// the command failed. Commands that do not set a summary only get one when
+// they fail.
+func Finish(err error) error {
+	mu.Lock()
+	summary := result
+	cmd := command
+	active := enabled
+	mu.Unlock()
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if !active || path == "" {
+		return nil
+	}
+	if summary == nil {
+		if err == nil {
+			return nil
+		}
+		summary = &Summary{}
+	}
+	if summary.Command == "" {
+		summary.Command = cmd
+	}
+	file, openErr := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if openErr != nil {
+		return openErr
+	}
+	defer file.Close()
+	_, writeErr := file.WriteString(summary.Markdown(err))
+	return writeErr
+}
+
+// Markdown renders the summary, err is the error the command failed with.
+func (s *Summary) Markdown(err error) string {
+	var b strings.Builder
+	status := "✅"
+	verb := "succeeded"
+	if err != nil || len(s.Errors) > 0 {
+		status = "❌"
+		verb = "failed"
+	}
+	heading := "sst " + s.Command
+	if s.Stage != "" {
+		heading += " to `" + s.Stage + "`"
+	}
+	fmt.Fprintf(&b, "### %s %s %s\n\n", status, heading, verb)
+	if s.App != "" {
+		fmt.Fprintf(&b, "App `%s`, stage `%s`\n\n", s.App, s.Stage)
+	}
+
+	if err != nil {
+		fmt.Fprintf(&b, "```\n%s\n```\n\n", stripColor(err.Error()))
+	}
+	if len(s.Errors) > 0 {
+		b.WriteString("#### Errors\n\n")
+		for _, item := range s.Errors {
+			fmt.Fprintf(&b, "- %s\n", cell(item))
+		}
+		b.WriteString("\n")
+	}
+
+	if s.Changes != nil {
+		b.WriteString("#### Changes\n\n")
+		if len(s.Changes) == 0 {
+			b.WriteString("No changes\n\n")
+		} else {
+			b.WriteString("| | Resource | Type |\n| --- | --- | --- |\n")
+			for _, change := range s.Changes {
+				fmt.Fprintf(&b, "| %s | %s | `%s` |\n", change.Op, cell(change.Name), change.Type)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	if len(s.Outputs) > 0 {
+		b.WriteString("#### Outputs\n\n| Name | Value |\n| --- | --- |\n")
+		keys := make([]string, 0, len(s.Outputs))
+		for key := range s.Outputs {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "| %s | %s |\n", cell(key), cell(valueString(s.Outputs[key])))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// escapeData escapes the message of a workflow command, the same way the
+// actions toolkit does.
+func escapeData(value string) string {
+	value = strings.ReplaceAll(value, "%", "%25")
+	value = strings.ReplaceAll(value, "\r", "%0D")
+	return strings.ReplaceAll(value, "\n", "%0A")
+}
+
+// escapeProperty escapes a property of a workflow command, which also cannot
+// contain the separators of the properties.
+func escapeProperty(value string) string {
+	value = escapeData(value)
+	value = strings.ReplaceAll(value, ":", "%3A")
+	return strings.ReplaceAll(value, ",", "%2C")
+}
+
+var ansiPattern = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+func stripColor(value string) string {
+	return ansiPattern.ReplaceAllString(value, "")
+}
+
+// cell makes value safe to put in a single cell of a Markdown table.
+func cell(value string) string {
+	value = stripColor(value)
+	value = strings.ReplaceAll(value, "|", `\|`)
+	value = strings.ReplaceAll(value, "\r\n", "<br>")
+	return strings.ReplaceAll(value, "\n", "<br>")
+}
+
+func valueString(value interface{}) string {
+	if str, ok := value.(string); ok {
+		return str
+	}
+	data, _ := json.Marshal(value)
+	return string(data)
+}
@@ -0,0 +1,109 @@
+package github
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAnnotation(t *testing.T) {
+	cases := []struct {
+		annotation Annotation
+		expected   string
+	}{
+		{Annotation{Level: LevelError, Message: "failed"}, "::error::failed"},
+		{Annotation{Level: LevelWarning, Title: "MyBucket", Message: "deprecated"}, "::warning title=MyBucket::deprecated"},
+		{Annotation{Level: LevelError, Title: "a:b,c", Message: "100%\r\nsure"}, "::error title=a%3Ab%2Cc::100%25%0D%0Asure"},
+		{Annotation{Level: LevelError, Message: "\x1b[31mred\x1b[0m: fine"}, "::error::red: fine"},
+	}
+	for _, c := range cases {
+		if got := c.annotation.String(); got != c.expected {
+			t.Errorf("expected %q, got %q", c.expected, got)
+		}
+	}
+}
+
+func TestSummaryMarkdown(t *testing.T) {
+	summary := &Summary{
+		Command: "deploy",
+		App:     "web",
+		Stage:   "production",
+		Changes: []Change{
+			{Op: "create", Name: "MyBucket", Type: "sst:aws:Bucket"},
+		},
+		Outputs: map[string]interface{}{
+			"url":  "https://example.com",
+			"list": []interface{}{1, 2},
+			"pipe": "a|b\nc",
+		},
+		Errors: []string{},
+	}
+	got := summary.Markdown(nil)
+	expected := strings.Join([]string{
+		"### ✅ sst deploy to `production` succeeded",
+		"",
+		"App `web`, stage `production`",
+		"",
+		"#### Changes",
+		"",
+		"| | Resource | Type |",
+		"| --- | --- | --- |",
+		"| create | MyBucket | `sst:aws:Bucket` |",
+		"",
+		"#### Outputs",
+		"",
+		"| Name | Value |",
+		"| --- | --- |",
+		"| list | [1,2] |",
+		"| pipe | a\\|b<br>c |",
+		"| url | https://example.com |",
+		"",
+		"",
+	}, "\n")
+	if got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestSummaryMarkdownFailed(t *testing.T) {
+	summary := &Summary{
+		Command: "deploy",
+		App:     "web",
+		Stage:   "dev",
+		Errors:  []string{"bucket already exists"},
+	}
+	got := summary.Markdown(fmt.Errorf("deploy failed"))
+	for _, part := range []string{"### ❌ sst deploy to `dev` failed", "```\ndeploy failed\n```", "- bucket already exists"} {
+		if !strings.Contains(got, part) {
+			t.Errorf("expected %q in\n%s", part, got)
+		}
+	}
+	if strings.Contains(got, "#### Changes") {
+		t.Errorf("expected no changes section in\n%s", got)
+	}
+}
+
+func TestFinish(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "summary.md")
+	t.Setenv("GITHUB_STEP_SUMMARY", path)
+	Enable("deploy")
+	defer func() {
+		enabled = false
+		result = nil
+	}()
+	if err := Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected no summary without a result or an error")
+	}
+	if err := Finish(fmt.Errorf("no config")); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "### ❌ sst deploy failed") {
+		t.Errorf("unexpected summary\n%s", data)
+	}
+}
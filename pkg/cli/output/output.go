@@ -0,0 +1,112 @@
+// Package output writes the machine readable output of the CLI when it is run
+// with --json.
+//
+// Every line on stdout is a Record. Events are written as they happen and the
+// last line is either the result of the command or the error it failed with.
+// Anything meant for humans goes to stderr instead.
+package output
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/fatih/color"
+	"github.com/sst/ion/pkg/bus"
+)
+
+// Version is bumped whenever a field of Record is removed or changes meaning.
+const Version = 1
+
+const (
+	KindEvent  = "event"
+	KindResult = "result"
+	KindError  = "error"
+)
+
+type Record struct {
+	Version int    `json:"version"`
+	Kind    string `json:"kind"`
+	Command string `json:"command"`
+	// Type is the type of the event, as used by the event bus
+	Type  string      `json:"type,omitempty"`
+	Data  interface{} `json:"data,omitempty"`
+	Error *Error      `json:"error,omitempty"`
+}
+
+type Error struct {
+	Message string `json:"message"`
+}
+
+var (
+	mu      sync.Mutex
+	out     io.Writer
+	command string
+	result  interface{}
+)
+
+// Enable switches the CLI to JSON mode. Records are written to the current
+// stdout and os.Stdout is pointed at stderr so the rest of the CLI can keep
+// printing for humans without corrupting the JSON stream.
+func Enable(cmd string) {
+	mu.Lock()
+	defer mu.Unlock()
+	out = os.Stdout
+	command = cmd
+	os.Stdout = os.Stderr
+	color.Output = color.Error
+}
+
+func Enabled() bool {
+	mu.Lock()
+	defer mu.Unlock()
+	return out != nil
+}
+
+// Event writes an event as it happens.
+func Event(evt interface{}) {
+	write(&Record{Kind: KindEvent, Type: bus.Topic(evt), Data: evt})
+}
+
+// Result sets the result of the command. It is written by Finish so it is
+// always the last record.
+func Result(data interface{}) {
+	mu.Lock()
+	defer mu.Unlock()
+	result = data
+}
+
+// Finish writes the result of the command, or err if it failed. A command
+// can set a result and still fail, like when drift is found, and then the
+// error carries it.
+func Finish(err error) {
+	mu.Lock()
+	data := result
+	mu.Unlock()
+	if err != nil {
+		write(&Record{Kind: KindError, Data: data, Error: &Error{Message: err.Error()}})
+		return
+	}
+	write(&Record{Kind: KindResult, Data: data})
+}
+
+func write(record *Record) {
+	mu.Lock()
+	defer mu.Unlock()
+	if out == nil {
+		return
+	}
+	record.Version = Version
+	record.Command = command
+	data, err := json.Marshal(record)
+	if err != nil {
+		data, _ = json.Marshal(&Record{
+			Version: Version,
+			Kind:    KindError,
+			Command: command,
+			Error:   &Error{Message: err.Error()},
+		})
+	}
+	out.Write(append(data, '\n'))
+}
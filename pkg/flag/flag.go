@@ -8,8 +8,17 @@ var SST_NO_CLEANUP = os.Getenv("SST_NO_CLEANUP") != ""
 var SST_PASSPHRASE = os.Getenv("SST_PASSPHRASE")
 var SST_PULUMI_PATH = os.Getenv("SST_PULUMI_PATH")
 var SST_PRINT_LOGS = os.Getenv("SST_PRINT_LOGS") != ""
+var SST_LOG_LEVEL = os.Getenv("SST_LOG_LEVEL")
+var SST_LOG_FORMAT = os.Getenv("SST_LOG_FORMAT")
 var SST_BUILD_CONCURRENCY = os.Getenv("SST_BUILD_CONCURRENCY")
 var SST_SKIP_DEPENDENCY_CHECK = os.Getenv("SST_SKIP_DEPENDENCY_CHECK") != ""
 var SST_TELEMETRY_DISABLED = os.Getenv("SST_TELEMETRY_DISABLED") == "1" || os.Getenv("DO_NOT_TRACK") == "1"
 var SST_BUN_VERSION = os.Getenv("SST_BUN_VERSION")
 var NO_BUN = os.Getenv("NO_BUN") != ""
+var SST_NO_SERVER_SOCKET = os.Getenv("SST_NO_SERVER_SOCKET") != ""
+var SST_SERVER_IDLE_TIMEOUT = os.Getenv("SST_SERVER_IDLE_TIMEOUT")
+var SST_NO_BUILD_CACHE = os.Getenv("SST_NO_BUILD_CACHE") != ""
+var SST_NO_INCREMENTAL = os.Getenv("SST_NO_INCREMENTAL") != ""
+var SST_BUILD_CACHE_BUCKET = os.Getenv("SST_BUILD_CACHE_BUCKET")
+var SST_PLUGIN_CACHE = os.Getenv("SST_PLUGIN_CACHE")
+var SST_CA_BUNDLE = os.Getenv("SST_CA_BUNDLE")
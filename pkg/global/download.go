@@ -0,0 +1,117 @@
+package global
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/registry"
+)
+
+// DownloadEvent is the progress of an artifact like pulumi, bun or a plugin
+// being downloaded. Total is 0 when the server does not say how large it is.
+type DownloadEvent struct {
+	Name     string
+	Done     int64
+	Total    int64
+	Finished bool
+}
+
+// downloadsDir keeps the downloads of pulumi and bun, interrupted ones are
+// resumed from their partial file.
+func downloadsDir() string {
+	return filepath.Join(CacheDir(), "downloads")
+}
+
+const progressInterval = 200 * time.Millisecond
+
+// download fetches url into dest and publishes its progress under name. The
+// data is written to dest.partial first, if that exists from an interrupted
+// download only the rest is requested.
+func download(ctx context.Context, name string, url string, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	partial := dest + ".partial"
+	offset := int64(0)
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+	slog.Info("downloading", "name", name, "url", url, "offset", offset)
+	resp, err := registry.Get().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// the server does not do ranges, start over
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the partial file is not a prefix of the artifact anymore
+		os.Remove(partial)
+		if offset > 0 {
+			return download(ctx, name, url, dest)
+		}
+		return fmt.Errorf("unexpected HTTP status from %s: %s", url, resp.Status)
+	default:
+		return fmt.Errorf("unexpected HTTP status from %s: %s", url, resp.Status)
+	}
+	file, err := os.OpenFile(partial, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	total := int64(0)
+	if resp.ContentLength > 0 {
+		total = offset + resp.ContentLength
+	}
+	progress := &progressWriter{
+		event: DownloadEvent{Name: name, Done: offset, Total: total},
+	}
+	if _, err := io.Copy(io.MultiWriter(file, progress), resp.Body); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(partial, dest); err != nil {
+		return err
+	}
+	progress.event.Finished = true
+	bus.Publish(&progress.event)
+	return nil
+}
+
+type progressWriter struct {
+	event DownloadEvent
+	last  time.Time
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.event.Done += int64(len(p))
+	if time.Since(w.last) >= progressInterval {
+		w.last = time.Now()
+		event := w.event
+		bus.Publish(&event)
+	}
+	return len(p), nil
+}
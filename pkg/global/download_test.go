@@ -0,0 +1,50 @@
+package global
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadResume(t *testing.T) {
+	content := bytes.Repeat([]byte("sst"), 1000)
+	ranges := []string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ranges = append(ranges, r.Header.Get("Range"))
+		http.ServeContent(w, r, "artifact", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+	dest := filepath.Join(t.TempDir(), "artifact.tar.gz")
+	os.WriteFile(dest+".partial", content[:1200], 0644)
+
+	if err := download(context.Background(), "artifact", srv.URL, dest); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, content) {
+		t.Errorf("Expected the resumed download to match, got %d bytes", len(data))
+	}
+	if len(ranges) != 1 || ranges[0] != "bytes=1200-" {
+		t.Errorf("Expected one request for the rest, got %v", ranges)
+	}
+	if _, err := os.Stat(dest + ".partial"); !os.IsNotExist(err) {
+		t.Errorf("Expected the partial file to be gone")
+	}
+
+	// a partial file longer than the artifact is thrown away
+	os.WriteFile(dest+".partial", append(content, content...), 0644)
+	if err := download(context.Background(), "artifact", srv.URL, dest); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(dest); !bytes.Equal(data, content) {
+		t.Errorf("Expected the download to start over, got %d bytes", len(data))
+	}
+}
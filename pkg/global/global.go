@@ -4,18 +4,20 @@ import (
 	"archive/zip"
 	"bytes"
 	"compress/gzip"
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
-	"net/http"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
 
 	"github.com/pulumi/pulumi/sdk/v3"
 	"github.com/sst/ion/pkg/flag"
+	"github.com/sst/ion/pkg/registry"
 )
 
 var PULUMI_VERSION = "v" + sdk.Version.String()
@@ -34,7 +36,7 @@ var configDir = (func() string {
 		panic(err)
 	}
 	result := filepath.Join(home, "sst")
-	os.Setenv("PATH", result+"/bin:"+os.Getenv("PATH"))
+	os.Setenv("PATH", filepath.Join(result, "bin")+string(os.PathListSeparator)+os.Getenv("PATH"))
 	os.MkdirAll(result, 0755)
 	os.MkdirAll(filepath.Join(result, "bin"), 0755)
 	return result
@@ -89,27 +91,39 @@ func InstallPulumi() error {
 		fileExtension = ".zip"
 	}
 
-	url := fmt.Sprintf("https://github.com/pulumi/pulumi/releases/download/%v/pulumi-%s-%s%s", PULUMI_VERSION, PULUMI_VERSION, osArch, fileExtension)
-	slog.Info("pulumi downloading", "url", url)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to download pulumi: HTTP status %d", resp.StatusCode)
+	filename := fmt.Sprintf("pulumi-%s-%s%s", PULUMI_VERSION, osArch, fileExtension)
+	var body io.Reader
+	if mirror := registry.Get(); mirror.Enabled() {
+		file, err := mirror.Download(context.Background(), "pulumi/"+filename)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		body = file
+	} else {
+		url := fmt.Sprintf("https://github.com/pulumi/pulumi/releases/download/%v/%s", PULUMI_VERSION, filename)
+		dest := filepath.Join(downloadsDir(), filename)
+		if err := download(context.Background(), "pulumi", url, dest); err != nil {
+			return fmt.Errorf("failed to download pulumi: %w", err)
+		}
+		defer os.Remove(dest)
+		file, err := os.Open(dest)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		body = file
 	}
 
 	tmp := filepath.Join(BinPath(), ".tmp")
-	err = os.MkdirAll(tmp, 0755)
+	err := os.MkdirAll(tmp, 0755)
 	if err != nil {
 		return err
 	}
 	switch fileExtension {
 	case ".tar.gz":
-		gzr, err := gzip.NewReader(resp.Body)
+		gzr, err := gzip.NewReader(body)
 		if err != nil {
 			return err
 		}
@@ -119,8 +133,15 @@ func InstallPulumi() error {
 			return err
 		}
 
-	default:
-		panic("cannot extract zip file for pulumi")
+	case ".zip":
+		data, err := io.ReadAll(body)
+		if err != nil {
+			return err
+		}
+		err = unzip(bytes.NewReader(data), int64(len(data)), tmp)
+		if err != nil {
+			return err
+		}
 	}
 
 	entries, err := os.ReadDir(tmp)
@@ -139,17 +160,30 @@ func InstallPulumi() error {
 }
 
 func PulumiPath() string {
-	return filepath.Join(BinPath(), "pulumi")
+	return filepath.Join(BinPath(), executable("pulumi"))
 }
 
 func BunPath() string {
-	return filepath.Join(BinPath(), "bun")
+	return filepath.Join(BinPath(), executable("bun"))
+}
+
+// executable is the file name of a binary, they end in .exe on windows.
+func executable(name string) string {
+	if runtime.GOOS == "windows" {
+		return name + ".exe"
+	}
+	return name
 }
 
 func BinPath() string {
 	return filepath.Join(configDir, "bin")
 }
 
+// CacheDir holds what is safe to delete, like the build cache.
+func CacheDir() string {
+	return filepath.Join(configDir, "cache")
+}
+
 func CertPath() string {
 	return filepath.Join(configDir, "cert")
 }
@@ -188,25 +222,38 @@ func InstallBun() error {
 		filename = "bun-linux-aarch64.zip"
 	case goos == "linux" && arch == "amd64":
 		filename = "bun-linux-x64-baseline.zip"
+	case goos == "windows" && arch == "amd64":
+		filename = "bun-windows-x64-baseline.zip"
 	default:
 	}
 	if filename == "" {
 		return fmt.Errorf("unsupported platform: %s %s", goos, arch)
 	}
 
-	url := "https://github.com/oven-sh/bun/releases//download/bun-v" + BUN_VERSION + "/" + filename
-	slog.Info("bun downloading", "url", url)
-	response, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer response.Body.Close()
-	if response.StatusCode != http.StatusOK {
-		return fmt.Errorf("bad status: %s", response.Status)
-	}
-	bodyBytes, err := io.ReadAll(response.Body)
-	if err != nil {
-		return err
+	var bodyBytes []byte
+	if mirror := registry.Get(); mirror.Enabled() {
+		file, err := mirror.Download(context.Background(), "bun/bun-v"+BUN_VERSION+"/"+filename)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(file.Name())
+		defer file.Close()
+		bodyBytes, err = io.ReadAll(file)
+		if err != nil {
+			return err
+		}
+	} else {
+		url := "https://github.com/oven-sh/bun/releases//download/bun-v" + BUN_VERSION + "/" + filename
+		dest := filepath.Join(downloadsDir(), "bun-v"+BUN_VERSION+"-"+filename)
+		if err := download(context.Background(), "bun", url, dest); err != nil {
+			return err
+		}
+		defer os.Remove(dest)
+		var err error
+		bodyBytes, err = os.ReadFile(dest)
+		if err != nil {
+			return err
+		}
 	}
 	readerAt := bytes.NewReader(bodyBytes)
 	zipReader, err := zip.NewReader(readerAt, readerAt.Size())
@@ -214,7 +261,7 @@ func InstallBun() error {
 		return err
 	}
 	for _, file := range zipReader.File {
-		if filepath.Base(file.Name) == "bun" {
+		if path.Base(file.Name) == executable("bun") {
 			f, err := file.Open()
 			if err != nil {
 				return err
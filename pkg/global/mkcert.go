@@ -8,6 +8,8 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+
+	"github.com/sst/ion/pkg/network"
 )
 
 const MKCERT_VERSION = "1.4.4"
@@ -46,7 +48,7 @@ func EnsureMkcert() error {
 	url := fmt.Sprintf("https://github.com/FiloSottile/mkcert/releases/download/v%v/mkcert-v%v-%s%s", MKCERT_VERSION, MKCERT_VERSION, osArch, fileExtension)
 	slog.Info("mkcert downloading", "url", url)
 
-	resp, err := http.Get(url)
+	resp, err := network.Client(0).Get(url)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,228 @@
+package global
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sst/ion/pkg/flag"
+	"github.com/sst/ion/pkg/registry"
+	"golang.org/x/sync/errgroup"
+)
+
+// Plugin is a Pulumi resource plugin that a provider package needs.
+type Plugin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	// Server is where the plugin is published if it is not the Pulumi
+	// registry, like github://api.github.com/pulumiverse
+	Server string `json:"server,omitempty"`
+}
+
+const defaultPluginServer = "https://get.pulumi.com/releases/plugins"
+
+// pluginConcurrency is how many plugins are downloaded at once.
+const pluginConcurrency = 4
+
+func (p Plugin) version() string {
+	return "v" + strings.TrimPrefix(p.Version, "v")
+}
+
+// Dir is the directory Pulumi looks for the plugin in under PluginsDir.
+func (p Plugin) Dir() string {
+	return "resource-" + p.Name + "-" + p.version()
+}
+
+// File is the name of the archive of the plugin for this platform.
+func (p Plugin) File() string {
+	return fmt.Sprintf("pulumi-resource-%s-%s-%s-%s.tar.gz", p.Name, p.version(), runtime.GOOS, runtime.GOARCH)
+}
+
+// URL is where the archive is downloaded from, using the same layout as
+// Pulumi for plugins on GitHub releases.
+func (p Plugin) URL() string {
+	server := p.Server
+	if server == "" {
+		server = defaultPluginServer
+	}
+	if org, ok := strings.CutPrefix(server, "github://api.github.com/"); ok {
+		return fmt.Sprintf("https://github.com/%s/pulumi-%s/releases/download/%s/%s", strings.Trim(org, "/"), p.Name, p.version(), p.File())
+	}
+	return strings.TrimSuffix(server, "/") + "/" + p.File()
+}
+
+// PluginsDir is where Pulumi installs plugins, it is shared by every project
+// on the machine.
+func PluginsDir() string {
+	return filepath.Join(configDir, "plugins")
+}
+
+// PluginCacheDir holds the downloaded archives of plugins by their sha256.
+// Point SST_PLUGIN_CACHE at a directory that is kept between runs to reuse
+// them in CI.
+func PluginCacheDir() string {
+	if flag.SST_PLUGIN_CACHE != "" {
+		return flag.SST_PLUGIN_CACHE
+	}
+	return filepath.Join(CacheDir(), "plugins")
+}
+
+// PluginInstalled is true if Pulumi will use the plugin without downloading
+// it.
+func PluginInstalled(plugin Plugin) bool {
+	dir := filepath.Join(PluginsDir(), plugin.Dir())
+	if _, err := os.Stat(dir); err != nil {
+		return false
+	}
+	// pulumi leaves this behind when an install is interrupted
+	if _, err := os.Stat(dir + ".partial"); err == nil {
+		return false
+	}
+	return true
+}
+
+// PrefetchPlugins installs the plugins that are not installed yet, a few at
+// a time. Archives are taken from the cache when they are in it.
+func PrefetchPlugins(ctx context.Context, plugins []Plugin) error {
+	wg, ctx := errgroup.WithContext(ctx)
+	wg.SetLimit(pluginConcurrency)
+	for _, plugin := range plugins {
+		if PluginInstalled(plugin) {
+			slog.Info("plugin already installed", "name", plugin.Name, "version", plugin.Version)
+			continue
+		}
+		wg.Go(func() error {
+			url, expected := plugin.URL(), ""
+			if mirror := registry.Get(); mirror.Enabled() {
+				path := "plugins/" + plugin.File()
+				checksum, err := mirror.Checksum(ctx, path)
+				if err != nil {
+					return err
+				}
+				url, expected = mirror.URL(path), checksum
+			}
+			archive, err := fetchPlugin(ctx, PluginCacheDir(), plugin, url, expected)
+			if err != nil {
+				return fmt.Errorf("failed to download plugin %s %s: %w", plugin.Name, plugin.version(), err)
+			}
+			return installPlugin(archive, PluginsDir(), plugin)
+		})
+	}
+	return wg.Wait()
+}
+
+func pluginIndexPath(cache string, plugin Plugin) string {
+	return filepath.Join(cache, "index", plugin.File())
+}
+
+func pluginBlobPath(cache string, digest string) string {
+	return filepath.Join(cache, "sha256", digest)
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fetchPlugin returns the path of the archive of plugin in cache, downloading
+// it from url if it is missing or does not match its digest. If expected is
+// set the archive has to have that sha256.
+func fetchPlugin(ctx context.Context, cache string, plugin Plugin, url string, expected string) (string, error) {
+	if data, err := os.ReadFile(pluginIndexPath(cache, plugin)); err == nil {
+		digest := strings.TrimSpace(string(data))
+		blob := pluginBlobPath(cache, digest)
+		actual, err := hashFile(blob)
+		if err == nil && actual == digest && (expected == "" || expected == digest) {
+			slog.Info("plugin cache hit", "name", plugin.Name, "version", plugin.Version)
+			return blob, nil
+		}
+		slog.Info("plugin cache entry is invalid", "name", plugin.Name, "digest", digest)
+		os.Remove(blob)
+	}
+
+	if err := os.MkdirAll(filepath.Join(cache, "sha256"), 0755); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Join(cache, "index"), 0755); err != nil {
+		return "", err
+	}
+	// the name is fixed so an interrupted download is resumed
+	tmp := filepath.Join(cache, ".download-"+plugin.File())
+	if err := download(ctx, plugin.Name, url, tmp); err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp)
+	digest, err := hashFile(tmp)
+	if err != nil {
+		return "", err
+	}
+	if expected != "" && digest != expected {
+		return "", fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, plugin.File(), digest, expected)
+	}
+	// renames are atomic, so other processes sharing the cache never see a
+	// partial archive
+	blob := pluginBlobPath(cache, digest)
+	if err := os.Rename(tmp, blob); err != nil {
+		return "", err
+	}
+	index, err := os.CreateTemp(filepath.Join(cache, "index"), ".index-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(index.Name())
+	if _, err := index.WriteString(digest + "\n"); err != nil {
+		index.Close()
+		return "", err
+	}
+	index.Close()
+	if err := os.Rename(index.Name(), pluginIndexPath(cache, plugin)); err != nil {
+		return "", err
+	}
+	return blob, nil
+}
+
+// installPlugin extracts archive next to the plugins directory and moves it
+// into place once it is complete.
+func installPlugin(archive string, plugins string, plugin Plugin) error {
+	if err := os.MkdirAll(plugins, 0755); err != nil {
+		return err
+	}
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	body, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	tmp, err := os.MkdirTemp(plugins, ".install-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	if err := untar(body, tmp); err != nil {
+		return err
+	}
+	target := filepath.Join(plugins, plugin.Dir())
+	os.RemoveAll(target)
+	os.Remove(target + ".partial")
+	return os.Rename(tmp, target)
+}
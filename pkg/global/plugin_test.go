@@ -0,0 +1,91 @@
+package global
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestPluginURL(t *testing.T) {
+	suffix := "-" + runtime.GOOS + "-" + runtime.GOARCH + ".tar.gz"
+	for _, tc := range []struct {
+		plugin Plugin
+		want   string
+	}{
+		{Plugin{Name: "aws", Version: "6.52.0"}, "https://get.pulumi.com/releases/plugins/pulumi-resource-aws-v6.52.0" + suffix},
+		{Plugin{Name: "vercel", Version: "v1.14.3", Server: "github://api.github.com/pulumiverse"}, "https://github.com/pulumiverse/pulumi-vercel/releases/download/v1.14.3/pulumi-resource-vercel-v1.14.3" + suffix},
+		{Plugin{Name: "acme", Version: "0.1.0", Server: "https://plugins.example.com/"}, "https://plugins.example.com/pulumi-resource-acme-v0.1.0" + suffix},
+	} {
+		if got := tc.plugin.URL(); got != tc.want {
+			t.Errorf("URL() = %s, want %s", got, tc.want)
+		}
+	}
+	if got := (Plugin{Name: "aws", Version: "6.52.0"}).Dir(); got != "resource-aws-v6.52.0" {
+		t.Errorf("Dir() = %s", got)
+	}
+}
+
+func pluginArchive(t *testing.T, name string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	content := []byte("#!/bin/sh\n")
+	tw.WriteHeader(&tar.Header{Name: name, Mode: 0755, Size: int64(len(content)), Typeflag: tar.TypeReg})
+	tw.Write(content)
+	tw.Close()
+	gz.Close()
+	return buf.Bytes()
+}
+
+func TestFetchPlugin(t *testing.T) {
+	archive := pluginArchive(t, "pulumi-resource-acme")
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		w.Write(archive)
+	}))
+	defer srv.Close()
+	cache := t.TempDir()
+	plugin := Plugin{Name: "acme", Version: "0.1.0", Server: srv.URL}
+	ctx := context.Background()
+
+	blob, err := fetchPlugin(ctx, cache, plugin, plugin.URL(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fetchPlugin(ctx, cache, plugin, plugin.URL(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 1 {
+		t.Errorf("Expected the second fetch to hit the cache, got %d downloads", hits)
+	}
+
+	os.WriteFile(blob, []byte("corrupted"), 0644)
+	if _, err := fetchPlugin(ctx, cache, plugin, plugin.URL(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if hits != 2 {
+		t.Errorf("Expected a corrupted archive to be downloaded again, got %d downloads", hits)
+	}
+
+	if _, err := fetchPlugin(ctx, t.TempDir(), plugin, plugin.URL(), strings.Repeat("0", 64)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+
+	plugins := t.TempDir()
+	if err := installPlugin(blob, plugins, plugin); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(plugins, plugin.Dir(), "pulumi-resource-acme")); err != nil {
+		t.Errorf("Expected the plugin to be installed: %v", err)
+	}
+}
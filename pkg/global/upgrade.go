@@ -2,22 +2,214 @@ package global
 
 import (
 	"archive/tar"
+	"archive/zip"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strings"
 
+	"github.com/Masterminds/semver/v3"
+	"github.com/sst/ion/pkg/network"
 	"github.com/sst/ion/pkg/npm"
 )
 
-func Upgrade(existingVersion string, nextVersion string) (string, error) {
+const (
+	ChannelStable = "stable"
+	ChannelBeta   = "beta"
+	ChannelEdge   = "edge"
+)
+
+var ErrUnknownChannel = fmt.Errorf("unknown channel")
+var ErrNoVersion = fmt.Errorf("no version to upgrade to")
+var ErrChecksumMismatch = fmt.Errorf("checksum mismatch")
+
+// UpgradeConfig is the channel and version picked with `sst upgrade`, later
+// upgrades stay on them until they are changed.
+type UpgradeConfig struct {
+	Channel string `json:"channel,omitempty"`
+	// Version pins upgrades to a major, minor or patch version, like 3 or 3.1
+	Version string `json:"version,omitempty"`
+}
+
+func upgradeConfigPath() string {
+	return filepath.Join(ConfigDir(), "upgrade.json")
+}
+
+func LoadUpgradeConfig() (*UpgradeConfig, error) {
+	result := &UpgradeConfig{}
+	data, err := os.ReadFile(upgradeConfigPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			result.Channel = ChannelStable
+			return result, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, result)
+	if err != nil {
+		return nil, err
+	}
+	if result.Channel == "" {
+		result.Channel = ChannelStable
+	}
+	return result, nil
+}
+
+func (c *UpgradeConfig) Save() error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(upgradeConfigPath(), data, 0644)
+}
+
+func channelRank(channel string) (int, error) {
+	switch channel {
+	case ChannelStable:
+		return 0, nil
+	case ChannelBeta:
+		return 1, nil
+	case ChannelEdge:
+		return 2, nil
+	}
+	return 0, fmt.Errorf("%w: %s, use stable, beta or edge", ErrUnknownChannel, channel)
+}
+
+// versionChannel is stable for releases, beta for beta and rc prereleases
+// and edge for any other prerelease.
+func versionChannel(version *semver.Version) string {
+	pre := version.Prerelease()
+	switch {
+	case pre == "":
+		return ChannelStable
+	case strings.HasPrefix(pre, "beta"), strings.HasPrefix(pre, "rc"):
+		return ChannelBeta
+	}
+	return ChannelEdge
+}
+
+// pickVersion returns the newest of candidates that is on channel, or on a
+// more stable one, and matches pin. A pin that is exactly one of the
+// candidates is returned as is, whatever its channel.
+func pickVersion(candidates []string, channel string, pin string) (string, error) {
+	rank, err := channelRank(channel)
+	if err != nil {
+		return "", err
+	}
+	pin = strings.TrimPrefix(pin, "v")
+	var result *semver.Version
+	for _, candidate := range candidates {
+		version, err := semver.NewVersion(candidate)
+		if err != nil {
+			continue
+		}
+		str := strings.TrimPrefix(candidate, "v")
+		if pin != "" {
+			if str == pin {
+				return str, nil
+			}
+			if !strings.HasPrefix(str, pin+".") && !strings.HasPrefix(str, pin+"-") {
+				continue
+			}
+		}
+		next, _ := channelRank(versionChannel(version))
+		if next > rank {
+			continue
+		}
+		if result == nil || version.GreaterThan(result) {
+			result = version
+		}
+	}
+	if result == nil {
+		if pin != "" {
+			return "", fmt.Errorf("%w: nothing matches %s on the %s channel", ErrNoVersion, pin, channel)
+		}
+		return "", fmt.Errorf("%w: nothing on the %s channel", ErrNoVersion, channel)
+	}
+	return strings.TrimPrefix(result.Original(), "v"), nil
+}
+
+type release struct {
+	TagName string `json:"tag_name"`
+	Draft   bool   `json:"draft"`
+	Assets  []struct {
+		Name string `json:"name"`
+		URL  string `json:"browser_download_url"`
+	} `json:"assets"`
+}
+
+func (r *release) asset(name string) string {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset.URL
+		}
+	}
+	return ""
+}
+
+func githubGet(url string, out interface{}) error {
+	slog.Info("fetching", "url", url)
+	resp, err := network.Client(0).Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected HTTP status from %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// findRelease looks up nextVersion if it is a full version, otherwise it
+// picks from the most recent releases.
+func findRelease(nextVersion string, config *UpgradeConfig) (*release, error) {
+	if _, err := semver.StrictNewVersion(strings.TrimPrefix(nextVersion, "v")); err == nil {
+		var result release
+		err := githubGet("https://api.github.com/repos/sst/sst/releases/tags/v"+strings.TrimPrefix(nextVersion, "v"), &result)
+		if err != nil {
+			return nil, err
+		}
+		return &result, nil
+	}
+	var releases []*release
+	err := githubGet("https://api.github.com/repos/sst/sst/releases?per_page=100", &releases)
+	if err != nil {
+		return nil, err
+	}
+	tags := []string{}
+	byTag := map[string]*release{}
+	for _, item := range releases {
+		if item.Draft {
+			continue
+		}
+		tags = append(tags, item.TagName)
+		byTag[strings.TrimPrefix(item.TagName, "v")] = item
+	}
+	pin := nextVersion
+	if pin == "" {
+		pin = config.Version
+	}
+	picked, err := pickVersion(tags, config.Channel, pin)
+	if err != nil {
+		return nil, err
+	}
+	return byTag[picked], nil
+}
+
+// Upgrade installs nextVersion, or the latest version allowed by config if it
+// is empty, into ~/.sst/bin. The archive is checked against the checksums
+// published with the release before anything is replaced.
+func Upgrade(existingVersion string, nextVersion string, config *UpgradeConfig) (string, error) {
 	var filename string
 	switch runtime.GOOS {
 	case "darwin":
@@ -38,25 +230,12 @@ func Upgrade(existingVersion string, nextVersion string) (string, error) {
 	default:
 		return "", fmt.Errorf("unsupported architecture")
 	}
-	if nextVersion == "" {
-		resp, err := http.Get("https://api.github.com/repos/sst/sst/releases/latest")
-		if err != nil {
-			return "", err
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			return "", err
-		}
-
-		var releaseInfo struct {
-			TagName string `json:"tag_name"`
-		}
-		if err := json.NewDecoder(resp.Body).Decode(&releaseInfo); err != nil {
-			return "", err
-		}
-		nextVersion = releaseInfo.TagName
+	filename = "sst-" + filename
+	rel, err := findRelease(nextVersion, config)
+	if err != nil {
+		return "", err
 	}
+	nextVersion = rel.TagName
 	if !strings.HasPrefix(nextVersion, "v") {
 		nextVersion = "v" + nextVersion
 	}
@@ -66,9 +245,22 @@ func Upgrade(existingVersion string, nextVersion string) (string, error) {
 	if nextVersion == existingVersion {
 		return nextVersion, nil
 	}
-	url := "https://github.com/sst/sst/releases/download/" + nextVersion + "/sst-" + filename
+
+	checksumsURL := rel.asset("checksums.txt")
+	if checksumsURL == "" {
+		return "", fmt.Errorf("release %s has no checksums.txt", nextVersion)
+	}
+	expected, err := fetchChecksum(checksumsURL, filename)
+	if err != nil {
+		return "", err
+	}
+
+	url := rel.asset(filename)
+	if url == "" {
+		url = "https://github.com/sst/sst/releases/download/" + nextVersion + "/" + filename
+	}
 	slog.Info("downloading", "url", url)
-	resp, err := http.Get(url)
+	resp, err := network.Client(0).Get(url)
 	if err != nil {
 		return "", err
 	}
@@ -78,19 +270,41 @@ func Upgrade(existingVersion string, nextVersion string) (string, error) {
 		return "", fmt.Errorf("unexpected HTTP status when downloading release: %s", resp.Status)
 	}
 
+	archive, err := os.CreateTemp("", "sst-upgrade-*.tar.gz")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(archive.Name())
+	defer archive.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archive, hash), resp.Body); err != nil {
+		return "", err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return "", fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, filename, actual, expected)
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
 
 	sstBinPath := filepath.Join(homeDir, ".sst", "bin")
+	// windows does not let a running binary be deleted, only renamed
+	if runtime.GOOS == "windows" {
+		current := filepath.Join(sstBinPath, executable("sst"))
+		os.Remove(current + ".old")
+		os.Rename(current, current+".old")
+	}
 	os.RemoveAll(sstBinPath)
 	if err := os.MkdirAll(sstBinPath, os.ModePerm); err != nil {
 		return "", err
 	}
 
-	// Assuming we have a variable `resp` which is the response from a *http.Request
-	body, err := gzip.NewReader(resp.Body)
+	body, err := gzip.NewReader(archive)
 	if err != nil {
 		return "", err
 	}
@@ -100,21 +314,52 @@ func Upgrade(existingVersion string, nextVersion string) (string, error) {
 		return "", err
 	}
 
-	if err := os.Chmod(filepath.Join(sstBinPath, "sst"), 0755); err != nil {
+	if err := os.Chmod(filepath.Join(sstBinPath, executable("sst")), 0755); err != nil {
 		return "", err
 	}
 
 	return nextVersion, nil
 }
 
-func UpgradeNode(existingVersion string, nextVersion string) (map[string]string, error) {
+// fetchChecksum returns the sha256 of filename from a goreleaser checksums.txt.
+func fetchChecksum(url string, filename string) (string, error) {
+	slog.Info("downloading", "url", url)
+	resp, err := network.Client(0).Get(url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected HTTP status when downloading checksums: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[1] == filename {
+			return fields[0], nil
+		}
+	}
+	return "", fmt.Errorf("no checksum for %s", filename)
+}
+
+func UpgradeNode(existingVersion string, nextVersion string, config *UpgradeConfig) (map[string]string, error) {
 	result := make(map[string]string)
-	if nextVersion == "" {
-		pkg, err := npm.Get("sst", "latest")
+	if _, err := semver.StrictNewVersion(nextVersion); err != nil {
+		versions, err := npm.Versions("sst")
+		if err != nil {
+			return result, err
+		}
+		pin := nextVersion
+		if pin == "" {
+			pin = config.Version
+		}
+		nextVersion, err = pickVersion(versions, config.Channel, pin)
 		if err != nil {
 			return result, err
 		}
-		nextVersion = pkg.Version
 	}
 
 	files, err := filepath.Glob("**/*/package.json")
@@ -184,3 +429,37 @@ func untar(reader io.Reader, target string) error {
 	}
 	return nil
 }
+
+// unzip extracts the files of the archive into target, without the
+// directories they are in like untar.
+func unzip(reader io.ReaderAt, size int64, target string) error {
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		return err
+	}
+	for _, file := range zipReader.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		in, err := file.Open()
+		if err != nil {
+			return err
+		}
+		outPath := filepath.Join(target, path.Base(file.Name))
+		outFile, err := os.Create(outPath)
+		if err != nil {
+			in.Close()
+			return err
+		}
+		_, err = io.Copy(outFile, in)
+		in.Close()
+		outFile.Close()
+		if err != nil {
+			return err
+		}
+		if err := os.Chmod(outPath, 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
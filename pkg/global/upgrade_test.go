@@ -0,0 +1,70 @@
+package global
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPickVersion(t *testing.T) {
+	candidates := []string{
+		"v3.0.1",
+		"v3.1.0",
+		"v3.1.2",
+		"v3.2.0-beta.1",
+		"v3.2.0-rc.1",
+		"v3.3.0-next.4",
+		"v2.9.9",
+		"not-a-version",
+	}
+	for _, tc := range []struct {
+		channel string
+		pin     string
+		want    string
+	}{
+		{ChannelStable, "", "3.1.2"},
+		{ChannelBeta, "", "3.2.0-rc.1"},
+		{ChannelEdge, "", "3.3.0-next.4"},
+		{ChannelStable, "3.0", "3.0.1"},
+		{ChannelStable, "v2", "2.9.9"},
+		{ChannelBeta, "3.2", "3.2.0-rc.1"},
+		{ChannelStable, "3.2.0-beta.1", "3.2.0-beta.1"},
+		{ChannelStable, "3.1.2", "3.1.2"},
+	} {
+		got, err := pickVersion(candidates, tc.channel, tc.pin)
+		if err != nil {
+			t.Errorf("%s %s: %v", tc.channel, tc.pin, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s %s: got %s, want %s", tc.channel, tc.pin, got, tc.want)
+		}
+	}
+
+	if _, err := pickVersion(candidates, ChannelStable, "3.2"); !errors.Is(err, ErrNoVersion) {
+		t.Errorf("expected ErrNoVersion, got %v", err)
+	}
+	if _, err := pickVersion(candidates, "nightly", ""); !errors.Is(err, ErrUnknownChannel) {
+		t.Errorf("expected ErrUnknownChannel, got %v", err)
+	}
+}
+
+func TestUnzip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("pulumi/bin/")
+	w, _ := zw.Create("pulumi/bin/pulumi.exe")
+	w.Write([]byte("binary"))
+	zw.Close()
+	target := t.TempDir()
+	if err := unzip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), target); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(target, "pulumi.exe"))
+	if err != nil || string(data) != "binary" {
+		t.Fatalf("Expected pulumi.exe at the root of the target, got %q %v", data, err)
+	}
+}
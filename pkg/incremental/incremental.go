@@ -0,0 +1,160 @@
+package incremental
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// Incremental deploys skip the work of a component when what it depends on
+// has not changed since the last time it was done. Each entry is the hash of
+// the inputs of that work and the version the resource doing it was given,
+// reusing the version leaves the resource unchanged.
+
+type Entry struct {
+	Hash    string `json:"hash"`
+	Version string `json:"version"`
+}
+
+// Store keeps the entries of a stage. They are saved with the state of the
+// stage so every machine deploying it sees them, the work is still done again
+// where its output is missing since the output is part of the hash.
+type Store struct {
+	lock    sync.Mutex
+	entries map[string]Entry
+	save    func(entries map[string]Entry) error
+}
+
+// New returns a store with the entries that were saved, save is called with
+// all of them whenever one is put.
+func New(entries map[string]Entry, save func(entries map[string]Entry) error) *Store {
+	if entries == nil {
+		entries = map[string]Entry{}
+	}
+	return &Store{
+		entries: entries,
+		save:    save,
+	}
+}
+
+// Version returns the version to reuse if hash matches the last one recorded
+// under name, or an empty string if the work has to be done again.
+func (s *Store) Version(name, hash string) string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	entry, ok := s.entries[name]
+	if !ok || entry.Hash != hash {
+		return ""
+	}
+	return entry.Version
+}
+
+func (s *Store) Put(name, hash, version string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.entries[name] == (Entry{Hash: hash, Version: version}) {
+		return nil
+	}
+	s.entries[name] = Entry{Hash: hash, Version: version}
+	return s.save(s.entries)
+}
+
+// Skip is what is not walked when hashing sources, dependencies are covered
+// by the lockfile and the rest is not part of the app.
+var Skip = []string{"node_modules", ".git", ".sst"}
+
+// Hash hashes the files under paths and the extra values. Paths can be files
+// or directories, the directories named in skip are not walked. The path of
+// each file relative to base is part of the hash so moving one changes it but
+// checking the app out somewhere else does not, and a path that does not
+// exist hashes differently from an empty one.
+func Hash(base string, paths []string, skip []string, extra ...string) (string, error) {
+	files := map[string]string{}
+	for _, root := range paths {
+		name := root
+		if rel, err := filepath.Rel(base, root); base != "" && err == nil {
+			name = filepath.ToSlash(rel)
+		}
+		info, err := os.Stat(root)
+		if os.IsNotExist(err) {
+			files[name] = "missing"
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if !info.IsDir() {
+			hash, err := hashFile(root)
+			if err != nil {
+				return "", err
+			}
+			files[name] = hash
+			continue
+		}
+		err = filepath.WalkDir(root, func(file string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if entry.IsDir() {
+				if file != root && slices.Contains(skip, entry.Name()) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			rel, _ := filepath.Rel(root, file)
+			key := name + "\x00" + filepath.ToSlash(rel)
+			if entry.Type()&fs.ModeSymlink != 0 {
+				target, err := os.Readlink(file)
+				if err != nil {
+					return err
+				}
+				files[key] = "link:" + target
+				return nil
+			}
+			if !entry.Type().IsRegular() {
+				return nil
+			}
+			hash, err := hashFile(file)
+			if err != nil {
+				return err
+			}
+			files[key] = hash
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	for _, name := range names {
+		io.WriteString(hash, name+"\x00"+files[name]+"\x00")
+	}
+	for _, value := range extra {
+		io.WriteString(hash, "\x01"+value)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
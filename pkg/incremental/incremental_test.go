@@ -0,0 +1,81 @@
+package incremental
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "index.ts"), []byte("export {}"), 0644)
+	os.MkdirAll(filepath.Join(dir, "node_modules", "dep"), 0755)
+	os.WriteFile(filepath.Join(dir, "node_modules", "dep", "index.js"), []byte("a"), 0644)
+
+	first, err := Hash("", []string{dir}, Skip, "npm run build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(filepath.Join(dir, "node_modules", "dep", "index.js"), []byte("b"), 0644)
+	if again, _ := Hash("", []string{dir}, Skip, "npm run build"); again != first {
+		t.Errorf("Expected node_modules to be skipped")
+	}
+	if bundle, _ := Hash("", []string{dir}, nil, "npm run build"); bundle == first {
+		t.Errorf("Expected node_modules to be hashed without skip")
+	}
+	if other, _ := Hash("", []string{dir}, Skip, "npm run build:prod"); other == first {
+		t.Errorf("Expected the extra values to change the hash")
+	}
+	os.Rename(filepath.Join(dir, "index.ts"), filepath.Join(dir, "main.ts"))
+	if moved, _ := Hash("", []string{dir}, Skip, "npm run build"); moved == first {
+		t.Errorf("Expected a moved file to change the hash")
+	}
+
+	missing := filepath.Join(dir, "dist")
+	before, _ := Hash("", []string{missing}, Skip)
+	os.MkdirAll(missing, 0755)
+	if after, _ := Hash("", []string{missing}, Skip); after == before {
+		t.Errorf("Expected a missing path to hash differently from an empty one")
+	}
+}
+
+func TestStore(t *testing.T) {
+	var saved map[string]Entry
+	store := New(nil, func(entries map[string]Entry) error {
+		saved = map[string]Entry{}
+		for name, entry := range entries {
+			saved[name] = entry
+		}
+		return nil
+	})
+	if version := store.Version("WebBuild", "abc"); version != "" {
+		t.Errorf("Expected no version, got %s", version)
+	}
+	if err := store.Put("WebBuild", "abc", "1700000000000"); err != nil {
+		t.Fatal(err)
+	}
+	reopened := New(saved, nil)
+	if version := reopened.Version("WebBuild", "abc"); version != "1700000000000" {
+		t.Errorf("Expected the recorded version, got %s", version)
+	}
+	if version := reopened.Version("WebBuild", "def"); version != "" {
+		t.Errorf("Expected no version for another hash, got %s", version)
+	}
+	// nothing to save if the entry did not change
+	if err := reopened.Put("WebBuild", "abc", "1700000000000"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHashBase(t *testing.T) {
+	first, second := t.TempDir(), t.TempDir()
+	for _, dir := range []string{first, second} {
+		os.MkdirAll(filepath.Join(dir, "web"), 0755)
+		os.WriteFile(filepath.Join(dir, "web", "index.ts"), []byte("export {}"), 0644)
+	}
+	a, _ := Hash(first, []string{filepath.Join(first, "web")}, Skip)
+	b, _ := Hash(second, []string{filepath.Join(second, "web")}, Skip)
+	if a != b {
+		t.Errorf("Expected the same files in another checkout to hash the same")
+	}
+}
@@ -22,6 +22,12 @@ type EvalOptions struct {
 	Define  map[string]string
 }
 
+// ImportPath is path as the specifier of an import in generated code. The
+// backslashes of windows paths would be escapes in the string.
+func ImportPath(path string) string {
+	return filepath.ToSlash(path)
+}
+
 type PackageJson struct {
 	Version         string                 `json:"version"`
 	Dependencies    map[string]string      `json:"dependencies"`
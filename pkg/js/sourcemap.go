@@ -0,0 +1,144 @@
+package js
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SourceMap is a parsed version 3 source map.
+type SourceMap struct {
+	Sources []string
+	Names   []string
+	// lines has the segments of every generated line, sorted by column
+	lines [][]segment
+}
+
+type segment struct {
+	column       int
+	source       int
+	sourceLine   int
+	sourceColumn int
+	name         int
+}
+
+// Position is a location in an original source. Line and Column are zero
+// based like in the source map itself.
+type Position struct {
+	Source string
+	Line   int
+	Column int
+	Name   string
+}
+
+func ParseSourceMap(data []byte) (*SourceMap, error) {
+	var raw struct {
+		Version    int      `json:"version"`
+		Sources    []string `json:"sources"`
+		Names      []string `json:"names"`
+		Mappings   string   `json:"mappings"`
+		SourceRoot string   `json:"sourceRoot"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if raw.Version != 3 {
+		return nil, fmt.Errorf("unsupported source map version %d", raw.Version)
+	}
+	result := &SourceMap{
+		Sources: raw.Sources,
+		Names:   raw.Names,
+	}
+	if raw.SourceRoot != "" {
+		for i, source := range result.Sources {
+			result.Sources[i] = strings.TrimSuffix(raw.SourceRoot, "/") + "/" + source
+		}
+	}
+	// everything but the generated column is relative to the previous
+	// segment in the whole map
+	var source, sourceLine, sourceColumn, name int
+	for _, line := range strings.Split(raw.Mappings, ";") {
+		segments := []segment{}
+		column := 0
+		for _, item := range strings.Split(line, ",") {
+			if item == "" {
+				continue
+			}
+			fields, err := decodeVLQ(item)
+			if err != nil {
+				return nil, err
+			}
+			column += fields[0]
+			if len(fields) < 4 {
+				continue
+			}
+			source += fields[1]
+			sourceLine += fields[2]
+			sourceColumn += fields[3]
+			next := segment{column, source, sourceLine, sourceColumn, -1}
+			if len(fields) > 4 {
+				name += fields[4]
+				next.name = name
+			}
+			segments = append(segments, next)
+		}
+		sort.SliceStable(segments, func(i, j int) bool { return segments[i].column < segments[j].column })
+		result.lines = append(result.lines, segments)
+	}
+	return result, nil
+}
+
+const vlqAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+func decodeVLQ(input string) ([]int, error) {
+	result := []int{}
+	value, shift := 0, 0
+	for _, char := range input {
+		digit := strings.IndexRune(vlqAlphabet, char)
+		if digit == -1 {
+			return nil, fmt.Errorf("invalid character %q in source map mappings", char)
+		}
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+		if value&1 != 0 {
+			result = append(result, -(value >> 1))
+		} else {
+			result = append(result, value>>1)
+		}
+		value, shift = 0, 0
+	}
+	if shift != 0 {
+		return nil, fmt.Errorf("truncated source map mappings %q", input)
+	}
+	return result, nil
+}
+
+// Find returns the original position of the zero based line and column of
+// the generated file. It is the closest mapping at or before the column.
+func (m *SourceMap) Find(line, column int) (Position, bool) {
+	if line < 0 || line >= len(m.lines) {
+		return Position{}, false
+	}
+	segments := m.lines[line]
+	index := sort.Search(len(segments), func(i int) bool { return segments[i].column > column }) - 1
+	if index < 0 {
+		return Position{}, false
+	}
+	match := segments[index]
+	if match.source < 0 || match.source >= len(m.Sources) {
+		return Position{}, false
+	}
+	result := Position{
+		Source: m.Sources[match.source],
+		Line:   match.sourceLine,
+		Column: match.sourceColumn,
+	}
+	if match.name >= 0 && match.name < len(m.Names) {
+		result.Name = m.Names[match.name]
+	}
+	return result, true
+}
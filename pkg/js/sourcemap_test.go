@@ -0,0 +1,54 @@
+package js
+
+import "testing"
+
+// generated by esbuild from src/index.ts with minification
+const testSourceMap = `{
+  "version": 3,
+  "sources": ["src/index.ts"],
+  "mappings": "AAAO,gBAAS,QAAQA,EAAY,CAElC,MAAM,IAAI,MAAM,MAAM,CACxB",
+  "names": ["event"]
+}`
+
+func TestSourceMapFind(t *testing.T) {
+	sourceMap, err := ParseSourceMap([]byte(testSourceMap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		line     int
+		column   int
+		expected Position
+		ok       bool
+	}{
+		{0, 24, Position{"src/index.ts", 0, 24, "event"}, true},
+		{0, 27, Position{"src/index.ts", 2, 2, ""}, true},
+		{0, 35, Position{"src/index.ts", 2, 8, ""}, true},
+		{1, 0, Position{}, false},
+	}
+	for _, test := range tests {
+		result, ok := sourceMap.Find(test.line, test.column)
+		if ok != test.ok || result != test.expected {
+			t.Errorf("Find(%d, %d) = %v, %v, expected %v, %v", test.line, test.column, result, ok, test.expected, test.ok)
+		}
+	}
+}
+
+func TestDecodeVLQ(t *testing.T) {
+	result, err := decodeVLQ("AAgBC")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []int{0, 0, 16, 1}
+	if len(result) != len(expected) {
+		t.Fatalf("decodeVLQ = %v, expected %v", result, expected)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Fatalf("decodeVLQ = %v, expected %v", result, expected)
+		}
+	}
+	if _, err := decodeVLQ("g"); err == nil {
+		t.Error("expected an error for truncated mappings")
+	}
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry has the metrics of the cli, the stage server serves it on
+// /metrics. It is its own registry so nothing a dependency registers on the
+// default one shows up.
+var Registry = prometheus.NewRegistry()
+
+var (
+	// BuildDuration is how long function builds take, including the ones
+	// restored from the cache.
+	BuildDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sst_build_duration_seconds",
+		Help:    "Duration of function builds.",
+		Buckets: []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"runtime", "status"})
+
+	BuildCache = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sst_build_cache_total",
+		Help: "Lookups of function builds in the build cache by result.",
+	}, []string{"result"})
+
+	Invocations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sst_invocations_total",
+		Help: "Live function invocations.",
+	}, []string{"function"})
+
+	InvocationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sst_invocation_errors_total",
+		Help: "Live function invocations that returned an error.",
+	}, []string{"function"})
+
+	InvocationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sst_invocation_duration_seconds",
+		Help:    "Duration of live function invocations, from the request to the response.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"function"})
+
+	StreamClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sst_stream_clients",
+		Help: "Clients connected to the event stream of the server.",
+	})
+
+	WatcherEvents = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sst_watcher_events_total",
+		Help: "File changes picked up by the watcher.",
+	})
+
+	// DeployDuration is how long stack commands take, a deploy in CI is
+	// one of these too.
+	DeployDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sst_deploy_duration_seconds",
+		Help:    "Duration of stack commands like deploy and remove.",
+		Buckets: []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600},
+	}, []string{"command", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		BuildDuration,
+		BuildCache,
+		Invocations,
+		InvocationErrors,
+		InvocationDuration,
+		StreamClients,
+		WatcherEvents,
+		DeployDuration,
+	)
+}
+
+// Status is the status label of an operation that returned err.
+func Status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
+
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
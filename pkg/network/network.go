@@ -0,0 +1,126 @@
+// Package network has the http transport the requests of the CLI go through,
+// so downloads, providers, telemetry and the dev server all work behind a
+// corporate proxy and with a private certificate authority.
+//
+// The proxy comes from HTTPS_PROXY, HTTP_PROXY and NO_PROXY like most tools.
+// Extra certificate authorities are read from the PEM bundle at
+// SST_CA_BUNDLE, or NODE_EXTRA_CA_CERTS since the functions and the config
+// already need it on these networks. They are trusted on top of the ones of
+// the system.
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sst/ion/pkg/flag"
+	"golang.org/x/net/http/httpproxy"
+)
+
+var ErrInvalidBundle = fmt.Errorf("no certificates in bundle")
+
+// base is the default transport of net/http before Install replaces it.
+var base = http.DefaultTransport.(*http.Transport).Clone()
+
+// Bundle is the path of the extra certificate authorities, if there are any.
+func Bundle() string {
+	if flag.SST_CA_BUNDLE != "" {
+		return flag.SST_CA_BUNDLE
+	}
+	return os.Getenv("NODE_EXTRA_CA_CERTS")
+}
+
+func loadRoots(path string) (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidBundle, path)
+	}
+	return pool, nil
+}
+
+var tlsConfig = sync.OnceValues(func() (*tls.Config, error) {
+	path := Bundle()
+	if path == "" {
+		return nil, nil
+	}
+	pool, err := loadRoots(path)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{RootCAs: pool}, nil
+})
+
+// TLSConfig trusts the extra certificate authorities. It is nil if there are
+// none, or if the bundle could not be read, so the defaults are used.
+func TLSConfig() *tls.Config {
+	config, err := tlsConfig()
+	if err != nil {
+		slog.Error("failed to load ca bundle", "err", err)
+		return nil
+	}
+	if config == nil {
+		return nil
+	}
+	return config.Clone()
+}
+
+// Check reports a problem with the bundle, for `sst doctor`.
+func Check() error {
+	_, err := tlsConfig()
+	return err
+}
+
+// Proxy picks the proxy for a request from the environment. Unlike
+// http.ProxyFromEnvironment it is read every time, the environment of a
+// command can set it after the first request.
+func Proxy(req *http.Request) (*url.URL, error) {
+	return httpproxy.FromEnvironment().ProxyFunc()(req.URL)
+}
+
+// Apply sets up a transport that was made elsewhere, like the one of the AWS
+// SDK, to use the proxy and the certificate authorities.
+func Apply(transport *http.Transport) {
+	transport.Proxy = Proxy
+	if config := TLSConfig(); config != nil {
+		transport.TLSClientConfig = config
+	}
+}
+
+// Transport returns a new transport with the proxy and the certificate
+// authorities.
+func Transport() *http.Transport {
+	transport := base.Clone()
+	Apply(transport)
+	return transport
+}
+
+// Client returns a client on Transport, a timeout of zero means none.
+func Client(timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: Transport(),
+		Timeout:   timeout,
+	}
+}
+
+// Install makes Transport the default of net/http for the libraries that use
+// it. The bundle is passed on to the node processes the CLI starts.
+func Install() {
+	http.DefaultTransport = Transport()
+	if flag.SST_CA_BUNDLE != "" && os.Getenv("NODE_EXTRA_CA_CERTS") == "" {
+		os.Setenv("NODE_EXTRA_CA_CERTS", flag.SST_CA_BUNDLE)
+	}
+}
@@ -0,0 +1,63 @@
+package network
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRoots(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dir := t.TempDir()
+	bundle := filepath.Join(dir, "ca.pem")
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(bundle, cert, 0644); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := loadRoots(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := base.Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	res, err := (&http.Client{Transport: transport}).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("Expected the bundle to be trusted, got %v", err)
+	}
+	res.Body.Close()
+
+	invalid := filepath.Join(dir, "invalid.pem")
+	os.WriteFile(invalid, []byte("not a certificate"), 0644)
+	if _, err := loadRoots(invalid); !errors.Is(err, ErrInvalidBundle) {
+		t.Errorf("Expected ErrInvalidBundle, got %v", err)
+	}
+}
+
+func TestProxy(t *testing.T) {
+	t.Setenv("HTTPS_PROXY", "http://proxy.example.com:3128")
+	t.Setenv("NO_PROXY", "internal.example.com")
+	for url, want := range map[string]string{
+		"https://registry.npmjs.org":      "http://proxy.example.com:3128",
+		"https://internal.example.com/ca": "",
+		"http://localhost:13557/stream":   "",
+	} {
+		req, _ := http.NewRequest("GET", url, nil)
+		proxy, err := Proxy(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if proxy != nil {
+			got = proxy.String()
+		}
+		if got != want {
+			t.Errorf("Proxy(%s) = %q, want %q", url, got, want)
+		}
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
+
+	"github.com/sst/ion/pkg/registry"
 )
 
 type Package struct {
@@ -17,14 +20,25 @@ type Package struct {
 	}
 }
 
+// registryURL is NPM_REGISTRY if it is set, then the registry of the mirror.
+func registryURL() string {
+	if url := os.Getenv("NPM_REGISTRY"); url != "" {
+		return strings.TrimSuffix(url, "/")
+	}
+	if mirror := registry.Get(); mirror.Enabled() {
+		return mirror.URL("npm")
+	}
+	return "https://registry.npmjs.org"
+}
+
 func Get(name string, version string) (*Package, error) {
 	slog.Info("getting package", "name", name, "version", version)
-	baseUrl := os.Getenv("NPM_REGISTRY")
-	if baseUrl == "" {
-		baseUrl = "https://registry.npmjs.org"
+	url := fmt.Sprintf("%s/%s/%s", registryURL(), name, version)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
 	}
-	url := fmt.Sprintf("%s/%s/%s", baseUrl, name, version)
-	resp, err := http.Get(url)
+	resp, err := registry.Get().Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -39,3 +53,34 @@ func Get(name string, version string) (*Package, error) {
 	}
 	return &data, nil
 }
+
+// Versions returns every published version of a package.
+func Versions(name string) ([]string, error) {
+	slog.Info("getting package versions", "name", name)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", registryURL(), name), nil)
+	if err != nil {
+		return nil, err
+	}
+	// the abbreviated document, the full one has the readme of every version
+	req.Header.Set("Accept", "application/vnd.npm.install-v1+json")
+	resp, err := registry.Get().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch package: %s", resp.Status)
+	}
+	var data struct {
+		Versions map[string]json.RawMessage `json:"versions"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(data.Versions))
+	for version := range data.Versions {
+		result = append(result, version)
+	}
+	return result, nil
+}
@@ -8,11 +8,18 @@ import (
 
 	awssdk "github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
 	"github.com/sst/ion/pkg/project/provider"
 )
 
 func (p *Project) EnvFor(ctx context.Context, complete *CompleteEvent, name string) (map[string]string, error) {
-	dev := complete.Devs[name]
+	return p.EnvForDev(ctx, complete, complete.Devs[name])
+}
+
+// EnvForDev is EnvFor a process that is not a component, like the processes
+// in dev.ui or a command wrapped with `sst dev`.
+func (p *Project) EnvForDev(ctx context.Context, complete *CompleteEvent, dev Dev) (map[string]string, error) {
 	env := map[string]string{}
 	if dev.Aws != nil && dev.Aws.Role != "" {
 		prov, _ := p.Provider("aws")
@@ -43,3 +50,56 @@ func (p *Project) EnvFor(ctx context.Context, complete *CompleteEvent, name stri
 	}
 	return env, nil
 }
+
+var ErrFunctionRole = fmt.Errorf("function role not found")
+
+// FunctionRole returns the arn of the IAM role of the function component with
+// the name, so a command can run with the same permissions.
+func FunctionRole(resources []apitype.ResourceV3, name string) (string, error) {
+	var function resource.URN
+	for _, item := range resources {
+		if item.Type == "sst:aws:Function" && item.URN.Name() == name {
+			function = item.URN
+			break
+		}
+	}
+	if function == "" {
+		return "", fmt.Errorf("%w: there is no function named %s", ErrFunctionRole, name)
+	}
+	// the role is a child of the function, or of one of its components
+	children := map[resource.URN]bool{function: true}
+	for _, item := range resources {
+		if !children[item.Parent] {
+			continue
+		}
+		children[item.URN] = true
+		if item.Type == "aws:iam/role:Role" {
+			if arn, ok := item.Outputs["arn"].(string); ok {
+				return arn, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("%w: %s does not have its own role", ErrFunctionRole, name)
+}
+
+// SecretEnv has the secrets of the stage as SST_SECRET_ variables, the way
+// the run function of the config sees them. The fallback values are used for
+// secrets the stage does not set.
+func (p *Project) SecretEnv() (map[string]string, error) {
+	env := map[string]string{}
+	fallback, err := p.secrets.GetSecrets(p.app.Name, "")
+	if err != nil {
+		return nil, err
+	}
+	secrets, err := p.secrets.GetSecrets(p.app.Name, p.app.Stage)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range fallback {
+		env["SST_SECRET_"+key] = value
+	}
+	for key, value := range secrets {
+		env["SST_SECRET_"+key] = value
+	}
+	return env, nil
+}
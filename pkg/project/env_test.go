@@ -0,0 +1,31 @@
+package project
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestFunctionRole(t *testing.T) {
+	urn := func(kind, name string) resource.URN {
+		return resource.URN("urn:pulumi:dev::app::" + kind + "::" + name)
+	}
+	resources := []apitype.ResourceV3{
+		{URN: urn("pulumi:pulumi:Stack", "app-dev"), Type: "pulumi:pulumi:Stack"},
+		{URN: urn("sst:aws:Function", "Api"), Type: "sst:aws:Function"},
+		{URN: urn("sst:aws:Function$aws:iam/role:Role", "ApiRole"), Type: "aws:iam/role:Role", Parent: urn("sst:aws:Function", "Api"), Outputs: map[string]interface{}{"arn": "arn:aws:iam::123:role/api"}},
+		{URN: urn("sst:aws:Function", "Worker"), Type: "sst:aws:Function"},
+	}
+	arn, err := FunctionRole(resources, "Api")
+	if err != nil || arn != "arn:aws:iam::123:role/api" {
+		t.Fatalf("Expected the role of Api, got %q %v", arn, err)
+	}
+	if _, err := FunctionRole(resources, "Worker"); !errors.Is(err, ErrFunctionRole) {
+		t.Errorf("Expected ErrFunctionRole for a function without a role, got %v", err)
+	}
+	if _, err := FunctionRole(resources, "Missing"); !errors.Is(err, ErrFunctionRole) {
+		t.Errorf("Expected ErrFunctionRole for a missing function, got %v", err)
+	}
+}
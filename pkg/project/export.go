@@ -0,0 +1,217 @@
+package project
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+// ExportPulumi returns the state in the format of `pulumi stack export` so it
+// can be imported into a Pulumi stack with `pulumi stack import`.
+func (s *State) ExportPulumi() ([]byte, error) {
+	deployment, err := json.Marshal(s.checkpoint.Latest)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(apitype.UntypedDeployment{
+		Version:    3,
+		Deployment: deployment,
+	}, "", "  ")
+}
+
+// terraformTypes are the Pulumi types whose Terraform names do not follow
+// from the type token.
+var terraformTypes = map[string]string{
+	"aws:s3/bucketV2:BucketV2":                                         "aws_s3_bucket",
+	"aws:s3/bucketObjectv2:BucketObjectv2":                             "aws_s3_object",
+	"aws:s3/bucketObject:BucketObject":                                 "aws_s3_object",
+	"aws:s3/bucketVersioningV2:BucketVersioningV2":                     "aws_s3_bucket_versioning",
+	"aws:s3/bucketCorsConfigurationV2:BucketCorsConfigurationV2":       "aws_s3_bucket_cors_configuration",
+	"aws:s3/bucketWebsiteConfigurationV2:BucketWebsiteConfigurationV2": "aws_s3_bucket_website_configuration",
+	"aws:lb/loadBalancer:LoadBalancer":                                 "aws_lb",
+}
+
+// terraformModules are the modules that are not part of the Terraform name,
+// like aws_vpc for aws:ec2/vpc:Vpc.
+var terraformModules = map[string]bool{
+	"index": true,
+	"ec2":   true,
+}
+
+// terraformType guesses the Terraform resource type of a Pulumi type bridged
+// from a Terraform provider. It returns false for anything else, like
+// components and providers.
+func terraformType(token string) (string, bool) {
+	if match, ok := terraformTypes[token]; ok {
+		return match, true
+	}
+	parts := strings.Split(token, ":")
+	if len(parts) != 3 || strings.HasPrefix(token, "pulumi") || strings.HasPrefix(token, "sst:") {
+		return "", false
+	}
+	module, _, _ := strings.Cut(parts[1], "/")
+	name := []string{parts[0]}
+	if !terraformModules[module] {
+		name = append(name, strings.ToLower(module))
+	}
+	name = append(name, snakeCase(parts[2]))
+	return strings.Join(name, "_"), true
+}
+
+func snakeCase(input string) string {
+	var sb strings.Builder
+	runes := []rune(input)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			// start a word before an upper case letter unless it continues an
+			// acronym, like the V2 in BucketV2 or the IP in IPSet
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))) {
+				sb.WriteRune('_')
+			}
+			sb.WriteRune(unicode.ToLower(r))
+			continue
+		}
+		if unicode.IsDigit(r) && i > 0 && unicode.IsLower(runes[i-1]) {
+			sb.WriteRune('_')
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+var terraformNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9_]+`)
+
+// terraformResource is a resource of the state under its Terraform address.
+type terraformResource struct {
+	URN  string
+	Type string
+	Name string
+	ID   string
+}
+
+// terraformResources returns the resources that have a Terraform equivalent
+// under a unique address, and the urns of the ones that were skipped.
+func (s *State) terraformResources() ([]terraformResource, []string) {
+	result := []terraformResource{}
+	skipped := []string{}
+	used := map[string]int{}
+	for _, item := range s.checkpoint.Latest.Resources {
+		if !item.Custom || item.Delete || strings.HasPrefix(string(item.Type), "pulumi:providers:") {
+			continue
+		}
+		tfType, ok := terraformType(string(item.Type))
+		if !ok || item.ID == "" {
+			skipped = append(skipped, string(item.URN))
+			continue
+		}
+		name := terraformNameInvalid.ReplaceAllString(snakeCase(item.URN.Name()), "_")
+		name = strings.Trim(name, "_")
+		if name == "" || unicode.IsDigit(rune(name[0])) {
+			name = "r_" + name
+		}
+		key := tfType + "." + name
+		used[key]++
+		if used[key] > 1 {
+			name = fmt.Sprintf("%s_%d", name, used[key])
+		}
+		result = append(result, terraformResource{
+			URN:  string(item.URN),
+			Type: tfType,
+			Name: name,
+			ID:   string(item.ID),
+		})
+	}
+	sort.Strings(skipped)
+	return result, skipped
+}
+
+// ExportTerraform returns a Terraform import block for every resource that
+// has a Terraform equivalent. Resources that are skipped are listed in a
+// comment at the end.
+func (s *State) ExportTerraform() string {
+	var sb strings.Builder
+	sb.WriteString("# Generated by `sst state export --format terraform`.\n")
+	sb.WriteString("# Run `terraform plan -generate-config-out=generated.tf` to write the\n")
+	sb.WriteString("# configuration of these resources, then check it before you apply.\n")
+	resources, skipped := s.terraformResources()
+	for _, item := range resources {
+		sb.WriteString("\n")
+		fmt.Fprintf(&sb, "# %s\n", item.URN)
+		sb.WriteString("import {\n")
+		fmt.Fprintf(&sb, "  to = %s.%s\n", item.Type, item.Name)
+		fmt.Fprintf(&sb, "  id = %q\n", item.ID)
+		sb.WriteString("}\n")
+	}
+	if len(skipped) > 0 {
+		sb.WriteString("\n# These resources have no Terraform equivalent and were skipped:\n")
+		for _, urn := range skipped {
+			fmt.Fprintf(&sb, "#   %s\n", urn)
+		}
+	}
+	return sb.String()
+}
+
+// terraformNamespaces are the registry namespaces of the providers that are
+// not published by hashicorp.
+var terraformNamespaces = map[string]string{
+	"cloudflare": "cloudflare",
+}
+
+func terraformProvider(tfType string) string {
+	name, _, _ := strings.Cut(tfType, "_")
+	namespace, ok := terraformNamespaces[name]
+	if !ok {
+		namespace = "hashicorp"
+	}
+	return fmt.Sprintf(`provider["registry.terraform.io/%s/%s"]`, namespace, name)
+}
+
+// ExportTerraformState returns a Terraform state with a stub for every
+// resource in ExportTerraform. The stubs only have the id of the resource,
+// `terraform apply -refresh-only` reads the rest of their attributes.
+func (s *State) ExportTerraformState() ([]byte, error) {
+	lineage := make([]byte, 16)
+	if _, err := rand.Read(lineage); err != nil {
+		return nil, err
+	}
+	lineage[6] = lineage[6]&0x0f | 0x40
+	lineage[8] = lineage[8]&0x3f | 0x80
+	type instance struct {
+		SchemaVersion int               `json:"schema_version"`
+		Attributes    map[string]string `json:"attributes"`
+	}
+	type stub struct {
+		Mode      string     `json:"mode"`
+		Type      string     `json:"type"`
+		Name      string     `json:"name"`
+		Provider  string     `json:"provider"`
+		Instances []instance `json:"instances"`
+	}
+	resources, _ := s.terraformResources()
+	stubs := make([]stub, 0, len(resources))
+	for _, item := range resources {
+		stubs = append(stubs, stub{
+			Mode:     "managed",
+			Type:     item.Type,
+			Name:     item.Name,
+			Provider: terraformProvider(item.Type),
+			Instances: []instance{{
+				Attributes: map[string]string{"id": item.ID},
+			}},
+		})
+	}
+	return json.MarshalIndent(map[string]interface{}{
+		"version":           4,
+		"terraform_version": "1.5.0",
+		"serial":            1,
+		"lineage":           fmt.Sprintf("%x-%x-%x-%x-%x", lineage[0:4], lineage[4:6], lineage[6:8], lineage[8:10], lineage[10:]),
+		"outputs":           map[string]interface{}{},
+		"resources":         stubs,
+	}, "", "  ")
+}
@@ -0,0 +1,98 @@
+package project
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+)
+
+func TestTerraformType(t *testing.T) {
+	for token, want := range map[string]string{
+		"aws:lambda/function:Function":                      "aws_lambda_function",
+		"aws:iam/rolePolicyAttachment:RolePolicyAttachment": "aws_iam_role_policy_attachment",
+		"aws:s3/bucketV2:BucketV2":                          "aws_s3_bucket",
+		"aws:ec2/securityGroup:SecurityGroup":               "aws_security_group",
+		"aws:apigatewayv2/api:Api":                          "aws_apigatewayv2_api",
+		"aws:wafv2/ipSet:IpSet":                             "aws_wafv2_ip_set",
+		"cloudflare:index/workerScript:WorkerScript":        "cloudflare_worker_script",
+		"sst:aws:Bucket":                                    "",
+		"pulumi:providers:aws":                              "",
+	} {
+		got, ok := terraformType(token)
+		if want == "" {
+			if ok {
+				t.Errorf("%s: expected no terraform type, got %s", token, got)
+			}
+			continue
+		}
+		if got != want {
+			t.Errorf("%s: got %s, want %s", token, got, want)
+		}
+	}
+}
+
+func TestExportTerraform(t *testing.T) {
+	state := testState()
+	state.checkpoint.Latest.Resources[2].Custom = true
+	state.checkpoint.Latest.Resources[2].Type = "aws:s3/bucketV2:BucketV2"
+	state.checkpoint.Latest.Resources[2].ID = "mybucket-abc123"
+	out := state.ExportTerraform()
+	if !strings.Contains(out, "to = aws_s3_bucket.my_bucket_bucket\n") || !strings.Contains(out, `id = "mybucket-abc123"`) {
+		t.Fatalf("missing import block:\n%s", out)
+	}
+	if strings.Contains(out, "skipped") {
+		t.Fatalf("components should not be listed as skipped:\n%s", out)
+	}
+}
+
+func TestExportTerraformState(t *testing.T) {
+	state := testState()
+	state.checkpoint.Latest.Resources[2].Custom = true
+	state.checkpoint.Latest.Resources[2].Type = "aws:s3/bucketV2:BucketV2"
+	state.checkpoint.Latest.Resources[2].ID = "mybucket-abc123"
+	data, err := state.ExportTerraformState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Version   int `json:"version"`
+		Resources []struct {
+			Type      string `json:"type"`
+			Name      string `json:"name"`
+			Provider  string `json:"provider"`
+			Instances []struct {
+				Attributes map[string]string `json:"attributes"`
+			} `json:"instances"`
+		} `json:"resources"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Version != 4 || len(parsed.Resources) != 1 {
+		t.Fatalf("unexpected state:\n%s", data)
+	}
+	stub := parsed.Resources[0]
+	if stub.Type != "aws_s3_bucket" || stub.Name != "my_bucket_bucket" || stub.Provider != `provider["registry.terraform.io/hashicorp/aws"]` || stub.Instances[0].Attributes["id"] != "mybucket-abc123" {
+		t.Fatalf("unexpected stub:\n%s", data)
+	}
+}
+
+func TestExportPulumi(t *testing.T) {
+	data, err := testState().ExportPulumi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deployment apitype.UntypedDeployment
+	if err := json.Unmarshal(data, &deployment); err != nil {
+		t.Fatal(err)
+	}
+	var parsed apitype.DeploymentV3
+	if err := json.Unmarshal(deployment.Deployment, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Version != 3 || len(parsed.Resources) != 3 {
+		t.Fatalf("unexpected export: %s", data)
+	}
+}
@@ -0,0 +1,112 @@
+package project
+
+import (
+	"sort"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+// Orphan is something in the state that no update will clean up on its own.
+type Orphan struct {
+	URN  string `json:"urn"`
+	Type string `json:"type"`
+	ID   string `json:"id,omitempty"`
+	// Reason is why it is an orphan
+	Reason string `json:"reason"`
+	// Pending is set for an operation an interrupted update did not finish,
+	// the resource may or may not exist
+	Pending bool `json:"pending,omitempty"`
+	// Replaced is set for the old copy of a resource that was replaced, it
+	// has the same urn as the new one
+	Replaced bool `json:"replaced,omitempty"`
+}
+
+// Orphans returns the resources that are left over from replacements that
+// could not delete them, the ones whose parent is gone, and the operations of
+// updates that were interrupted.
+func (s *State) Orphans() []Orphan {
+	resources := s.checkpoint.Latest.Resources
+	// the old copy of a replaced resource shares its urn, parents are always
+	// the live one
+	live := map[resource.URN]int{}
+	for i, item := range resources {
+		if !item.Delete {
+			live[item.URN] = i
+		}
+	}
+	reasons := map[int]string{}
+	for i, item := range resources {
+		if item.Delete {
+			reasons[i] = "replaced but never deleted"
+		}
+	}
+	// a resource is orphaned with its parent, walk until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for i, item := range resources {
+			if _, ok := reasons[i]; ok || item.Parent == "" {
+				continue
+			}
+			parent, ok := live[item.Parent]
+			if !ok {
+				reasons[i] = "parent " + item.Parent.Name() + " is not in the state"
+				changed = true
+				continue
+			}
+			if _, ok := reasons[parent]; ok {
+				reasons[i] = "parent " + item.Parent.Name() + " is orphaned"
+				changed = true
+			}
+		}
+	}
+
+	result := []Orphan{}
+	for i, item := range resources {
+		if reason, ok := reasons[i]; ok {
+			result = append(result, Orphan{
+				URN:      string(item.URN),
+				Type:     string(item.Type),
+				ID:       string(item.ID),
+				Reason:   reason,
+				Replaced: item.Delete,
+			})
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].URN < result[j].URN
+	})
+	for _, op := range s.checkpoint.Latest.PendingOperations {
+		result = append(result, Orphan{
+			URN:     string(op.Resource.URN),
+			Type:    string(op.Resource.Type),
+			ID:      string(op.Resource.ID),
+			Reason:  "pending " + string(op.Type) + " from an interrupted update",
+			Pending: true,
+		})
+	}
+	return result
+}
+
+// RemoveOrphans drops orphans from the state, along with every pending
+// operation.
+func (s *State) RemoveOrphans(orphans []Orphan) {
+	type key struct {
+		urn      string
+		replaced bool
+	}
+	remove := map[key]bool{}
+	for _, orphan := range orphans {
+		if !orphan.Pending {
+			remove[key{orphan.URN, orphan.Replaced}] = true
+		}
+	}
+	resources := s.checkpoint.Latest.Resources[:0]
+	for _, item := range s.checkpoint.Latest.Resources {
+		if remove[key{string(item.URN), item.Delete}] {
+			continue
+		}
+		resources = append(resources, item)
+	}
+	s.checkpoint.Latest.Resources = resources
+	s.checkpoint.Latest.PendingOperations = nil
+}
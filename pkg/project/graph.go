@@ -0,0 +1,192 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+// Graph is the dependency graph of a stage. Nodes are grouped by the top level
+// component they belong to and an edge points from a node to one it depends
+// on.
+type Graph struct {
+	Groups []*GraphGroup `json:"groups"`
+	Edges  []GraphEdge   `json:"edges"`
+}
+
+type GraphGroup struct {
+	Name  string      `json:"name"`
+	Type  string      `json:"type"`
+	Nodes []GraphNode `json:"nodes"`
+}
+
+type GraphNode struct {
+	URN  string `json:"urn"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// NewGraph builds the graph of resources. By default every top level
+// component is a single node and the dependencies of the resources inside it
+// are merged. With expand every resource is a node inside its component.
+func NewGraph(resources []apitype.ResourceV3, expand bool) *Graph {
+	byURN := map[resource.URN]apitype.ResourceV3{}
+	for _, item := range resources {
+		byURN[item.URN] = item
+	}
+	skip := func(item apitype.ResourceV3) bool {
+		return item.Type == "pulumi:pulumi:Stack" || strings.HasPrefix(string(item.Type), "pulumi:providers:")
+	}
+	// root walks up to the component right below the stack
+	root := func(urn resource.URN) resource.URN {
+		for {
+			item, ok := byURN[urn]
+			if !ok {
+				return urn
+			}
+			parent, ok := byURN[item.Parent]
+			if !ok || skip(parent) {
+				return urn
+			}
+			urn = item.Parent
+		}
+	}
+	node := func(urn resource.URN) resource.URN {
+		if expand {
+			return urn
+		}
+		return root(urn)
+	}
+
+	graph := &Graph{Groups: []*GraphGroup{}, Edges: []GraphEdge{}}
+	groups := map[resource.URN]*GraphGroup{}
+	edges := map[GraphEdge]bool{}
+	for _, item := range resources {
+		if skip(item) {
+			continue
+		}
+		top := root(item.URN)
+		group, ok := groups[top]
+		if !ok {
+			group = &GraphGroup{Name: top.Name(), Type: graphType(top.Type())}
+			groups[top] = group
+			graph.Groups = append(graph.Groups, group)
+		}
+		if expand || item.URN == top {
+			group.Nodes = append(group.Nodes, GraphNode{
+				URN:  string(item.URN),
+				Name: item.URN.Name(),
+				Type: graphType(item.Type),
+			})
+		}
+
+		deps := append([]resource.URN{}, item.Dependencies...)
+		for _, list := range item.PropertyDependencies {
+			deps = append(deps, list...)
+		}
+		from := node(item.URN)
+		for _, dep := range deps {
+			target, ok := byURN[dep]
+			if !ok || skip(target) {
+				continue
+			}
+			to := node(dep)
+			if from == to {
+				continue
+			}
+			edges[GraphEdge{From: string(from), To: string(to)}] = true
+		}
+	}
+	for edge := range edges {
+		graph.Edges = append(graph.Edges, edge)
+	}
+	sort.Slice(graph.Groups, func(i, j int) bool {
+		return graph.Groups[i].Name < graph.Groups[j].Name
+	})
+	sort.Slice(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].From != graph.Edges[j].From {
+			return graph.Edges[i].From < graph.Edges[j].From
+		}
+		return graph.Edges[i].To < graph.Edges[j].To
+	})
+	return graph
+}
+
+// graphType prints component types the way they are written in sst.config.ts.
+func graphType[T ~string](input T) string {
+	if strings.HasPrefix(string(input), "sst:") {
+		return strings.ReplaceAll(string(input), ":", ".")
+	}
+	return string(input)
+}
+
+func (g *Graph) DOT() string {
+	var sb strings.Builder
+	sb.WriteString("digraph {\n")
+	sb.WriteString("  rankdir=LR;\n")
+	sb.WriteString("  node [shape=box];\n")
+	for i, group := range g.Groups {
+		indent := "  "
+		if len(group.Nodes) > 1 {
+			fmt.Fprintf(&sb, "  subgraph \"cluster_%d\" {\n", i)
+			fmt.Fprintf(&sb, "    label=%s;\n", dotQuote(group.Name+"\n"+group.Type))
+			indent = "    "
+		}
+		for _, node := range group.Nodes {
+			fmt.Fprintf(&sb, "%s%s [label=%s];\n", indent, dotQuote(node.URN), dotQuote(node.Name+"\n"+node.Type))
+		}
+		if len(group.Nodes) > 1 {
+			sb.WriteString("  }\n")
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %s -> %s;\n", dotQuote(edge.From), dotQuote(edge.To))
+	}
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+func dotQuote(input string) string {
+	input = strings.ReplaceAll(input, `\`, `\\`)
+	input = strings.ReplaceAll(input, `"`, `\"`)
+	input = strings.ReplaceAll(input, "\n", `\n`)
+	return `"` + input + `"`
+}
+
+// Mermaid ids can't contain the characters in a URN so nodes are numbered.
+func (g *Graph) Mermaid() string {
+	ids := map[string]string{}
+	var sb strings.Builder
+	sb.WriteString("flowchart LR\n")
+	for i, group := range g.Groups {
+		indent := "  "
+		if len(group.Nodes) > 1 {
+			fmt.Fprintf(&sb, "  subgraph g%d[%s]\n", i, mermaidQuote(group.Name+"<br/>"+group.Type))
+			indent = "    "
+		}
+		for _, node := range group.Nodes {
+			id := fmt.Sprintf("n%d", len(ids))
+			ids[node.URN] = id
+			fmt.Fprintf(&sb, "%s%s[%s]\n", indent, id, mermaidQuote(node.Name+"<br/>"+node.Type))
+		}
+		if len(group.Nodes) > 1 {
+			sb.WriteString("  end\n")
+		}
+	}
+	for _, edge := range g.Edges {
+		fmt.Fprintf(&sb, "  %s --> %s\n", ids[edge.From], ids[edge.To])
+	}
+	return sb.String()
+}
+
+func mermaidQuote(input string) string {
+	return `"` + strings.ReplaceAll(input, `"`, "#quot;") + `"`
+}
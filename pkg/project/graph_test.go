@@ -0,0 +1,51 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestNewGraph(t *testing.T) {
+	const (
+		stack    = "urn:pulumi:dev::app::pulumi:pulumi:Stack::app-dev"
+		provider = "urn:pulumi:dev::app::pulumi:providers:aws::default"
+		bucket   = "urn:pulumi:dev::app::sst:aws:Bucket::Uploads"
+		inner    = "urn:pulumi:dev::app::sst:aws:Bucket$aws:s3/bucketV2:BucketV2::UploadsBucket"
+		api      = "urn:pulumi:dev::app::sst:aws:Function::Api"
+		handler  = "urn:pulumi:dev::app::sst:aws:Function$aws:lambda/function:Function::ApiFunction"
+		role     = "urn:pulumi:dev::app::sst:aws:Function$aws:iam/role:Role::ApiRole"
+	)
+	resources := []apitype.ResourceV3{
+		{URN: stack, Type: "pulumi:pulumi:Stack"},
+		{URN: provider, Type: "pulumi:providers:aws"},
+		{URN: bucket, Type: "sst:aws:Bucket", Parent: stack},
+		{URN: inner, Type: "aws:s3/bucketV2:BucketV2", Parent: bucket},
+		{URN: api, Type: "sst:aws:Function", Parent: stack},
+		{URN: role, Type: "aws:iam/role:Role", Parent: api},
+		{URN: handler, Type: "aws:lambda/function:Function", Parent: api,
+			Dependencies: []resource.URN{role, provider},
+			PropertyDependencies: map[resource.PropertyKey][]resource.URN{
+				"environment": {inner},
+			}},
+	}
+
+	graph := NewGraph(resources, false)
+	if len(graph.Groups) != 2 || graph.Groups[0].Name != "Api" || len(graph.Groups[0].Nodes) != 1 {
+		t.Fatalf("unexpected groups: %+v", graph.Groups)
+	}
+	if want := []GraphEdge{{From: api, To: bucket}}; !reflect.DeepEqual(graph.Edges, want) {
+		t.Errorf("got %v, want %v", graph.Edges, want)
+	}
+
+	graph = NewGraph(resources, true)
+	if len(graph.Groups[0].Nodes) != 3 {
+		t.Fatalf("unexpected nodes: %+v", graph.Groups[0].Nodes)
+	}
+	want := []GraphEdge{{From: handler, To: inner}, {From: handler, To: role}}
+	if !reflect.DeepEqual(graph.Edges, want) {
+		t.Errorf("got %v, want %v", graph.Edges, want)
+	}
+}
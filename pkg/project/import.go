@@ -0,0 +1,156 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+
+	"github.com/nrednav/cuid2"
+	"github.com/pulumi/pulumi/sdk/v3/go/auto/optimport"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+var ErrImportUnsupported = fmt.Errorf("component type cannot be imported")
+var ErrImportExists = fmt.Errorf("resource already exists in state")
+
+type ImportOptions struct {
+	// Type is the component type, like sst.aws.Bucket
+	Type string
+	Name string
+	// ID is the id of the cloud resource, in the format its provider expects
+	ID string
+}
+
+type ImportResult struct {
+	// Code is the generated program for the imported resource. Its arguments
+	// need to be set through Transform on the component so the next deploy
+	// does not change the resource.
+	Code      string
+	Transform string
+}
+
+type importable struct {
+	Type      string
+	Suffix    string
+	Transform string
+}
+
+// importables maps components to the resource they wrap, named the way the
+// component names it.
+var importables = map[string]importable{
+	"sst:aws:Bucket":          {Type: "aws:s3/bucketV2:BucketV2", Suffix: "Bucket", Transform: "bucket"},
+	"sst:aws:Dynamo":          {Type: "aws:dynamodb/table:Table", Suffix: "Table", Transform: "table"},
+	"sst:aws:Queue":           {Type: "aws:sqs/queue:Queue", Suffix: "Queue", Transform: "queue"},
+	"sst:aws:SnsTopic":        {Type: "aws:sns/topic:Topic", Suffix: "Topic", Transform: "topic"},
+	"sst:aws:CognitoUserPool": {Type: "aws:cognito/userPool:UserPool", Suffix: "UserPool", Transform: "userPool"},
+}
+
+func ImportableTypes() []string {
+	result := []string{}
+	for key := range importables {
+		result = append(result, strings.ReplaceAll(key, ":", "."))
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Import adopts an existing cloud resource as the resource a component would
+// have created. If the component is not in the state yet a placeholder is
+// imported for it so the next deploy takes it over.
+func (p *Project) Import(ctx context.Context, input *ImportOptions) (*ImportResult, error) {
+	componentType := strings.ReplaceAll(input.Type, ".", ":")
+	match, ok := importables[componentType]
+	if !ok {
+		return nil, ErrImportUnsupported
+	}
+
+	updateID := cuid2.Generate()
+	err := p.Lock(updateID, "import")
+	if err != nil {
+		return nil, err
+	}
+	defer p.Unlock()
+
+	_, err = p.PullState()
+	if err != nil {
+		if errors.Is(err, provider.ErrStateNotFound) {
+			return nil, ErrStageNotFound
+		}
+		return nil, err
+	}
+
+	passphrase, err := provider.Passphrase(p.home, p.app.Name, p.app.Stage)
+	if err != nil {
+		return nil, err
+	}
+	env := map[string]string{
+		"PULUMI_CONFIG_PASSPHRASE": passphrase,
+		"PULUMI_SKIP_UPDATE_CHECK": "true",
+	}
+	if prov, ok := p.Provider("aws"); ok {
+		creds, err := prov.(*provider.AwsProvider).Config().Credentials.Retrieve(ctx)
+		if err != nil {
+			return nil, err
+		}
+		env["AWS_ACCESS_KEY_ID"] = creds.AccessKeyID
+		env["AWS_SECRET_ACCESS_KEY"] = creds.SecretAccessKey
+		env["AWS_SESSION_TOKEN"] = creds.SessionToken
+		env["AWS_REGION"] = prov.(*provider.AwsProvider).Config().Region
+	}
+
+	stack, err := p.localStack(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	complete, err := getCompletedEvent(ctx, stack)
+	if err != nil {
+		return nil, err
+	}
+
+	name := input.Name + match.Suffix
+	resources := []*optimport.ImportResource{}
+	parent := ""
+	for _, item := range complete.Resources {
+		if string(item.Type) == match.Type && item.URN.Name() == name {
+			return nil, ErrImportExists
+		}
+		if string(item.Type) == componentType && item.URN.Name() == input.Name {
+			parent = string(item.URN)
+		}
+	}
+	if parent == "" {
+		resources = append(resources, &optimport.ImportResource{
+			Type:      componentType,
+			Name:      input.Name,
+			Component: true,
+		})
+		parent = input.Name
+	}
+	resources = append(resources, &optimport.ImportResource{
+		Type:   match.Type,
+		Name:   name,
+		ID:     input.ID,
+		Parent: parent,
+	})
+
+	slog.Info("importing", "type", match.Type, "name", name, "id", input.ID)
+	result, err := stack.ImportResources(ctx,
+		optimport.Resources(resources),
+		optimport.Protect(false),
+		optimport.GenerateCode(true),
+	)
+	if err != nil {
+		return nil, err
+	}
+	err = p.PushState(updateID)
+	if err != nil {
+		return nil, err
+	}
+	return &ImportResult{
+		Code:      result.GeneratedCode,
+		Transform: match.Transform,
+	}, nil
+}
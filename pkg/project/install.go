@@ -1,6 +1,7 @@
 package project
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,11 +10,14 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/sst/ion/pkg/flag"
 	"github.com/sst/ion/pkg/global"
 	"github.com/sst/ion/pkg/npm"
+	"github.com/sst/ion/pkg/registry"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -21,10 +25,13 @@ func (p *Project) NeedsInstall() bool {
 	if len(p.app.Providers) != len(p.lock) {
 		return true
 	}
+	if p.lockfile == nil {
+		return true
+	}
 	for _, entry := range p.lock {
 		config := p.app.Providers[entry.Name].(map[string]interface{})
-		version := config["version"]
-		if version == nil || version == "" {
+		version := p.lockedVersion(entry.Name, config)
+		if version == "latest" {
 			continue
 		}
 		slog.Info("checking provider", "name", entry.Name, "version", version, "compare", entry.Version)
@@ -37,6 +44,10 @@ func (p *Project) NeedsInstall() bool {
 
 func (p *Project) Install() error {
 	slog.Info("installing deps")
+	start := time.Now()
+	defer func() {
+		p.providerTime += time.Since(start)
+	}()
 
 	err := p.generateProviderLock()
 	if err != nil {
@@ -58,11 +69,24 @@ func (p *Project) Install() error {
 		return err
 	}
 
+	// pulumi cannot download the plugins itself without the public registry
+	if registry.Get().Enabled() {
+		_, err = p.PrefetchPlugins(context.Background())
+		if err != nil {
+			return err
+		}
+	}
+
 	err = p.writeProviderLock()
 	if err != nil {
 		return err
 	}
 
+	err = p.writeLockfile()
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -158,12 +182,32 @@ func (p *Project) fetchDeps() error {
 	if flag.NO_BUN {
 		manager = "npm"
 	}
+	if mirror := registry.Get(); mirror.Enabled() {
+		err := os.WriteFile(filepath.Join(p.PathPlatformDir(), ".npmrc"), []byte(mirror.Npmrc()), 0600)
+		if err != nil {
+			return err
+		}
+	}
+	key, err := p.modulesKey()
+	if err != nil {
+		return err
+	}
+	if err := p.linkModules(key); err == nil {
+		slog.Info("linked deps", "key", key)
+		return nil
+	}
+	// the files may be linked to the cache, installing over them would change
+	// it for every other project
+	os.RemoveAll(filepath.Join(p.PathPlatformDir(), "node_modules"))
 	cmd := exec.Command(manager, "install")
 	cmd.Dir = p.PathPlatformDir()
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return errors.New("failed to run bun install " + string(output))
 	}
+	if err := p.storeModules(key); err != nil {
+		slog.Warn("failed to cache deps", "err", err)
+	}
 	return nil
 }
 
@@ -196,12 +240,9 @@ func (p *Project) generateProviderLock() error {
 	results := make(chan ProviderLockEntry)
 	for name, config := range p.app.Providers {
 		n := name
-		version := config.(map[string]interface{})["version"]
-		if version == nil || version == "" {
-			version = "latest"
-		}
+		version := p.lockedVersion(name, config.(map[string]interface{}))
 		wg.Go(func() error {
-			result, err := FindProvider(n, version.(string))
+			result, err := FindProvider(n, version)
 			if err != nil {
 				return err
 			}
@@ -265,3 +306,62 @@ func (p *Project) writeProviderLock() error {
 	}
 	return nil
 }
+
+// Plugins returns the Pulumi plugins of the installed provider packages.
+func (p *Project) Plugins() ([]global.Plugin, error) {
+	data, err := os.ReadFile(filepath.Join(p.PathPlatformDir(), "package.json"))
+	if err != nil {
+		return nil, err
+	}
+	var packageJson struct {
+		Dependencies map[string]string `json:"dependencies"`
+	}
+	if err := json.Unmarshal(data, &packageJson); err != nil {
+		return nil, err
+	}
+	result := []global.Plugin{}
+	for name := range packageJson.Dependencies {
+		data, err := os.ReadFile(filepath.Join(p.PathPlatformDir(), "node_modules", name, "package.json"))
+		if err != nil {
+			continue
+		}
+		var pkg struct {
+			Version string `json:"version"`
+			Pulumi  *struct {
+				Resource bool   `json:"resource"`
+				Name     string `json:"name"`
+				Version  string `json:"version"`
+				Server   string `json:"server"`
+			} `json:"pulumi"`
+		}
+		if err := json.Unmarshal(data, &pkg); err != nil || pkg.Pulumi == nil || !pkg.Pulumi.Resource {
+			continue
+		}
+		plugin := global.Plugin{
+			Name:    pkg.Pulumi.Name,
+			Version: pkg.Pulumi.Version,
+			Server:  pkg.Pulumi.Server,
+		}
+		if plugin.Version == "" {
+			plugin.Version = pkg.Version
+		}
+		if plugin.Name == "" {
+			continue
+		}
+		result = append(result, plugin)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// PrefetchPlugins downloads the plugins of the providers ahead of the first
+// deploy.
+func (p *Project) PrefetchPlugins(ctx context.Context) ([]global.Plugin, error) {
+	plugins, err := p.Plugins()
+	if err != nil {
+		return nil, err
+	}
+	return plugins, global.PrefetchPlugins(ctx, plugins)
+}
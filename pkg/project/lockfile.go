@@ -0,0 +1,138 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// Lockfile is the sst.lock next to sst.config.ts. It pins the providers and
+// the version of sst an app was installed with, so teammates and CI resolve
+// the same versions and get the same diffs. It is meant to be committed.
+type Lockfile struct {
+	Platform  string                       `json:"platform"`
+	Providers map[string]*LockfileProvider `json:"providers"`
+}
+
+type LockfileProvider struct {
+	Package string `json:"package"`
+	Version string `json:"version"`
+}
+
+// LockfileChange is a provider that `sst update` moved to another version.
+// Old is empty for a provider that was not locked yet.
+type LockfileChange struct {
+	Name string
+	Old  string
+	New  string
+}
+
+func (p Project) PathLockfile() string {
+	return filepath.Join(p.root, "sst.lock")
+}
+
+func (p *Project) loadLockfile() error {
+	data, err := os.ReadFile(p.PathLockfile())
+	if os.IsNotExist(err) {
+		p.lockfile = nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var parsed Lockfile
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("sst.lock is invalid: %w", err)
+	}
+	if parsed.Providers == nil {
+		parsed.Providers = map[string]*LockfileProvider{}
+	}
+	p.lockfile = &parsed
+	return nil
+}
+
+func (p *Project) writeLockfile() error {
+	lockfile := &Lockfile{
+		Platform:  p.version,
+		Providers: map[string]*LockfileProvider{},
+	}
+	for _, entry := range p.lock {
+		lockfile.Providers[entry.Name] = &LockfileProvider{
+			Package: entry.Package,
+			Version: entry.Version,
+		}
+	}
+	// encoding/json sorts the keys so the file only changes with the versions
+	data, err := json.MarshalIndent(lockfile, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(p.PathLockfile(), append(data, '\n'), 0644); err != nil {
+		return err
+	}
+	p.lockfile = lockfile
+	return nil
+}
+
+// lockedVersion is the version a provider is installed at. A version in the
+// config always wins, then the one in sst.lock, then the latest.
+func (p *Project) lockedVersion(name string, config map[string]interface{}) string {
+	if version, ok := config["version"].(string); ok && version != "" {
+		return version
+	}
+	if p.lockfile != nil {
+		if locked, ok := p.lockfile.Providers[name]; ok && locked.Version != "" {
+			return locked.Version
+		}
+	}
+	return "latest"
+}
+
+// CheckLockfile fails when sst.lock was written by another version of sst.
+// Dev builds are not checked.
+func (p *Project) CheckLockfile() error {
+	if p.lockfile == nil || p.lockfile.Platform == "" || p.version == "dev" {
+		return nil
+	}
+	if p.lockfile.Platform == "dev" || p.lockfile.Platform == p.version {
+		return nil
+	}
+	return fmt.Errorf("%wsst.lock was installed with v%s but you are using v%s. Switch to v%s, or run `sst update` to lock v%s.", ErrVersionMismatch, p.lockfile.Platform, p.version, p.lockfile.Platform, p.version)
+}
+
+// Update installs the latest versions of the named providers, or of all of
+// them, that the config allows and rewrites sst.lock.
+func (p *Project) Update(names ...string) ([]LockfileChange, error) {
+	for _, name := range names {
+		if _, ok := p.app.Providers[name]; !ok {
+			return nil, fmt.Errorf("provider %s is not in sst.config.ts", name)
+		}
+	}
+	old := map[string]string{}
+	if p.lockfile != nil {
+		for name, locked := range p.lockfile.Providers {
+			old[name] = locked.Version
+		}
+		for name := range p.lockfile.Providers {
+			if len(names) == 0 || slices.Contains(names, name) {
+				delete(p.lockfile.Providers, name)
+			}
+		}
+	}
+	if err := p.Install(); err != nil {
+		return nil, err
+	}
+	changes := []LockfileChange{}
+	for _, entry := range p.lock {
+		if old[entry.Name] != entry.Version {
+			changes = append(changes, LockfileChange{Name: entry.Name, Old: old[entry.Name], New: entry.Version})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes, nil
+}
@@ -0,0 +1,57 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLockfile(t *testing.T) {
+	p := &Project{
+		root:    t.TempDir(),
+		version: "3.2.0",
+		lock: ProviderLock{
+			{Name: "aws", Package: "@pulumi/aws", Version: "6.51.0", Alias: "aws"},
+			{Name: "cloudflare", Package: "@pulumi/cloudflare", Version: "5.37.1", Alias: "cloudflare"},
+		},
+	}
+	if err := p.writeLockfile(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := os.ReadFile(p.PathLockfile())
+	if !strings.Contains(string(data), `"platform": "3.2.0"`) {
+		t.Errorf("Unexpected sst.lock %s", data)
+	}
+
+	loaded := &Project{root: p.root, version: "3.2.0"}
+	if err := loaded.loadLockfile(); err != nil {
+		t.Fatal(err)
+	}
+	if got := loaded.lockedVersion("aws", map[string]interface{}{}); got != "6.51.0" {
+		t.Errorf("Expected the locked version, got %s", got)
+	}
+	if got := loaded.lockedVersion("aws", map[string]interface{}{"version": "6.52.0"}); got != "6.52.0" {
+		t.Errorf("Expected the version in the config to win, got %s", got)
+	}
+	if got := loaded.lockedVersion("vercel", map[string]interface{}{}); got != "latest" {
+		t.Errorf("Expected an unlocked provider to be latest, got %s", got)
+	}
+	if err := loaded.CheckLockfile(); err != nil {
+		t.Errorf("Expected the same version to pass, got %v", err)
+	}
+
+	loaded.version = "3.3.0"
+	if err := loaded.CheckLockfile(); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch, got %v", err)
+	}
+	loaded.version = "dev"
+	if err := loaded.CheckLockfile(); err != nil {
+		t.Errorf("Expected dev builds to pass, got %v", err)
+	}
+
+	missing := &Project{root: t.TempDir()}
+	if err := missing.loadLockfile(); err != nil || missing.lockfile != nil {
+		t.Errorf("Expected no lockfile, got %v %v", missing.lockfile, err)
+	}
+}
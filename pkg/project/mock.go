@@ -0,0 +1,155 @@
+package project
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
+	"github.com/sst/ion/pkg/global"
+	"github.com/sst/ion/pkg/js"
+)
+
+type MockInput struct {
+	// ServerPort and ServerToken are for the rpc calls of the components,
+	// the functions are added as targets of the runtime through them
+	ServerPort  int
+	ServerToken string
+}
+
+type mockedResource struct {
+	Type   string `json:"type"`
+	Name   string `json:"name"`
+	Parent *struct {
+		Type string `json:"type"`
+		Name string `json:"name"`
+	} `json:"parent"`
+	Outputs map[string]interface{} `json:"outputs"`
+}
+
+// Mock runs the app with mocked resources like `sst dev` would deploy it,
+// without a cloud account or a deploy. The resources it returns have the
+// inputs they were created with as their outputs. Secrets come from the
+// environment and the sops file of the stage, the ones that are missing are
+// empty.
+func (p *Project) Mock(ctx context.Context, input *MockInput) ([]apitype.ResourceV3, error) {
+	outfile := filepath.Join(p.PathPlatformDir(), fmt.Sprintf("sst.mock.%v.mjs", time.Now().UnixMilli()))
+	cli := map[string]interface{}{
+		"command":   "dev",
+		"dev":       true,
+		"offline":   true,
+		"unprotect": false,
+		"paths": map[string]string{
+			"home":     global.ConfigDir(),
+			"root":     p.PathRoot(),
+			"work":     p.PathWorkingDir(),
+			"platform": p.PathPlatformDir(),
+		},
+		"state": map[string]interface{}{
+			"version": map[string]int{},
+		},
+	}
+	cliBytes, err := json.Marshal(cli)
+	if err != nil {
+		return nil, err
+	}
+	appBytes, err := json.Marshal(p.app)
+	if err != nil {
+		return nil, err
+	}
+	providerShim := []string{}
+	for _, entry := range p.lock {
+		providerShim = append(providerShim, fmt.Sprintf("import * as %s from \"%s\";", entry.Alias, entry.Package))
+	}
+	providerShim = append(providerShim, fmt.Sprintf("import * as sst from \"%s\";", js.ImportPath(filepath.Join(p.PathPlatformDir(), "src", "components"))))
+	buildResult, err := js.Build(js.EvalOptions{
+		Dir:     p.PathRoot(),
+		Outfile: outfile,
+		Define: map[string]string{
+			"$app": string(appBytes),
+			"$cli": string(cliBytes),
+			"$dev": "true",
+		},
+		Inject:  []string{filepath.Join(p.PathPlatformDir(), "src", "shim", "run.js")},
+		Globals: strings.Join(providerShim, "\n"),
+		Code: fmt.Sprintf(`
+      import { mock } from "%v";
+      import { run } from "%v";
+      import mod from "%v/sst.config.ts";
+      const done = await mock();
+      await run(mod.run);
+      await done();
+    `,
+			js.ImportPath(filepath.Join(p.PathPlatformDir(), "src", "auto", "mock.ts")),
+			js.ImportPath(filepath.Join(p.PathPlatformDir(), "src", "auto", "run.ts")),
+			js.ImportPath(p.PathRoot()),
+		),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer js.Cleanup(buildResult)
+
+	env := os.Environ()
+	if secrets, err := p.SopsSecrets(p.app.Stage); err != nil {
+		slog.Warn("failed to read the sops secrets", "error", err)
+	} else {
+		for key, value := range secrets {
+			env = append(env, "SST_SECRET_"+key+"="+value)
+		}
+	}
+	env = append(env,
+		"NODE_OPTIONS=--enable-source-maps --no-deprecation",
+		fmt.Sprintf("SST_SERVER=http://localhost:%v", input.ServerPort),
+		"SST_SERVER_TOKEN="+input.ServerToken,
+	)
+	node := exec.CommandContext(ctx, "node", "--no-warnings", outfile)
+	node.Dir = p.PathRoot()
+	node.Env = env
+	output, err := node.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("Error evaluating the app: %w\n%s", err, output)
+	}
+	var mocked []mockedResource
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	scanner.Buffer(nil, 64*1024*1024)
+	for scanner.Scan() {
+		if line, ok := strings.CutPrefix(scanner.Text(), "~m"); ok {
+			if err := json.Unmarshal([]byte(line), &mocked); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	urn := func(kind, name string) resource.URN {
+		return resource.URN(fmt.Sprintf("urn:pulumi:%s::%s::%s::%s", p.app.Stage, p.app.Name, kind, name))
+	}
+	result := make([]apitype.ResourceV3, 0, len(mocked))
+	for _, item := range mocked {
+		next := apitype.ResourceV3{
+			URN:     urn(item.Type, item.Name),
+			Type:    tokens.Type(item.Type),
+			Outputs: item.Outputs,
+		}
+		if id, ok := item.Outputs["id"].(string); ok {
+			next.ID = resource.ID(id)
+		}
+		if item.Parent != nil {
+			next.Parent = urn(item.Parent.Type, item.Parent.Name)
+		}
+		result = append(result, next)
+	}
+	return result, nil
+}
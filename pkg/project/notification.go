@@ -0,0 +1,123 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	NotificationStart   = "start"
+	NotificationSuccess = "success"
+	NotificationFailure = "failure"
+)
+
+// AppNotification is a URL that is sent a POST when a deploy or a remove of
+// the stage starts, succeeds, or fails. Updates in `sst dev` are not sent.
+type AppNotification struct {
+	URL string `json:"url"`
+	// Events are the ones to send, all of them if it is not set
+	Events []string `json:"events"`
+	// Format is the shape of the body, the payload as json, or a message for
+	// slack or discord
+	Format string `json:"format"`
+	// Template is a Go template of the body that replaces the format, it is
+	// rendered with the payload
+	Template string            `json:"template"`
+	Headers  map[string]string `json:"headers"`
+}
+
+// NotificationPayload is what a notification is about.
+type NotificationPayload struct {
+	Event    string               `json:"event"`
+	App      string               `json:"app"`
+	Stage    string               `json:"stage"`
+	Command  string               `json:"command"`
+	DeployID string               `json:"deployID"`
+	Duration float64              `json:"duration"`
+	Changes  []NotificationChange `json:"changes"`
+	Error    string               `json:"error,omitempty"`
+}
+
+type NotificationChange struct {
+	URN  string `json:"urn"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Op   string `json:"op"`
+}
+
+func (n *AppNotification) Wants(event string) bool {
+	if len(n.Events) == 0 {
+		return true
+	}
+	for _, item := range n.Events {
+		if item == event {
+			return true
+		}
+	}
+	return false
+}
+
+var notificationFuncs = template.FuncMap{
+	// json writes a value as JSON, so strings in a template are escaped
+	"json": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// Body renders the body of the notification for the payload.
+func (n *AppNotification) Body(payload *NotificationPayload) ([]byte, error) {
+	if n.Template != "" {
+		tmpl, err := template.New("notification").Funcs(notificationFuncs).Option("missingkey=error").Parse(n.Template)
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, payload); err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		return buf.Bytes(), nil
+	}
+	switch n.Format {
+	case "slack":
+		return json.Marshal(map[string]string{"text": payload.Message()})
+	case "discord":
+		return json.Marshal(map[string]string{"content": payload.Message()})
+	}
+	return json.Marshal(payload)
+}
+
+// Message is the payload as a line of text, for chat apps.
+func (p *NotificationPayload) Message() string {
+	verb := "Deploy"
+	if p.Command == "remove" {
+		verb = "Remove"
+	}
+	target := fmt.Sprintf("%s to %s", p.App, p.Stage)
+	if p.Command == "remove" {
+		target = fmt.Sprintf("%s from %s", p.App, p.Stage)
+	}
+	duration := (time.Duration(p.Duration * float64(time.Second))).Round(time.Second)
+	switch p.Event {
+	case NotificationStart:
+		return fmt.Sprintf("%s of %s started", verb, target)
+	case NotificationFailure:
+		message := fmt.Sprintf("%s of %s failed after %s", verb, target, duration)
+		if p.Error != "" {
+			message += ": " + strings.SplitN(p.Error, "\n", 2)[0]
+		}
+		return message
+	}
+	changes := "no changes"
+	if len(p.Changes) == 1 {
+		changes = "1 resource changed"
+	}
+	if len(p.Changes) > 1 {
+		changes = fmt.Sprintf("%d resources changed", len(p.Changes))
+	}
+	return fmt.Sprintf("%s of %s succeeded in %s, %s", verb, target, duration, changes)
+}
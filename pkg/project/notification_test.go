@@ -0,0 +1,77 @@
+package project
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestNotificationWants(t *testing.T) {
+	all := &AppNotification{}
+	some := &AppNotification{Events: []string{NotificationFailure}}
+	if !all.Wants(NotificationStart) || !all.Wants(NotificationFailure) {
+		t.Error("Expected a notification without events to want all of them")
+	}
+	if some.Wants(NotificationStart) || !some.Wants(NotificationFailure) {
+		t.Error("Expected a notification to only want its events")
+	}
+}
+
+func TestNotificationBody(t *testing.T) {
+	payload := &NotificationPayload{
+		Event:    NotificationSuccess,
+		App:      "web",
+		Stage:    "production",
+		Command:  "deploy",
+		Duration: 62.4,
+		Changes: []NotificationChange{
+			{Name: "MyBucket", Type: "sst:aws:Bucket", Op: "create"},
+			{Name: "MyApi", Type: "sst:aws:Function", Op: "update"},
+		},
+	}
+	cases := []struct {
+		notification AppNotification
+		expected     string
+	}{
+		{AppNotification{Format: "slack"}, `{"text":"Deploy of web to production succeeded in 1m2s, 2 resources changed"}`},
+		{AppNotification{Format: "discord"}, `{"content":"Deploy of web to production succeeded in 1m2s, 2 resources changed"}`},
+		{AppNotification{Template: `{"app": {{json .App}}, "changes": {{len .Changes}}}`}, `{"app": "web", "changes": 2}`},
+	}
+	for _, c := range cases {
+		body, err := c.notification.Body(payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(body) != c.expected {
+			t.Errorf("Expected %s, got %s", c.expected, body)
+		}
+	}
+
+	body, err := (&AppNotification{}).Body(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded NotificationPayload
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded.Stage != "production" || len(decoded.Changes) != 2 {
+		t.Errorf("Expected the payload as json, got %s", body)
+	}
+
+	if _, err := (&AppNotification{Template: `{{.Missing}}`}).Body(payload); err == nil {
+		t.Error("Expected an error for a field that is not in the payload")
+	}
+}
+
+func TestNotificationMessage(t *testing.T) {
+	cases := []struct {
+		payload  NotificationPayload
+		expected string
+	}{
+		{NotificationPayload{Event: NotificationStart, App: "web", Stage: "dev", Command: "deploy"}, "Deploy of web to dev started"},
+		{NotificationPayload{Event: NotificationFailure, App: "web", Stage: "dev", Command: "remove", Duration: 3, Error: "bucket is not empty\nmore"}, "Remove of web from dev failed after 3s: bucket is not empty"},
+		{NotificationPayload{Event: NotificationSuccess, App: "web", Stage: "dev", Command: "deploy", Duration: 1}, "Deploy of web to dev succeeded in 1s, no changes"},
+	}
+	for _, c := range cases {
+		if got := c.payload.Message(); got != c.expected {
+			t.Errorf("Expected %q, got %q", c.expected, got)
+		}
+	}
+}
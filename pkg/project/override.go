@@ -0,0 +1,59 @@
+package project
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/sst/ion/internal/fs"
+)
+
+// A stage can have its own sst.config.<stage>.ts next to sst.config.ts. What
+// its default export returns is merged over the app config, objects key by
+// key and everything else, like lists, replaced.
+
+// configOverride is the overrides file of the stage, or empty if it has none.
+func configOverride(config, stage string) string {
+	if stage == "" {
+		return ""
+	}
+	path := filepath.Join(filepath.Dir(config), strings.TrimSuffix(filepath.Base(config), ".ts")+"."+stage+".ts")
+	if !fs.Exists(path) {
+		return ""
+	}
+	return path
+}
+
+// PathConfigOverride is the overrides file merged over the config, or empty
+// if the stage has none.
+func (p Project) PathConfigOverride() string {
+	return p.override
+}
+
+// mergeConfig merges the override over the config, both are JSON objects.
+func mergeConfig(config, override []byte) ([]byte, error) {
+	var base, extra map[string]interface{}
+	if err := json.Unmarshal(config, &base); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(override, &extra); err != nil {
+		return nil, err
+	}
+	return json.Marshal(mergeObjects(base, extra))
+}
+
+func mergeObjects(base, extra map[string]interface{}) map[string]interface{} {
+	if base == nil {
+		base = map[string]interface{}{}
+	}
+	for key, value := range extra {
+		next, ok := value.(map[string]interface{})
+		current, isObject := base[key].(map[string]interface{})
+		if ok && isObject {
+			base[key] = mergeObjects(current, next)
+			continue
+		}
+		base[key] = value
+	}
+	return base
+}
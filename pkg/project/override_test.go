@@ -0,0 +1,41 @@
+package project
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMergeConfig(t *testing.T) {
+	merged, err := mergeConfig(
+		[]byte(`{"name":"myapp","removal":"remove","providers":{"aws":{"region":"us-east-1"}},"watch":{"ignore":["a","b"]}}`),
+		[]byte(`{"removal":"retain","providers":{"aws":{"profile":"production"},"cloudflare":true},"watch":{"ignore":["c"]}}`),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	json.Unmarshal(merged, &got)
+	var want map[string]interface{}
+	json.Unmarshal([]byte(`{"name":"myapp","removal":"retain","providers":{"aws":{"region":"us-east-1","profile":"production"},"cloudflare":true},"watch":{"ignore":["c"]}}`), &want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+}
+
+func TestConfigOverride(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "sst.config.ts")
+	os.WriteFile(filepath.Join(dir, "sst.config.production.ts"), []byte(""), 0644)
+	if got := configOverride(config, "production"); got != filepath.Join(dir, "sst.config.production.ts") {
+		t.Errorf("Unexpected override %q", got)
+	}
+	if got := configOverride(config, "dev"); got != "" {
+		t.Errorf("Expected no override for dev, got %q", got)
+	}
+	if got := configOverride(config, ""); got != "" {
+		t.Errorf("Expected no override without a stage, got %q", got)
+	}
+}
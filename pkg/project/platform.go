@@ -1,47 +1,225 @@
 package project
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/sst/ion/internal/fs"
+	"github.com/sst/ion/pkg/global"
 	"github.com/sst/ion/pkg/js"
 	"github.com/sst/ion/platform"
 )
 
-func (p *Project) CheckPlatform(version string) bool {
+// PlatformVersion is the version of the platform a version of the cli
+// installs, builds from source use the time they were built.
+func PlatformVersion(version string) string {
 	if version == "dev" {
 		currentExecutable, _ := os.Executable()
 		info, _ := os.Stat(currentExecutable)
 		version = fmt.Sprint(info.ModTime().UnixMilli())
 	}
+	return version
+}
+
+// InstalledPlatform returns the version of the platform in the .sst of the
+// config, it is empty if none is installed.
+func InstalledPlatform(cfgPath string) string {
+	contents, err := os.ReadFile(filepath.Join(filepath.Dir(cfgPath), ".sst", "platform", "version"))
+	if err != nil {
+		return ""
+	}
+	return string(contents)
+}
+
+func (p *Project) CheckPlatform(version string) bool {
 	slog.Info("checking platform")
 	contents, err := os.ReadFile(filepath.Join(p.PathPlatformDir(), "version"))
 	if err != nil {
 		return false
 	}
-	return string(contents) == version
+	return string(contents) == PlatformVersion(version)
 }
 
+// platformKeep is how many versions of the platform are kept in the cache,
+// projects that link an older one keep their files.
+const platformKeep = 3
+
+// platformMutable are the files of the platform that are changed in place in
+// a project, they are never hardlinked to the cache.
+var platformMutable = []string{"package.json", "bun.lockb", "version"}
+
 func (p *Project) CopyPlatform(version string) error {
 	slog.Info("installing platform")
 	platformDir := p.PathPlatformDir()
 	os.RemoveAll(filepath.Join(platformDir))
-	err := platform.CopyTo(".", platformDir)
+	p.lock = ProviderLock{}
+	version = PlatformVersion(version)
+	store, err := platformStore(version)
+	if err == nil {
+		err = fs.LinkTree(store, platformDir, func(rel string) bool {
+			return slices.Contains(platformMutable, rel)
+		})
+		if err == nil {
+			return nil
+		}
+		os.RemoveAll(platformDir)
+	}
+	slog.Error("failed to link platform, copying it", "err", err)
+	err = platform.CopyTo(".", platformDir)
 	if err != nil {
 		return err
 	}
-	p.lock = ProviderLock{}
-	if version == "dev" {
-		currentExecutable, _ := os.Executable()
-		info, _ := os.Stat(currentExecutable)
-		version = fmt.Sprint(info.ModTime().UnixMilli())
-	}
 	return os.WriteFile(filepath.Join(platformDir, "version"), []byte(version), 0644)
 }
 
+// platformStore returns the platform of the version in the cache, it is
+// extracted once for every project on the machine.
+func platformStore(version string) (string, error) {
+	root := filepath.Join(global.CacheDir(), "platform")
+	dir := filepath.Join(root, version)
+	if _, err := os.Stat(filepath.Join(dir, "version")); err == nil {
+		return dir, nil
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(root, ".extract-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := platform.CopyTo(".", tmp); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, "version"), []byte(version), 0644); err != nil {
+		return "", err
+	}
+	os.RemoveAll(dir)
+	if err := os.Rename(tmp, dir); err != nil {
+		// another process extracted it first
+		if _, statErr := os.Stat(filepath.Join(dir, "version")); statErr == nil {
+			return dir, nil
+		}
+		return "", err
+	}
+	pruneStore(root, version, platformKeep)
+	return dir, nil
+}
+
+// modulesKeep is how many installs of node_modules are kept in the cache,
+// there is one for every platform version and set of providers.
+const modulesKeep = 10
+
+// modulesKey identifies the node_modules of the platform, they only change
+// with its version and the providers locked in its package.json.
+func (p *Project) modulesKey() (string, error) {
+	hash := sha256.New()
+	for _, name := range []string{"version", "package.json", ".npmrc"} {
+		data, err := os.ReadFile(filepath.Join(p.PathPlatformDir(), name))
+		if err != nil && !os.IsNotExist(err) {
+			return "", err
+		}
+		hash.Write([]byte(name))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16], nil
+}
+
+// linkModules links the node_modules installed under key into the platform,
+// it fails if they were never installed.
+func (p *Project) linkModules(key string) error {
+	dir := filepath.Join(global.CacheDir(), "platform-modules", key)
+	if _, err := os.Stat(filepath.Join(dir, "node_modules")); err != nil {
+		return err
+	}
+	dest := filepath.Join(p.PathPlatformDir(), "node_modules")
+	os.RemoveAll(dest)
+	err := fs.LinkTree(filepath.Join(dir, "node_modules"), dest, func(rel string) bool {
+		return false
+	})
+	if err != nil {
+		os.RemoveAll(dest)
+		return err
+	}
+	// touched so pruning keeps the ones in use
+	now := time.Now()
+	os.Chtimes(dir, now, now)
+	return nil
+}
+
+// storeModules keeps the node_modules just installed in the platform for the
+// next project with the same key.
+func (p *Project) storeModules(key string) error {
+	root := filepath.Join(global.CacheDir(), "platform-modules")
+	dir := filepath.Join(root, key)
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(root, ".install-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+	err = fs.LinkTree(filepath.Join(p.PathPlatformDir(), "node_modules"), filepath.Join(tmp, "node_modules"), func(rel string) bool {
+		return false
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		// another process stored it first
+		if _, statErr := os.Stat(filepath.Join(dir, "node_modules")); statErr == nil {
+			return nil
+		}
+		return err
+	}
+	pruneStore(root, key, modulesKeep)
+	return nil
+}
+
+// pruneStore removes all but the latest keep entries of a store in the
+// cache. The files of a removed entry stay around for the projects that link
+// them.
+func pruneStore(root string, current string, keep int) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	type version struct {
+		name    string
+		modTime time.Time
+	}
+	versions := []version{}
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || entry.Name() == current {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		versions = append(versions, version{entry.Name(), info.ModTime()})
+	}
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].modTime.After(versions[j].modTime)
+	})
+	for i, item := range versions {
+		if i >= keep-1 {
+			slog.Info("removing old cache entry", "root", root, "name", item.name)
+			os.RemoveAll(filepath.Join(root, item.name))
+		}
+	}
+}
+
 func getPackageJson(proj *Project, pkg string) (*js.PackageJson, error) {
 	data, err := os.ReadFile(
 		filepath.Join(
@@ -0,0 +1,34 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPruneStore(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	for i, version := range []string{"3.0.0", "3.0.1", "3.0.2", "3.0.3"} {
+		dir := filepath.Join(root, version)
+		os.MkdirAll(dir, 0755)
+		at := now.Add(time.Duration(i) * time.Minute)
+		os.Chtimes(dir, at, at)
+	}
+	os.MkdirAll(filepath.Join(root, ".extract-123"), 0755)
+
+	pruneStore(root, "3.0.0", platformKeep)
+	for version, kept := range map[string]bool{
+		"3.0.0":        true,
+		"3.0.1":        false,
+		"3.0.2":        true,
+		"3.0.3":        true,
+		".extract-123": true,
+	} {
+		_, err := os.Stat(filepath.Join(root, version))
+		if kept != (err == nil) {
+			t.Errorf("Expected %s kept to be %v", version, kept)
+		}
+	}
+}
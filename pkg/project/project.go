@@ -3,6 +3,7 @@ package project
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"log/slog"
@@ -11,6 +12,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/sst/ion/internal/fs"
@@ -19,8 +21,12 @@ import (
 	"github.com/sst/ion/pkg/js"
 	"github.com/sst/ion/pkg/project/provider"
 	"github.com/sst/ion/pkg/runtime"
+	"github.com/sst/ion/pkg/runtime/deno"
+	"github.com/sst/ion/pkg/runtime/dotnet"
+	"github.com/sst/ion/pkg/runtime/java"
 	"github.com/sst/ion/pkg/runtime/node"
 	"github.com/sst/ion/pkg/runtime/python"
+	"github.com/sst/ion/pkg/runtime/rust"
 	"github.com/sst/ion/pkg/runtime/worker"
 )
 
@@ -31,22 +37,125 @@ type App struct {
 	Providers map[string]interface{} `json:"providers"`
 	Home      string                 `json:"home"`
 	Version   string                 `json:"version"`
+	Hooks     map[string]string      `json:"hooks"`
+	Watch     AppWatch               `json:"watch"`
+	Dev       AppDev                 `json:"dev"`
+	State     *provider.StateConfig  `json:"state"`
+	History   AppHistory             `json:"history"`
+	// Encryption turns on encrypting the state before it is stored
+	Encryption *provider.EncryptionConfig `json:"encryption"`
+	// Secrets picks where secrets are stored, the home if it is not set
+	Secrets *SecretsConfig `json:"secrets"`
+	// Protect keeps the resources of the stage from being deleted
+	Protect *AppProtect `json:"protect"`
+	// Notifications are sent when a deploy of the stage starts and ends
+	Notifications []AppNotification `json:"notifications"`
+	// EsbuildConfig is a file with a config hook for the esbuild build of
+	// every Node function
+	EsbuildConfig string `json:"esbuildConfig"`
 	// Deprecated: Backend is now Home
 	Backend string `json:"backend"`
 	// Deprecated: RemovalPolicy is now Removal
 	RemovalPolicy string `json:"removalPolicy"`
 }
 
+type AppWatch struct {
+	Ignore []string `json:"ignore"`
+}
+
+type AppDev struct {
+	UI AppDevUI `json:"ui"`
+}
+
+// AppDevUI is the layout of the multiplexer of `sst dev`.
+type AppDevUI struct {
+	// Panes are the keys of the panes that are listed first, in order
+	Panes []string `json:"panes"`
+	// Hide are the keys of the panes that are not shown
+	Hide         []string                 `json:"hide"`
+	SidebarWidth int                      `json:"sidebarWidth"`
+	Keys         map[string]KeyList       `json:"keys"`
+	Processes    map[string]AppDevProcess `json:"processes"`
+	// Restart has the restart policies of the panes by key
+	Restart map[string]AppDevRestart `json:"restart"`
+}
+
+// AppDevRestart is a restart policy, either just its name or with the retries
+// and backoff.
+type AppDevRestart struct {
+	Policy  string `json:"policy"`
+	Retries *int   `json:"retries"`
+	Backoff string `json:"backoff"`
+}
+
+func (r *AppDevRestart) UnmarshalJSON(data []byte) error {
+	var policy string
+	if err := json.Unmarshal(data, &policy); err == nil {
+		r.Policy = policy
+		return nil
+	}
+	type alias AppDevRestart
+	return json.Unmarshal(data, (*alias)(r))
+}
+
+// KeyList is one key or a list of keys.
+type KeyList []string
+
+func (k *KeyList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*k = KeyList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*k = list
+	return nil
+}
+
+// AppDevProcess is a process of its own that the multiplexer runs with the
+// linked resources of the app in its environment.
+type AppDevProcess struct {
+	Command   string `json:"command"`
+	Directory string `json:"directory"`
+	Title     string `json:"title"`
+	Autostart *bool  `json:"autostart"`
+	// Link has the names of the resources linked to the process, all of them
+	// if it is not set
+	Link []string `json:"link"`
+}
+
+type AppHistory struct {
+	// Retain is how many checkpoints to keep for each stage, all of them if
+	// it is not set
+	Retain int `json:"retain"`
+	// Receipts uploads the receipt of every update next to its checkpoint
+	Receipts bool `json:"receipts"`
+}
+
 type Project struct {
 	version         string
 	lock            ProviderLock
+	lockfile        *Lockfile
 	root            string
 	config          string
 	app             *App
 	home            provider.Home
+	secrets         SecretStore
 	env             map[string]string
 	loadedProviders map[string]provider.Provider
-	Runtime         *runtime.Collection
+	lockRenew       context.CancelFunc
+	// lockLost is closed if another update took the lock while it was held
+	lockLost  chan struct{}
+	workspace *Workspace
+	// override is the sst.config.<stage>.ts merged over the config
+	override string
+	Runtime  *runtime.Collection
+	// configTime and providerTime are reported in the timings of updates
+	configTime   time.Duration
+	providerTime time.Duration
 }
 
 func Discover() (string, error) {
@@ -100,6 +209,7 @@ func New(input *ProjectConfig) (*Project, error) {
 
 	rootPath := filepath.Dir(input.Config)
 
+	nodeRuntime := node.New(input.Version)
 	proj := &Project{
 		version: input.Version,
 		root:    rootPath,
@@ -107,11 +217,16 @@ func New(input *ProjectConfig) (*Project, error) {
 		env:     map[string]string{},
 		Runtime: runtime.NewCollection(
 			input.Config,
-			node.New(input.Version),
+			nodeRuntime,
 			worker.New(),
 			python.New(),
+			rust.New(),
+			java.New(),
+			dotnet.New(),
+			deno.New(),
 		),
 	}
+	proj.Runtime.Version = input.Version
 	tmp := proj.PathWorkingDir()
 
 	_, err := os.Stat(tmp)
@@ -125,6 +240,23 @@ func New(input *ProjectConfig) (*Project, error) {
 		}
 	}
 
+	proj.override = configOverride(input.Config, input.Stage)
+	proj.workspace, err = workspaceOf(input.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	// the overrides of the stage can be an object or a function of the input
+	// like app
+	overrideCode := ""
+	if path := proj.PathConfigOverride(); path != "" {
+		overrideCode = fmt.Sprintf(`
+import override from '%s';
+const extra = typeof override === "function" ? override(appInput) : override;
+console.log("~o" + JSON.stringify(extra || {}))`, js.ImportPath(path))
+	}
+
+	evalStart := time.Now()
 	inputBytes, err := json.Marshal(map[string]string{
 		"stage": input.Stage,
 	})
@@ -141,10 +273,12 @@ if (mod.stacks || mod.config) {
   console.log("~v2")
   process.exit(0)
 }
-console.log("~j" + JSON.stringify(mod.app({
+const appInput = {
   stage: $input.stage || undefined,
-})))`,
-				input.Config),
+};
+console.log("~j" + JSON.stringify(mod.app(appInput)))
+%s`,
+				js.ImportPath(input.Config), overrideCode),
 		},
 	)
 	if err != nil {
@@ -156,98 +290,136 @@ console.log("~j" + JSON.stringify(mod.app({
 	node := exec.Command("node", "--no-warnings", string(buildResult.OutputFiles[1].Path))
 	output, err := node.CombinedOutput()
 	slog.Info("config evaluated")
+	proj.configTime = time.Since(evalStart)
 	if err != nil {
 		return nil, fmt.Errorf("Error evaluating config: %w\n%s", err, output)
 	}
 	scanner := bufio.NewScanner(bytes.NewReader(output))
+	var appData, overrideData []byte
 	for scanner.Scan() {
 		line := scanner.Text()
 		if line == "~v2" {
 			return nil, ErrV2Config
 		}
 		if strings.HasPrefix(line, "~j") {
-			var parsed App
-			err = json.Unmarshal([]byte(line[2:]), &parsed)
-			if err != nil {
-				return nil, err
-			}
-			proj.app = &parsed
-			proj.app.Stage = input.Stage
-
-			if proj.app.Providers == nil {
-				proj.app.Providers = map[string]interface{}{}
-			}
-
-			for name, args := range proj.app.Providers {
-				if argsBool, ok := args.(bool); ok && argsBool {
-					proj.app.Providers[name] = make(map[string]interface{})
-				}
-
-				if argsString, ok := args.(string); ok {
-					proj.app.Providers[name] = map[string]interface{}{
-						"version": argsString,
-					}
-				}
-			}
-
-			if proj.app.Name == "" {
-				return nil, fmt.Errorf("Project name is required")
-			}
-
-			if InvalidAppRegex.MatchString(proj.app.Name) {
-				return nil, ErrInvalidAppName
-			}
-
-			if proj.app.Home == "" {
-				return nil, util.NewReadableError(nil, `You must specify a "home" provider in the project configuration file.`)
-			}
-
-			if _, ok := proj.app.Providers[proj.app.Home]; !ok && proj.app.Home != "local" {
-				proj.app.Providers[proj.app.Home] = map[string]interface{}{}
-			}
-
-			if proj.app.RemovalPolicy != "" {
-				return nil, util.NewReadableError(nil, `The "removalPolicy" has been renamed to "removal"`)
-			}
-
-			if proj.app.Removal == "" {
-				proj.app.Removal = "retain"
-			}
-
-			if proj.app.Version != "" && input.Version != "dev" {
-				constraint, err := semver.NewConstraint(proj.app.Version)
-				if err != nil {
-					return nil, ErrVersionInvalid
-				}
-				version, err := semver.NewVersion(input.Version)
-				if err != nil {
-					return nil, ErrVersionInvalid
-				}
-				if !constraint.Check(version) {
-					return nil, fmt.Errorf("%wYou are using v%s which does not match v%s in your \"sst.config.ts\".", ErrVersionMismatch, input.Version, proj.app.Version)
-				}
-			}
-
-			if proj.app.Removal != "remove" && proj.app.Removal != "retain" && proj.app.Removal != "retain-all" {
-				return nil, fmt.Errorf("Removal must be one of: remove, retain, retain-all")
-			}
+			appData = []byte(line[2:])
+			continue
+		}
+		if strings.HasPrefix(line, "~o") {
+			overrideData = []byte(line[2:])
 			continue
 		}
 	}
 	if err := scanner.Err(); err != nil {
 		return nil, err
 	}
+	if appData != nil {
+		if err := proj.loadApp(input, appData, overrideData); err != nil {
+			return nil, err
+		}
+		nodeRuntime.SetConfig(proj.app.EsbuildConfig)
+	}
 
 	err = proj.loadProviderLock()
 	if err != nil {
 		return nil, err
 	}
+	err = proj.loadLockfile()
+	if err != nil {
+		return nil, err
+	}
 
 	return proj, nil
 }
 
+// loadApp parses the evaluated config, with the overrides of the stage merged
+// over it.
+func (proj *Project) loadApp(input *ProjectConfig, data []byte, override []byte) error {
+	source, _ := os.ReadFile(input.Config)
+	if issues := validateConfig(data, string(source)); len(issues) > 0 {
+		return configError(filepath.Base(input.Config), issues)
+	}
+	if override != nil {
+		path := proj.PathConfigOverride()
+		source, _ := os.ReadFile(path)
+		if issues := validateConfig(override, string(source)); len(issues) > 0 {
+			return configError(filepath.Base(path), issues)
+		}
+		merged, err := mergeConfig(data, override)
+		if err != nil {
+			return err
+		}
+		data = merged
+	}
+	var parsed App
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return err
+	}
+	proj.app = &parsed
+	proj.app.Stage = input.Stage
+
+	if proj.app.Providers == nil {
+		proj.app.Providers = map[string]interface{}{}
+	}
+
+	normalizeProviders(proj.app.Providers)
+	if proj.workspace != nil {
+		mergeProviders(proj.app.Providers, proj.workspace.Providers)
+	}
+
+	if proj.app.Name == "" {
+		return fmt.Errorf("Project name is required")
+	}
+
+	if InvalidAppRegex.MatchString(proj.app.Name) {
+		return ErrInvalidAppName
+	}
+	proj.Runtime.App = proj.app.Name
+	proj.Runtime.Stage = proj.app.Stage
+
+	if proj.app.Home == "" {
+		return util.NewReadableError(nil, `You must specify a "home" provider in the project configuration file.`)
+	}
+
+	if _, ok := proj.app.Providers[proj.app.Home]; !ok && proj.app.Home != "local" {
+		proj.app.Providers[proj.app.Home] = map[string]interface{}{}
+	}
+
+	if proj.app.RemovalPolicy != "" {
+		return util.NewReadableError(nil, `The "removalPolicy" has been renamed to "removal"`)
+	}
+
+	if proj.app.Removal == "" {
+		proj.app.Removal = "retain"
+	}
+
+	if proj.app.Version != "" && input.Version != "dev" {
+		constraint, err := semver.NewConstraint(proj.app.Version)
+		if err != nil {
+			return ErrVersionInvalid
+		}
+		version, err := semver.NewVersion(input.Version)
+		if err != nil {
+			return ErrVersionInvalid
+		}
+		if !constraint.Check(version) {
+			return fmt.Errorf("%wYou are using v%s which does not match v%s in your \"sst.config.ts\".", ErrVersionMismatch, input.Version, proj.app.Version)
+		}
+	}
+
+	if proj.app.Removal != "remove" && proj.app.Removal != "retain" && proj.app.Removal != "retain-all" {
+		return fmt.Errorf("Removal must be one of: remove, retain, retain-all")
+	}
+	return nil
+}
+
 func (proj *Project) LoadHome() error {
 	slog.Info("loading home")
+	start := time.Now()
+	defer func() {
+		proj.providerTime += time.Since(start)
+	}()
 	loadedProviders := make(map[string]provider.Provider)
 
 	for key, args := range proj.app.Providers {
@@ -257,6 +429,10 @@ func (proj *Project) LoadHome() error {
 			match = &provider.CloudflareProvider{}
 		case "aws":
 			match = &provider.AwsProvider{}
+		case "gcp":
+			match = &provider.GcpProvider{}
+		case "azure":
+			match = &provider.AzureProvider{}
 		}
 		if match == nil {
 			continue
@@ -284,6 +460,10 @@ func (proj *Project) LoadHome() error {
 		home = provider.NewAwsHome(loadedProviders["aws"].(*provider.AwsProvider))
 	case "cloudflare":
 		home = provider.NewCloudflareHome(loadedProviders["cloudflare"].(*provider.CloudflareProvider))
+	case "gcp":
+		home = provider.NewGcpHome(loadedProviders["gcp"].(*provider.GcpProvider))
+	case "azure":
+		home = provider.NewAzureHome(loadedProviders["azure"].(*provider.AzureProvider))
 	default:
 		return fmt.Errorf("Home provider %s is invalid", proj.app.Home)
 	}
@@ -292,7 +472,28 @@ func (proj *Project) LoadHome() error {
 	if err != nil {
 		return fmt.Errorf("Error initializing %s:\n   %w", proj.app.Home, err)
 	}
-	proj.home = home
+	var awsProvider *provider.AwsProvider
+	if match, ok := loadedProviders["aws"]; ok {
+		awsProvider = match.(*provider.AwsProvider)
+	}
+	if proj.app.State != nil {
+		home, err = provider.NewStateHome(*proj.app.State, awsProvider)
+		if err != nil {
+			return util.NewReadableError(err, err.Error())
+		}
+		err = home.Bootstrap()
+		if err != nil {
+			return fmt.Errorf("Error initializing the %s state backend:\n   %w", proj.app.State.Backend, err)
+		}
+	}
+	if encryption := proj.app.Encryption; encryption != nil && encryption.KMS == "" && os.Getenv(provider.StatePassphraseEnv) == "" {
+		return util.NewReadableError(provider.ErrEncryptionPassphrase, "The state is encrypted with a passphrase, set it in "+provider.StatePassphraseEnv+" or use a KMS key in `encryption`")
+	}
+	proj.home = provider.NewEncryptedHome(home, proj.app.Encryption, awsProvider)
+	proj.secrets, err = NewSecretStore(proj.app.Secrets, proj.home, awsProvider)
+	if err != nil {
+		return util.NewReadableError(err, err.Error())
+	}
 	proj.loadedProviders = loadedProviders
 	return nil
 }
@@ -330,6 +531,11 @@ func (p Project) Backend() provider.Home {
 	return p.home
 }
 
+// Secrets returns where the secrets of the app are stored.
+func (p Project) Secrets() SecretStore {
+	return p.secrets
+}
+
 func (p Project) Env() map[string]string {
 	return p.env
 }
@@ -346,6 +552,10 @@ func (p *Project) Cleanup() error {
 	return nil
 }
 
+func (p *Project) PathBackup() string {
+	return filepath.Join(p.PathWorkingDir(), "backup")
+}
+
 func (p *Project) PathLog(name string) string {
 	if name == "" {
 		return filepath.Join(p.PathWorkingDir(), "log")
@@ -0,0 +1,121 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/internal/util"
+)
+
+// AppProtect is the protection policy of a stage. Protected resources are not
+// deleted by `sst remove` or a deploy, unless it runs with --force-unprotect.
+// It is either true for all the resources, or the types to protect.
+type AppProtect struct {
+	All bool `json:"all"`
+	// Types are resource types like aws:rds/cluster:Cluster, or globs of them
+	// like aws:rds/*. The children of a protected component are protected too.
+	Types []string `json:"types"`
+}
+
+func (a *AppProtect) UnmarshalJSON(data []byte) error {
+	var all bool
+	if err := json.Unmarshal(data, &all); err == nil {
+		a.All = all
+		return nil
+	}
+	type alias AppProtect
+	return json.Unmarshal(data, (*alias)(a))
+}
+
+var ErrProtected = fmt.Errorf("protected resources")
+
+// Matches reports if the policy protects resources of the type. The stack and
+// the providers are never protected.
+func (a *AppProtect) Matches(kind string) bool {
+	if a == nil || kind == "pulumi:pulumi:Stack" || strings.HasPrefix(kind, "pulumi:providers:") {
+		return false
+	}
+	if a.All {
+		return true
+	}
+	for _, pattern := range a.Types {
+		if match, _ := path.Match(pattern, kind); match {
+			return true
+		}
+	}
+	return false
+}
+
+// protectedResources are the resources that a remove of the targets would
+// delete but are protected, in the state or by the policy. All of them are
+// deleted without targets, otherwise the targets, their children and what
+// depends on them.
+func protectedResources(resources []apitype.ResourceV3, policy *AppProtect, targets []string) []apitype.ResourceV3 {
+	deleted := map[resource.URN]bool{}
+	if len(targets) == 0 {
+		for _, item := range resources {
+			deleted[item.URN] = true
+		}
+	}
+	for _, target := range targets {
+		deleted[resource.URN(target)] = true
+	}
+	// the state lists resources after the ones they depend on
+	for _, item := range resources {
+		if deleted[item.Parent] {
+			deleted[item.URN] = true
+		}
+		for _, dep := range item.Dependencies {
+			if deleted[dep] {
+				deleted[item.URN] = true
+			}
+		}
+	}
+	protected := map[resource.URN]bool{}
+	result := []apitype.ResourceV3{}
+	for _, item := range resources {
+		if item.Protect || policy.Matches(string(item.Type)) || protected[item.Parent] {
+			protected[item.URN] = true
+			if deleted[item.URN] {
+				result = append(result, item)
+			}
+		}
+	}
+	return result
+}
+
+// unprotect clears the protect flag of the resources in the state, the rest
+// stay protected.
+func (s *State) unprotect(resources []apitype.ResourceV3) int {
+	urns := map[resource.URN]bool{}
+	for _, item := range resources {
+		urns[item.URN] = true
+	}
+	count := 0
+	for i, item := range s.checkpoint.Latest.Resources {
+		if item.Protect && urns[item.URN] {
+			s.checkpoint.Latest.Resources[i].Protect = false
+			count++
+		}
+	}
+	return count
+}
+
+const protectedListed = 10
+
+func protectedError(stage string, protected []apitype.ResourceV3) error {
+	lines := []string{fmt.Sprintf("Refusing to remove %d protected resources from the %s stage:", len(protected), stage)}
+	for i, item := range protected {
+		if i == protectedListed {
+			lines = append(lines, fmt.Sprintf("  and %d more", len(protected)-protectedListed))
+			break
+		}
+		lines = append(lines, "  "+item.URN.Name()+" ("+string(item.Type)+")")
+	}
+	lines = append(lines, "Run with --force-unprotect to remove them anyway.")
+	return util.NewReadableError(ErrProtected, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,86 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestAppProtect(t *testing.T) {
+	var app App
+	if err := json.Unmarshal([]byte(`{"protect":true}`), &app); err != nil || !app.Protect.All {
+		t.Fatalf("Expected everything to be protected, got %+v %v", app.Protect, err)
+	}
+	app = App{}
+	if err := json.Unmarshal([]byte(`{"protect":{"types":["aws:rds/*"]}}`), &app); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"aws:rds/cluster:Cluster":       true,
+		"aws:dynamodb/table:Table":      false,
+		"pulumi:pulumi:Stack":           false,
+		"pulumi:providers:aws":          false,
+		"aws:rds/clusterInstance:Other": true,
+	}
+	for kind, want := range cases {
+		if got := app.Protect.Matches(kind); got != want {
+			t.Errorf("Matches(%s) = %v, want %v", kind, got, want)
+		}
+	}
+	var none *AppProtect
+	if none.Matches("aws:rds/cluster:Cluster") {
+		t.Error("Expected no policy to protect nothing")
+	}
+	if issues := validateConfig([]byte(`{"protect":false}`), ""); len(issues) != 0 {
+		t.Errorf("Expected a boolean to be valid, got %+v", issues)
+	}
+}
+
+func TestProtectedResources(t *testing.T) {
+	urn := func(kind, name string) resource.URN {
+		return resource.URN("urn:pulumi:prod::app::" + kind + "::" + name)
+	}
+	resources := []apitype.ResourceV3{
+		{URN: urn("pulumi:pulumi:Stack", "app-prod"), Type: "pulumi:pulumi:Stack"},
+		{URN: urn("sst:aws:Postgres", "Database"), Type: "sst:aws:Postgres", Protect: true},
+		{URN: urn("sst:aws:Postgres$aws:rds/cluster:Cluster", "DatabaseCluster"), Type: "aws:rds/cluster:Cluster", Parent: urn("sst:aws:Postgres", "Database")},
+		{URN: urn("aws:s3/bucketV2:BucketV2", "Assets"), Type: "aws:s3/bucketV2:BucketV2"},
+		{URN: urn("aws:lambda/function:Function", "Api"), Type: "aws:lambda/function:Function", Dependencies: []resource.URN{urn("sst:aws:Postgres", "Database")}},
+	}
+
+	protected := protectedResources(resources, nil, nil)
+	if len(protected) != 2 || protected[0].URN.Name() != "Database" || protected[1].URN.Name() != "DatabaseCluster" {
+		t.Errorf("Expected the component and its child, got %v", protected)
+	}
+	if protected := protectedResources(resources, nil, []string{string(urn("aws:s3/bucketV2:BucketV2", "Assets"))}); len(protected) != 0 {
+		t.Errorf("Expected an unprotected target to be removable, got %v", protected)
+	}
+	policy := &AppProtect{Types: []string{"aws:s3/*"}}
+	if protected := protectedResources(resources, policy, []string{string(urn("aws:s3/bucketV2:BucketV2", "Assets"))}); len(protected) != 1 {
+		t.Errorf("Expected the policy to protect the bucket, got %v", protected)
+	}
+
+	err := protectedError("prod", protected)
+	if !errors.Is(err, ErrProtected) {
+		t.Errorf("Expected ErrProtected, got %v", err)
+	}
+}
+
+func TestUnprotect(t *testing.T) {
+	urn := func(name string) resource.URN {
+		return resource.URN("urn:pulumi:prod::app::sst:aws:Postgres::" + name)
+	}
+	state := &State{checkpoint: apitype.CheckpointV3{Latest: &apitype.DeploymentV3{Resources: []apitype.ResourceV3{
+		{URN: urn("Database"), Protect: true},
+		{URN: urn("Other"), Protect: true},
+	}}}}
+	if count := state.unprotect([]apitype.ResourceV3{{URN: urn("Database")}}); count != 1 {
+		t.Fatalf("Expected one resource to be unprotected, got %d", count)
+	}
+	if resources := state.checkpoint.Latest.Resources; resources[0].Protect || !resources[1].Protect {
+		t.Errorf("Expected only the database to be unprotected, got %+v", resources)
+	}
+}
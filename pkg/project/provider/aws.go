@@ -11,8 +11,11 @@ import (
 	"path"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/ecr"
@@ -20,7 +23,11 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
 	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/network"
+	"golang.org/x/term"
 
 	ecrTypes "github.com/aws/aws-sdk-go-v2/service/ecr/types"
 	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
@@ -31,6 +38,12 @@ type AwsProvider struct {
 	config      aws.Config
 	profile     string
 	credentials sync.Once
+	// started is set once Init is done, from then on a UI owns the terminal
+	// and logins are published instead of printed
+	started atomic.Bool
+	// refresh is held while the credentials are replaced, Config waits on it
+	// so nothing starts with credentials that are about to expire
+	refresh sync.RWMutex
 }
 
 var ErrBucketMissing = errors.New("sst state bucket missing")
@@ -56,6 +69,10 @@ func (a *AwsProvider) Init(app string, stage string, args map[string]interface{}
 	if os.Getenv("SST_AWS_NO_PROFILE") != "" {
 		delete(args, "profile")
 	}
+	interactive := func() bool {
+		return !a.started.Load()
+	}
+	profile := os.Getenv("AWS_PROFILE")
 	cfg, err := config.LoadDefaultConfig(
 		ctx,
 		func(lo *config.LoadOptions) error {
@@ -66,24 +83,87 @@ func (a *AwsProvider) Init(app string, stage string, args map[string]interface{}
 				lo.Region = region
 				lo.DefaultRegion = "us-east-1"
 			}
+			lo.HTTPClient = awshttp.NewBuildableClient().WithTransportOptions(network.Apply)
+			// profiles with an mfa_serial
+			lo.AssumeRoleCredentialOptions = func(aro *stscreds.AssumeRoleOptions) {
+				if aro.SerialNumber != nil {
+					aro.TokenProvider = mfaPrompt(interactive, *aro.SerialNumber)
+				}
+			}
 			return nil
 		},
 	)
 	if err != nil {
 		return err
 	}
+	if match, ok := args["profile"].(string); ok && match != "" {
+		profile = match
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	oidc, err := parseOIDC(args)
+	if err != nil {
+		return util.NewReadableError(err, "The oidc config of the aws provider is not valid: "+err.Error())
+	}
+	if oidc != nil {
+		slog.Info("using oidc credentials", "role", oidc.RoleArn, "session", oidc.SessionName)
+		cfg.Credentials = oidcCredentials(cfg, oidc)
+		delete(args, "oidc")
+	}
 	if assumeRole, ok := args["assumeRole"].(map[string]interface{}); ok {
 		stsclient := sts.NewFromConfig(cfg)
-		cfg.Credentials = stscreds.NewAssumeRoleProvider(stsclient, assumeRole["roleArn"].(string), func(aro *stscreds.AssumeRoleOptions) {
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(stsclient, assumeRole["roleArn"].(string), func(aro *stscreds.AssumeRoleOptions) {
 			if sessionName, ok := assumeRole["sessionName"].(string); ok {
 				aro.RoleSessionName = sessionName
 			}
-		})
+			if externalID, ok := assumeRole["externalId"].(string); ok && externalID != "" {
+				aro.ExternalID = aws.String(externalID)
+			}
+			if duration, ok := assumeRole["duration"].(string); ok {
+				if parsed, err := time.ParseDuration(duration); err == nil {
+					aro.Duration = parsed
+				}
+			}
+			if serial, ok := assumeRole["mfaSerial"].(string); ok && serial != "" {
+				aro.SerialNumber = aws.String(serial)
+				aro.TokenProvider = mfaPrompt(interactive, serial)
+			}
+		}))
+		// the pulumi provider does not know about it, the credentials it gets
+		// are already the ones of the role
+		delete(assumeRole, "mfaSerial")
+	}
+	cfg.Credentials = &reauthProvider{
+		provider: cfg.Credentials,
+		login: func(ctx context.Context) error {
+			if interactive() {
+				if !term.IsTerminal(int(os.Stderr.Fd())) {
+					return errNoTerminal
+				}
+				return ssoLogin(ctx, profile, printLogin)
+			}
+			err := ssoLogin(ctx, profile, publishLogin)
+			if err == nil {
+				bus.Publish(&AwsLoginCompleteEvent{Profile: profile})
+			}
+			return err
+		},
 	}
 	_, err = cfg.Credentials.Retrieve(ctx)
+	if errors.Is(err, ErrOIDCToken) {
+		return util.NewReadableError(err, "Could not get an OIDC token for the CI job to assume "+oidc.RoleArn+". In GitHub Actions the workflow needs the `id-token: write` permission, elsewhere put the token in SST_OIDC_TOKEN.")
+	}
+	if errors.Is(err, ErrMfaRequired) {
+		return util.NewReadableError(err, "Your AWS credentials need an MFA code, run the command again in a terminal to enter one")
+	}
 	if err != nil {
+		if isSSOExpired(err) || errors.Is(err, errNoTerminal) {
+			return util.NewReadableError(err, "Your AWS SSO session for "+profile+" expired, run `aws sso login --profile "+profile+"`")
+		}
 		return err
 	}
+	a.started.Store(true)
 	if cfg.Region == "" {
 		cfg.Region = "us-east-1"
 	}
@@ -115,9 +195,36 @@ func (a *AwsProvider) Init(app string, stage string, args map[string]interface{}
 }
 
 func (a *AwsProvider) Config() aws.Config {
+	a.refresh.RLock()
+	defer a.refresh.RUnlock()
 	return a.config
 }
 
+func (a *AwsProvider) Expiry(ctx context.Context) (time.Time, error) {
+	creds, err := a.config.Credentials.Retrieve(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !creds.CanExpire {
+		return time.Time{}, nil
+	}
+	return creds.Expires, nil
+}
+
+// Refresh gets new credentials from the profile or role, logging in again if
+// the SSO session expired.
+func (a *AwsProvider) Refresh(ctx context.Context) error {
+	a.refresh.Lock()
+	defer a.refresh.Unlock()
+	reauth, ok := a.config.Credentials.(*reauthProvider)
+	if !ok {
+		return ErrCannotRefresh
+	}
+	reauth.invalidate()
+	_, err := reauth.Retrieve(ctx)
+	return err
+}
+
 type AwsHome struct {
 	provider  *AwsProvider
 	bootstrap *AwsBootstrapData
@@ -176,6 +283,50 @@ func (a *AwsHome) putData(key, app, stage string, data io.Reader) error {
 	return nil
 }
 
+func (a *AwsHome) getDataVersion(key, app, stage string) (io.Reader, string, error) {
+	s3Client := s3.NewFromConfig(a.provider.config)
+
+	result, err := s3Client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(a.bootstrap.State),
+		Key:    aws.String(a.pathForData(key, app, stage)),
+	})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+	return result.Body, aws.ToString(result.ETag), nil
+}
+
+// putDataIf sends S3 the If-None-Match and If-Match headers of a conditional
+// write. The version of the SDK has no fields for them so they are added to
+// the request.
+func (a *AwsHome) putDataIf(key, app, stage string, data io.Reader, version string) error {
+	s3Client := s3.NewFromConfig(a.provider.config)
+
+	header, value := "If-None-Match", "*"
+	if version != "" {
+		header, value = "If-Match", version
+	}
+	_, err := s3Client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(a.bootstrap.State),
+		Key:         aws.String(a.pathForData(key, app, stage)),
+		Body:        data,
+		ContentType: aws.String("application/json"),
+	}, s3.WithAPIOptions(smithyhttp.AddHeaderValue(header, value)))
+	if err != nil {
+		var apiErr smithy.APIError
+		// a conflict is another conditional write to the key at the same time
+		if errors.As(err, &apiErr) && (apiErr.ErrorCode() == "PreconditionFailed" || apiErr.ErrorCode() == "ConditionalRequestConflict") {
+			return errConditionFailed
+		}
+		return err
+	}
+	return nil
+}
+
 func (a *AwsHome) removeData(key, app, stage string) error {
 	s3Client := s3.NewFromConfig(a.provider.config)
 
@@ -190,6 +341,27 @@ func (a *AwsHome) removeData(key, app, stage string) error {
 	return nil
 }
 
+func (a *AwsHome) listData(key, app, prefix string) ([]string, error) {
+	s3Client := s3.NewFromConfig(a.provider.config)
+	root := path.Join(key, app) + "/"
+	paginator := s3.NewListObjectsV2Paginator(s3Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(a.bootstrap.State),
+		Prefix: aws.String(root + prefix),
+	})
+	result := []string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(object.Key), root)
+			result = append(result, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	return result, nil
+}
+
 func (a *AwsHome) getPassphrase(app string, stage string) (string, error) {
 	ssmClient := ssm.NewFromConfig(a.provider.config)
 
@@ -0,0 +1,248 @@
+package provider
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	ssooidcTypes "github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+	"github.com/sst/ion/pkg/bus"
+	"golang.org/x/term"
+)
+
+var ErrMfaRequired = errors.New("mfa code required")
+var errNoTerminal = errors.New("no terminal to log in with")
+
+// AwsLoginEvent is published when the SSO session of the aws provider
+// expired and the user has to confirm a new one in the browser.
+type AwsLoginEvent struct {
+	Profile string
+	URL     string
+	Code    string
+}
+
+// AwsLoginCompleteEvent is published once the new SSO session is confirmed.
+type AwsLoginCompleteEvent struct {
+	Profile string
+}
+
+// isSSOExpired is true for the errors of an SSO session that expired or was
+// never started, which a login fixes.
+func isSSOExpired(err error) bool {
+	if err == nil {
+		return false
+	}
+	var invalid *ssocreds.InvalidTokenError
+	if errors.As(err, &invalid) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "SSO token") ||
+		strings.Contains(msg, "SSOProviderInvalidToken") ||
+		strings.Contains(msg, "sso session has expired")
+}
+
+// ssoConfig is the SSO session a profile logs in with. Key names the file
+// the token is cached in, the session name if there is one or the start URL.
+type ssoConfig struct {
+	Key      string
+	StartURL string
+	Region   string
+}
+
+func loadSSOConfig(ctx context.Context, profile string) (*ssoConfig, error) {
+	shared, err := config.LoadSharedConfigProfile(ctx, profile, func(o *config.LoadSharedConfigOptions) {
+		if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+			o.ConfigFiles = []string{path}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if shared.SSOSession != nil {
+		return &ssoConfig{
+			Key:      shared.SSOSession.Name,
+			StartURL: shared.SSOSession.SSOStartURL,
+			Region:   shared.SSOSession.SSORegion,
+		}, nil
+	}
+	if shared.SSOStartURL != "" {
+		return &ssoConfig{
+			Key:      shared.SSOStartURL,
+			StartURL: shared.SSOStartURL,
+			Region:   shared.SSORegion,
+		}, nil
+	}
+	return nil, fmt.Errorf("profile %s does not use sso", profile)
+}
+
+// ssoToken is the cached token in the format of the AWS CLI, so the SDK and
+// `aws sso login` share it.
+type ssoToken struct {
+	AccessToken           string `json:"accessToken"`
+	ExpiresAt             string `json:"expiresAt"`
+	RefreshToken          string `json:"refreshToken,omitempty"`
+	ClientID              string `json:"clientId,omitempty"`
+	ClientSecret          string `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt string `json:"registrationExpiresAt,omitempty"`
+	Region                string `json:"region"`
+	StartURL              string `json:"startUrl"`
+}
+
+func writeSSOToken(sso *ssoConfig, token *ssoToken) error {
+	path, err := ssocreds.StandardCachedTokenFilepath(sso.Key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// ssoLogin runs the device authorization of the SSO session of profile. The
+// user confirms the code in the browser, notify shows it to them.
+func ssoLogin(ctx context.Context, profile string, notify func(*AwsLoginEvent)) error {
+	sso, err := loadSSOConfig(ctx, profile)
+	if err != nil {
+		return err
+	}
+	client := ssooidc.New(ssooidc.Options{Region: sso.Region})
+	registration, err := client.RegisterClient(ctx, &ssooidc.RegisterClientInput{
+		ClientName: aws.String("sst"),
+		ClientType: aws.String("public"),
+		Scopes:     []string{"sso:account:access"},
+	})
+	if err != nil {
+		return err
+	}
+	authorization, err := client.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     registration.ClientId,
+		ClientSecret: registration.ClientSecret,
+		StartUrl:     aws.String(sso.StartURL),
+	})
+	if err != nil {
+		return err
+	}
+	notify(&AwsLoginEvent{
+		Profile: profile,
+		URL:     aws.ToString(authorization.VerificationUriComplete),
+		Code:    aws.ToString(authorization.UserCode),
+	})
+
+	interval := time.Duration(max(authorization.Interval, 1)) * time.Second
+	deadline := time.Now().Add(time.Duration(authorization.ExpiresIn) * time.Second)
+	for time.Now().Before(deadline) {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+		result, err := client.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     registration.ClientId,
+			ClientSecret: registration.ClientSecret,
+			DeviceCode:   authorization.DeviceCode,
+			GrantType:    aws.String("urn:ietf:params:oauth:grant-type:device_code"),
+		})
+		var pending *ssooidcTypes.AuthorizationPendingException
+		if errors.As(err, &pending) {
+			continue
+		}
+		var slow *ssooidcTypes.SlowDownException
+		if errors.As(err, &slow) {
+			interval += 5 * time.Second
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		slog.Info("sso login complete", "profile", profile)
+		return writeSSOToken(sso, &ssoToken{
+			AccessToken:           aws.ToString(result.AccessToken),
+			ExpiresAt:             time.Now().Add(time.Duration(result.ExpiresIn) * time.Second).UTC().Format(time.RFC3339),
+			RefreshToken:          aws.ToString(result.RefreshToken),
+			ClientID:              aws.ToString(registration.ClientId),
+			ClientSecret:          aws.ToString(registration.ClientSecret),
+			RegistrationExpiresAt: time.Unix(registration.ClientSecretExpiresAt, 0).UTC().Format(time.RFC3339),
+			Region:                sso.Region,
+			StartURL:              sso.StartURL,
+		})
+	}
+	return fmt.Errorf("the sso login for %s was not confirmed in time", profile)
+}
+
+// reauthProvider logs in again when the SSO session behind provider expires,
+// so long running commands keep going instead of failing.
+type reauthProvider struct {
+	provider aws.CredentialsProvider
+	login    func(ctx context.Context) error
+	mu       sync.Mutex
+}
+
+func (r *reauthProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	creds, err := r.provider.Retrieve(ctx)
+	if !isSSOExpired(err) {
+		return creds, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// another caller might have logged in while this one waited
+	creds, err = r.provider.Retrieve(ctx)
+	if !isSSOExpired(err) {
+		return creds, err
+	}
+	slog.Info("sso session expired, logging in")
+	if err := r.login(ctx); err != nil {
+		return aws.Credentials{}, err
+	}
+	r.invalidate()
+	return r.provider.Retrieve(ctx)
+}
+
+// invalidate drops the cached credentials so the next Retrieve gets new ones.
+func (r *reauthProvider) invalidate() {
+	if cache, ok := r.provider.(*aws.CredentialsCache); ok {
+		cache.Invalidate()
+	}
+}
+
+// mfaPrompt asks for the code of the MFA device on the terminal. It can only
+// do that while the provider is starting, before a UI takes over the
+// terminal.
+func mfaPrompt(interactive func() bool, serial string) func() (string, error) {
+	return func() (string, error) {
+		if !interactive() || !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("%w: set a new code for %s by running the command again", ErrMfaRequired, serial)
+		}
+		fmt.Fprintf(os.Stderr, "Enter the MFA code for %s: ", serial)
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(line), nil
+	}
+}
+
+func printLogin(evt *AwsLoginEvent) {
+	fmt.Fprintf(os.Stderr, "Your AWS SSO session for %s expired. Open %s and confirm the code %s to continue.\n", evt.Profile, evt.URL, evt.Code)
+}
+
+func publishLogin(evt *AwsLoginEvent) {
+	bus.Publish(evt)
+}
@@ -0,0 +1,129 @@
+package provider
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+)
+
+type fakeCredentials struct {
+	calls   int
+	expired int
+}
+
+func (f *fakeCredentials) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	f.calls++
+	if f.calls <= f.expired {
+		return aws.Credentials{}, &ssocreds.InvalidTokenError{Err: errors.New("token expired")}
+	}
+	return aws.Credentials{AccessKeyID: "AKIA"}, nil
+}
+
+func TestReauthProvider(t *testing.T) {
+	inner := &fakeCredentials{expired: 2}
+	logins := 0
+	provider := &reauthProvider{
+		provider: inner,
+		login: func(ctx context.Context) error {
+			logins++
+			return nil
+		},
+	}
+	creds, err := provider.Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if creds.AccessKeyID != "AKIA" || logins != 1 {
+		t.Errorf("Expected one login before the credentials, got %d logins and %+v", logins, creds)
+	}
+
+	failing := &reauthProvider{
+		provider: &fakeCredentials{expired: 10},
+		login: func(ctx context.Context) error {
+			return errNoTerminal
+		},
+	}
+	if _, err := failing.Retrieve(context.Background()); !errors.Is(err, errNoTerminal) {
+		t.Errorf("Expected the login error, got %v", err)
+	}
+
+	other := &reauthProvider{
+		provider: aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+			return aws.Credentials{}, errors.New("access denied")
+		}),
+		login: func(ctx context.Context) error {
+			t.Errorf("Expected no login for other errors")
+			return nil
+		},
+	}
+	other.Retrieve(context.Background())
+}
+
+func TestSSOConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	path := filepath.Join(dir, "config")
+	os.WriteFile(path, []byte(`[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-east-1
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[profile session]
+sso_session = acme
+sso_account_id = 123456789012
+sso_role_name = Admin
+
+[sso-session acme]
+sso_start_url = https://acme.awsapps.com/start
+sso_region = eu-west-1
+`), 0644)
+	t.Setenv("AWS_CONFIG_FILE", path)
+	ctx := context.Background()
+
+	legacy, err := loadSSOConfig(ctx, "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy.Key != "https://legacy.awsapps.com/start" || legacy.Region != "us-east-1" {
+		t.Errorf("Unexpected config %+v", legacy)
+	}
+	session, err := loadSSOConfig(ctx, "session")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Key != "acme" || session.StartURL != "https://acme.awsapps.com/start" || session.Region != "eu-west-1" {
+		t.Errorf("Unexpected config %+v", session)
+	}
+
+	err = writeSSOToken(session, &ssoToken{AccessToken: "token", ExpiresAt: "2030-01-01T00:00:00Z", Region: session.Region, StartURL: session.StartURL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cached, _ := ssocreds.StandardCachedTokenFilepath("acme")
+	data, err := os.ReadFile(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var token map[string]string
+	json.Unmarshal(data, &token)
+	if token["accessToken"] != "token" || token["startUrl"] != "https://acme.awsapps.com/start" {
+		t.Errorf("Unexpected cached token %s", data)
+	}
+}
+
+func TestMfaPrompt(t *testing.T) {
+	prompt := mfaPrompt(func() bool { return false }, "arn:aws:iam::123456789012:mfa/me")
+	if _, err := prompt(); !errors.Is(err, ErrMfaRequired) {
+		t.Errorf("Expected ErrMfaRequired once the UI owns the terminal, got %v", err)
+	}
+	if !isSSOExpired(&ssocreds.InvalidTokenError{Err: errors.New("expired")}) || isSSOExpired(errors.New("access denied")) {
+		t.Errorf("Unexpected isSSOExpired")
+	}
+}
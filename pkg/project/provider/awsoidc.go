@@ -0,0 +1,176 @@
+package provider
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/sst/ion/pkg/network"
+)
+
+// CI providers can hand out an OIDC token for the job, which AWS exchanges
+// for the credentials of a role that trusts them. The aws provider does that
+// itself when it has oidc in its args, or SST_AWS_OIDC_ROLE_ARN is set, so
+// workflows do not need a separate step to configure credentials.
+
+var ErrOIDCToken = errors.New("no oidc token")
+
+const oidcAudience = "sts.amazonaws.com"
+
+type oidcConfig struct {
+	RoleArn     string
+	SessionName string
+	Audience    string
+	Duration    time.Duration
+}
+
+// parseOIDC reads the oidc args of the provider, true or an object, and
+// fills in the role from the environment. It returns nil if oidc is not
+// used.
+func parseOIDC(args map[string]interface{}) (*oidcConfig, error) {
+	result := &oidcConfig{Audience: oidcAudience}
+	switch value := args["oidc"].(type) {
+	case nil:
+		if os.Getenv("SST_AWS_OIDC_ROLE_ARN") == "" {
+			return nil, nil
+		}
+	case bool:
+		if !value {
+			return nil, nil
+		}
+	case map[string]interface{}:
+		result.RoleArn, _ = value["roleArn"].(string)
+		result.SessionName, _ = value["sessionName"].(string)
+		if audience, ok := value["audience"].(string); ok && audience != "" {
+			result.Audience = audience
+		}
+		if duration, ok := value["duration"].(string); ok && duration != "" {
+			parsed, err := time.ParseDuration(duration)
+			if err != nil {
+				return nil, fmt.Errorf("invalid oidc duration %q: %w", duration, err)
+			}
+			result.Duration = parsed
+		}
+	default:
+		return nil, fmt.Errorf("oidc must be true or an object")
+	}
+	if result.RoleArn == "" {
+		result.RoleArn = os.Getenv("SST_AWS_OIDC_ROLE_ARN")
+	}
+	if result.RoleArn == "" {
+		result.RoleArn = os.Getenv("AWS_ROLE_ARN")
+	}
+	if result.RoleArn == "" {
+		return nil, fmt.Errorf("oidc needs a roleArn, or the SST_AWS_OIDC_ROLE_ARN environment variable")
+	}
+	if result.SessionName == "" {
+		result.SessionName = oidcSessionName()
+	}
+	return result, nil
+}
+
+var invalidSessionName = regexp.MustCompile(`[^\w+=,.@-]+`)
+
+// oidcSessionName names the session after the repo and the run of the CI
+// job, so CloudTrail shows which one made a change.
+func oidcSessionName() string {
+	parts := []string{"sst"}
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		parts = append(parts, os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID"), os.Getenv("GITHUB_RUN_ATTEMPT"))
+	case os.Getenv("GITLAB_CI") == "true":
+		parts = append(parts, os.Getenv("CI_PROJECT_PATH"), os.Getenv("CI_PIPELINE_ID"), os.Getenv("CI_JOB_ID"))
+	default:
+		parts = append(parts, fmt.Sprint(time.Now().Unix()))
+	}
+	filtered := []string{}
+	for _, part := range parts {
+		part = strings.Trim(invalidSessionName.ReplaceAllString(part, "-"), "-")
+		if part != "" {
+			filtered = append(filtered, part)
+		}
+	}
+	name := strings.Join(filtered, "-")
+	// the session name can be at most 64 characters, the end has the run
+	if len(name) > 64 {
+		name = name[len(name)-64:]
+	}
+	return name
+}
+
+// oidcToken gets the token of the CI job. GitHub Actions hands one out for
+// the audience when the workflow has the id-token: write permission. Other
+// providers, like GitLab with id_tokens, put them in a variable.
+type oidcToken struct {
+	audience string
+	client   *http.Client
+}
+
+func (o *oidcToken) GetIdentityToken() ([]byte, error) {
+	for _, key := range []string{"SST_OIDC_TOKEN", "GITLAB_OIDC_TOKEN"} {
+		if token := os.Getenv(key); token != "" {
+			return []byte(token), nil
+		}
+	}
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL == "" || requestToken == "" {
+		return nil, ErrOIDCToken
+	}
+	parsed, err := url.Parse(requestURL)
+	if err != nil {
+		return nil, err
+	}
+	query := parsed.Query()
+	query.Set("audience", o.audience)
+	parsed.RawQuery = query.Encode()
+	req, err := http.NewRequest(http.MethodGet, parsed.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	req.Header.Set("Accept", "application/json")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: github returned status %d", ErrOIDCToken, resp.StatusCode)
+	}
+	var body struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if body.Value == "" {
+		return nil, fmt.Errorf("%w: github returned an empty token", ErrOIDCToken)
+	}
+	return []byte(body.Value), nil
+}
+
+// oidcCredentials exchanges the token of the job for the credentials of the
+// role. They are cached and a new token is fetched when they expire.
+func oidcCredentials(cfg aws.Config, oidc *oidcConfig) aws.CredentialsProvider {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	// the exchange is not signed, it must not use the credentials it replaces
+	cfg.Credentials = aws.AnonymousCredentials{}
+	client := sts.NewFromConfig(cfg)
+	token := &oidcToken{audience: oidc.Audience, client: network.Client(10 * time.Second)}
+	return aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(client, oidc.RoleArn, token, func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = oidc.SessionName
+		o.Duration = oidc.Duration
+	}))
+}
@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func clearCIEnv(t *testing.T) {
+	for _, key := range []string{
+		"SST_AWS_OIDC_ROLE_ARN", "AWS_ROLE_ARN", "GITHUB_ACTIONS", "GITLAB_CI",
+		"SST_OIDC_TOKEN", "GITLAB_OIDC_TOKEN", "ACTIONS_ID_TOKEN_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_TOKEN",
+	} {
+		t.Setenv(key, "")
+	}
+}
+
+func TestParseOIDC(t *testing.T) {
+	clearCIEnv(t)
+	if oidc, err := parseOIDC(map[string]interface{}{}); oidc != nil || err != nil {
+		t.Fatalf("Expected no oidc without args or env, got %+v %v", oidc, err)
+	}
+	if _, err := parseOIDC(map[string]interface{}{"oidc": true}); err == nil {
+		t.Fatal("Expected an error without a role")
+	}
+
+	oidc, err := parseOIDC(map[string]interface{}{"oidc": map[string]interface{}{
+		"roleArn":     "arn:aws:iam::123:role/deploy",
+		"sessionName": "release",
+		"duration":    "30m",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oidc.RoleArn != "arn:aws:iam::123:role/deploy" || oidc.SessionName != "release" || oidc.Duration != 30*time.Minute || oidc.Audience != oidcAudience {
+		t.Errorf("Unexpected config %+v", oidc)
+	}
+
+	t.Setenv("SST_AWS_OIDC_ROLE_ARN", "arn:aws:iam::123:role/env")
+	oidc, err = parseOIDC(map[string]interface{}{})
+	if err != nil || oidc == nil || oidc.RoleArn != "arn:aws:iam::123:role/env" {
+		t.Errorf("Expected the role from the env, got %+v %v", oidc, err)
+	}
+	if oidc, _ := parseOIDC(map[string]interface{}{"oidc": false}); oidc != nil {
+		t.Error("Expected oidc: false to turn it off")
+	}
+}
+
+func TestOIDCSessionName(t *testing.T) {
+	clearCIEnv(t)
+	t.Setenv("GITHUB_ACTIONS", "true")
+	t.Setenv("GITHUB_REPOSITORY", "acme/web app")
+	t.Setenv("GITHUB_RUN_ID", "42")
+	t.Setenv("GITHUB_RUN_ATTEMPT", "2")
+	if got := oidcSessionName(); got != "sst-acme-web-app-42-2" {
+		t.Errorf("Unexpected session name %s", got)
+	}
+
+	t.Setenv("GITHUB_REPOSITORY", "acme/"+strings.Repeat("a", 80))
+	got := oidcSessionName()
+	if len(got) != 64 || !strings.HasSuffix(got, "-42-2") {
+		t.Errorf("Expected the name cut to 64 characters keeping the run, got %s", got)
+	}
+}
+
+func TestOIDCToken(t *testing.T) {
+	clearCIEnv(t)
+	token := &oidcToken{audience: oidcAudience, client: http.DefaultClient}
+	if _, err := token.GetIdentityToken(); !errors.Is(err, ErrOIDCToken) {
+		t.Fatalf("Expected ErrOIDCToken outside of CI, got %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != oidcAudience {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"value":"jwt"}`))
+	}))
+	defer server.Close()
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_URL", server.URL+"?api-version=2.0")
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "request-token")
+	value, err := token.GetIdentityToken()
+	if err != nil || string(value) != "jwt" {
+		t.Errorf("Expected the token from github, got %s %v", value, err)
+	}
+
+	t.Setenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN", "wrong")
+	if _, err := token.GetIdentityToken(); !errors.Is(err, ErrOIDCToken) {
+		t.Errorf("Expected ErrOIDCToken for a rejected request, got %v", err)
+	}
+
+	t.Setenv("SST_OIDC_TOKEN", "static")
+	if value, _ := token.GetIdentityToken(); string(value) != "static" {
+		t.Errorf("Expected the token from SST_OIDC_TOKEN, got %s", value)
+	}
+}
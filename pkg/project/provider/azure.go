@@ -0,0 +1,826 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/network"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/clientcredentials"
+)
+
+const azureManagement = "https://management.azure.com/"
+
+// azureVault is the resource of the tokens for the secrets in a key vault.
+const azureVault = "https://vault.azure.net/"
+
+type AzureProvider struct {
+	subscription string
+	location     string
+	client       *http.Client
+	// source returns the tokens of the credentials for a resource
+	source func(resource string) oauth2.TokenSource
+	// tenant and object are who the credentials belong to, read from the
+	// token
+	tenant string
+	object string
+}
+
+// azureEnv reads the ARM_ variables of the pulumi and terraform providers,
+// falling back to the AZURE_ ones of the azure sdks.
+func azureEnv(name string) string {
+	if value := os.Getenv("ARM_" + name); value != "" {
+		return value
+	}
+	return os.Getenv("AZURE_" + name)
+}
+
+func azureArg(args map[string]interface{}, key, env string) string {
+	if value, ok := args[key].(string); ok && value != "" {
+		return value
+	}
+	return azureEnv(env)
+}
+
+func (a *AzureProvider) Env() (map[string]string, error) {
+	return map[string]string{
+		"ARM_SUBSCRIPTION_ID": a.subscription,
+		"ARM_LOCATION":        a.location,
+	}, nil
+}
+
+// Init authenticates with a service principal if one is configured, a
+// managed identity if useMsi is set, and the azure cli otherwise. That is the
+// same order the pulumi provider uses.
+func (a *AzureProvider) Init(app string, stage string, args map[string]interface{}) error {
+	ctx := context.Background()
+	subscription := azureArg(args, "subscriptionId", "SUBSCRIPTION_ID")
+	clientID := azureArg(args, "clientId", "CLIENT_ID")
+	clientSecret := azureArg(args, "clientSecret", "CLIENT_SECRET")
+	tenantID := azureArg(args, "tenantId", "TENANT_ID")
+	useMsi, _ := args["useMsi"].(bool)
+	if value := azureEnv("USE_MSI"); value == "true" {
+		useMsi = true
+	}
+
+	var sources func(resource string) oauth2.TokenSource
+	switch {
+	case clientID != "" && clientSecret != "" && tenantID != "":
+		slog.Info("azure using service principal", "clientId", clientID)
+		sources = func(resource string) oauth2.TokenSource {
+			return (&clientcredentials.Config{
+				ClientID:     clientID,
+				ClientSecret: clientSecret,
+				TokenURL:     "https://login.microsoftonline.com/" + tenantID + "/oauth2/v2.0/token",
+				Scopes:       []string{resource + ".default"},
+			}).TokenSource(ctx)
+		}
+	case useMsi:
+		slog.Info("azure using managed identity")
+		sources = func(resource string) oauth2.TokenSource {
+			return &azureMsiSource{clientID: clientID, resource: resource}
+		}
+	default:
+		slog.Info("azure using the azure cli")
+		cli := &azureCliSource{resource: azureManagement}
+		if subscription == "" {
+			if _, err := cli.Token(); err != nil {
+				return util.NewReadableError(err, "Could not get your Azure credentials, run `az login` or set ARM_CLIENT_ID, ARM_CLIENT_SECRET, and ARM_TENANT_ID")
+			}
+			subscription = cli.subscription
+		}
+		sources = func(resource string) oauth2.TokenSource {
+			if resource == azureManagement {
+				return cli
+			}
+			return &azureCliSource{resource: resource}
+		}
+	}
+	source := oauth2.ReuseTokenSource(nil, sources(azureManagement))
+	token, err := source.Token()
+	if err != nil {
+		return util.NewReadableError(err, "Could not get your Azure credentials: "+err.Error())
+	}
+	a.tenant, a.object = azureClaims(token.AccessToken)
+	if subscription == "" {
+		return util.NewReadableError(nil, `Set the Azure subscription in the "azure" provider config or with ARM_SUBSCRIPTION_ID.`)
+	}
+	location := azureArg(args, "location", "LOCATION")
+	if location == "" {
+		location = "eastus"
+	}
+	a.subscription = subscription
+	a.location = location
+	a.client = oauth2.NewClient(ctx, source)
+	a.source = sources
+	if args["location"] == nil {
+		args["location"] = location
+	}
+	slog.Info("azure credentials found", "subscription", subscription, "location", location)
+	return nil
+}
+
+func (a *AzureProvider) Subscription() string {
+	return a.subscription
+}
+
+func (a *AzureProvider) Location() string {
+	return a.location
+}
+
+// clientFor returns a client with tokens for another resource than the
+// management api, like a key vault.
+func (a *AzureProvider) clientFor(resource string) *http.Client {
+	return oauth2.NewClient(context.Background(), oauth2.ReuseTokenSource(nil, a.source(resource)))
+}
+
+// azureClaims reads the tenant and the object id of the principal from an
+// access token, they are empty if it is not a jwt.
+func azureClaims(token string) (string, string) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", ""
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return "", ""
+	}
+	var claims struct {
+		Tenant string `json:"tid"`
+		Object string `json:"oid"`
+	}
+	json.Unmarshal(data, &claims)
+	return claims.Tenant, claims.Object
+}
+
+// azureCliSource gets tokens from `az account get-access-token`, which also
+// tells the subscription the cli is set to.
+type azureCliSource struct {
+	resource     string
+	subscription string
+}
+
+func (s *azureCliSource) Token() (*oauth2.Token, error) {
+	out, err := exec.Command("az", "account", "get-access-token", "--resource", s.resource, "--output", "json").Output()
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) {
+			return nil, fmt.Errorf("az account get-access-token: %s", strings.TrimSpace(string(exit.Stderr)))
+		}
+		return nil, err
+	}
+	var parsed struct {
+		AccessToken  string `json:"accessToken"`
+		ExpiresOn    string `json:"expiresOn"`
+		ExpiresOnInt int64  `json:"expires_on"`
+		Subscription string `json:"subscription"`
+	}
+	if err := json.Unmarshal(out, &parsed); err != nil {
+		return nil, err
+	}
+	s.subscription = parsed.Subscription
+	expiry := time.Unix(parsed.ExpiresOnInt, 0)
+	if parsed.ExpiresOnInt == 0 {
+		// older versions only have the local time
+		expiry, err = time.ParseInLocation("2006-01-02 15:04:05.999999", parsed.ExpiresOn, time.Local)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &oauth2.Token{AccessToken: parsed.AccessToken, Expiry: expiry}, nil
+}
+
+// azureMsiSource gets tokens from the instance metadata service of the vm the
+// command runs on.
+type azureMsiSource struct {
+	clientID string
+	resource string
+}
+
+func (s *azureMsiSource) Token() (*oauth2.Token, error) {
+	query := url.Values{
+		"api-version": {"2018-02-01"},
+		"resource":    {s.resource},
+	}
+	if s.clientID != "" {
+		query.Set("client_id", s.clientID)
+	}
+	req, err := http.NewRequest(http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Metadata", "true")
+	resp, err := network.Client(5 * time.Second).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("managed identity: %s", resp.Status)
+	}
+	var parsed struct {
+		AccessToken string `json:"access_token"`
+		ExpiresOn   string `json:"expires_on"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, err
+	}
+	seconds, err := strconv.ParseInt(parsed.ExpiresOn, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &oauth2.Token{AccessToken: parsed.AccessToken, Expiry: time.Unix(seconds, 0)}, nil
+}
+
+// AzureHome keeps the state in a blob container of a storage account it
+// creates in the subscription. The account is found again by its tag. Blobs
+// are signed with the account key like the terraform backend does, so
+// anyone that can manage the account can read the state without an extra
+// role assignment. The passphrases are in a key vault next to the account.
+type AzureHome struct {
+	provider   *AzureProvider
+	management string
+	// blob returns the endpoint of the blob service of an account
+	blob    func(account string) string
+	account string
+	key     []byte
+	// vault returns the endpoint of a key vault
+	vault       func(name string) string
+	vaultName   string
+	vaultID     string
+	vaultClient *http.Client
+}
+
+func NewAzureHome(provider *AzureProvider) *AzureHome {
+	return &AzureHome{
+		provider:   provider,
+		management: strings.TrimSuffix(azureManagement, "/"),
+		blob: func(account string) string {
+			return "https://" + account + ".blob.core.windows.net"
+		},
+		vault: func(name string) string {
+			return "https://" + name + ".vault.azure.net"
+		},
+	}
+}
+
+const (
+	azureStorageAPI  = "2023-01-01"
+	azureBlobVersion = "2021-08-06"
+	azureVaultAPI    = "2023-07-01"
+	azureSecretsAPI  = "7.4"
+	azureContainer   = "state"
+	azureGroup       = "sst"
+)
+
+var errAzureNotFound = errors.New("not found")
+var errAzureForbidden = errors.New("forbidden")
+
+func (a *AzureHome) arm(method, path string, body interface{}) ([]byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	sep := "?"
+	if strings.Contains(path, "?") {
+		sep = "&"
+	}
+	api := "api-version=" + azureStorageAPI
+	if strings.Contains(path, "api-version=") {
+		api = ""
+		sep = ""
+	}
+	req, err := http.NewRequest(method, a.management+path+sep+api, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.provider.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errAzureNotFound
+	}
+	if resp.StatusCode >= 300 {
+		var parsed struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &parsed) == nil && parsed.Error.Message != "" {
+			return nil, fmt.Errorf("azure: %s (%s)", parsed.Error.Message, parsed.Error.Code)
+		}
+		return nil, fmt.Errorf("azure: %s", resp.Status)
+	}
+	return data, nil
+}
+
+type azureAccount struct {
+	ID         string            `json:"id"`
+	Name       string            `json:"name"`
+	Tags       map[string]string `json:"tags"`
+	Properties struct {
+		ProvisioningState string `json:"provisioningState"`
+	} `json:"properties"`
+}
+
+func (a *AzureHome) Bootstrap() error {
+	subscription := "/subscriptions/" + a.provider.subscription
+	next := subscription + "/providers/Microsoft.Storage/storageAccounts"
+	var found *azureAccount
+	for next != "" && found == nil {
+		data, err := a.arm(http.MethodGet, next, nil)
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Value    []azureAccount `json:"value"`
+			NextLink string         `json:"nextLink"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		for _, account := range page.Value {
+			if account.Tags["sst"] == "state" {
+				found = &account
+				break
+			}
+		}
+		next = strings.TrimPrefix(page.NextLink, a.management)
+	}
+
+	if found == nil {
+		suffix := make([]byte, 6)
+		if _, err := rand.Read(suffix); err != nil {
+			return err
+		}
+		name := "sststate" + hex.EncodeToString(suffix)
+		slog.Info("creating storage account", "account", name)
+		group := subscription + "/resourcegroups/" + azureGroup
+		_, err := a.arm(http.MethodPut, group+"?api-version=2021-04-01", map[string]interface{}{
+			"location": a.provider.location,
+			"tags":     map[string]string{"sst": "state"},
+		})
+		if err != nil {
+			return err
+		}
+		id := group + "/providers/Microsoft.Storage/storageAccounts/" + name
+		_, err = a.arm(http.MethodPut, id, map[string]interface{}{
+			"location": a.provider.location,
+			"kind":     "StorageV2",
+			"sku":      map[string]string{"name": "Standard_LRS"},
+			"tags":     map[string]string{"sst": "state"},
+			"properties": map[string]interface{}{
+				"allowBlobPublicAccess": false,
+				"minimumTlsVersion":     "TLS1_2",
+			},
+		})
+		if err != nil {
+			return err
+		}
+		// the account is created in the background
+		for i := 0; ; i++ {
+			data, err := a.arm(http.MethodGet, id, nil)
+			if err != nil && !errors.Is(err, errAzureNotFound) {
+				return err
+			}
+			var account azureAccount
+			if data != nil {
+				if err := json.Unmarshal(data, &account); err != nil {
+					return err
+				}
+			}
+			if account.Properties.ProvisioningState == "Succeeded" {
+				found = &account
+				break
+			}
+			if i == 60 {
+				return fmt.Errorf("timed out waiting for the storage account %s", name)
+			}
+			time.Sleep(2 * time.Second)
+		}
+		_, err = a.arm(http.MethodPut, id+"/blobServices/default/containers/"+azureContainer, map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+	}
+	slog.Info("found storage account", "account", found.Name)
+
+	data, err := a.arm(http.MethodPost, found.ID+"/listKeys", nil)
+	if err != nil {
+		return err
+	}
+	var keys struct {
+		Keys []struct {
+			Value string `json:"value"`
+		} `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if len(keys.Keys) == 0 {
+		return fmt.Errorf("the storage account %s has no keys", found.Name)
+	}
+	key, err := base64.StdEncoding.DecodeString(keys.Keys[0].Value)
+	if err != nil {
+		return err
+	}
+	a.account = found.Name
+	a.key = key
+	return nil
+}
+
+// azureStringToSign is the string the shared key of a blob request signs.
+// https://learn.microsoft.com/rest/api/storageservices/authorize-with-shared-key
+func azureStringToSign(account string, req *http.Request) string {
+	length := req.Header.Get("Content-Length")
+	if length == "0" {
+		length = ""
+	}
+	headers := []string{}
+	for name := range req.Header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-ms-") {
+			headers = append(headers, lower+":"+strings.TrimSpace(req.Header.Get(name))+"\n")
+		}
+	}
+	sort.Strings(headers)
+	resource := "/" + account + req.URL.EscapedPath()
+	query := req.URL.Query()
+	params := []string{}
+	for name, values := range query {
+		sorted := append([]string{}, values...)
+		sort.Strings(sorted)
+		params = append(params, "\n"+strings.ToLower(name)+":"+strings.Join(sorted, ","))
+	}
+	sort.Strings(params)
+	return strings.Join([]string{
+		req.Method,
+		req.Header.Get("Content-Encoding"),
+		req.Header.Get("Content-Language"),
+		length,
+		req.Header.Get("Content-MD5"),
+		req.Header.Get("Content-Type"),
+		"", // Date, x-ms-date is used instead
+		req.Header.Get("If-Modified-Since"),
+		req.Header.Get("If-Match"),
+		req.Header.Get("If-None-Match"),
+		req.Header.Get("If-Unmodified-Since"),
+		req.Header.Get("Range"),
+	}, "\n") + "\n" + strings.Join(headers, "") + resource + strings.Join(params, "")
+}
+
+func (a *AzureHome) storage(method, path string, query url.Values, body []byte, headers map[string]string) ([]byte, error) {
+	target := a.blob(a.account) + "/" + azureContainer
+	if path != "" {
+		target += "/" + (&url.URL{Path: path}).EscapedPath()
+	}
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("x-ms-date", time.Now().UTC().Format(http.TimeFormat))
+	req.Header.Set("x-ms-version", azureBlobVersion)
+	req.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(azureStringToSign(a.account, req)))
+	req.Header.Set("Authorization", "SharedKey "+a.account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	resp, err := network.Client(0).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errAzureNotFound
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("azure storage: %s %s", resp.Status, resp.Header.Get("x-ms-error-code"))
+	}
+	return data, nil
+}
+
+func (a *AzureHome) pathForData(key, app, stage string) string {
+	return path.Join(key, app, fmt.Sprintf("%v.json", stage))
+}
+
+func (a *AzureHome) getData(key, app, stage string) (io.Reader, error) {
+	data, err := a.storage(http.MethodGet, a.pathForData(key, app, stage), nil, nil, nil)
+	if errors.Is(err, errAzureNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (a *AzureHome) putData(key, app, stage string, data io.Reader) error {
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	_, err = a.storage(http.MethodPut, a.pathForData(key, app, stage), nil, body, map[string]string{
+		"x-ms-blob-type": "BlockBlob",
+		"Content-Type":   "application/json",
+	})
+	return err
+}
+
+func (a *AzureHome) removeData(key, app, stage string) error {
+	_, err := a.storage(http.MethodDelete, a.pathForData(key, app, stage), nil, nil, nil)
+	if errors.Is(err, errAzureNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (a *AzureHome) listData(key, app, prefix string) ([]string, error) {
+	root := path.Join(key, app) + "/"
+	result := []string{}
+	marker := ""
+	for {
+		query := url.Values{
+			"restype": {"container"},
+			"comp":    {"list"},
+			"prefix":  {root + prefix},
+		}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		data, err := a.storage(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Blobs []struct {
+				Name string `xml:"Name"`
+			} `xml:"Blobs>Blob"`
+			NextMarker string `xml:"NextMarker"`
+		}
+		if err := xml.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		for _, blob := range page.Blobs {
+			name := strings.TrimPrefix(blob.Name, root)
+			result = append(result, strings.TrimSuffix(name, ".json"))
+		}
+		if page.NextMarker == "" {
+			return result, nil
+		}
+		marker = page.NextMarker
+	}
+}
+
+// ensureVault finds the key vault with the passphrases or creates it. The
+// account key can read every blob, so the passphrase that decrypts the
+// secrets in them is kept where reading it needs an access policy.
+func (a *AzureHome) ensureVault() error {
+	if a.vaultName != "" {
+		return nil
+	}
+	group := "/subscriptions/" + a.provider.subscription + "/resourcegroups/" + azureGroup
+	next := group + "/providers/Microsoft.KeyVault/vaults?api-version=" + azureVaultAPI
+	for next != "" {
+		data, err := a.arm(http.MethodGet, next, nil)
+		if errors.Is(err, errAzureNotFound) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Value []struct {
+				ID   string            `json:"id"`
+				Name string            `json:"name"`
+				Tags map[string]string `json:"tags"`
+			} `json:"value"`
+			NextLink string `json:"nextLink"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		for _, vault := range page.Value {
+			if vault.Tags["sst"] == "passphrase" {
+				slog.Info("found key vault", "vault", vault.Name)
+				a.vaultName, a.vaultID = vault.Name, vault.ID
+				return nil
+			}
+		}
+		next = strings.TrimPrefix(page.NextLink, a.management)
+	}
+
+	if a.provider.tenant == "" || a.provider.object == "" {
+		return fmt.Errorf("could not tell who the azure credentials belong to, the key vault for the passphrases cannot be created")
+	}
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := "sststate" + hex.EncodeToString(suffix)
+	slog.Info("creating key vault", "vault", name)
+	_, err := a.arm(http.MethodPut, group+"?api-version=2021-04-01", map[string]interface{}{
+		"location": a.provider.location,
+		"tags":     map[string]string{"sst": "state"},
+	})
+	if err != nil {
+		return err
+	}
+	id := group + "/providers/Microsoft.KeyVault/vaults/" + name
+	_, err = a.arm(http.MethodPut, id+"?api-version="+azureVaultAPI, map[string]interface{}{
+		"location": a.provider.location,
+		"tags":     map[string]string{"sst": "passphrase"},
+		"properties": map[string]interface{}{
+			"tenantId":       a.provider.tenant,
+			"sku":            map[string]string{"family": "A", "name": "standard"},
+			"accessPolicies": []interface{}{a.accessPolicy()},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for i := 0; ; i++ {
+		data, err := a.arm(http.MethodGet, id+"?api-version="+azureVaultAPI, nil)
+		if err != nil && !errors.Is(err, errAzureNotFound) {
+			return err
+		}
+		var vault struct {
+			Properties struct {
+				ProvisioningState string `json:"provisioningState"`
+			} `json:"properties"`
+		}
+		if data != nil {
+			if err := json.Unmarshal(data, &vault); err != nil {
+				return err
+			}
+		}
+		if vault.Properties.ProvisioningState == "Succeeded" {
+			break
+		}
+		if i == 60 {
+			return fmt.Errorf("timed out waiting for the key vault %s", name)
+		}
+		time.Sleep(2 * time.Second)
+	}
+	a.vaultName, a.vaultID = name, id
+	return nil
+}
+
+func (a *AzureHome) accessPolicy() map[string]interface{} {
+	return map[string]interface{}{
+		"tenantId": a.provider.tenant,
+		"objectId": a.provider.object,
+		"permissions": map[string][]string{
+			"secrets": {"get", "set", "delete"},
+		},
+	}
+}
+
+func (a *AzureHome) secret(method, name string, body interface{}) ([]byte, error) {
+	if err := a.ensureVault(); err != nil {
+		return nil, err
+	}
+	if a.vaultClient == nil {
+		a.vaultClient = a.provider.clientFor(azureVault)
+	}
+	var payload []byte
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		payload = data
+	}
+	target := a.vault(a.vaultName) + "/secrets/" + name + "?api-version=" + azureSecretsAPI
+	do := func() ([]byte, error) {
+		req, err := http.NewRequest(method, target, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := a.vaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			return nil, errAzureNotFound
+		case resp.StatusCode == http.StatusForbidden:
+			return nil, errAzureForbidden
+		case resp.StatusCode >= 300:
+			return nil, fmt.Errorf("azure key vault: %s %s", resp.Status, data)
+		}
+		return data, nil
+	}
+	data, err := do()
+	if !errors.Is(err, errAzureForbidden) {
+		return data, err
+	}
+	// like with the account key, anyone that can manage the vault can use it
+	slog.Info("adding an access policy to the key vault", "vault", a.vaultName)
+	_, err = a.arm(http.MethodPut, a.vaultID+"/accessPolicies/add?api-version="+azureVaultAPI, map[string]interface{}{
+		"properties": map[string]interface{}{
+			"accessPolicies": []interface{}{a.accessPolicy()},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// the policy takes a moment to apply
+	for i := 0; i < 10; i++ {
+		time.Sleep(2 * time.Second)
+		data, err = do()
+		if !errors.Is(err, errAzureForbidden) {
+			return data, err
+		}
+	}
+	return nil, fmt.Errorf("azure key vault: no access to %s", a.vaultName)
+}
+
+func (a *AzureHome) setPassphrase(app, stage string, passphrase string) error {
+	_, err := a.secret(http.MethodPut, passphraseName(app, stage), map[string]interface{}{
+		"value": passphrase,
+		"tags":  map[string]string{"app": app, "stage": stage},
+	})
+	return err
+}
+
+// getPassphrase moves a passphrase that was kept in the storage account into
+// the key vault.
+func (a *AzureHome) getPassphrase(app, stage string) (string, error) {
+	data, err := a.secret(http.MethodGet, passphraseName(app, stage), nil)
+	if err == nil {
+		var parsed struct {
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return "", err
+		}
+		return parsed.Value, nil
+	}
+	if !errors.Is(err, errAzureNotFound) {
+		return "", err
+	}
+	reader, err := a.getData("passphrase", app, stage)
+	if err != nil || reader == nil {
+		return "", err
+	}
+	read, err := io.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	slog.Info("moving the passphrase to the key vault", "app", app, "stage", stage)
+	if err := a.setPassphrase(app, stage, string(read)); err != nil {
+		return "", err
+	}
+	if err := a.removeData("passphrase", app, stage); err != nil {
+		return "", err
+	}
+	return string(read), nil
+}
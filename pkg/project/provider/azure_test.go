@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestAzureStringToSign(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "https://acme.blob.core.windows.net/state?restype=container&comp=list&prefix=app%2Fmyapp%2F", nil)
+	req.Header.Set("x-ms-date", "Mon, 14 Oct 2024 00:00:00 GMT")
+	req.Header.Set("x-ms-version", "2021-08-06")
+	req.Header.Set("Content-Length", "0")
+	want := "GET\n\n\n\n\n\n\n\n\n\n\n\n" +
+		"x-ms-date:Mon, 14 Oct 2024 00:00:00 GMT\nx-ms-version:2021-08-06\n" +
+		"/acme/state\ncomp:list\nprefix:app/myapp/\nrestype:container"
+	if got := azureStringToSign("acme", req); got != want {
+		t.Errorf("azureStringToSign() = %q, want %q", got, want)
+	}
+}
+
+// fakeAzure serves the management and blob apis the azure home uses, and
+// checks the shared key of every blob request.
+func fakeAzure(t *testing.T, key []byte) *httptest.Server {
+	var mu sync.Mutex
+	accounts := []azureAccount{}
+	blobs := map[string]string{}
+	vaults := []map[string]interface{}{}
+	secrets := map[string]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.HasPrefix(r.URL.Path, "/blob/") {
+			account := strings.Split(r.URL.Path, "/")[2]
+			r.Header.Set("Content-Length", fmt.Sprint(r.ContentLength))
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(azureStringToSign(account, r)))
+			if r.Header.Get("Authorization") != "SharedKey "+account+":"+base64.StdEncoding.EncodeToString(mac.Sum(nil)) {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			name := strings.TrimPrefix(r.URL.Path, "/blob/"+account+"/state/")
+			switch {
+			case r.URL.Query().Get("comp") == "list":
+				io.WriteString(w, "<EnumerationResults><Blobs>")
+				for blob := range blobs {
+					if strings.HasPrefix(blob, r.URL.Query().Get("prefix")) {
+						io.WriteString(w, "<Blob><Name>"+blob+"</Name></Blob>")
+					}
+				}
+				io.WriteString(w, "</Blobs><NextMarker/></EnumerationResults>")
+			case r.Method == http.MethodPut:
+				data, _ := io.ReadAll(r.Body)
+				blobs[name] = string(data)
+				w.WriteHeader(http.StatusCreated)
+			case r.Method == http.MethodDelete:
+				if _, ok := blobs[name]; !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				delete(blobs, name)
+			default:
+				data, ok := blobs[name]
+				if !ok {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				io.WriteString(w, data)
+			}
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, "/vault/") {
+			name := strings.TrimPrefix(r.URL.Path, "/vault/")
+			if r.Method == http.MethodPut {
+				var body map[string]interface{}
+				json.NewDecoder(r.Body).Decode(&body)
+				secrets[name] = body["value"].(string)
+				return
+			}
+			value, ok := secrets[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{"value": value})
+			return
+		}
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/providers/Microsoft.KeyVault/vaults"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": vaults})
+		case strings.Contains(r.URL.Path, "/providers/Microsoft.KeyVault/vaults/"):
+			parts := strings.Split(r.URL.Path, "/")
+			vault := map[string]interface{}{
+				"id":         r.URL.Path,
+				"name":       parts[len(parts)-1],
+				"tags":       map[string]string{"sst": "passphrase"},
+				"properties": map[string]string{"provisioningState": "Succeeded"},
+			}
+			if r.Method == http.MethodPut {
+				vaults = append(vaults, vault)
+			}
+			json.NewEncoder(w).Encode(vault)
+		case strings.HasSuffix(r.URL.Path, "/providers/Microsoft.Storage/storageAccounts") && !strings.Contains(r.URL.Path, "resourcegroups"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": accounts})
+		case strings.HasSuffix(r.URL.Path, "/listKeys"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{"value": base64.StdEncoding.EncodeToString(key)}}})
+		case strings.Contains(r.URL.Path, "/storageAccounts/") && !strings.Contains(r.URL.Path, "/blobServices/"):
+			parts := strings.Split(r.URL.Path, "/")
+			account := azureAccount{ID: r.URL.Path, Name: parts[len(parts)-1], Tags: map[string]string{"sst": "state"}}
+			account.Properties.ProvisioningState = "Succeeded"
+			if r.Method == http.MethodPut {
+				accounts = append(accounts, account)
+				w.WriteHeader(http.StatusAccepted)
+				return
+			}
+			json.NewEncoder(w).Encode(account)
+		default:
+			io.WriteString(w, "{}")
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAzureHome(t *testing.T) {
+	key := []byte("secret key")
+	srv := fakeAzure(t, key)
+	prov := &AzureProvider{subscription: "sub", location: "eastus", client: http.DefaultClient}
+	newHome := func() *AzureHome {
+		return &AzureHome{
+			provider:   prov,
+			management: srv.URL,
+			blob: func(account string) string {
+				return srv.URL + "/blob/" + account
+			},
+		}
+	}
+	home := newHome()
+	if err := home.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(home.account, "sststate") || len(home.account) > 24 {
+		t.Errorf("Unexpected account %s", home.account)
+	}
+	again := newHome()
+	if err := again.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if again.account != home.account {
+		t.Errorf("Expected the existing account %s, got %s", home.account, again.account)
+	}
+
+	if err := home.putData("app", "myapp", "dev", strings.NewReader(`{"ok":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := home.getData("app", "myapp", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(reader); string(data) != `{"ok":true}` {
+		t.Errorf("Unexpected state %s", data)
+	}
+	names, err := home.listData("app", "myapp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "dev" {
+		t.Errorf("Unexpected names %v", names)
+	}
+	if err := home.removeData("app", "myapp", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if reader, err := home.getData("app", "myapp", "dev"); err != nil || reader != nil {
+		t.Errorf("Expected no state, got %v %v", reader, err)
+	}
+}
+
+func TestAzurePassphrase(t *testing.T) {
+	key := []byte("secret key")
+	srv := fakeAzure(t, key)
+	prov := &AzureProvider{subscription: "sub", location: "eastus", client: http.DefaultClient, tenant: "tenant", object: "me"}
+	newHome := func() *AzureHome {
+		return &AzureHome{
+			provider:   prov,
+			management: srv.URL,
+			blob: func(account string) string {
+				return srv.URL + "/blob/" + account
+			},
+			vault: func(name string) string {
+				return srv.URL + "/vault"
+			},
+			vaultClient: http.DefaultClient,
+		}
+	}
+	home := newHome()
+	if err := home.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	home.putData("passphrase", "myapp", "prod", strings.NewReader("old"))
+	passphrase, err := home.getPassphrase("myapp", "prod")
+	if err != nil || passphrase != "old" {
+		t.Fatalf("Expected the passphrase from the storage account, got %q %v", passphrase, err)
+	}
+	if reader, _ := home.getData("passphrase", "myapp", "prod"); reader != nil {
+		t.Errorf("Expected the passphrase to be removed from the storage account")
+	}
+	if !strings.HasPrefix(home.vaultName, "sststate") {
+		t.Errorf("Unexpected vault %s", home.vaultName)
+	}
+
+	again := newHome()
+	if err := again.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if passphrase, err := again.getPassphrase("myapp", "prod"); err != nil || passphrase != "old" {
+		t.Errorf("Expected the passphrase from the key vault, got %q %v", passphrase, err)
+	}
+	if again.vaultName != home.vaultName {
+		t.Errorf("Expected the existing vault %s, got %s", home.vaultName, again.vaultName)
+	}
+	if passphrase, err := again.getPassphrase("myapp", "dev"); err != nil || passphrase != "" {
+		t.Errorf("Expected no passphrase, got %q %v", passphrase, err)
+	}
+}
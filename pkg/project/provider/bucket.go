@@ -0,0 +1,296 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/smithy-go"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/network"
+
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// StateConfig is the state option in sst.config.ts. It stores the state in a
+// bucket you own instead of the one the home bootstraps.
+type StateConfig struct {
+	// Backend is one of s3, r2, gcs, or local
+	Backend string `json:"backend"`
+	Bucket  string `json:"bucket"`
+	// Prefix is prepended to every key, to share a bucket between apps
+	Prefix   string `json:"prefix"`
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint"`
+	// AccountId is the Cloudflare account of an r2 bucket
+	AccountId string `json:"accountId"`
+}
+
+var ErrStateConfig = fmt.Errorf("invalid state config")
+
+// BucketHome keeps the state in a bucket that speaks the S3 API. That covers
+// S3 itself, R2 with an R2 API token, and GCS with HMAC keys.
+//
+// A state backend is a Home and not an interface of its own. Locks, history,
+// encryption, and moving a stage between homes are all written against Home,
+// and a bucket you own only differs from the one a home bootstraps in how it
+// is found, so its own interface would have the same methods.
+type BucketHome struct {
+	client *s3.Client
+	bucket string
+	prefix string
+	// ssm keeps the passphrases of an s3 bucket like the aws home does
+	ssm *ssm.Client
+}
+
+// NewStateHome returns the home for a state config. The aws provider is used
+// for the credentials of an s3 bucket if the app has one.
+func NewStateHome(cfg StateConfig, awsProvider *AwsProvider) (Home, error) {
+	ctx := context.Background()
+	if cfg.Backend == "local" {
+		return NewLocalHome(), nil
+	}
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("%w: the %s backend needs a bucket", ErrStateConfig, cfg.Backend)
+	}
+	var awsConfig aws.Config
+	endpoint := cfg.Endpoint
+	switch cfg.Backend {
+	case "s3":
+		if awsProvider != nil && cfg.Region == "" {
+			awsConfig = awsProvider.Config()
+			break
+		}
+		loaded, err := config.LoadDefaultConfig(ctx,
+			config.WithRegion(cfg.Region),
+			config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(network.Apply)),
+		)
+		if err != nil {
+			return nil, err
+		}
+		awsConfig = loaded
+	case "r2":
+		accountID := cfg.AccountId
+		if accountID == "" {
+			accountID = os.Getenv("CLOUDFLARE_DEFAULT_ACCOUNT_ID")
+		}
+		if endpoint == "" {
+			if accountID == "" {
+				return nil, fmt.Errorf("%w: the r2 backend needs an accountId", ErrStateConfig)
+			}
+			endpoint = "https://" + accountID + ".r2.cloudflarestorage.com"
+		}
+		creds, err := staticCredentials("R2_ACCESS_KEY_ID", "R2_SECRET_ACCESS_KEY")
+		if err != nil {
+			return nil, err
+		}
+		awsConfig = aws.Config{Region: "auto", Credentials: creds}
+	case "gcs":
+		if endpoint == "" {
+			endpoint = "https://storage.googleapis.com"
+		}
+		creds, err := staticCredentials("GCS_HMAC_ACCESS_KEY_ID", "GCS_HMAC_SECRET")
+		if err != nil {
+			return nil, err
+		}
+		awsConfig = aws.Config{Region: "auto", Credentials: creds}
+	default:
+		return nil, fmt.Errorf("%w: unknown backend %q, use s3, r2, gcs, or local", ErrStateConfig, cfg.Backend)
+	}
+	client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+	result := &BucketHome{
+		client: client,
+		bucket: cfg.Bucket,
+		prefix: strings.Trim(cfg.Prefix, "/"),
+	}
+	if cfg.Backend == "s3" {
+		result.ssm = ssm.NewFromConfig(awsConfig)
+	}
+	return result, nil
+}
+
+func staticCredentials(keyEnv, secretEnv string) (aws.CredentialsProvider, error) {
+	key := os.Getenv(keyEnv)
+	secret := os.Getenv(secretEnv)
+	if key == "" || secret == "" {
+		return nil, fmt.Errorf("%w: set %s and %s", ErrStateConfig, keyEnv, secretEnv)
+	}
+	return credentials.NewStaticCredentialsProvider(key, secret, ""), nil
+}
+
+// Bootstrap only checks that the bucket exists, it is not created for you.
+func (b *BucketHome) Bootstrap() error {
+	slog.Info("checking state bucket", "bucket", b.bucket)
+	_, err := b.client.HeadBucket(context.TODO(), &s3.HeadBucketInput{
+		Bucket: aws.String(b.bucket),
+	})
+	if err != nil {
+		var notFound *s3types.NotFound
+		if errors.As(err, &notFound) {
+			return fmt.Errorf("%w: %s", ErrBucketMissing, b.bucket)
+		}
+		return err
+	}
+	return nil
+}
+
+func (b *BucketHome) pathForData(key, app, stage string) string {
+	return path.Join(b.prefix, key, app, fmt.Sprintf("%v.json", stage))
+}
+
+func (b *BucketHome) getData(key, app, stage string) (io.Reader, error) {
+	result, err := b.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.pathForData(key, app, stage)),
+	})
+	if err != nil {
+		var apiErr smithy.APIError
+		if errors.As(err, &apiErr) {
+			if apiErr.ErrorCode() == "NoSuchBucket" {
+				return nil, ErrBucketMissing
+			}
+		}
+		var nsk *s3types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return result.Body, nil
+}
+
+func (b *BucketHome) putData(key, app, stage string, data io.Reader) error {
+	// the body has to be seekable to be signed by anything but s3
+	body, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	_, err = b.client.PutObject(context.TODO(), &s3.PutObjectInput{
+		Bucket:      aws.String(b.bucket),
+		Key:         aws.String(b.pathForData(key, app, stage)),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func (b *BucketHome) removeData(key, app, stage string) error {
+	_, err := b.client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(b.pathForData(key, app, stage)),
+	})
+	return err
+}
+
+func (b *BucketHome) listData(key, app, prefix string) ([]string, error) {
+	root := path.Join(b.prefix, key, app) + "/"
+	paginator := s3.NewListObjectsV2Paginator(b.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucket),
+		Prefix: aws.String(root + prefix),
+	})
+	result := []string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			name := strings.TrimPrefix(aws.ToString(object.Key), root)
+			result = append(result, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	return result, nil
+}
+
+// setPassphrase keeps the passphrase of an s3 bucket in ssm. Only the keys
+// of r2 and gcs are known, so there it is kept in the bucket sealed with
+// SST_STATE_PASSPHRASE, reading the bucket is not enough to decrypt the
+// secrets in it.
+func (b *BucketHome) setPassphrase(app, stage string, passphrase string) error {
+	if b.ssm != nil {
+		_, err := b.ssm.PutParameter(context.TODO(), &ssm.PutParameterInput{
+			Name:        aws.String(b.pathForPassphrase(app, stage)),
+			Type:        ssmTypes.ParameterTypeSecureString,
+			Value:       aws.String(passphrase),
+			Description: aws.String("DO NOT DELETE STATE WILL BECOME UNRECOVERABLE"),
+			Overwrite:   aws.Bool(false),
+		})
+		return err
+	}
+	wrapped, err := wrapPassphrase(passphrase)
+	if errors.Is(err, ErrEncryptionPassphrase) {
+		return util.NewReadableError(err, "Set "+StatePassphraseEnv+", the passphrase of the stage is encrypted with it in the state bucket")
+	}
+	if err != nil {
+		return err
+	}
+	return b.putData("passphrase", app, stage, bytes.NewReader(wrapped))
+}
+
+func (b *BucketHome) pathForPassphrase(app, stage string) string {
+	return "/" + strings.Join([]string{"sst", "passphrase", app, stage}, "/")
+}
+
+// getPassphrase moves a passphrase that was kept in the bucket as is into ssm,
+// or seals it.
+func (b *BucketHome) getPassphrase(app, stage string) (string, error) {
+	if b.ssm != nil {
+		result, err := b.ssm.GetParameter(context.TODO(), &ssm.GetParameterInput{
+			Name:           aws.String(b.pathForPassphrase(app, stage)),
+			WithDecryption: aws.Bool(true),
+		})
+		if err == nil {
+			return aws.ToString(result.Parameter.Value), nil
+		}
+		pnf := &ssmTypes.ParameterNotFound{}
+		if !errors.As(err, &pnf) {
+			return "", err
+		}
+	}
+	data, err := b.getData("passphrase", app, stage)
+	if err != nil || data == nil {
+		return "", err
+	}
+	read, err := io.ReadAll(data)
+	if err != nil {
+		return "", err
+	}
+	passphrase, wrapped, err := unwrapPassphrase(read)
+	if errors.Is(err, ErrEncryptionPassphrase) {
+		return "", util.NewReadableError(err, "Set "+StatePassphraseEnv+" to the passphrase the state bucket was set up with")
+	}
+	if err != nil {
+		return "", err
+	}
+	if wrapped && b.ssm == nil {
+		return passphrase, nil
+	}
+	slog.Info("moving the passphrase out of the state bucket", "app", app, "stage", stage)
+	if err := b.setPassphrase(app, stage, passphrase); err != nil {
+		return "", err
+	}
+	if b.ssm != nil {
+		if err := b.removeData("passphrase", app, stage); err != nil {
+			return "", err
+		}
+	}
+	return passphrase, nil
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 	_ "unsafe"
 
 	cloudflare "github.com/cloudflare/cloudflare-go"
@@ -19,6 +21,8 @@ type CloudflareProvider struct {
 	api              *cloudflare.API
 	identifier       *cloudflare.ResourceContainer
 	defaultAccountId string
+	// expiry is only known for api tokens, keys do not expire
+	expiry func() (time.Time, error)
 }
 
 var ErrCloudflareMissingAccount = fmt.Errorf("missing account")
@@ -46,8 +50,16 @@ func (c *CloudflareProvider) Init(app, stage string, args map[string]interface{}
 	if apiToken != "" {
 		api, _ = cloudflare.NewWithAPIToken(apiToken)
 	}
+	c.expiry = func() (time.Time, error) { return time.Time{}, nil }
+	if api != nil {
+		c.expiry = sync.OnceValues(func() (time.Time, error) {
+			result, err := api.VerifyAPIToken(context.Background())
+			return result.ExpiresOn, err
+		})
+	}
 	if apiKey != "" && email != "" {
 		api, _ = cloudflare.New(apiKey, email)
+		c.expiry = func() (time.Time, error) { return time.Time{}, nil }
 	}
 	if api == nil {
 		return util.NewReadableError(nil, "Cloudflare API not initialized. Please provide CLOUDFLARE_API_TOKEN or CLOUDFLARE_API_KEY and CLOUDFLARE_EMAIL environment variables or in the provider section of the project configuration file.")
@@ -70,6 +82,16 @@ func (c *CloudflareProvider) Init(app, stage string, args map[string]interface{}
 	return nil
 }
 
+func (c *CloudflareProvider) Expiry(ctx context.Context) (time.Time, error) {
+	return c.expiry()
+}
+
+// Refresh cannot do anything, a new api token has to be created in the
+// dashboard.
+func (c *CloudflareProvider) Refresh(ctx context.Context) error {
+	return ErrCannotRefresh
+}
+
 func (c CloudflareProvider) Api() *cloudflare.API {
 	return c.api
 }
@@ -155,6 +177,13 @@ func (c *CloudflareHome) removeData(kind, app, stage string) error {
 	return nil
 }
 
+var ErrListUnsupported = fmt.Errorf("listing is not supported by the cloudflare home")
+
+// the r2 api does not have a way to list objects without s3 credentials
+func (c *CloudflareHome) listData(kind, app, prefix string) ([]string, error) {
+	return nil, ErrListUnsupported
+}
+
 // these should go into secrets manager once it's out of beta
 func (c *CloudflareHome) setPassphrase(app, stage string, passphrase string) error {
 	return c.putData("passphrase", app, stage, bytes.NewReader([]byte(passphrase)))
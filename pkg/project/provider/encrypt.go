@@ -0,0 +1,347 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/kms"
+	"golang.org/x/crypto/scrypt"
+)
+
+// EncryptionConfig is the encryption option in sst.config.ts. The state is
+// encrypted with the passphrase in SST_STATE_PASSPHRASE unless KMS is set.
+type EncryptionConfig struct {
+	// KMS is the id, ARN, or alias of a KMS key
+	KMS string `json:"kms"`
+}
+
+var ErrEncryptionDisabled = fmt.Errorf("state encryption is not enabled")
+var ErrEncryptionKMS = fmt.Errorf("state is encrypted with KMS but there is no aws provider")
+var ErrEncryptionPassphrase = fmt.Errorf("state encryption needs a kms key or a passphrase in " + StatePassphraseEnv)
+
+// StatePassphraseEnv holds the passphrase the state is encrypted with. The
+// passphrase of the stage can't be used, most homes store it next to the
+// state.
+const StatePassphraseEnv = "SST_STATE_PASSPHRASE"
+
+// encryptedKeys are the kinds of data that hold the state, secrets are
+// always encrypted on their own.
+var encryptedKeys = map[string]bool{
+	"app":     true,
+	"history": true,
+}
+
+// envelope is how encrypted state is stored. Every write has its own data key
+// that is encrypted with KMS or a key derived from the passphrase and Salt.
+// Envelopes without either were encrypted with the passphrase of the stage
+// and can still be read.
+type envelope struct {
+	Encrypted int    `json:"sstEncrypted"`
+	KMS       string `json:"kms,omitempty"`
+	Salt      []byte `json:"salt,omitempty"`
+	Key       []byte `json:"key"`
+	Data      []byte `json:"data"`
+}
+
+// envelopePrefix starts every envelope since Encrypted is its first field.
+var envelopePrefix = []byte(`{"sstEncrypted":`)
+
+// EncryptedHome encrypts the state on its way to another home and decrypts it
+// on the way back. State that is not encrypted is read as is, so turning
+// encryption on or off does not break a stage.
+type EncryptedHome struct {
+	Home
+	config *EncryptionConfig
+	kms    *kms.Client
+}
+
+// NewEncryptedHome wraps home. With a nil config nothing new is encrypted but
+// existing encrypted state can still be read.
+func NewEncryptedHome(home Home, config *EncryptionConfig, awsProvider *AwsProvider) *EncryptedHome {
+	result := &EncryptedHome{
+		Home:   home,
+		config: config,
+	}
+	if awsProvider != nil {
+		result.kms = kms.NewFromConfig(awsProvider.Config())
+	}
+	return result
+}
+
+func (e *EncryptedHome) Enabled() bool {
+	return e.config != nil
+}
+
+func (e *EncryptedHome) putData(key, app, stage string, data io.Reader) error {
+	if !e.Enabled() || !encryptedKeys[key] {
+		return e.Home.putData(key, app, stage, data)
+	}
+	plaintext, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	encrypted, err := e.encrypt(app, stageOf(key, stage), plaintext)
+	if err != nil {
+		return err
+	}
+	return e.Home.putData(key, app, stage, bytes.NewReader(encrypted))
+}
+
+func (e *EncryptedHome) getData(key, app, stage string) (io.Reader, error) {
+	reader, err := e.Home.getData(key, app, stage)
+	if err != nil || reader == nil || !encryptedKeys[key] {
+		return reader, err
+	}
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.HasPrefix(data, envelopePrefix) {
+		return bytes.NewReader(data), nil
+	}
+	decrypted, err := e.decrypt(app, stageOf(key, stage), data)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(decrypted), nil
+}
+
+// stageOf returns the stage that data belongs to, for history it is followed
+// by the id of the checkpoint.
+func stageOf(key, stage string) string {
+	if key == "history" {
+		stage, _, _ = strings.Cut(stage, "/")
+	}
+	return stage
+}
+
+func (e *EncryptedHome) encrypt(app, stage string, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+	sealed, err := seal(dataKey, plaintext)
+	if err != nil {
+		return nil, err
+	}
+	result := envelope{Encrypted: 1, Data: sealed}
+	if e.config.KMS != "" {
+		if e.kms == nil {
+			return nil, ErrEncryptionKMS
+		}
+		out, err := e.kms.Encrypt(context.TODO(), &kms.EncryptInput{
+			KeyId:             aws.String(e.config.KMS),
+			Plaintext:         dataKey,
+			EncryptionContext: map[string]string{"app": app, "stage": stage},
+		})
+		if err != nil {
+			return nil, err
+		}
+		result.KMS = e.config.KMS
+		result.Key = out.CiphertextBlob
+	} else {
+		result.Salt = make([]byte, 16)
+		if _, err := rand.Read(result.Salt); err != nil {
+			return nil, err
+		}
+		kek, err := passphraseKey(result.Salt)
+		if err != nil {
+			return nil, err
+		}
+		result.Key, err = seal(kek, dataKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(result)
+}
+
+func (e *EncryptedHome) decrypt(app, stage string, data []byte) ([]byte, error) {
+	var parsed envelope
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	var dataKey []byte
+	if parsed.KMS != "" {
+		if e.kms == nil {
+			return nil, ErrEncryptionKMS
+		}
+		out, err := e.kms.Decrypt(context.TODO(), &kms.DecryptInput{
+			CiphertextBlob:    parsed.Key,
+			EncryptionContext: map[string]string{"app": app, "stage": stage},
+		})
+		if err != nil {
+			return nil, err
+		}
+		dataKey = out.Plaintext
+	} else {
+		var kek []byte
+		if parsed.Salt != nil {
+			kek, err = passphraseKey(parsed.Salt)
+		} else {
+			kek, err = e.stageKey(app, stage)
+		}
+		if err != nil {
+			return nil, err
+		}
+		dataKey, err = open(kek, parsed.Key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return open(dataKey, parsed.Data)
+}
+
+// derivedKeys are the keys derived from the passphrase by salt.
+var derivedKeys sync.Map
+
+func passphraseKey(salt []byte) ([]byte, error) {
+	passphrase := os.Getenv(StatePassphraseEnv)
+	if passphrase == "" {
+		return nil, ErrEncryptionPassphrase
+	}
+	if key, ok := derivedKeys.Load(passphrase + "\x00" + string(salt)); ok {
+		return key.([]byte), nil
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	derivedKeys.Store(passphrase+"\x00"+string(salt), key)
+	return key, nil
+}
+
+// wrappedPassphrase is how the passphrase of a stage is stored by homes that
+// have nowhere to keep it but next to the state. It is sealed with a key
+// derived from SST_STATE_PASSPHRASE.
+type wrappedPassphrase struct {
+	Wrapped int    `json:"sstWrapped"`
+	Salt    []byte `json:"salt"`
+	Data    []byte `json:"data"`
+}
+
+var wrappedPrefix = []byte(`{"sstWrapped":`)
+
+func wrapPassphrase(passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	kek, err := passphraseKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := seal(kek, []byte(passphrase))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(wrappedPassphrase{Wrapped: 1, Salt: salt, Data: sealed})
+}
+
+// unwrapPassphrase returns false if data is a passphrase that was stored
+// before they were wrapped.
+func unwrapPassphrase(data []byte) (string, bool, error) {
+	if !bytes.HasPrefix(data, wrappedPrefix) {
+		return string(data), false, nil
+	}
+	var parsed wrappedPassphrase
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", true, err
+	}
+	kek, err := passphraseKey(parsed.Salt)
+	if err != nil {
+		return "", true, err
+	}
+	opened, err := open(kek, parsed.Data)
+	if err != nil {
+		return "", true, err
+	}
+	return string(opened), true, nil
+}
+
+// stageKey is the key of envelopes written before the passphrase was needed.
+func (e *EncryptedHome) stageKey(app, stage string) ([]byte, error) {
+	passphrase, err := Passphrase(e.Home, app, stage)
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(passphrase)
+}
+
+func seal(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func open(key, sealed []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	return gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+}
+
+// EncryptStage rewrites the state and every checkpoint of a stage so they are
+// encrypted with the current config. It returns how many were rewritten.
+func EncryptStage(backend Home, app, stage string) (int, error) {
+	encrypted, ok := backend.(*EncryptedHome)
+	if !ok || !encrypted.Enabled() {
+		return 0, ErrEncryptionDisabled
+	}
+	type item struct{ key, stage string }
+	items := []item{{"app", stage}}
+	history, err := ListHistory(backend, app, stage)
+	if err != nil && !errors.Is(err, ErrListUnsupported) {
+		return 0, err
+	}
+	for _, checkpoint := range history {
+		items = append(items, item{"history", stage + "/" + checkpoint.ID})
+	}
+	count := 0
+	for _, item := range items {
+		reader, err := backend.getData(item.key, app, item.stage)
+		if err != nil {
+			return count, err
+		}
+		if reader == nil {
+			continue
+		}
+		err = backend.putData(item.key, app, item.stage, reader)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
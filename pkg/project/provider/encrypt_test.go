@@ -0,0 +1,139 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+type memoryHome struct {
+	data map[string][]byte
+}
+
+func (m *memoryHome) Bootstrap() error { return nil }
+
+func (m *memoryHome) getData(key, app, stage string) (io.Reader, error) {
+	data, ok := m.data[key+"/"+app+"/"+stage]
+	if !ok {
+		return nil, nil
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (m *memoryHome) putData(key, app, stage string, data io.Reader) error {
+	read, err := io.ReadAll(data)
+	if err != nil {
+		return err
+	}
+	m.data[key+"/"+app+"/"+stage] = read
+	return nil
+}
+
+func (m *memoryHome) removeData(key, app, stage string) error {
+	delete(m.data, key+"/"+app+"/"+stage)
+	return nil
+}
+
+func (m *memoryHome) listData(key, app, prefix string) ([]string, error) {
+	return nil, ErrListUnsupported
+}
+
+func (m *memoryHome) setPassphrase(app, stage string, passphrase string) error {
+	return m.putData("passphrase", app, stage, bytes.NewReader([]byte(passphrase)))
+}
+
+func (m *memoryHome) getPassphrase(app, stage string) (string, error) {
+	return string(m.data["passphrase/"+app+"/"+stage]), nil
+}
+
+func TestEncryptedHome(t *testing.T) {
+	inner := &memoryHome{data: map[string][]byte{}}
+	state := []byte(`{"version":3,"checkpoint":{}}`)
+	t.Setenv(StatePassphraseEnv, "correct horse battery staple")
+
+	inner.putData("app", "app", "legacy", bytes.NewReader(state))
+	home := NewEncryptedHome(inner, &EncryptionConfig{}, nil)
+	for _, item := range []struct{ key, stage string }{
+		{"app", "dev"},
+		{"history", "dev/00000000000000000001-abc"},
+	} {
+		err := home.putData(item.key, "app", item.stage, bytes.NewReader(state))
+		if err != nil {
+			t.Fatal(err)
+		}
+		stored := inner.data[item.key+"/app/"+item.stage]
+		if !bytes.HasPrefix(stored, envelopePrefix) || bytes.Contains(stored, []byte("checkpoint")) {
+			t.Fatalf("%s was not encrypted: %s", item.key, stored)
+		}
+		// reading works without the encryption config
+		reader, err := NewEncryptedHome(inner, nil, nil).getData(item.key, "app", item.stage)
+		if err != nil {
+			t.Fatal(err)
+		}
+		read, _ := io.ReadAll(reader)
+		if !bytes.Equal(read, state) {
+			t.Fatalf("%s: got %s", item.key, read)
+		}
+	}
+	if _, ok := inner.data["passphrase/app/dev"]; ok {
+		t.Fatal("the passphrase of the stage was used")
+	}
+
+	t.Setenv(StatePassphraseEnv, "wrong")
+	if _, err := NewEncryptedHome(inner, nil, nil).getData("app", "app", "dev"); err == nil {
+		t.Fatal("decrypted with the wrong passphrase")
+	}
+	t.Setenv(StatePassphraseEnv, "")
+	if err := home.putData("app", "app", "dev", bytes.NewReader(state)); err != ErrEncryptionPassphrase {
+		t.Fatalf("expected ErrEncryptionPassphrase, got %v", err)
+	}
+	t.Setenv(StatePassphraseEnv, "correct horse battery staple")
+
+	reader, err := home.getData("app", "app", "legacy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	read, _ := io.ReadAll(reader)
+	if !bytes.Equal(read, state) {
+		t.Fatalf("legacy: got %s", read)
+	}
+
+	count, err := EncryptStage(home, "app", "legacy")
+	if err != nil || count != 1 {
+		t.Fatalf("EncryptStage: %d %v", count, err)
+	}
+	if !bytes.HasPrefix(inner.data["app/app/legacy"], envelopePrefix) {
+		t.Fatal("legacy state was not encrypted")
+	}
+	if _, err := EncryptStage(NewEncryptedHome(inner, nil, nil), "app", "legacy"); err != ErrEncryptionDisabled {
+		t.Fatalf("expected ErrEncryptionDisabled, got %v", err)
+	}
+}
+
+func TestWrapPassphrase(t *testing.T) {
+	t.Setenv(StatePassphraseEnv, "correct horse battery staple")
+	wrapped, err := wrapPassphrase("stage passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(wrapped, []byte("stage passphrase")) {
+		t.Errorf("Expected the passphrase to be sealed, got %s", wrapped)
+	}
+	passphrase, ok, err := unwrapPassphrase(wrapped)
+	if err != nil || !ok || passphrase != "stage passphrase" {
+		t.Errorf("unwrapPassphrase() = %q %v %v", passphrase, ok, err)
+	}
+	if passphrase, ok, _ := unwrapPassphrase([]byte("legacy")); ok || passphrase != "legacy" {
+		t.Errorf("Expected a passphrase stored as is to be returned, got %q %v", passphrase, ok)
+	}
+
+	t.Setenv(StatePassphraseEnv, "wrong")
+	if _, _, err := unwrapPassphrase(wrapped); err == nil {
+		t.Errorf("Expected the wrong passphrase to fail")
+	}
+	t.Setenv(StatePassphraseEnv, "")
+	if _, _, err := unwrapPassphrase(wrapped); !errors.Is(err, ErrEncryptionPassphrase) {
+		t.Errorf("Expected a missing passphrase to fail with ErrEncryptionPassphrase, got %v", err)
+	}
+}
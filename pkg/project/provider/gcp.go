@@ -0,0 +1,376 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/sst/ion/internal/util"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const gcpScope = "https://www.googleapis.com/auth/cloud-platform"
+
+type GcpProvider struct {
+	project string
+	region  string
+	client  *http.Client
+}
+
+func (g *GcpProvider) Env() (map[string]string, error) {
+	return map[string]string{
+		"GOOGLE_PROJECT": g.project,
+		"GOOGLE_REGION":  g.region,
+	}, nil
+}
+
+// Init finds the credentials the same way the gcloud cli and the pulumi
+// provider do. The credentials arg is a path to a service account key or the
+// key itself, otherwise the application default credentials are used.
+func (g *GcpProvider) Init(app string, stage string, args map[string]interface{}) error {
+	ctx := context.Background()
+	var creds *google.Credentials
+	var err error
+	if value, ok := args["credentials"].(string); ok && value != "" {
+		data := []byte(value)
+		if contents, err := os.ReadFile(value); err == nil {
+			data = contents
+		}
+		creds, err = google.CredentialsFromJSON(ctx, data, gcpScope)
+	} else {
+		creds, err = google.FindDefaultCredentials(ctx, gcpScope)
+	}
+	if err != nil {
+		return util.NewReadableError(err, "Could not find your Google Cloud credentials, run `gcloud auth application-default login` or set GOOGLE_APPLICATION_CREDENTIALS to a service account key")
+	}
+	if _, err := creds.TokenSource.Token(); err != nil {
+		return util.NewReadableError(err, "Your Google Cloud credentials are invalid, run `gcloud auth application-default login`: "+err.Error())
+	}
+
+	project, _ := args["project"].(string)
+	for _, value := range []string{os.Getenv("GOOGLE_PROJECT"), os.Getenv("GOOGLE_CLOUD_PROJECT"), os.Getenv("CLOUDSDK_CORE_PROJECT"), creds.ProjectID} {
+		if project == "" {
+			project = value
+		}
+	}
+	if project == "" {
+		return util.NewReadableError(nil, `Set the Google Cloud project in the "gcp" provider config or with GOOGLE_PROJECT.`)
+	}
+	region, _ := args["region"].(string)
+	if region == "" {
+		region = os.Getenv("GOOGLE_REGION")
+	}
+	if region == "" {
+		region = "us-central1"
+	}
+	g.project = project
+	g.region = region
+	g.client = oauth2.NewClient(ctx, creds.TokenSource)
+	args["project"] = project
+	args["region"] = region
+	slog.Info("gcp credentials found", "project", project, "region", region)
+	return nil
+}
+
+func (g *GcpProvider) Project() string {
+	return g.project
+}
+
+func (g *GcpProvider) Region() string {
+	return g.region
+}
+
+// Client is an http client that authorizes its requests with the credentials
+// of the provider.
+func (g *GcpProvider) Client() *http.Client {
+	return g.client
+}
+
+// GcpHome keeps the state in a GCS bucket it creates in the project. The
+// bucket is found again by its label, so its name can have a random suffix
+// like the buckets of the aws home.
+type GcpHome struct {
+	provider *GcpProvider
+	endpoint string
+	// secrets is the endpoint of secret manager, where the passphrases are
+	secrets string
+	bucket  string
+}
+
+func NewGcpHome(provider *GcpProvider) *GcpHome {
+	return &GcpHome{
+		provider: provider,
+		endpoint: "https://storage.googleapis.com",
+		secrets:  "https://secretmanager.googleapis.com",
+	}
+}
+
+var errGcsNotFound = errors.New("not found")
+var errGcpConflict = errors.New("already exists")
+
+type gcsError struct {
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (g *GcpHome) do(method, url string, body io.Reader, contentType string) ([]byte, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := g.provider.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errGcsNotFound
+	}
+	if resp.StatusCode == http.StatusConflict {
+		return nil, errGcpConflict
+	}
+	if resp.StatusCode >= 300 {
+		var parsed gcsError
+		if json.Unmarshal(data, &parsed) == nil && parsed.Error.Message != "" {
+			return nil, fmt.Errorf("gcp: %s (%d)", parsed.Error.Message, parsed.Error.Code)
+		}
+		return nil, fmt.Errorf("gcp: %s", resp.Status)
+	}
+	return data, nil
+}
+
+func (g *GcpHome) Bootstrap() error {
+	project := url.QueryEscape(g.provider.project)
+	token := ""
+	for {
+		query := "?project=" + project + "&prefix=sst-state-"
+		if token != "" {
+			query += "&pageToken=" + url.QueryEscape(token)
+		}
+		data, err := g.do(http.MethodGet, g.endpoint+"/storage/v1/b"+query, nil, "")
+		if err != nil {
+			return err
+		}
+		var page struct {
+			Items []struct {
+				Name   string            `json:"name"`
+				Labels map[string]string `json:"labels"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return err
+		}
+		for _, bucket := range page.Items {
+			if bucket.Labels["sst"] == "state" {
+				slog.Info("found existing bucket", "bucket", bucket.Name)
+				g.bucket = bucket.Name
+				return nil
+			}
+		}
+		if page.NextPageToken == "" {
+			break
+		}
+		token = page.NextPageToken
+	}
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := "sst-state-" + hex.EncodeToString(suffix)
+	slog.Info("creating new bucket", "bucket", name)
+	body, err := json.Marshal(map[string]interface{}{
+		"name":       name,
+		"location":   g.provider.region,
+		"labels":     map[string]string{"sst": "state"},
+		"versioning": map[string]bool{"enabled": true},
+		"iamConfiguration": map[string]interface{}{
+			"uniformBucketLevelAccess": map[string]bool{"enabled": true},
+			"publicAccessPrevention":   "enforced",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = g.do(http.MethodPost, g.endpoint+"/storage/v1/b?project="+project, bytes.NewReader(body), "application/json")
+	if err != nil {
+		return err
+	}
+	g.bucket = name
+	return nil
+}
+
+func (g *GcpHome) pathForData(key, app, stage string) string {
+	return path.Join(key, app, fmt.Sprintf("%v.json", stage))
+}
+
+func (g *GcpHome) objectURL(name string) string {
+	return g.endpoint + "/storage/v1/b/" + g.bucket + "/o/" + url.PathEscape(name)
+}
+
+func (g *GcpHome) getData(key, app, stage string) (io.Reader, error) {
+	data, err := g.do(http.MethodGet, g.objectURL(g.pathForData(key, app, stage))+"?alt=media", nil, "")
+	if errors.Is(err, errGcsNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+func (g *GcpHome) putData(key, app, stage string, data io.Reader) error {
+	name := url.QueryEscape(g.pathForData(key, app, stage))
+	_, err := g.do(http.MethodPost, g.endpoint+"/upload/storage/v1/b/"+g.bucket+"/o?uploadType=media&name="+name, data, "application/json")
+	return err
+}
+
+func (g *GcpHome) removeData(key, app, stage string) error {
+	_, err := g.do(http.MethodDelete, g.objectURL(g.pathForData(key, app, stage)), nil, "")
+	if errors.Is(err, errGcsNotFound) {
+		return nil
+	}
+	return err
+}
+
+func (g *GcpHome) listData(key, app, prefix string) ([]string, error) {
+	root := path.Join(key, app) + "/"
+	result := []string{}
+	token := ""
+	for {
+		query := "?prefix=" + url.QueryEscape(root+prefix)
+		if token != "" {
+			query += "&pageToken=" + url.QueryEscape(token)
+		}
+		data, err := g.do(http.MethodGet, g.endpoint+"/storage/v1/b/"+g.bucket+"/o"+query, nil, "")
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Items []struct {
+				Name string `json:"name"`
+			} `json:"items"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(data, &page); err != nil {
+			return nil, err
+		}
+		for _, item := range page.Items {
+			name := strings.TrimPrefix(item.Name, root)
+			result = append(result, strings.TrimSuffix(name, ".json"))
+		}
+		if page.NextPageToken == "" {
+			return result, nil
+		}
+		token = page.NextPageToken
+	}
+}
+
+func (g *GcpHome) secretURL(app, stage string) string {
+	return g.secrets + "/v1/projects/" + url.PathEscape(g.provider.project) + "/secrets/" + passphraseName(app, stage)
+}
+
+// setPassphrase keeps the passphrase in secret manager instead of the bucket,
+// reading it takes secretmanager.versions.access on top of reading the state.
+// The first version is the passphrase, so when two commands set it at once
+// the one that loses fails instead of encrypting with the other passphrase.
+func (g *GcpHome) setPassphrase(app, stage string, passphrase string) error {
+	body, err := json.Marshal(map[string]interface{}{
+		"replication": map[string]interface{}{"automatic": map[string]interface{}{}},
+		"labels":      map[string]string{"sst": "passphrase"},
+	})
+	if err != nil {
+		return err
+	}
+	project := g.secrets + "/v1/projects/" + url.PathEscape(g.provider.project)
+	_, err = g.do(http.MethodPost, project+"/secrets?secretId="+passphraseName(app, stage), bytes.NewReader(body), "application/json")
+	if err != nil && !errors.Is(err, errGcpConflict) {
+		return util.NewReadableError(err, "Could not store the passphrase in Secret Manager, make sure its API is enabled in your project: "+err.Error())
+	}
+	body, err = json.Marshal(map[string]interface{}{
+		"payload": map[string]string{"data": base64.StdEncoding.EncodeToString([]byte(passphrase))},
+	})
+	if err != nil {
+		return err
+	}
+	_, err = g.do(http.MethodPost, g.secretURL(app, stage)+":addVersion", bytes.NewReader(body), "application/json")
+	if err != nil {
+		return err
+	}
+	stored, err := g.accessPassphrase(app, stage)
+	if err != nil {
+		return err
+	}
+	if stored != passphrase {
+		return fmt.Errorf("the passphrase of %s/%s was set by another command, run this again", app, stage)
+	}
+	return nil
+}
+
+func (g *GcpHome) accessPassphrase(app, stage string) (string, error) {
+	data, err := g.do(http.MethodGet, g.secretURL(app, stage)+"/versions/1:access", nil, "")
+	if err != nil {
+		return "", err
+	}
+	var parsed struct {
+		Payload struct {
+			Data string `json:"data"`
+		} `json:"payload"`
+	}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(parsed.Payload.Data)
+	if err != nil {
+		return "", err
+	}
+	return string(decoded), nil
+}
+
+// getPassphrase moves a passphrase that was kept in the bucket into secret
+// manager.
+func (g *GcpHome) getPassphrase(app, stage string) (string, error) {
+	passphrase, err := g.accessPassphrase(app, stage)
+	if err == nil || !errors.Is(err, errGcsNotFound) {
+		return passphrase, err
+	}
+	data, err := g.getData("passphrase", app, stage)
+	if err != nil || data == nil {
+		return "", err
+	}
+	read, err := io.ReadAll(data)
+	if err != nil {
+		return "", err
+	}
+	slog.Info("moving the passphrase to secret manager", "app", app, "stage", stage)
+	if err := g.setPassphrase(app, stage, string(read)); err != nil {
+		return "", err
+	}
+	if err := g.removeData("passphrase", app, stage); err != nil {
+		return "", err
+	}
+	return string(read), nil
+}
@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGcs serves the parts of the storage json api the gcp home uses.
+func fakeGcs(t *testing.T) (*httptest.Server, map[string]string) {
+	var mu sync.Mutex
+	objects := map[string]string{}
+	buckets := []map[string]interface{}{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.URL.Path == "/storage/v1/b" && r.Method == http.MethodGet:
+			// a bucket a page, to check the pages are followed
+			page := map[string]interface{}{"items": []interface{}{}}
+			index, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+			if index < len(buckets) {
+				page["items"] = buckets[index : index+1]
+			}
+			if index+1 < len(buckets) {
+				page["nextPageToken"] = strconv.Itoa(index + 1)
+			}
+			json.NewEncoder(w).Encode(page)
+		case strings.HasPrefix(r.URL.Path, "/v1/projects/"):
+			secret, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/v1/projects/acme/secrets"), ":")
+			secret = strings.TrimPrefix(secret, "/")
+			switch {
+			case r.Method == http.MethodPost && secret == "":
+				name := "secret:" + r.URL.Query().Get("secretId")
+				if _, ok := objects[name]; ok {
+					w.WriteHeader(http.StatusConflict)
+					return
+				}
+				objects[name] = ""
+			case action == "addVersion":
+				var body struct {
+					Payload struct {
+						Data string `json:"data"`
+					} `json:"payload"`
+				}
+				json.NewDecoder(r.Body).Decode(&body)
+				if objects["secret:"+secret] == "" {
+					objects["secret:"+secret] = body.Payload.Data
+				}
+			case action == "access":
+				data := objects["secret:"+strings.TrimSuffix(secret, "/versions/1")]
+				if data == "" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				json.NewEncoder(w).Encode(map[string]interface{}{"payload": map[string]string{"data": data}})
+			}
+		case r.URL.Path == "/storage/v1/b" && r.Method == http.MethodPost:
+			var bucket map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&bucket)
+			buckets = append(buckets, bucket)
+			json.NewEncoder(w).Encode(bucket)
+		case strings.HasPrefix(r.URL.Path, "/upload/storage/v1/b/"):
+			data, _ := io.ReadAll(r.Body)
+			objects[r.URL.Query().Get("name")] = string(data)
+		case strings.HasSuffix(r.URL.Path, "/o"):
+			items := []map[string]string{}
+			for name := range objects {
+				if strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+					items = append(items, map[string]string{"name": name})
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+		default:
+			_, name, _ := strings.Cut(r.URL.Path, "/o/")
+			data, ok := objects[name]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if r.Method == http.MethodDelete {
+				delete(objects, name)
+				return
+			}
+			io.WriteString(w, data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, objects
+}
+
+func TestGcpHome(t *testing.T) {
+	srv, objects := fakeGcs(t)
+	prov := &GcpProvider{project: "acme", region: "us-central1", client: http.DefaultClient}
+	home := &GcpHome{provider: prov, endpoint: srv.URL, secrets: srv.URL}
+	// a bucket of someone else comes first
+	http.Post(srv.URL+"/storage/v1/b", "application/json", strings.NewReader(`{"name":"sst-state-other"}`))
+	if err := home.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(home.bucket, "sst-state-") {
+		t.Errorf("Unexpected bucket %s", home.bucket)
+	}
+	again := &GcpHome{provider: prov, endpoint: srv.URL, secrets: srv.URL}
+	if err := again.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if again.bucket != home.bucket {
+		t.Errorf("Expected the existing bucket %s, got %s", home.bucket, again.bucket)
+	}
+
+	if err := home.putData("app", "myapp", "dev", strings.NewReader(`{"ok":true}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := objects["app/myapp/dev.json"]; !ok {
+		t.Errorf("Expected the state at app/myapp/dev.json, got %v", objects)
+	}
+	reader, err := home.getData("app", "myapp", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := io.ReadAll(reader); string(data) != `{"ok":true}` {
+		t.Errorf("Unexpected state %s", data)
+	}
+	names, err := home.listData("app", "myapp", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 1 || names[0] != "dev" {
+		t.Errorf("Unexpected names %v", names)
+	}
+
+	if err := home.removeData("app", "myapp", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := home.removeData("app", "myapp", "dev"); err != nil {
+		t.Errorf("Expected removing a missing object to succeed, got %v", err)
+	}
+	if reader, err := home.getData("app", "myapp", "dev"); err != nil || reader != nil {
+		t.Errorf("Expected no state, got %v %v", reader, err)
+	}
+}
+
+func TestGcpPassphrase(t *testing.T) {
+	srv, objects := fakeGcs(t)
+	prov := &GcpProvider{project: "acme", region: "us-central1", client: http.DefaultClient}
+	home := &GcpHome{provider: prov, endpoint: srv.URL, secrets: srv.URL, bucket: "sst-state-1"}
+
+	objects["passphrase/myapp/prod.json"] = "old"
+	passphrase, err := home.getPassphrase("myapp", "prod")
+	if err != nil || passphrase != "old" {
+		t.Fatalf("Expected the passphrase from the bucket, got %q %v", passphrase, err)
+	}
+	if _, ok := objects["passphrase/myapp/prod.json"]; ok {
+		t.Errorf("Expected the passphrase to be removed from the bucket")
+	}
+	if passphrase, err := home.getPassphrase("myapp", "prod"); err != nil || passphrase != "old" {
+		t.Errorf("Expected the passphrase from secret manager, got %q %v", passphrase, err)
+	}
+
+	if passphrase, err := home.getPassphrase("myapp", "dev"); err != nil || passphrase != "" {
+		t.Errorf("Expected no passphrase, got %q %v", passphrase, err)
+	}
+	if err := home.setPassphrase("myapp", "dev", "first"); err != nil {
+		t.Fatal(err)
+	}
+	if err := home.setPassphrase("myapp", "dev", "second"); err == nil {
+		t.Errorf("Expected setting the passphrase again to fail")
+	}
+}
@@ -0,0 +1,85 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/sst/ion/pkg/bus"
+)
+
+// ExpiringProvider is a provider with credentials that expire.
+type ExpiringProvider interface {
+	// Expiry is when the current credentials expire, zero if they do not
+	Expiry(ctx context.Context) (time.Time, error)
+	// Refresh replaces the credentials before they expire
+	Refresh(ctx context.Context) error
+}
+
+var ErrCannotRefresh = errors.New("credentials cannot be refreshed")
+
+// The statuses of CredentialsHealthEvent.
+const (
+	CredentialsExpiring  = "expiring"
+	CredentialsRefreshed = "refreshed"
+	CredentialsFailed    = "failed"
+)
+
+// CredentialsHealthEvent is published when the credentials of a provider
+// are about to expire, and again once they are refreshed or could not be.
+type CredentialsHealthEvent struct {
+	Provider  string
+	Status    string
+	ExpiresAt time.Time
+	Error     string
+}
+
+const (
+	// credentialsWarning is how long before the credentials expire they are
+	// refreshed
+	credentialsWarning = 10 * time.Minute
+	credentialsCheck   = time.Minute
+)
+
+// credentialsDue is true when credentials that expire at expiry should be
+// refreshed.
+func credentialsDue(now time.Time, expiry time.Time) bool {
+	return !expiry.IsZero() && expiry.Sub(now) <= credentialsWarning
+}
+
+// WatchCredentials checks the credentials of prov until ctx is done. When
+// they are about to expire it warns and refreshes them, if that fails the
+// warning stays up and it tries again on the next check.
+func WatchCredentials(ctx context.Context, name string, prov ExpiringProvider) {
+	ticker := time.NewTicker(credentialsCheck)
+	defer ticker.Stop()
+	warned := false
+	for {
+		expiry, err := prov.Expiry(ctx)
+		if err != nil {
+			slog.Error("failed to check credentials", "provider", name, "err", err)
+		}
+		if err == nil && credentialsDue(time.Now(), expiry) {
+			if !warned {
+				bus.Publish(&CredentialsHealthEvent{Provider: name, Status: CredentialsExpiring, ExpiresAt: expiry})
+				warned = true
+			}
+			err := prov.Refresh(ctx)
+			if err != nil && !errors.Is(err, ErrCannotRefresh) {
+				slog.Error("failed to refresh credentials", "provider", name, "err", err)
+				bus.Publish(&CredentialsHealthEvent{Provider: name, Status: CredentialsFailed, ExpiresAt: expiry, Error: err.Error()})
+			}
+			if err == nil {
+				next, _ := prov.Expiry(ctx)
+				bus.Publish(&CredentialsHealthEvent{Provider: name, Status: CredentialsRefreshed, ExpiresAt: next})
+				warned = false
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
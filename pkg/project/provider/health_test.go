@@ -0,0 +1,72 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sst/ion/pkg/bus"
+)
+
+type fakeExpiring struct {
+	expiry  time.Time
+	refresh error
+}
+
+func (f *fakeExpiring) Expiry(ctx context.Context) (time.Time, error) {
+	return f.expiry, nil
+}
+
+func (f *fakeExpiring) Refresh(ctx context.Context) error {
+	if f.refresh != nil {
+		return f.refresh
+	}
+	f.expiry = time.Now().Add(time.Hour)
+	return nil
+}
+
+func TestCredentialsDue(t *testing.T) {
+	now := time.Now()
+	if credentialsDue(now, time.Time{}) {
+		t.Errorf("Expected credentials without an expiry to never be due")
+	}
+	if credentialsDue(now, now.Add(time.Hour)) {
+		t.Errorf("Expected credentials that expire in an hour to not be due")
+	}
+	if !credentialsDue(now, now.Add(5*time.Minute)) || !credentialsDue(now, now.Add(-time.Minute)) {
+		t.Errorf("Expected credentials that expire soon to be due")
+	}
+}
+
+func watchOnce(prov ExpiringProvider) []string {
+	ctx, cancel := context.WithCancel(context.Background())
+	events := bus.Subscribe[*CredentialsHealthEvent](ctx)
+	watch, stop := context.WithCancel(context.Background())
+	stop()
+	WatchCredentials(watch, "test", prov)
+	cancel()
+	statuses := []string{}
+	for evt := range events {
+		statuses = append(statuses, evt.Status)
+	}
+	return statuses
+}
+
+func TestWatchCredentials(t *testing.T) {
+	refreshed := watchOnce(&fakeExpiring{expiry: time.Now().Add(time.Minute)})
+	if len(refreshed) != 2 || refreshed[0] != CredentialsExpiring || refreshed[1] != CredentialsRefreshed {
+		t.Errorf("Expected a warning and a refresh, got %v", refreshed)
+	}
+	failed := watchOnce(&fakeExpiring{expiry: time.Now().Add(time.Minute), refresh: errors.New("access denied")})
+	if len(failed) != 2 || failed[1] != CredentialsFailed {
+		t.Errorf("Expected the refresh to fail, got %v", failed)
+	}
+	warned := watchOnce(&fakeExpiring{expiry: time.Now().Add(time.Minute), refresh: ErrCannotRefresh})
+	if len(warned) != 1 || warned[0] != CredentialsExpiring {
+		t.Errorf("Expected only a warning, got %v", warned)
+	}
+	if fresh := watchOnce(&fakeExpiring{expiry: time.Now().Add(time.Hour)}); len(fresh) != 0 {
+		t.Errorf("Expected no events, got %v", fresh)
+	}
+}
@@ -3,10 +3,13 @@ package provider
 import (
 	"bytes"
 	"fmt"
-	"github.com/sst/ion/pkg/global"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
+
+	"github.com/sst/ion/pkg/global"
 )
 
 type LocalHome struct {
@@ -78,6 +81,32 @@ func (c *LocalHome) getPassphrase(app, stage string) (string, error) {
 	return string(read), nil
 }
 
+func (l *LocalHome) listData(key, app, prefix string) ([]string, error) {
+	root := filepath.Join(global.ConfigDir(), "state", key, app)
+	result := []string{}
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return filepath.SkipDir
+			}
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(rel), ".json")
+		if strings.HasPrefix(name, prefix) {
+			result = append(result, name)
+		}
+		return nil
+	})
+	return result, err
+}
+
 func (l *LocalHome) pathForData(key, app, stage string) string {
 	return filepath.Join(global.ConfigDir(), "state", key, app, fmt.Sprintf("%v.json", stage))
 }
@@ -5,12 +5,20 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sst/ion/pkg/flag"
@@ -23,6 +31,9 @@ type Home interface {
 	getData(key, app, stage string) (io.Reader, error)
 	putData(key, app, stage string, data io.Reader) error
 	removeData(key, app, stage string) error
+	// listData returns the names under key/app that start with prefix, in
+	// the same form as the stage passed to getData
+	listData(key, app, prefix string) ([]string, error)
 	setPassphrase(app, stage string, passphrase string) error
 	getPassphrase(app, stage string) (string, error)
 }
@@ -78,6 +89,16 @@ func Copy(from Home, to Home, app, stage string) error {
 	return nil
 }
 
+var passphraseNameInvalid = regexp.MustCompile(`[^a-zA-Z0-9-]`)
+
+// passphraseName is the name of the secret that holds the passphrase of a
+// stage in a secret store of a cloud. They only allow some characters, the
+// hash keeps apart the stages whose names only differ in the others.
+func passphraseName(app, stage string) string {
+	hash := sha256.Sum256([]byte(app + "/" + stage))
+	return "sst-passphrase-" + passphraseNameInvalid.ReplaceAllString(app+"-"+stage, "-") + "-" + hex.EncodeToString(hash[:4])
+}
+
 func Passphrase(backend Home, app, stage string) (string, error) {
 	slog.Info("getting passphrase", "app", app, "stage", stage)
 
@@ -128,6 +149,12 @@ type Summary struct {
 	ResourceDeleted int            `json:"resourceDeleted"`
 	ResourceSame    int            `json:"resourceSame"`
 	Errors          []SummaryError `json:"errors"`
+	// Interrupted is set when the update was cancelled before it finished
+	Interrupted bool `json:"interrupted,omitempty"`
+	// Secrets is the version of every secret of the stage the update used
+	Secrets map[string]int `json:"secrets,omitempty"`
+	// Timings is where the time of the update went, kept to compare deploys
+	Timings *SummaryTimings `json:"timings,omitempty"`
 }
 
 type SummaryError struct {
@@ -135,12 +162,71 @@ type SummaryError struct {
 	Message string `json:"message"`
 }
 
+type SummaryTimings struct {
+	Total time.Duration `json:"total"`
+	// Config is evaluating sst.config.ts when the project was loaded
+	Config time.Duration `json:"config"`
+	// Providers is installing and initializing the providers
+	Providers time.Duration `json:"providers"`
+	// Build is bundling the run function of sst.config.ts
+	Build time.Duration `json:"build"`
+	// Engine is the update itself, the function builds happen during it
+	Engine    time.Duration   `json:"engine"`
+	Functions []SummaryTiming `json:"functions,omitempty"`
+	// Resources are the slowest resource operations
+	Resources []SummaryTiming `json:"resources,omitempty"`
+}
+
+type SummaryTiming struct {
+	Name     string        `json:"name"`
+	Op       string        `json:"op,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
 func PutSummary(backend Home, app, stage, updateID string, summary Summary) error {
 	slog.Info("putting summary", "app", app, "stage", stage)
 	return putData(backend, "summary", app, stage+"/"+updateID, false, summary)
 
 }
 
+// GetSummary returns the summary of an update, or nil if there is none.
+func GetSummary(backend Home, app, stage, updateID string) (*Summary, error) {
+	var summary Summary
+	err := getData(backend, "summary", app, stage+"/"+updateID, false, &summary)
+	if err != nil {
+		return nil, err
+	}
+	if summary.UpdateID == "" {
+		return nil, nil
+	}
+	return &summary, nil
+}
+
+var ErrReceiptNotFound = fmt.Errorf("receipt not found")
+
+// PutReceipt uploads the receipt of an update, it is kept next to the
+// summary.
+func PutReceipt(backend Home, app, stage, updateID string, receipt interface{}) error {
+	slog.Info("putting receipt", "app", app, "stage", stage, "updateID", updateID)
+	return putData(backend, "receipt", app, stage+"/"+updateID, false, receipt)
+}
+
+// GetReceipt reads the receipt of an update into out, it is left as is if
+// there is none.
+func GetReceipt(backend Home, app, stage, updateID string, out interface{}) error {
+	return getData(backend, "receipt", app, stage+"/"+updateID, false, out)
+}
+
+// PutIncremental stores the hashes of the work incremental deploys can skip,
+// they are kept next to the state of the stage.
+func PutIncremental(backend Home, app, stage string, data interface{}) error {
+	return putData(backend, "incremental", app, stage, false, data)
+}
+
+func GetIncremental(backend Home, app, stage string, out interface{}) error {
+	return getData(backend, "incremental", app, stage, false, out)
+}
+
 func GetSecrets(backend Home, app, stage string) (map[string]string, error) {
 	if stage == "" {
 		stage = "_fallback"
@@ -164,6 +250,41 @@ func PutSecrets(backend Home, app, stage string, data map[string]string) error {
 	return putData(backend, "secret", app, stage, true, data)
 }
 
+// SecretVersion is one value a secret had. The versions of a secret start at
+// 1 and a removed secret gets a version too.
+type SecretVersion struct {
+	Version int    `json:"version"`
+	Value   string `json:"value,omitempty"`
+	// Native is the version the backend of a secret gave it, like the
+	// version of an ssm parameter. The value is only kept in the backend then.
+	Native  string    `json:"native,omitempty"`
+	Removed bool      `json:"removed,omitempty"`
+	Time    time.Time `json:"time"`
+	// Holder is who made the change, like with locks
+	Holder string `json:"holder"`
+}
+
+// GetSecretHistory returns every version of the secrets of a stage, oldest
+// first. It is encrypted with the passphrase of the stage like the secrets.
+func GetSecretHistory(backend Home, app, stage string) (map[string][]SecretVersion, error) {
+	if stage == "" {
+		stage = "_fallback"
+	}
+	data := map[string][]SecretVersion{}
+	err := getData(backend, "secret-history", app, stage, true, &data)
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func PutSecretHistory(backend Home, app, stage string, data map[string][]SecretVersion) error {
+	if stage == "" {
+		stage = "_fallback"
+	}
+	return putData(backend, "secret-history", app, stage, true, data)
+}
+
 func PushState(backend Home, updateID string, app, stage string, from string) error {
 	slog.Info("pushing state", "app", app, "stage", stage, "from", from)
 	file, err := os.Open(from)
@@ -190,6 +311,88 @@ func PushState(backend Home, updateID string, app, stage string, from string) er
 }
 
 var ErrStateNotFound = fmt.Errorf("state not found")
+var ErrCheckpointNotFound = fmt.Errorf("checkpoint not found")
+
+// Checkpoint is a copy of the state pushed at the end of an update.
+type Checkpoint struct {
+	ID       string    `json:"id"`
+	UpdateID string    `json:"updateID"`
+	Time     time.Time `json:"time"`
+}
+
+// ListHistory returns the checkpoints of a stage, newest first.
+func ListHistory(backend Home, app, stage string) ([]Checkpoint, error) {
+	names, err := backend.listData("history", app, stage+"/")
+	if err != nil {
+		return nil, err
+	}
+	result := []Checkpoint{}
+	for _, name := range names {
+		id := strings.TrimPrefix(name, stage+"/")
+		prefix, updateID, ok := strings.Cut(id, "-")
+		if !ok {
+			continue
+		}
+		inverted, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			continue
+		}
+		result = append(result, Checkpoint{
+			ID:       id,
+			UpdateID: updateID,
+			Time:     time.Unix(math.MaxInt64-inverted, 0),
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].ID < result[j].ID
+	})
+	return result, nil
+}
+
+// PruneHistory removes all but the newest keep checkpoints of a stage and
+// returns how many were removed.
+func PruneHistory(backend Home, app, stage string, keep int) (int, error) {
+	history, err := ListHistory(backend, app, stage)
+	if err != nil {
+		return 0, err
+	}
+	if len(history) <= keep {
+		return 0, nil
+	}
+	removed := 0
+	for _, item := range history[keep:] {
+		err := backend.removeData("history", app, stage+"/"+item.ID)
+		if err != nil {
+			return removed, err
+		}
+		removed++
+		// the receipt is only there if history.receipts was set
+		if item.UpdateID != "" {
+			if err := backend.removeData("receipt", app, stage+"/"+item.UpdateID); err != nil && !errors.Is(err, os.ErrNotExist) {
+				slog.Warn("failed to remove receipt", "updateID", item.UpdateID, "err", err)
+			}
+		}
+	}
+	return removed, nil
+}
+
+func PullCheckpoint(backend Home, app, stage, id string, out string) error {
+	slog.Info("pulling checkpoint", "app", app, "stage", stage, "id", id)
+	reader, err := backend.getData("history", app, stage+"/"+id)
+	if err != nil {
+		return err
+	}
+	if reader == nil {
+		return ErrCheckpointNotFound
+	}
+	file, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.Copy(file, reader)
+	return err
+}
 
 func PullState(backend Home, app, stage string, out string) error {
 	slog.Info("pulling state", "app", app, "stage", stage, "out", out)
@@ -212,34 +415,210 @@ func PullState(backend Home, app, stage string, out string) error {
 	return nil
 }
 
-type lockData struct {
+type LockInfo struct {
 	Created  time.Time `json:"created"`
 	UpdateID string    `json:"updateID"`
 	RunID    string    `json:"runID"`
 	Command  string    `json:"command"`
+	// Holder is the user and host that took the lock
+	Holder string `json:"holder,omitempty"`
+	// Expires is pushed forward by the holder every LockRenew, a lock that is
+	// past it was left behind and is taken over by the next update. Locks
+	// taken by older versions do not expire.
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+const (
+	LockLease = 2 * time.Minute
+	LockRenew = 30 * time.Second
+	// lockSettle is how long to wait before reading back a lock that was just
+	// written, to catch another update that took it at the same time
+	lockSettle = time.Second
+)
+
+var ErrLockLost = fmt.Errorf("lock was taken by another update")
+
+func (l *LockInfo) Expired() bool {
+	return !l.Expires.IsZero() && time.Now().After(l.Expires)
+}
+
+func LockHolder() string {
+	name := "unknown"
+	if current, err := user.Current(); err == nil {
+		name = current.Username
+	}
+	if host, err := os.Hostname(); err == nil {
+		name += "@" + host
+	}
+	return name
+}
+
+// conditionalHome is a home that can write data only if it has not changed
+// since it was read. The lock is taken and renewed with it so two updates
+// cannot both get it.
+type conditionalHome interface {
+	// getDataVersion is getData with the version of the data, like an etag.
+	// The version is empty if there is no data.
+	getDataVersion(key, app, stage string) (io.Reader, string, error)
+	// putDataIf writes data only if its version is still version, or if there
+	// is none when version is empty. It returns errConditionFailed otherwise.
+	putDataIf(key, app, stage string, data io.Reader, version string) error
+}
+
+var errConditionFailed = fmt.Errorf("data was changed by someone else")
+
+// conditional returns home as a conditionalHome if it is one. The lock is
+// never encrypted so an EncryptedHome is looked through.
+func conditional(backend Home) (conditionalHome, bool) {
+	if encrypted, ok := backend.(*EncryptedHome); ok {
+		backend = encrypted.Home
+	}
+	result, ok := backend.(conditionalHome)
+	return result, ok
+}
+
+func getLockVersion(backend conditionalHome, app, stage string) (*LockInfo, string, error) {
+	reader, version, err := backend.getDataVersion("lock", app, stage)
+	if err != nil || reader == nil {
+		return nil, "", err
+	}
+	var lock LockInfo
+	if err := json.NewDecoder(reader).Decode(&lock); err != nil {
+		return nil, "", err
+	}
+	if lock.Created.IsZero() {
+		return nil, version, nil
+	}
+	return &lock, version, nil
+}
+
+func putLockIf(backend conditionalHome, app, stage string, lock *LockInfo, version string) error {
+	data, err := json.Marshal(lock)
+	if err != nil {
+		return err
+	}
+	return backend.putDataIf("lock", app, stage, bytes.NewReader(data), version)
 }
 
+func newLock(updateID, command string) *LockInfo {
+	return &LockInfo{
+		RunID:    os.Getenv("SST_RUN_ID"),
+		Created:  time.Now(),
+		UpdateID: updateID,
+		Command:  command,
+		Holder:   LockHolder(),
+		Expires:  time.Now().Add(LockLease),
+	}
+}
+
+// Lock takes the lock on a stage for an update. On the homes with conditional
+// writes it is written only if it is not there, or is the expired lock that
+// was read. The others have no compare and swap in the APIs they are called
+// with, so the lock is read back after it is written and the update that
+// wrote last gets it.
 func Lock(backend Home, updateID, command, app, stage string) error {
 	slog.Info("locking", "app", app, "stage", stage)
-	var lockData lockData
+	if home, ok := conditional(backend); ok {
+		existing, version, err := getLockVersion(home, app, stage)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			if !existing.Expired() {
+				return ErrLockExists
+			}
+			slog.Info("taking over expired lock", "updateID", existing.UpdateID, "holder", existing.Holder, "expires", existing.Expires)
+		}
+		err = putLockIf(home, app, stage, newLock(updateID, command), version)
+		if errors.Is(err, errConditionFailed) {
+			return ErrLockExists
+		}
+		return err
+	}
+	var lockData LockInfo
 	err := getData(backend, "lock", app, stage, false, &lockData)
 	if err != nil {
 		return err
 	}
 	if !lockData.Created.IsZero() {
-		return ErrLockExists
+		if !lockData.Expired() {
+			return ErrLockExists
+		}
+		slog.Info("taking over expired lock", "updateID", lockData.UpdateID, "holder", lockData.Holder, "expires", lockData.Expires)
 	}
-	lockData.RunID = os.Getenv("SST_RUN_ID")
-	lockData.Created = time.Now()
-	lockData.UpdateID = updateID
-	lockData.Command = command
-	err = putData(backend, "lock", app, stage, false, lockData)
+	err = putData(backend, "lock", app, stage, false, newLock(updateID, command))
 	if err != nil {
 		return err
 	}
+	time.Sleep(lockSettle)
+	current, err := GetLock(backend, app, stage)
+	if err != nil {
+		return err
+	}
+	if current == nil || current.UpdateID != updateID {
+		return ErrLockExists
+	}
 	return nil
 }
 
+// RenewLock extends the lease on a lock held by updateID. With conditional
+// writes the lease is only written over the lock that was read, otherwise an
+// update that took the lock in between can still be overwritten.
+func RenewLock(backend Home, updateID, app, stage string) error {
+	if home, ok := conditional(backend); ok {
+		lock, version, err := getLockVersion(home, app, stage)
+		if err != nil {
+			return err
+		}
+		if lock == nil || lock.UpdateID != updateID {
+			return ErrLockLost
+		}
+		lock.Expires = time.Now().Add(LockLease)
+		err = putLockIf(home, app, stage, lock, version)
+		if errors.Is(err, errConditionFailed) {
+			return ErrLockLost
+		}
+		return err
+	}
+	lock, err := GetLock(backend, app, stage)
+	if err != nil {
+		return err
+	}
+	if lock == nil || lock.UpdateID != updateID {
+		return ErrLockLost
+	}
+	lock.Expires = time.Now().Add(LockLease)
+	return putData(backend, "lock", app, stage, false, lock)
+}
+
+// ListStages returns the stages of app that have state.
+func ListStages(backend Home, app string) ([]string, error) {
+	names, err := backend.listData("app", app, "")
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	for _, name := range names {
+		if !strings.Contains(name, "/") {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// GetLock returns the lock on a stage, or nil if it is not locked.
+func GetLock(backend Home, app, stage string) (*LockInfo, error) {
+	var lock LockInfo
+	err := getData(backend, "lock", app, stage, false, &lock)
+	if err != nil {
+		return nil, err
+	}
+	if lock.Created.IsZero() {
+		return nil, nil
+	}
+	return &lock, nil
+}
+
 func Unlock(backend Home, app, stage string) error {
 	slog.Info("unlocking", "app", app, "stage", stage)
 	return removeData(backend, "lock", app, stage)
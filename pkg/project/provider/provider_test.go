@@ -0,0 +1,78 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+// versionedHome keeps a version of every key like the etags of S3.
+type versionedHome struct {
+	memoryHome
+	versions map[string]int
+}
+
+func (v *versionedHome) getDataVersion(key, app, stage string) (io.Reader, string, error) {
+	reader, err := v.getData(key, app, stage)
+	if reader == nil || err != nil {
+		return nil, "", err
+	}
+	return reader, fmt.Sprint(v.versions[key+"/"+app+"/"+stage]), nil
+}
+
+func (v *versionedHome) putDataIf(key, app, stage string, data io.Reader, version string) error {
+	path := key + "/" + app + "/" + stage
+	_, exists := v.data[path]
+	if (version == "" && exists) || (version != "" && version != fmt.Sprint(v.versions[path])) {
+		return errConditionFailed
+	}
+	v.versions[path]++
+	return v.putData(key, app, stage, data)
+}
+
+func TestConditionalLock(t *testing.T) {
+	home := &versionedHome{memoryHome: memoryHome{data: map[string][]byte{}}, versions: map[string]int{}}
+	backend := NewEncryptedHome(home, nil, nil)
+
+	if err := Lock(backend, "first", "deploy", "app", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Lock(backend, "second", "deploy", "app", "dev"); !errors.Is(err, ErrLockExists) {
+		t.Fatalf("Expected ErrLockExists, got %v", err)
+	}
+	if err := RenewLock(backend, "first", "app", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RenewLock(backend, "second", "app", "dev"); !errors.Is(err, ErrLockLost) {
+		t.Fatalf("Expected ErrLockLost, got %v", err)
+	}
+
+	// a lock written after it was read is not overwritten
+	lock, version, err := getLockVersion(home, "app", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := putLockIf(home, "app", "dev", newLock("second", "deploy"), version); err != nil {
+		t.Fatal(err)
+	}
+	lock.Expires = time.Now().Add(LockLease)
+	if err := putLockIf(home, "app", "dev", lock, version); !errors.Is(err, errConditionFailed) {
+		t.Fatalf("Expected errConditionFailed, got %v", err)
+	}
+
+	// an expired lock is taken over
+	expired := newLock("second", "deploy")
+	expired.Expires = time.Now().Add(-time.Minute)
+	data, _ := json.Marshal(expired)
+	home.putData("lock", "app", "dev", bytes.NewReader(data))
+	if err := Lock(backend, "third", "deploy", "app", "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if current, _ := GetLock(backend, "app", "dev"); current == nil || current.UpdateID != "third" {
+		t.Fatalf("Expected the lock of third, got %+v", current)
+	}
+}
@@ -0,0 +1,292 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+// Receipt is the record of what an update did. One is written to
+// .sst/receipts for every deploy, remove, and refresh outside of sst dev,
+// and uploaded next to the checkpoint when history.receipts is set.
+type Receipt struct {
+	DeployID  string    `json:"deployID"`
+	App       string    `json:"app"`
+	Stage     string    `json:"stage"`
+	Command   string    `json:"command"`
+	Version   string    `json:"version"`
+	Status    string    `json:"status"`
+	Started   time.Time `json:"started"`
+	Completed time.Time `json:"completed"`
+	// Duration is in nanoseconds, like the timings of the summary
+	Duration time.Duration `json:"duration"`
+	Git      *ReceiptGit   `json:"git,omitempty"`
+	// ConfigHash is the sha256 of sst.config.ts and the overrides of the
+	// stage
+	ConfigHash string                 `json:"configHash"`
+	Operator   ReceiptOperator        `json:"operator"`
+	Changes    []ReceiptChange        `json:"changes"`
+	Outputs    map[string]interface{} `json:"outputs"`
+	Errors     []Error                `json:"errors"`
+}
+
+type ReceiptGit struct {
+	SHA    string `json:"sha"`
+	Branch string `json:"branch,omitempty"`
+	Dirty  bool   `json:"dirty"`
+}
+
+type ReceiptOperator struct {
+	// User is the user and host the cli ran as, like for the lock
+	User string `json:"user"`
+	// CI is the CI provider the update ran in, and Actor who started it there
+	CI    string `json:"ci,omitempty"`
+	Actor string `json:"actor,omitempty"`
+	Run   string `json:"run,omitempty"`
+}
+
+type ReceiptChange struct {
+	URN    string `json:"urn"`
+	Type   string `json:"type"`
+	Op     string `json:"op"`
+	Failed bool   `json:"failed,omitempty"`
+}
+
+const (
+	ReceiptSuccess     = "success"
+	ReceiptFailed      = "failed"
+	ReceiptInterrupted = "interrupted"
+)
+
+func (p *Project) PathReceipts() string {
+	return filepath.Join(p.PathWorkingDir(), "receipts")
+}
+
+// receiptChanges collects the resources an update changed from its engine
+// events, the ones that failed are marked.
+type receiptChanges struct {
+	list  []ReceiptChange
+	index map[string]int
+}
+
+func newReceiptChanges() *receiptChanges {
+	return &receiptChanges{list: []ReceiptChange{}, index: map[string]int{}}
+}
+
+func (r *receiptChanges) add(metadata apitype.StepEventMetadata, failed bool) {
+	switch metadata.Op {
+	case apitype.OpSame, apitype.OpRead, apitype.OpRefresh:
+		if !failed {
+			return
+		}
+	}
+	change := ReceiptChange{
+		URN:    metadata.URN,
+		Type:   metadata.Type,
+		Op:     string(metadata.Op),
+		Failed: failed,
+	}
+	if i, ok := r.index[metadata.URN]; ok {
+		r.list[i] = change
+		return
+	}
+	r.index[metadata.URN] = len(r.list)
+	r.list = append(r.list, change)
+}
+
+func (p *Project) newReceipt(input *StackInput, updateID string, started time.Time, complete *CompleteEvent, changes []ReceiptChange) *Receipt {
+	completed := time.Now()
+	receipt := &Receipt{
+		DeployID:   updateID,
+		App:        p.app.Name,
+		Stage:      p.app.Stage,
+		Command:    input.Command,
+		Version:    p.version,
+		Status:     ReceiptSuccess,
+		Started:    started.UTC(),
+		Completed:  completed.UTC(),
+		Duration:   completed.Sub(started),
+		Git:        gitInfo(p.PathRoot()),
+		ConfigHash: configHash(p.PathConfig(), p.PathConfigOverride()),
+		Operator:   receiptOperator(),
+		Changes:    changes,
+		Outputs:    publicOutputs(complete.Outputs),
+		Errors:     complete.Errors,
+	}
+	if len(complete.Errors) > 0 {
+		receipt.Status = ReceiptFailed
+	} else if !complete.Finished {
+		receipt.Status = ReceiptInterrupted
+	}
+	return receipt
+}
+
+// writeReceipt saves the receipt locally and uploads it if the app asks for
+// it. A receipt that cannot be written does not fail the update.
+func (p *Project) writeReceipt(receipt *Receipt) error {
+	if err := os.MkdirAll(p.PathReceipts(), 0755); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(receipt, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(p.PathReceipts(), receipt.DeployID+".json"), data, 0644); err != nil {
+		return err
+	}
+	if !p.app.History.Receipts {
+		return nil
+	}
+	return provider.PutReceipt(p.home, p.app.Name, p.app.Stage, receipt.DeployID, receipt)
+}
+
+// pruneReceipts keeps the newest receipts in dir, like history.retain does
+// for the checkpoints.
+func pruneReceipts(dir string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	type entry struct {
+		path    string
+		modTime time.Time
+	}
+	receipts := []entry{}
+	for _, item := range entries {
+		if item.IsDir() || !strings.HasSuffix(item.Name(), ".json") {
+			continue
+		}
+		info, err := item.Info()
+		if err != nil {
+			continue
+		}
+		receipts = append(receipts, entry{filepath.Join(dir, item.Name()), info.ModTime()})
+	}
+	if len(receipts) <= keep {
+		return nil
+	}
+	sort.Slice(receipts, func(i, j int) bool {
+		return receipts[i].modTime.After(receipts[j].modTime)
+	})
+	for _, item := range receipts[keep:] {
+		if err := os.Remove(item.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadReceipt returns the receipt of an update, from .sst/receipts if it was
+// made here or from the home if it was uploaded.
+func (p *Project) ReadReceipt(updateID string) (*Receipt, error) {
+	data, err := os.ReadFile(filepath.Join(p.PathReceipts(), updateID+".json"))
+	if err == nil {
+		var receipt Receipt
+		if err := json.Unmarshal(data, &receipt); err != nil {
+			return nil, err
+		}
+		return &receipt, nil
+	}
+	var receipt Receipt
+	if err := provider.GetReceipt(p.home, p.app.Name, p.app.Stage, updateID, &receipt); err != nil {
+		return nil, err
+	}
+	if receipt.DeployID == "" {
+		return nil, fmt.Errorf("%w: %s", provider.ErrReceiptNotFound, updateID)
+	}
+	return &receipt, nil
+}
+
+func configHash(paths ...string) string {
+	hash := sha256.New()
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// gitInfo is the commit the app was deployed from, nil if it is not in a git
+// repo. CI checkouts are often detached so the branch comes from the env.
+func gitInfo(dir string) *ReceiptGit {
+	git := func(args ...string) (string, error) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	sha, err := git("rev-parse", "HEAD")
+	if err != nil || sha == "" {
+		return nil
+	}
+	result := &ReceiptGit{SHA: sha}
+	for _, key := range []string{"GITHUB_HEAD_REF", "GITHUB_REF_NAME", "CI_COMMIT_REF_NAME"} {
+		if branch := os.Getenv(key); branch != "" {
+			result.Branch = branch
+			break
+		}
+	}
+	if result.Branch == "" {
+		if branch, err := git("rev-parse", "--abbrev-ref", "HEAD"); err == nil && branch != "HEAD" {
+			result.Branch = branch
+		}
+	}
+	if status, err := git("status", "--porcelain"); err == nil && status != "" {
+		result.Dirty = true
+	}
+	return result
+}
+
+func receiptOperator() ReceiptOperator {
+	result := ReceiptOperator{User: provider.LockHolder()}
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		result.CI = "github"
+		result.Actor = os.Getenv("GITHUB_ACTOR")
+		if os.Getenv("GITHUB_RUN_ID") != "" {
+			result.Run = os.Getenv("GITHUB_SERVER_URL") + "/" + os.Getenv("GITHUB_REPOSITORY") + "/actions/runs/" + os.Getenv("GITHUB_RUN_ID")
+		}
+	case os.Getenv("GITLAB_CI") == "true":
+		result.CI = "gitlab"
+		result.Actor = os.Getenv("GITLAB_USER_LOGIN")
+		result.Run = os.Getenv("CI_JOB_URL")
+	case os.Getenv("CI") != "":
+		result.CI = "unknown"
+	}
+	return result
+}
+
+// publicOutputs leaves out the outputs that are secret.
+func publicOutputs(outputs map[string]interface{}) map[string]interface{} {
+	result := map[string]interface{}{}
+	for key, value := range outputs {
+		if strings.HasPrefix(key, "_") {
+			continue
+		}
+		if secret, ok := value.(map[string]interface{}); ok && secret["ciphertext"] != nil {
+			continue
+		}
+		result[key] = value
+	}
+	return result
+}
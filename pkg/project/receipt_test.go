@@ -0,0 +1,95 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+func TestReceiptChanges(t *testing.T) {
+	changes := newReceiptChanges()
+	changes.add(apitype.StepEventMetadata{URN: "urn:a", Type: "aws:s3/bucketV2:BucketV2", Op: apitype.OpCreate}, false)
+	changes.add(apitype.StepEventMetadata{URN: "urn:b", Type: "aws:lambda/function:Function", Op: apitype.OpSame}, false)
+	changes.add(apitype.StepEventMetadata{URN: "urn:c", Type: "aws:lambda/function:Function", Op: apitype.OpUpdate}, false)
+	changes.add(apitype.StepEventMetadata{URN: "urn:c", Type: "aws:lambda/function:Function", Op: apitype.OpUpdate}, true)
+	if len(changes.list) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", changes.list)
+	}
+	if changes.list[0].URN != "urn:a" || changes.list[0].Failed {
+		t.Errorf("Unexpected first change %+v", changes.list[0])
+	}
+	if changes.list[1].URN != "urn:c" || !changes.list[1].Failed {
+		t.Errorf("Expected the failed update to replace the change, got %+v", changes.list[1])
+	}
+}
+
+func TestConfigHash(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "sst.config.ts")
+	override := filepath.Join(dir, "sst.config.production.ts")
+	os.WriteFile(config, []byte("export default $config({})"), 0644)
+	os.WriteFile(override, []byte("export default {}"), 0644)
+	base := configHash(config, "")
+	if len(base) != 64 || base == configHash(config, override) {
+		t.Errorf("Expected the override to change the hash, got %s", base)
+	}
+	if base != configHash(config, "") {
+		t.Error("Expected the same hash for the same config")
+	}
+}
+
+func TestPublicOutputs(t *testing.T) {
+	result := publicOutputs(map[string]interface{}{
+		"url":       "https://example.com",
+		"_internal": "hidden",
+		"password":  map[string]interface{}{"ciphertext": "abc"},
+	})
+	if len(result) != 1 || result["url"] != "https://example.com" {
+		t.Errorf("Expected only the url, got %+v", result)
+	}
+}
+
+func TestPruneReceipts(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"old", "middle", "new"} {
+		path := filepath.Join(dir, name+".json")
+		os.WriteFile(path, []byte("{}"), 0644)
+		os.Chtimes(path, now, now.Add(time.Duration(i)*time.Minute))
+	}
+	if err := pruneReceipts(dir, 2); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 receipts, got %d", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+		t.Error("Expected the oldest receipt to be removed")
+	}
+	if err := pruneReceipts(filepath.Join(dir, "missing"), 2); err != nil {
+		t.Errorf("Expected a missing directory to be fine, got %v", err)
+	}
+}
+
+func TestReadReceipt(t *testing.T) {
+	root := t.TempDir()
+	p := &Project{
+		root:   root,
+		config: filepath.Join(root, "sst.config.ts"),
+		app:    &App{Name: "web", Stage: "dev"},
+		home:   provider.NewLocalHome(),
+	}
+	receipt := &Receipt{DeployID: "123", App: "web", Stage: "dev", Status: ReceiptSuccess, Started: time.Now().UTC()}
+	if err := p.writeReceipt(receipt); err != nil {
+		t.Fatal(err)
+	}
+	read, err := p.ReadReceipt("123")
+	if err != nil || read.DeployID != "123" || read.Status != ReceiptSuccess {
+		t.Errorf("Expected the receipt back, got %+v %v", read, err)
+	}
+}
@@ -0,0 +1,121 @@
+package project
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/auto"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+// rollbackProgram declares every resource of a checkpoint with the inputs it
+// was deployed with. Resources keep their URN since they are registered with
+// the same name, type, and parent, so deploying it converges the stage back
+// to that checkpoint.
+const rollbackProgram = `
+import * as pulumi from "@pulumi/pulumi";
+
+const resources = __RESOURCES__;
+
+const SIG = "4dabf18193072939515e22adb298388d";
+const SECRET = "1b47061264138c4ac30d75fd1eb44270";
+const ASSET = "c44067f5952c0a294b673a41bacd8c17";
+const ARCHIVE = "0def7320c3a5731c473e5ecbe6d01bc7";
+
+function revive(value) {
+  if (Array.isArray(value)) return value.map(revive);
+  if (!value || typeof value !== "object") return value;
+  switch (value[SIG]) {
+    case SECRET:
+      return pulumi.secret(revive("plaintext" in value ? JSON.parse(value.plaintext) : value.value));
+    case ASSET:
+      if (value.text !== undefined) return new pulumi.asset.StringAsset(value.text);
+      if (value.path !== undefined) return new pulumi.asset.FileAsset(value.path);
+      return new pulumi.asset.RemoteAsset(value.uri);
+    case ARCHIVE:
+      if (value.assets !== undefined) return new pulumi.asset.AssetArchive(revive(value.assets));
+      if (value.path !== undefined) return new pulumi.asset.FileArchive(value.path);
+      return new pulumi.asset.RemoteArchive(value.uri);
+  }
+  return Object.fromEntries(Object.entries(value).map(([key, item]) => [key, revive(item)]));
+}
+
+const registered = {};
+const providers = {};
+let outputs = {};
+for (const item of resources) {
+  if (item.delete) continue;
+  if (item.type === "pulumi:pulumi:Stack") {
+    outputs = revive(item.outputs || {});
+    continue;
+  }
+  const name = item.urn.split("::").pop();
+  const opts = {
+    parent: registered[item.parent],
+    dependsOn: (item.dependencies || []).map((urn) => registered[urn]).filter(Boolean),
+    protect: item.protect,
+    retainOnDelete: item.retainOnDelete,
+    deletedWith: registered[item.deletedWith],
+    additionalSecretOutputs: item.additionalSecretOutputs,
+  };
+  if (item.type.startsWith("pulumi:providers:")) {
+    const pkg = item.type.slice("pulumi:providers:".length);
+    const resource = new pulumi.ProviderResource(pkg, name, revive(item.inputs || {}), opts);
+    registered[item.urn] = resource;
+    providers[item.urn + "::" + item.id] = resource;
+    continue;
+  }
+  if (!item.custom) {
+    registered[item.urn] = new pulumi.ComponentResource(item.type, name, {}, opts);
+    continue;
+  }
+  opts.provider = providers[item.provider];
+  if (item.external) {
+    opts.id = item.id;
+    registered[item.urn] = new pulumi.CustomResource(item.type, name, {}, opts);
+    continue;
+  }
+  registered[item.urn] = new pulumi.CustomResource(item.type, name, revive(item.inputs || {}), opts);
+}
+
+export default outputs;
+`
+
+// writeRollback writes the program for checkpoint id to outfile. The
+// checkpoint is swapped in for the current state just long enough to export
+// it with its secrets decrypted.
+func (p *Project) writeRollback(ctx context.Context, stack auto.Stack, id string, outfile string) error {
+	statePath := filepath.Join(p.PathWorkingDir(), ".pulumi", "stacks", p.app.Name, fmt.Sprintf("%v.json", p.app.Stage))
+	current, err := os.ReadFile(statePath)
+	if err != nil {
+		return err
+	}
+	err = provider.PullCheckpoint(p.home, p.app.Name, p.app.Stage, id, statePath)
+	if err != nil {
+		return err
+	}
+	exported, err := stack.Export(ctx)
+	restoreErr := os.WriteFile(statePath, current, 0644)
+	if err != nil {
+		return err
+	}
+	if restoreErr != nil {
+		return restoreErr
+	}
+	var deployment apitype.DeploymentV3
+	err = json.Unmarshal(exported.Deployment, &deployment)
+	if err != nil {
+		return err
+	}
+	resources, err := json.Marshal(deployment.Resources)
+	if err != nil {
+		return err
+	}
+	program := strings.Replace(rollbackProgram, "__RESOURCES__", string(resources), 1)
+	return os.WriteFile(outfile, []byte(program), 0644)
+}
@@ -0,0 +1,198 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/lambda"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+// SecretRotation is how often a secret has to be rotated, and how.
+type SecretRotation struct {
+	// Interval is how long a value is good for, like 30d, 12h, or 2 weeks
+	Interval string `json:"interval"`
+	// Expires is a date the secret has to be rotated by, like when an API
+	// key expires
+	Expires string `json:"expires"`
+	// Command is a shell command that prints the new value
+	Command string `json:"command"`
+	// Function is the name or ARN of a Lambda function that returns the new
+	// value
+	Function string `json:"function"`
+}
+
+var ErrRotationInterval = fmt.Errorf("invalid rotation interval")
+var ErrRotationHook = fmt.Errorf("secret has no rotation command or function")
+
+// parseInterval reads the durations Go understands along with days and weeks.
+func parseInterval(input string) (time.Duration, error) {
+	input = strings.TrimSpace(input)
+	if duration, err := time.ParseDuration(input); err == nil {
+		return duration, nil
+	}
+	number := strings.TrimRight(input, "abcdefghijklmnopqrstuvwxyz ")
+	unit := strings.TrimSpace(input[len(number):])
+	count, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrRotationInterval, input)
+	}
+	var base time.Duration
+	switch strings.TrimSuffix(unit, "s") {
+	case "h", "hour":
+		base = time.Hour
+	case "d", "day":
+		base = 24 * time.Hour
+	case "w", "week":
+		base = 7 * 24 * time.Hour
+	default:
+		return 0, fmt.Errorf("%w: %q, use hours, days, or weeks", ErrRotationInterval, input)
+	}
+	return time.Duration(count * float64(base)), nil
+}
+
+// Deadline returns when a secret last set at changed has to be rotated by. A
+// zero changed time is a secret set before versions were kept, which is due
+// right away if it has an interval.
+func (r SecretRotation) Deadline(changed time.Time) (time.Time, error) {
+	var deadline time.Time
+	if r.Interval != "" {
+		interval, err := parseInterval(r.Interval)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if !changed.IsZero() {
+			deadline = changed.Add(interval)
+		}
+	}
+	if r.Expires != "" {
+		expires, err := time.Parse(time.DateOnly, r.Expires)
+		if err != nil {
+			expires, err = time.Parse(time.RFC3339, r.Expires)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("invalid expiry %q, use YYYY-MM-DD", r.Expires)
+			}
+		}
+		if r.Interval == "" || expires.Before(deadline) {
+			deadline = expires
+		}
+	}
+	return deadline, nil
+}
+
+type RotationStatus struct {
+	Name     string    `json:"name"`
+	Changed  time.Time `json:"changed"`
+	Deadline time.Time `json:"deadline"`
+	Due      bool      `json:"due"`
+}
+
+// RotationStatuses returns the rotation status of every secret that is set
+// and has an interval or an expiry, sorted by name.
+func RotationStatuses(rotation map[string]SecretRotation, secrets map[string]string, history map[string][]provider.SecretVersion, now time.Time) ([]RotationStatus, error) {
+	result := []RotationStatus{}
+	for name, config := range rotation {
+		if _, ok := secrets[name]; !ok || (config.Interval == "" && config.Expires == "") {
+			continue
+		}
+		status := RotationStatus{Name: name}
+		if versions := history[name]; len(versions) > 0 {
+			status.Changed = versions[len(versions)-1].Time
+		}
+		deadline, err := config.Deadline(status.Changed)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		status.Deadline = deadline
+		status.Due = !now.Before(deadline)
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// SecretRotationEvent is published on deploy when secrets are past due.
+type SecretRotationEvent struct {
+	Names []string
+}
+
+// RotateSecret runs the rotation hook of a secret and returns the new value.
+// The hook gets the current value so it can revoke it.
+func (p *Project) RotateSecret(name, current string) (string, error) {
+	var config SecretRotation
+	if p.app.Secrets != nil {
+		config = p.app.Secrets.Rotation[name]
+	}
+	var output []byte
+	switch {
+	case config.Command != "":
+		cmd := exec.Command("sh", "-c", config.Command)
+		cmd.Dir = p.PathRoot()
+		cmd.Env = append(os.Environ(),
+			"SST_APP="+p.app.Name,
+			"SST_STAGE="+p.app.Stage,
+			"SST_SECRET_NAME="+name,
+			"SST_SECRET_VALUE="+current,
+		)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		result, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("rotation command for %s failed: %s", name, strings.TrimSpace(stderr.String()))
+		}
+		output = result
+	case config.Function != "":
+		match, ok := p.Provider("aws")
+		if !ok {
+			return "", fmt.Errorf("rotating %s with a function needs the aws provider", name)
+		}
+		payload, err := json.Marshal(map[string]string{
+			"app":   p.app.Name,
+			"stage": p.app.Stage,
+			"name":  name,
+			"value": current,
+		})
+		if err != nil {
+			return "", err
+		}
+		client := lambda.NewFromConfig(match.(*provider.AwsProvider).Config())
+		result, err := client.Invoke(context.TODO(), &lambda.InvokeInput{
+			FunctionName: aws.String(config.Function),
+			Payload:      payload,
+		})
+		if err != nil {
+			return "", err
+		}
+		if result.FunctionError != nil {
+			return "", fmt.Errorf("rotation function for %s failed: %s", name, string(result.Payload))
+		}
+		// the function can return the value as a string or as { value }
+		var parsed struct {
+			Value string `json:"value"`
+		}
+		var value string
+		if json.Unmarshal(result.Payload, &value) == nil {
+			output = []byte(value)
+		} else if json.Unmarshal(result.Payload, &parsed) == nil {
+			output = []byte(parsed.Value)
+		}
+	default:
+		return "", fmt.Errorf("%w: %s", ErrRotationHook, name)
+	}
+	value := strings.TrimRight(string(output), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("the rotation hook for %s did not return a value", name)
+	}
+	return value, nil
+}
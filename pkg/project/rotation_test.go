@@ -0,0 +1,65 @@
+package project
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+func TestParseInterval(t *testing.T) {
+	tests := map[string]time.Duration{
+		"12h":     12 * time.Hour,
+		"30d":     30 * 24 * time.Hour,
+		"30 days": 30 * 24 * time.Hour,
+		"2 weeks": 14 * 24 * time.Hour,
+		"1 hour":  time.Hour,
+	}
+	for input, want := range tests {
+		got, err := parseInterval(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("parseInterval(%q) = %v, want %v", input, got, want)
+		}
+	}
+	if _, err := parseInterval("monthly"); err == nil {
+		t.Error("expected an error for an unknown unit")
+	}
+}
+
+func TestRotationStatuses(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	rotation := map[string]SecretRotation{
+		"Fresh":   {Interval: "30d"},
+		"Stale":   {Interval: "30d"},
+		"Unknown": {Interval: "30d"},
+		"Expires": {Interval: "90d", Expires: "2024-05-15"},
+		"Manual":  {Command: "echo new"},
+		"Unset":   {Interval: "30d"},
+	}
+	secrets := map[string]string{"Fresh": "", "Stale": "", "Unknown": "", "Expires": "", "Manual": ""}
+	history := map[string][]provider.SecretVersion{
+		"Fresh":   {{Version: 1, Time: now.Add(-24 * time.Hour)}},
+		"Stale":   {{Version: 1, Time: now.Add(-31 * 24 * time.Hour)}},
+		"Expires": {{Version: 1, Time: now.Add(-24 * time.Hour)}},
+	}
+	statuses, err := RotationStatuses(rotation, secrets, history, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	due := map[string]bool{}
+	for _, status := range statuses {
+		due[status.Name] = status.Due
+	}
+	want := map[string]bool{"Expires": true, "Fresh": false, "Stale": true, "Unknown": true}
+	if len(due) != len(want) {
+		t.Fatalf("expected %d statuses, got %+v", len(want), statuses)
+	}
+	for name, expected := range want {
+		if due[name] != expected {
+			t.Errorf("%s due = %v, want %v", name, due[name], expected)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/internal/util"
+)
+
+// The config is checked against the App struct before it is used, so a typo
+// fails right away instead of somewhere in the middle of a deploy. The
+// properties are the json tags of the struct, configValues has the ones that
+// only take some values.
+
+// ConfigIssue is a problem with a property of the app config. Line and
+// Column point at it in sst.config.ts and are zero if it was not found there.
+type ConfigIssue struct {
+	Path    string
+	Message string
+	Line    int
+	Column  int
+}
+
+// configValues are the allowed values of properties by path, * matches any
+// key of a map.
+var configValues = map[string][]string{
+	"removal":                  {"remove", "retain", "retain-all"},
+	"home":                     {"aws", "cloudflare", "gcp", "azure", "local"},
+	"state.backend":            {"s3", "r2", "gcs", "local"},
+	"secrets.backend":          {"sst", "ssm", "secretsmanager", "vault"},
+	"secrets.prefixes.*":       {"sst", "ssm", "secretsmanager", "vault"},
+	"secrets.vault.auth":       {"token", "approle", "oidc"},
+	"dev.ui.restart.*":         {"always", "on-failure", "never"},
+	"dev.ui.restart.*.policy":  {"always", "on-failure", "never"},
+	"notifications.*.format":   {"json", "slack", "discord"},
+	"notifications.*.events.*": {NotificationStart, NotificationSuccess, NotificationFailure},
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// validateConfig checks the evaluated config against the App struct, the
+// issues are located in source.
+func validateConfig(data []byte, source string) []ConfigIssue {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return []ConfigIssue{{Message: err.Error()}}
+	}
+	issues := []ConfigIssue{}
+	checkValue(reflect.TypeOf(App{}), raw, nil, "", &issues)
+	for i := range issues {
+		issues[i].Line, issues[i].Column = locateProperty(source, strings.Split(issues[i].Path, "."))
+	}
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Line != issues[j].Line {
+			return issues[i].Line < issues[j].Line
+		}
+		return issues[i].Column < issues[j].Column
+	})
+	return issues
+}
+
+func checkValue(t reflect.Type, value interface{}, path []string, pattern string, issues *[]ConfigIssue) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if value == nil || t.Kind() == reflect.Interface {
+		return
+	}
+	fail := func(format string, args ...interface{}) {
+		*issues = append(*issues, ConfigIssue{
+			Path:    strings.Join(path, "."),
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+	// the types with their own unmarshaler also take a string or a boolean
+	// in place of the whole value, like a key list or a restart policy
+	if t.Kind() != reflect.String && reflect.PointerTo(t).Implements(unmarshalerType) {
+		switch scalar := value.(type) {
+		case string:
+			checkAllowed(scalar, path, pattern, fail)
+			return
+		case bool:
+			return
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		str, ok := value.(string)
+		if !ok {
+			fail("%s must be a string, not %s", displayPath(path), describe(value))
+			return
+		}
+		checkAllowed(str, path, pattern, fail)
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			fail("%s must be true or false, not %s", displayPath(path), describe(value))
+		}
+	case reflect.Int, reflect.Int64, reflect.Int32:
+		number, ok := value.(float64)
+		if !ok {
+			fail("%s must be a whole number, not %s", displayPath(path), describe(value))
+		} else if number != math.Trunc(number) {
+			fail("%s must be a whole number, not %v", displayPath(path), number)
+		}
+	case reflect.Float64:
+		if _, ok := value.(float64); !ok {
+			fail("%s must be a number, not %s", displayPath(path), describe(value))
+		}
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			fail("%s must be a list, not %s", displayPath(path), describe(value))
+			return
+		}
+		for i, item := range list {
+			checkValue(t.Elem(), item, append(path, fmt.Sprint(i)), join(pattern, "*"), issues)
+		}
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("%s must be an object, not %s", displayPath(path), describe(value))
+			return
+		}
+		for _, key := range sortedKeys(object) {
+			checkValue(t.Elem(), object[key], append(path, key), join(pattern, "*"), issues)
+		}
+	case reflect.Struct:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			fail("%s must be an object, not %s", displayPath(path), describe(value))
+			return
+		}
+		fields := map[string]reflect.StructField{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			if name != "" && name != "-" {
+				fields[name] = field
+			}
+		}
+		for _, key := range sortedKeys(object) {
+			field, ok := fields[key]
+			if !ok {
+				message := fmt.Sprintf("%q is not a property of %s", key, displayPath(path))
+				if suggestion := suggest(key, fields); suggestion != "" {
+					message += fmt.Sprintf(", did you mean %q?", suggestion)
+				}
+				*issues = append(*issues, ConfigIssue{
+					Path:    strings.Join(append(path, key), "."),
+					Message: message,
+				})
+				continue
+			}
+			checkValue(field.Type, object[key], append(path, key), join(pattern, key), issues)
+		}
+	}
+}
+
+func checkAllowed(value string, path []string, pattern string, fail func(string, ...interface{})) {
+	allowed, ok := configValues[pattern]
+	if !ok {
+		return
+	}
+	for _, item := range allowed {
+		if item == value {
+			return
+		}
+	}
+	fail("%s must be one of %s, not %q", displayPath(path), strings.Join(allowed, ", "), value)
+}
+
+func join(pattern, key string) string {
+	if pattern == "" {
+		return key
+	}
+	return pattern + "." + key
+}
+
+func displayPath(path []string) string {
+	return strings.Join(append([]string{"app"}, path...), ".")
+}
+
+func describe(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case float64:
+		return "a number"
+	case bool:
+		return "a boolean"
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+	return fmt.Sprintf("%v", value)
+}
+
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// suggest returns the property closest to key, if one is close enough to be
+// a typo of it.
+func suggest(key string, fields map[string]reflect.StructField) string {
+	best := ""
+	bestDistance := 3
+	for name := range fields {
+		distance := levenshtein(strings.ToLower(key), strings.ToLower(name))
+		if distance < bestDistance || (distance == bestDistance && best != "" && name < best) {
+			best = name
+			bestDistance = distance
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// locateProperty finds the property at path in the source of the config. It
+// looks for each key after the one before it, starting at the app function,
+// so it is a best guess for configs that build the object in other places.
+// Indexes of lists are skipped.
+func locateProperty(source string, path []string) (int, int) {
+	start := strings.Index(source, "app(")
+	if start == -1 {
+		start = 0
+	}
+	found := -1
+	for _, key := range path {
+		if key == "" || strings.Trim(key, "0123456789") == "" {
+			continue
+		}
+		quoted := regexp.QuoteMeta(key)
+		pattern := regexp.MustCompile(`(?:^|[^\w$])("` + quoted + `"|'` + quoted + `'|` + quoted + `)\s*:`)
+		match := pattern.FindStringSubmatchIndex(source[start:])
+		if match == nil {
+			break
+		}
+		found = start + match[2]
+		start = start + match[1]
+	}
+	if found == -1 {
+		return 0, 0
+	}
+	line := strings.Count(source[:found], "\n") + 1
+	column := found - strings.LastIndex(source[:found], "\n")
+	return line, column
+}
+
+// configError is the readable error for the issues with the config at file.
+func configError(file string, issues []ConfigIssue) error {
+	lines := []string{"There is a problem with your config:"}
+	if len(issues) > 1 {
+		lines[0] = fmt.Sprintf("There are %d problems with your config:", len(issues))
+	}
+	for _, issue := range issues {
+		location := file
+		if issue.Line > 0 {
+			location = fmt.Sprintf("%s:%d:%d", file, issue.Line, issue.Column)
+		}
+		lines = append(lines, "  "+location+": "+issue.Message)
+	}
+	return util.NewReadableError(nil, strings.Join(lines, "\n"))
+}
@@ -0,0 +1,65 @@
+package project
+
+import (
+	"strings"
+	"testing"
+)
+
+const schemaSource = `/// <reference path="./.sst/platform/config.d.ts" />
+export default $config({
+  app(input) {
+    return {
+      name: "myapp",
+      removel: "retain",
+      home: "aws",
+      watch: {
+        ignore: "dist",
+      },
+      dev: {
+        ui: {
+          keys: { quit: "q", up: ["k", "up"] },
+          restart: {
+            web: "sometimes",
+            api: { policy: "on-failure", retries: 1.5 },
+          },
+        },
+      },
+    };
+  },
+});
+`
+
+func TestValidateConfig(t *testing.T) {
+	data := `{"name":"myapp","removel":"retain","home":"aws","watch":{"ignore":"dist"},` +
+		`"dev":{"ui":{"keys":{"quit":"q","up":["k","up"]},"restart":{"web":"sometimes","api":{"policy":"on-failure","retries":1.5}}}}}`
+	issues := validateConfig([]byte(data), schemaSource)
+	want := []ConfigIssue{
+		{Path: "removel", Message: `"removel" is not a property of app, did you mean "removal"?`, Line: 6, Column: 7},
+		{Path: "watch.ignore", Message: "app.watch.ignore must be a list, not a string", Line: 9, Column: 9},
+		{Path: "dev.ui.restart.web", Message: `app.dev.ui.restart.web must be one of always, on-failure, never, not "sometimes"`, Line: 15, Column: 13},
+		{Path: "dev.ui.restart.api.retries", Message: "app.dev.ui.restart.api.retries must be a whole number, not 1.5", Line: 16, Column: 42},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("Expected %d issues, got %+v", len(want), issues)
+	}
+	for i := range want {
+		if issues[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], issues[i])
+		}
+	}
+
+	if issues := validateConfig([]byte(`{"name":"myapp","home":"local","providers":{"aws":{"region":"us-east-1"},"cloudflare":true}}`), ""); len(issues) != 0 {
+		t.Errorf("Expected no issues, got %+v", issues)
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	err := configError("sst.config.ts", []ConfigIssue{
+		{Message: `"removel" is not a property of app`, Line: 6, Column: 7},
+		{Message: "app.home must be a string, not a number"},
+	})
+	message := err.Error()
+	if !strings.Contains(message, "2 problems") || !strings.Contains(message, "sst.config.ts:6:7: ") || !strings.Contains(message, "  sst.config.ts: app.home") {
+		t.Errorf("Unexpected error %s", message)
+	}
+}
@@ -0,0 +1,377 @@
+package project
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+// SecretStore holds the secrets of an app. A stage of "" is the fallback
+// secrets that every stage falls back to.
+type SecretStore interface {
+	GetSecrets(app, stage string) (map[string]string, error)
+	// PutSecrets replaces the secrets of a stage, the ones that are not in
+	// secrets are removed
+	PutSecrets(app, stage string, secrets map[string]string) error
+}
+
+// SecretsConfig is the secrets option in sst.config.ts.
+type SecretsConfig struct {
+	// Backend is where secrets are stored, one of sst, ssm, secretsmanager,
+	// or vault
+	Backend string `json:"backend"`
+	// Prefixes picks a different backend for the secrets that start with a
+	// prefix, the longest prefix wins
+	Prefixes map[string]string `json:"prefixes"`
+	Vault    *VaultConfig      `json:"vault"`
+	// Rotation is how the secrets that have to be rotated are rotated
+	Rotation map[string]SecretRotation `json:"rotation"`
+}
+
+var ErrSecretBackend = fmt.Errorf("invalid secret backend")
+
+// fallbackStage is what the fallback secrets are stored under.
+const fallbackStage = "_fallback"
+
+func secretStage(stage string) string {
+	if stage == "" {
+		return fallbackStage
+	}
+	return stage
+}
+
+// homeSecretStore keeps secrets encrypted in the home, it is the default.
+type homeSecretStore struct {
+	home provider.Home
+}
+
+func (s *homeSecretStore) GetSecrets(app, stage string) (map[string]string, error) {
+	secrets, err := provider.GetSecrets(s.home, app, stage)
+	if err != nil {
+		// secrets that cannot be read were encrypted with another passphrase
+		return nil, fmt.Errorf("%w: %w", ErrPassphraseInvalid, err)
+	}
+	return secrets, nil
+}
+
+func (s *homeSecretStore) PutSecrets(app, stage string, secrets map[string]string) error {
+	return provider.PutSecrets(s.home, app, stage, secrets)
+}
+
+// nativeVersionStore is a store that versions secrets itself, like ssm. The
+// history of a secret kept in one only records the version, the value never
+// leaves the store.
+type nativeVersionStore interface {
+	// SecretVersions returns the current version of the secrets in names,
+	// the ones the store has no version for are left out
+	SecretVersions(app, stage string, names []string) (map[string]string, error)
+	// SecretValue returns the value a secret had at a version
+	SecretValue(app, stage, name, version string) (string, error)
+}
+
+var ErrSecretVersion = fmt.Errorf("the value of this version is not kept")
+
+// versionedSecretStore records every change to a secret in the history of
+// the stage, whatever store the secret is kept in.
+type versionedSecretStore struct {
+	SecretStore
+	home provider.Home
+}
+
+// storeOf returns the store a secret is kept in.
+func (s *versionedSecretStore) storeOf(name string) SecretStore {
+	if router, ok := s.SecretStore.(*secretRouter); ok {
+		return router.route(name)
+	}
+	return s.SecretStore
+}
+
+func (s *versionedSecretStore) native(name string) bool {
+	_, ok := s.storeOf(name).(nativeVersionStore)
+	return ok
+}
+
+// nativeVersions returns the versions the stores keep of the secrets in
+// names.
+func (s *versionedSecretStore) nativeVersions(app, stage string, names []string) (map[string]string, error) {
+	byStore := map[SecretStore][]string{}
+	for _, name := range names {
+		store := s.storeOf(name)
+		if _, ok := store.(nativeVersionStore); ok {
+			byStore[store] = append(byStore[store], name)
+		}
+	}
+	result := map[string]string{}
+	for store, names := range byStore {
+		versions, err := store.(nativeVersionStore).SecretVersions(app, stage, names)
+		if err != nil {
+			return nil, err
+		}
+		for name, version := range versions {
+			result[name] = version
+		}
+	}
+	return result, nil
+}
+
+func (s *versionedSecretStore) PutSecrets(app, stage string, secrets map[string]string) error {
+	before, err := s.SecretStore.GetSecrets(app, stage)
+	if err != nil {
+		return err
+	}
+	changed := changedSecrets(before, secrets)
+	snapshot := nativeSnapshot{native: s.native}
+	snapshot.before, err = s.nativeVersions(app, stage, changed)
+	if err != nil {
+		return err
+	}
+	err = s.SecretStore.PutSecrets(app, stage, secrets)
+	if err != nil {
+		return err
+	}
+	snapshot.after, err = s.nativeVersions(app, stage, changed)
+	if err != nil {
+		return err
+	}
+	history, err := provider.GetSecretHistory(s.home, app, stage)
+	if err != nil {
+		return err
+	}
+	scrubbed := false
+	for name, versions := range history {
+		if !s.native(name) {
+			continue
+		}
+		// recorded before only the versions of the store were kept
+		for i := range versions {
+			if versions[i].Value != "" {
+				versions[i].Value = ""
+				scrubbed = true
+			}
+		}
+	}
+	if !recordSecretVersions(history, before, secrets, snapshot, provider.LockHolder(), time.Now().UTC()) && !scrubbed {
+		return nil
+	}
+	return provider.PutSecretHistory(s.home, app, stage, history)
+}
+
+// SecretVersionValue returns the value a secret had at a version of its
+// history, it is read from the store of the secret if that versions it.
+func SecretVersionValue(store SecretStore, app, stage, name string, version provider.SecretVersion) (string, error) {
+	versioned, ok := store.(*versionedSecretStore)
+	if !ok || !versioned.native(name) {
+		return version.Value, nil
+	}
+	if version.Native == "" {
+		return "", ErrSecretVersion
+	}
+	return versioned.storeOf(name).(nativeVersionStore).SecretValue(app, stage, name, version.Native)
+}
+
+// nativeSnapshot has the versions of the secrets kept in a store that
+// versions them itself, from before and after a change. Only those versions
+// are recorded for them and never their values.
+type nativeSnapshot struct {
+	native        func(name string) bool
+	before, after map[string]string
+}
+
+func (n nativeSnapshot) version(name, value string, versions map[string]string) provider.SecretVersion {
+	if n.native != nil && n.native(name) {
+		return provider.SecretVersion{Native: versions[name]}
+	}
+	return provider.SecretVersion{Value: value}
+}
+
+// changedSecrets returns the names of the secrets that were added, changed,
+// or removed between before and after, sorted.
+func changedSecrets(before, after map[string]string) []string {
+	result := []string{}
+	for name, value := range after {
+		if current, ok := before[name]; !ok || current != value {
+			result = append(result, name)
+		}
+	}
+	for name := range before {
+		if _, ok := after[name]; !ok {
+			result = append(result, name)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// recordSecretVersions adds a version for every secret that changed between
+// before and after. A secret that was set before versions were kept gets its
+// old value as the first version. It returns false if nothing changed.
+func recordSecretVersions(history map[string][]provider.SecretVersion, before, after map[string]string, snapshot nativeSnapshot, holder string, now time.Time) bool {
+	changed := changedSecrets(before, after)
+	for _, name := range changed {
+		if value, ok := before[name]; ok && len(history[name]) == 0 {
+			first := snapshot.version(name, value, snapshot.before)
+			first.Version = 1
+			history[name] = append(history[name], first)
+		}
+		version := provider.SecretVersion{Removed: true}
+		if value, ok := after[name]; ok {
+			version = snapshot.version(name, value, snapshot.after)
+		}
+		version.Version = len(history[name]) + 1
+		version.Time = now
+		version.Holder = holder
+		history[name] = append(history[name], version)
+	}
+	return len(changed) > 0
+}
+
+// LatestSecretVersions returns the current version of every secret in history
+// that has not been removed.
+func LatestSecretVersions(history map[string][]provider.SecretVersion) map[string]int {
+	result := map[string]int{}
+	for name, versions := range history {
+		if len(versions) == 0 || versions[len(versions)-1].Removed {
+			continue
+		}
+		result[name] = versions[len(versions)-1].Version
+	}
+	return result
+}
+
+type secretRoute struct {
+	prefix string
+	store  SecretStore
+}
+
+// secretRouter sends every secret to the store of its longest matching
+// prefix, or to the default store.
+type secretRouter struct {
+	fallback SecretStore
+	routes   []secretRoute
+}
+
+func (r *secretRouter) route(name string) SecretStore {
+	for _, route := range r.routes {
+		if strings.HasPrefix(name, route.prefix) {
+			return route.store
+		}
+	}
+	return r.fallback
+}
+
+func (r *secretRouter) stores() []SecretStore {
+	result := []SecretStore{r.fallback}
+	for _, route := range r.routes {
+		if !containsStore(result, route.store) {
+			result = append(result, route.store)
+		}
+	}
+	return result
+}
+
+func containsStore(stores []SecretStore, store SecretStore) bool {
+	for _, item := range stores {
+		if item == store {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *secretRouter) GetSecrets(app, stage string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, store := range r.stores() {
+		secrets, err := store.GetSecrets(app, stage)
+		if err != nil {
+			return nil, err
+		}
+		// a secret only counts in the store it is routed to, so one left behind
+		// after the routes change is not read
+		for name, value := range secrets {
+			if r.route(name) == store {
+				result[name] = value
+			}
+		}
+	}
+	return result, nil
+}
+
+func (r *secretRouter) PutSecrets(app, stage string, secrets map[string]string) error {
+	for _, store := range r.stores() {
+		subset := map[string]string{}
+		for name, value := range secrets {
+			if r.route(name) == store {
+				subset[name] = value
+			}
+		}
+		err := store.PutSecrets(app, stage, subset)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewSecretStore returns the store for a secrets config. A nil config keeps
+// secrets in the home. The history of every secret is kept in the home either
+// way, without the values of the secrets kept in the other backends.
+func NewSecretStore(config *SecretsConfig, home provider.Home, awsProvider *provider.AwsProvider) (SecretStore, error) {
+	stores := map[string]SecretStore{}
+	load := func(backend string) (SecretStore, error) {
+		if backend == "" {
+			backend = "sst"
+		}
+		if store, ok := stores[backend]; ok {
+			return store, nil
+		}
+		var store SecretStore
+		switch backend {
+		case "sst":
+			store = &homeSecretStore{home: home}
+		case "ssm", "secretsmanager":
+			if awsProvider == nil {
+				return nil, fmt.Errorf("%w: the %s backend needs the aws provider", ErrSecretBackend, backend)
+			}
+			if backend == "ssm" {
+				store = newSSMSecretStore(awsProvider.Config())
+			} else {
+				store = newSecretsManagerStore(awsProvider.Config())
+			}
+		case "vault":
+			vault, err := newVaultSecretStore(config.Vault)
+			if err != nil {
+				return nil, err
+			}
+			store = vault
+		default:
+			return nil, fmt.Errorf("%w: unknown backend %q, use sst, ssm, secretsmanager, or vault", ErrSecretBackend, backend)
+		}
+		stores[backend] = store
+		return store, nil
+	}
+	if config == nil {
+		config = &SecretsConfig{}
+	}
+	fallback, err := load(config.Backend)
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Prefixes) == 0 {
+		return &versionedSecretStore{SecretStore: fallback, home: home}, nil
+	}
+	router := &secretRouter{fallback: fallback}
+	for prefix, backend := range config.Prefixes {
+		store, err := load(backend)
+		if err != nil {
+			return nil, err
+		}
+		router.routes = append(router.routes, secretRoute{prefix: prefix, store: store})
+	}
+	sort.Slice(router.routes, func(i, j int) bool {
+		return len(router.routes[i].prefix) > len(router.routes[j].prefix)
+	})
+	return &versionedSecretStore{SecretStore: router, home: home}, nil
+}
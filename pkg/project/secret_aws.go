@@ -0,0 +1,253 @@
+package project
+
+import (
+	"context"
+	"errors"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+)
+
+// ssmSecretStore keeps every secret in a SecureString parameter named
+// /sst/<app>/<stage>/<name>.
+type ssmSecretStore struct {
+	client *ssm.Client
+}
+
+func newSSMSecretStore(config aws.Config) *ssmSecretStore {
+	return &ssmSecretStore{client: ssm.NewFromConfig(config)}
+}
+
+func (s *ssmSecretStore) root(app, stage string) string {
+	return path.Join("/sst", app, secretStage(stage)) + "/"
+}
+
+func (s *ssmSecretStore) GetSecrets(app, stage string) (map[string]string, error) {
+	root := s.root(app, stage)
+	paginator := ssm.NewGetParametersByPathPaginator(s.client, &ssm.GetParametersByPathInput{
+		Path:           aws.String(root),
+		WithDecryption: aws.Bool(true),
+	})
+	result := map[string]string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		for _, param := range page.Parameters {
+			result[strings.TrimPrefix(aws.ToString(param.Name), root)] = aws.ToString(param.Value)
+		}
+	}
+	return result, nil
+}
+
+func (s *ssmSecretStore) PutSecrets(app, stage string, secrets map[string]string) error {
+	existing, err := s.GetSecrets(app, stage)
+	if err != nil {
+		return err
+	}
+	root := s.root(app, stage)
+	for name, value := range secrets {
+		if current, ok := existing[name]; ok && current == value {
+			continue
+		}
+		_, err := s.client.PutParameter(context.TODO(), &ssm.PutParameterInput{
+			Name:      aws.String(root + name),
+			Value:     aws.String(value),
+			Type:      ssmtypes.ParameterTypeSecureString,
+			Overwrite: aws.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	removed := []string{}
+	for name := range existing {
+		if _, ok := secrets[name]; !ok {
+			removed = append(removed, root+name)
+		}
+	}
+	// parameters can only be deleted 10 at a time
+	for len(removed) > 0 {
+		batch := removed[:min(len(removed), 10)]
+		removed = removed[len(batch):]
+		_, err := s.client.DeleteParameters(context.TODO(), &ssm.DeleteParametersInput{
+			Names: batch,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecretVersions returns the version of the parameter of each secret.
+func (s *ssmSecretStore) SecretVersions(app, stage string, names []string) (map[string]string, error) {
+	root := s.root(app, stage)
+	result := map[string]string{}
+	// parameters can only be read 10 at a time
+	for len(names) > 0 {
+		batch := names[:min(len(names), 10)]
+		names = names[len(batch):]
+		full := make([]string, len(batch))
+		for i, name := range batch {
+			full[i] = root + name
+		}
+		page, err := s.client.GetParameters(context.TODO(), &ssm.GetParametersInput{
+			Names: full,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, param := range page.Parameters {
+			result[strings.TrimPrefix(aws.ToString(param.Name), root)] = strconv.FormatInt(param.Version, 10)
+		}
+	}
+	return result, nil
+}
+
+// SecretValue reads a version of a parameter. Deleting a parameter deletes
+// its versions, so the versions of a removed secret are gone.
+func (s *ssmSecretStore) SecretValue(app, stage, name, version string) (string, error) {
+	out, err := s.client.GetParameter(context.TODO(), &ssm.GetParameterInput{
+		Name:           aws.String(s.root(app, stage) + name + ":" + version),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.Parameter.Value), nil
+}
+
+// secretsManagerStore keeps every secret in a secret named
+// sst/<app>/<stage>/<name>.
+type secretsManagerStore struct {
+	client *secretsmanager.Client
+}
+
+func newSecretsManagerStore(config aws.Config) *secretsManagerStore {
+	return &secretsManagerStore{client: secretsmanager.NewFromConfig(config)}
+}
+
+func (s *secretsManagerStore) root(app, stage string) string {
+	return path.Join("sst", app, secretStage(stage)) + "/"
+}
+
+func (s *secretsManagerStore) GetSecrets(app, stage string) (map[string]string, error) {
+	root := s.root(app, stage)
+	paginator := secretsmanager.NewBatchGetSecretValuePaginator(s.client, &secretsmanager.BatchGetSecretValueInput{
+		Filters: []smtypes.Filter{
+			{Key: smtypes.FilterNameStringTypeName, Values: []string{root}},
+		},
+	})
+	result := map[string]string{}
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page.Errors {
+			return nil, errors.New(aws.ToString(item.SecretId) + ": " + aws.ToString(item.Message))
+		}
+		for _, value := range page.SecretValues {
+			// the filter also matches names that only contain the root
+			name := aws.ToString(value.Name)
+			if !strings.HasPrefix(name, root) {
+				continue
+			}
+			result[strings.TrimPrefix(name, root)] = aws.ToString(value.SecretString)
+		}
+	}
+	return result, nil
+}
+
+func (s *secretsManagerStore) PutSecrets(app, stage string, secrets map[string]string) error {
+	existing, err := s.GetSecrets(app, stage)
+	if err != nil {
+		return err
+	}
+	root := s.root(app, stage)
+	for name, value := range secrets {
+		current, ok := existing[name]
+		if ok && current == value {
+			continue
+		}
+		if ok {
+			_, err = s.client.PutSecretValue(context.TODO(), &secretsmanager.PutSecretValueInput{
+				SecretId:     aws.String(root + name),
+				SecretString: aws.String(value),
+			})
+		} else {
+			_, err = s.client.CreateSecret(context.TODO(), &secretsmanager.CreateSecretInput{
+				Name:         aws.String(root + name),
+				SecretString: aws.String(value),
+				Tags: []smtypes.Tag{
+					{Key: aws.String("sst:app"), Value: aws.String(app)},
+					{Key: aws.String("sst:stage"), Value: aws.String(secretStage(stage))},
+				},
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for name := range existing {
+		if _, ok := secrets[name]; ok {
+			continue
+		}
+		// without a recovery window so the secret can be set again right away
+		_, err := s.client.DeleteSecret(context.TODO(), &secretsmanager.DeleteSecretInput{
+			SecretId:                   aws.String(root + name),
+			ForceDeleteWithoutRecovery: aws.Bool(true),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SecretVersions returns the id of the AWSCURRENT version of each secret.
+func (s *secretsManagerStore) SecretVersions(app, stage string, names []string) (map[string]string, error) {
+	root := s.root(app, stage)
+	result := map[string]string{}
+	for _, name := range names {
+		out, err := s.client.DescribeSecret(context.TODO(), &secretsmanager.DescribeSecretInput{
+			SecretId: aws.String(root + name),
+		})
+		var notFound *smtypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for id, stages := range out.VersionIdsToStages {
+			if slices.Contains(stages, "AWSCURRENT") {
+				result[name] = id
+			}
+		}
+	}
+	return result, nil
+}
+
+// SecretValue reads a version of a secret. Secrets are deleted without a
+// recovery window, so the versions of a removed secret are gone.
+func (s *secretsManagerStore) SecretValue(app, stage, name, version string) (string, error) {
+	out, err := s.client.GetSecretValue(context.TODO(), &secretsmanager.GetSecretValueInput{
+		SecretId:  aws.String(s.root(app, stage) + name),
+		VersionId: aws.String(version),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.SecretString), nil
+}
@@ -0,0 +1,118 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+type memorySecretStore struct {
+	stages map[string]map[string]string
+}
+
+func (m *memorySecretStore) GetSecrets(app, stage string) (map[string]string, error) {
+	result := map[string]string{}
+	for name, value := range m.stages[stage] {
+		result[name] = value
+	}
+	return result, nil
+}
+
+func (m *memorySecretStore) PutSecrets(app, stage string, secrets map[string]string) error {
+	m.stages[stage] = secrets
+	return nil
+}
+
+func TestSecretRouter(t *testing.T) {
+	fallback := &memorySecretStore{stages: map[string]map[string]string{}}
+	stripe := &memorySecretStore{stages: map[string]map[string]string{}}
+	stripeLive := &memorySecretStore{stages: map[string]map[string]string{}}
+	router := &secretRouter{
+		fallback: fallback,
+		routes: []secretRoute{
+			{prefix: "StripeLive", store: stripeLive},
+			{prefix: "Stripe", store: stripe},
+		},
+	}
+	err := router.PutSecrets("app", "dev", map[string]string{
+		"Database":      "db",
+		"StripeKey":     "test",
+		"StripeLiveKey": "live",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(fallback.stages["dev"], map[string]string{"Database": "db"}) {
+		t.Errorf("unexpected fallback secrets %v", fallback.stages["dev"])
+	}
+	if !reflect.DeepEqual(stripeLive.stages["dev"], map[string]string{"StripeLiveKey": "live"}) {
+		t.Errorf("unexpected StripeLive secrets %v", stripeLive.stages["dev"])
+	}
+
+	// left behind in a store it is no longer routed to
+	fallback.stages["dev"]["StripeOld"] = "old"
+	secrets, err := router.GetSecrets("app", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"Database": "db", "StripeKey": "test", "StripeLiveKey": "live"}
+	if !reflect.DeepEqual(secrets, want) {
+		t.Errorf("GetSecrets() = %v, want %v", secrets, want)
+	}
+}
+
+func TestRecordSecretVersions(t *testing.T) {
+	history := map[string][]provider.SecretVersion{}
+	now := time.Now()
+	before := map[string]string{"Old": "a", "Same": "s"}
+	after := map[string]string{"Old": "b", "Same": "s", "New": "n"}
+	if !recordSecretVersions(history, before, after, nativeSnapshot{}, "me@host", now) {
+		t.Fatal("expected changes to be recorded")
+	}
+	// set before versions were kept, so the old value is the first version
+	if len(history["Old"]) != 2 || history["Old"][0].Value != "a" || history["Old"][1].Version != 2 {
+		t.Errorf("unexpected history for Old %+v", history["Old"])
+	}
+	if len(history["New"]) != 1 || history["New"][0].Holder != "me@host" {
+		t.Errorf("unexpected history for New %+v", history["New"])
+	}
+	if _, ok := history["Same"]; ok {
+		t.Errorf("expected no history for a secret that did not change")
+	}
+
+	recordSecretVersions(history, after, map[string]string{"Same": "s"}, nativeSnapshot{}, "me@host", now)
+	latest := LatestSecretVersions(history)
+	want := map[string]int{}
+	if !reflect.DeepEqual(latest, want) {
+		t.Errorf("LatestSecretVersions() = %v, want %v", latest, want)
+	}
+	if !history["New"][1].Removed {
+		t.Errorf("expected removing New to be recorded")
+	}
+	if recordSecretVersions(history, map[string]string{"Same": "s"}, map[string]string{"Same": "s"}, nativeSnapshot{}, "me@host", now) {
+		t.Errorf("expected nothing to be recorded")
+	}
+}
+
+func TestRecordNativeSecretVersions(t *testing.T) {
+	history := map[string][]provider.SecretVersion{}
+	snapshot := nativeSnapshot{
+		native: func(name string) bool { return name == "Ssm" },
+		before: map[string]string{"Ssm": "3"},
+		after:  map[string]string{"Ssm": "4"},
+	}
+	before := map[string]string{"Ssm": "a", "Home": "b"}
+	after := map[string]string{"Ssm": "c", "Home": "d"}
+	recordSecretVersions(history, before, after, snapshot, "me@host", time.Now())
+	want := []provider.SecretVersion{{Version: 1, Native: "3"}, {Version: 2, Native: "4"}}
+	for i, version := range history["Ssm"] {
+		if version.Value != "" || version.Native != want[i].Native || version.Version != want[i].Version {
+			t.Errorf("unexpected version of Ssm %+v", version)
+		}
+	}
+	if history["Home"][1].Value != "d" {
+		t.Errorf("expected the value of Home to be recorded, got %+v", history["Home"])
+	}
+}
@@ -0,0 +1,455 @@
+package project
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/network"
+)
+
+// VaultConfig is the vault option of secrets in sst.config.ts.
+type VaultConfig struct {
+	// Address defaults to VAULT_ADDR
+	Address string `json:"address"`
+	// Namespace defaults to VAULT_NAMESPACE, it is only used by HCP and
+	// Vault Enterprise
+	Namespace string `json:"namespace"`
+	// Mount is the path of the KV secrets engine
+	Mount string `json:"mount"`
+	// Version is the version of the KV secrets engine, 1 or 2
+	Version int `json:"version"`
+	// Path is where the secrets of a stage are stored in the mount, {app}
+	// and {stage} are replaced
+	Path string `json:"path"`
+	// Auth is one of token, approle, or oidc
+	Auth string `json:"auth"`
+	// AuthMount is the path the auth method is mounted at, it defaults to
+	// the name of the method
+	AuthMount string `json:"authMount"`
+	// Role is the role to log in with oidc, or the role id for approle which
+	// defaults to VAULT_ROLE_ID
+	Role string `json:"role"`
+	// CacheTTL is how many seconds secrets that were read are reused for
+	CacheTTL int `json:"cacheTtl"`
+}
+
+var ErrVaultAuth = fmt.Errorf("could not log in to vault")
+
+// vaultOIDCCallback is the redirect uri the vault cli uses, roles set up for
+// `vault login -method=oidc` work with it too.
+const vaultOIDCCallback = "localhost:8250"
+
+type vaultCacheEntry struct {
+	secrets map[string]string
+	expires time.Time
+}
+
+// vaultSecretStore keeps the secrets of a stage as the keys of one secret in
+// a KV mount.
+type vaultSecretStore struct {
+	config VaultConfig
+	client *http.Client
+	mutex  sync.Mutex
+	token  string
+	cache  map[string]vaultCacheEntry
+}
+
+func newVaultSecretStore(config *VaultConfig) (*vaultSecretStore, error) {
+	if config == nil {
+		config = &VaultConfig{}
+	}
+	result := &vaultSecretStore{
+		config: *config,
+		client: network.Client(30 * time.Second),
+		cache:  map[string]vaultCacheEntry{},
+	}
+	cfg := &result.config
+	if cfg.Address == "" {
+		cfg.Address = os.Getenv("VAULT_ADDR")
+	}
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("%w: the vault backend needs an address or VAULT_ADDR", ErrSecretBackend)
+	}
+	cfg.Address = strings.TrimSuffix(cfg.Address, "/")
+	if cfg.Namespace == "" {
+		cfg.Namespace = os.Getenv("VAULT_NAMESPACE")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+	cfg.Mount = strings.Trim(cfg.Mount, "/")
+	if cfg.Version == 0 {
+		cfg.Version = 2
+	}
+	if cfg.Version != 1 && cfg.Version != 2 {
+		return nil, fmt.Errorf("%w: vault version must be 1 or 2", ErrSecretBackend)
+	}
+	if cfg.Path == "" {
+		cfg.Path = "sst/{app}/{stage}"
+	}
+	if cfg.Auth == "" {
+		cfg.Auth = "token"
+	}
+	switch cfg.Auth {
+	case "token", "approle", "oidc":
+	default:
+		return nil, fmt.Errorf("%w: unknown vault auth %q, use token, approle, or oidc", ErrSecretBackend, cfg.Auth)
+	}
+	if cfg.AuthMount == "" {
+		cfg.AuthMount = cfg.Auth
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 60
+	}
+	return result, nil
+}
+
+// secretPath is where the secret of a stage is in the mount.
+func (v *vaultSecretStore) secretPath(app, stage string) string {
+	path := strings.ReplaceAll(v.config.Path, "{app}", app)
+	path = strings.ReplaceAll(path, "{stage}", secretStage(stage))
+	return strings.Trim(path, "/")
+}
+
+func (v *vaultSecretStore) path(app, stage string) string {
+	path := v.secretPath(app, stage)
+	if v.config.Version == 2 {
+		return v.config.Mount + "/data/" + path
+	}
+	return v.config.Mount + "/" + path
+}
+
+func (v *vaultSecretStore) GetSecrets(app, stage string) (map[string]string, error) {
+	path := v.path(app, stage)
+	v.mutex.Lock()
+	entry, ok := v.cache[path]
+	v.mutex.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		return copySecrets(entry.secrets), nil
+	}
+
+	result, err := v.read(path)
+	if err != nil {
+		return nil, err
+	}
+	v.mutex.Lock()
+	v.cache[path] = vaultCacheEntry{
+		secrets: copySecrets(result),
+		expires: time.Now().Add(time.Duration(v.config.CacheTTL) * time.Second),
+	}
+	v.mutex.Unlock()
+	return result, nil
+}
+
+func (v *vaultSecretStore) PutSecrets(app, stage string, secrets map[string]string) error {
+	path := v.path(app, stage)
+	var body interface{} = secrets
+	if v.config.Version == 2 {
+		body = map[string]interface{}{"data": secrets}
+	}
+	v.mutex.Lock()
+	delete(v.cache, path)
+	v.mutex.Unlock()
+	_, err := v.request(http.MethodPost, path, body, nil)
+	return err
+}
+
+// read returns the keys of the secret at path, a missing secret has none.
+func (v *vaultSecretStore) read(path string) (map[string]string, error) {
+	var response struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	status, err := v.request(http.MethodGet, path, nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	if status != http.StatusNotFound {
+		data := response.Data
+		if v.config.Version == 2 {
+			// v2 nests the secret in data with its metadata next to it
+			data = map[string]json.RawMessage{}
+			if raw, ok := response.Data["data"]; ok && string(raw) != "null" {
+				err = json.Unmarshal(raw, &data)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		for key, raw := range data {
+			var value string
+			if err := json.Unmarshal(raw, &value); err != nil {
+				// keys someone else set that are not strings are passed as json
+				value = string(raw)
+			}
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+// SecretVersions returns the version of the secret of the stage for all the
+// names, they are its keys and are versioned together. KV v1 keeps no
+// versions.
+func (v *vaultSecretStore) SecretVersions(app, stage string, names []string) (map[string]string, error) {
+	result := map[string]string{}
+	if v.config.Version != 2 {
+		return result, nil
+	}
+	var response struct {
+		Data struct {
+			CurrentVersion int `json:"current_version"`
+		} `json:"data"`
+	}
+	status, err := v.request(http.MethodGet, v.config.Mount+"/metadata/"+v.secretPath(app, stage), nil, &response)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return result, nil
+	}
+	for _, name := range names {
+		result[name] = strconv.Itoa(response.Data.CurrentVersion)
+	}
+	return result, nil
+}
+
+// SecretValue reads a key of a version of the secret of the stage.
+func (v *vaultSecretStore) SecretValue(app, stage, name, version string) (string, error) {
+	if v.config.Version != 2 {
+		return "", ErrSecretVersion
+	}
+	secrets, err := v.read(v.path(app, stage) + "?version=" + url.QueryEscape(version))
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[name]
+	if !ok {
+		return "", fmt.Errorf("%w: version %s of the vault secret has no %s", ErrSecretVersion, version, name)
+	}
+	return value, nil
+}
+
+func copySecrets(secrets map[string]string) map[string]string {
+	result := make(map[string]string, len(secrets))
+	for key, value := range secrets {
+		result[key] = value
+	}
+	return result
+}
+
+// request calls the vault api and decodes the response into out. A missing
+// secret is not an error, the status is returned for the caller to check.
+func (v *vaultSecretStore) request(method, path string, body interface{}, out interface{}) (int, error) {
+	token, err := v.login()
+	if err != nil {
+		return 0, err
+	}
+	status, err := v.call(method, path, token, body, out)
+	if status == http.StatusForbidden && v.config.Auth != "token" {
+		// the token expired while we were running, log in again
+		v.mutex.Lock()
+		v.token = ""
+		v.mutex.Unlock()
+		token, err = v.login()
+		if err != nil {
+			return 0, err
+		}
+		status, err = v.call(method, path, token, body, out)
+	}
+	return status, err
+}
+
+func (v *vaultSecretStore) call(method, path, token string, body interface{}, out interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, v.config.Address+"/v1/"+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.config.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.config.Namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && method == http.MethodGet {
+		return resp.StatusCode, nil
+	}
+	if resp.StatusCode >= 300 {
+		var parsed struct {
+			Errors []string `json:"errors"`
+		}
+		json.NewDecoder(resp.Body).Decode(&parsed)
+		return resp.StatusCode, fmt.Errorf("vault %s %s: %d %s", method, path, resp.StatusCode, strings.Join(parsed.Errors, ", "))
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		err = json.NewDecoder(resp.Body).Decode(out)
+		if err != nil {
+			return resp.StatusCode, err
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+type vaultAuthResponse struct {
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Data struct {
+		AuthURL string `json:"auth_url"`
+	} `json:"data"`
+}
+
+func (v *vaultSecretStore) login() (string, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	if v.token != "" {
+		return v.token, nil
+	}
+	var token string
+	var err error
+	switch v.config.Auth {
+	case "token":
+		token, err = vaultTokenFromEnv()
+	case "approle":
+		token, err = v.loginAppRole()
+	case "oidc":
+		token, err = v.loginOIDC()
+	}
+	if err != nil {
+		return "", err
+	}
+	v.token = token
+	return token, nil
+}
+
+// vaultTokenFromEnv reads the token the way the vault cli does, from
+// VAULT_TOKEN or the file `vault login` writes.
+func vaultTokenFromEnv() (string, error) {
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", fmt.Errorf("%w: set VAULT_TOKEN or run `vault login`", ErrVaultAuth)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (v *vaultSecretStore) loginAppRole() (string, error) {
+	roleID := v.config.Role
+	if roleID == "" {
+		roleID = os.Getenv("VAULT_ROLE_ID")
+	}
+	secretID := os.Getenv("VAULT_SECRET_ID")
+	if roleID == "" || secretID == "" {
+		return "", fmt.Errorf("%w: approle needs VAULT_ROLE_ID and VAULT_SECRET_ID", ErrVaultAuth)
+	}
+	var response vaultAuthResponse
+	_, err := v.call(http.MethodPost, "auth/"+v.config.AuthMount+"/login", "", map[string]string{
+		"role_id":   roleID,
+		"secret_id": secretID,
+	}, &response)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrVaultAuth, err)
+	}
+	return response.Auth.ClientToken, nil
+}
+
+// loginOIDC logs in through the browser like `vault login -method=oidc`.
+func (v *vaultSecretStore) loginOIDC() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	clientNonce := hex.EncodeToString(nonce)
+	redirect := "http://" + vaultOIDCCallback + "/oidc/callback"
+	var response vaultAuthResponse
+	_, err := v.call(http.MethodPost, "auth/"+v.config.AuthMount+"/oidc/auth_url", "", map[string]string{
+		"role":         v.config.Role,
+		"redirect_uri": redirect,
+		"client_nonce": clientNonce,
+	}, &response)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrVaultAuth, err)
+	}
+	if response.Data.AuthURL == "" {
+		return "", fmt.Errorf("%w: the oidc role does not allow %s as a redirect uri", ErrVaultAuth, redirect)
+	}
+
+	listener, err := net.Listen("tcp", vaultOIDCCallback)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrVaultAuth, err)
+	}
+	type result struct {
+		token string
+		err   error
+	}
+	done := make(chan result, 1)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/oidc/callback" {
+				http.NotFound(w, r)
+				return
+			}
+			query := url.Values{}
+			query.Set("state", r.URL.Query().Get("state"))
+			query.Set("code", r.URL.Query().Get("code"))
+			query.Set("client_nonce", clientNonce)
+			var response vaultAuthResponse
+			_, err := v.call(http.MethodGet, "auth/"+v.config.AuthMount+"/oidc/callback?"+query.Encode(), "", nil, &response)
+			if err != nil {
+				http.Error(w, "Could not log in to Vault, check your terminal", http.StatusInternalServerError)
+				done <- result{err: fmt.Errorf("%w: %w", ErrVaultAuth, err)}
+				return
+			}
+			fmt.Fprintln(w, "Logged in to Vault, you can close this window.")
+			done <- result{token: response.Auth.ClientToken}
+		}),
+	}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	fmt.Fprintln(os.Stderr, "Complete the Vault login in your browser:", response.Data.AuthURL)
+	util.OpenBrowser(response.Data.AuthURL)
+	select {
+	case r := <-done:
+		return r.token, r.err
+	case <-time.After(5 * time.Minute):
+		return "", fmt.Errorf("%w: timed out waiting for the browser", ErrVaultAuth)
+	}
+}
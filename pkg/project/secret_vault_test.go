@@ -0,0 +1,87 @@
+package project
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestVaultSecretStore(t *testing.T) {
+	stored := map[string]map[string]string{}
+	reads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/auth/approle/login" {
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"auth": map[string]string{"client_token": "approle-token"},
+			})
+			return
+		}
+		if r.Header.Get("X-Vault-Token") != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			reads++
+			data, ok := stored[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"data": map[string]interface{}{"data": data, "metadata": map[string]int{"version": 1}},
+			})
+		case http.MethodPost:
+			var body struct {
+				Data map[string]string `json:"data"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			stored[r.URL.Path] = body.Data
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("VAULT_ROLE_ID", "role")
+	t.Setenv("VAULT_SECRET_ID", "secret")
+	store, err := newVaultSecretStore(&VaultConfig{
+		Address: server.URL,
+		Mount:   "kv",
+		Path:    "apps/{app}/{stage}",
+		Auth:    "approle",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secrets, err := store.GetSecrets("app", "dev")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secrets) != 0 {
+		t.Fatalf("expected no secrets, got %v", secrets)
+	}
+	err = store.PutSecrets("app", "dev", map[string]string{"Key": "value"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stored["/v1/kv/data/apps/app/dev"]; !ok {
+		t.Fatalf("expected secrets at the templated path, got %v", stored)
+	}
+
+	want := map[string]string{"Key": "value"}
+	for i := 0; i < 2; i++ {
+		secrets, err = store.GetSecrets("app", "dev")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(secrets, want) {
+			t.Fatalf("GetSecrets() = %v, want %v", secrets, want)
+		}
+	}
+	if reads != 2 {
+		t.Errorf("expected the second read to be cached, vault was read %d times", reads)
+	}
+}
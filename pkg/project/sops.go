@@ -0,0 +1,92 @@
+package project
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var ErrSopsMissing = fmt.Errorf("sops is not installed, it is needed to decrypt the secrets file")
+
+// SecretConflictEvent is published when a secret is set both in the sops file
+// of a stage and with `sst secret set`.
+type SecretConflictEvent struct {
+	File  string
+	Names []string
+}
+
+// PathSopsSecrets is the sops file that declares the secrets of a stage.
+func (p Project) PathSopsSecrets(stage string) string {
+	return filepath.Join(p.PathRoot(), "secrets."+stage+".sops.yaml")
+}
+
+// SopsSecrets decrypts the sops file of a stage with the sops cli, which reads
+// the age and KMS keys from the environment. It returns nil if there is no file.
+func (p Project) SopsSecrets(stage string) (map[string]string, error) {
+	path := p.PathSopsSecrets(stage)
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if _, err := exec.LookPath("sops"); err != nil {
+		return nil, ErrSopsMissing
+	}
+	cmd := exec.Command("sops", "--decrypt", "--output-type", "json", path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not decrypt %s: %s", filepath.Base(path), strings.TrimSpace(stderr.String()))
+	}
+	return parseSopsSecrets(output)
+}
+
+func parseSopsSecrets(data []byte) (map[string]string, error) {
+	parsed := map[string]interface{}{}
+	err := json.Unmarshal(data, &parsed)
+	if err != nil {
+		return nil, err
+	}
+	result := map[string]string{}
+	for name, value := range parsed {
+		switch value := value.(type) {
+		case string:
+			result[name] = value
+		case nil:
+			result[name] = ""
+		default:
+			// numbers, booleans, and nested values are passed as json so
+			// they can be parsed in the function
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, err
+			}
+			result[name] = string(encoded)
+		}
+	}
+	return result, nil
+}
+
+// MergeSecrets adds the secrets declared in a sops file to the ones that were
+// set with `sst secret set`. The file wins, the secrets set to something else
+// are returned as conflicts.
+func MergeSecrets(declared, imperative map[string]string) (map[string]string, []string) {
+	merged := map[string]string{}
+	for name, value := range imperative {
+		merged[name] = value
+	}
+	conflicts := []string{}
+	for name, value := range declared {
+		if current, ok := imperative[name]; ok && current != value {
+			conflicts = append(conflicts, name)
+		}
+		merged[name] = value
+	}
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
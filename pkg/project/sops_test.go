@@ -0,0 +1,30 @@
+package project
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMergeSecrets(t *testing.T) {
+	declared, err := parseSopsSecrets([]byte(`{"Stripe":"sk_live","Port":8080,"Flags":{"beta":true}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, conflicts := MergeSecrets(declared, map[string]string{
+		"Stripe":   "sk_test",
+		"Port":     "8080",
+		"Database": "db",
+	})
+	want := map[string]string{
+		"Stripe":   "sk_live",
+		"Port":     "8080",
+		"Flags":    `{"beta":true}`,
+		"Database": "db",
+	}
+	if !reflect.DeepEqual(merged, want) {
+		t.Errorf("merged = %v, want %v", merged, want)
+	}
+	if !reflect.DeepEqual(conflicts, []string{"Stripe"}) {
+		t.Errorf("conflicts = %v, want [Stripe]", conflicts)
+	}
+}
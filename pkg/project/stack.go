@@ -9,7 +9,6 @@ import (
 	"io"
 	"log/slog"
 	"os"
-	"path"
 	"path/filepath"
 	"reflect"
 	"regexp"
@@ -27,14 +26,20 @@ import (
 	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/tokens"
 	"github.com/pulumi/pulumi/sdk/v3/go/common/workspace"
+	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/bus"
 	"github.com/sst/ion/pkg/flag"
 	"github.com/sst/ion/pkg/global"
 	"github.com/sst/ion/pkg/js"
+	"github.com/sst/ion/pkg/metrics"
 	"github.com/sst/ion/pkg/project/common"
 	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/registry"
 	"github.com/sst/ion/pkg/telemetry"
 	"github.com/sst/ion/pkg/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/sync/errgroup"
 )
 
@@ -43,11 +48,27 @@ type BuildFailedEvent struct {
 }
 
 type StackInput struct {
-	Command    string
-	Target     []string
-	ServerPort int
-	Dev        bool
-	Verbose    bool
+	Command string
+	// Target limits the command to these URNs, URN globs, or component names
+	Target []string
+	// TargetDependents also runs the command on resources that depend on the
+	// targets. Deploy does unless --skip-dependents is passed, and remove
+	// always does since dependents cannot outlive a target.
+	TargetDependents bool
+	// Rollback is the id of a checkpoint to deploy instead of sst.config.ts
+	Rollback    string
+	ServerPort  int
+	ServerToken string
+	Dev         bool
+	Verbose     bool
+	// ForceUnprotect deletes protected resources too, the stage has to be
+	// confirmed before
+	ForceUnprotect bool
+}
+
+// readOnly commands preview changes without locking or touching the state.
+func (input *StackInput) readOnly() bool {
+	return input.Command == "diff" || input.Command == "drift"
 }
 
 type ConcurrentUpdateEvent struct{}
@@ -72,7 +93,20 @@ type Dev struct {
 	Aws         *struct {
 		Role string `json:"role"`
 	} `json:"aws"`
+	Docker *DevDocker `json:"docker"`
 }
+
+// DevDocker is set when a service runs its image locally in dev. The image is
+// built from Context unless it is empty.
+type DevDocker struct {
+	Image      string            `json:"image"`
+	Context    string            `json:"context"`
+	Dockerfile string            `json:"dockerfile"`
+	Args       map[string]string `json:"args"`
+	Sync       string            `json:"sync"`
+	Ports      []string          `json:"ports"`
+}
+
 type Devs map[string]Dev
 
 type CompleteEvent struct {
@@ -87,6 +121,8 @@ type CompleteEvent struct {
 	Resources   []apitype.ResourceV3
 	ImportDiffs map[string][]ImportDiff
 	Tunnels     map[string]Tunnel
+	DeployID    string
+	Timings     *provider.SummaryTimings
 }
 
 type Tunnel struct {
@@ -94,6 +130,11 @@ type Tunnel struct {
 	Username   string   `json:"username"`
 	PrivateKey string   `json:"privateKey"`
 	Subnets    []string `json:"subnets"`
+	// Transport is how the tunnel reaches the bastion, ssh to its public ip
+	// or ssm through the agent on Instance
+	Transport string `json:"transport"`
+	Instance  string `json:"instance"`
+	Region    string `json:"region"`
 }
 
 type ImportDiff struct {
@@ -103,12 +144,24 @@ type ImportDiff struct {
 	New   interface{}
 }
 
+// TunnelStatusEvent is published by `sst dev` when the tunnel to the bastion
+// of the vpc drops, reconnects or stops.
+type TunnelStatusEvent struct {
+	State      string
+	Error      string
+	Reconnects int
+}
+
 type StackCommandEvent struct {
 	App     string
 	Stage   string
 	Config  string
 	Command string
 	Version string
+	// DeployID is also the id of the update in the history and an attribute
+	// of the spans when tracing is enabled
+	DeployID string
+	Dev      bool
 }
 
 type Error struct {
@@ -164,18 +217,38 @@ var ErrStageNotFound = fmt.Errorf("stage not found")
 var ErrPassphraseInvalid = fmt.Errorf("passphrase invalid")
 
 func (p *Project) Run(ctx context.Context, input *StackInput) error {
+	updateID := cuid2.Generate()
+	ctx, span := telemetry.Span(ctx, "stack."+input.Command,
+		attribute.String("sst.app", p.app.Name),
+		attribute.String("sst.stage", p.app.Stage),
+		attribute.String("sst.deploy.id", updateID),
+	)
+	telemetry.SetDeploy(span)
+	defer telemetry.SetDeploy(nil)
+	start := time.Now()
+	err := p.run(ctx, input, updateID)
+	metrics.DeployDuration.WithLabelValues(input.Command, metrics.Status(err)).Observe(time.Since(start).Seconds())
+	telemetry.EndSpan(span, err)
+	return err
+}
+
+func (p *Project) run(ctx context.Context, input *StackInput, updateID string) error {
 	slog.Info("running stack command", "cmd", input.Command)
 
 	bus.Publish(&StackCommandEvent{
-		App:     p.app.Name,
-		Stage:   p.app.Stage,
-		Config:  p.PathConfig(),
-		Command: input.Command,
-		Version: p.Version(),
+		App:      p.app.Name,
+		Stage:    p.app.Stage,
+		Config:   p.PathConfig(),
+		Command:  input.Command,
+		Version:  p.Version(),
+		DeployID: updateID,
+		Dev:      input.Dev,
 	})
+	started := time.Now()
+	// builds from before this update, like the ones of dev, are not part of it
+	p.Runtime.Timings()
 
-	updateID := cuid2.Generate()
-	if input.Command != "diff" {
+	if !input.readOnly() {
 		err := p.Lock(updateID, input.Command)
 		if err != nil {
 			if err == provider.ErrLockExists {
@@ -184,9 +257,22 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 			return err
 		}
 		defer p.Unlock()
+		// the update is stopped if the lock is lost, it would overwrite the
+		// state of the update that has it now
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		lost := p.lockLost
+		go func() {
+			select {
+			case <-lost:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
 	}
 
-	_, err := p.PullState()
+	statePath, err := p.PullState()
 	if err != nil {
 		if errors.Is(err, provider.ErrStateNotFound) {
 			if input.Command != "deploy" {
@@ -196,7 +282,7 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 			return err
 		}
 	}
-	if input.Command != "diff" {
+	if !input.readOnly() {
 		defer p.PushState(updateID)
 	}
 
@@ -211,17 +297,17 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 	wg := errgroup.Group{}
 
 	wg.Go(func() error {
-		secrets, err = provider.GetSecrets(p.home, p.app.Name, p.app.Stage)
+		secrets, err = p.secrets.GetSecrets(p.app.Name, p.app.Stage)
 		if err != nil {
-			return ErrPassphraseInvalid
+			return err
 		}
 		return nil
 	})
 
 	wg.Go(func() error {
-		fallback, err = provider.GetSecrets(p.home, p.app.Name, "")
+		fallback, err = p.secrets.GetSecrets(p.app.Name, "")
 		if err != nil {
-			return ErrPassphraseInvalid
+			return err
 		}
 		return nil
 	})
@@ -230,12 +316,72 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 		return err
 	}
 
+	secretHistory, err := provider.GetSecretHistory(p.home, p.app.Name, p.app.Stage)
+	if err != nil {
+		return err
+	}
+	secretVersions := map[string]int{}
+	for name, version := range LatestSecretVersions(secretHistory) {
+		if _, ok := secrets[name]; ok {
+			secretVersions[name] = version
+		}
+	}
+
+	if p.app.Secrets != nil && len(p.app.Secrets.Rotation) > 0 {
+		statuses, err := RotationStatuses(p.app.Secrets.Rotation, secrets, secretHistory, time.Now())
+		if err != nil {
+			return err
+		}
+		due := []string{}
+		for _, status := range statuses {
+			if status.Due {
+				due = append(due, status.Name)
+			}
+		}
+		if len(due) > 0 {
+			bus.Publish(&SecretRotationEvent{Names: due})
+		}
+	}
+
+	declared, err := p.SopsSecrets(p.app.Stage)
+	if err != nil {
+		return err
+	}
+	if declared != nil {
+		var conflicts []string
+		secrets, conflicts = MergeSecrets(declared, secrets)
+		if len(conflicts) > 0 {
+			bus.Publish(&SecretConflictEvent{
+				File:  filepath.Base(p.PathSopsSecrets(p.app.Stage)),
+				Names: conflicts,
+			})
+		}
+	}
+
 	outfile := filepath.Join(p.PathPlatformDir(), fmt.Sprintf("sst.config.%v.mjs", time.Now().UnixMilli()))
 
 	env := map[string]string{}
 	for key, value := range p.Env() {
 		env[key] = value
 	}
+	// credentials can expire during a long session of sst dev, so they are
+	// fetched again for every run
+	for _, prov := range p.loadedProviders {
+		fresh, err := prov.Env()
+		if err != nil {
+			return err
+		}
+		for key, value := range fresh {
+			env[key] = value
+		}
+	}
+	watchCtx, stopWatch := context.WithCancel(ctx)
+	defer stopWatch()
+	for name, prov := range p.loadedProviders {
+		if expiring, ok := prov.(provider.ExpiringProvider); ok {
+			go provider.WatchCredentials(watchCtx, name, expiring)
+		}
+	}
 	for _, value := range os.Environ() {
 		pair := strings.SplitN(value, "=", 2)
 		if len(pair) == 2 {
@@ -251,10 +397,14 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 	env["PULUMI_CONFIG_PASSPHRASE"] = passphrase
 	env["PULUMI_SKIP_UPDATE_CHECK"] = "true"
 	// env["PULUMI_DISABLE_AUTOMATIC_PLUGIN_ACQUISITION"] = "true"
+	if mirror := registry.Get(); mirror.Enabled() {
+		env["PULUMI_PLUGIN_DOWNLOAD_URL_OVERRIDES"] = "^.*=" + mirror.URL("plugins")
+	}
 	env["NODE_OPTIONS"] = "--enable-source-maps --no-deprecation"
 	// env["TMPDIR"] = p.PathLog("")
 	if input.ServerPort != 0 {
 		env["SST_SERVER"] = fmt.Sprintf("http://localhost:%v", input.ServerPort)
+		env["SST_SERVER_TOKEN"] = input.ServerToken
 	}
 	pulumiPath := flag.SST_PULUMI_PATH
 	if pulumiPath == "" {
@@ -310,9 +460,34 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 	bus.Publish(completed)
 	slog.Info("got previous deployment")
 
+	target, err := resolveTargets(completed.Resources, input.Target, input.Command != "remove")
+	if err != nil {
+		return err
+	}
+
+	protected := protectedResources(completed.Resources, p.app.Protect, target)
+	if input.Command == "remove" && len(protected) > 0 && !input.ForceUnprotect {
+		return protectedError(p.app.Stage, protected)
+	}
+	// pulumi refuses to delete resources that are protected in the state, only
+	// the ones this update could delete are unprotected
+	if input.ForceUnprotect && statePath != "" && len(protected) > 0 {
+		state, err := readState(statePath)
+		if err != nil {
+			return err
+		}
+		if count := state.unprotect(protected); count > 0 {
+			slog.Info("unprotected resources", "count", count)
+			if err := state.Save(); err != nil {
+				return err
+			}
+		}
+	}
+
 	cli := map[string]interface{}{
-		"command": input.Command,
-		"dev":     input.Dev,
+		"command":   input.Command,
+		"dev":       input.Dev,
+		"unprotect": input.ForceUnprotect,
 		"paths": map[string]string{
 			"home":     global.ConfigDir(),
 			"root":     p.PathRoot(),
@@ -336,53 +511,68 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 	for _, entry := range p.lock {
 		providerShim = append(providerShim, fmt.Sprintf("import * as %s from \"%s\";", entry.Alias, entry.Package))
 	}
-	providerShim = append(providerShim, fmt.Sprintf("import * as sst from \"%s\";", path.Join(p.PathPlatformDir(), "src/components")))
+	providerShim = append(providerShim, fmt.Sprintf("import * as sst from \"%s\";", js.ImportPath(filepath.Join(p.PathPlatformDir(), "src", "components"))))
 
-	buildResult, err := js.Build(js.EvalOptions{
-		Dir:     p.PathRoot(),
-		Outfile: outfile,
-		Define: map[string]string{
-			"$app": string(appBytes),
-			"$cli": string(cliBytes),
-			"$dev": fmt.Sprintf("%v", input.Dev),
-		},
-		Inject:  []string{filepath.Join(p.PathWorkingDir(), "platform/src/shim/run.js")},
-		Globals: strings.Join(providerShim, "\n"),
-		Code: fmt.Sprintf(`
+	var buildTime time.Duration
+	if input.Rollback != "" {
+		err = p.writeRollback(ctx, stack, input.Rollback, outfile)
+		if err != nil {
+			return err
+		}
+		if !flag.SST_NO_CLEANUP {
+			defer os.Remove(outfile)
+		}
+	} else {
+		_, buildSpan := telemetry.Span(ctx, "config.build")
+		buildStart := time.Now()
+		buildResult, err := js.Build(js.EvalOptions{
+			Dir:     p.PathRoot(),
+			Outfile: outfile,
+			Define: map[string]string{
+				"$app": string(appBytes),
+				"$cli": string(cliBytes),
+				"$dev": fmt.Sprintf("%v", input.Dev),
+			},
+			Inject:  []string{filepath.Join(p.PathWorkingDir(), "platform", "src", "shim", "run.js")},
+			Globals: strings.Join(providerShim, "\n"),
+			Code: fmt.Sprintf(`
       import { run } from "%v";
       import mod from "%v/sst.config.ts";
       const result = await run(mod.run);
       export default result;
     `,
-			path.Join(p.PathWorkingDir(), "platform/src/auto/run.ts"),
-			p.PathRoot(),
-		),
-	})
-	if err != nil {
-		bus.Publish(&BuildFailedEvent{
-			Error: err.Error(),
+				js.ImportPath(filepath.Join(p.PathWorkingDir(), "platform", "src", "auto", "run.ts")),
+				js.ImportPath(p.PathRoot()),
+			),
 		})
-		return err
-	}
-	if !flag.SST_NO_CLEANUP {
-		defer js.Cleanup(buildResult)
-	}
+		telemetry.EndSpan(buildSpan, err)
+		buildTime = time.Since(buildStart)
+		if err != nil {
+			bus.Publish(&BuildFailedEvent{
+				Error: err.Error(),
+			})
+			return err
+		}
+		if !flag.SST_NO_CLEANUP {
+			defer js.Cleanup(buildResult)
+		}
 
-	var meta = map[string]interface{}{}
-	err = json.Unmarshal([]byte(buildResult.Metafile), &meta)
-	if err != nil {
-		return err
-	}
-	files := []string{}
-	for key := range meta["inputs"].(map[string]interface{}) {
-		absPath, err := filepath.Abs(key)
+		var meta = map[string]interface{}{}
+		err = json.Unmarshal([]byte(buildResult.Metafile), &meta)
 		if err != nil {
-			continue
+			return err
+		}
+		files := []string{}
+		for key := range meta["inputs"].(map[string]interface{}) {
+			absPath, err := filepath.Abs(key)
+			if err != nil {
+				continue
+			}
+			files = append(files, absPath)
 		}
-		files = append(files, absPath)
+		bus.Publish(&BuildSuccessEvent{files})
+		slog.Info("tracked files")
 	}
-	bus.Publish(&BuildSuccessEvent{files})
-	slog.Info("tracked files")
 
 	config := auto.ConfigMap{}
 	for provider, args := range p.app.Providers {
@@ -419,6 +609,11 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 	errors := []Error{}
 	finished := false
 	importDiffs := map[string][]ImportDiff{}
+	var timings *provider.SummaryTimings
+	// a span for each resource operation, from its pre event to its outputs
+	resourceSpans := map[string]trace.Span{}
+	resources := newResourceTimer()
+	changes := newReceiptChanges()
 
 	go func() {
 		for {
@@ -430,6 +625,35 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 					return
 				}
 
+				if event.ResourcePreEvent != nil {
+					metadata := event.ResourcePreEvent.Metadata
+					if metadata.Op != apitype.OpSame {
+						resources.start(metadata.URN, string(metadata.Op), time.Now())
+						_, resourceSpans[metadata.URN] = telemetry.Span(ctx, "resource."+string(metadata.Op),
+							attribute.String("sst.resource.urn", metadata.URN),
+							attribute.String("sst.resource.type", metadata.Type),
+							attribute.String("sst.deploy.id", updateID),
+						)
+					}
+				}
+				if event.ResOutputsEvent != nil {
+					resources.finish(event.ResOutputsEvent.Metadata.URN, time.Now())
+					changes.add(event.ResOutputsEvent.Metadata, false)
+					if span, ok := resourceSpans[event.ResOutputsEvent.Metadata.URN]; ok {
+						telemetry.EndSpan(span, nil)
+						delete(resourceSpans, event.ResOutputsEvent.Metadata.URN)
+					}
+				}
+				if event.ResOpFailedEvent != nil {
+					resources.finish(event.ResOpFailedEvent.Metadata.URN, time.Now())
+					changes.add(event.ResOpFailedEvent.Metadata, true)
+					if span, ok := resourceSpans[event.ResOpFailedEvent.Metadata.URN]; ok {
+						span.SetStatus(codes.Error, "operation failed")
+						span.End()
+						delete(resourceSpans, event.ResOpFailedEvent.Metadata.URN)
+					}
+				}
+
 				if event.DiagnosticEvent != nil && event.DiagnosticEvent.Severity == "error" {
 					if strings.HasPrefix(event.DiagnosticEvent.Message, "update failed") {
 						break
@@ -502,12 +726,20 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 			return
 		}
 		complete.Finished = finished
+		complete.DeployID = updateID
 		complete.Errors = errors
 		complete.ImportDiffs = importDiffs
+		complete.Timings = timings
 		defer bus.Publish(complete)
-		if input.Command == "diff" {
+		if input.readOnly() {
 			return
 		}
+		// sst dev redeploys on every change, those are not worth a receipt
+		if !input.Dev {
+			if err := p.writeReceipt(p.newReceipt(input, updateID, started, complete, changes.list)); err != nil {
+				slog.Error("failed to write receipt", "err", err)
+			}
+		}
 
 		outputsFilePath := filepath.Join(p.PathWorkingDir(), "outputs.json")
 		outputsFile, _ := os.Create(outputsFilePath)
@@ -519,13 +751,14 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 	slog.Info("running stack command", "cmd", input.Command)
 	var summary auto.UpdateSummary
 	defer func() {
-		if input.Command == "diff" {
+		if input.readOnly() {
 			return
 		}
 		var parsed provider.Summary
 		parsed.Version = p.Version()
 		parsed.UpdateID = updateID
 		parsed.TimeStarted = summary.StartTime
+		parsed.Interrupted = ctx.Err() != nil
 		parsed.TimeCompleted = time.Now().Format(time.RFC3339)
 		if summary.EndTime != nil {
 			parsed.TimeCompleted = *summary.EndTime
@@ -544,6 +777,10 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 				parsed.ResourceDeleted = match
 			}
 		}
+		if len(secretVersions) > 0 {
+			parsed.Secrets = secretVersions
+		}
+		parsed.Timings = timings
 		for _, err := range errors {
 			parsed.Errors = append(parsed.Errors, provider.SummaryError{
 				URN:     err.URN,
@@ -552,6 +789,22 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 		}
 		provider.PutSummary(p.home, p.app.Name, p.app.Stage, updateID, parsed)
 	}()
+	var engineTime time.Duration
+	// runs before the summary and the complete event are put together
+	defer func() {
+		timings = &provider.SummaryTimings{
+			Total:     time.Since(started) + p.configTime + p.providerTime,
+			Config:    p.configTime,
+			Providers: p.providerTime,
+			Build:     buildTime,
+			Engine:    engineTime,
+			Functions: functionTimings(p.Runtime.Timings()),
+			Resources: slowest(resources.done, slowestResources),
+		}
+		// the project is loaded once, later updates in dev did not wait on it
+		p.configTime = 0
+		p.providerTime = 0
+	}()
 
 	pulumiLog, err := os.Create(p.PathLog("pulumi"))
 	if err != nil {
@@ -568,7 +821,8 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 		match := regexp.MustCompile(`\[resource plugin ([^\]]*)`)
 		for scanner.Scan() {
 			text := scanner.Text()
-			slog.Error("pulumi error", "line", text)
+			// pulumi writes its progress to stderr too, so this is not a warning
+			slog.Info("pulumi stderr", "line", text)
 			matches := match.FindStringSubmatch(text)
 			if len(matches) > 1 {
 				plugin := matches[1]
@@ -597,16 +851,20 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 		}
 	}
 
+	engineStart := time.Now()
 	switch input.Command {
 	case "deploy":
-		result, derr := stack.Up(ctx,
+		opts := []optup.Option{
 			optup.DebugLogging(debugLogging),
-			optup.Target(input.Target),
-			optup.TargetDependents(),
+			optup.Target(target),
 			optup.ProgressStreams(pulumiLog),
 			optup.ErrorProgressStreams(pulumiErrWriter),
 			optup.EventStreams(stream),
-		)
+		}
+		if input.TargetDependents {
+			opts = append(opts, optup.TargetDependents())
+		}
+		result, derr := stack.Up(ctx, opts...)
 		err = derr
 		summary = result.Summary
 
@@ -614,7 +872,7 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 		result, derr := stack.Destroy(ctx,
 			optdestroy.DebugLogging(debugLogging),
 			optdestroy.ContinueOnError(),
-			optdestroy.Target(input.Target),
+			optdestroy.Target(target),
 			optdestroy.TargetDependents(),
 			optdestroy.ProgressStreams(pulumiLog),
 			optdestroy.ErrorProgressStreams(pulumiErrWriter),
@@ -626,26 +884,43 @@ func (p *Project) Run(ctx context.Context, input *StackInput) error {
 	case "refresh":
 		result, derr := stack.Refresh(ctx,
 			optrefresh.DebugLogging(debugLogging),
-			optrefresh.Target(input.Target),
+			optrefresh.Target(target),
 			optrefresh.ProgressStreams(pulumiLog),
 			optrefresh.ErrorProgressStreams(pulumiErrWriter),
 			optrefresh.EventStreams(stream),
 		)
 		err = derr
 		summary = result.Summary
+	case "drift":
+		_, derr := stack.PreviewRefresh(ctx,
+			optrefresh.DebugLogging(debugLogging),
+			optrefresh.Target(target),
+			optrefresh.ProgressStreams(pulumiLog),
+			optrefresh.ErrorProgressStreams(pulumiErrWriter),
+			optrefresh.EventStreams(stream),
+		)
+		err = derr
 	case "diff":
-		_, derr := stack.Preview(ctx,
+		opts := []optpreview.Option{
 			optpreview.DebugLogging(debugLogging),
 			optpreview.Diff(),
-			optpreview.Target(input.Target),
+			optpreview.Target(target),
 			optpreview.ProgressStreams(pulumiLog),
 			optpreview.ErrorProgressStreams(pulumiErrWriter),
 			optpreview.EventStreams(stream),
-		)
+		}
+		if input.TargetDependents {
+			opts = append(opts, optpreview.TargetDependents())
+		}
+		_, derr := stack.Preview(ctx, opts...)
 		err = derr
 	}
+	engineTime = time.Since(engineStart)
 
 	slog.Info("done running stack command")
+	if p.isLockLost() {
+		return lockLostError()
+	}
 	if err != nil {
 		slog.Error("stack run failed", "error", err)
 		return ErrStackRunFailed
@@ -657,18 +932,61 @@ type PreviewInput struct {
 	Out chan interface{}
 }
 
-type ImportOptions struct {
-	Type   string
-	Name   string
-	ID     string
-	Parent string
+// Lock takes the lock on the stage and renews its lease until Unlock.
+func (s *Project) Lock(updateID string, command string) error {
+	err := provider.Lock(s.home, updateID, command, s.app.Name, s.app.Stage)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	s.lockRenew = cancel
+	lost := make(chan struct{})
+	s.lockLost = lost
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(provider.LockRenew):
+			}
+			err := provider.RenewLock(s.home, updateID, s.app.Name, s.app.Stage)
+			if err != nil {
+				slog.Error("failed to renew lock", "err", err)
+				if errors.Is(err, provider.ErrLockLost) {
+					close(lost)
+					return
+				}
+			}
+		}
+	}()
+	return nil
 }
 
-func (s *Project) Lock(updateID string, command string) error {
-	return provider.Lock(s.home, updateID, command, s.app.Name, s.app.Stage)
+func (s *Project) isLockLost() bool {
+	if s.lockLost == nil {
+		return false
+	}
+	select {
+	case <-s.lockLost:
+		return true
+	default:
+		return false
+	}
+}
+
+func lockLostError() error {
+	return util.NewReadableError(provider.ErrLockLost, "Another update took the lock on this stage while this one was running. It was stopped and its state was not saved.")
+}
+
+func (s *Project) stopRenew() {
+	if s.lockRenew != nil {
+		s.lockRenew()
+		s.lockRenew = nil
+	}
 }
 
 func (s *Project) Unlock() error {
+	s.stopRenew()
 	if !flag.SST_NO_CLEANUP {
 		dir := s.PathWorkingDir()
 		files, err := os.ReadDir(dir)
@@ -684,6 +1002,10 @@ func (s *Project) Unlock() error {
 			}
 		}
 	}
+	// the lock is not ours to remove anymore
+	if s.isLockLost() {
+		return nil
+	}
 	return provider.Unlock(s.home, s.app.Name, s.app.Stage)
 }
 
@@ -712,17 +1034,37 @@ func (s *Project) PullState() (string, error) {
 }
 
 func (s *Project) PushState(version string) error {
+	if s.isLockLost() {
+		return lockLostError()
+	}
 	pulumiDir := filepath.Join(s.PathWorkingDir(), ".pulumi")
-	return provider.PushState(
+	err := provider.PushState(
 		s.home,
 		version,
 		s.app.Name,
 		s.app.Stage,
 		filepath.Join(pulumiDir, "stacks", s.app.Name, fmt.Sprintf("%v.json", s.app.Stage)),
 	)
+	if err != nil {
+		return err
+	}
+	if s.app.History.Retain > 0 {
+		removed, err := provider.PruneHistory(s.home, s.app.Name, s.app.Stage, s.app.History.Retain)
+		if err != nil && !errors.Is(err, provider.ErrListUnsupported) {
+			slog.Error("failed to prune history", "err", err)
+		}
+		if removed > 0 {
+			slog.Info("pruned history", "removed", removed)
+		}
+		if err := pruneReceipts(s.PathReceipts(), s.app.History.Retain); err != nil {
+			slog.Error("failed to prune receipts", "err", err)
+		}
+	}
+	return nil
 }
 
 func (s *Project) Cancel() error {
+	s.stopRenew()
 	return provider.Unlock(
 		s.home,
 		s.app.Name,
@@ -819,6 +1161,9 @@ func getCompletedEvent(ctx context.Context, stack auto.Stack) (*CompleteEvent, e
 				PrivateKey: match["privateKey"].(string),
 				Subnets:    []string{},
 			}
+			tunnel.Transport, _ = match["transport"].(string)
+			tunnel.Instance, _ = match["instance"].(string)
+			tunnel.Region, _ = match["region"].(string)
 			subnets, ok := match["subnets"].([]interface{})
 			if ok {
 				for _, subnet := range subnets {
@@ -897,12 +1242,24 @@ func (p *Project) GetCompleted(ctx context.Context) (*CompleteEvent, error) {
 	if err != nil {
 		return nil, err
 	}
+	stack, err := p.localStack(ctx, map[string]string{
+		"PULUMI_CONFIG_PASSPHRASE": passphrase,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return getCompletedEvent(ctx, stack)
+}
+
+// localStack opens the pulled state without a program, for commands that
+// only read or edit the state.
+func (p *Project) localStack(ctx context.Context, env map[string]string) (auto.Stack, error) {
 	pulumi, err := auto.NewPulumiCommand(&auto.PulumiCommandOptions{
 		Root:             filepath.Join(global.BinPath(), ".."),
 		SkipVersionCheck: true,
 	})
 	if err != nil {
-		return nil, err
+		return auto.Stack{}, err
 	}
 	ws, err := auto.NewLocalWorkspace(ctx,
 		auto.Pulumi(pulumi),
@@ -915,21 +1272,13 @@ func (p *Project) GetCompleted(ctx context.Context) (*CompleteEvent, error) {
 				URL: fmt.Sprintf("file://%v", p.PathWorkingDir()),
 			},
 		}),
-		auto.EnvVars(
-			map[string]string{
-				"PULUMI_CONFIG_PASSPHRASE": passphrase,
-			},
-		),
+		auto.EnvVars(env),
 	)
 	if err != nil {
-		return nil, err
+		return auto.Stack{}, err
 	}
-	stack, err := auto.UpsertStack(ctx,
+	return auto.UpsertStack(ctx,
 		p.app.Stage,
 		ws,
 	)
-	if err != nil {
-		return nil, err
-	}
-	return getCompletedEvent(ctx, stack)
 }
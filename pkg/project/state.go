@@ -0,0 +1,215 @@
+package project
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+var ErrResourceNotFound = fmt.Errorf("resource not found")
+
+// State is a pulled copy of the state for the current stage that can be
+// inspected and edited before being pushed back.
+type State struct {
+	path       string
+	original   []byte
+	version    int
+	checkpoint apitype.CheckpointV3
+}
+
+func (p *Project) ReadState() (*State, error) {
+	path, err := p.PullState()
+	if err != nil {
+		return nil, err
+	}
+	return readState(path)
+}
+
+// ReadCheckpoint reads a checkpoint from the history of the stage. It is
+// pulled next to the state and not meant to be saved.
+func (p *Project) ReadCheckpoint(id string) (*State, error) {
+	path := filepath.Join(p.PathWorkingDir(), "checkpoint.json")
+	err := provider.PullCheckpoint(p.home, p.app.Name, p.app.Stage, id, path)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	return readState(path)
+}
+
+func readState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var versioned apitype.VersionedCheckpoint
+	err = json.Unmarshal(data, &versioned)
+	if err != nil {
+		return nil, err
+	}
+	if versioned.Version != 3 {
+		return nil, fmt.Errorf("unsupported state version: %d", versioned.Version)
+	}
+	state := &State{
+		path:     path,
+		original: data,
+		version:  versioned.Version,
+	}
+	err = json.Unmarshal(versioned.Checkpoint, &state.checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	if state.checkpoint.Latest == nil {
+		state.checkpoint.Latest = &apitype.DeploymentV3{}
+	}
+	return state, nil
+}
+
+// Restore replaces the contents of the state with those of another, like a
+// checkpoint.
+func (s *State) Restore(from *State) {
+	s.version = from.version
+	s.checkpoint = from.checkpoint
+}
+
+func (s *State) Resources() []apitype.ResourceV3 {
+	return s.checkpoint.Latest.Resources
+}
+
+func (s *State) Find(urn string) (*apitype.ResourceV3, error) {
+	for i := range s.checkpoint.Latest.Resources {
+		if string(s.checkpoint.Latest.Resources[i].URN) == urn {
+			return &s.checkpoint.Latest.Resources[i], nil
+		}
+	}
+	return nil, ErrResourceNotFound
+}
+
+// Dependents returns the resources that reference urn as their parent, a
+// dependency, or their provider.
+func (s *State) Dependents(urn string) []resource.URN {
+	result := []resource.URN{}
+	for _, item := range s.checkpoint.Latest.Resources {
+		if string(item.URN) == urn {
+			continue
+		}
+		if references(&item, resource.URN(urn)) {
+			result = append(result, item.URN)
+		}
+	}
+	return result
+}
+
+func references(item *apitype.ResourceV3, urn resource.URN) bool {
+	if item.Parent == urn || item.DeletedWith == urn || strings.HasPrefix(item.Provider, string(urn)+"::") {
+		return true
+	}
+	for _, dep := range item.Dependencies {
+		if dep == urn {
+			return true
+		}
+	}
+	for _, deps := range item.PropertyDependencies {
+		for _, dep := range deps {
+			if dep == urn {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Remove drops urn from the state. It refuses to if other resources still
+// reference it since that would leave the state inconsistent.
+func (s *State) Remove(urn string) error {
+	if _, err := s.Find(urn); err != nil {
+		return err
+	}
+	if dependents := s.Dependents(urn); len(dependents) > 0 {
+		return fmt.Errorf("%s is still referenced by %d resources: %v", urn, len(dependents), dependents)
+	}
+	resources := s.checkpoint.Latest.Resources[:0]
+	for _, item := range s.checkpoint.Latest.Resources {
+		if string(item.URN) != urn {
+			resources = append(resources, item)
+		}
+	}
+	s.checkpoint.Latest.Resources = resources
+	return nil
+}
+
+// Move renames a resource and updates everything that references it.
+func (s *State) Move(from string, to string) error {
+	item, err := s.Find(from)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Find(to); err == nil {
+		return fmt.Errorf("%s already exists", to)
+	}
+	if !resource.URN(to).IsValid() {
+		return fmt.Errorf("invalid urn: %s", to)
+	}
+	old := resource.URN(from)
+	next := resource.URN(to)
+	item.URN = next
+	for i := range s.checkpoint.Latest.Resources {
+		r := &s.checkpoint.Latest.Resources[i]
+		if r.Parent == old {
+			r.Parent = next
+		}
+		if r.DeletedWith == old {
+			r.DeletedWith = next
+		}
+		if strings.HasPrefix(r.Provider, from+"::") {
+			r.Provider = to + strings.TrimPrefix(r.Provider, from)
+		}
+		for j, dep := range r.Dependencies {
+			if dep == old {
+				r.Dependencies[j] = next
+			}
+		}
+		for _, deps := range r.PropertyDependencies {
+			for j, dep := range deps {
+				if dep == old {
+					deps[j] = next
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Backup writes the state as it was pulled to dir and returns the path.
+func (s *State) Backup(dir string) (string, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.json", strings.TrimSuffix(filepath.Base(s.path), ".json"), time.Now().UTC().Format("20060102T150405")))
+	return path, os.WriteFile(path, s.original, 0644)
+}
+
+// Save writes the edited state back to the pulled file so it can be pushed
+// with PushState.
+func (s *State) Save() error {
+	checkpoint, err := json.Marshal(s.checkpoint)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(apitype.VersionedCheckpoint{
+		Version:    s.version,
+		Checkpoint: checkpoint,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.path, data, 0644)
+}
@@ -0,0 +1,112 @@
+package project
+
+import (
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+const (
+	stackURN  = "urn:pulumi:dev::app::pulumi:pulumi:Stack::app-dev"
+	bucketURN = "urn:pulumi:dev::app::sst:aws:Bucket::MyBucket"
+	childURN  = "urn:pulumi:dev::app::sst:aws:Bucket$aws:s3/bucketV2:BucketV2::MyBucketBucket"
+)
+
+func testState() *State {
+	return &State{
+		checkpoint: apitype.CheckpointV3{
+			Latest: &apitype.DeploymentV3{
+				Resources: []apitype.ResourceV3{
+					{URN: stackURN},
+					{URN: bucketURN, Parent: stackURN},
+					{
+						URN:          childURN,
+						Parent:       bucketURN,
+						Dependencies: []resource.URN{bucketURN},
+						PropertyDependencies: map[resource.PropertyKey][]resource.URN{
+							"bucket": {bucketURN},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestStateRemoveRefusesDependents(t *testing.T) {
+	state := testState()
+	if err := state.Remove(bucketURN); err == nil {
+		t.Fatal("expected removing a resource with dependents to fail")
+	}
+	if err := state.Remove(childURN); err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Resources()) != 2 {
+		t.Fatalf("expected 2 resources, got %d", len(state.Resources()))
+	}
+	if err := state.Remove(childURN); err != ErrResourceNotFound {
+		t.Fatalf("expected ErrResourceNotFound, got %v", err)
+	}
+}
+
+func TestStateMoveRewritesReferences(t *testing.T) {
+	state := testState()
+	renamed := "urn:pulumi:dev::app::sst:aws:Bucket::Uploads"
+	if err := state.Move(bucketURN, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := state.Find(bucketURN); err != ErrResourceNotFound {
+		t.Fatal("expected the old urn to be gone")
+	}
+	child, err := state.Find(childURN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child.Parent != resource.URN(renamed) || child.Dependencies[0] != resource.URN(renamed) || child.PropertyDependencies["bucket"][0] != resource.URN(renamed) {
+		t.Fatalf("references were not updated: %+v", child)
+	}
+	if err := state.Move(renamed, stackURN); err == nil {
+		t.Fatal("expected moving onto an existing urn to fail")
+	}
+}
+
+func TestStateOrphans(t *testing.T) {
+	state := testState()
+	missing := resource.URN("urn:pulumi:dev::app::sst:aws:Function::Old")
+	lost := resource.URN("urn:pulumi:dev::app::sst:aws:Function$aws:lambda/function:Function::OldFunction")
+	lostRole := resource.URN("urn:pulumi:dev::app::sst:aws:Function$aws:lambda/function:Function$aws:iam/role:Role::OldRole")
+	latest := state.checkpoint.Latest
+	latest.Resources = append(latest.Resources,
+		apitype.ResourceV3{URN: bucketURN, Parent: stackURN, ID: "old-bucket", Delete: true},
+		apitype.ResourceV3{URN: lost, Parent: missing, ID: "old-function"},
+		apitype.ResourceV3{URN: lostRole, Parent: lost, ID: "old-role"},
+	)
+	latest.PendingOperations = []apitype.OperationV2{
+		{Resource: apitype.ResourceV3{URN: "urn:pulumi:dev::app::aws:s3/bucketV2:BucketV2::Half"}, Type: apitype.OperationTypeCreating},
+	}
+
+	orphans := state.Orphans()
+	if len(orphans) != 4 {
+		t.Fatalf("expected 4 orphans, got %+v", orphans)
+	}
+	if !orphans[3].Pending {
+		t.Errorf("expected the pending operation last, got %+v", orphans[3])
+	}
+
+	state.RemoveOrphans(orphans)
+	if len(state.Resources()) != 3 {
+		t.Fatalf("expected 3 resources, got %d", len(state.Resources()))
+	}
+	for _, item := range state.Resources() {
+		if item.Delete {
+			t.Errorf("expected %s to be removed", item.URN)
+		}
+	}
+	if len(latest.PendingOperations) != 0 {
+		t.Errorf("expected pending operations to be cleared")
+	}
+	if len(state.Orphans()) != 0 {
+		t.Errorf("expected no orphans left")
+	}
+}
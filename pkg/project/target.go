@@ -0,0 +1,103 @@
+package project
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+var ErrTargetNotFound = fmt.Errorf("target not found")
+
+// resolveTargets expands targets into the URNs to pass to the engine. A
+// target is a URN, a URN with `*` wildcards, or a component name which can
+// also be a glob. Each match brings along the resources it is made of and the
+// resources it depends on so a component can be deployed on its own. Removing
+// a target leaves what it depends on alone, so remove passes in false for
+// dependencies.
+//
+// URNs are passed through even if they are not in the state yet since the
+// engine understands wildcards and a new resource can be targeted by its URN.
+func resolveTargets(resources []apitype.ResourceV3, targets []string, dependencies bool) ([]string, error) {
+	if len(targets) == 0 {
+		return targets, nil
+	}
+	children := map[resource.URN][]resource.URN{}
+	byURN := map[resource.URN]apitype.ResourceV3{}
+	for _, item := range resources {
+		byURN[item.URN] = item
+		if item.Parent != "" {
+			children[item.Parent] = append(children[item.Parent], item.URN)
+		}
+	}
+
+	result := []string{}
+	included := map[resource.URN]bool{}
+	var include func(urn resource.URN)
+	include = func(urn resource.URN) {
+		if included[urn] {
+			return
+		}
+		included[urn] = true
+		result = append(result, string(urn))
+		for _, child := range children[urn] {
+			include(child)
+		}
+		if !dependencies {
+			return
+		}
+		item := byURN[urn]
+		for _, dep := range item.Dependencies {
+			include(dep)
+		}
+		for _, deps := range item.PropertyDependencies {
+			for _, dep := range deps {
+				include(dep)
+			}
+		}
+	}
+
+	for _, target := range targets {
+		target = strings.TrimSpace(target)
+		if target == "" {
+			continue
+		}
+		if strings.HasPrefix(target, "urn:") {
+			pattern := urnPattern(target)
+			for _, item := range resources {
+				if pattern.MatchString(string(item.URN)) {
+					include(item.URN)
+				}
+			}
+			if !included[resource.URN(target)] {
+				result = append(result, target)
+			}
+			continue
+		}
+		matched := false
+		for _, item := range resources {
+			if item.Type == "pulumi:pulumi:Stack" {
+				continue
+			}
+			if ok, _ := path.Match(target, item.URN.Name()); ok {
+				matched = true
+				include(item.URN)
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("%w: %s", ErrTargetNotFound, target)
+		}
+	}
+	return result, nil
+}
+
+func urnPattern(input string) *regexp.Regexp {
+	parts := strings.Split(input, "*")
+	for i, part := range parts {
+		parts[i] = regexp.QuoteMeta(part)
+	}
+	return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$")
+}
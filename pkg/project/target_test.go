@@ -0,0 +1,66 @@
+package project
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+)
+
+func TestResolveTargets(t *testing.T) {
+	const (
+		stack    = "urn:pulumi:dev::app::pulumi:pulumi:Stack::app-dev"
+		bucket   = "urn:pulumi:dev::app::sst:aws:Bucket::Uploads"
+		inner    = "urn:pulumi:dev::app::sst:aws:Bucket$aws:s3/bucketV2:BucketV2::UploadsBucket"
+		api      = "urn:pulumi:dev::app::sst:aws:Function::Api"
+		handler  = "urn:pulumi:dev::app::sst:aws:Function$aws:lambda/function:Function::ApiFunction"
+		cron     = "urn:pulumi:dev::app::sst:aws:Cron::Cleanup"
+		cronRule = "urn:pulumi:dev::app::sst:aws:Cron$aws:cloudwatch/eventRule:EventRule::CleanupRule"
+	)
+	resources := []apitype.ResourceV3{
+		{URN: stack, Type: "pulumi:pulumi:Stack"},
+		{URN: bucket, Parent: stack},
+		{URN: inner, Parent: bucket},
+		{URN: api, Parent: stack},
+		{URN: handler, Parent: api, PropertyDependencies: map[resource.PropertyKey][]resource.URN{
+			"environment": {inner},
+		}},
+		{URN: cron, Parent: stack},
+		{URN: cronRule, Parent: cron},
+	}
+
+	tests := []struct {
+		targets []string
+		want    []string
+	}{
+		{[]string{}, []string{}},
+		{[]string{"Uploads"}, []string{bucket, inner}},
+		{[]string{"Api"}, []string{api, handler, inner}},
+		{[]string{"Clean*"}, []string{cron, cronRule}},
+		{[]string{"urn:pulumi:dev::app::sst:aws:Cron*"}, []string{cron, cronRule, "urn:pulumi:dev::app::sst:aws:Cron*"}},
+		{[]string{"urn:pulumi:dev::app::sst:aws:Queue::New"}, []string{"urn:pulumi:dev::app::sst:aws:Queue::New"}},
+	}
+	for _, test := range tests {
+		got, err := resolveTargets(resources, test.targets, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("resolveTargets(%v) = %v, want %v", test.targets, got, test.want)
+		}
+	}
+
+	got, err := resolveTargets(resources, []string{"Api"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{api, handler}; !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveTargets without dependencies = %v, want %v", got, want)
+	}
+
+	if _, err := resolveTargets(resources, []string{"Missing"}, true); !errors.Is(err, ErrTargetNotFound) {
+		t.Errorf("expected ErrTargetNotFound, got %v", err)
+	}
+}
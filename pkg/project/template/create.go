@@ -1,4 +1,4 @@
-package project
+package template
 
 import (
 	"encoding/json"
@@ -10,7 +10,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
-	"text/template"
+	texttemplate "text/template"
 
 	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/npm"
@@ -116,12 +116,17 @@ func Create(templateName string, home string) ([]string, error) {
 			if packageJson == nil {
 				slog.Info("reading package.json", "file", npmStep.File)
 				f, err := os.Open(npmStep.File)
-				if err != nil {
+				if err != nil && !os.IsNotExist(err) {
 					return nil, err
 				}
-				err = json.NewDecoder(f).Decode(&packageJson)
-				if err != nil {
-					return nil, err
+				if err == nil {
+					err = json.NewDecoder(f).Decode(&packageJson)
+					f.Close()
+					if err != nil {
+						return nil, err
+					}
+				} else {
+					packageJson = util.KeyValuePairs[interface{}]{{Key: "name", Value: directoryName}}
 				}
 				packageJsons[npmStep.File] = packageJson
 			}
@@ -257,7 +262,7 @@ func Create(templateName string, home string) ([]string, error) {
 				name := filepath.Join(".", strings.TrimPrefix(path, templateFilesPath))
 
 				slog.Info("copying template", "path", path)
-				tmpl, err := template.New(path).Parse(string(src))
+				tmpl, err := texttemplate.New(path).Parse(string(src))
 				data := struct {
 					App  string
 					Home string
@@ -0,0 +1,165 @@
+// Package template creates the files for a new app from the templates
+// embedded in the platform.
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sst/ion/platform"
+)
+
+var ErrTemplateNotFound = fmt.Errorf("template not found")
+
+// Template is an entry in templates/index.json. The index is in the order
+// templates are detected and offered in.
+type Template struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// Detect matches a directory for this template if any rule matches
+	Detect []DetectRule `json:"detect"`
+	// Homes maps the homes that can be picked to the template directory for
+	// each. If it is not set the home comes from the framework, aws for now.
+	Homes map[string]string `json:"homes"`
+}
+
+type DetectRule struct {
+	// File is a glob matched against the files in the directory
+	File string `json:"file"`
+	// Contains also requires the file to contain this
+	Contains string `json:"contains"`
+}
+
+func List() ([]*Template, error) {
+	data, err := platform.Templates.ReadFile("templates/index.json")
+	if err != nil {
+		return nil, err
+	}
+	var result []*Template
+	err = json.Unmarshal(data, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func Get(name string) (*Template, error) {
+	templates, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range templates {
+		if item.Name == name {
+			return item, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrTemplateNotFound, name)
+}
+
+// Detect returns the first template that matches dir, or nil if none do.
+func Detect(dir string) (*Template, error) {
+	templates, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, item := range templates {
+		if item.Matches(dir) {
+			return item, nil
+		}
+	}
+	return nil, nil
+}
+
+func (t *Template) Matches(dir string) bool {
+	for _, rule := range t.Detect {
+		matches, _ := filepath.Glob(filepath.Join(dir, rule.File))
+		for _, match := range matches {
+			if rule.Contains == "" {
+				return true
+			}
+			data, err := os.ReadFile(match)
+			if err == nil && strings.Contains(string(data), rule.Contains) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Dir returns the directory under templates/ to create the app from.
+func (t *Template) Dir(home string) string {
+	if dir, ok := t.Homes[home]; ok {
+		return dir
+	}
+	return t.Name
+}
+
+// Changes describes what creating the app from this template does to a
+// project, in the order it happens.
+func (t *Template) Changes(home string) ([]string, error) {
+	data, err := platform.Templates.ReadFile(filepath.Join("templates", t.Dir(home), "preset.json"))
+	if err != nil {
+		return nil, err
+	}
+	var preset preset
+	err = json.Unmarshal(data, &preset)
+	if err != nil {
+		return nil, err
+	}
+	result := []string{}
+	npm := map[string]bool{}
+	for _, step := range preset.Steps {
+		switch step.Type {
+		case "copy":
+			result = append(result, "create an sst.config.ts")
+		case "patch":
+			var patch patchStep
+			if json.Unmarshal(step.Properties, &patch) == nil {
+				result = append(result, "modify the "+patch.File)
+			}
+		case "npm":
+			var npmStep npmStep
+			if json.Unmarshal(step.Properties, &npmStep) == nil && !npm[npmStep.File] {
+				npm[npmStep.File] = true
+				result = append(result, "add sst to "+npmStep.File)
+			}
+		}
+	}
+	return result, nil
+}
+
+// DetectPackageManager returns the package manager used in dir from its lock
+// file or the packageManager field of its package.json. It falls back to npm
+// if there is a package.json and is empty if there is not.
+func DetectPackageManager(dir string) string {
+	for _, item := range []struct {
+		file    string
+		manager string
+	}{
+		{"bun.lockb", "bun"},
+		{"bun.lock", "bun"},
+		{"pnpm-lock.yaml", "pnpm"},
+		{"yarn.lock", "yarn"},
+		{"package-lock.json", "npm"},
+	} {
+		if _, err := os.Stat(filepath.Join(dir, item.file)); err == nil {
+			return item.manager
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return ""
+	}
+	var packageJson struct {
+		PackageManager string `json:"packageManager"`
+	}
+	if json.Unmarshal(data, &packageJson) == nil && packageJson.PackageManager != "" {
+		name, _, _ := strings.Cut(packageJson.PackageManager, "@")
+		return name
+	}
+	return "npm"
+}
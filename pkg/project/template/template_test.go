@@ -0,0 +1,63 @@
+package template
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sst/ion/platform"
+)
+
+func TestList(t *testing.T) {
+	templates, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, item := range templates {
+		dirs := []string{item.Name}
+		if len(item.Homes) > 0 {
+			dirs = []string{}
+			for _, dir := range item.Homes {
+				dirs = append(dirs, dir)
+			}
+		}
+		for _, dir := range dirs {
+			if _, err := platform.Templates.ReadFile(filepath.Join("templates", dir, "preset.json")); err != nil {
+				t.Errorf("%s: %v", item.Name, err)
+			}
+		}
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		files map[string]string
+		want  string
+	}{
+		{map[string]string{"next.config.mjs": "", "package.json": "{}"}, "nextjs"},
+		{map[string]string{"vite.config.ts": `import { vitePlugin } from "@remix-run/dev"`, "package.json": "{}"}, "remix"},
+		{map[string]string{"vite.config.ts": `import analog from "@analogjs/platform"`}, "analog"},
+		{map[string]string{"package.json": `{"devDependencies":{"@types/aws-lambda":"8"}}`}, "api"},
+		{map[string]string{"package.json": "{}"}, "js"},
+		{map[string]string{"README.md": ""}, ""},
+	}
+	for _, test := range tests {
+		dir := t.TempDir()
+		for name, content := range test.files {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		match, err := Detect(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ""
+		if match != nil {
+			got = match.Name
+		}
+		if got != test.want {
+			t.Errorf("%v: got %q, want %q", test.files, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package project
+
+import (
+	"sort"
+	"time"
+
+	"github.com/sst/ion/pkg/project/provider"
+	"github.com/sst/ion/pkg/runtime"
+)
+
+// slowestResources is how many resource operations the timings of an update
+// keep, a large app has thousands of them.
+const slowestResources = 10
+
+// resourceTimer times the resource operations of an update from their pre
+// event to their outputs.
+type resourceTimer struct {
+	started map[string]time.Time
+	ops     map[string]string
+	done    []provider.SummaryTiming
+}
+
+func newResourceTimer() *resourceTimer {
+	return &resourceTimer{
+		started: map[string]time.Time{},
+		ops:     map[string]string{},
+	}
+}
+
+func (t *resourceTimer) start(urn, op string, at time.Time) {
+	t.started[urn] = at
+	t.ops[urn] = op
+}
+
+func (t *resourceTimer) finish(urn string, at time.Time) {
+	started, ok := t.started[urn]
+	if !ok {
+		return
+	}
+	t.done = append(t.done, provider.SummaryTiming{
+		Name:     urn,
+		Op:       t.ops[urn],
+		Duration: at.Sub(started),
+	})
+	delete(t.started, urn)
+	delete(t.ops, urn)
+}
+
+// slowest returns the n longest timings, longest first.
+func slowest(timings []provider.SummaryTiming, n int) []provider.SummaryTiming {
+	result := append([]provider.SummaryTiming{}, timings...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Duration > result[j].Duration
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+func functionTimings(builds []runtime.BuildTiming) []provider.SummaryTiming {
+	result := []provider.SummaryTiming{}
+	for _, build := range builds {
+		result = append(result, provider.SummaryTiming{
+			Name:     build.FunctionID,
+			Op:       build.Runtime,
+			Duration: build.Duration,
+		})
+	}
+	return slowest(result, 0)
+}
@@ -0,0 +1,48 @@
+package project
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+func TestResourceTimer(t *testing.T) {
+	start := time.Unix(0, 0)
+	timer := newResourceTimer()
+	timer.start("urn:a", "create", start)
+	timer.start("urn:b", "update", start.Add(time.Second))
+	timer.finish("urn:b", start.Add(3*time.Second))
+	timer.finish("urn:c", start.Add(3*time.Second))
+	timer.finish("urn:a", start.Add(5*time.Second))
+	if len(timer.done) != 2 {
+		t.Fatalf("Expected 2 operations, got %v", timer.done)
+	}
+	if timer.done[0].Name != "urn:b" || timer.done[0].Op != "update" || timer.done[0].Duration != 2*time.Second {
+		t.Errorf("Unexpected first operation %v", timer.done[0])
+	}
+	if timer.done[1].Duration != 5*time.Second {
+		t.Errorf("Unexpected second operation %v", timer.done[1])
+	}
+	if len(timer.started) != 0 {
+		t.Errorf("Expected no running operations, got %v", timer.started)
+	}
+}
+
+func TestSlowest(t *testing.T) {
+	timings := []provider.SummaryTiming{
+		{Name: "a", Duration: time.Second},
+		{Name: "b", Duration: 3 * time.Second},
+		{Name: "c", Duration: 2 * time.Second},
+	}
+	got := slowest(timings, 2)
+	if len(got) != 2 || got[0].Name != "b" || got[1].Name != "c" {
+		t.Errorf("Unexpected slowest %v", got)
+	}
+	if timings[0].Name != "a" {
+		t.Errorf("Expected the input to be left alone, got %v", timings)
+	}
+	if got := slowest(timings, 0); len(got) != 3 {
+		t.Errorf("Expected all timings, got %v", got)
+	}
+}
@@ -0,0 +1,252 @@
+package project
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/internal/fs"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+// Workspace is a repo with several apps, it is set up with the
+// sst.workspace.json at its root. Apps are referred to by the name of their
+// directory.
+type Workspace struct {
+	Root string `json:"-"`
+	// Apps are globs of the directories of the apps, relative to the root
+	Apps []string `json:"apps"`
+	// Providers are shared by all the apps, an app can override the args of
+	// a provider in its own config
+	Providers map[string]interface{} `json:"providers"`
+	// Dev are the apps `sst dev` runs at the root, all of them if it is not
+	// set
+	Dev []string `json:"dev"`
+}
+
+type WorkspaceApp struct {
+	Name   string
+	Config string
+}
+
+const workspaceFile = "sst.workspace.json"
+
+var ErrWorkspaceApp = fmt.Errorf("app not in workspace")
+
+// DiscoverWorkspace finds the workspace that dir is in. It returns nil if
+// there is none.
+func DiscoverWorkspace(dir string) (*Workspace, error) {
+	path, err := fs.FindUp(dir, workspaceFile)
+	if err != nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var workspace Workspace
+	if err := json.Unmarshal(data, &workspace); err != nil {
+		return nil, util.NewReadableError(err, fmt.Sprintf("The %s is not valid: %v", workspaceFile, err))
+	}
+	workspace.Root = filepath.Dir(path)
+	if workspace.Providers == nil {
+		workspace.Providers = map[string]interface{}{}
+	}
+	normalizeProviders(workspace.Providers)
+	return &workspace, nil
+}
+
+// DiscoverApp finds the config of an app in the workspace of the current
+// directory, like Discover.
+func DiscoverApp(name string) (string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	workspace, err := DiscoverWorkspace(cwd)
+	if err != nil {
+		return "", err
+	}
+	if workspace == nil {
+		return "", util.NewReadableError(nil, fmt.Sprintf("Could not find a %s, --app picks an app of a workspace", workspaceFile))
+	}
+	app, err := workspace.Find(name)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(ResolveWorkingDir(app.Config), 0755); err != nil {
+		return "", err
+	}
+	return app.Config, nil
+}
+
+// workspaceOf returns the workspace the app of the config is in, or nil if
+// it is not one of its apps.
+func workspaceOf(config string) (*Workspace, error) {
+	workspace, err := DiscoverWorkspace(filepath.Dir(config))
+	if err != nil || workspace == nil {
+		return nil, err
+	}
+	apps, err := workspace.List()
+	if err != nil {
+		return nil, err
+	}
+	config, _ = filepath.Abs(config)
+	for _, app := range apps {
+		if path, _ := filepath.Abs(app.Config); path == config {
+			return workspace, nil
+		}
+	}
+	return nil, nil
+}
+
+// List returns the apps of the workspace by name.
+func (w *Workspace) List() ([]WorkspaceApp, error) {
+	seen := map[string]string{}
+	result := []WorkspaceApp{}
+	for _, pattern := range w.Apps {
+		matches, err := filepath.Glob(filepath.Join(w.Root, pattern))
+		if err != nil {
+			return nil, util.NewReadableError(err, fmt.Sprintf("The apps pattern %q in %s is not valid", pattern, workspaceFile))
+		}
+		for _, dir := range matches {
+			config := filepath.Join(dir, "sst.config.ts")
+			if !fs.Exists(config) {
+				continue
+			}
+			name := filepath.Base(dir)
+			if existing, ok := seen[name]; ok {
+				if existing == dir {
+					continue
+				}
+				return nil, util.NewReadableError(nil, fmt.Sprintf("There are two apps named %s in the workspace, in %s and %s. Rename one of the directories.", name, existing, dir))
+			}
+			seen[name] = dir
+			result = append(result, WorkspaceApp{Name: name, Config: config})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// Find returns the app of the workspace with the name.
+func (w *Workspace) Find(name string) (*WorkspaceApp, error) {
+	apps, err := w.List()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, app := range apps {
+		if app.Name == name {
+			return &app, nil
+		}
+		names = append(names, app.Name)
+	}
+	return nil, util.NewReadableError(ErrWorkspaceApp, fmt.Sprintf("There is no %s app in the workspace, the apps are: %s", name, strings.Join(names, ", ")))
+}
+
+// DevApps are the apps `sst dev` runs together.
+func (w *Workspace) DevApps() ([]WorkspaceApp, error) {
+	if len(w.Dev) == 0 {
+		return w.List()
+	}
+	result := []WorkspaceApp{}
+	for _, name := range w.Dev {
+		app, err := w.Find(name)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, *app)
+	}
+	return result, nil
+}
+
+// normalizeProviders turns the shorthands for the args of a provider, true
+// and a version, into their map.
+func normalizeProviders(providers map[string]interface{}) {
+	for name, args := range providers {
+		if argsBool, ok := args.(bool); ok && argsBool {
+			providers[name] = make(map[string]interface{})
+		}
+		if argsString, ok := args.(string); ok {
+			providers[name] = map[string]interface{}{
+				"version": argsString,
+			}
+		}
+	}
+}
+
+// mergeProviders adds the shared providers to those of an app, the args the
+// app sets win.
+func mergeProviders(app map[string]interface{}, shared map[string]interface{}) {
+	for name, args := range shared {
+		sharedArgs, ok := args.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		appArgs, ok := app[name].(map[string]interface{})
+		if !ok {
+			appArgs = map[string]interface{}{}
+			app[name] = appArgs
+		}
+		for key, value := range sharedArgs {
+			if _, ok := appArgs[key]; !ok {
+				appArgs[key] = value
+			}
+		}
+	}
+}
+
+func (p *Project) Workspace() *Workspace {
+	return p.workspace
+}
+
+// WorkspaceOutputs are the outputs of another app of the workspace in the
+// same stage. They are read from its state, so both apps have to use the
+// same home. Secret outputs are left out.
+func (p *Project) WorkspaceOutputs(name string) (map[string]interface{}, error) {
+	if p.workspace == nil {
+		return nil, util.NewReadableError(nil, fmt.Sprintf("The app is not in a workspace, add a %s to reference other apps", workspaceFile))
+	}
+	app, err := p.workspace.Find(name)
+	if err != nil {
+		return nil, err
+	}
+	other, err := New(&ProjectConfig{
+		Version: p.version,
+		Stage:   p.app.Stage,
+		Config:  app.Config,
+	})
+	if err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp("", "sst-workspace-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+	err = provider.PullState(p.home, other.app.Name, p.app.Stage, path)
+	if errors.Is(err, provider.ErrStateNotFound) {
+		return nil, util.NewReadableError(err, fmt.Sprintf("The %s app has not been deployed to the %s stage yet", name, p.app.Stage))
+	}
+	if err != nil {
+		return nil, err
+	}
+	state, err := readState(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.checkpoint.Latest.Resources) == 0 {
+		return map[string]interface{}{}, nil
+	}
+	outputs, _ := decrypt(state.checkpoint.Latest.Resources[0].Outputs).(map[string]interface{})
+	return publicOutputs(outputs), nil
+}
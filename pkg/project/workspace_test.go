@@ -0,0 +1,79 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspace(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, workspaceFile), []byte(`{"apps":["apps/*","infra"],"providers":{"aws":{"region":"us-east-1"},"cloudflare":true}}`), 0644)
+	for _, dir := range []string{"apps/api", "apps/web", "apps/docs", "infra"} {
+		os.MkdirAll(filepath.Join(root, dir), 0755)
+	}
+	// a directory without a config is not an app
+	for _, dir := range []string{"apps/api", "apps/web", "infra"} {
+		os.WriteFile(filepath.Join(root, dir, "sst.config.ts"), []byte(""), 0644)
+	}
+
+	workspace, err := DiscoverWorkspace(filepath.Join(root, "apps", "web"))
+	if err != nil || workspace == nil {
+		t.Fatalf("Expected a workspace, got %v", err)
+	}
+	if workspace.Root != root {
+		t.Errorf("Unexpected root %s", workspace.Root)
+	}
+	apps, err := workspace.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, app := range apps {
+		names = append(names, app.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"api", "infra", "web"}) {
+		t.Errorf("Unexpected apps %v", names)
+	}
+	app, err := workspace.Find("api")
+	if err != nil || app.Config != filepath.Join(root, "apps", "api", "sst.config.ts") {
+		t.Errorf("Unexpected app %+v %v", app, err)
+	}
+	if _, err := workspace.Find("docs"); !errors.Is(err, ErrWorkspaceApp) {
+		t.Errorf("Expected ErrWorkspaceApp, got %v", err)
+	}
+
+	if found, _ := workspaceOf(filepath.Join(root, "infra", "sst.config.ts")); found == nil {
+		t.Error("Expected infra to be in the workspace")
+	}
+	os.WriteFile(filepath.Join(root, "apps", "docs", "sst.config.ts"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(root, workspaceFile), []byte(`{"apps":["apps/api"]}`), 0644)
+	if found, _ := workspaceOf(filepath.Join(root, "apps", "docs", "sst.config.ts")); found != nil {
+		t.Error("Expected an app that is not listed to be left out")
+	}
+
+	if workspace, _ := DiscoverWorkspace(t.TempDir()); workspace != nil {
+		t.Errorf("Expected no workspace, got %+v", workspace)
+	}
+}
+
+func TestMergeProviders(t *testing.T) {
+	shared := map[string]interface{}{
+		"aws":        map[string]interface{}{"region": "us-east-1", "profile": "shared"},
+		"cloudflare": true,
+	}
+	normalizeProviders(shared)
+	app := map[string]interface{}{
+		"aws": map[string]interface{}{"profile": "api"},
+	}
+	mergeProviders(app, shared)
+	want := map[string]interface{}{
+		"aws":        map[string]interface{}{"region": "us-east-1", "profile": "api"},
+		"cloudflare": map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(app, want) {
+		t.Errorf("Expected %v, got %v", want, app)
+	}
+}
@@ -0,0 +1,222 @@
+// Package registry points the downloads of the CLI at a mirror, for machines
+// that cannot reach the public registries.
+//
+// A mirror serves an npm registry under /npm, the Pulumi plugins under
+// /plugins, the Pulumi CLI under /pulumi and Bun under /bun. It also serves a
+// checksums.txt at its root with the sha256 and path of every archive in it,
+// the same format as the checksums of a release, and archives that are not
+// listed in it are not installed.
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sst/ion/pkg/network"
+)
+
+var client = network.Client(0)
+
+var ErrNoChecksum = fmt.Errorf("no checksum in the mirror manifest")
+var ErrChecksumMismatch = fmt.Errorf("checksum mismatch")
+
+// Config is read from registry.json in the config directory of sst, the
+// SST_REGISTRY_MIRROR and SST_REGISTRY_TOKEN environment variables take
+// precedence over it.
+type Config struct {
+	Mirror string `json:"mirror,omitempty"`
+	// Headers are sent with every request to the mirror, like an
+	// Authorization header
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+func configPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sst", "registry.json")
+}
+
+// Load reads the config from path and the environment.
+func Load(path string) (*Config, error) {
+	result := &Config{Headers: map[string]string{}}
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", path, err)
+		}
+	}
+	if result.Headers == nil {
+		result.Headers = map[string]string{}
+	}
+	if mirror := os.Getenv("SST_REGISTRY_MIRROR"); mirror != "" {
+		result.Mirror = mirror
+	}
+	if token := os.Getenv("SST_REGISTRY_TOKEN"); token != "" {
+		result.Headers["Authorization"] = "Bearer " + token
+	}
+	result.Mirror = strings.TrimSuffix(result.Mirror, "/")
+	if result.Mirror != "" {
+		if _, err := url.ParseRequestURI(result.Mirror); err != nil {
+			return nil, fmt.Errorf("invalid registry mirror %q: %w", result.Mirror, err)
+		}
+	}
+	return result, nil
+}
+
+var current = sync.OnceValues(func() (*Config, error) {
+	return Load(configPath())
+})
+
+// Get returns the config of this machine. An invalid config is logged and
+// ignored.
+func Get() *Config {
+	result, err := current()
+	if err != nil {
+		slog.Error("failed to load registry config", "err", err)
+		return &Config{Headers: map[string]string{}}
+	}
+	return result
+}
+
+func (c *Config) Enabled() bool {
+	return c.Mirror != ""
+}
+
+// URL is the address of path in the mirror.
+func (c *Config) URL(path string) string {
+	return c.Mirror + "/" + strings.TrimPrefix(path, "/")
+}
+
+// Authorize adds the headers of the mirror to req if it is sent to the
+// mirror.
+func (c *Config) Authorize(req *http.Request) {
+	if !c.Enabled() || !strings.HasPrefix(req.URL.String(), c.Mirror+"/") {
+		return
+	}
+	for key, value := range c.Headers {
+		req.Header.Set(key, value)
+	}
+}
+
+// Do sends req with the headers of the mirror.
+func (c *Config) Do(req *http.Request) (*http.Response, error) {
+	c.Authorize(req)
+	return client.Do(req)
+}
+
+// Checksums fetches the manifest of the mirror, the sha256 of each archive by
+// its path.
+func (c *Config) Checksums(ctx context.Context) (map[string]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL("checksums.txt"), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status when downloading the mirror manifest: %s", resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return parseChecksums(string(data)), nil
+}
+
+func parseChecksums(data string) map[string]string {
+	result := map[string]string{}
+	for _, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			result[strings.TrimPrefix(fields[1], "/")] = strings.ToLower(fields[0])
+		}
+	}
+	return result
+}
+
+// Checksum returns the sha256 of path from the manifest of the mirror.
+func (c *Config) Checksum(ctx context.Context, path string) (string, error) {
+	checksums, err := c.Checksums(ctx)
+	if err != nil {
+		return "", err
+	}
+	match, ok := checksums[strings.TrimPrefix(path, "/")]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrNoChecksum, path)
+	}
+	return match, nil
+}
+
+// Npmrc is the config for bun and npm to install packages from the mirror.
+// Only a bearer token in the Authorization header can be passed on to them.
+func (c *Config) Npmrc() string {
+	registry := c.URL("npm") + "/"
+	lines := []string{"registry=" + registry}
+	if token, ok := strings.CutPrefix(c.Headers["Authorization"], "Bearer "); ok {
+		parsed, _ := url.Parse(registry)
+		lines = append(lines, "//"+parsed.Host+parsed.Path+":_authToken="+token)
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// Download fetches path from the mirror into a temporary file and checks it
+// against the manifest. The caller removes the file.
+func (c *Config) Download(ctx context.Context, path string) (*os.File, error) {
+	expected, err := c.Checksum(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.URL(path), nil)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("downloading from mirror", "url", req.URL.String())
+	resp, err := c.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status from %s: %s", req.URL, resp.Status)
+	}
+	file, err := os.CreateTemp("", "sst-mirror-*")
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(file, hash), resp.Body); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, fmt.Errorf("%w: %s is %s, expected %s", ErrChecksumMismatch, path, actual, expected)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		os.Remove(file.Name())
+		return nil, err
+	}
+	return file, nil
+}
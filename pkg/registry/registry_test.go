@@ -0,0 +1,89 @@
+package registry
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "registry.json")
+	os.WriteFile(path, []byte(`{"mirror":"https://mirror.example.com/sst/","headers":{"X-Team":"infra"}}`), 0644)
+	t.Setenv("SST_REGISTRY_TOKEN", "secret")
+	config, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Mirror != "https://mirror.example.com/sst" {
+		t.Errorf("Unexpected mirror %s", config.Mirror)
+	}
+	if config.Headers["X-Team"] != "infra" || config.Headers["Authorization"] != "Bearer secret" {
+		t.Errorf("Unexpected headers %v", config.Headers)
+	}
+	want := "registry=https://mirror.example.com/sst/npm/\n//mirror.example.com/sst/npm/:_authToken=secret\n"
+	if got := config.Npmrc(); got != want {
+		t.Errorf("Npmrc() = %q, want %q", got, want)
+	}
+
+	t.Setenv("SST_REGISTRY_MIRROR", "not a url")
+	if _, err := Load(path); err == nil {
+		t.Errorf("Expected an invalid mirror to fail")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	config := &Config{Mirror: "https://mirror.example.com", Headers: map[string]string{"Authorization": "Bearer secret"}}
+	inside, _ := http.NewRequest(http.MethodGet, config.URL("npm/sst"), nil)
+	config.Authorize(inside)
+	if inside.Header.Get("Authorization") == "" {
+		t.Errorf("Expected the headers on a request to the mirror")
+	}
+	outside, _ := http.NewRequest(http.MethodGet, "https://mirror.example.com.evil.dev/npm/sst", nil)
+	config.Authorize(outside)
+	if outside.Header.Get("Authorization") != "" {
+		t.Errorf("Expected no headers on a request to another host")
+	}
+}
+
+func TestDownload(t *testing.T) {
+	archive := []byte("archive")
+	sum := sha256.Sum256(archive)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/checksums.txt":
+			io.WriteString(w, hex.EncodeToString(sum[:])+"  pulumi/pulumi.tar.gz\n"+hex.EncodeToString(make([]byte, 32))+"  bun/bun.zip\n")
+		default:
+			w.Write(archive)
+		}
+	}))
+	defer srv.Close()
+	config := &Config{Mirror: srv.URL, Headers: map[string]string{"Authorization": "Bearer secret"}}
+	ctx := context.Background()
+
+	file, err := config.Download(ctx, "pulumi/pulumi.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	if data, _ := io.ReadAll(file); string(data) != "archive" {
+		t.Errorf("Unexpected content %q", data)
+	}
+	if _, err := config.Download(ctx, "bun/bun.zip"); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+	if _, err := config.Download(ctx, "plugins/missing.tar.gz"); !errors.Is(err, ErrNoChecksum) {
+		t.Errorf("Expected ErrNoChecksum, got %v", err)
+	}
+}
@@ -0,0 +1,399 @@
+package runtime
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/sst/ion/pkg/flag"
+	"github.com/sst/ion/pkg/global"
+	"github.com/sst/ion/pkg/network"
+	"github.com/sst/ion/pkg/project/path"
+)
+
+// InputLister is implemented by runtimes that know which files a build of a
+// function read. Only their builds are cached.
+type InputLister interface {
+	Inputs(functionID string) []string
+}
+
+// cacheManifest is stored under the hash of the build options. It lists the
+// files the last build with those options read, and the output is stored
+// under the hash of their contents.
+type cacheManifest struct {
+	Files   map[string]string `json:"files"`
+	Output  string            `json:"output"`
+	Handler string            `json:"handler"`
+}
+
+// BuildCache keeps the output of deploy builds in the cache dir, and in a
+// bucket if SST_BUILD_CACHE_BUCKET is set so CI machines share it.
+type BuildCache struct {
+	dir    string
+	root   string
+	remote *remoteCache
+}
+
+func NewBuildCache(cfgPath string) *BuildCache {
+	cache := &BuildCache{
+		dir:  filepath.Join(global.CacheDir(), "build"),
+		root: path.ResolveRootDir(cfgPath),
+	}
+	if flag.SST_BUILD_CACHE_BUCKET != "" {
+		remote, err := newRemoteCache(flag.SST_BUILD_CACHE_BUCKET)
+		if err != nil {
+			slog.Error("remote build cache unavailable", "err", err)
+		} else {
+			cache.remote = remote
+		}
+	}
+	return cache
+}
+
+// optionsKey hashes what a build depends on besides the files it reads. The
+// function id and links are left out so identical functions share a build,
+// the links are encrypted into the output after the build.
+func optionsKey(input *BuildInput, version string) (string, error) {
+	options := *input
+	options.CfgPath = ""
+	options.FunctionID = ""
+	options.Links = nil
+	options.EncryptionKey = ""
+	options.CopyFiles = nil
+	options.Container = nil
+	data, err := json.Marshal(options)
+	if err != nil {
+		return "", err
+	}
+	return hashBytes([]byte(version), data), nil
+}
+
+func hashBytes(parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func hashFile(file string) (string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// hashInputs hashes the files a build read, keyed by their path relative to
+// the root of the app so the cache works across checkouts.
+func (c *BuildCache) hashInputs(files []string) (map[string]string, error) {
+	result := map[string]string{}
+	for _, file := range files {
+		hash, err := hashFile(file)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(c.root, file)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			rel = file
+		}
+		result[filepath.ToSlash(rel)] = hash
+	}
+	return result, nil
+}
+
+// outputKey is the hash of the options and every input file.
+func outputKey(options string, files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := [][]byte{[]byte(options)}
+	for _, name := range names {
+		parts = append(parts, []byte(name), []byte(files[name]))
+	}
+	return hashBytes(parts...)
+}
+
+func (c *BuildCache) read(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(c.dir, key))
+	if err == nil {
+		return data, nil
+	}
+	if c.remote == nil {
+		return nil, nil
+	}
+	data, err = c.remote.get(ctx, key)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	c.writeLocal(key, data)
+	return data, nil
+}
+
+func (c *BuildCache) writeLocal(key string, data []byte) error {
+	file := filepath.Join(c.dir, key)
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(file, data, 0644)
+}
+
+func (c *BuildCache) write(ctx context.Context, key string, data []byte) error {
+	if err := c.writeLocal(key, data); err != nil {
+		return err
+	}
+	if c.remote != nil {
+		return c.remote.put(ctx, key, data)
+	}
+	return nil
+}
+
+// Restore unpacks the cached output of a build into out. It returns nil if
+// nothing is cached or an input changed since.
+func (c *BuildCache) Restore(ctx context.Context, options string, out string) (*BuildOutput, error) {
+	data, err := c.read(ctx, filepath.Join("manifests", options+".json"))
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var manifest cacheManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil
+	}
+	for name, expected := range manifest.Files {
+		file := filepath.FromSlash(name)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(c.root, file)
+		}
+		hash, err := hashFile(file)
+		if err != nil || hash != expected {
+			return nil, nil
+		}
+	}
+	if outputKey(options, manifest.Files) != manifest.Output {
+		return nil, nil
+	}
+	archive, err := c.read(ctx, filepath.Join("outputs", manifest.Output+".tar.gz"))
+	if err != nil || archive == nil {
+		return nil, err
+	}
+	if err := untarDir(bytes.NewReader(archive), out); err != nil {
+		return nil, err
+	}
+	return &BuildOutput{
+		Handler: manifest.Handler,
+		Errors:  []string{},
+	}, nil
+}
+
+// Save stores the output of a build along with the files it read.
+func (c *BuildCache) Save(ctx context.Context, options string, inputs []string, out string, result *BuildOutput) error {
+	files, err := c.hashInputs(inputs)
+	if err != nil {
+		return err
+	}
+	key := outputKey(options, files)
+	var archive bytes.Buffer
+	if err := tarDir(out, &archive); err != nil {
+		return err
+	}
+	if err := c.write(ctx, filepath.Join("outputs", key+".tar.gz"), archive.Bytes()); err != nil {
+		return err
+	}
+	manifest, err := json.Marshal(cacheManifest{
+		Files:   files,
+		Output:  key,
+		Handler: result.Handler,
+	})
+	if err != nil {
+		return err
+	}
+	return c.write(ctx, filepath.Join("manifests", options+".json"), manifest)
+}
+
+func tarDir(dir string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	err := filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		// symlinks, like node_modules in dev, are not part of an output
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func untarDir(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, filepath.FromSlash(header.Name))
+		if !strings.HasPrefix(dest, filepath.Clean(dir)+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in build cache: %v", header.Name)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+				return err
+			}
+			f, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(header.Mode).Perm())
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(f, tr)
+			f.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// SourceFiles walks dir for the files match accepts. It does not go into
+// dependencies or the output of other build tools.
+func SourceFiles(dir string, match func(file string) bool) []string {
+	result := []string{}
+	filepath.WalkDir(dir, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if entry.IsDir() {
+			switch entry.Name() {
+			case "node_modules", ".git", ".sst", "target", "obj", ".gradle":
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if match(file) {
+			result = append(result, file)
+		}
+		return nil
+	})
+	return result
+}
+
+type remoteCache struct {
+	client *s3.Client
+	bucket string
+	prefix string
+}
+
+// newRemoteCache takes a bucket, optionally followed by a prefix like
+// my-bucket/sst-cache.
+func newRemoteCache(location string) (*remoteCache, error) {
+	cfg, err := config.LoadDefaultConfig(context.Background(),
+		config.WithHTTPClient(awshttp.NewBuildableClient().WithTransportOptions(network.Apply)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	bucket, prefix, _ := strings.Cut(location, "/")
+	return &remoteCache{
+		client: s3.NewFromConfig(cfg),
+		bucket: bucket,
+		prefix: strings.Trim(prefix, "/"),
+	}, nil
+}
+
+func (r *remoteCache) key(key string) string {
+	return strings.TrimPrefix(r.prefix+"/"+filepath.ToSlash(key), "/")
+}
+
+func (r *remoteCache) get(ctx context.Context, key string) ([]byte, error) {
+	result, err := r.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key(key)),
+	})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		if errors.As(err, &nsk) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer result.Body.Close()
+	return io.ReadAll(result.Body)
+}
+
+func (r *remoteCache) put(ctx context.Context, key string, data []byte) error {
+	_, err := r.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key(key)),
+		Body:   bytes.NewReader(data),
+	})
+	return err
+}
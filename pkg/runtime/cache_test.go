@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuildCache(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	cache := &BuildCache{dir: t.TempDir(), root: root}
+	source := filepath.Join(root, "index.ts")
+	os.WriteFile(source, []byte("export const handler = 1"), 0644)
+
+	out := t.TempDir()
+	os.WriteFile(filepath.Join(out, "bootstrap"), []byte("binary"), 0755)
+	options, err := optionsKey(&BuildInput{Handler: "index.handler", Runtime: "nodejs20.x"}, "1.0.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cache.Save(ctx, options, []string{source}, out, &BuildOutput{Handler: "index.handler"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := t.TempDir()
+	result, err := cache.Restore(ctx, options, restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result == nil || result.Handler != "index.handler" {
+		t.Fatalf("expected a cached build, got %v", result)
+	}
+	stat, err := os.Stat(filepath.Join(restored, "bootstrap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stat.Mode().Perm()&0100 == 0 {
+		t.Error("expected the restored file to keep its mode")
+	}
+
+	other, _ := optionsKey(&BuildInput{Handler: "index.handler", Runtime: "nodejs20.x", FunctionID: "Other"}, "1.0.0")
+	if other != options {
+		t.Error("expected the function id to not be part of the key")
+	}
+	if result, _ := cache.Restore(ctx, other, t.TempDir()); result == nil {
+		t.Error("expected identical functions to share a build")
+	}
+
+	os.WriteFile(source, []byte("export const handler = 2"), 0644)
+	result, err = cache.Restore(ctx, options, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != nil {
+		t.Error("expected a changed input to miss the cache")
+	}
+}
@@ -0,0 +1,169 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project/path"
+)
+
+// ContainerInput is set when a function is deployed as a container image. The
+// image is built from the build output of the function with the Dockerfile,
+// or one generated for the runtime.
+type ContainerInput struct {
+	Dockerfile string `json:"dockerfile"`
+}
+
+var baseImages = []struct {
+	prefix string
+	image  string
+}{
+	{"nodejs", "nodejs"},
+	{"python", "python"},
+	{"java", "java"},
+	{"dotnet", "dotnet"},
+}
+
+// baseImage returns the AWS base image for a runtime, like
+// public.ecr.aws/lambda/nodejs:20 for nodejs20.x. Runtimes that build a
+// bootstrap use the OS only image.
+func baseImage(runtime string) (string, bool) {
+	for _, item := range baseImages {
+		if version, ok := strings.CutPrefix(runtime, item.prefix); ok {
+			return "public.ecr.aws/lambda/" + item.image + ":" + strings.TrimSuffix(version, ".x"), false
+		}
+	}
+	if version, ok := strings.CutPrefix(runtime, "provided."); ok {
+		return "public.ecr.aws/lambda/provided:" + version, true
+	}
+	return "public.ecr.aws/lambda/provided:al2023", true
+}
+
+// Dockerfile generates the Dockerfile of a function that does not have its
+// own.
+func Dockerfile(runtime string, handler string) string {
+	image, provided := baseImage(runtime)
+	lines := []string{
+		"FROM " + image,
+		"COPY . ${LAMBDA_TASK_ROOT}",
+	}
+	if provided {
+		lines = append(lines, "RUN cp ${LAMBDA_TASK_ROOT}/"+handler+" ${LAMBDA_RUNTIME_DIR}/bootstrap")
+	}
+	lines = append(lines, fmt.Sprintf("CMD [%q]", handler))
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// writeDockerfile puts the Dockerfile of a function in its build output so
+// it can be built from there.
+func writeDockerfile(input *BuildInput, out string, handler string) error {
+	dest := filepath.Join(out, "Dockerfile")
+	if input.Container.Dockerfile == "" {
+		return os.WriteFile(dest, []byte(Dockerfile(input.Runtime, handler)), 0644)
+	}
+	source := input.Container.Dockerfile
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(path.ResolveRootDir(input.CfgPath), source)
+	}
+	data, err := os.ReadFile(source)
+	if err != nil {
+		return fmt.Errorf("Dockerfile not found: %v", input.Container.Dockerfile)
+	}
+	return os.WriteFile(dest, data, 0644)
+}
+
+var imageNameInvalid = regexp.MustCompile(`[^a-z0-9_.-]+`)
+
+// buildImage builds the image a function runs in during dev.
+func buildImage(ctx context.Context, input *BuildInput, out string) (string, []string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "", nil, fmt.Errorf("Docker is needed to run container functions in dev")
+	}
+	image := "sst-dev-" + imageNameInvalid.ReplaceAllString(strings.ToLower(input.FunctionID), "-")
+	cmd := exec.CommandContext(ctx, "docker", "build", "--tag", image, out)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	slog.Info("building container", "image", image)
+	if err := cmd.Run(); err != nil {
+		return image, []string{strings.TrimSpace(output.String())}, nil
+	}
+	return image, nil, nil
+}
+
+type containerWorker struct {
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+	cmd    *exec.Cmd
+}
+
+func (w *containerWorker) Stop() {
+	util.TerminateProcess(w.cmd.Process.Pid)
+}
+
+func (w *containerWorker) Logs() io.ReadCloser {
+	reader, writer := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, stream := range []io.Reader{w.stdout, w.stderr} {
+		go func(stream io.Reader) {
+			defer wg.Done()
+			io.Copy(writer, stream)
+		}(stream)
+	}
+	go func() {
+		wg.Wait()
+		writer.Close()
+	}()
+	return reader
+}
+
+// runContainer runs the image of a function pointed at the runtime API of the
+// bridge on this machine. The base images run their runtime client when
+// AWS_LAMBDA_RUNTIME_API is set instead of the emulator.
+func runContainer(ctx context.Context, input *RunInput) (Worker, error) {
+	server := input.Server
+	if rest, ok := strings.CutPrefix(server, "localhost"); ok {
+		server = "host.docker.internal" + rest
+	}
+	args := []string{
+		"run", "--rm",
+		"--add-host", "host.docker.internal:host-gateway",
+		"--env", "AWS_LAMBDA_RUNTIME_API=" + server,
+	}
+	args = append(args, util.DockerEnvArgs(input.Env)...)
+	args = append(args, input.Build.Image, input.Build.Handler)
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Env = append(os.Environ(), input.Env...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &containerWorker{
+		stdout: stdout,
+		stderr: stderr,
+		cmd:    cmd,
+	}, nil
+}
@@ -0,0 +1,21 @@
+package runtime
+
+import "testing"
+
+func TestDockerfile(t *testing.T) {
+	tests := []struct {
+		runtime  string
+		handler  string
+		expected string
+	}{
+		{"nodejs20.x", "index.handler", "FROM public.ecr.aws/lambda/nodejs:20\nCOPY . ${LAMBDA_TASK_ROOT}\nCMD [\"index.handler\"]\n"},
+		{"python3.12", "app.handler", "FROM public.ecr.aws/lambda/python:3.12\nCOPY . ${LAMBDA_TASK_ROOT}\nCMD [\"app.handler\"]\n"},
+		{"java21", "com.example.Handler::handleRequest", "FROM public.ecr.aws/lambda/java:21\nCOPY . ${LAMBDA_TASK_ROOT}\nCMD [\"com.example.Handler::handleRequest\"]\n"},
+		{"rust", "bootstrap", "FROM public.ecr.aws/lambda/provided:al2023\nCOPY . ${LAMBDA_TASK_ROOT}\nRUN cp ${LAMBDA_TASK_ROOT}/bootstrap ${LAMBDA_RUNTIME_DIR}/bootstrap\nCMD [\"bootstrap\"]\n"},
+	}
+	for _, test := range tests {
+		if result := Dockerfile(test.runtime, test.handler); result != test.expected {
+			t.Errorf("Dockerfile(%q) = %q, expected %q", test.runtime, result, test.expected)
+		}
+	}
+}
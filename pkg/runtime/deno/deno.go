@@ -0,0 +1,349 @@
+package deno
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/global"
+	"github.com/sst/ion/pkg/project/path"
+	"github.com/sst/ion/pkg/runtime"
+)
+
+type Worker struct {
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+	cmd    *exec.Cmd
+}
+
+func (w *Worker) Stop() {
+	util.TerminateProcess(w.cmd.Process.Pid)
+}
+
+func (w *Worker) Logs() io.ReadCloser {
+	reader, writer := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, stream := range []io.Reader{w.stdout, w.stderr} {
+		go func(stream io.Reader) {
+			defer wg.Done()
+			io.Copy(writer, stream)
+		}(stream)
+	}
+	go func() {
+		wg.Wait()
+		writer.Close()
+	}()
+	return reader
+}
+
+type Runtime struct {
+	// files are the local modules of every function that was built along
+	// with its deno.json and lockfile
+	files sync.Map
+	// locks is the hash of the config and lockfile of every function the
+	// last time its dependencies were cached
+	locks sync.Map
+}
+
+func New() *Runtime {
+	return &Runtime{}
+}
+
+func (r *Runtime) Match(runtime string) bool {
+	return runtime == "deno"
+}
+
+var extensions = []string{".ts", ".tsx", ".mts", ".js", ".jsx", ".mjs"}
+
+// findHandler resolves a handler like src/index.handler to the file and the
+// name of the export.
+func findHandler(root, handler string) (string, string, error) {
+	ext := filepath.Ext(handler)
+	if ext == "" {
+		return "", "", fmt.Errorf("Handler must be the file followed by the export, like src/index.handler: %v", handler)
+	}
+	base := strings.TrimSuffix(handler, ext)
+	if !filepath.IsAbs(base) {
+		base = filepath.Join(root, base)
+	}
+	for _, ext := range extensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			return base + ext, strings.TrimPrefix(filepath.Ext(handler), "."), nil
+		}
+	}
+	return "", "", fmt.Errorf("Handler not found: %v", handler)
+}
+
+// findConfig returns the closest deno.json to the handler, which has the
+// import map, and the lockfile next to it.
+func findConfig(file, root string) (string, string) {
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		for _, name := range []string{"deno.json", "deno.jsonc"} {
+			config := filepath.Join(dir, name)
+			if _, err := os.Stat(config); err == nil {
+				lock := filepath.Join(dir, "deno.lock")
+				if _, err := os.Stat(lock); err != nil {
+					lock = ""
+				}
+				return config, lock
+			}
+		}
+		if dir == root || dir == filepath.Dir(dir) {
+			return "", ""
+		}
+	}
+}
+
+// bootstrap is the entrypoint of a function, it polls the Lambda runtime API
+// for invocations and passes them to the handler.
+const bootstrap = `import { %s as handler } from %q;
+
+const api = "http://" + Deno.env.get("AWS_LAMBDA_RUNTIME_API") + "/2018-06-01/runtime";
+
+while (true) {
+  const next = await fetch(api + "/invocation/next");
+  const requestId = next.headers.get("lambda-runtime-aws-request-id");
+  const deadline = Number(next.headers.get("lambda-runtime-deadline-ms"));
+  const event = await next.json();
+  const context = {
+    awsRequestId: requestId,
+    invokedFunctionArn: next.headers.get("lambda-runtime-invoked-function-arn"),
+    functionName: Deno.env.get("AWS_LAMBDA_FUNCTION_NAME"),
+    getRemainingTimeInMillis: () => deadline - Date.now(),
+  };
+  try {
+    const result = await handler(event, context);
+    await fetch(api + "/invocation/" + requestId + "/response", {
+      method: "POST",
+      body: JSON.stringify(result ?? null),
+    });
+  } catch (error) {
+    await fetch(api + "/invocation/" + requestId + "/error", {
+      method: "POST",
+      headers: { "Lambda-Runtime-Function-Error-Type": "Unhandled" },
+      body: JSON.stringify({
+        errorType: error?.name ?? "Error",
+        errorMessage: error?.message ?? String(error),
+        stackTrace: error?.stack?.split("\n") ?? [],
+      }),
+    });
+  }
+}
+`
+
+func target(architecture string) string {
+	if architecture == "arm64" {
+		return "aarch64-unknown-linux-gnu"
+	}
+	return "x86_64-unknown-linux-gnu"
+}
+
+func (r *Runtime) command(ctx context.Context, dir string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "deno", args...)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Dir = dir
+	// the cache is shared by every app on this machine
+	cmd.Env = append(os.Environ(), "DENO_DIR="+filepath.Join(global.ConfigDir(), "deno"), "NO_COLOR=1")
+	return cmd
+}
+
+// configArgs are the flags that point deno at the config and lockfile.
+func configArgs(config, lock string) []string {
+	args := []string{}
+	if config != "" {
+		args = append(args, "--config", config)
+	}
+	if lock != "" {
+		args = append(args, "--lock", lock)
+	}
+	return args
+}
+
+func hashFiles(files ...string) string {
+	hash := sha256.New()
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		data, _ := os.ReadFile(file)
+		hash.Write([]byte(file))
+		hash.Write(data)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtime.BuildOutput, error) {
+	root := path.ResolveRootDir(input.CfgPath)
+	file, export, err := findHandler(root, input.Handler)
+	if err != nil {
+		return nil, err
+	}
+	config, lock := findConfig(file, root)
+	dir := filepath.Dir(file)
+	if config != "" {
+		dir = filepath.Dir(config)
+	}
+
+	entry := filepath.Join(input.Out(), "bootstrap.ts")
+	specifier := (&url.URL{Scheme: "file", Path: filepath.ToSlash(file)}).String()
+	err = os.WriteFile(entry, []byte(fmt.Sprintf(bootstrap, export, specifier)), 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd *exec.Cmd
+	handler := "bootstrap.ts"
+	hash := ""
+	if input.Dev {
+		// dependencies are only fetched again when the config or lockfile
+		// changes, new imports are fetched by deno when the function runs
+		hash = hashFiles(config, lock)
+		if previous, ok := r.locks.Load(input.FunctionID); !ok || previous != hash {
+			cmd = r.command(ctx, dir, append(append([]string{"cache"}, configArgs(config, lock)...), entry)...)
+		}
+	} else {
+		handler = "bootstrap"
+		args := append([]string{"compile", "--allow-all", "--target", target(input.Architecture), "--output", filepath.Join(input.Out(), "bootstrap")}, configArgs(config, lock)...)
+		cmd = r.command(ctx, dir, append(args, entry)...)
+	}
+	if cmd != nil {
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		slog.Info("building deno function", "args", cmd.Args)
+		if err := cmd.Run(); err != nil {
+			return &runtime.BuildOutput{Handler: handler, Errors: []string{strings.TrimSpace(output.String())}}, nil
+		}
+	}
+
+	files := []string{config, lock}
+	info := r.command(ctx, dir, append(append([]string{"info", "--json"}, configArgs(config, lock)...), entry)...)
+	var stderr bytes.Buffer
+	info.Stderr = &stderr
+	data, err := info.Output()
+	if err != nil {
+		return &runtime.BuildOutput{Handler: handler, Errors: []string{strings.TrimSpace(stderr.String())}}, nil
+	}
+	local, errors, err := parseModuleGraph(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(errors) > 0 {
+		return &runtime.BuildOutput{Handler: handler, Errors: errors}, nil
+	}
+	r.files.Store(input.FunctionID, append(files, local...))
+
+	if input.Dev {
+		r.locks.Store(input.FunctionID, hash)
+	} else {
+		os.Remove(entry)
+	}
+	return &runtime.BuildOutput{
+		Handler: handler,
+		Errors:  []string{},
+	}, nil
+}
+
+type moduleGraph struct {
+	Modules []struct {
+		Specifier string `json:"specifier"`
+		Error     string `json:"error"`
+	} `json:"modules"`
+}
+
+// parseModuleGraph reads the output of deno info for the local files a
+// function imports and the modules that could not be resolved.
+func parseModuleGraph(data []byte) ([]string, []string, error) {
+	var graph moduleGraph
+	err := json.Unmarshal(data, &graph)
+	if err != nil {
+		return nil, nil, err
+	}
+	files := []string{}
+	errors := []string{}
+	for _, module := range graph.Modules {
+		if module.Error != "" {
+			errors = append(errors, module.Error)
+			continue
+		}
+		parsed, err := url.Parse(module.Specifier)
+		if err != nil || parsed.Scheme != "file" {
+			continue
+		}
+		files = append(files, filepath.FromSlash(parsed.Path))
+	}
+	return files, errors, nil
+}
+
+func (r *Runtime) Run(ctx context.Context, input *runtime.RunInput) (runtime.Worker, error) {
+	cmd := exec.CommandContext(ctx, "deno", "run", "--allow-all", filepath.Join(input.Build.Out, input.Build.Handler))
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Env = append(input.Env,
+		"AWS_LAMBDA_RUNTIME_API="+input.Server,
+		"DENO_DIR="+filepath.Join(global.ConfigDir(), "deno"),
+	)
+	cmd.Dir = input.Build.Out
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{
+		stdout: stdout,
+		stderr: stderr,
+		cmd:    cmd,
+	}, nil
+}
+
+// ShouldRebuild is true for the modules a function imports and its deno.json
+// and lockfile.
+func (r *Runtime) ShouldRebuild(functionID string, file string) bool {
+	files, ok := r.files.Load(functionID)
+	if !ok {
+		return false
+	}
+	for _, match := range files.([]string) {
+		if match != "" && match == file {
+			return true
+		}
+	}
+	return false
+}
+
+// Inputs are the local modules of a function and its deno.json and lockfile.
+// Remote modules are pinned by the lockfile.
+func (r *Runtime) Inputs(functionID string) []string {
+	files, ok := r.files.Load(functionID)
+	if !ok {
+		return nil
+	}
+	result := []string{}
+	for _, file := range files.([]string) {
+		if file != "" {
+			result = append(result, file)
+		}
+	}
+	return result
+}
@@ -0,0 +1,51 @@
+package deno
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseModuleGraph(t *testing.T) {
+	data := []byte(`{
+  "roots": ["file:///app/.sst/artifacts/api-dev/bootstrap.ts"],
+  "modules": [
+    {"kind": "esm", "specifier": "file:///app/src/index.ts", "local": "/app/src/index.ts"},
+    {"kind": "npm", "specifier": "npm:hono@4"},
+    {"kind": "esm", "specifier": "https://deno.land/std/path/mod.ts"},
+    {"specifier": "file:///app/src/missing.ts", "error": "Module not found \"file:///app/src/missing.ts\"."}
+  ]
+}`)
+	files, errors, err := parseModuleGraph(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0] != filepath.FromSlash("/app/src/index.ts") {
+		t.Errorf("unexpected files %q", files)
+	}
+	if len(errors) != 1 {
+		t.Errorf("unexpected errors %q", errors)
+	}
+}
+
+func TestFindHandler(t *testing.T) {
+	root := t.TempDir()
+	os.MkdirAll(filepath.Join(root, "src"), 0755)
+	os.WriteFile(filepath.Join(root, "src", "index.ts"), []byte("export const handler = () => {}\n"), 0644)
+	os.WriteFile(filepath.Join(root, "deno.json"), []byte("{}\n"), 0644)
+
+	file, export, err := findHandler(root, "src/index.handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file != filepath.Join(root, "src", "index.ts") || export != "handler" {
+		t.Errorf("unexpected handler %q %q", file, export)
+	}
+	config, lock := findConfig(file, root)
+	if config != filepath.Join(root, "deno.json") || lock != "" {
+		t.Errorf("unexpected config %q %q", config, lock)
+	}
+	if _, _, err := findHandler(root, "src/missing.handler"); err == nil {
+		t.Error("expected an error for a missing handler")
+	}
+}
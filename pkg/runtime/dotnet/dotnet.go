@@ -0,0 +1,226 @@
+package dotnet
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project/path"
+	"github.com/sst/ion/pkg/runtime"
+)
+
+type Worker struct {
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+	cmd    *exec.Cmd
+}
+
+func (w *Worker) Stop() {
+	util.TerminateProcess(w.cmd.Process.Pid)
+}
+
+func (w *Worker) Logs() io.ReadCloser {
+	reader, writer := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, stream := range []io.Reader{w.stdout, w.stderr} {
+		go func(stream io.Reader) {
+			defer wg.Done()
+			io.Copy(writer, stream)
+		}(stream)
+	}
+	go func() {
+		wg.Wait()
+		writer.Close()
+	}()
+	return reader
+}
+
+type Runtime struct {
+	// projects is the directory of the project of every function that was
+	// built, to know which ones a changed file affects
+	projects sync.Map
+}
+
+func New() *Runtime {
+	return &Runtime{}
+}
+
+func (r *Runtime) Match(runtime string) bool {
+	return strings.HasPrefix(runtime, "dotnet")
+}
+
+// splitHandler splits a handler like packages/api/Api::Api.Function::Handler
+// into the directory of the project and the handler Lambda calls.
+func splitHandler(handler string) (string, string, error) {
+	index := strings.LastIndex(handler, "/")
+	if index == -1 || !strings.Contains(handler[index+1:], "::") {
+		return "", "", fmt.Errorf("Handler must be the directory of the project followed by Assembly::Namespace.Class::Method: %v", handler)
+	}
+	return handler[:index], handler[index+1:], nil
+}
+
+// rid is the runtime identifier dotnet publishes for on an architecture.
+func rid(architecture string) string {
+	if architecture == "arm64" {
+		return "linux-arm64"
+	}
+	return "linux-x64"
+}
+
+// parseBuildErrors picks the errors out of the output of msbuild. Every error
+// is printed again in the summary at the end so they are only kept once.
+func parseBuildErrors(output string) []string {
+	result := []string{}
+	seen := map[string]bool{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.Contains(line, ": error ") {
+			continue
+		}
+		// the project is appended in brackets, it is noise next to the path
+		if index := strings.LastIndex(line, " ["); index != -1 && strings.HasSuffix(line, "]") {
+			line = line[:index]
+		}
+		if seen[line] {
+			continue
+		}
+		seen[line] = true
+		result = append(result, line)
+	}
+	return result
+}
+
+func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtime.BuildOutput, error) {
+	project, handler, err := splitHandler(input.Handler)
+	if err != nil {
+		return nil, err
+	}
+	dir := project
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(path.ResolveRootDir(input.CfgPath), dir)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("Project not found: %v", project)
+	}
+	r.projects.Store(input.FunctionID, dir)
+
+	// obj and bin stay in the project so restores and builds are incremental,
+	// only the published output goes to the function
+	args := []string{"publish", "--configuration", "Release", "--output", input.Out(), "--nologo"}
+	if !input.Dev {
+		args = append(args, "--runtime", rid(input.Architecture), "--self-contained", "false")
+	}
+	cmd := exec.CommandContext(ctx, "dotnet", args...)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "DOTNET_CLI_TELEMETRY_OPTOUT=1", "DOTNET_NOLOGO=1")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	slog.Info("building dotnet function", "args", cmd.Args)
+	err = cmd.Run()
+	if err != nil {
+		errors := parseBuildErrors(output.String())
+		if len(errors) == 0 {
+			errors = append(errors, strings.TrimSpace(output.String()))
+		}
+		return &runtime.BuildOutput{Handler: handler, Errors: errors}, nil
+	}
+	return &runtime.BuildOutput{
+		Handler: handler,
+		Errors:  []string{},
+	}, nil
+}
+
+// Run starts the function the way the Lambda bootstrap does, with
+// Amazon.Lambda.RuntimeSupport which has to be a dependency of the function
+// to run it in dev.
+func (r *Runtime) Run(ctx context.Context, input *runtime.RunInput) (runtime.Worker, error) {
+	assembly, _, _ := strings.Cut(input.Build.Handler, "::")
+	support := filepath.Join(input.Build.Out, "Amazon.Lambda.RuntimeSupport.dll")
+	if _, err := os.Stat(support); err != nil {
+		return nil, fmt.Errorf("Add the Amazon.Lambda.RuntimeSupport package to %v to run it in dev", assembly)
+	}
+	cmd := exec.CommandContext(ctx,
+		"dotnet",
+		"exec",
+		"--depsfile", filepath.Join(input.Build.Out, assembly+".deps.json"),
+		"--runtimeconfig", filepath.Join(input.Build.Out, assembly+".runtimeconfig.json"),
+		support,
+		input.Build.Handler,
+	)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Env = append(input.Env, "AWS_LAMBDA_RUNTIME_API="+input.Server)
+	cmd.Dir = input.Build.Out
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{
+		stdout: stdout,
+		stderr: stderr,
+		cmd:    cmd,
+	}, nil
+}
+
+var sourceExtensions = map[string]bool{
+	".cs":     true,
+	".fs":     true,
+	".vb":     true,
+	".csproj": true,
+	".fsproj": true,
+	".vbproj": true,
+	".props":  true,
+	".json":   true,
+}
+
+// ShouldRebuild is true for the sources and project files of the project of
+// a function, the build output in bin and obj is left alone.
+func (r *Runtime) ShouldRebuild(functionID string, file string) bool {
+	dir, ok := r.projects.Load(functionID)
+	if !ok {
+		return false
+	}
+	rel, err := filepath.Rel(dir.(string), file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	for _, skip := range []string{"bin", "obj"} {
+		if strings.HasPrefix(rel, skip+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return sourceExtensions[filepath.Ext(file)]
+}
+
+// Inputs are the sources and build files of the project of a function.
+func (r *Runtime) Inputs(functionID string) []string {
+	dir, ok := r.projects.Load(functionID)
+	if !ok {
+		return nil
+	}
+	return runtime.SourceFiles(dir.(string), func(file string) bool {
+		return r.ShouldRebuild(functionID, file)
+	})
+}
@@ -0,0 +1,32 @@
+package dotnet
+
+import "testing"
+
+func TestSplitHandler(t *testing.T) {
+	dir, handler, err := splitHandler("packages/api/Api::Api.Function::Handler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "packages/api" || handler != "Api::Api.Function::Handler" {
+		t.Errorf("unexpected split %q %q", dir, handler)
+	}
+	if _, _, err := splitHandler("packages/api/Api.Function"); err == nil {
+		t.Error("expected an error without an assembly")
+	}
+}
+
+func TestParseBuildErrors(t *testing.T) {
+	output := `  Determining projects to restore...
+/app/Function.cs(12,31): error CS1002: ; expected [/app/Api.csproj]
+/app/Function.cs(3,7): warning CS8019: Unnecessary using directive. [/app/Api.csproj]
+
+Build FAILED.
+
+/app/Function.cs(12,31): error CS1002: ; expected [/app/Api.csproj]
+    0 Warning(s)
+    1 Error(s)`
+	errors := parseBuildErrors(output)
+	if len(errors) != 1 || errors[0] != "/app/Function.cs(12,31): error CS1002: ; expected" {
+		t.Errorf("unexpected errors %q", errors)
+	}
+}
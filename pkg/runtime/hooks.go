@@ -0,0 +1,65 @@
+package runtime
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project/path"
+)
+
+// BuildHooks are commands that run around the build of a function, like
+// codegen before it is bundled or post processing of the output.
+type BuildHooks struct {
+	PreBuild  []string `json:"preBuild"`
+	PostBuild []string `json:"postBuild"`
+}
+
+// hookEnv is the build context passed to every hook.
+func (c *Collection) hookEnv(input *BuildInput, out string) []string {
+	return []string{
+		"SST_APP=" + c.App,
+		"SST_STAGE=" + c.Stage,
+		"SST_FUNCTION_ID=" + input.FunctionID,
+		"SST_HANDLER=" + input.Handler,
+		"SST_RUNTIME=" + input.Runtime,
+		"SST_OUT=" + out,
+		fmt.Sprintf("SST_DEV=%v", input.Dev),
+	}
+}
+
+// runHooks runs the commands in order from the root of the app and stops at
+// the first one that fails. Failures are returned as build errors so they are
+// reported like any other.
+func (c *Collection) runHooks(ctx context.Context, name string, commands []string, input *BuildInput, out string) []string {
+	for _, command := range commands {
+		slog.Info("running build hook", "hook", name, "functionID", input.FunctionID, "command", command)
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		util.SetProcessGroupID(cmd)
+		util.SetProcessCancel(cmd)
+		cmd.Dir = path.ResolveRootDir(input.CfgPath)
+		cmd.Env = append(os.Environ(), c.hookEnv(input, out)...)
+		var output bytes.Buffer
+		cmd.Stdout = &output
+		cmd.Stderr = &output
+		if err := cmd.Run(); err != nil {
+			return hookErrors(name, command, err, output.String())
+		}
+	}
+	return nil
+}
+
+func hookErrors(name, command string, err error, output string) []string {
+	result := []string{fmt.Sprintf("%s hook `%s` failed: %v", name, command, err)}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if line != "" {
+			result = append(result, line)
+		}
+	}
+	return result
+}
@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunHooks(t *testing.T) {
+	root := t.TempDir()
+	cfgPath := filepath.Join(root, "sst.config.ts")
+	out := filepath.Join(root, "out")
+	if err := os.MkdirAll(out, 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := &Collection{App: "app", Stage: "dev"}
+	input := &BuildInput{CfgPath: cfgPath, FunctionID: "MyFunction", Handler: "src/index.handler"}
+
+	errors := c.runHooks(context.Background(), "postBuild", []string{
+		`echo "$SST_APP $SST_STAGE $SST_FUNCTION_ID $SST_HANDLER" > "$SST_OUT/context"`,
+	}, input, out)
+	if len(errors) > 0 {
+		t.Fatalf("unexpected errors: %v", errors)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "context"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result := strings.TrimSpace(string(data)); result != "app dev MyFunction src/index.handler" {
+		t.Errorf("context = %q", result)
+	}
+
+	errors = c.runHooks(context.Background(), "preBuild", []string{
+		"echo generating; echo no schema >&2; exit 3",
+		"touch " + filepath.Join(out, "skipped"),
+	}, input, out)
+	expected := []string{"preBuild hook `echo generating; echo no schema >&2; exit 3` failed: exit status 3", "generating", "no schema"}
+	if strings.Join(errors, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("errors = %q, expected %q", errors, expected)
+	}
+	if _, err := os.Stat(filepath.Join(out, "skipped")); err == nil {
+		t.Error("hooks after a failure should not run")
+	}
+}
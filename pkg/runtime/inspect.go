@@ -0,0 +1,206 @@
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/project/path"
+)
+
+// Inspector is implemented by runtimes whose workers can listen for a
+// debugger. Their Run starts the debugger when RunInput.Inspect is set.
+type Inspector interface {
+	// LaunchConfig is the VS Code configuration that attaches to a worker of
+	// input listening on port
+	LaunchConfig(input *BuildInput, port int) map[string]interface{}
+}
+
+// InspectPort is the port of the first function, the default of node.
+const InspectPort = 9229
+
+// InspectEvent is published when a worker starts listening for a debugger.
+type InspectEvent struct {
+	FunctionID string
+	WorkerID   string
+	Port       int
+}
+
+// Inspection is the debugger port of a function. Ports are kept across
+// sessions so launch configurations keep working.
+type Inspection struct {
+	Runtime string                 `json:"runtime"`
+	Port    int                    `json:"port"`
+	Config  map[string]interface{} `json:"config"`
+}
+
+func inspectPath(cfgPath string) string {
+	return filepath.Join(path.ResolveWorkingDir(cfgPath), "inspect.json")
+}
+
+// LoadInspections returns the debugger ports of every function that has
+// been run with `sst dev --inspect`.
+func LoadInspections(cfgPath string) (map[string]*Inspection, error) {
+	result := map[string]*Inspection{}
+	data, err := os.ReadFile(inspectPath(cfgPath))
+	if os.IsNotExist(err) {
+		return result, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(data, &result)
+	return result, err
+}
+
+type inspector struct {
+	mu          sync.Mutex
+	cfgPath     string
+	inspections map[string]*Inspection
+	// workers has the worker listening on the port of each function, a port
+	// can only be used by one worker at a time
+	workers map[string]string
+}
+
+// EnableInspect makes workers of runtimes that support it listen for a
+// debugger, each function on a port of its own.
+func (c *Collection) EnableInspect() error {
+	inspections, err := LoadInspections(c.cfgPath)
+	if err != nil {
+		return err
+	}
+	c.inspector = &inspector{
+		cfgPath:     c.cfgPath,
+		inspections: inspections,
+		workers:     map[string]string{},
+	}
+	return nil
+}
+
+// assign picks the port of the function if it does not have one yet.
+func (i *inspector) assign(runtime Inspector, input *BuildInput) *Inspection {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	match, ok := i.inspections[input.FunctionID]
+	if ok && match.Runtime == input.Runtime {
+		return match
+	}
+	used := map[int]bool{}
+	for _, item := range i.inspections {
+		used[item.Port] = true
+	}
+	port := InspectPort
+	for ; used[port] || !portFree(port); port++ {
+	}
+	match = &Inspection{
+		Runtime: input.Runtime,
+		Port:    port,
+		Config:  runtime.LaunchConfig(input, port),
+	}
+	i.inspections[input.FunctionID] = match
+	data, err := json.MarshalIndent(i.inspections, "", "  ")
+	if err == nil {
+		err = os.WriteFile(inspectPath(i.cfgPath), data, 0644)
+	}
+	if err != nil {
+		slog.Error("failed to save inspect ports", "err", err)
+	}
+	return match
+}
+
+func portFree(port int) bool {
+	listener, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		return false
+	}
+	listener.Close()
+	return true
+}
+
+// acquire returns the port for a new worker of the function, or 0 if
+// another worker of it is already listening.
+func (i *inspector) acquire(runtime Inspector, input *BuildInput, workerID string) int {
+	port := i.assign(runtime, input).Port
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if _, ok := i.workers[input.FunctionID]; ok {
+		return 0
+	}
+	i.workers[input.FunctionID] = workerID
+	return port
+}
+
+func (i *inspector) release(functionID string, workerID string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.workers[functionID] == workerID {
+		delete(i.workers, functionID)
+	}
+}
+
+// inspectedWorker frees the port of its function once it stops or exits.
+type inspectedWorker struct {
+	Worker
+	once    sync.Once
+	release func()
+}
+
+func (w *inspectedWorker) Stop() {
+	w.Worker.Stop()
+	w.once.Do(w.release)
+}
+
+func (w *inspectedWorker) Logs() io.ReadCloser {
+	return &releasingReader{w.Worker.Logs(), w}
+}
+
+type releasingReader struct {
+	io.ReadCloser
+	worker *inspectedWorker
+}
+
+func (r *releasingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if err != nil {
+		r.worker.once.Do(r.worker.release)
+	}
+	return n, err
+}
+
+// inspect sets the debugger port of a worker that is about to run, if
+// inspecting is enabled and the runtime supports it. The worker it started is
+// passed to the returned function.
+func (c *Collection) inspect(r Runtime, input *RunInput) func(Worker) Worker {
+	none := func(worker Worker) Worker { return worker }
+	if c.inspector == nil {
+		return none
+	}
+	runtime, ok := r.(Inspector)
+	target, exists := c.targets[input.FunctionID]
+	if !ok || !exists {
+		return none
+	}
+	input.Inspect = c.inspector.acquire(runtime, target, input.WorkerID)
+	if input.Inspect == 0 {
+		return none
+	}
+	return func(worker Worker) Worker {
+		release := func() { c.inspector.release(input.FunctionID, input.WorkerID) }
+		if worker == nil {
+			release()
+			return nil
+		}
+		bus.Publish(&InspectEvent{
+			FunctionID: input.FunctionID,
+			WorkerID:   input.WorkerID,
+			Port:       input.Inspect,
+		})
+		return &inspectedWorker{Worker: worker, release: release}
+	}
+}
@@ -0,0 +1,55 @@
+package runtime
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type testInspector struct{}
+
+func (testInspector) LaunchConfig(input *BuildInput, port int) map[string]interface{} {
+	return map[string]interface{}{"type": "test", "port": port}
+}
+
+func TestInspectPorts(t *testing.T) {
+	root := t.TempDir()
+	cfgPath := filepath.Join(root, "sst.config.ts")
+	if err := os.MkdirAll(filepath.Join(root, ".sst"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := &Collection{cfgPath: cfgPath}
+	if err := c.EnableInspect(); err != nil {
+		t.Fatal(err)
+	}
+	first := c.inspector.assign(testInspector{}, &BuildInput{FunctionID: "A", Runtime: "nodejs20.x"})
+	second := c.inspector.assign(testInspector{}, &BuildInput{FunctionID: "B", Runtime: "nodejs20.x"})
+	if first.Port == second.Port {
+		t.Fatalf("Expected different ports, got %v", first.Port)
+	}
+
+	// ports are kept across sessions
+	inspections, err := LoadInspections(cfgPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inspections["A"].Port != first.Port || inspections["B"].Port != second.Port {
+		t.Errorf("Expected saved ports, got %v and %v", inspections["A"].Port, inspections["B"].Port)
+	}
+
+	input := &BuildInput{FunctionID: "A", Runtime: "nodejs20.x"}
+	if port := c.inspector.acquire(testInspector{}, input, "worker1"); port != first.Port {
+		t.Errorf("Expected port %v, got %v", first.Port, port)
+	}
+	if port := c.inspector.acquire(testInspector{}, input, "worker2"); port != 0 {
+		t.Errorf("Expected no port while another worker listens, got %v", port)
+	}
+	c.inspector.release("A", "worker2")
+	if port := c.inspector.acquire(testInspector{}, input, "worker3"); port != 0 {
+		t.Errorf("Expected the port to stay with worker1, got %v", port)
+	}
+	c.inspector.release("A", "worker1")
+	if port := c.inspector.acquire(testInspector{}, input, "worker3"); port != first.Port {
+		t.Errorf("Expected port %v after release, got %v", first.Port, port)
+	}
+}
@@ -0,0 +1,347 @@
+package java
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project/path"
+	"github.com/sst/ion/pkg/runtime"
+)
+
+type Worker struct {
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+	cmd    *exec.Cmd
+}
+
+func (w *Worker) Stop() {
+	util.TerminateProcess(w.cmd.Process.Pid)
+}
+
+func (w *Worker) Logs() io.ReadCloser {
+	reader, writer := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, stream := range []io.Reader{w.stdout, w.stderr} {
+		go func(stream io.Reader) {
+			defer wg.Done()
+			io.Copy(writer, stream)
+		}(stream)
+	}
+	go func() {
+		wg.Wait()
+		writer.Close()
+	}()
+	return reader
+}
+
+type Runtime struct {
+	// projects is the directory of the project of every function that was
+	// built, to know which ones a changed file affects
+	projects sync.Map
+}
+
+func New() *Runtime {
+	return &Runtime{}
+}
+
+func (r *Runtime) Match(runtime string) bool {
+	return strings.HasPrefix(runtime, "java")
+}
+
+func (r *Runtime) LaunchConfig(input *runtime.BuildInput, port int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":     "java",
+		"request":  "attach",
+		"hostName": "127.0.0.1",
+		"port":     port,
+	}
+}
+
+// splitHandler splits a handler like packages/api/com.example.Handler::handleRequest
+// into the directory of the project and the handler Lambda calls.
+func splitHandler(handler string) (string, string, error) {
+	index := strings.LastIndex(handler, "/")
+	if index == -1 {
+		return "", "", fmt.Errorf("Handler must start with the directory of the project, like packages/api/com.example.Handler::handleRequest: %v", handler)
+	}
+	return handler[:index], handler[index+1:], nil
+}
+
+type buildTool struct {
+	name string
+	cmd  string
+	args []string
+	// outputs are the globs the packaged function is looked for in, in order
+	outputs []string
+}
+
+// findBuildTool picks the build of a project, the Maven or Gradle wrapper in
+// the project is preferred over the one on the PATH.
+func findBuildTool(dir string) (*buildTool, error) {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+	switch {
+	case exists("pom.xml"):
+		tool := &buildTool{
+			name:    "maven",
+			cmd:     "mvn",
+			args:    []string{"--batch-mode", "package", "-DskipTests"},
+			outputs: []string{"target/*.zip", "target/*.jar"},
+		}
+		if exists("mvnw") {
+			tool.cmd = filepath.Join(dir, "mvnw")
+		}
+		return tool, nil
+	case exists("build.gradle"), exists("build.gradle.kts"):
+		tool := &buildTool{
+			name:    "gradle",
+			cmd:     "gradle",
+			args:    []string{"build", "--console=plain", "-x", "test"},
+			outputs: []string{"build/distributions/*.zip", "build/libs/*-all.jar", "build/libs/*.jar"},
+		}
+		if exists("gradlew") {
+			tool.cmd = filepath.Join(dir, "gradlew")
+		}
+		return tool, nil
+	}
+	return nil, fmt.Errorf("No pom.xml or build.gradle found in %v", dir)
+}
+
+// findPackage returns the archive the build produced. Sources, javadoc, and
+// the original jar the shade plugin leaves behind are skipped, and of the
+// rest the largest is the one with the dependencies in it.
+func findPackage(dir string, outputs []string) (string, error) {
+	for _, glob := range outputs {
+		matches, err := filepath.Glob(filepath.Join(dir, glob))
+		if err != nil {
+			return "", err
+		}
+		candidates := []string{}
+		sizes := map[string]int64{}
+		for _, match := range matches {
+			base := filepath.Base(match)
+			if strings.HasPrefix(base, "original-") ||
+				strings.HasSuffix(base, "-sources.jar") ||
+				strings.HasSuffix(base, "-javadoc.jar") ||
+				strings.HasSuffix(base, "-plain.jar") {
+				continue
+			}
+			stat, err := os.Stat(match)
+			if err != nil {
+				continue
+			}
+			candidates = append(candidates, match)
+			sizes[match] = stat.Size()
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+		sort.SliceStable(candidates, func(i, j int) bool {
+			return sizes[candidates[i]] > sizes[candidates[j]]
+		})
+		return candidates[0], nil
+	}
+	return "", fmt.Errorf("No jar or zip found in %v, make sure the build packages the function with its dependencies", dir)
+}
+
+// parseBuildErrors picks the errors out of the output of Maven or Gradle.
+func parseBuildErrors(output string) []string {
+	result := []string{}
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+		switch {
+		case strings.HasPrefix(line, "[ERROR] "):
+			line = strings.TrimPrefix(line, "[ERROR] ")
+			// maven ends with a wall of advice on how to run it again
+			if line == "" || strings.HasPrefix(line, "-> [Help") || strings.HasPrefix(line, "To see the full stack trace") || strings.HasPrefix(line, "Re-run Maven") || strings.HasPrefix(line, "For more information") {
+				continue
+			}
+			result = append(result, line)
+		case strings.HasPrefix(line, "e: "), strings.Contains(line, ".java:") && strings.Contains(line, "error:"):
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
+func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtime.BuildOutput, error) {
+	project, handler, err := splitHandler(input.Handler)
+	if err != nil {
+		return nil, err
+	}
+	dir := project
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(path.ResolveRootDir(input.CfgPath), dir)
+	}
+	tool, err := findBuildTool(dir)
+	if err != nil {
+		return nil, err
+	}
+	r.projects.Store(input.FunctionID, dir)
+
+	cmd := exec.CommandContext(ctx, tool.cmd, tool.args...)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Dir = dir
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	slog.Info("building java function", "tool", tool.name, "args", cmd.Args)
+	err = cmd.Run()
+	if err != nil {
+		errors := parseBuildErrors(output.String())
+		if len(errors) == 0 {
+			errors = append(errors, strings.TrimSpace(output.String()))
+		}
+		return &runtime.BuildOutput{Handler: handler, Errors: errors}, nil
+	}
+
+	pkg, err := findPackage(dir, tool.outputs)
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("extracting java package", "package", pkg)
+	err = extract(pkg, input.Out())
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.BuildOutput{
+		Handler: handler,
+		Errors:  []string{},
+	}, nil
+}
+
+// extract unpacks a jar or zip into the output, Lambda loads the classes at
+// the root and the jars in lib.
+func extract(archive, out string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for _, file := range reader.File {
+		dest := filepath.Join(out, file.Name)
+		if !strings.HasPrefix(dest, filepath.Clean(out)+string(filepath.Separator)) {
+			return fmt.Errorf("invalid path in %v: %v", archive, file.Name)
+		}
+		if file.FileInfo().IsDir() {
+			if err := os.MkdirAll(dest, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		source, err := file.Open()
+		if err != nil {
+			return err
+		}
+		target, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+		if err != nil {
+			source.Close()
+			return err
+		}
+		_, err = io.Copy(target, source)
+		source.Close()
+		target.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run starts the function with the runtime interface client, which has to be
+// a dependency of the function to run it in dev.
+func (r *Runtime) Run(ctx context.Context, input *runtime.RunInput) (runtime.Worker, error) {
+	classpath := strings.Join([]string{
+		input.Build.Out,
+		filepath.Join(input.Build.Out, "lib", "*"),
+	}, string(os.PathListSeparator))
+	args := []string{"-cp", classpath}
+	if input.Inspect != 0 {
+		args = append(args, fmt.Sprintf("-agentlib:jdwp=transport=dt_socket,server=y,suspend=n,address=127.0.0.1:%d", input.Inspect))
+	}
+	args = append(args,
+		"com.amazonaws.services.lambda.runtime.api.client.AWSLambda",
+		input.Build.Handler,
+	)
+	cmd := exec.CommandContext(ctx, "java", args...)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Env = append(input.Env, "AWS_LAMBDA_RUNTIME_API="+input.Server)
+	cmd.Dir = input.Build.Out
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{
+		stdout: stdout,
+		stderr: stderr,
+		cmd:    cmd,
+	}, nil
+}
+
+var sourceExtensions = map[string]bool{
+	".java":       true,
+	".kt":         true,
+	".kts":        true,
+	".gradle":     true,
+	".properties": true,
+	".xml":        true,
+}
+
+// ShouldRebuild is true for the sources and build files of the project of a
+// function, the build output in target and build is left alone.
+func (r *Runtime) ShouldRebuild(functionID string, file string) bool {
+	dir, ok := r.projects.Load(functionID)
+	if !ok {
+		return false
+	}
+	rel, err := filepath.Rel(dir.(string), file)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	for _, skip := range []string{"target", "build", ".gradle"} {
+		if strings.HasPrefix(rel, skip+string(filepath.Separator)) {
+			return false
+		}
+	}
+	return sourceExtensions[filepath.Ext(file)]
+}
+
+// Inputs are the sources and build files of the project of a function.
+func (r *Runtime) Inputs(functionID string) []string {
+	dir, ok := r.projects.Load(functionID)
+	if !ok {
+		return nil
+	}
+	return runtime.SourceFiles(dir.(string), func(file string) bool {
+		return r.ShouldRebuild(functionID, file)
+	})
+}
@@ -0,0 +1,62 @@
+package java
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitHandler(t *testing.T) {
+	dir, handler, err := splitHandler("packages/api/com.example.Handler::handleRequest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir != "packages/api" || handler != "com.example.Handler::handleRequest" {
+		t.Errorf("unexpected split %q %q", dir, handler)
+	}
+	if _, _, err := splitHandler("com.example.Handler"); err == nil {
+		t.Error("expected an error without a project directory")
+	}
+}
+
+func TestParseBuildErrors(t *testing.T) {
+	output := `[INFO] Scanning for projects...
+[ERROR] COMPILATION ERROR : 
+[ERROR] /app/src/main/java/com/example/Handler.java:[12,5] cannot find symbol
+[ERROR] 
+[ERROR] To see the full stack trace of the errors, re-run Maven with the -e switch.
+[ERROR] -> [Help 1]
+/app/src/main/java/com/example/Other.java:4: error: ';' expected
+e: file:///app/src/main/kotlin/Handler.kt:3:1 Unresolved reference: foo
+> Task :compileJava FAILED`
+	errors := parseBuildErrors(output)
+	expected := []string{
+		"COMPILATION ERROR :",
+		"/app/src/main/java/com/example/Handler.java:[12,5] cannot find symbol",
+		"/app/src/main/java/com/example/Other.java:4: error: ';' expected",
+		"e: file:///app/src/main/kotlin/Handler.kt:3:1 Unresolved reference: foo",
+	}
+	if len(errors) != len(expected) {
+		t.Fatalf("expected %q, got %q", expected, errors)
+	}
+	for i := range expected {
+		if errors[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], errors[i])
+		}
+	}
+}
+
+func TestFindPackage(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "target"), 0755)
+	os.WriteFile(filepath.Join(dir, "target", "original-api-1.0.jar"), make([]byte, 10), 0644)
+	os.WriteFile(filepath.Join(dir, "target", "api-1.0-sources.jar"), make([]byte, 500), 0644)
+	os.WriteFile(filepath.Join(dir, "target", "api-1.0.jar"), make([]byte, 100), 0644)
+	match, err := findPackage(dir, []string{"target/*.zip", "target/*.jar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Base(match) != "api-1.0.jar" {
+		t.Errorf("unexpected package %v", match)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	iofs "io/fs"
 	"log/slog"
 	"os"
 	"os/exec"
@@ -24,8 +25,6 @@ var forceExternal = []string{
 }
 
 func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtime.BuildOutput, error) {
-	r.concurrency.Acquire(ctx, 1)
-	defer r.concurrency.Release(1)
 	var properties NodeProperties
 	json.Unmarshal(input.Properties, &properties)
 
@@ -108,8 +107,26 @@ func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtim
 		},
 	}
 	if properties.Plugins != "" {
-		plugins = append(plugins, plugin(properties.Plugins))
+		cwd, _ := os.Getwd()
+		plugins = append(plugins, plugin(filepath.Join(cwd, properties.Plugins), nil))
 	}
+	configs := []string{}
+	for _, file := range []string{r.config, properties.EsbuildConfig} {
+		if file == "" {
+			continue
+		}
+		module, source, err := r.configModule(input.CfgPath, file)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, source)
+		plugins = append(plugins, plugin(module, map[string]any{
+			"functionID": input.FunctionID,
+			"handler":    input.Handler,
+			"dev":        input.Dev,
+		}))
+	}
+	r.configs.Store(input.FunctionID, configs)
 	external := append(forceExternal, properties.Install...)
 	external = append(external, properties.ESBuild.External...)
 	if err != nil {
@@ -167,8 +184,10 @@ func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtim
 			options.MinifySyntax = properties.Minify
 			options.MinifyIdentifiers = properties.Minify
 		}
-		if !properties.SourceMap {
-			options.Sourcemap = esbuild.SourceMapNone
+		// source maps that are not included are moved out of the function
+		// once it is built, so there is no comment pointing to them
+		if properties.SourceMap != SourceMapInclude {
+			options.Sourcemap = esbuild.SourceMapExternal
 		}
 	}
 
@@ -285,3 +304,55 @@ func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtim
 		Errors:  errors,
 	}, nil
 }
+
+// Finalize keeps the source maps of a deployed function in its sourcemaps
+// directory for `sst logs`. They are moved out of the function unless they
+// are included.
+func (r *Runtime) Finalize(input *runtime.BuildInput, out string, result *runtime.BuildOutput) error {
+	if input.Dev {
+		return nil
+	}
+	var properties NodeProperties
+	json.Unmarshal(input.Properties, &properties)
+	dir := input.Sourcemaps()
+	err := os.RemoveAll(dir)
+	if err != nil {
+		return err
+	}
+	found := false
+	err = filepath.WalkDir(out, func(file string, entry iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == "node_modules" {
+			return filepath.SkipDir
+		}
+		if entry.IsDir() || !strings.HasSuffix(file, ".map") {
+			return nil
+		}
+		rel, err := filepath.Rel(out, file)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		found = true
+		if properties.SourceMap != SourceMapInclude {
+			return os.Rename(file, dest)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(dest, data, 0644)
+	})
+	if err != nil {
+		return err
+	}
+	if found {
+		result.Sourcemaps = dir
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package node
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sst/ion/pkg/js"
+	"github.com/sst/ion/pkg/project/path"
+)
+
+// configModule returns the module node loads the config hook in file from,
+// along with the path of file itself. Files that are not JavaScript are
+// bundled with pkg/js first, once for every run of sst.
+func (r *Runtime) configModule(cfgPath string, file string) (string, string, error) {
+	root := path.ResolveRootDir(cfgPath)
+	source := file
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(root, file)
+	}
+	if _, err := os.Stat(source); err != nil {
+		return "", "", fmt.Errorf("esbuild config not found: %v", file)
+	}
+	switch filepath.Ext(source) {
+	case ".js", ".mjs", ".cjs":
+		return source, source, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if module, ok := r.modules[source]; ok {
+		return module, source, nil
+	}
+	hash := sha256.Sum256([]byte(source))
+	module := filepath.Join(path.ResolveWorkingDir(cfgPath), "esbuild", hex.EncodeToString(hash[:8])+".mjs")
+	_, err := js.Build(js.EvalOptions{
+		Dir:     root,
+		Outfile: module,
+		Code:    fmt.Sprintf("export { default } from %q;", source),
+	})
+	if err != nil {
+		return "", "", fmt.Errorf("esbuild config %v could not be built: %w", file, err)
+	}
+	r.modules[source] = module
+	return module, source, nil
+}
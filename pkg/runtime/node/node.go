@@ -3,22 +3,22 @@ package node
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/evanw/esbuild/pkg/api"
 	esbuild "github.com/evanw/esbuild/pkg/api"
+	"github.com/sst/ion/internal/fs"
 	"github.com/sst/ion/internal/util"
-	"github.com/sst/ion/pkg/flag"
+	"github.com/sst/ion/pkg/js"
 	"github.com/sst/ion/pkg/project/path"
 	"github.com/sst/ion/pkg/runtime"
-	"golang.org/x/sync/semaphore"
 )
 
 var loaderMap = map[string]api.Loader{
@@ -58,25 +58,32 @@ var LoaderToString = []string{
 }
 
 type Runtime struct {
-	version     string
-	contexts    sync.Map
-	results     sync.Map
-	concurrency *semaphore.Weighted
+	version  string
+	contexts sync.Map
+	results  sync.Map
+	// config is the esbuild config hook of every function
+	config string
+	// configs has the config hooks used by each function
+	configs sync.Map
+	modules map[string]string
+	mu      sync.Mutex
 }
 
 func New(version string) *Runtime {
-	weight := int64(4)
-	if flag.SST_BUILD_CONCURRENCY != "" {
-		weight, _ = strconv.ParseInt(flag.SST_BUILD_CONCURRENCY, 10, 64)
-	}
 	return &Runtime{
-		contexts:    sync.Map{},
-		results:     sync.Map{},
-		version:     version,
-		concurrency: semaphore.NewWeighted(weight),
+		contexts: sync.Map{},
+		results:  sync.Map{},
+		version:  version,
+		modules:  map[string]string{},
 	}
 }
 
+// SetConfig sets the esbuild config hook that is applied to every function,
+// before the one of the function itself.
+func (r *Runtime) SetConfig(file string) {
+	r.config = file
+}
+
 type Worker struct {
 	stdout io.ReadCloser
 	stderr io.ReadCloser
@@ -117,19 +124,54 @@ type NodeProperties struct {
 	ESBuild      esbuild.BuildOptions `json:"esbuild"`
 	Minify       bool                 `json:"minify"`
 	Format       string               `json:"format"`
-	SourceMap    bool                 `json:"sourceMap"`
+	SourceMap    SourceMap            `json:"sourceMap"`
 	Splitting    bool                 `json:"splitting"`
 	Plugins      string               `json:"plugins"`
 	Architecture string               `json:"architecture"`
+	// EsbuildConfig is a file with a config hook for esbuild
+	EsbuildConfig string `json:"esbuildConfig"`
+}
+
+// SourceMap is the sourcemap option of a function. Deployed functions always
+// have source maps, they are kept out of the function unless it is included.
+type SourceMap string
+
+const (
+	SourceMapNone    SourceMap = ""
+	SourceMapInclude SourceMap = "include"
+	SourceMapUpload  SourceMap = "upload"
+)
+
+// UnmarshalJSON takes true for SourceMapInclude as well as the mode itself.
+func (s *SourceMap) UnmarshalJSON(data []byte) error {
+	var enabled bool
+	if err := json.Unmarshal(data, &enabled); err == nil {
+		*s = SourceMapNone
+		if enabled {
+			*s = SourceMapInclude
+		}
+		return nil
+	}
+	var mode string
+	if err := json.Unmarshal(data, &mode); err != nil {
+		return err
+	}
+	switch SourceMap(mode) {
+	case SourceMapNone, SourceMapInclude, SourceMapUpload:
+		*s = SourceMap(mode)
+		return nil
+	}
+	return fmt.Errorf("invalid sourcemap option %q", mode)
 }
 
 var NODE_EXTENSIONS = []string{".ts", ".tsx", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs"}
 
 func (r *Runtime) Run(ctx context.Context, input *runtime.RunInput) (runtime.Worker, error) {
-	cmd := exec.CommandContext(
-		ctx,
-		"node",
-		"--enable-source-maps",
+	args := []string{"--enable-source-maps"}
+	if input.Inspect != 0 {
+		args = append(args, fmt.Sprintf("--inspect=127.0.0.1:%d", input.Inspect))
+	}
+	args = append(args,
 		filepath.Join(
 			path.ResolvePlatformDir(input.CfgPath),
 			"/dist/nodejs-runtime/index.js",
@@ -137,6 +179,7 @@ func (r *Runtime) Run(ctx context.Context, input *runtime.RunInput) (runtime.Wor
 		filepath.Join(input.Build.Out, input.Build.Handler),
 		input.WorkerID,
 	)
+	cmd := exec.CommandContext(ctx, "node", args...)
 	util.SetProcessGroupID(cmd)
 	util.SetProcessCancel(cmd)
 	cmd.Env = input.Env
@@ -159,6 +202,18 @@ func (r *Runtime) Match(runtime string) bool {
 	return strings.HasPrefix(runtime, "node")
 }
 
+func (r *Runtime) LaunchConfig(input *runtime.BuildInput, port int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":      "node",
+		"request":   "attach",
+		"address":   "127.0.0.1",
+		"port":      port,
+		"restart":   true,
+		"skipFiles": []string{"<node_internals>/**"},
+		"outFiles":  []string{filepath.Join(input.Out(), "**", "*.(m|c|)js")},
+	}
+}
+
 func (r *Runtime) getFile(input *runtime.BuildInput) (string, bool) {
 	dir := filepath.Dir(input.Handler)
 	fileSplit := strings.Split(filepath.Base(input.Handler), ".")
@@ -198,3 +253,52 @@ func (r *Runtime) ShouldRebuild(functionID string, file string) bool {
 
 	return false
 }
+
+// Inputs are the files esbuild read for the last build of a function, along
+// with the package.json and lockfile that decide what gets installed.
+func (r *Runtime) Inputs(functionID string) []string {
+	result, ok := r.results.Load(functionID)
+	if !ok {
+		return nil
+	}
+	var meta js.Metafile
+	if err := json.Unmarshal([]byte(result.(esbuild.BuildResult).Metafile), &meta); err != nil {
+		return nil
+	}
+	files := []string{}
+	for key := range meta.Inputs {
+		// inputs from plugins have a namespace and are not files
+		if strings.Contains(key, ":") && !filepath.IsAbs(key) {
+			continue
+		}
+		absPath, err := filepath.Abs(key)
+		if err != nil {
+			return nil
+		}
+		files = append(files, absPath)
+	}
+	if configs, ok := r.configs.Load(functionID); ok {
+		files = append(files, configs.([]string)...)
+	}
+	source := ""
+	for _, file := range files {
+		if !strings.Contains(file, "node_modules") {
+			source = file
+			break
+		}
+	}
+	if source == "" {
+		return nil
+	}
+	pkg, err := fs.FindUp(filepath.Dir(source), "package.json")
+	if err == nil {
+		files = append(files, pkg)
+		for _, lock := range []string{"package-lock.json", "pnpm-lock.yaml", "yarn.lock", "bun.lockb"} {
+			if lockfile, err := fs.FindUp(filepath.Dir(pkg), lock); err == nil {
+				files = append(files, lockfile)
+				break
+			}
+		}
+	}
+	return files
+}
@@ -3,11 +3,10 @@ package node
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
 	"log/slog"
-	"os"
 	"os/exec"
-	"path/filepath"
 
 	"github.com/evanw/esbuild/pkg/api"
 	"github.com/sst/ion/internal/util"
@@ -26,14 +25,20 @@ type request struct {
 	Value   map[string]any
 }
 
-func plugin(path string) api.Plugin {
-	cwd, _ := os.Getwd()
-	path = filepath.Join(cwd, path)
+// plugin runs the esbuild plugins exported by the module at path in node. If
+// context is set the module is a config hook, it is called with the context
+// and the options it returns are applied to the build.
+func plugin(path string, context map[string]any) api.Plugin {
 	return api.Plugin{
 		Name: "nodejs-plugin",
 		Setup: func(build api.PluginBuild) {
 			slog.Info("nodejs plugin", "path", path)
-			cmd := exec.Command("node", ".sst/platform/functions/nodejs-runtime/plugin.mjs", path)
+			args := []string{".sst/platform/functions/nodejs-runtime/plugin.mjs", path}
+			if context != nil {
+				encoded, _ := json.Marshal(context)
+				args = append(args, string(encoded))
+			}
+			cmd := exec.Command("node", args...)
 			util.SetProcessGroupID(cmd)
 			var wg errgroup.Group
 			// cmd.Stderr = os.Stderr
@@ -101,6 +106,30 @@ func plugin(path string) api.Plugin {
 					responses <- reply
 				}
 			})
+			dispose := func() {
+				stdin.Close()
+				stdout.Close()
+				close(requests)
+				close(responses)
+				util.TerminateProcess(cmd.Process.Pid)
+				wg.Wait()
+			}
+			if context != nil {
+				config := request("config", context)
+				if value, ok := config["error"]; ok {
+					build.OnStart(func() (api.OnStartResult, error) {
+						return api.OnStartResult{}, fmt.Errorf("esbuild config %v failed: %v", path, value)
+					})
+				} else {
+					applyConfig(build.InitialOptions, config)
+				}
+				// every import goes through node with plugins, so the process
+				// is not kept around for a config without any
+				if plugins, _ := config["plugins"].(float64); plugins == 0 {
+					dispose()
+					return
+				}
+			}
 			build.OnResolve(api.OnResolveOptions{Filter: ".*"}, func(args api.OnResolveArgs) (api.OnResolveResult, error) {
 				with := make(map[string]interface{}, len(args.With))
 				for k, v := range args.With {
@@ -218,18 +247,59 @@ func plugin(path string) api.Plugin {
 				req["mangleCache"] = result.MangleCache
 				return api.OnEndResult{}, nil
 			})
-			build.OnDispose(func() {
-				stdin.Close()
-				stdout.Close()
-				close(requests)
-				close(responses)
-				util.TerminateProcess(cmd.Process.Pid)
-				wg.Wait()
-			})
+			build.OnDispose(dispose)
 		},
 	}
 }
 
+// applyConfig adds what a config hook returned to the build options. Banners
+// and footers are either a string for js or a map by file type.
+func applyConfig(options *api.BuildOptions, config map[string]any) {
+	if define, ok := config["define"].(map[string]any); ok {
+		if options.Define == nil {
+			options.Define = map[string]string{}
+		}
+		for key, value := range define {
+			if value, ok := value.(string); ok {
+				options.Define[key] = value
+			}
+		}
+	}
+	if external, ok := config["external"].([]any); ok {
+		for _, value := range external {
+			if value, ok := value.(string); ok {
+				options.External = append(options.External, value)
+			}
+		}
+	}
+	for key, target := range map[string]*map[string]string{"banner": &options.Banner, "footer": &options.Footer} {
+		value, ok := config[key]
+		if !ok {
+			continue
+		}
+		if text, ok := value.(string); ok {
+			value = map[string]any{"js": text}
+		}
+		byType, ok := value.(map[string]any)
+		if !ok {
+			continue
+		}
+		if *target == nil {
+			*target = map[string]string{}
+		}
+		for fileType, text := range byType {
+			text, ok := text.(string)
+			if !ok {
+				continue
+			}
+			if existing := (*target)[fileType]; existing != "" {
+				text = existing + "\n" + text
+			}
+			(*target)[fileType] = text
+		}
+	}
+}
+
 func encodeStringArray(strings []string) []interface{} {
 	values := make([]interface{}, len(strings))
 	for i, value := range strings {
@@ -0,0 +1,30 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/evanw/esbuild/pkg/api"
+)
+
+func TestApplyConfig(t *testing.T) {
+	options := &api.BuildOptions{
+		External: []string{"sharp"},
+		Banner:   map[string]string{"js": "// sst"},
+	}
+	applyConfig(options, map[string]any{
+		"define":   map[string]any{"process.env.STAGE": `"production"`, "invalid": 1.0},
+		"external": []any{"pg-native"},
+		"banner":   "// user",
+		"footer":   map[string]any{"js": "// end", "css": "/* end */"},
+	})
+	expected := &api.BuildOptions{
+		External: []string{"sharp", "pg-native"},
+		Define:   map[string]string{"process.env.STAGE": `"production"`},
+		Banner:   map[string]string{"js": "// sst\n// user"},
+		Footer:   map[string]string{"js": "// end", "css": "/* end */"},
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("applyConfig = %+v, expected %+v", options, expected)
+	}
+}
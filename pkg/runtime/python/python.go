@@ -240,6 +240,25 @@ func (r *PythonRuntime) Match(runtime string) bool {
 	return strings.HasPrefix(runtime, "python")
 }
 
+func (r *PythonRuntime) LaunchConfig(input *runtime.BuildInput, port int) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "debugpy",
+		"request": "attach",
+		"connect": map[string]interface{}{
+			"host": "127.0.0.1",
+			"port": port,
+		},
+		// the handler runs from a copy of its directory in the artifacts
+		"pathMappings": []map[string]string{
+			{
+				"localRoot":  path.ResolveRootDir(input.CfgPath),
+				"remoteRoot": input.Out(),
+			},
+		},
+		"justMyCode": true,
+	}
+}
+
 type Source struct {
 	URL          string  `toml:"url,omitempty"`
 	Git          string  `toml:"git,omitempty"`
@@ -333,6 +352,10 @@ func (r *PythonRuntime) Run(ctx context.Context, input *runtime.RunInput) (runti
 		}
 	}
 
+	if input.Inspect != 0 {
+		args = append(args, "--with", "debugpy", "python", "-m", "debugpy", "--listen", fmt.Sprintf("127.0.0.1:%d", input.Inspect))
+	}
+
 	args = append(args,
 		filepath.Join(path.ResolvePlatformDir(input.CfgPath), "/dist/python-runtime/index.py"),
 		filepath.Join(input.Build.Out, input.Build.Handler),
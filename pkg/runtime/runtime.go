@@ -11,8 +11,16 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
+	"time"
 
+	"github.com/sst/ion/pkg/flag"
+	"github.com/sst/ion/pkg/metrics"
 	"github.com/sst/ion/pkg/project/path"
+	"github.com/sst/ion/pkg/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type Runtime interface {
@@ -34,9 +42,12 @@ type BuildInput struct {
 	Handler       string                     `json:"handler"`
 	Bundle        string                     `json:"bundle"`
 	Runtime       string                     `json:"runtime"`
+	Architecture  string                     `json:"architecture"`
 	Properties    json.RawMessage            `json:"properties"`
 	Links         map[string]json.RawMessage `json:"links"`
 	EncryptionKey string                     `json:"encryptionKey"`
+	Container     *ContainerInput            `json:"container"`
+	Hooks         *BuildHooks                `json:"hooks"`
 	CopyFiles     []struct {
 		From string `json:"from"`
 		To   string `json:"to"`
@@ -51,10 +62,27 @@ func (input *BuildInput) Out() string {
 	return filepath.Join(path.ResolveWorkingDir(input.CfgPath), "artifacts", input.FunctionID+suffix)
 }
 
+// Sourcemaps is where the source maps of a deployed function are kept, they
+// are used to map stack traces in `sst logs` back to the source. It is as deep
+// as Out so the relative paths in the maps still resolve.
+func (input *BuildInput) Sourcemaps() string {
+	return filepath.Join(path.ResolveWorkingDir(input.CfgPath), "sourcemaps", input.FunctionID)
+}
+
+// Finalizer is implemented by runtimes that process the output of a build,
+// whether it was built or restored from the cache.
+type Finalizer interface {
+	Finalize(input *BuildInput, out string, result *BuildOutput) error
+}
+
 type BuildOutput struct {
 	Out     string   `json:"out"`
 	Handler string   `json:"handler"`
 	Errors  []string `json:"errors"`
+	// Image is the local image a container function runs in during dev
+	Image string `json:"image,omitempty"`
+	// Sourcemaps is the directory the source maps were moved to
+	Sourcemaps string `json:"sourcemaps,omitempty"`
 }
 
 type RunInput struct {
@@ -65,19 +93,41 @@ type RunInput struct {
 	WorkerID   string
 	Build      *BuildOutput
 	Env        []string
+	// Inspect is the port the debugger of the worker listens on, if any
+	Inspect int
 }
 
 type Collection struct {
 	runtimes []Runtime
 	cfgPath  string
 	targets  map[string]*BuildInput
+	// Version of sst is part of the key of cached builds
+	Version string
+	// App and Stage are passed to build hooks
+	App       string
+	Stage     string
+	cache     *BuildCache
+	cacheOnce sync.Once
+	scheduler *scheduler
+	inspector *inspector
+	timingsMu sync.Mutex
+	timings   []BuildTiming
+}
+
+// BuildTiming is how long the build of a function took, the updates report
+// them in their timings.
+type BuildTiming struct {
+	FunctionID string
+	Runtime    string
+	Duration   time.Duration
 }
 
 func NewCollection(platform string, runtimes ...Runtime) *Collection {
 	return &Collection{
-		runtimes: runtimes,
-		cfgPath:  platform,
-		targets:  map[string]*BuildInput{},
+		runtimes:  runtimes,
+		cfgPath:   platform,
+		targets:   map[string]*BuildInput{},
+		scheduler: newScheduler(buildConcurrency()),
 	}
 }
 
@@ -91,6 +141,40 @@ func (c *Collection) Runtime(input string) (Runtime, bool) {
 }
 
 func (c *Collection) Build(ctx context.Context, input *BuildInput) (*BuildOutput, error) {
+	ctx, span := telemetry.Span(telemetry.WithDeploy(ctx), "build",
+		attribute.String("sst.function.id", input.FunctionID),
+		attribute.String("sst.runtime", input.Runtime),
+		attribute.Bool("sst.dev", input.Dev),
+	)
+	start := time.Now()
+	result, err := c.build(ctx, input)
+	status := metrics.Status(err)
+	if err == nil && len(result.Errors) > 0 {
+		span.SetStatus(codes.Error, result.Errors[0])
+		status = "error"
+	}
+	metrics.BuildDuration.WithLabelValues(input.Runtime, status).Observe(time.Since(start).Seconds())
+	c.timingsMu.Lock()
+	c.timings = append(c.timings, BuildTiming{
+		FunctionID: input.FunctionID,
+		Runtime:    input.Runtime,
+		Duration:   time.Since(start),
+	})
+	c.timingsMu.Unlock()
+	telemetry.EndSpan(span, err)
+	return result, err
+}
+
+// Timings returns the builds since the last call and starts over.
+func (c *Collection) Timings() []BuildTiming {
+	c.timingsMu.Lock()
+	defer c.timingsMu.Unlock()
+	result := c.timings
+	c.timings = nil
+	return result
+}
+
+func (c *Collection) build(ctx context.Context, input *BuildInput) (*BuildOutput, error) {
 	slog.Info("building function", "runtime", input.Runtime, "functionID", input.FunctionID)
 	defer slog.Info("function built", "runtime", input.Runtime, "functionID", input.FunctionID)
 	runtime, ok := c.Runtime(input.Runtime)
@@ -117,10 +201,55 @@ func (c *Collection) Build(ctx context.Context, input *BuildInput) (*BuildOutput
 		if err != nil {
 			return nil, err
 		}
-		result, err = runtime.Build(ctx, input)
+		// identical functions are only deduplicated in deploys, dev keeps
+		// the state of every build for rebuilds
+		key := ""
+		if !input.Dev {
+			key, _ = optionsKey(input, c.Version)
+		}
+		hooks := BuildHooks{}
+		if input.Hooks != nil {
+			hooks = *input.Hooks
+		}
+		result, err = c.scheduler.run(ctx, input.FunctionID, key, out, func() (*BuildOutput, error) {
+			// codegen has to happen before the cache is checked so the files
+			// it writes are part of the inputs
+			if errors := c.runHooks(ctx, "preBuild", hooks.PreBuild, input, out); len(errors) > 0 {
+				return &BuildOutput{Handler: input.Handler, Errors: errors}, nil
+			}
+			result := c.restore(ctx, runtime, input, out)
+			trace.SpanFromContext(ctx).SetAttributes(attribute.Bool("sst.build.cached", result != nil))
+			if result == nil {
+				built, err := runtime.Build(ctx, input)
+				if err != nil {
+					return nil, err
+				}
+				c.save(ctx, runtime, input, out, built)
+				result = built
+			}
+			if finalizer, ok := runtime.(Finalizer); ok && len(result.Errors) == 0 {
+				if err := finalizer.Finalize(input, out, result); err != nil {
+					return nil, err
+				}
+			}
+			if len(result.Errors) == 0 {
+				result.Errors = append(result.Errors, c.runHooks(ctx, "postBuild", hooks.PostBuild, input, out)...)
+			}
+			return result, nil
+		})
 		if err != nil {
 			return nil, err
 		}
+		// a deduplicated build shares the source maps of the first one
+		if result.Sourcemaps != "" && result.Sourcemaps != input.Sourcemaps() {
+			if err := os.RemoveAll(input.Sourcemaps()); err != nil {
+				return nil, err
+			}
+			if err := copyDir(result.Sourcemaps, input.Sourcemaps()); err != nil {
+				return nil, err
+			}
+			result.Sourcemaps = input.Sourcemaps()
+		}
 	}
 
 	result.Out = out
@@ -189,9 +318,81 @@ func (c *Collection) Build(ctx context.Context, input *BuildInput) (*BuildOutput
 		}
 	}
 
+	if input.Container != nil && len(result.Errors) == 0 {
+		err := writeDockerfile(input, result.Out, result.Handler)
+		if err != nil {
+			return nil, err
+		}
+		// deploys push the image from the component, in dev it runs here
+		if input.Dev {
+			image, errors, err := buildImage(ctx, input, result.Out)
+			if err != nil {
+				return nil, err
+			}
+			result.Image = image
+			result.Errors = append(result.Errors, errors...)
+		}
+	}
+
 	return result, nil
 }
 
+// cacheFor returns the build cache if the build of input can be cached. Only
+// deploys are, dev has its own incremental builds.
+func (c *Collection) cacheFor(runtime Runtime, input *BuildInput) (*BuildCache, InputLister, bool) {
+	lister, ok := runtime.(InputLister)
+	if !ok || input.Dev || flag.SST_NO_BUILD_CACHE {
+		return nil, nil, false
+	}
+	c.cacheOnce.Do(func() {
+		c.cache = NewBuildCache(c.cfgPath)
+	})
+	return c.cache, lister, true
+}
+
+func (c *Collection) restore(ctx context.Context, runtime Runtime, input *BuildInput, out string) *BuildOutput {
+	cache, _, ok := c.cacheFor(runtime, input)
+	if !ok {
+		return nil
+	}
+	options, err := optionsKey(input, c.Version)
+	if err != nil {
+		return nil
+	}
+	result, err := cache.Restore(ctx, options, out)
+	if err != nil {
+		slog.Error("failed to restore cached build", "functionID", input.FunctionID, "err", err)
+		metrics.BuildCache.WithLabelValues("miss").Inc()
+		return nil
+	}
+	if result != nil {
+		slog.Info("restored cached build", "functionID", input.FunctionID)
+		metrics.BuildCache.WithLabelValues("hit").Inc()
+		return result
+	}
+	metrics.BuildCache.WithLabelValues("miss").Inc()
+	return result
+}
+
+func (c *Collection) save(ctx context.Context, runtime Runtime, input *BuildInput, out string, result *BuildOutput) {
+	cache, lister, ok := c.cacheFor(runtime, input)
+	if !ok || len(result.Errors) > 0 {
+		return
+	}
+	inputs := lister.Inputs(input.FunctionID)
+	if len(inputs) == 0 {
+		return
+	}
+	options, err := optionsKey(input, c.Version)
+	if err != nil {
+		return
+	}
+	err = cache.Save(ctx, options, inputs, out, result)
+	if err != nil {
+		slog.Error("failed to cache build", "functionID", input.FunctionID, "err", err)
+	}
+}
+
 func (c *Collection) Run(ctx context.Context, input *RunInput) (Worker, error) {
 	slog.Info("running function", "runtime", input.Runtime, "functionID", input.FunctionID)
 	runtime, ok := c.Runtime(input.Runtime)
@@ -200,7 +401,12 @@ func (c *Collection) Run(ctx context.Context, input *RunInput) (Worker, error) {
 	if !ok {
 		return nil, fmt.Errorf("runtime not found")
 	}
-	return runtime.Run(ctx, input)
+	if input.Build.Image != "" {
+		return runContainer(ctx, input)
+	}
+	inspected := c.inspect(runtime, input)
+	worker, err := runtime.Run(ctx, input)
+	return inspected(worker), err
 }
 
 func (c *Collection) ShouldRebuild(runtime string, functionID string, file string) bool {
@@ -217,4 +423,12 @@ func (c *Collection) ShouldRebuild(runtime string, functionID string, file strin
 func (c *Collection) AddTarget(input *BuildInput) {
 	input.CfgPath = c.cfgPath
 	c.targets[input.FunctionID] = input
+	if c.inspector == nil {
+		return
+	}
+	if r, ok := c.Runtime(input.Runtime); ok {
+		if runtime, ok := r.(Inspector); ok {
+			c.inspector.assign(runtime, input)
+		}
+	}
 }
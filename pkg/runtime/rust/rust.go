@@ -0,0 +1,298 @@
+package rust
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/sst/ion/internal/fs"
+	"github.com/sst/ion/internal/util"
+	"github.com/sst/ion/pkg/project/path"
+	"github.com/sst/ion/pkg/runtime"
+)
+
+type Worker struct {
+	stdout io.ReadCloser
+	stderr io.ReadCloser
+	cmd    *exec.Cmd
+}
+
+func (w *Worker) Stop() {
+	util.TerminateProcess(w.cmd.Process.Pid)
+}
+
+func (w *Worker) Logs() io.ReadCloser {
+	reader, writer := io.Pipe()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	for _, stream := range []io.Reader{w.stdout, w.stderr} {
+		go func(stream io.Reader) {
+			defer wg.Done()
+			io.Copy(writer, stream)
+		}(stream)
+	}
+	go func() {
+		wg.Wait()
+		writer.Close()
+	}()
+	return reader
+}
+
+type Runtime struct {
+	// crates is the directory of the crate of every function that was built,
+	// to know which ones a changed file affects
+	crates sync.Map
+}
+
+func New() *Runtime {
+	return &Runtime{}
+}
+
+func (r *Runtime) Match(runtime string) bool {
+	return runtime == "rust"
+}
+
+// findCrate resolves a handler to the manifest of its crate and the binary to
+// build. The handler is a Cargo.toml, a directory with one, or the source of
+// a binary in src/bin.
+func findCrate(root, handler string) (string, string, error) {
+	file := handler
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(root, file)
+	}
+	stat, err := os.Stat(file)
+	if err != nil {
+		return "", "", fmt.Errorf("Handler not found: %v", handler)
+	}
+	if stat.IsDir() {
+		file = filepath.Join(file, "Cargo.toml")
+		if _, err := os.Stat(file); err != nil {
+			return "", "", fmt.Errorf("No Cargo.toml in %v", handler)
+		}
+	}
+	if filepath.Base(file) == "Cargo.toml" {
+		return file, "", nil
+	}
+	if filepath.Ext(file) != ".rs" {
+		return "", "", fmt.Errorf("Handler must be a Cargo.toml or a .rs file: %v", handler)
+	}
+	bin := ""
+	if filepath.Base(filepath.Dir(file)) == "bin" {
+		bin = strings.TrimSuffix(filepath.Base(file), ".rs")
+	}
+	for dir := filepath.Dir(file); ; dir = filepath.Dir(dir) {
+		manifest := filepath.Join(dir, "Cargo.toml")
+		if _, err := os.Stat(manifest); err == nil {
+			return manifest, bin, nil
+		}
+		if dir == filepath.Dir(dir) {
+			return "", "", fmt.Errorf("No Cargo.toml found for %v", handler)
+		}
+	}
+}
+
+type cargoMessage struct {
+	Reason       string `json:"reason"`
+	ManifestPath string `json:"manifest_path"`
+	Target       struct {
+		Kind []string `json:"kind"`
+		Name string   `json:"name"`
+	} `json:"target"`
+	Executable string `json:"executable"`
+	Message    struct {
+		Level    string `json:"level"`
+		Rendered string `json:"rendered"`
+	} `json:"message"`
+	Success bool `json:"success"`
+}
+
+type cargoResult struct {
+	executables map[string]string
+	errors      []string
+}
+
+// parseCargoOutput reads the json messages of cargo for the binaries of the
+// crate at manifest and the errors of the build.
+func parseCargoOutput(reader io.Reader, manifest string) (*cargoResult, error) {
+	result := &cargoResult{executables: map[string]string{}, errors: []string{}}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg cargoMessage
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue
+		}
+		switch msg.Reason {
+		case "compiler-message":
+			if msg.Message.Level == "error" {
+				result.errors = append(result.errors, strings.TrimSpace(msg.Message.Rendered))
+			}
+		case "compiler-artifact":
+			if msg.Executable == "" || msg.ManifestPath != manifest {
+				continue
+			}
+			for _, kind := range msg.Target.Kind {
+				if kind == "bin" {
+					result.executables[msg.Target.Name] = msg.Executable
+				}
+			}
+		}
+	}
+	return result, scanner.Err()
+}
+
+func (r *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtime.BuildOutput, error) {
+	manifest, bin, err := findCrate(path.ResolveRootDir(input.CfgPath), input.Handler)
+	if err != nil {
+		return nil, err
+	}
+	r.crates.Store(input.FunctionID, filepath.Dir(manifest))
+
+	// dev runs the function on this machine so it is a debug build for it,
+	// which cargo builds incrementally
+	args := []string{"build", "--message-format", "json-diagnostic-rendered-ansi", "--manifest-path", manifest}
+	if !input.Dev {
+		if _, err := exec.LookPath("cargo-lambda"); err != nil {
+			return nil, fmt.Errorf("cargo-lambda is needed to build Rust functions, see https://www.cargo-lambda.info")
+		}
+		args = append([]string{"lambda"}, args...)
+		args = append(args, "--release")
+		if input.Architecture == "arm64" {
+			args = append(args, "--arm64")
+		} else {
+			args = append(args, "--x86-64")
+		}
+	}
+	if bin != "" {
+		args = append(args, "--bin", bin)
+	}
+	cmd := exec.CommandContext(ctx, "cargo", args...)
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Dir = filepath.Dir(manifest)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	slog.Info("building rust function", "args", cmd.Args)
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	result, err := parseCargoOutput(stdout, manifest)
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Wait()
+	if len(result.errors) > 0 || err != nil {
+		if len(result.errors) == 0 {
+			// cargo fails before compiling for things like a broken manifest
+			result.errors = append(result.errors, strings.TrimSpace(stderr.String()))
+		}
+		return &runtime.BuildOutput{Handler: "bootstrap", Errors: result.errors}, nil
+	}
+
+	executable := result.executables[bin]
+	if bin == "" {
+		if len(result.executables) != 1 {
+			return nil, fmt.Errorf("%v has %d binaries, set the handler to the one in src/bin to use", input.Handler, len(result.executables))
+		}
+		for _, match := range result.executables {
+			executable = match
+		}
+	}
+	if executable == "" {
+		return nil, fmt.Errorf("cargo did not build %v", bin)
+	}
+	err = copyExecutable(executable, filepath.Join(input.Out(), "bootstrap"))
+	if err != nil {
+		return nil, err
+	}
+	return &runtime.BuildOutput{
+		Handler: "bootstrap",
+		Errors:  []string{},
+	}, nil
+}
+
+func copyExecutable(from, to string) error {
+	source, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	dest, err := os.OpenFile(to, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	_, err = io.Copy(dest, source)
+	return err
+}
+
+func (r *Runtime) Run(ctx context.Context, input *runtime.RunInput) (runtime.Worker, error) {
+	cmd := exec.CommandContext(ctx, filepath.Join(input.Build.Out, input.Build.Handler))
+	util.SetProcessGroupID(cmd)
+	util.SetProcessCancel(cmd)
+	cmd.Env = append(input.Env, "AWS_LAMBDA_RUNTIME_API="+input.Server)
+	cmd.Dir = input.Build.Out
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, err
+	}
+	return &Worker{
+		stdout: stdout,
+		stderr: stderr,
+		cmd:    cmd,
+	}, nil
+}
+
+// ShouldRebuild is true for the sources and manifests in the crate of a
+// function, the build output in target is left alone.
+func (r *Runtime) ShouldRebuild(functionID string, file string) bool {
+	dir, ok := r.crates.Load(functionID)
+	if !ok {
+		return false
+	}
+	rel, err := filepath.Rel(dir.(string), file)
+	if err != nil || strings.HasPrefix(rel, "..") || strings.HasPrefix(rel, "target"+string(filepath.Separator)) {
+		return false
+	}
+	base := filepath.Base(file)
+	return filepath.Ext(file) == ".rs" || base == "Cargo.toml" || base == "Cargo.lock"
+}
+
+// Inputs are the sources and manifests of the crate of a function, and the
+// lockfile of its workspace.
+func (r *Runtime) Inputs(functionID string) []string {
+	dir, ok := r.crates.Load(functionID)
+	if !ok {
+		return nil
+	}
+	files := runtime.SourceFiles(dir.(string), func(file string) bool {
+		return r.ShouldRebuild(functionID, file)
+	})
+	if lock, err := fs.FindUp(dir.(string), "Cargo.lock"); err == nil && !slices.Contains(files, lock) {
+		files = append(files, lock)
+	}
+	return files
+}
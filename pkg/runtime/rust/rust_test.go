@@ -0,0 +1,61 @@
+package rust
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseCargoOutput(t *testing.T) {
+	manifest := "/app/packages/api/Cargo.toml"
+	output := strings.Join([]string{
+		`{"reason":"compiler-artifact","manifest_path":"/registry/serde/Cargo.toml","target":{"kind":["lib"],"name":"serde"},"executable":null}`,
+		`{"reason":"compiler-message","manifest_path":"/app/packages/api/Cargo.toml","message":{"level":"warning","rendered":"warning: unused"}}`,
+		`{"reason":"compiler-message","manifest_path":"/app/packages/api/Cargo.toml","message":{"level":"error","rendered":"error[E0425]: cannot find value\n"}}`,
+		`{"reason":"compiler-artifact","manifest_path":"/app/packages/api/Cargo.toml","target":{"kind":["bin"],"name":"users"},"executable":"/app/target/debug/users"}`,
+		`Compiling api`,
+		`{"reason":"build-finished","success":false}`,
+	}, "\n")
+	result, err := parseCargoOutput(strings.NewReader(output), manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.errors) != 1 || result.errors[0] != "error[E0425]: cannot find value" {
+		t.Errorf("unexpected errors %q", result.errors)
+	}
+	if result.executables["users"] != "/app/target/debug/users" || len(result.executables) != 1 {
+		t.Errorf("unexpected executables %v", result.executables)
+	}
+}
+
+func TestFindCrate(t *testing.T) {
+	root := t.TempDir()
+	crate := filepath.Join(root, "packages", "api")
+	os.MkdirAll(filepath.Join(crate, "src", "bin"), 0755)
+	os.WriteFile(filepath.Join(crate, "Cargo.toml"), []byte("[package]\nname = \"api\"\n"), 0644)
+	os.WriteFile(filepath.Join(crate, "src", "bin", "users.rs"), []byte("fn main() {}\n"), 0644)
+	os.WriteFile(filepath.Join(crate, "src", "main.rs"), []byte("fn main() {}\n"), 0644)
+
+	tests := []struct {
+		handler string
+		bin     string
+	}{
+		{"packages/api", ""},
+		{"packages/api/Cargo.toml", ""},
+		{"packages/api/src/main.rs", ""},
+		{"packages/api/src/bin/users.rs", "users"},
+	}
+	for _, test := range tests {
+		manifest, bin, err := findCrate(root, test.handler)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if manifest != filepath.Join(crate, "Cargo.toml") || bin != test.bin {
+			t.Errorf("findCrate(%q) = %q, %q", test.handler, manifest, bin)
+		}
+	}
+	if _, _, err := findCrate(root, "packages/missing"); err == nil {
+		t.Error("expected an error for a missing handler")
+	}
+}
@@ -0,0 +1,166 @@
+package runtime
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/flag"
+	"golang.org/x/sync/semaphore"
+)
+
+// BuildProgressEvent is published when a function build starts or finishes,
+// Done of Total have finished.
+type BuildProgressEvent struct {
+	FunctionID string
+	Done       int
+	Total      int
+}
+
+type sharedBuild struct {
+	done   chan struct{}
+	out    string
+	result BuildOutput
+	err    error
+}
+
+// scheduler runs builds on a bounded number of slots. Builds with the same
+// key that run at the same time are only built once, the others get a copy
+// of the output.
+type scheduler struct {
+	slots    *semaphore.Weighted
+	mu       sync.Mutex
+	inflight map[string]*sharedBuild
+	done     int
+	total    int
+}
+
+func buildConcurrency() int64 {
+	if flag.SST_BUILD_CONCURRENCY != "" {
+		weight, err := strconv.ParseInt(flag.SST_BUILD_CONCURRENCY, 10, 64)
+		if err == nil && weight > 0 {
+			return weight
+		}
+	}
+	return 4
+}
+
+func newScheduler(concurrency int64) *scheduler {
+	return &scheduler{
+		slots:    semaphore.NewWeighted(concurrency),
+		inflight: map[string]*sharedBuild{},
+	}
+}
+
+func (s *scheduler) progress(functionID string, started bool) {
+	s.mu.Lock()
+	if started {
+		// a new round of builds, like the next deploy in dev
+		if s.done == s.total {
+			s.done = 0
+			s.total = 0
+		}
+		s.total++
+	} else {
+		s.done++
+	}
+	evt := &BuildProgressEvent{FunctionID: functionID, Done: s.done, Total: s.total}
+	s.mu.Unlock()
+	bus.Publish(evt)
+}
+
+// run calls build once a slot is free. An empty key is never deduplicated.
+func (s *scheduler) run(ctx context.Context, functionID string, key string, out string, build func() (*BuildOutput, error)) (*BuildOutput, error) {
+	s.progress(functionID, true)
+	defer s.progress(functionID, false)
+
+	var shared *sharedBuild
+	if key != "" {
+		s.mu.Lock()
+		existing, ok := s.inflight[key]
+		if ok {
+			s.mu.Unlock()
+			select {
+			case <-existing.done:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			if existing.err != nil {
+				return nil, existing.err
+			}
+			result := existing.result
+			if len(result.Errors) == 0 {
+				if err := copyDir(existing.out, out); err != nil {
+					return nil, err
+				}
+			}
+			return &result, nil
+		}
+		shared = &sharedBuild{done: make(chan struct{}), out: out}
+		s.inflight[key] = shared
+		s.mu.Unlock()
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, key)
+			s.mu.Unlock()
+			close(shared.done)
+		}()
+	}
+
+	if err := s.slots.Acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+	result, err := build()
+	s.slots.Release(1)
+	if shared != nil {
+		shared.err = err
+		if result != nil {
+			shared.result = *result
+		}
+	}
+	return result, err
+}
+
+func copyDir(from, to string) error {
+	return filepath.WalkDir(from, func(file string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(from, file)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(to, rel)
+		if entry.IsDir() {
+			return os.MkdirAll(dest, 0755)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			target, err := os.Readlink(file)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, dest)
+		}
+		source, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer source.Close()
+		target, err := os.OpenFile(dest, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+		if err != nil {
+			return err
+		}
+		defer target.Close()
+		_, err = io.Copy(target, source)
+		return err
+	})
+}
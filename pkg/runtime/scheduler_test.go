@@ -0,0 +1,60 @@
+package runtime
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSchedulerDeduplicates(t *testing.T) {
+	ctx := context.Background()
+	s := newScheduler(2)
+	var builds atomic.Int32
+	release := make(chan struct{})
+	build := func(out string) func() (*BuildOutput, error) {
+		return func() (*BuildOutput, error) {
+			builds.Add(1)
+			<-release
+			os.WriteFile(filepath.Join(out, "index.mjs"), []byte("built"), 0644)
+			return &BuildOutput{Handler: "index.handler", Errors: []string{}}, nil
+		}
+	}
+
+	outs := []string{t.TempDir(), t.TempDir(), t.TempDir()}
+	var wg sync.WaitGroup
+	results := make([]*BuildOutput, len(outs))
+	for i, out := range outs {
+		wg.Add(1)
+		go func(i int, out string) {
+			defer wg.Done()
+			result, err := s.run(ctx, "Fn", "same", out, build(out))
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = result
+		}(i, out)
+		// let the first build claim the key
+		time.Sleep(10 * time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+
+	if builds.Load() != 1 {
+		t.Errorf("expected one build, got %d", builds.Load())
+	}
+	for i, out := range outs {
+		if results[i] == nil || results[i].Handler != "index.handler" {
+			t.Errorf("unexpected result %v", results[i])
+		}
+		if data, _ := os.ReadFile(filepath.Join(out, "index.mjs")); string(data) != "built" {
+			t.Errorf("expected the output to be copied to %v", out)
+		}
+	}
+	if s.done != 3 || s.total != 3 {
+		t.Errorf("expected 3 of 3 builds, got %d of %d", s.done, s.total)
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/evanw/esbuild/pkg/api"
 	esbuild "github.com/evanw/esbuild/pkg/api"
+	"github.com/sst/ion/pkg/js"
 	"github.com/sst/ion/pkg/project/path"
 	"github.com/sst/ion/pkg/runtime"
 	"github.com/sst/ion/pkg/runtime/node"
@@ -93,7 +94,7 @@ func (w *Runtime) Build(ctx context.Context, input *runtime.BuildInput) (*runtim
       import handler from "%s"
       import { fromCloudflareEnv, wrapCloudflareHandler } from "sst"
       export default wrapCloudflareHandler(handler)
-      `, abs),
+      `, js.ImportPath(abs)),
 			ResolveDir: filepath.Dir(abs),
 			Loader:     esbuild.LoaderTS,
 		},
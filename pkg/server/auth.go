@@ -0,0 +1,49 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// These paths are called by clients that cannot attach an Authorization
+// header. Local function workers talk to /lambda/ through the Lambda runtime
+// API and the Console connects to /socket from the browser. The page of the
+// dashboard at /ui/ has nothing in it, it reads the token from its url and
+// sends it with the requests it makes.
+var public = []string{"/lambda/", "/socket", "/ui/"}
+
+func newToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Authorize attaches the server token to a request made to the server.
+func Authorize(r *http.Request, token string) {
+	if token == "" {
+		return
+	}
+	r.Header.Set("Authorization", "Bearer "+token)
+}
+
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, prefix := range public {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(s.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -1,10 +1,16 @@
 package server
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 
 	"github.com/sst/ion/pkg/project"
 )
@@ -31,12 +37,19 @@ func resolveServerFile(cfgPath, stage string) string {
 	return filepath.Join(project.ResolveWorkingDir(cfgPath), stage+".server")
 }
 
+func resolveTokenFile(cfgPath, stage string) string {
+	return filepath.Join(project.ResolveWorkingDir(cfgPath), stage+".server.token")
+}
+
 var ErrServerNotFound = errors.New("server not found")
 
 func Discover(cfgPath string, stage string) (string, error) {
 	if env := os.Getenv("SST_SERVER"); env != "" {
 		return env, nil
 	}
+	if local := resolveLocalAddress(cfgPath, stage); probeLocal(local) {
+		return localScheme + local, nil
+	}
 	resolved := resolveServerFile(cfgPath, stage)
 	contents, err := os.ReadFile(resolved)
 	if err != nil {
@@ -47,3 +60,116 @@ func Discover(cfgPath string, stage string) (string, error) {
 	}
 	return string(contents), nil
 }
+
+// Remote normalizes the address of a server running on another machine.
+// A bare host:port is assumed to be http, pass an https url to use TLS.
+func Remote(addr string) (string, error) {
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme: %s", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host: %s", addr)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+func DiscoverToken(cfgPath string, stage string) (string, error) {
+	if env := os.Getenv("SST_SERVER_TOKEN"); env != "" {
+		return env, nil
+	}
+	return readToken(cfgPath, stage)
+}
+
+func readToken(cfgPath string, stage string) (string, error) {
+	contents, err := os.ReadFile(resolveTokenFile(cfgPath, stage))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", ErrServerNotFound
+		}
+		return "", err
+	}
+	return string(contents), nil
+}
+
+// Stop asks the server at addr to shut down. It returns once the request is
+// accepted, the server drains its connections and exits shortly after.
+func Stop(ctx context.Context, addr string, token string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", BaseURL(addr)+"/shutdown", nil)
+	if err != nil {
+		return err
+	}
+	Authorize(req, token)
+	resp, err := HttpClient(addr).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+var ErrNothingToCancel = errors.New("nothing to cancel")
+
+// Cancel asks the server at addr to cancel the command it was started by,
+// the same as if it was interrupted.
+func Cancel(ctx context.Context, addr string, token string) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", BaseURL(addr)+"/cancel", nil)
+	if err != nil {
+		return err
+	}
+	Authorize(req, token)
+	resp, err := HttpClient(addr).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusAccepted:
+		return nil
+	case http.StatusConflict:
+		return ErrNothingToCancel
+	}
+	return fmt.Errorf("unexpected status: %s", resp.Status)
+}
+
+type VersionInfo struct {
+	Version string `json:"version"`
+}
+
+// ErrVersionUnknown is returned by a server from before /version was added.
+var ErrVersionUnknown = errors.New("server does not report its version")
+
+// FetchVersion returns the version of the CLI that started the server at addr.
+func FetchVersion(ctx context.Context, addr string, token string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", BaseURL(addr)+"/version", nil)
+	if err != nil {
+		return "", err
+	}
+	Authorize(req, token)
+	resp, err := HttpClient(addr).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrVersionUnknown
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var info VersionInfo
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	if err != nil {
+		return "", err
+	}
+	return info.Version, nil
+}
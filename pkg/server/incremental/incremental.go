@@ -0,0 +1,83 @@
+package incremental
+
+import (
+	"context"
+	"log/slog"
+	"net/rpc"
+
+	"github.com/sst/ion/pkg/flag"
+	"github.com/sst/ion/pkg/incremental"
+	"github.com/sst/ion/pkg/project"
+	"github.com/sst/ion/pkg/project/provider"
+)
+
+type Incremental struct {
+	store *incremental.Store
+	root  string
+}
+
+type HashInput struct {
+	Paths []string `json:"paths"`
+	Extra []string `json:"extra"`
+	// All hashes dependencies too, a function bundle has its node_modules
+	All bool `json:"all"`
+}
+
+func (i *Incremental) Hash(input *HashInput, output *string) error {
+	skip := incremental.Skip
+	if input.All {
+		skip = nil
+	}
+	hash, err := incremental.Hash(i.root, input.Paths, skip, input.Extra...)
+	if err != nil {
+		return err
+	}
+	*output = hash
+	return nil
+}
+
+type VersionInput struct {
+	Name string `json:"name"`
+	Hash string `json:"hash"`
+}
+
+func (i *Incremental) Version(input *VersionInput, output *string) error {
+	if flag.SST_NO_INCREMENTAL {
+		*output = ""
+		return nil
+	}
+	*output = i.store.Version(input.Name, input.Hash)
+	return nil
+}
+
+type PutInput struct {
+	Name    string `json:"name"`
+	Hash    string `json:"hash"`
+	Version string `json:"version"`
+}
+
+func (i *Incremental) Put(input *PutInput, output *bool) error {
+	err := i.store.Put(input.Name, input.Hash, input.Version)
+	if err != nil {
+		return err
+	}
+	*output = true
+	return nil
+}
+
+func Register(ctx context.Context, p *project.Project, r *rpc.Server) error {
+	app, stage := p.App().Name, p.App().Stage
+	entries := map[string]incremental.Entry{}
+	// without them everything is done again
+	err := provider.GetIncremental(p.Backend(), app, stage, &entries)
+	if err != nil {
+		slog.Warn("failed to read incremental hashes", "error", err)
+	}
+	r.RegisterName("Incremental", &Incremental{
+		root: p.PathRoot(),
+		store: incremental.New(entries, func(entries map[string]incremental.Entry) error {
+			return provider.PutIncremental(p.Backend(), app, stage, entries)
+		}),
+	})
+	return nil
+}
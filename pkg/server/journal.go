@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sst/ion/pkg/bus"
+)
+
+// JournalEntry is written for every published event so a run can be
+// reconstructed after the fact with `sst events replay`.
+type JournalEntry struct {
+	Time  time.Time       `json:"time"`
+	Type  string          `json:"type"`
+	Event json.RawMessage `json:"event"`
+}
+
+const journalMaxSize = 50 * 1024 * 1024
+const journalKeep = 5
+
+type journal struct {
+	dir  string
+	file *os.File
+	size int64
+}
+
+func (j *journal) rotate() error {
+	if j.file != nil {
+		j.file.Close()
+	}
+	name := "events-" + time.Now().UTC().Format("20060102T150405.000") + ".ndjson"
+	file, err := os.Create(filepath.Join(j.dir, name))
+	if err != nil {
+		return err
+	}
+	j.file = file
+	j.size = 0
+
+	matches, _ := filepath.Glob(filepath.Join(j.dir, "events-*.ndjson"))
+	sort.Strings(matches)
+	for len(matches) > journalKeep {
+		os.Remove(matches[0])
+		matches = matches[1:]
+	}
+	return nil
+}
+
+func (j *journal) write(event interface{}) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(&JournalEntry{
+		Time:  time.Now(),
+		Type:  bus.Topic(event),
+		Event: data,
+	})
+	if err != nil {
+		return err
+	}
+	if j.file == nil || j.size+int64(len(line)) > journalMaxSize {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := j.file.Write(append(line, '\n'))
+	j.size += int64(n)
+	return err
+}
+
+func record(ctx context.Context, dir string) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		slog.Error("failed to create event journal", "err", err)
+		return
+	}
+	events := bus.Subscribe[any](ctx)
+	j := &journal{dir: dir}
+	defer func() {
+		if j.file != nil {
+			j.file.Close()
+		}
+	}()
+	for event := range events {
+		// failing to write is logged, which would be forwarded and written again
+		if _, ok := event.(*LogEvent); ok {
+			continue
+		}
+		if err := j.write(event); err != nil {
+			slog.Error("failed to write event journal", "err", err)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sst/ion/pkg/network"
+)
+
+// IsLocal reports whether addr refers to the server's unix socket or named
+// pipe rather than a tcp url.
+func IsLocal(addr string) bool {
+	return strings.HasPrefix(addr, localScheme)
+}
+
+// BaseURL returns the url requests to addr should be made against. Local
+// addresses are dialed directly so the host is only a placeholder.
+func BaseURL(addr string) string {
+	if IsLocal(addr) {
+		return "http://sst"
+	}
+	return addr
+}
+
+// DialContext returns a dialer for local addresses and nil for tcp ones so
+// callers fall back to their default dialer.
+func DialContext(addr string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	if !IsLocal(addr) {
+		return nil
+	}
+	path := strings.TrimPrefix(addr, localScheme)
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return dialLocal(ctx, path)
+	}
+}
+
+// remoteClient is for servers on other machines, which can be behind a
+// proxy or use a private certificate authority.
+var remoteClient = network.Client(0)
+
+func HttpClient(addr string) *http.Client {
+	dial := DialContext(addr)
+	if dial == nil {
+		return remoteClient
+	}
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: dial,
+		},
+	}
+}
+
+func probeLocal(path string) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	conn, err := dialLocal(ctx, path)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
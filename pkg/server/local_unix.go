@@ -0,0 +1,48 @@
+//go:build !windows
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/sst/ion/pkg/project"
+)
+
+const localScheme = "unix://"
+
+// unix socket paths are limited to 104 bytes on darwin and 108 on linux
+const maxSocketPath = 104
+
+func resolveLocalAddress(cfgPath, stage string) string {
+	return filepath.Join(project.ResolveWorkingDir(cfgPath), stage+".sock")
+}
+
+func listenLocal(path string) (net.Listener, error) {
+	if len(path) >= maxSocketPath {
+		return nil, fmt.Errorf("socket path too long: %s", path)
+	}
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, 0600)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
+
+func dialLocal(ctx context.Context, path string) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, "unix", path)
+}
+
+func cleanupLocal(path string) {
+	os.Remove(path)
+}
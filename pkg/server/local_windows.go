@@ -0,0 +1,31 @@
+package server
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+	"github.com/sst/ion/pkg/project"
+)
+
+const localScheme = "npipe://"
+
+func resolveLocalAddress(cfgPath, stage string) string {
+	hash := sha1.Sum([]byte(project.ResolveWorkingDir(cfgPath)))
+	return `\\.\pipe\sst-` + hex.EncodeToString(hash[:])[:12] + "-" + stage
+}
+
+func listenLocal(path string) (net.Listener, error) {
+	return winio.ListenPipe(path, &winio.PipeConfig{
+		// only the current user can connect
+		SecurityDescriptor: "D:P(A;;GA;;;OW)",
+	})
+}
+
+func dialLocal(ctx context.Context, path string) (net.Conn, error) {
+	return winio.DialPipeContext(ctx, path)
+}
+
+func cleanupLocal(path string) {}
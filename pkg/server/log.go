@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+
+	"github.com/sst/ion/pkg/bus"
+)
+
+// LogEvent is a warning or an error the server logged. They are forwarded so
+// clients can show them, the rest of the log stays in the log file.
+type LogEvent struct {
+	Level   string
+	Message string
+	Attrs   map[string]string
+}
+
+type forwardHandler struct {
+	slog.Handler
+	attrs []slog.Attr
+}
+
+func (h *forwardHandler) Handle(ctx context.Context, record slog.Record) error {
+	if record.Level >= slog.LevelWarn {
+		evt := &LogEvent{
+			Level:   record.Level.String(),
+			Message: record.Message,
+			Attrs:   map[string]string{},
+		}
+		for _, attr := range h.attrs {
+			evt.Attrs[attr.Key] = attr.Value.String()
+		}
+		record.Attrs(func(attr slog.Attr) bool {
+			evt.Attrs[attr.Key] = attr.Value.String()
+			return true
+		})
+		// some of these are logged with the lock of a subscriber held
+		go bus.Publish(evt)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *forwardHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &forwardHandler{Handler: h.Handler.WithAttrs(attrs), attrs: append(slices.Clone(h.attrs), attrs...)}
+}
+
+func (h *forwardHandler) WithGroup(name string) slog.Handler {
+	return &forwardHandler{Handler: h.Handler.WithGroup(name), attrs: h.attrs}
+}
+
+// forwardLogs publishes the warnings and errors logged while the server runs
+// until the returned function is called.
+func forwardLogs() func() {
+	previous := slog.Default()
+	slog.SetDefault(slog.New(&forwardHandler{Handler: previous.Handler()}))
+	return func() {
+		slog.SetDefault(previous)
+	}
+}
@@ -0,0 +1,181 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pulumi/pulumi/sdk/v3/go/common/apitype"
+	"github.com/pulumi/pulumi/sdk/v3/go/common/resource"
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/network"
+	"github.com/sst/ion/pkg/project"
+)
+
+const (
+	attempts = 4
+	timeout  = 10 * time.Second
+)
+
+var client = network.Client(timeout)
+
+// update is the deploy or remove that is running, from its command event to
+// its complete event.
+type update struct {
+	command  *project.StackCommandEvent
+	started  time.Time
+	changes  []project.NotificationChange
+	finished bool
+}
+
+func (u *update) payload(event string) *project.NotificationPayload {
+	return &project.NotificationPayload{
+		Event:    event,
+		App:      u.command.App,
+		Stage:    u.command.Stage,
+		Command:  u.command.Command,
+		DeployID: u.command.DeployID,
+		Duration: time.Since(u.started).Seconds(),
+		Changes:  u.changes,
+	}
+}
+
+// Start sends the notifications in the app config for the updates of the
+// stage until ctx is done. Like hooks, the ones still being sent are waited
+// on, and an update that never completed is sent as a failure.
+func Start(ctx context.Context, p *project.Project) {
+	notifications := p.App().Notifications
+	if len(notifications) == 0 {
+		return
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	send := func(payload *project.NotificationPayload) {
+		for _, notification := range notifications {
+			if !notification.Wants(payload.Event) {
+				continue
+			}
+			wg.Add(1)
+			go func(notification project.AppNotification) {
+				defer wg.Done()
+				if err := post(&notification, payload); err != nil {
+					slog.Error("notification failed", "url", notification.URL, "event", payload.Event, "err", err)
+				}
+			}(notification)
+		}
+	}
+	var current *update
+	events := bus.Subscribe[any](ctx, bus.Topics(
+		&project.StackCommandEvent{},
+		&project.BuildFailedEvent{},
+		&project.CompleteEvent{},
+		&apitype.ResOutputsEvent{},
+	)...)
+	for event := range events {
+		switch evt := event.(type) {
+		case *project.StackCommandEvent:
+			current = nil
+			if evt.Dev || (evt.Command != "deploy" && evt.Command != "remove") {
+				continue
+			}
+			current = &update{command: evt, started: time.Now(), changes: []project.NotificationChange{}}
+			send(current.payload(project.NotificationStart))
+		case *apitype.ResOutputsEvent:
+			if current == nil {
+				continue
+			}
+			switch evt.Metadata.Op {
+			case apitype.OpCreate, apitype.OpUpdate, apitype.OpReplace, apitype.OpDelete, apitype.OpImport:
+				current.changes = append(current.changes, project.NotificationChange{
+					URN:  string(evt.Metadata.URN),
+					Name: resource.URN(evt.Metadata.URN).Name(),
+					Type: string(evt.Metadata.Type),
+					Op:   string(evt.Metadata.Op),
+				})
+			}
+		case *project.BuildFailedEvent:
+			if current == nil {
+				continue
+			}
+			payload := current.payload(project.NotificationFailure)
+			payload.Error = evt.Error
+			send(payload)
+			current = nil
+		case *project.CompleteEvent:
+			if current == nil || evt.Old {
+				continue
+			}
+			payload := current.payload(project.NotificationSuccess)
+			if len(evt.Errors) > 0 {
+				payload.Event = project.NotificationFailure
+				payload.Error = evt.Errors[0].Message
+			} else if !evt.Finished {
+				payload.Event = project.NotificationFailure
+				payload.Error = "The update was interrupted"
+			}
+			send(payload)
+			current = nil
+		}
+	}
+	if current != nil {
+		payload := current.payload(project.NotificationFailure)
+		payload.Error = "The update did not complete"
+		send(payload)
+	}
+}
+
+// post sends the notification, retrying with a backoff when the request
+// fails or the server returns a 429 or a 5xx.
+func post(notification *project.AppNotification, payload *project.NotificationPayload) error {
+	body, err := notification.Body(payload)
+	if err != nil {
+		return err
+	}
+	backoff := time.Second
+	for attempt := 1; ; attempt++ {
+		err = request(notification, body)
+		if err == nil || attempt == attempts {
+			return err
+		}
+		if errors.As(err, new(*permanentError)) {
+			return err
+		}
+		slog.Info("retrying notification", "url", notification.URL, "attempt", attempt, "err", err)
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// permanentError is a failure that retrying does not fix.
+type permanentError struct {
+	error
+}
+
+func request(notification *project.AppNotification, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, notification.URL, bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "sst")
+	for key, value := range notification.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if resp.StatusCode >= 400 {
+		return &permanentError{fmt.Errorf("status %d", resp.StatusCode)}
+	}
+	return nil
+}
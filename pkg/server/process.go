@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sst/ion/pkg/global"
+)
+
+// Process describes a running server. A copy is written to the global config
+// directory when the server starts so servers can be listed across projects.
+type Process struct {
+	PID     int       `json:"pid"`
+	App     string    `json:"app"`
+	Stage   string    `json:"stage"`
+	Config  string    `json:"config"`
+	Addr    string    `json:"addr"`
+	Version string    `json:"version"`
+	Started time.Time `json:"started"`
+	Clients int       `json:"clients"`
+}
+
+func processDir() string {
+	return filepath.Join(global.ConfigDir(), "server")
+}
+
+func processFile(pid int) string {
+	return filepath.Join(processDir(), fmt.Sprintf("%d.json", pid))
+}
+
+func writeProcess(proc *Process) (string, error) {
+	err := os.MkdirAll(processDir(), 0755)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(proc)
+	if err != nil {
+		return "", err
+	}
+	path := processFile(proc.PID)
+	return path, os.WriteFile(path, data, 0644)
+}
+
+// ListProcesses returns every server that has registered itself, including
+// ones that have since died without cleaning up.
+func ListProcesses() ([]*Process, error) {
+	entries, err := os.ReadDir(processDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	result := []*Process{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(processDir(), entry.Name()))
+		if err != nil {
+			continue
+		}
+		var proc Process
+		if err := json.Unmarshal(data, &proc); err != nil {
+			continue
+		}
+		result = append(result, &proc)
+	}
+	return result, nil
+}
+
+// RemoveProcess deletes the record for a server that is no longer running.
+func RemoveProcess(proc *Process) error {
+	err := os.Remove(processFile(proc.PID))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Token returns the token for the server described by proc. Unlike
+// DiscoverToken it ignores SST_SERVER_TOKEN since that belongs to whichever
+// server started the current process.
+func (proc *Process) Token() (string, error) {
+	return readToken(proc.Config, proc.Stage)
+}
+
+// Probe asks the server described by proc for its current state. An error
+// means the server is not reachable.
+func Probe(ctx context.Context, proc *Process) (*Process, error) {
+	token, err := proc.Token()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", BaseURL(proc.Addr)+"/process", nil)
+	if err != nil {
+		return nil, err
+	}
+	Authorize(req, token)
+	resp, err := HttpClient(proc.Addr).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	var result Process
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -8,6 +8,7 @@ import (
 	"log/slog"
 	"os"
 	"os/exec"
+	"runtime"
 	"strconv"
 
 	"github.com/sst/ion/cmd/sst/mosaic/ui/common"
@@ -70,6 +71,9 @@ func (r *Run) executeCommand(input *RunInputs) error {
 	r.lock.Acquire(context.Background(), 1)
 	defer r.lock.Release(1)
 	cmd := exec.Command("sh", "-c", input.Command)
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", input.Command)
+	}
 	cmd.Dir = input.Cwd
 	cmd.Env = os.Environ()
 	if len(input.Env) > 0 {
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -13,19 +14,50 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/flag"
 	"github.com/sst/ion/pkg/global"
+	"github.com/sst/ion/pkg/metrics"
 	"github.com/sst/ion/pkg/project"
 	"github.com/sst/ion/pkg/server/aws"
+	"github.com/sst/ion/pkg/server/incremental"
+	"github.com/sst/ion/pkg/server/notify"
 	"github.com/sst/ion/pkg/server/resource"
 	"github.com/sst/ion/pkg/server/runtime"
 	"github.com/sst/ion/pkg/server/scrap"
+	"github.com/sst/ion/pkg/server/workspace"
 )
 
 type Server struct {
-	Port int
-	Mux  *http.ServeMux
-	Rpc  *rpc.Server
+	Port  int
+	Token string
+	Mux   *http.ServeMux
+	Rpc   *rpc.Server
+	// Version is the CLI version the server was started by. Clients compare it
+	// against their own before consuming events.
+	Version string
+	// IdleTimeout stops the server once no clients have been connected for
+	// this long. Zero disables it.
+	IdleTimeout time.Duration
+	// OnCancel is called when a client asks to cancel the command the server
+	// was started by. Leave it unset if there is nothing to cancel.
+	OnCancel func()
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	process  Process
+	clients  atomic.Int32
+	active   atomic.Int64
+}
+
+// IdleShutdownEvent is published right before a server stops itself because
+// nothing has been connected to it for IdleTimeout.
+type IdleShutdownEvent struct {
+	Idle time.Duration
 }
 
 func New() (*Server, error) {
@@ -34,11 +66,28 @@ func New() (*Server, error) {
 	if err != nil {
 		return nil, err
 	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
 	result := &Server{
-		Port: port,
-		Mux:  http.NewServeMux(),
-		Rpc:  rpc.NewServer(),
+		Port:  port,
+		Token: token,
+		Mux:   http.NewServeMux(),
+		Rpc:   rpc.NewServer(),
+		stop:  make(chan struct{}),
 	}
+	result.Mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(&VersionInfo{Version: result.Version})
+	})
+	result.Mux.HandleFunc("/process", func(w http.ResponseWriter, r *http.Request) {
+		proc := result.process
+		proc.Clients = int(result.clients.Load())
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(proc)
+	})
+	result.Mux.Handle("/metrics", metrics.Handler())
 	result.Mux.HandleFunc("/rpc", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			w.WriteHeader(http.StatusMethodNotAllowed)
@@ -47,19 +96,110 @@ func New() (*Server, error) {
 		slog.Info("rpc request", "method", r.Method, "url", r.URL.String())
 		result.Rpc.ServeCodec(jsonrpc.NewServerCodec(&HttpConn{Reader: r.Body, Writer: w}))
 	})
+	result.Mux.HandleFunc("/shutdown", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		slog.Info("shutdown requested", "addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusAccepted)
+		result.Stop()
+	})
+	result.Mux.HandleFunc("/cancel", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if result.OnCancel == nil {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		slog.Info("cancel requested", "addr", r.RemoteAddr)
+		w.WriteHeader(http.StatusAccepted)
+		result.OnCancel()
+	})
 	return result, nil
 }
 
+// Track records a connected client until the returned function is called.
+func (s *Server) Track() func() {
+	s.clients.Add(1)
+	metrics.StreamClients.Inc()
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			s.active.Store(time.Now().UnixNano())
+			s.clients.Add(-1)
+			metrics.StreamClients.Dec()
+		})
+	}
+}
+
+func (s *Server) watchIdle(ctx context.Context) {
+	s.active.Store(time.Now().UnixNano())
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			if s.clients.Load() > 0 {
+				continue
+			}
+			idle := time.Since(time.Unix(0, s.active.Load()))
+			if idle < s.IdleTimeout {
+				continue
+			}
+			slog.Info("server idle, shutting down", "idle", idle)
+			bus.Publish(&IdleShutdownEvent{Idle: idle})
+			s.Stop()
+			return
+		}
+	}
+}
+
+// Stop asks a started server to drain its connections and exit. Start
+// returns once that is done so the caller can cancel everything else.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+}
+
 func (s *Server) Start(ctx context.Context, p *project.Project) error {
 	defer slog.Info("server done")
+	defer forwardLogs()()
+
+	go record(ctx, p.PathLog(""))
 
 	resource.Register(ctx, p, s.Rpc)
 	aws.Register(ctx, p, s.Rpc)
 	scrap.Register(ctx, p, s.Rpc)
 	runtime.Register(ctx, p, s.Rpc)
+	incremental.Register(ctx, p, s.Rpc)
+	workspace.Register(ctx, p, s.Rpc)
+
+	// notifications are waited on so the last ones of a deploy are sent
+	var notifications sync.WaitGroup
+	notifications.Add(1)
+	go func() {
+		defer notifications.Done()
+		notify.Start(ctx, p)
+	}()
+	defer notifications.Wait()
 
+	// requests are bound to drain rather than ctx so long lived streams can
+	// be told to disconnect before the listeners are closed
+	drain, cancelDrain := context.WithCancel(context.Background())
+	defer cancelDrain()
 	server := &http.Server{
-		Handler: s.Mux,
+		Handler: s.authorize(s.Mux),
+		BaseContext: func(net.Listener) context.Context {
+			return drain
+		},
 	}
 	server.Addr = fmt.Sprintf("0.0.0.0:%d", s.Port)
 	slog.Info("server", "addr", server.Addr)
@@ -67,7 +207,41 @@ func (s *Server) Start(ctx context.Context, p *project.Project) error {
 	u, _ := url.Parse("http://" + server.Addr)
 	os.WriteFile(serverPath, []byte(u.String()), 0644)
 	defer os.Remove(serverPath)
+	tokenPath := resolveTokenFile(p.PathConfig(), p.App().Stage)
+	os.WriteFile(tokenPath, []byte(s.Token), 0600)
+	defer os.Remove(tokenPath)
+
+	s.process = Process{
+		PID:     os.Getpid(),
+		App:     p.App().Name,
+		Stage:   p.App().Stage,
+		Config:  p.PathConfig(),
+		Addr:    u.String(),
+		Version: s.Version,
+		Started: time.Now(),
+	}
+	processPath, err := writeProcess(&s.process)
+	if err != nil {
+		slog.Error("failed to register server", "err", err)
+	}
+	defer os.Remove(processPath)
+
 	go server.ListenAndServe()
+	if s.IdleTimeout > 0 {
+		go s.watchIdle(ctx)
+	}
+
+	if !flag.SST_NO_SERVER_SOCKET {
+		localPath := resolveLocalAddress(p.PathConfig(), p.App().Stage)
+		listener, err := listenLocal(localPath)
+		if err != nil {
+			slog.Info("local listener unavailable", "err", err)
+		} else {
+			slog.Info("server", "local", localPath)
+			defer cleanupLocal(localPath)
+			go server.Serve(listener)
+		}
+	}
 
 	keyPath := filepath.Join(global.CertPath(), "key.pem")
 	certPath := filepath.Join(global.CertPath(), "cert.pem")
@@ -86,9 +260,15 @@ func (s *Server) Start(ctx context.Context, p *project.Project) error {
 		}
 	}
 
-	<-ctx.Done()
+	select {
+	case <-ctx.Done():
+	case <-s.stop:
+	}
 	slog.Info("shutting down server")
-	go server.Shutdown(ctx)
+	cancelDrain()
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	server.Shutdown(shutdownCtx)
 	return nil
 }
 
@@ -0,0 +1,64 @@
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"net/rpc"
+	"sync"
+
+	"github.com/sst/ion/pkg/bus"
+	"github.com/sst/ion/pkg/project"
+)
+
+type Workspace struct {
+	project *project.Project
+	mu      sync.Mutex
+	// outputs are read once per app for each deploy, they are cleared when
+	// the next one starts
+	outputs map[string]map[string]interface{}
+}
+
+type OutputInput struct {
+	App  string `json:"app"`
+	Name string `json:"name"`
+}
+
+func (w *Workspace) Output(input *OutputInput, output *interface{}) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	outputs, ok := w.outputs[input.App]
+	if !ok {
+		var err error
+		outputs, err = w.project.WorkspaceOutputs(input.App)
+		if err != nil {
+			return err
+		}
+		w.outputs[input.App] = outputs
+	}
+	value, ok := outputs[input.Name]
+	if !ok {
+		return fmt.Errorf("The %s app has no output named %s", input.App, input.Name)
+	}
+	*output = value
+	return nil
+}
+
+func (w *Workspace) reset() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.outputs = map[string]map[string]interface{}{}
+}
+
+func Register(ctx context.Context, p *project.Project, r *rpc.Server) error {
+	w := &Workspace{
+		project: p,
+		outputs: map[string]map[string]interface{}{},
+	}
+	go func() {
+		for range bus.Subscribe[*project.StackCommandEvent](ctx, bus.Topic(&project.StackCommandEvent{})) {
+			w.reset()
+		}
+	}()
+	r.RegisterName("Workspace", w)
+	return nil
+}
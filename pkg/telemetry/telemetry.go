@@ -14,6 +14,7 @@ import (
 	"github.com/sst/ion/internal/util"
 	"github.com/sst/ion/pkg/flag"
 	"github.com/sst/ion/pkg/global"
+	"github.com/sst/ion/pkg/network"
 )
 
 const (
@@ -105,7 +106,8 @@ var telemetryEnvironment = sync.OnceValue((func() map[string]interface{} {
 var client = (func() posthog.Client {
 	client, _ := posthog.NewWithConfig("phc_M0b2lW4smpsGIufiTBZ22USKwCy0fyqljMOGufJc79p",
 		posthog.Config{
-			Endpoint: "https://telemetry.ion.sst.dev",
+			Endpoint:  "https://telemetry.ion.sst.dev",
+			Transport: network.Transport(),
 		},
 	)
 	return client
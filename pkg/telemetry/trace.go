@@ -0,0 +1,110 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/sst/ion/pkg/network"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Tracing is not part of the anonymous telemetry above, the spans only go to
+// the collector the user points OTEL_EXPORTER_OTLP_ENDPOINT at. The exporter
+// reads the rest of the OTEL_EXPORTER_OTLP_* variables, like the headers.
+
+const tracerName = "github.com/sst/ion"
+
+func tracingEnabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// StartTracing sets up the otlp exporter if it is configured. The returned
+// function flushes the spans and has to be called before the cli exits.
+func StartTracing(ctx context.Context) func() {
+	if !tracingEnabled() {
+		return func() {}
+	}
+	options := []otlptracehttp.Option{}
+	if config := network.TLSConfig(); config != nil {
+		options = append(options, otlptracehttp.WithTLSClientConfig(config))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		slog.Error("failed to create trace exporter", "err", err)
+		return func() {}
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "sst"),
+			attribute.String("service.version", version),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		slog.Error("failed to create trace resource", "err", err)
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	slog.Info("tracing enabled")
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			slog.Error("failed to flush spans", "err", err)
+		}
+	}
+}
+
+// Span starts a span under the one in ctx. It is a no-op when tracing is not
+// enabled.
+func Span(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// EndSpan marks the span as failed if there was an error and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+type deploySpan struct {
+	span trace.Span
+}
+
+var deploy atomic.Value
+
+// SetDeploy makes span the parent of the work the platform asks for during a
+// deploy, like function builds, which comes in without it. It is cleared with
+// nil once the deploy is done.
+func SetDeploy(span trace.Span) {
+	deploy.Store(deploySpan{span})
+}
+
+// WithDeploy returns ctx under the span of the running deploy, unless ctx is
+// already part of a trace.
+func WithDeploy(ctx context.Context) context.Context {
+	if trace.SpanFromContext(ctx).SpanContext().IsValid() {
+		return ctx
+	}
+	current, ok := deploy.Load().(deploySpan)
+	if !ok || current.span == nil || !current.span.IsRecording() {
+		return ctx
+	}
+	return trace.ContextWithSpan(ctx, current.span)
+}
@@ -2,35 +2,171 @@ package tunnel
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net"
+	"sync"
+	"time"
 
 	"github.com/armon/go-socks5"
 	"github.com/sst/ion/cmd/sst/mosaic/ui"
 	"golang.org/x/crypto/ssh"
 )
 
-func StartProxy(ctx context.Context, username string, host string, key []byte) error {
+const (
+	keepaliveInterval = 10 * time.Second
+	keepaliveTimeout  = 5 * time.Second
+	maxBackoff        = 30 * time.Second
+)
+
+func sshConfig(username string, key []byte) (*ssh.ClientConfig, error) {
 	signer, err := ssh.ParsePrivateKey(key)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	config := &ssh.ClientConfig{
+	return &ssh.ClientConfig{
 		User: username,
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeys(signer),
 		},
 		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         10 * time.Second,
+	}, nil
+}
+
+func StartProxy(ctx context.Context, status *Status, username string, host string, key []byte) error {
+	config, err := sshConfig(username, key)
+	if err != nil {
+		return err
 	}
-	sshClient, err := ssh.Dial("tcp", host, config)
+	return serveProxy(ctx, status, "ssh", func(ctx context.Context) (*ssh.Client, error) {
+		return ssh.Dial("tcp", host, config)
+	})
+}
+
+// StartSSMProxy is StartProxy with the ssh connection going through the
+// local port of an ssm session started by ForwardSSM, instead of the public
+// ip of the instance.
+func StartSSMProxy(ctx context.Context, status *Status, username string, host string, key []byte) error {
+	config, err := sshConfig(username, key)
 	if err != nil {
 		return err
 	}
-	defer sshClient.Close()
+	return serveProxy(ctx, status, "ssm", func(ctx context.Context) (*ssh.Client, error) {
+		return ssh.Dial("tcp", host, config)
+	})
+}
+
+// connection is the ssh client the proxy dials through. It is replaced when
+// the keepalive finds it dead, usually after the machine was asleep.
+type connection struct {
+	mu     sync.RWMutex
+	client *ssh.Client
+	dial   func(ctx context.Context) (*ssh.Client, error)
+	status *Status
+}
+
+func (c *connection) current() *ssh.Client {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client
+}
+
+func (c *connection) setStatus(state string, err error) {
+	c.status.State = state
+	c.status.Since = time.Now()
+	c.status.Error = ""
+	if err != nil {
+		c.status.Error = err.Error()
+	}
+	if err := writeStatus(c.status); err != nil {
+		slog.Error("failed to write tunnel status", "error", err)
+	}
+}
+
+func alive(client *ssh.Client) error {
+	result := make(chan error, 1)
+	go func() {
+		_, _, err := client.SendRequest("keepalive@openssh.com", true, nil)
+		result <- err
+	}()
+	select {
+	case err := <-result:
+		return err
+	case <-time.After(keepaliveTimeout):
+		return errors.New("keepalive timed out")
+	}
+}
+
+func backoff(attempt int) time.Duration {
+	if attempt >= 5 {
+		return maxBackoff
+	}
+	return min(time.Second<<attempt, maxBackoff)
+}
+
+func (c *connection) keepalive(ctx context.Context) {
+	ticker := time.NewTicker(keepaliveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := alive(c.current())
+		if err == nil {
+			continue
+		}
+		slog.Warn("tunnel connection lost", "error", err)
+		fmt.Println(ui.TEXT_WARNING_BOLD.Render("| "), ui.TEXT_NORMAL.Render("Connection lost, reconnecting"))
+		c.current().Close()
+		c.setStatus(StateReconnecting, err)
+		for attempt := 0; ; attempt++ {
+			client, err := c.dial(ctx)
+			if err == nil {
+				c.mu.Lock()
+				c.client = client
+				c.mu.Unlock()
+				c.status.Reconnects++
+				c.setStatus(StateConnected, nil)
+				fmt.Println(ui.TEXT_SUCCESS_BOLD.Render("| "), ui.TEXT_NORMAL.Render("Reconnected"))
+				break
+			}
+			slog.Warn("tunnel reconnect failed", "attempt", attempt, "error", err)
+			c.setStatus(StateReconnecting, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff(attempt)):
+			}
+		}
+	}
+}
+
+func serveProxy(ctx context.Context, status *Status, transport string, dial func(ctx context.Context) (*ssh.Client, error)) (err error) {
+	status.Transport = transport
+	conn := &connection{dial: dial, status: status}
+	conn.setStatus(StateConnecting, nil)
+	defer func() {
+		conn.setStatus(StateStopped, err)
+	}()
+	client, err := dial(ctx)
+	if err != nil {
+		return err
+	}
+	conn.client = client
+	defer func() {
+		conn.current().Close()
+	}()
+	conn.setStatus(StateConnected, nil)
+	go conn.keepalive(ctx)
+
 	server, err := socks5.New(&socks5.Config{
 		Dial: func(ctx context.Context, network, addr string) (net.Conn, error) {
 			fmt.Println(ui.TEXT_INFO_BOLD.Render(("| "), ui.TEXT_NORMAL.Render("Tunneling", network, addr)))
-			return sshClient.Dial(network, addr)
+			return conn.current().Dial(network, addr)
 			// return net.Dial(network, addr)
 		},
 	})
@@ -39,7 +175,7 @@ func StartProxy(ctx context.Context, username string, host string, key []byte) e
 	}
 	errChan := make(chan error, 1)
 	go func() {
-		err := server.ListenAndServe("tcp", fmt.Sprintf("%s:%d", "127.0.0.1", 1080))
+		err := server.ListenAndServe("tcp", fmt.Sprintf("%s:%d", "127.0.0.1", status.ProxyPort()))
 		errChan <- err
 	}()
 	select {
@@ -0,0 +1,106 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sst/ion/internal/util"
+)
+
+// CheckSSM fails if the aws cli or the session manager plugin are missing.
+func CheckSSM() error {
+	for _, binary := range []string{"aws", "session-manager-plugin"} {
+		if _, err := exec.LookPath(binary); err != nil {
+			return util.NewReadableError(err, "The ssm tunnel needs the AWS CLI and its Session Manager plugin, could not find `"+binary+"` in your PATH. See https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html")
+		}
+	}
+	return nil
+}
+
+// ForwardSSM forwards a local port to port 22 of the instance through the
+// ssm agent running on it and returns the port once it accepts connections.
+// It runs as the user that started the tunnel, the root process only dials
+// the local port so it never runs the aws cli. The session is restarted when
+// it ends until ctx is done.
+func ForwardSSM(ctx context.Context, instance string, region string, env []string) (int, error) {
+	if instance == "" {
+		return 0, errors.New("the ssm tunnel needs the id of the bastion instance")
+	}
+	port, err := freePort()
+	if err != nil {
+		return 0, err
+	}
+	args := []string{
+		"ssm", "start-session",
+		"--target", instance,
+		"--document-name", "AWS-StartPortForwardingSession",
+		"--parameters", fmt.Sprintf("portNumber=22,localPortNumber=%d", port),
+	}
+	if region != "" {
+		args = append(args, "--region", region)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		for attempt := 0; ; attempt++ {
+			cmd := exec.CommandContext(ctx, "aws", args...)
+			cmd.Env = env
+			stderr := &strings.Builder{}
+			cmd.Stderr = stderr
+			started := time.Now()
+			err := cmd.Run()
+			if ctx.Err() != nil {
+				return
+			}
+			if output := strings.TrimSpace(stderr.String()); output != "" {
+				err = fmt.Errorf("%w: %s", err, output)
+			}
+			slog.Warn("ssm session ended", "instance", instance, "error", err)
+			select {
+			case exited <- err:
+			default:
+			}
+			// a session that ran for a while starts the backoff over
+			if time.Since(started) > maxBackoff {
+				attempt = 0
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff(attempt)):
+			}
+		}
+	}()
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	deadline := time.After(30 * time.Second)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, time.Second)
+		if err == nil {
+			conn.Close()
+			return port, nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case err := <-exited:
+			return 0, err
+		case <-deadline:
+			return 0, fmt.Errorf("timed out waiting for the ssm session to %s", instance)
+		case <-time.After(500 * time.Millisecond):
+		}
+	}
+}
+
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,246 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/sst/ion/internal/util"
+)
+
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateStopped      = "stopped"
+)
+
+// MaxTunnels is how many tunnels can run at once, the interfaces take the
+// addresses from 100.96.0.1 to 100.96.15.1. They are in the shared range of
+// carrier grade NAT, 172.16.0.0/12 is taken by docker and the default vpc.
+const MaxTunnels = 16
+
+// Status is a tunnel running on this machine, the routing table is the
+// status of all of them. Each tunnel gets a slot with its own interface,
+// address and socks port so the stages of different apps can be tunneled at
+// the same time. The tunnel runs as root, so this is kept in files next to
+// the tunnel binary for the other commands to read.
+type Status struct {
+	App        string    `json:"app"`
+	Stage      string    `json:"stage"`
+	Slot       int       `json:"slot"`
+	Subnets    []string  `json:"subnets"`
+	State      string    `json:"state"`
+	Transport  string    `json:"transport"`
+	Since      time.Time `json:"since"`
+	Reconnects int       `json:"reconnects"`
+	Error      string    `json:"error,omitempty"`
+	PID        int       `json:"pid"`
+}
+
+func (s *Status) Interface() string {
+	return interfaceName(s.Slot)
+}
+
+func (s *Status) Address() string {
+	return slotAddress(s.Slot).String()
+}
+
+func slotAddress(slot int) net.IP {
+	return net.IPv4(100, 96, byte(slot), 1)
+}
+
+// addressTaken reports if the address of the slot is in a network that is
+// already on an interface of this machine, or in one of the subnets that
+// would be routed through the tunnel.
+func addressTaken(slot int, subnets []string) bool {
+	ip := slotAddress(slot)
+	network := &net.IPNet{IP: ip.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		existing, ok := addr.(*net.IPNet)
+		if ok && (existing.Contains(ip) || network.Contains(existing.IP)) {
+			return true
+		}
+	}
+	_, _, ok := overlap([]string{network.String()}, subnets)
+	return ok
+}
+
+func (s *Status) ProxyPort() int {
+	return 1080 + s.Slot
+}
+
+func statusDir() string {
+	return filepath.Join(filepath.Dir(BINARY_PATH), "tunnels")
+}
+
+func statusPath(slot int) string {
+	return filepath.Join(statusDir(), strconv.Itoa(slot)+".json")
+}
+
+func writeStatus(status *Status) error {
+	data, err := json.Marshal(status)
+	if err != nil {
+		return err
+	}
+	tmp := statusPath(status.Slot) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, statusPath(status.Slot))
+}
+
+func readStatus(path string) (*Status, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var status Status
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// List returns the tunnels running on this machine by slot. Tunnels that
+// exited without cleaning up are left out.
+func List() ([]*Status, error) {
+	paths, err := filepath.Glob(filepath.Join(statusDir(), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	result := []*Status{}
+	for _, path := range paths {
+		status, err := readStatus(path)
+		// a slot that is being claimed is empty for a moment
+		if err != nil || !util.ProcessAlive(status.PID) {
+			continue
+		}
+		result = append(result, status)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Slot < result[j].Slot
+	})
+	return result, nil
+}
+
+// Find returns the tunnel for the stage of the app, or nil if there is none.
+func Find(app, stage string) (*Status, error) {
+	all, err := List()
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range all {
+		if status.App == app && status.Stage == stage {
+			return status, nil
+		}
+	}
+	return nil, nil
+}
+
+// CheckRoutes fails if the stage already has a tunnel, or if its ranges
+// overlap with the tunnel of another stage. The traffic to a range can only
+// be routed to one interface.
+func CheckRoutes(app, stage string, subnets []string) error {
+	all, err := List()
+	if err != nil {
+		return err
+	}
+	for _, status := range all {
+		if status.App == app && status.Stage == stage {
+			return util.NewReadableError(nil, fmt.Sprintf("There is already a tunnel for the %s stage of %s", stage, app))
+		}
+		if a, b, ok := overlap(subnets, status.Subnets); ok {
+			return util.NewReadableError(nil, fmt.Sprintf("The range %s overlaps with %s of the tunnel for the %s stage of %s. Stop that tunnel or give the vpc another cidr block.", a, b, status.Stage, status.App))
+		}
+	}
+	return nil
+}
+
+func overlap(a, b []string) (string, string, bool) {
+	for _, left := range a {
+		_, x, err := net.ParseCIDR(left)
+		if err != nil {
+			continue
+		}
+		for _, right := range b {
+			_, y, err := net.ParseCIDR(right)
+			if err != nil {
+				continue
+			}
+			if x.Contains(y.IP) || y.Contains(x.IP) {
+				return left, right, true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// Claim checks the routes and takes the first free slot for the tunnel. The
+// status file of the slot is created exclusively, so two tunnels starting at
+// the same time can not take the same one. Slots whose address is already in
+// use on this machine are skipped.
+func Claim(app, stage string, subnets []string) (*Status, error) {
+	if err := CheckRoutes(app, stage, subnets); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(statusDir(), 0755); err != nil {
+		return nil, err
+	}
+	for slot := 0; slot < MaxTunnels; slot++ {
+		path := statusPath(slot)
+		if existing, err := readStatus(path); err == nil && !util.ProcessAlive(existing.PID) {
+			os.Remove(path)
+		}
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err == nil && addressTaken(slot, subnets) {
+			file.Close()
+			os.Remove(path)
+			slog.Warn("skipping tunnel slot, its address is in use", "slot", slot, "address", slotAddress(slot))
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		file.Close()
+		status := &Status{
+			App:     app,
+			Stage:   stage,
+			Slot:    slot,
+			Subnets: subnets,
+			State:   StateConnecting,
+			Since:   time.Now(),
+			PID:     os.Getpid(),
+		}
+		if err := writeStatus(status); err != nil {
+			os.Remove(path)
+			return nil, err
+		}
+		return status, nil
+	}
+	return nil, util.NewReadableError(nil, fmt.Sprintf("There are already %d tunnels running, stop one of them first", MaxTunnels))
+}
+
+// Release frees the slot of the tunnel.
+func (s *Status) Release() error {
+	return os.Remove(statusPath(s.Slot))
+}
+
+// Key is how a tunnel is shown, the stage and the app it belongs to.
+func (s *Status) Key() string {
+	return s.App + "/" + s.Stage
+}
@@ -84,15 +84,15 @@ func runCommands(cmds [][]string) error {
 	return nil
 }
 
-func tun2socks(name string) {
+func tun2socks(status *Status) {
 	key := new(engine.Key)
-	key.Device = name
-	key.Proxy = "socks5://127.0.0.1:1080"
+	key.Device = status.Interface()
+	key.Proxy = fmt.Sprintf("socks5://127.0.0.1:%d", status.ProxyPort())
 	engine.Insert(key)
 	engine.Start()
 }
 
-func Stop() {
+func Stop(status *Status) {
 	engine.Stop()
-	destroy()
+	destroy(status)
 }
@@ -3,14 +3,15 @@ package tunnel
 import (
 	"log/slog"
 	"runtime"
+	"strconv"
 )
 
-func Start(routes ...string) error {
-	name := "utun69"
+func Start(status *Status, routes ...string) error {
+	name := status.Interface()
 	slog.Info("creating interface", "name", name, "os", runtime.GOOS)
-	tun2socks(name)
+	tun2socks(status)
 	cmds := [][]string{
-		{"ifconfig", "utun69", "172.16.0.1", "172.16.0.1", "netmask", "255.255.0.0", "up"},
+		{"ifconfig", name, status.Address(), status.Address(), "netmask", "255.255.255.0", "up"},
 	}
 	for _, route := range routes {
 		cmds = append(cmds, []string{
@@ -24,6 +25,10 @@ func Start(routes ...string) error {
 	return nil
 }
 
-func destroy() error {
+func destroy(status *Status) error {
 	return nil
 }
+
+func interfaceName(slot int) string {
+	return "utun" + strconv.Itoa(69+slot)
+}
@@ -3,14 +3,15 @@ package tunnel
 import (
 	"log/slog"
 	"runtime"
+	"strconv"
 )
 
-func Start(routes ...string) error {
-	name := resolveInterface()
+func Start(status *Status, routes ...string) error {
+	name := status.Interface()
 	slog.Info("creating interface", "name", name, "os", runtime.GOOS)
 	cmds := [][]string{
 		{"ip", "tuntap", "add", name, "mode", "tun"},
-		{"ip", "addr", "add", "172.16.0.1", "dev", name},
+		{"ip", "addr", "add", status.Address(), "dev", name},
 		{"ip", "link", "set", "dev", name, "up"},
 	}
 	for _, route := range routes {
@@ -22,18 +23,21 @@ func Start(routes ...string) error {
 	if err != nil {
 		return err
 	}
-	tun2socks(name)
+	tun2socks(status)
 	return nil
 }
 
-func destroy() error {
-	name := resolveInterface()
+func destroy(status *Status) error {
+	name := status.Interface()
 	return runCommands([][]string{
 		{"ip", "link", "set", "dev", name, "down"},
 		{"ip", "tuntap", "del", "dev", name, "mode", "tun"},
 	})
 }
 
-func resolveInterface() string {
-	return "sst"
+func interfaceName(slot int) string {
+	if slot == 0 {
+		return "sst"
+	}
+	return "sst" + strconv.Itoa(slot)
 }
@@ -0,0 +1,24 @@
+package tunnel
+
+import (
+	"strconv"
+
+	"github.com/sst/ion/internal/util"
+)
+
+// the tunnel needs a tun interface and sudo, neither of which it can set up
+// on windows yet. The rest of dev mode works without it.
+func Start(status *Status, routes ...string) error {
+	return util.NewReadableError(nil, "The tunnel is not supported on Windows yet, run `sst tunnel` from WSL instead")
+}
+
+func destroy(status *Status) error {
+	return nil
+}
+
+func interfaceName(slot int) string {
+	if slot == 0 {
+		return "sst"
+	}
+	return "sst" + strconv.Itoa(slot)
+}
@@ -0,0 +1,50 @@
+package dotenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/pkg/project/common"
+)
+
+// Template lists the SST_RESOURCE_ variables of the linked resources, with
+// the shape of their values in a comment. The values are left out since they
+// can be secret, `sst shell` sets them.
+func Template(links common.Links) []byte {
+	var builder strings.Builder
+	builder.WriteString("# Automatically generated by `sst types`. Each variable is the JSON of a linked resource,\n")
+	builder.WriteString("# run your command with `sst shell` to have them set.\n")
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		shape, _ := json.Marshal(describe(links[name].Properties))
+		builder.WriteString(fmt.Sprintf("\n# %s\nSST_RESOURCE_%s=\n", shape, name))
+	}
+	return []byte(builder.String())
+}
+
+// describe replaces the values with their types.
+func describe(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := map[string]interface{}{}
+		for key, item := range v {
+			result[key] = describe(item)
+		}
+		return result
+	case string:
+		return "string"
+	case float64, float32, int:
+		return "number"
+	case bool:
+		return "boolean"
+	case []interface{}:
+		return "array"
+	}
+	return "any"
+}
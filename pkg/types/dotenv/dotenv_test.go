@@ -0,0 +1,22 @@
+package dotenv
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sst/ion/pkg/project/common"
+)
+
+func TestTemplate(t *testing.T) {
+	source := string(Template(common.Links{
+		"MyBucket": {Properties: map[string]interface{}{"name": "secret-name", "size": float64(2)}},
+		"App":      {Properties: map[string]interface{}{"name": "web"}},
+	}))
+	expected := "\n# {\"name\":\"string\"}\nSST_RESOURCE_App=\n\n# {\"name\":\"string\",\"size\":\"number\"}\nSST_RESOURCE_MyBucket=\n"
+	if !strings.HasSuffix(source, expected) {
+		t.Errorf("Expected the variables in\n%s", source)
+	}
+	if strings.Contains(source, "secret-name") {
+		t.Error("Expected no values in the template")
+	}
+}
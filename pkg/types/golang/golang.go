@@ -0,0 +1,75 @@
+package golang
+
+import (
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/pkg/project/common"
+	"github.com/sst/ion/pkg/types/naming"
+)
+
+// Bindings generates a Go package with a function for each linked resource
+// that reads it from its SST_RESOURCE_ variable into a struct.
+func Bindings(pkg string, links common.Links) ([]byte, error) {
+	var builder strings.Builder
+	builder.WriteString("// Code generated by sst types. DO NOT EDIT.\n\n")
+	builder.WriteString("package " + pkg + "\n\n")
+	builder.WriteString("import (\n\t\"encoding/json\"\n\t\"fmt\"\n\t\"os\"\n)\n\n")
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typeName := naming.Pascal(name) + "Resource"
+		builder.WriteString("type " + typeName + " " + infer(links[name].Properties, "") + "\n\n")
+		builder.WriteString(fmt.Sprintf("// %s reads the linked %s from SST_RESOURCE_%s.\n", naming.Pascal(name), name, name))
+		builder.WriteString(fmt.Sprintf("func %s() (*%s, error) {\n\tresult := &%s{}\n\treturn result, load(%q, result)\n}\n\n", naming.Pascal(name), typeName, typeName, name))
+	}
+	builder.WriteString(strings.Join([]string{
+		"func load(name string, out interface{}) error {",
+		"\tvalue, ok := os.LookupEnv(\"SST_RESOURCE_\" + name)",
+		"\tif !ok {",
+		"\t\treturn fmt.Errorf(\"%s is not linked, SST_RESOURCE_%s is not set\", name, name)",
+		"\t}",
+		"\tif err := json.Unmarshal([]byte(value), out); err != nil {",
+		"\t\treturn fmt.Errorf(\"SST_RESOURCE_%s is not valid: %w\", name, err)",
+		"\t}",
+		"\treturn nil",
+		"}",
+		"",
+	}, "\n"))
+	return format.Source([]byte(builder.String()))
+}
+
+func infer(input map[string]interface{}, indent string) string {
+	var builder strings.Builder
+	builder.WriteString("struct {\n")
+	keys := make([]string, 0, len(input))
+	for key := range input {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		builder.WriteString(indent + "\t" + naming.Pascal(key) + " ")
+		switch v := input[key].(type) {
+		case string:
+			builder.WriteString("string")
+		case float64, float32, int:
+			builder.WriteString("float64")
+		case bool:
+			builder.WriteString("bool")
+		case []interface{}:
+			builder.WriteString("[]interface{}")
+		case map[string]interface{}:
+			builder.WriteString(infer(v, indent+"\t"))
+		default:
+			builder.WriteString("interface{}")
+		}
+		builder.WriteString(fmt.Sprintf(" `json:%q`\n", key))
+	}
+	builder.WriteString(indent + "}")
+	return builder.String()
+}
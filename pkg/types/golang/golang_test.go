@@ -0,0 +1,39 @@
+package golang
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sst/ion/pkg/project/common"
+)
+
+func TestBindings(t *testing.T) {
+	data, err := Bindings("sstresource", common.Links{
+		"MyBucket": {Properties: map[string]interface{}{
+			"name": "bucket",
+			"size": float64(3),
+			"tags": []interface{}{"a"},
+			"config": map[string]interface{}{
+				"public-read": true,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := string(data)
+	for _, part := range []string{
+		"package sstresource",
+		"type MyBucketResource struct {",
+		"Config struct {\n\t\tPublicRead bool `json:\"public-read\"`\n\t} `json:\"config\"`",
+		"Name string        `json:\"name\"`",
+		"Size float64       `json:\"size\"`",
+		"Tags []interface{} `json:\"tags\"`",
+		"func MyBucket() (*MyBucketResource, error) {",
+		"return result, load(\"MyBucket\", result)",
+	} {
+		if !strings.Contains(source, part) {
+			t.Errorf("Expected %q in\n%s", part, source)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Package naming turns the names of linked resources and their properties
+// into identifiers of the languages bindings are generated for.
+package naming
+
+import (
+	"strings"
+	"unicode"
+)
+
+// words splits a name on anything that is not a letter or a digit and where
+// the case changes, so MyBucket, my_bucket and my-bucket are the same words.
+func words(name string) []string {
+	result := []string{}
+	current := []rune{}
+	runes := []rune(name)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			if len(current) > 0 {
+				result = append(result, string(current))
+				current = []rune{}
+			}
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				result = append(result, string(current))
+				current = []rune{}
+			}
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		result = append(result, string(current))
+	}
+	return result
+}
+
+// Pascal is MyBucket for a type or an exported name.
+func Pascal(name string) string {
+	var builder strings.Builder
+	for _, word := range words(name) {
+		runes := []rune(word)
+		builder.WriteString(strings.ToUpper(string(runes[0])) + string(runes[1:]))
+	}
+	return prefix(builder.String())
+}
+
+// Snake is my_bucket for a function or a field.
+func Snake(name string) string {
+	parts := []string{}
+	for _, word := range words(name) {
+		parts = append(parts, strings.ToLower(word))
+	}
+	return prefix(strings.Join(parts, "_"))
+}
+
+// prefix makes sure the identifier does not start with a digit and is not
+// empty.
+func prefix(name string) string {
+	if name == "" {
+		return "_"
+	}
+	if unicode.IsDigit([]rune(name)[0]) {
+		return "_" + name
+	}
+	return name
+}
+
+// Avoid appends an underscore to names that are keywords.
+func Avoid(name string, keywords map[string]bool) string {
+	if keywords[name] {
+		return name + "_"
+	}
+	return name
+}
@@ -0,0 +1,30 @@
+package naming
+
+import "testing"
+
+func TestNames(t *testing.T) {
+	cases := []struct {
+		input  string
+		pascal string
+		snake  string
+	}{
+		{"MyBucket", "MyBucket", "my_bucket"},
+		{"my-bucket", "MyBucket", "my_bucket"},
+		{"apiURL", "ApiURL", "api_url"},
+		{"URLPrefix", "URLPrefix", "url_prefix"},
+		{"queue2", "Queue2", "queue2"},
+		{"3d", "_3d", "_3d"},
+		{"", "_", "_"},
+	}
+	for _, c := range cases {
+		if got := Pascal(c.input); got != c.pascal {
+			t.Errorf("Pascal(%q) = %q, want %q", c.input, got, c.pascal)
+		}
+		if got := Snake(c.input); got != c.snake {
+			t.Errorf("Snake(%q) = %q, want %q", c.input, got, c.snake)
+		}
+	}
+	if got := Avoid("type", map[string]bool{"type": true}); got != "type_" {
+		t.Errorf("Avoid(type) = %q", got)
+	}
+}
@@ -0,0 +1,106 @@
+package python
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/pkg/project/common"
+	"github.com/sst/ion/pkg/types/naming"
+)
+
+var keywords = map[string]bool{
+	"False": true, "None": true, "True": true, "and": true, "as": true, "assert": true,
+	"async": true, "await": true, "break": true, "class": true, "continue": true, "def": true,
+	"del": true, "elif": true, "else": true, "except": true, "finally": true, "for": true,
+	"from": true, "global": true, "if": true, "import": true, "in": true, "is": true,
+	"lambda": true, "nonlocal": true, "not": true, "or": true, "pass": true, "raise": true,
+	"return": true, "try": true, "while": true, "with": true, "yield": true,
+}
+
+// Bindings generates a module with a dataclass for each linked resource and
+// a Resource class to read them from their SST_RESOURCE_ variables. Unlike
+// the sst.pyi stubs it does not need the sst package.
+func Bindings(links common.Links) []byte {
+	var builder strings.Builder
+	builder.WriteString(strings.Join([]string{
+		"# Automatically generated by `sst types`, do not edit.",
+		"# pylint: disable=all",
+		"import json",
+		"import os",
+		"from dataclasses import dataclass",
+		"from typing import Any, List",
+		"",
+		"",
+		"def _load(name: str) -> dict:",
+		"    value = os.environ.get(\"SST_RESOURCE_\" + name)",
+		"    if value is None:",
+		"        raise KeyError(f\"{name} is not linked, SST_RESOURCE_{name} is not set\")",
+		"    return json.loads(value)",
+		"",
+	}, "\n"))
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dataclass(&builder, naming.Pascal(name)+"Resource", links[name].Properties)
+	}
+	builder.WriteString("\n\nclass Resource:\n")
+	if len(names) == 0 {
+		builder.WriteString("    pass\n")
+	}
+	for i, name := range names {
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		className := naming.Pascal(name) + "Resource"
+		builder.WriteString("    @staticmethod\n")
+		builder.WriteString(fmt.Sprintf("    def %s() -> \"%s\":\n", naming.Avoid(name, keywords), className))
+		builder.WriteString(fmt.Sprintf("        return %s.from_dict(_load(%q))\n", className, name))
+	}
+	return []byte(builder.String())
+}
+
+// dataclass writes the classes of the nested objects first so they are
+// defined when the ones that use them are.
+func dataclass(builder *strings.Builder, className string, properties map[string]interface{}) {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if nested, ok := properties[key].(map[string]interface{}); ok {
+			dataclass(builder, className+naming.Pascal(key), nested)
+		}
+	}
+	builder.WriteString("\n\n@dataclass\nclass " + className + ":\n")
+	fields := []string{}
+	for _, key := range keys {
+		field := naming.Avoid(naming.Snake(key), keywords)
+		value := fmt.Sprintf("data.get(%q)", key)
+		kind := bindingType(properties[key])
+		if _, ok := properties[key].(map[string]interface{}); ok {
+			kind = className + naming.Pascal(key)
+			value = fmt.Sprintf("%s.from_dict(data.get(%q) or {})", kind, key)
+		}
+		builder.WriteString(fmt.Sprintf("    %s: \"%s\"\n", field, kind))
+		fields = append(fields, fmt.Sprintf("%s=%s", field, value))
+	}
+	if len(keys) > 0 {
+		builder.WriteString("\n")
+	}
+	builder.WriteString("    @staticmethod\n")
+	builder.WriteString(fmt.Sprintf("    def from_dict(data: dict) -> \"%s\":\n", className))
+	builder.WriteString(fmt.Sprintf("        return %s(%s)\n", className, strings.Join(fields, ", ")))
+}
+
+func bindingType(value interface{}) string {
+	switch value.(type) {
+	case []interface{}:
+		return "List[Any]"
+	}
+	return inferType(value)
+}
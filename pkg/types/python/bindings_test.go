@@ -0,0 +1,32 @@
+package python
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sst/ion/pkg/project/common"
+)
+
+func TestBindings(t *testing.T) {
+	source := string(Bindings(common.Links{
+		"MyBucket": {Properties: map[string]interface{}{
+			"name":   "bucket",
+			"class":  "standard",
+			"config": map[string]interface{}{"public-read": true},
+		}},
+	}))
+	for _, part := range []string{
+		"class MyBucketResourceConfig:\n    public_read: \"bool\"",
+		"class MyBucketResource:\n    class_: \"str\"\n    config: \"MyBucketResourceConfig\"\n    name: \"str\"",
+		"return MyBucketResource(class_=data.get(\"class\"), config=MyBucketResourceConfig.from_dict(data.get(\"config\") or {}), name=data.get(\"name\"))",
+		"    def MyBucket() -> \"MyBucketResource\":\n        return MyBucketResource.from_dict(_load(\"MyBucket\"))",
+	} {
+		if !strings.Contains(source, part) {
+			t.Errorf("Expected %q in\n%s", part, source)
+		}
+	}
+	// the nested class is defined before the one that uses it
+	if strings.Index(source, "class MyBucketResourceConfig") > strings.Index(source, "class MyBucketResource:") {
+		t.Errorf("Expected the nested class first in\n%s", source)
+	}
+}
@@ -0,0 +1,100 @@
+package rust
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sst/ion/pkg/project/common"
+	"github.com/sst/ion/pkg/types/naming"
+)
+
+var keywords = map[string]bool{
+	"as": true, "async": true, "await": true, "break": true, "const": true, "continue": true,
+	"crate": true, "dyn": true, "else": true, "enum": true, "extern": true, "false": true,
+	"fn": true, "for": true, "if": true, "impl": true, "in": true, "let": true, "loop": true,
+	"match": true, "mod": true, "move": true, "mut": true, "pub": true, "ref": true,
+	"return": true, "self": true, "static": true, "struct": true, "super": true, "trait": true,
+	"true": true, "type": true, "unsafe": true, "use": true, "where": true, "while": true,
+}
+
+// Bindings generates a module with a struct for each linked resource and a
+// function to read it from its SST_RESOURCE_ variable. It needs the serde,
+// with derive, and serde_json crates.
+func Bindings(links common.Links) []byte {
+	var builder strings.Builder
+	builder.WriteString(strings.Join([]string{
+		"// Automatically generated by `sst types`, do not edit.",
+		"#![allow(dead_code)]",
+		"",
+		"use serde::Deserialize;",
+		"",
+		"#[derive(Debug)]",
+		"pub enum Error {",
+		"    NotLinked(String),",
+		"    Invalid(String, serde_json::Error),",
+		"}",
+		"",
+		"impl std::fmt::Display for Error {",
+		"    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {",
+		"        match self {",
+		"            Error::NotLinked(name) => write!(f, \"{} is not linked, SST_RESOURCE_{} is not set\", name, name),",
+		"            Error::Invalid(name, err) => write!(f, \"SST_RESOURCE_{} is not valid: {}\", name, err),",
+		"        }",
+		"    }",
+		"}",
+		"",
+		"impl std::error::Error for Error {}",
+		"",
+		"fn load<T: serde::de::DeserializeOwned>(name: &str) -> Result<T, Error> {",
+		"    let value = std::env::var(format!(\"SST_RESOURCE_{}\", name)).map_err(|_| Error::NotLinked(name.to_string()))?;",
+		"    serde_json::from_str(&value).map_err(|err| Error::Invalid(name.to_string(), err))",
+		"}",
+		"",
+	}, "\n"))
+	names := make([]string, 0, len(links))
+	for name := range links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		typeName := naming.Pascal(name) + "Resource"
+		structs(&builder, typeName, links[name].Properties)
+		builder.WriteString(fmt.Sprintf("\n/// Reads the linked %s from SST_RESOURCE_%s.\n", name, name))
+		builder.WriteString(fmt.Sprintf("pub fn %s() -> Result<%s, Error> {\n    load(%q)\n}\n", naming.Avoid(naming.Snake(name), keywords), typeName, name))
+	}
+	return []byte(builder.String())
+}
+
+func structs(builder *strings.Builder, typeName string, properties map[string]interface{}) {
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if nested, ok := properties[key].(map[string]interface{}); ok {
+			structs(builder, typeName+naming.Pascal(key), nested)
+		}
+	}
+	builder.WriteString("\n#[derive(Debug, Clone, Deserialize)]\n")
+	builder.WriteString("pub struct " + typeName + " {\n")
+	for _, key := range keys {
+		kind := "serde_json::Value"
+		switch properties[key].(type) {
+		case string:
+			kind = "String"
+		case float64, float32, int:
+			kind = "f64"
+		case bool:
+			kind = "bool"
+		case []interface{}:
+			kind = "Vec<serde_json::Value>"
+		case map[string]interface{}:
+			kind = typeName + naming.Pascal(key)
+		}
+		builder.WriteString(fmt.Sprintf("    #[serde(rename = %q)]\n", key))
+		builder.WriteString(fmt.Sprintf("    pub %s: %s,\n", naming.Avoid(naming.Snake(key), keywords), kind))
+	}
+	builder.WriteString("}\n")
+}
@@ -0,0 +1,29 @@
+package rust
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sst/ion/pkg/project/common"
+)
+
+func TestBindings(t *testing.T) {
+	source := string(Bindings(common.Links{
+		"MyBucket": {Properties: map[string]interface{}{
+			"type":   "bucket",
+			"size":   float64(2),
+			"config": map[string]interface{}{"public-read": true},
+		}},
+	}))
+	for _, part := range []string{
+		"pub struct MyBucketResourceConfig {\n    #[serde(rename = \"public-read\")]\n    pub public_read: bool,\n}",
+		"    pub config: MyBucketResourceConfig,",
+		"    pub size: f64,",
+		"    #[serde(rename = \"type\")]\n    pub type_: String,",
+		"pub fn my_bucket() -> Result<MyBucketResource, Error> {\n    load(\"MyBucket\")\n}",
+	} {
+		if !strings.Contains(source, part) {
+			t.Errorf("Expected %q in\n%s", part, source)
+		}
+	}
+}
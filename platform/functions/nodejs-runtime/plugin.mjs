@@ -23,7 +23,19 @@ const loaderToString = [
   "tsx",
 ];
 
-const plugins = await import(process.argv[2]);
+// The default export is a list of plugins or, for a config hook, the config
+// or a function that returns it.
+let config = {};
+let error;
+try {
+  const mod = await import(process.argv[2]);
+  config = mod.default;
+  if (typeof config === "function")
+    config = await config(JSON.parse(process.argv[3] || "{}"));
+} catch (e) {
+  error = e instanceof Error ? e.message : String(e);
+}
+const plugins = Array.isArray(config) ? config : config?.plugins ?? [];
 
 const onResolve = [];
 const onLoad = [];
@@ -41,10 +53,16 @@ const stubAPI = {
   },
 };
 
-for (const plugin of plugins.default) {
+for (const plugin of plugins) {
   plugin.setup(stubAPI);
 }
 
+function matches(options, value) {
+  if (options.namespace && options.namespace !== (value.namespace || "file"))
+    return false;
+  return new RegExp(options.filter).test(value.path);
+}
+
 const rl = createInterface({ input, output, terminal: false });
 
 rl.on("line", async (line) => {
@@ -53,9 +71,21 @@ rl.on("line", async (line) => {
   new Promise(async () => {
     let reply;
 
+    if (msg.command === "config") {
+      reply = error
+        ? { error }
+        : {
+            define: config?.define,
+            external: config?.external,
+            banner: config?.banner,
+            footer: config?.footer,
+            plugins: plugins.length,
+          };
+    }
+
     if (msg.command === "resolve") {
       for (const { options, callback } of onResolve) {
-        if (new RegExp(options.filter).test(msg.path)) {
+        if (matches(options, msg.value)) {
           reply = await callback(msg.value);
           if (reply) break;
         }
@@ -64,7 +94,7 @@ rl.on("line", async (line) => {
 
     if (msg.command === "load") {
       for (const { options, callback } of onLoad) {
-        if (new RegExp(options.filter).test(msg.path)) {
+        if (matches(options, msg.value)) {
           reply = await callback(msg.value);
           if (reply) break;
         }
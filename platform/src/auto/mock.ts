@@ -0,0 +1,87 @@
+import { ResourceTransformationArgs, runtime } from "@pulumi/pulumi";
+
+interface Mocked {
+  type: string;
+  name: string;
+  parent?: { type: string; name: string };
+  outputs?: Record<string, any>;
+}
+
+/**
+ * Offline mode evaluates the app with mocked resources, so it knows the
+ * functions and routes of the app without deploying it or a cloud account.
+ * The outputs that wire a function to its routes are made up, everything
+ * else is the inputs of the resource. The resources are printed once the
+ * program is done.
+ */
+export async function mock() {
+  const resources: Record<string, Mocked> = {};
+  const key = (type: string, name: string) => `${type}::${name}`;
+
+  await runtime.setMocks(
+    {
+      newResource(args) {
+        const outputs = {
+          ...args.inputs,
+          ...computed(args.type, args.name, args.inputs),
+        };
+        const item = (resources[key(args.type, args.name)] ??= {
+          type: args.type,
+          name: args.name,
+        });
+        item.outputs = outputs;
+        return { id: outputs.id ?? args.name, state: outputs };
+      },
+      call(args) {
+        return {
+          accountId: "000000000000",
+          arn: "arn:aws:iam::000000000000:user/offline",
+          name: "us-east-1",
+          region: "us-east-1",
+          partition: "aws",
+          dnsSuffix: "amazonaws.com",
+          ...args.inputs,
+        };
+      },
+    },
+    $app.name,
+    $app.stage,
+    false,
+  );
+
+  // the mocks are not told the parent of a resource
+  runtime.registerStackTransformation((args: ResourceTransformationArgs) => {
+    const parent = args.opts.parent as any;
+    const item = (resources[key(args.type, args.name)] ??= {
+      type: args.type,
+      name: args.name,
+    });
+    if (parent?.__pulumiType && parent?.__name)
+      item.parent = { type: parent.__pulumiType, name: parent.__name };
+    return undefined;
+  });
+
+  return async function done() {
+    await runtime.disconnect();
+    console.log("~m" + JSON.stringify(Object.values(resources)));
+  };
+}
+
+function computed(type: string, name: string, inputs: Record<string, any>) {
+  switch (type) {
+    case "aws:lambda/function:Function": {
+      const functionName = inputs.name ?? name;
+      const arn = `arn:aws:lambda:offline:000000000000:function:${functionName}`;
+      return {
+        name: functionName,
+        arn,
+        invokeArn: `arn:aws:apigateway:offline:lambda:path/2015-03-31/functions/${arn}/invocations`,
+      };
+    }
+    case "aws:lambda/functionUrl:FunctionUrl":
+      return {
+        functionUrl: `https://${name}.lambda-url.offline.on.aws/`,
+      };
+  }
+  return { id: name };
+}
@@ -11,11 +11,13 @@ import { VisibleError } from "../components/error";
 import { dynamodb } from "@pulumi/aws";
 import { Linkable } from "../components";
 import { permission } from "../components/aws/permission.js";
+import { permission as gcpPermission } from "../components/gcp/permission.js";
 
 export async function run(program: automation.PulumiFn) {
   process.chdir($cli.paths.root);
 
   addTransformationToRetainResourcesOnDelete();
+  addTransformationToProtectResources();
   addTransformationToAddTags();
   addTransformationToCheckBucketsHaveMultiplePolicies();
 
@@ -28,11 +30,34 @@ export async function run(program: automation.PulumiFn) {
       }),
     ],
   }));
+  if ($app.providers?.gcp) await wrapGcpResources();
   Link.reset();
   const outputs = (await program()) || {};
   return outputs;
 }
 
+async function wrapGcpResources() {
+  const gcp = await import("@pulumi/gcp");
+  Linkable.wrap(gcp.storage.Bucket, (item) => ({
+    properties: { name: item.name },
+    include: [
+      gcpPermission({
+        role: "roles/storage.objectAdmin",
+        resource: interpolate`projects/_/buckets/${item.name}`,
+      }),
+    ],
+  }));
+  Linkable.wrap(gcp.pubsub.Topic, (item) => ({
+    properties: { name: item.name, id: item.id },
+    include: [
+      gcpPermission({
+        role: "roles/pubsub.publisher",
+        resource: item.id,
+      }),
+    ],
+  }));
+}
+
 function addTransformationToRetainResourcesOnDelete() {
   runtime.registerStackTransformation((args: ResourceTransformationArgs) => {
     if (
@@ -43,6 +68,7 @@ function addTransformationToRetainResourcesOnDelete() {
           "aws:rds/instance:Instance",
           "aws:s3/bucket:Bucket",
           "aws:s3/bucketV2:BucketV2",
+          "gcp:storage/bucket:Bucket",
         ].includes(args.type))
     ) {
       args.opts.retainOnDelete = true;
@@ -52,6 +78,35 @@ function addTransformationToRetainResourcesOnDelete() {
   });
 }
 
+function addTransformationToProtectResources() {
+  // the cli normalizes `protect: true` to `{ all: true }`
+  const protect = ($app as any).protect as {
+    all: boolean;
+    types: string[] | null;
+  } | null;
+  // with --force-unprotect the protected resources can be deleted this once
+  if (!protect || $cli.unprotect) return;
+  const patterns = (protect.types ?? []).map(
+    (pattern) =>
+      new RegExp(
+        "^" +
+          pattern
+            .split("*")
+            .map((part) => part.replace(/[.+?^${}()|[\]\\]/g, "\\$&"))
+            .join("[^/]*") +
+          "$",
+      ),
+  );
+  runtime.registerStackTransformation((args: ResourceTransformationArgs) => {
+    if (args.type.startsWith("pulumi:providers:")) return undefined;
+    if (protect.all || patterns.some((pattern) => pattern.test(args.type))) {
+      args.opts.protect = true;
+      return args;
+    }
+    return undefined;
+  });
+}
+
 function addTransformationToAddTags() {
   runtime.registerStackTransformation((args: ResourceTransformationArgs) => {
     if ("import" in args.opts && args.opts.import) {
@@ -59,6 +114,15 @@ function addTransformationToAddTags() {
       args.opts.ignoreChanges.push("tags");
       args.opts.ignoreChanges.push("tagsAll");
     }
+    // the azure provider has no default tags like aws, so the resource groups
+    // are tagged with the stage instead
+    if (args.type === "azure:core/resourceGroup:ResourceGroup") {
+      args.props.tags = output(args.props.tags).apply((tags) => ({
+        "sst:app": $app.name,
+        "sst:stage": $app.stage,
+        ...tags,
+      }));
+    }
     return args;
   });
 }
@@ -286,6 +286,53 @@ export interface ClusterServiceArgs {
        * @default Uses the `image.dockerfile` path
        */
       directory?: Input<string>;
+      /**
+       * Run the `image` of the service locally in Docker, instead of the `command`.
+       *
+       * The image is rebuilt and the container restarted when a file in the build
+       * `context` changes. Its output shows up in the `sst dev` multiplexer, and it
+       * gets the linked resources and the permissions of the service.
+       *
+       * @example
+       *
+       * ```js
+       * {
+       *   dev: {
+       *     docker: true
+       *   }
+       * }
+       * ```
+       *
+       * Rebuilding can be slow for large images. Set `sync` to the directory the
+       * `context` is copied to in the image, and changed files are copied into the
+       * running container before it is restarted. The image is still rebuilt when
+       * the Dockerfile changes.
+       *
+       * ```js
+       * {
+       *   dev: {
+       *     docker: {
+       *       sync: "/app"
+       *     }
+       *   }
+       * }
+       * ```
+       *
+       * @default `false`
+       */
+      docker?:
+        | boolean
+        | {
+          /**
+           * The directory in the container that the build `context` is copied to.
+           */
+          sync?: string;
+          /**
+           * The ports to publish, as `host:container`.
+           * @default The `forward` ports of `public.ports`
+           */
+          ports?: string[];
+        };
     };
   /**
    * Configure a public endpoint for the service. When configured, a load balancer
@@ -1042,6 +1089,11 @@ export interface ClusterServiceArgs {
        * [`dev.directory`](#dev-directory).
        */
       directory?: Input<string>;
+      /**
+       * Run the image of this container locally in Docker. Same as the top-level
+       * [`dev.docker`](#dev-docker).
+       */
+      docker?: Exclude<ClusterServiceArgs["dev"], false | undefined>["docker"];
     };
   }>[];
   /**
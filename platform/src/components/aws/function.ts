@@ -33,10 +33,12 @@ import {
   types,
 } from "@pulumi/aws";
 import { Permission, permission } from "./permission.js";
+import { credentialsLink } from "../gcp/permission.js";
 import { Vpc } from "./vpc.js";
 import { buildPython, buildPythonContainer } from "../../runtime/python.js";
 import { Image } from "@pulumi/docker-build";
 import { rpc } from "../rpc/rpc.js";
+import { incremental } from "../incremental.js";
 import { parseRoleArn } from "./helpers/arn.js";
 import { RandomBytes } from "@pulumi/random";
 import { lazy } from "../../util/lazy.js";
@@ -260,6 +262,28 @@ export interface FunctionArgs {
    *   runtime: "nodejs18.x"
    * }
    * ```
+   *
+   * Use `rust` for a function written in Rust. It is built with
+   * [cargo-lambda](https://www.cargo-lambda.info), which needs to be installed along with
+   * [Zig](https://ziglang.org) to cross-compile for the `architecture` of the function. It
+   * runs on the `provided.al2023` runtime.
+   *
+   * ```js
+   * {
+   *   runtime: "rust",
+   *   handler: "packages/api/Cargo.toml"
+   * }
+   * ```
+   *
+   * The `java` runtimes build the project with its Maven or Gradle wrapper. The build
+   * needs to package the function with its dependencies, like with the shade plugin or
+   * a `buildZip` task.
+   *
+   * The `dotnet` runtimes run `dotnet publish` for the `architecture` of the function.
+   *
+   * Use `deno` for a function written for [Deno](https://deno.com). The handler is
+   * compiled with `deno compile` using the closest `deno.json` and its lockfile, so
+   * `npm:` specifiers and import maps work. It runs on the `provided.al2023` runtime.
    */
   runtime?: Input<
     | "nodejs18.x"
@@ -269,6 +293,13 @@ export interface FunctionArgs {
     | "python3.10"
     | "python3.11"
     | "python3.12"
+    | "java11"
+    | "java17"
+    | "java21"
+    | "dotnet6"
+    | "dotnet8"
+    | "deno"
+    | "rust"
   >;
   /**
    * Path to the source code directory for the function. By default, the handler is
@@ -321,6 +352,35 @@ export interface FunctionArgs {
    *   handler: "index.handler"
    * }
    * ```
+   *
+   * For the `rust` runtime, the handler is the `Cargo.toml` of the crate, or the
+   * `src/bin/{name}.rs` file of the binary to use if the crate has more than one.
+   *
+   * ```js
+   * {
+   *   runtime: "rust",
+   *   handler: "packages/api/src/bin/users.rs"
+   * }
+   * ``   *
+   * For the `java` runtimes, the handler is the directory of the project followed by the
+   * class and method.
+   *
+   * ```js
+   * {
+   *   runtime: "java21",
+   *   handler: "packages/api/com.example.Handler::handleRequest"
+   * }
+   * ``   *
+   * For the `dotnet` runtimes, it's the directory of the project followed by the
+   * assembly, class, and method. To run the function in `sst dev`, add the
+   * `Amazon.Lambda.RuntimeSupport` package to the project.
+   *
+   * ```js
+   * {
+   *   runtime: "dotnet8",
+   *   handler: "packages/api/Api::Api.Function::Handler"
+   * }
+   * ```
    */
   handler: Input<string>;
   /**
@@ -678,6 +738,38 @@ export interface FunctionArgs {
      * You'll also need to install the npm package of the plugin.
      */
     plugins?: Input<string>;
+    /**
+     * Point to a file with an esbuild config hook. It can add plugins, `define` and
+     * `external` entries, and a `banner` or `footer` to the build of the function.
+     *
+     * @example
+     * ```js
+     * {
+     *   nodejs: {
+     *     esbuildConfig: "./esbuild.config.ts"
+     *   }
+     * }
+     * ```
+     *
+     * The path is relative to the location of the `sst.config.ts`. The file can be JS or TS,
+     * its default export is the config or a function that returns it. The function gets the
+     * `functionID`, the `handler`, and if it's running in `sst dev`.
+     *
+     * ```ts title="esbuild.config.ts"
+     * import { somePlugin } from "some-plugin";
+     *
+     * export default (input) => ({
+     *   plugins: [somePlugin()],
+     *   define: { "process.env.FUNCTION": JSON.stringify(input.functionID) },
+     *   external: ["some-native-package"],
+     *   banner: "// built by sst",
+     *   footer: { js: "// end" },
+     * });
+     * ```
+     *
+     * To apply a config to every function, set `esbuildConfig` on your app instead.
+     */
+    esbuildConfig?: Input<string>;
     /**
      * Configure additional esbuild loaders for other file extensions. This is useful
      * when your code is importing non-JS files like `.png`, `.css`, etc.
@@ -784,6 +876,14 @@ export interface FunctionArgs {
      * increase payload size and potentially cold starts, they are not added by default.
      * However, they are always generated during `sst dev`.
      *
+     * Source maps are always generated when deploying. If they are not added to the bundle,
+     * they are kept in the `.sst/` directory and used to map the stack traces in `sst logs`
+     * back to your source.
+     *
+     * - `true` adds them to the bundle and sets `NODE_OPTIONS=--enable-source-maps`, so the
+     *   stack traces in your logs point to your source.
+     * - `"upload"` uploads them to your bootstrap bucket instead, to be used by other tools.
+     *
      * :::tip[SST Console]
      * For the [Console](/docs/console/), source maps are always generated and uploaded
      * to your bootstrap bucket. These are then downloaded and used to display
@@ -801,7 +901,7 @@ export interface FunctionArgs {
      * }
      * ```
      */
-    sourcemap?: Input<boolean>;
+    sourcemap?: Input<boolean | "upload">;
     /**
      * If enabled, modules that are dynamically imported will be bundled in their own files
      * with common dependencies placed in shared chunks. This can help reduce cold starts
@@ -856,7 +956,88 @@ export interface FunctionArgs {
      * ```
      */
     container?: Input<boolean>;
+    /**
+     * Put the dependencies of the function in a Lambda layer of their own instead of
+     * the function package. This way deploys that only change your code don't upload
+     * the dependencies again.
+     *
+     * Dependencies are installed for the Lambda platform and `architecture` of the
+     * function, and they are cached until your lockfile or requirements change.
+     *
+     * @default `true` if the dependencies are over 50 MB
+     * @example
+     * ```ts
+     * {
+     *   python: {
+     *     layer: true
+     *   }
+     * }
+     * ```
+     */
+    layer?: Input<boolean>;
   }>;
+  /**
+   * Configure your java function.
+   *
+   * To run the function in `sst dev`, add the
+   * [runtime interface client](https://github.com/aws/aws-lambda-java-libs/tree/main/aws-lambda-java-runtime-interface-client)
+   * as a dependency of your project.
+   */
+  java?: Input<{
+    /**
+     * Turn on [SnapStart](https://docs.aws.amazon.com/lambda/latest/dg/snapstart.html) to
+     * cut down on cold starts. It applies to published versions, so this turns on
+     * `versioning` as well.
+     * @default `false`
+     * @example
+     * ```ts
+     * {
+     *   java: {
+     *     snapStart: true
+     *   }
+     * }
+     * ```
+     */
+    snapStart?: Input<boolean>;
+  }>;
+  /**
+   * Deploy the function as a container image instead of a zip, for functions that are
+   * over the size limit of a zip or need native dependencies.
+   *
+   * The image is built from the function package with a Dockerfile that's generated
+   * for the `runtime`, and pushed to the ECR repository of your account. Pass in your own
+   * `dockerfile` to customize it, it's built in the directory of the function package.
+   *
+   * In `sst dev` the image is built and run locally with Docker, and invocations are
+   * forwarded to it.
+   *
+   * @default `false`
+   * @example
+   * ```js
+   * {
+   *   container: true
+   * }
+   * ```
+   *
+   * Use your own Dockerfile.
+   *
+   * ```js
+   * {
+   *   container: {
+   *     dockerfile: "packages/api/Dockerfile"
+   *   }
+   * }
+   * ```
+   */
+  container?: Input<
+    | boolean
+    | {
+        /**
+         * The path to the Dockerfile, relative to the root of your app.
+         */
+        dockerfile?: Input<string>;
+      }
+  >;
   /**
    * Add additional files to copy into the function package. Takes a list of objects
    * with `from` and `to` paths. These will be copied over before the function package
@@ -908,6 +1089,43 @@ export interface FunctionArgs {
       to?: Input<string>;
     }[]
   >;
+  /**
+   * Commands to run before and after the function is built, like generating code from
+   * a schema before it's bundled or post-processing the output.
+   *
+   * The commands run from the root of your app, one after the other. They get the
+   * build context in the environment:
+   *
+   * - `SST_APP` and `SST_STAGE`
+   * - `SST_FUNCTION_ID`, the name of the function
+   * - `SST_HANDLER` and `SST_RUNTIME`
+   * - `SST_OUT`, the directory the function is built into
+   * - `SST_DEV`, `true` in `sst dev`
+   *
+   * If a command fails, the build fails with its output. Hooks run on every build,
+   * even if the build itself is cached. They don't run for a `bundle` or for Python
+   * functions outside of `sst dev`.
+   *
+   * @example
+   * ```js
+   * {
+   *   hooks: {
+   *     preBuild: ["buf generate"],
+   *     postBuild: ["cp schema.graphql $SST_OUT"]
+   *   }
+   * }
+   * ```
+   */
+  hooks?: Input<{
+    /**
+     * Commands to run before the function is built.
+     */
+    preBuild?: Input<Input<string>[]>;
+    /**
+     * Commands to run after the function is built.
+     */
+    postBuild?: Input<Input<string>[]>;
+  }>;
   /**
    * Configure the concurrency settings for the function.
    *
@@ -1234,8 +1452,10 @@ export class Function extends Component implements Link.Linkable {
 
     const parent = this;
     const dev = normalizeDev();
-    const isContainer = all([args.python, dev]).apply(
-      ([python, dev]) => !dev && (python?.container ?? false),
+    const container = normalizeContainer();
+    const isContainer = all([args.python, container, dev]).apply(
+      ([python, container, dev]) =>
+        !dev && ((python?.container ?? false) || container !== undefined),
     );
     const region = normalizeRegion();
     const bootstrapData = region.apply((region) => bootstrap.forRegion(region));
@@ -1254,11 +1474,18 @@ export class Function extends Component implements Link.Linkable {
 
     const linkData = buildLinkData();
     const linkPermissions = buildLinkPermissions();
-    const { bundle, handler: handler0 } = buildHandler();
+    const {
+      bundle,
+      handler: handler0,
+      dependencies,
+      sourcemaps,
+    } = buildHandler();
     const { handler, wrapper } = buildHandlerWrapper();
     const role = createRole();
     const imageAsset = createImageAsset();
     const zipAsset = createZipAsset();
+    const dependencyLayer = createDependencyLayer();
+    createSourcemaps();
     const logGroup = createLogGroup();
     const fn = createFunction();
     const fnUrl = createUrl();
@@ -1277,10 +1504,13 @@ export class Function extends Component implements Link.Linkable {
       bundle: args.bundle,
       encryptionKey: Function.encryptionKey().base64,
       runtime,
+      architecture,
       links: output(linkData).apply((input) =>
         Object.fromEntries(input.map((item) => [item.name, item.properties])),
       ),
       copyFiles,
+      container,
+      hooks: args.hooks,
       properties: output({ nodejs: args.nodejs, python: args.python }).apply(
         (val) => val.nodejs || val.python,
       ),
@@ -1359,25 +1589,39 @@ export class Function extends Component implements Link.Linkable {
         bootstrapData,
         Function.encryptionKey().base64,
         args.bundle,
-      ]).apply(([environment, dev, bootstrap, key, bundle]) => {
-        const result = environment ?? {};
-        result.SST_RESOURCE_App = JSON.stringify({
-          name: $app.name,
-          stage: $app.stage,
-        });
-        result.SST_KEY = key;
-        result.SST_KEY_FILE = "resource.enc";
-        if (dev) {
-          result.SST_REGION = process.env.SST_AWS_REGION!;
-          result.SST_FUNCTION_ID = name;
-          result.SST_APP = $app.name;
-          result.SST_STAGE = $app.stage;
-          result.SST_ASSET_BUCKET = bootstrap.asset;
-          if (process.env.SST_FUNCTION_TIMEOUT)
-            result.SST_FUNCTION_TIMEOUT = process.env.SST_FUNCTION_TIMEOUT;
-        }
-        return result;
-      });
+        runtime,
+        args.nodejs,
+      ]).apply(
+        ([environment, dev, bootstrap, key, bundle, runtime, nodejs]) => {
+          const result = environment ?? {};
+          result.SST_RESOURCE_App = JSON.stringify({
+            name: $app.name,
+            stage: $app.stage,
+          });
+          result.SST_KEY = key;
+          result.SST_KEY_FILE = "resource.enc";
+          if (dev) {
+            result.SST_REGION = process.env.SST_AWS_REGION!;
+            result.SST_FUNCTION_ID = name;
+            result.SST_APP = $app.name;
+            result.SST_STAGE = $app.stage;
+            result.SST_ASSET_BUCKET = bootstrap.asset;
+            if (process.env.SST_FUNCTION_TIMEOUT)
+              result.SST_FUNCTION_TIMEOUT = process.env.SST_FUNCTION_TIMEOUT;
+          }
+          if (
+            !dev &&
+            runtime.startsWith("nodejs") &&
+            nodejs?.sourcemap === true &&
+            !result.NODE_OPTIONS?.includes("--enable-source-maps")
+          ) {
+            result.NODE_OPTIONS = [result.NODE_OPTIONS, "--enable-source-maps"]
+              .filter(Boolean)
+              .join(" ");
+          }
+          return result;
+        },
+      );
     }
 
     function normalizeStreaming() {
@@ -1445,6 +1689,14 @@ export class Function extends Component implements Link.Linkable {
       });
     }
 
+    function normalizeContainer() {
+      return output(args.container).apply((container) => {
+        if (!container) return undefined;
+        if (container === true) return {};
+        return { dockerfile: container.dockerfile };
+      });
+    }
+
     function normalizeCopyFiles() {
       return output(args.copyFiles ?? []).apply((copyFiles) =>
         Promise.all(
@@ -1499,7 +1751,10 @@ export class Function extends Component implements Link.Linkable {
     }
 
     function buildLinkData() {
-      return output(args.link || []).apply((links) => Link.build(links));
+      return all([
+        output(args.link || []).apply((links) => Link.build(links)),
+        credentialsLink(name, args.link, dev, parent),
+      ]).apply(([links, credentials]) => [...links, ...credentials]);
     }
 
     function buildLinkPermissions() {
@@ -1512,6 +1767,8 @@ export class Function extends Component implements Link.Linkable {
           return {
             handler: "bootstrap",
             bundle: path.join($cli.paths.platform, "dist", "bridge"),
+            dependencies: undefined,
+            sourcemaps: undefined,
           };
         }
 
@@ -1548,6 +1805,10 @@ export class Function extends Component implements Link.Linkable {
           return {
             handler: buildResult.handler,
             bundle: buildResult.out,
+            dependencies: buildResult.apply((result) =>
+              "layer" in result ? result.layer : undefined,
+            ),
+            sourcemaps: undefined,
           };
         }
 
@@ -1556,6 +1817,7 @@ export class Function extends Component implements Link.Linkable {
             handler: string;
             out: string;
             errors: string[];
+            sourcemaps?: string;
           }>("Runtime.Build", input);
           if (result.errors.length > 0) {
             throw new Error(result.errors.join("\n"));
@@ -1565,6 +1827,8 @@ export class Function extends Component implements Link.Linkable {
         return {
           handler: buildResult.handler,
           bundle: buildResult.out,
+          dependencies: undefined,
+          sourcemaps: buildResult.sourcemaps,
         };
       });
     }
@@ -1775,7 +2039,7 @@ export class Function extends Component implements Link.Linkable {
             // Cannot use latest tag it breaks lambda because for whatever reason
             // .ref is actually digest + tags and is not properly qualified???
             context: {
-              location: path.join($cli.paths.work, "artifacts", `${name}-src`),
+              location: bundle,
             },
             // Use the pushed image as a cache source.
             cacheFrom: [
@@ -1818,6 +2082,51 @@ export class Function extends Component implements Link.Linkable {
       });
     }
 
+    function createDependencyLayer() {
+      return all([dependencies, runtime, architecture]).apply(
+        ([dependencies, runtime, architecture]) => {
+          if (!dependencies) return;
+          return new lambda.LayerVersion(
+            `${name}Dependencies`,
+            {
+              layerName: physicalName(140, `${name}Dependencies`),
+              code: new asset.FileArchive(dependencies),
+              compatibleRuntimes: [runtime],
+              compatibleArchitectures: [architecture],
+            },
+            { parent },
+          );
+        },
+      );
+    }
+
+    function createSourcemaps() {
+      return all([sourcemaps, args.nodejs, bootstrapData]).apply(
+        async ([sourcemaps, nodejs, bootstrap]) => {
+          if (!sourcemaps || nodejs?.sourcemap !== "upload") return [];
+          const files = (
+            await fs.promises.readdir(sourcemaps, { recursive: true })
+          )
+            .filter((file) => file.endsWith(".map"))
+            .sort();
+          return files.map(
+            (file, i) =>
+              new s3.BucketObjectv2(
+                `${name}Sourcemap${i}`,
+                {
+                  key: `sourcemap/${$app.name}/${$app.stage}/${name}/${file
+                    .split(path.sep)
+                    .join("/")}`,
+                  bucket: bootstrap.asset,
+                  source: new asset.FileAsset(path.join(sourcemaps, file)),
+                },
+                { parent },
+              ),
+          );
+        },
+      );
+    }
+
     function createZipAsset() {
       // Note: cannot point the bundle to the `.open-next/server-function`
       //       b/c the folder contains node_modules. And pnpm node_modules
@@ -1837,54 +2146,22 @@ export class Function extends Component implements Link.Linkable {
             recursive: true,
           });
 
-          await new Promise(async (resolve, reject) => {
-            const ws = fs.createWriteStream(zipPath);
-            const archive = archiver("zip", {
-              // Ensure deterministic zip file hashes
-              // https://github.com/archiverjs/node-archiver/issues/397#issuecomment-554327338
-              statConcurrency: 1,
-            });
-            archive.on("warning", reject);
-            archive.on("error", reject);
-            // archive has been finalized and the output file descriptor has closed, resolve promise
-            // this has to be done before calling `finalize` since the events may fire immediately after.
-            // see https://www.npmjs.com/package/archiver
-            ws.once("close", () => {
-              resolve(zipPath);
-            });
-            archive.pipe(ws);
-
-            // set the date to 0 so that the zip file is deterministic
-            archive.glob(
-              "**",
-              { cwd: bundle, dot: true },
-              { date: new Date(0), mode: 0o777 },
-            );
-
-            // Add handler wrapper into the zip
-            if (wrapper) {
-              archive.append(wrapper.content, {
-                name: wrapper.name,
-                date: new Date(0),
-              });
-            }
-
-            // Add copyFiles into the zip
-            copyFiles.forEach(async (entry) => {
-              entry.isDir
-                ? archive.directory(entry.from, entry.to, { date: new Date(0) })
-                : archive.file(entry.from, {
-                    name: entry.to,
-                    date: new Date(0),
-                  });
-            });
-            await archive.finalize();
-          });
-
-          // Calculate hash of the zip file
-          const hash = crypto.createHash("sha256");
-          hash.update(await fs.promises.readFile(zipPath));
-          const hashValue = hash.digest("hex");
+          // The zip is left alone if what goes into it has not changed, the
+          // hash of the zip is recorded with it
+          const inputs = await incremental.hash(
+            [path.resolve(bundle), ...copyFiles.map((entry) => entry.from)],
+            [
+              wrapper?.name ?? "",
+              wrapper?.content ?? "",
+              ...copyFiles.map((entry) => entry.to),
+            ],
+            true,
+          );
+          const previous = fs.existsSync(zipPath)
+            ? await incremental.previous(`${name}Code`, inputs)
+            : "";
+          const hashValue = previous || (await zip());
+          await incremental.put(`${name}Code`, inputs, hashValue);
 
           return new s3.BucketObjectv2(
             `${name}Code`,
@@ -1897,6 +2174,57 @@ export class Function extends Component implements Link.Linkable {
             },
             { parent },
           );
+
+          async function zip() {
+            await new Promise(async (resolve, reject) => {
+              const ws = fs.createWriteStream(zipPath);
+              const archive = archiver("zip", {
+                // Ensure deterministic zip file hashes
+                // https://github.com/archiverjs/node-archiver/issues/397#issuecomment-554327338
+                statConcurrency: 1,
+              });
+              archive.on("warning", reject);
+              archive.on("error", reject);
+              // archive has been finalized and the output file descriptor has closed, resolve promise
+              // this has to be done before calling `finalize` since the events may fire immediately after.
+              // see https://www.npmjs.com/package/archiver
+              ws.once("close", () => {
+                resolve(zipPath);
+              });
+              archive.pipe(ws);
+
+              // set the date to 0 so that the zip file is deterministic
+              archive.glob(
+                "**",
+                { cwd: bundle, dot: true },
+                { date: new Date(0), mode: 0o777 },
+              );
+
+              // Add handler wrapper into the zip
+              if (wrapper) {
+                archive.append(wrapper.content, {
+                  name: wrapper.name,
+                  date: new Date(0),
+                });
+              }
+
+              // Add copyFiles into the zip
+              copyFiles.forEach(async (entry) => {
+                entry.isDir
+                  ? archive.directory(entry.from, entry.to, { date: new Date(0) })
+                  : archive.file(entry.from, {
+                      name: entry.to,
+                      date: new Date(0),
+                    });
+              });
+              await archive.finalize();
+            });
+
+            // Calculate hash of the zip file
+            const hash = crypto.createHash("sha256");
+            hash.update(await fs.promises.readFile(zipPath));
+            return hash.digest("hex");
+          }
         },
       );
     }
@@ -1970,9 +2298,19 @@ export class Function extends Component implements Link.Linkable {
                 arn: volume.efs,
                 localMountPath: volume.path,
               },
-              layers: args.layers,
+              layers: all([args.layers, dependencyLayer]).apply(
+                ([layers, dependencyLayer]) => [
+                  ...(layers ?? []),
+                  ...(dependencyLayer ? [dependencyLayer.arn] : []),
+                ],
+              ),
               tags: args.tags,
-              publish: output(args.versioning).apply((v) => v ?? false),
+              publish: all([args.versioning, args.java]).apply(
+                ([versioning, java]) => versioning ?? java?.snapStart ?? false,
+              ),
+              snapStart: output(args.java).apply((java) =>
+                java?.snapStart ? { applyOn: "PublishedVersions" } : undefined,
+              ),
               reservedConcurrentExecutions: concurrency?.reserved,
               ...(isContainer
                 ? {
@@ -1989,7 +2327,9 @@ export class Function extends Component implements Link.Linkable {
                     s3Bucket: zipAsset!.bucket,
                     s3Key: zipAsset!.key,
                     handler: unsecret(handler),
-                    runtime,
+                    runtime: runtime.apply((v) =>
+                      v === "rust" || v === "deno" ? "provided.al2023" : v,
+                    ),
                   }),
             },
             { parent },
@@ -2007,6 +2347,7 @@ export class Function extends Component implements Link.Linkable {
                       : "live",
                     runtime: "provided.al2023",
                     architectures: ["x86_64"],
+                    snapStart: undefined,
                   }
                 : {}),
             },
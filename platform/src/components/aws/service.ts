@@ -8,6 +8,7 @@ import {
   interpolate,
   output,
   secret,
+  Unwrap,
 } from "@pulumi/pulumi";
 import { Image, Platform } from "@pulumi/docker-build";
 import { Component, transform } from "../component.js";
@@ -925,10 +926,42 @@ export class Service extends Component implements Link.Linkable {
             aws: {
               role: taskRole.arn,
             },
+            docker: normalizeDocker(container, title),
           });
         }
       });
     }
+
+    function normalizeDocker(
+      container: Unwrap<typeof containers>[number],
+      title: string,
+    ) {
+      const docker = container.dev ? container.dev.docker : undefined;
+      if (!docker) return;
+      return all([container.image, pub?.ports ?? []]).apply(
+        ([image, ports]) => {
+          const config = typeof docker === "object" ? docker : {};
+          const forwarded = ports
+            .filter((port) => port.container === container.name)
+            .map((port) => `${port.forwardPort}:${port.forwardPort}`);
+          if (typeof image === "string")
+            return {
+              image,
+              context: "",
+              sync: config.sync,
+              ports: config.ports ?? forwarded,
+            };
+          return {
+            image: `sst-dev-${title.toLowerCase()}`,
+            context: image?.context ?? ".",
+            dockerfile: image?.dockerfile,
+            args: image?.args,
+            sync: config.sync,
+            ports: config.ports ?? forwarded,
+          };
+        },
+      );
+    }
   }
 
   /**
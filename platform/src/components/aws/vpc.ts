@@ -126,6 +126,30 @@ export interface VpcArgs {
    * ```
    */
   bastion?: Input<true>;
+  /**
+   * How the tunnel to the bastion host is made.
+   *
+   * - `ssh` connects to the public IP of the bastion host over SSH on port 22.
+   * - `ssm` goes through AWS Systems Manager Session Manager, using the SSM agent on
+   *   the bastion host. Port 22 is not opened to the internet, which helps when your
+   *   network blocks outbound SSH or your security policy does not allow open ports.
+   *
+   * The `ssm` transport needs the [AWS CLI](https://aws.amazon.com/cli/) and its
+   * [Session Manager plugin](https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html)
+   * installed on your machine.
+   *
+   * Either way `sst tunnel` works the same.
+   *
+   * @default `"ssh"`
+   * @example
+   * ```ts
+   * {
+   *   bastion: true,
+   *   tunnel: "ssm"
+   * }
+   * ```
+   */
+  tunnel?: Input<"ssh" | "ssm">;
   /**
    * [Transform](/docs/components#transform) how this component creates its underlying
    * resources.
@@ -325,6 +349,13 @@ export class Vpc extends Component implements Link.Linkable {
           ([bastion, privateKeyValue, privateSubnets, publicSubnets]) => {
             if (!bastion) return;
             return {
+              transport:
+                args && "ref" in args
+                  ? bastion.tags.apply((tags) => tags?.["sst:tunnel"] ?? "ssh")
+                  : output(args?.tunnel ?? "ssh"),
+              instance: bastion.id,
+              // arn:aws:ec2:<region>:<account>:instance/<id>
+              region: bastion.arn.apply((arn) => arn.split(":")[3]),
               ip: bastion.publicIp,
               username: "ec2-user",
               privateKey: privateKeyValue!,
@@ -708,6 +739,7 @@ export class Vpc extends Component implements Link.Linkable {
     function createBastion() {
       if (!args?.bastion) return undefined;
 
+      const tunnel = output(args?.tunnel ?? "ssh");
       return natInstances.apply((natInstances) => {
         if (natInstances.length) return natInstances[0];
 
@@ -715,14 +747,19 @@ export class Vpc extends Component implements Link.Linkable {
           `${name}BastionSecurityGroup`,
           {
             vpcId: vpc.id,
-            ingress: [
-              {
-                protocol: "tcp",
-                fromPort: 22,
-                toPort: 22,
-                cidrBlocks: ["0.0.0.0/0"],
-              },
-            ],
+            // SSM sessions are started by the agent, nothing needs to be open
+            ingress: tunnel.apply((tunnel) =>
+              tunnel === "ssm"
+                ? []
+                : [
+                    {
+                      protocol: "tcp",
+                      fromPort: 22,
+                      toPort: 22,
+                      cidrBlocks: ["0.0.0.0/0"],
+                    },
+                  ],
+            ),
             egress: [
               {
                 protocol: "-1",
@@ -793,6 +830,7 @@ export class Vpc extends Component implements Link.Linkable {
               keyName: keyPair?.keyName,
               tags: {
                 "sst:lookup-type": "bastion",
+                "sst:tunnel": tunnel,
               },
             },
             { parent },
@@ -8,6 +8,7 @@ import { Link } from "../link.js";
 import { VisibleError } from "../error.js";
 import { BaseSiteDev, BaseSiteFileOptions, limiter } from "./base-site";
 import { Run } from "../providers/run";
+import { incremental } from "../incremental.js";
 
 export interface BaseSsrSiteArgs {
   dev?: false | Prettify<BaseSiteDev>;
@@ -118,8 +119,18 @@ export function buildApp(
     args.environment,
   ]).apply(([sitePath, userCommand, links, environment]) => {
     const cmd = resolveBuildCommand();
-    const result = runBuild();
-    return result.id.apply(() => sitePath);
+    const { result, inputs, version } = runBuild();
+    return all([inputs, version, result.id]).apply(
+      async ([inputs, version]) => {
+        await incremental.done(
+          `${name}Build`,
+          version,
+          inputs.paths,
+          inputs.extra,
+        );
+        return sitePath;
+      },
+    );
 
     function resolveBuildCommand() {
       if (userCommand) return userCommand;
@@ -174,25 +185,48 @@ export function buildApp(
         return envs;
       });
 
+      // the address of the server changes with every deploy and would make
+      // the build run again
+      const env = linkEnvs.apply((linkEnvs) => ({
+        SST: "1",
+        ...Object.fromEntries(
+          Object.entries(process.env).filter(
+            ([key]) =>
+              !key.startsWith("SST_SERVER") && !key.startsWith("PULUMI_"),
+          ),
+        ),
+        ...environment,
+        ...linkEnvs,
+      }));
+
+      // The build is skipped if the site, including its output, the inputs of
+      // the component, and the environment of the build have not changed
+      // since it last ran.
+      const inputs = all([env, incremental.inputs(args)]).apply(
+        ([env, args]) => ({
+          paths: [sitePath],
+          extra: [cmd, args, incremental.env(env as Record<string, string>)],
+        }),
+      );
+      const version = inputs.apply((inputs) =>
+        incremental.version(`${name}Build`, inputs.paths, inputs.extra),
+      );
+
       // Run build
-      return new Run(
+      const result = new Run(
         `${name}Build`,
         {
           command: cmd,
           cwd: sitePath,
-          env: linkEnvs.apply((linkEnvs) => ({
-            SST: "1",
-            ...process.env,
-            ...environment,
-            ...linkEnvs,
-          })),
-          version: Date.now().toString(),
+          env,
+          version,
         },
         {
           parent,
           ignoreChanges: process.env.SKIP ? ["*"] : undefined,
         },
       );
+      return { result, inputs, version };
     }
   });
 }
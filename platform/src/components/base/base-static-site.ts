@@ -6,6 +6,7 @@ import { Input } from "../input.js";
 import { Prettify } from "../component.js";
 import { BaseSiteFileOptions } from "./base-site.js";
 import { Run } from "../providers/run.js";
+import { incremental } from "../incremental.js";
 
 export type BaseStaticSiteAssets = {
   /**
@@ -281,13 +282,26 @@ export function buildApp(
 ) {
   if (!build) return sitePath;
 
+  // The build is skipped if the site, including its output, its build
+  // options, and the environment of the build have not changed since it last
+  // ran
+  const inputs = all([sitePath, build, environment]).apply(
+    ([sitePath, build, environment]) => ({
+      paths: [sitePath],
+      extra: [JSON.stringify(build), incremental.env(environment)],
+    }),
+  );
+  const version = inputs.apply((inputs) =>
+    incremental.version(`${name}Build`, inputs.paths, inputs.extra),
+  );
+
   const result = new Run(
     `${name}Build`,
     {
       command: output(build).command,
       cwd: sitePath,
       env: environment,
-      version: Date.now().toString(),
+      version,
     },
     {
       parent,
@@ -296,14 +310,22 @@ export function buildApp(
   );
 
   // Validate build output
-  return all([sitePath, build, result.id]).apply(([sitePath, build, _id]) => {
-    const outputPath = path.join(sitePath, build.output);
-    if (!fs.existsSync(outputPath)) {
-      throw new VisibleError(
-        `No build output found at "${path.resolve(outputPath)}".`,
+  return all([sitePath, build, inputs, version, result.id]).apply(
+    async ([sitePath, build, inputs, version, _id]) => {
+      const outputPath = path.join(sitePath, build.output);
+      if (!fs.existsSync(outputPath)) {
+        throw new VisibleError(
+          `No build output found at "${path.resolve(outputPath)}".`,
+        );
+      }
+      await incremental.done(
+        `${name}Build`,
+        version,
+        inputs.paths,
+        inputs.extra,
       );
-    }
 
-    return outputPath;
-  });
+      return outputPath;
+    },
+  );
 }
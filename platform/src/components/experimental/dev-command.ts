@@ -63,6 +63,17 @@ export interface DevCommandArgs {
   aws?: {
     role: Input<string>;
   };
+  /**
+   * @internal
+   */
+  docker?: Input<{
+    image: string;
+    context: string;
+    dockerfile?: string;
+    args?: Record<string, string>;
+    sync?: string;
+    ports: string[];
+  }>;
 }
 
 /**
@@ -116,6 +127,7 @@ export class DevCommand extends Component {
         aws: {
           role: args.aws?.role,
         },
+        docker: args.docker,
       },
     });
   }
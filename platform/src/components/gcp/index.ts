@@ -0,0 +1 @@
+export { permission } from "./permission.js";
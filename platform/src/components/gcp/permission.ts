@@ -0,0 +1,118 @@
+/**
+ * The GCP Permission Linkable helper is used to define the GCP roles included with the
+ * [`sst.Linkable`](/docs/component/linkable/) component.
+ *
+ * Functions that are linked to a resource with GCP permissions get a service account
+ * with those roles. Its key is available in your handler as `Resource.GcpCredentials`.
+ *
+ * @example
+ *
+ * ```ts
+ * sst.gcp.permission({
+ *   role: "roles/storage.objectViewer",
+ *   resource: bucket.id.apply((id) => `projects/_/buckets/${id}`)
+ * })
+ * ```
+ *
+ * @packageDocumentation
+ */
+
+import { ComponentResource, Input, Output, all } from "@pulumi/pulumi";
+import { Link } from "../link.js";
+import { hashStringToPrettyString } from "../naming.js";
+
+export interface InputArgs {
+  /**
+   * The role to grant.
+   *
+   * @example
+   * ```js
+   * {
+   *   role: "roles/pubsub.publisher"
+   * }
+   * ```
+   */
+  role: Input<string>;
+  /**
+   * The full resource name the role is limited to. If it's not set the role is granted on
+   * the whole project.
+   */
+  resource?: Input<string>;
+}
+
+export function permission(input: InputArgs) {
+  return {
+    type: "gcp.permission" as const,
+    ...input,
+  };
+}
+
+export type Permission = ReturnType<typeof permission>;
+
+/**
+ * Creates a service account with the GCP permissions of the links and returns the link
+ * data that hands its key to the function. In dev the function runs locally with your own
+ * credentials so nothing is created.
+ *
+ * @internal
+ */
+export function credentialsLink(
+  name: string,
+  links: Input<any[]> | undefined,
+  dev: Input<boolean>,
+  parent: ComponentResource,
+): Output<{ name: string; properties: Record<string, any> }[]> {
+  return all([Link.getInclude<Permission>("gcp.permission", links), dev]).apply(
+    async ([permissions, dev]) => {
+      if (dev || permissions.length === 0) return [];
+      const gcp = await import("@pulumi/gcp");
+      const account = new gcp.serviceaccount.Account(
+        `${name}GcpServiceAccount`,
+        {
+          accountId: `sst-${hashStringToPrettyString(
+            `${$app.name}-${$app.stage}-${name}`,
+            12,
+          )}`,
+          displayName: `${$app.name} ${$app.stage} ${name}`,
+        },
+        { parent },
+      );
+      permissions.forEach((item, index) => {
+        new gcp.projects.IAMMember(
+          `${name}GcpPermission${index}`,
+          {
+            project: account.project,
+            role: item.role,
+            member: account.member,
+            condition: item.resource
+              ? {
+                  title: `${name} ${index}`,
+                  expression: all([item.resource]).apply(
+                    ([resource]) => `resource.name.startsWith("${resource}")`,
+                  ),
+                }
+              : undefined,
+          },
+          { parent },
+        );
+      });
+      const key = new gcp.serviceaccount.Key(
+        `${name}GcpServiceAccountKey`,
+        { serviceAccountId: account.name },
+        { parent },
+      );
+      return [
+        {
+          name: "GcpCredentials",
+          properties: {
+            type: "sst.gcp.Credentials",
+            project: account.project,
+            credentials: key.privateKey.apply((value) =>
+              JSON.parse(Buffer.from(value, "base64").toString("utf8")),
+            ),
+          },
+        },
+      ];
+    },
+  );
+}
@@ -0,0 +1,94 @@
+import { Input, Resource, output, runtime } from "@pulumi/pulumi";
+import { rpc } from "./rpc/rpc.js";
+
+/**
+ * Incremental deploys skip work like building a site when the files and values
+ * it depends on hash the same as right after it was last done. The hashes are
+ * kept with the state of the stage, the files include the output of the work
+ * so it is still done where the output is missing. The work is done by a
+ * resource with a `version` input, it is left unchanged by reusing the
+ * version.
+ *
+ * Set `SST_NO_INCREMENTAL` to always do the work.
+ */
+export module incremental {
+  /**
+   * Hashes the files under the paths and the extra values. Dependencies are
+   * skipped unless `all` is set.
+   */
+  export function hash(paths: string[], extra: string[] = [], all = false) {
+    return rpc.call<string>("Incremental.Hash", { paths, extra, all });
+  }
+
+  /**
+   * The environment a build runs with as a value to hash. The address of the
+   * server, the credentials, and the secrets change without changing what is
+   * built, linked secrets are hashed with the links.
+   */
+  export function env(extra: Record<string, string> = {}) {
+    const env: Record<string, string | undefined> = { ...process.env, ...extra };
+    return JSON.stringify(
+      Object.keys(env)
+        .filter((key) => !unhashed.test(key))
+        .sort()
+        .map((key) => [key, env[key]]),
+    );
+  }
+
+  const unhashed =
+    /^(SST_SERVER|SST_SECRET_|PULUMI_|AWS_(ACCESS_KEY_ID|SECRET_ACCESS_KEY|SESSION_TOKEN|CREDENTIAL_EXPIRATION)$)/;
+
+  /**
+   * The inputs of a component as a value to hash. Linked resources are left
+   * out, what is linked is hashed with the links.
+   */
+  export function inputs(args: Input<any>) {
+    return output(args).apply((args) =>
+      JSON.stringify(args, (_key, value) =>
+        Resource.isInstance(value) ? undefined : value,
+      ),
+    );
+  }
+
+  /**
+   * The version recorded with the hash, or an empty string if the work has to
+   * be done again.
+   */
+  export function previous(name: string, hash: string) {
+    return rpc.call<string>("Incremental.Version", { name, hash });
+  }
+
+  export async function put(name: string, hash: string, version: string) {
+    // nothing is done in a preview
+    if (runtime.isDryRun()) return;
+    await rpc.call("Incremental.Put", { name, hash, version });
+  }
+
+  /**
+   * The version to give the resource doing the work, the last one if nothing
+   * changed since.
+   */
+  export async function version(
+    name: string,
+    paths: string[],
+    extra: string[] = [],
+  ) {
+    return (
+      (await previous(name, await hash(paths, extra))) || Date.now().toString()
+    );
+  }
+
+  /**
+   * Records the work as done, the paths are hashed again so what the work
+   * wrote is part of it.
+   */
+  export async function done(
+    name: string,
+    version: string,
+    paths: string[],
+    extra: string[] = [],
+  ) {
+    if (runtime.isDryRun()) return;
+    await put(name, await hash(paths, extra), version);
+  }
+}
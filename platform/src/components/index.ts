@@ -1,8 +1,10 @@
 export * as aws from "./aws/index.js";
 export * as cloudflare from "./cloudflare/index.js";
+export * as gcp from "./gcp/index.js";
 export * as vercel from "./vercel/index.js";
 export * from "./secret.js";
 export * from "./linkable.js";
+export * from "./workspace.js";
 /**
  * experimental packages, you may be fired for using
  */
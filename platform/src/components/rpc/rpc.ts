@@ -17,6 +17,9 @@ export module rpc {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
+          ...(process.env.SST_SERVER_TOKEN
+            ? { Authorization: `Bearer ${process.env.SST_SERVER_TOKEN}` }
+            : {}),
         },
       };
 
@@ -115,7 +115,11 @@ export class Secret extends Component implements Link.Linkable {
     );
     this._name = name;
     this._placeholder = placeholder;
-    const value = process.env["SST_SECRET_" + this._name] ?? this._placeholder;
+    // offline mode runs without the secrets of the stage
+    const value =
+      process.env["SST_SECRET_" + this._name] ??
+      this._placeholder ??
+      ($cli.offline ? "" : undefined);
     if (typeof value !== "string") {
       throw new SecretMissingError(this._name);
     }
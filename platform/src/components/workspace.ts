@@ -0,0 +1,50 @@
+import { output as pulumiOutput } from "@pulumi/pulumi";
+import { VisibleError } from "./error.js";
+import { rpc } from "./rpc/rpc.js";
+
+/**
+ * The `workspace` module lets an app reference the outputs of the other apps in its
+ * workspace. A workspace is a repo with an `sst.workspace.json` at its root that lists
+ * the directories of its apps.
+ *
+ * ```json title="sst.workspace.json"
+ * {
+ *   "apps": ["apps/*"],
+ *   "providers": {
+ *     "aws": { "region": "us-east-1" }
+ *   }
+ * }
+ * ```
+ *
+ * The `providers` are shared by all the apps, an app can still override their args in its
+ * own config. Apps are referred to by the name of their directory, so `apps/api` is `api`.
+ */
+export module workspace {
+  /**
+   * The output of another app in the same stage, it is read from the state of that app.
+   * The app has to be deployed to the stage first and use the same `home`. Secret outputs
+   * are not shared.
+   *
+   * @param app The name of the app.
+   * @param name The name of the output, one of the values returned from its `run` function.
+   *
+   * @example
+   *
+   * ```ts title="apps/web/sst.config.ts"
+   * const api = sst.workspace.output("api", "url");
+   *
+   * new sst.aws.StaticSite("Web", {
+   *   environment: {
+   *     VITE_API_URL: api,
+   *   },
+   * });
+   * ```
+   */
+  export function output<T = any>(app: string, name: string) {
+    return pulumiOutput(
+      rpc.call<T>("Workspace.Output", { app, name }).catch((error) => {
+        throw new VisibleError(error.message);
+      }),
+    );
+  }
+}
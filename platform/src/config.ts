@@ -78,6 +78,27 @@
  *
  * Make sure the stage name in your `.env.<stage>` matches the stage your app is running on.
  *
+ * ---
+ *
+ * #### Stage overrides
+ *
+ * Instead of conditionals on the stage in your `app` function, a stage can have its own
+ * `sst.config.<stage>.ts` next to your `sst.config.ts`. What it exports is merged over your
+ * app config when running on that stage.
+ *
+ * ```ts title="sst.config.production.ts"
+ * export default {
+ *   removal: "retain",
+ *   providers: {
+ *     aws: { profile: "production" }
+ *   }
+ * };
+ * ```
+ *
+ * Objects are merged key by key, everything else like lists is replaced. It can also export a
+ * function that takes the same input as `app`. Run `sst print-config --stage production` to see
+ * the merged config.
+ *
  * @packageDocumentation
  */
 
@@ -150,6 +171,37 @@ export interface App {
    * ```
    */
   removal?: "remove" | "retain" | "retain-all";
+  /**
+   * Protect the resources of the stage from being deleted, by `sst remove` or by a deploy
+   * after they were removed from your config. Use `true` for all of them, or pick the types
+   * of resources. Types can have `*` wildcards, and the resources of a protected component
+   * are protected too.
+   *
+   * To protect a single component, pass `protect: true` in its options instead.
+   *
+   * ```ts
+   * new sst.aws.Postgres("Database", { vpc }, { protect: true });
+   * ```
+   *
+   * `sst remove` refuses to run while there are protected resources that it would delete.
+   * Pass `--force-unprotect` and type the name of the stage to delete them anyway.
+   *
+   * :::tip
+   * A deploy only refuses to delete resources that were deployed with the protection,
+   * while `sst remove` checks the policy in your config right away.
+   * :::
+   *
+   * @example
+   * Protect the databases and buckets of the _production_ stage.
+   * ```ts
+   * {
+   *   protect: input.stage === "production" && {
+   *     types: ["aws:rds/*", "aws:dynamodb/table:Table", "aws:s3/bucketV2:BucketV2"]
+   *   }
+   * }
+   * ```
+   */
+  protect?: boolean | { types: string[] };
   /**
    * The providers that are being used in this app. This allows you to use the resources from
    * these providers in your app.
@@ -183,6 +235,8 @@ export interface App {
    * You can also configure the provider props. Here's the config for some common providers:
    * - [AWS](https://www.pulumi.com/registry/packages/aws/api-docs/provider/#inputs)
    * - [Cloudflare](https://www.pulumi.com/registry/packages/cloudflare/api-docs/provider/#inputs)
+   * - [GCP](https://www.pulumi.com/registry/packages/gcp/api-docs/provider/#inputs)
+   * - [Azure](https://www.pulumi.com/registry/packages/azure/api-docs/provider/#inputs)
    *
    * @example
    *
@@ -198,15 +252,653 @@ export interface App {
    * }
    * ```
    *
+   * In CI, the AWS provider can get its credentials with OIDC, without a step to configure
+   * them. It exchanges the OIDC token of the job for the credentials of a role that trusts
+   * your CI provider. The session is named after the repo and the run, so you can tell them
+   * apart in CloudTrail.
+   *
+   * ```ts
+   * {
+   *   providers: {
+   *     aws: {
+   *       oidc: {
+   *         roleArn: "arn:aws:iam::123456789012:role/deploy"
+   *       }
+   *     }
+   *   }
+   * }
+   * ```
+   *
+   * You can also set `oidc: true` and the role in the `SST_AWS_OIDC_ROLE_ARN` environment
+   * variable, or only set the variable. In GitHub Actions the workflow needs the
+   * `id-token: write` permission. In other CI providers, like GitLab with `id_tokens`, put the
+   * token in `SST_OIDC_TOKEN`.
+   *
    * @default The `home` provider.
    */
   providers?: Record<string, any>;
 
+  /**
+   * Run commands when certain events happen while your app is being deployed or is running
+   * in `sst dev`. It maps the name of an event to a shell command.
+   *
+   * The command is run from the root of your app with the event as JSON on stdin, and your
+   * [linked resources](/docs/linking) in the environment as `SST_RESOURCE_*`.
+   *
+   * The supported events are:
+   *
+   * - `stack.update.complete`: A deploy finished successfully.
+   * - `stack.update.failed`: A deploy failed.
+   * - `function.build.complete`: A function was built in `sst dev`.
+   * - `function.build.failed`: A function failed to build in `sst dev`.
+   * - `function.invoked`: A function was invoked in `sst dev`.
+   * - `function.error`: A function threw an error in `sst dev`.
+   *
+   * You can also use wildcards, like `function.*`.
+   *
+   * @example
+   *
+   * ```ts
+   * {
+   *   hooks: {
+   *     "stack.update.complete": "./scripts/seed.sh",
+   *     "function.build.failed": "osascript -e 'display notification \"Build failed\"'"
+   *   }
+   * }
+   * ```
+   */
+  hooks?: Record<string, string>;
+
+  /**
+   * Send a POST to these URLs when a deploy or a remove of the stage starts, succeeds, or
+   * fails. Updates in `sst dev` don't send notifications.
+   *
+   * By default the body is a JSON payload with the `event`, `app`, `stage`, `command`,
+   * `deployID`, the `duration` in seconds, the resources that were changed in `changes`, and
+   * the `error` if it failed. Set the `format` to `slack` or `discord` to send a message to
+   * their incoming webhooks instead.
+   *
+   * Failed requests, and responses with a 429 or a 5xx status, are retried a few times.
+   *
+   * @example
+   *
+   * ```ts
+   * {
+   *   notifications: [
+   *     {
+   *       url: process.env.SLACK_WEBHOOK_URL,
+   *       format: "slack",
+   *       events: ["success", "failure"]
+   *     }
+   *   ]
+   * }
+   * ```
+   *
+   * Or use a `template` for the body. It's a [Go template](https://pkg.go.dev/text/template)
+   * of the payload, with its fields capitalized, and `json` escapes a value.
+   *
+   * ```ts
+   * {
+   *   notifications: [
+   *     {
+   *       url: "https://example.com/deploys",
+   *       template: `{"summary": {{json .App}}, "ok": {{if eq .Event "failure"}}false{{else}}true{{end}}}`
+   *     }
+   *   ]
+   * }
+   * ```
+   */
+  notifications?: {
+    /**
+     * The URL to send the POST to.
+     */
+    url: string;
+    /**
+     * The events to send.
+     * @default All of them.
+     */
+    events?: ("start" | "success" | "failure")[];
+    /**
+     * The body that is sent.
+     * @default `"json"`
+     */
+    format?: "json" | "slack" | "discord";
+    /**
+     * A Go template of the body, it's used in place of the `format`.
+     */
+    template?: string;
+    /**
+     * Headers to send with the request, like an `Authorization` header.
+     */
+    headers?: Record<string, string>;
+  }[];
+
+  /**
+   * Configure the file watcher used by `sst dev`.
+   */
+  watch?: {
+    /**
+     * Paths to ignore when watching for changes, so changing them does not rebuild your
+     * functions or redeploy your app. They are matched relative to the root of your app, the
+     * same way as a `.gitignore`.
+     *
+     * Directories that start with a `.`, `node_modules`, `__snapshots__`, `coverage`, build
+     * output like `dist`, `build`, `out`, `target`, and `cdk.out`, and `*.snap`, `*.log`, and
+     * `*.pyc` files are always ignored. So is everything in your `.gitignore` files, except
+     * for `.env` files. Negate a pattern with `!` to watch one of these anyway.
+     *
+     * @example
+     *
+     * ```ts
+     * {
+     *   watch: {
+     *     ignore: ["src/generated/", "*.gen.ts", "!.storybook/"]
+     *   }
+     * }
+     * ```
+     */
+    ignore?: string[];
+  };
+
+  /**
+   * Configure `sst dev`.
+   */
+  dev?: {
+    /**
+     * The layout and keys of the multiplexer.
+     *
+     * Panes are referred to by their key. The built-in ones are `deploy`, `function`,
+     * and `tunnel`, and the panes of your components use their name, like `MyWeb` for
+     * `new sst.aws.Nextjs("MyWeb")`.
+     *
+     * @example
+     *
+     * ```ts
+     * {
+     *   dev: {
+     *     ui: {
+     *       panes: ["function", "MyWeb", "deploy"],
+     *       hide: ["tunnel"],
+     *       sidebarWidth: 28,
+     *       keys: {
+     *         up: ["k", "up"],
+     *         kill: "d"
+     *       },
+     *       processes: {
+     *         Storybook: {
+     *           command: "npm run storybook",
+     *           directory: "packages/web"
+     *         }
+     *       }
+     *     }
+     *   }
+     * }
+     * ```
+     */
+    ui?: {
+      /**
+       * The panes that are listed first in the sidebar, in this order. The rest follow
+       * them. Stopped processes are always listed last.
+       */
+      panes?: string[];
+      /**
+       * The panes that are not shown at all. Their processes are not started either.
+       */
+      hide?: string[];
+      /**
+       * The width of the sidebar in columns.
+       *
+       * You can also drag the border of the sidebar to resize it. The new width is kept
+       * for your app in the `.sst/` directory and is used instead of this.
+       *
+       * @default `20`
+       */
+      sidebarWidth?: number;
+      /**
+       * Remap the keys of the multiplexer. Each action takes a key or a list of keys,
+       * like `"k"`, `"enter"`, or `"ctrl-u"`, and replaces the default keys of the action.
+       *
+       * The `scrollUp`, `scrollDown`, and `sidebar` keys also work while a pane is
+       * focused, so use `ctrl-` keys for them.
+       *
+       * Pausing a pane freezes its output so it can be searched. While it is paused,
+       * `level` hides the function logs below a level, `function` only shows the logs
+       * of one function, and `next` and `previous` jump between the matches.
+       *
+       * @default `{ up: ["k", "up"], down: ["j", "down"], select: "enter", kill: "x", sidebar: "ctrl-z", scrollUp: "ctrl-u", scrollDown: "ctrl-d", quit: "ctrl-c", pause: "p", search: "/", next: "n", previous: "N", level: "l", function: "f", resume: "esc" }`
+       */
+      keys?: Partial<
+        Record<
+          | "up"
+          | "down"
+          | "select"
+          | "kill"
+          | "sidebar"
+          | "scrollUp"
+          | "scrollDown"
+          | "quit"
+          | "pause"
+          | "search"
+          | "next"
+          | "previous"
+          | "level"
+          | "function"
+          | "resume",
+          string | string[]
+        >
+      >;
+      /**
+       * Processes of your own to run in a pane, like a storybook or a queue worker. They
+       * are keyed by their name and get the environment of `sst dev`, along with their
+       * [linked resources](/docs/linking/). They are restarted when the links change.
+       *
+       * @example
+       *
+       * ```ts
+       * {
+       *   processes: {
+       *     storybook: {
+       *       command: "npm run storybook",
+       *       directory: "packages/web",
+       *       link: ["MyBucket"]
+       *     }
+       *   }
+       * }
+       * ```
+       */
+      processes?: Record<
+        string,
+        {
+          /**
+           * The command to run.
+           */
+          command: string;
+          /**
+           * The directory to run it in, relative to the root of your app.
+           */
+          directory?: string;
+          /**
+           * The title of the pane, the name of the process if it is not set.
+           */
+          title?: string;
+          /**
+           * Start the process with `sst dev`. Otherwise it is started from the sidebar.
+           * @default `true`
+           */
+          autostart?: boolean;
+          /**
+           * The names of the resources to link to the process.
+           * @default All the resources in your app
+           */
+          link?: string[];
+        }
+      >;
+      /**
+       * Restart panes when their process exits, keyed by the name of the pane. The
+       * number of restarts is shown next to it in the sidebar.
+       *
+       * - `always`: Restart it whenever it exits.
+       * - `on-failure`: Restart it when it exits with an error, up to `retries` times
+       *   in a row.
+       * - `never`: Leave it stopped.
+       *
+       * It waits `backoff` before the first restart and twice as long for each one
+       * after it, up to 30 seconds. The retries start over once it has run for a
+       * minute. Processes that are stopped from the sidebar are not restarted.
+       *
+       * @default `"never"`
+       * @example
+       *
+       * ```ts
+       * {
+       *   restart: {
+       *     storybook: "always",
+       *     MyWeb: { policy: "on-failure", retries: 3, backoff: "2s" }
+       *   }
+       * }
+       * ```
+       */
+      restart?: Record<
+        string,
+        | "always"
+        | "on-failure"
+        | "never"
+        | {
+            policy: "always" | "on-failure" | "never";
+            /**
+             * @default `5` with `on-failure`, no limit with `always`
+             */
+            retries?: number;
+            /**
+             * @default `"1s"`
+             */
+            backoff?: string;
+          }
+      >;
+    };
+  };
+
+  /**
+   * Store the state of your app in a bucket you own, instead of the one created by your
+   * `home`. The state, secrets, locks, and the history of your stages are all kept there.
+   *
+   * Your `home` is still used for everything else, like the assets of your functions.
+   *
+   * :::caution
+   * Changing this on an app that has been deployed starts it with empty state. The old state
+   * is left where it was.
+   * :::
+   *
+   * The bucket needs to exist. The credentials for it are read from the environment:
+   *
+   * - `s3`: Your AWS credentials, the same as the `aws` provider.
+   * - `r2`: An R2 API token in `R2_ACCESS_KEY_ID` and `R2_SECRET_ACCESS_KEY`.
+   * - `gcs`: HMAC keys in `GCS_HMAC_ACCESS_KEY_ID` and `GCS_HMAC_SECRET`.
+   *
+   * The passphrase that encrypts your secrets is not stored in the bucket as is. With `s3` it
+   * is kept in SSM like with the `aws` home. With `r2` and `gcs` it is kept in the bucket
+   * encrypted with `SST_STATE_PASSPHRASE`, which has to be set to use the bucket.
+   *
+   * @example
+   *
+   * ```ts
+   * {
+   *   home: "cloudflare",
+   *   state: {
+   *     backend: "r2",
+   *     bucket: "my-app-state",
+   *     accountId: "24bc7b8a9ff38e03d6cb4c08d3f12968"
+   *   }
+   * }
+   * ```
+   */
+  state?: {
+    /**
+     * Where the state is stored. Use `local` to keep it on your machine.
+     */
+    backend: "s3" | "r2" | "gcs" | "local";
+    /**
+     * The name of the bucket.
+     */
+    bucket?: string;
+    /**
+     * A prefix for everything stored in the bucket, to share it between apps.
+     */
+    prefix?: string;
+    /**
+     * The region of an `s3` bucket.
+     * @default The region of the `aws` provider.
+     */
+    region?: string;
+    /**
+     * The Cloudflare account of an `r2` bucket.
+     * @default `CLOUDFLARE_DEFAULT_ACCOUNT_ID`
+     */
+    accountId?: string;
+    /**
+     * A custom endpoint, for any other storage that works with the S3 API.
+     */
+    endpoint?: string;
+  };
+
+  /**
+   * Configure the history of your stages. A checkpoint of the state is saved at the end of
+   * every update, you can list them with `sst state history` and go back to one with
+   * `sst state restore` or `sst rollback`.
+   */
+  history?: {
+    /**
+     * How many checkpoints to keep for each stage. Older ones are removed after every update,
+     * together with their receipts and the ones in `.sst/receipts`.
+     *
+     * @default All of them
+     * @example
+     *
+     * ```ts
+     * {
+     *   history: {
+     *     retain: 50
+     *   }
+     * }
+     * ```
+     */
+    retain?: number;
+    /**
+     * Upload the receipt of every update next to its checkpoint. A receipt is written to
+     * `.sst/receipts` either way, except for the deploys of `sst dev`. It has the deploy ID, the
+     * git commit, a hash of your config, the resources that changed, the outputs, how long it
+     * took, and who ran it. Secret outputs are left out.
+     *
+     * With this set, `sst history show <id>` can read them from any machine, like the ones of
+     * your CI deploys.
+     *
+     * @default `false`
+     */
+    receipts?: boolean;
+  };
+
+  /**
+   * Encrypt the state of your app before it is stored. The state has the outputs of all your
+   * resources, some of which can be sensitive.
+   *
+   * By default it is encrypted with a key derived from the passphrase in the
+   * `SST_STATE_PASSPHRASE` environment variable. It is never stored, so it needs to be set
+   * wherever you deploy from. Pass in a KMS key to use that instead, this needs the `aws`
+   * provider.
+   *
+   * @example
+   *
+   * ```ts
+   * {
+   *   encryption: {
+   *     kms: "alias/sst-state"
+   *   }
+   * }
+   * ```
+   *
+   * State that was stored before this was set is still read as is. Run `sst state encrypt` to
+   * encrypt it, along with the history of the stage.
+   */
+  encryption?: {
+    /**
+     * The ID, ARN, or alias of the KMS key.
+     */
+    kms?: string;
+  };
+
+  /**
+   * Where the secrets of your app are stored. By default they are encrypted with the
+   * passphrase of the stage and kept in your `home`.
+   *
+   * If they need to be in AWS, use `ssm` to keep each secret in a `SecureString` parameter
+   * named `/sst/<app>/<stage>/<name>`, or `secretsmanager` to keep each one in a secret named
+   * `sst/<app>/<stage>/<name>`. Both need the `aws` provider. The fallback secrets are stored
+   * under the `_fallback` stage.
+   *
+   * @example
+   *
+   * ```ts
+   * {
+   *   secrets: {
+   *     backend: "ssm"
+   *   }
+   * }
+   * ```
+   *
+   * Secrets that start with a prefix can be stored somewhere else. The longest matching prefix
+   * wins.
+   *
+   * ```ts
+   * {
+   *   secrets: {
+   *     backend: "ssm",
+   *     prefixes: {
+   *       Stripe: "secretsmanager"
+   *     }
+   *   }
+   * }
+   * ```
+   *
+   * Use `vault` to keep them in a KV secrets engine of HashiCorp Vault. The secrets of a stage
+   * are the keys of one Vault secret.
+   *
+   * ```ts
+   * {
+   *   secrets: {
+   *     backend: "vault",
+   *     vault: {
+   *       address: "https://vault.example.com",
+   *       path: "teams/web/{app}/{stage}",
+   *       auth: "oidc",
+   *       role: "developer"
+   *     }
+   *   }
+   * }
+   * ```
+   *
+   * Changing this does not move existing secrets, set them again with `sst secret set` or
+   * `sst secret load`. Removing a secret from `secretsmanager` deletes it without a recovery
+   * window.
+   */
+  secrets?: {
+    /**
+     * Where secrets are stored.
+     * @default `"sst"`
+     */
+    backend?: "sst" | "ssm" | "secretsmanager" | "vault";
+    /**
+     * Where the secrets that start with a prefix are stored.
+     */
+    prefixes?: Record<string, "sst" | "ssm" | "secretsmanager" | "vault">;
+    /**
+     * Configure the `vault` backend.
+     */
+    vault?: {
+      /**
+       * The address of the Vault server.
+       * @default The `VAULT_ADDR` environment variable
+       */
+      address?: string;
+      /**
+       * The namespace, for HCP Vault and Vault Enterprise.
+       * @default The `VAULT_NAMESPACE` environment variable
+       */
+      namespace?: string;
+      /**
+       * The path the KV secrets engine is mounted at.
+       * @default `"secret"`
+       */
+      mount?: string;
+      /**
+       * The version of the KV secrets engine.
+       * @default `2`
+       */
+      version?: 1 | 2;
+      /**
+       * Where the secrets of a stage are stored in the mount. `{app}` and `{stage}` are
+       * replaced with the name of your app and stage, the fallback secrets use `_fallback` as
+       * the stage.
+       * @default `"sst/{app}/{stage}"`
+       */
+      path?: string;
+      /**
+       * How to log in to Vault.
+       *
+       * - `token`: Uses `VAULT_TOKEN`, or the token saved by `vault login`.
+       * - `approle`: Logs in with `VAULT_ROLE_ID` and `VAULT_SECRET_ID`, useful in CI.
+       * - `oidc`: Logs in through your browser, like `vault login -method=oidc`.
+       *
+       * @default `"token"`
+       */
+      auth?: "token" | "approle" | "oidc";
+      /**
+       * The path the auth method is mounted at.
+       * @default The name of the auth method
+       */
+      authMount?: string;
+      /**
+       * The role to log in with `oidc`, or the role ID for `approle`.
+       */
+      role?: string;
+      /**
+       * How many seconds secrets that were read are reused for before they are read from
+       * Vault again. This matters in `sst dev`, which reads them on every deploy.
+       * @default `60`
+       */
+      cacheTtl?: number;
+    };
+    /**
+     * Secrets that have to be rotated, and how. `sst deploy` warns about the ones that are
+     * past due and `sst secret rotate` rotates them.
+     *
+     * When a secret was last changed is read from its history, so it counts from the last
+     * `sst secret set`.
+     *
+     * @example
+     *
+     * ```ts
+     * {
+     *   secrets: {
+     *     rotation: {
+     *       StripeSecret: {
+     *         interval: "30 days",
+     *         command: "./scripts/rotate-stripe.sh"
+     *       },
+     *       GithubToken: {
+     *         expires: "2025-01-31",
+     *         function: "rotate-github-token"
+     *       }
+     *     }
+     *   }
+     * }
+     * ```
+     */
+    rotation?: Record<
+      string,
+      {
+        /**
+         * How long a value is good for, like `30 days`, `12h`, or `2 weeks`.
+         */
+        interval?: string;
+        /**
+         * The date the secret has to be rotated by, in `YYYY-MM-DD`.
+         */
+        expires?: string;
+        /**
+         * A shell command that prints the new value. It gets the current one in
+         * `SST_SECRET_VALUE`.
+         */
+        command?: string;
+        /**
+         * The name or ARN of a Lambda function that returns the new value. It gets the current
+         * one as `value`.
+         */
+        function?: string;
+      }
+    >;
+  };
+
+  /**
+   * Point to a file with an esbuild config hook that's applied to every Node function. It runs
+   * before the [`nodejs.esbuildConfig`](/docs/component/aws/function/#nodejs-esbuildconfig) of
+   * a function.
+   *
+   * The path is relative to the location of the `sst.config.ts`.
+   *
+   * @example
+   * ```ts
+   * {
+   *   esbuildConfig: "./esbuild.config.ts"
+   * }
+   * ```
+   */
+  esbuildConfig?: string;
+
   /**
    * The provider SST will use to store the state for your app. The state keeps track of all your resources and secrets. The state is generated locally and backed up in your cloud provider.
    *
    *
-   * Currently supports AWS, Cloudflare and local.
+   * Currently supports AWS, Cloudflare, GCP, Azure and local.
    *
    * :::tip
    * SST uses the `home` provider to store the state for your app. If you use the local provider it will be saved on your machine. You can see where by running `sst version`.
@@ -225,8 +917,46 @@ export interface App {
    * }
    * ```
    *
+   * The gcp home creates a private GCS bucket for the state in your project. It uses your
+   * [application default credentials](https://cloud.google.com/docs/authentication/application-default-credentials),
+   * so run `gcloud auth application-default login` or set `GOOGLE_APPLICATION_CREDENTIALS`
+   * to a service account key. The passphrase that encrypts the secrets of a stage is kept in
+   * Secret Manager, so its API needs to be enabled in the project.
+   *
+   * ```ts
+   * {
+   *   home: "gcp",
+   *   providers: {
+   *     gcp: {
+   *       project: "my-project",
+   *       region: "us-central1"
+   *     }
+   *   }
+   * }
+   * ```
+   *
+   * The azure home creates a storage account tagged `sst: state` in an `sst` resource group
+   * of your subscription, and keeps the state of every stage in its `state` container. It
+   * signs in with a service principal if `ARM_CLIENT_ID`, `ARM_CLIENT_SECRET`, and
+   * `ARM_TENANT_ID` are set, with the managed identity of the machine if `useMsi` is set,
+   * and with the Azure CLI otherwise. The passphrase that encrypts the secrets of a stage is
+   * kept in a Key Vault in the same resource group, and whoever can manage the vault is given
+   * an access policy to it.
+   *
+   * ```ts
+   * {
+   *   home: "azure",
+   *   providers: {
+   *     azure: {
+   *       subscriptionId: "00000000-0000-0000-0000-000000000000",
+   *       location: "westeurope"
+   *     }
+   *   }
+   * }
+   * ```
+   *
    */
-  home: "aws" | "cloudflare" | "local";
+  home: "aws" | "cloudflare" | "gcp" | "azure" | "local";
 }
 
 export interface AppInput {
@@ -292,6 +292,8 @@ declare global {
   /** @internal */
   export const $cli: {
     command: string;
+    unprotect: boolean;
+    offline?: boolean;
     rpc: string;
     paths: {
       home: string;
@@ -1,6 +1,8 @@
 import path from "path";
 import fs from "fs/promises";
-import { exec } from "child_process";
+import { execFile } from "child_process";
+import crypto from "crypto";
+import { promisify } from "util";
 import pulumi from "@pulumi/pulumi";
 import fsSync from "fs";
 import { Semaphore } from "../util/semaphore.js";
@@ -11,6 +13,12 @@ const limiter = new Semaphore(
 	parseInt(process.env.SST_BUILD_CONCURRENCY || "4"),
 );
 
+const execFileAsync = promisify(execFile);
+
+// Dependencies over this size go in a layer unless `python.layer` says otherwise,
+// so deploys that only change the handler don't upload them again.
+const LAYER_THRESHOLD_BYTES = 50 * 1024 * 1024;
+
 export async function buildPythonContainer(
 	name: string,
 	input: pulumi.Unwrap<FunctionArgs> & {
@@ -133,6 +141,7 @@ export async function buildPython(
 			type: "success";
 			out: string;
 			handler: string;
+			layer?: string;
 	  }
 	| { type: "error"; errors: string[] }
 > {
@@ -159,8 +168,10 @@ export async function buildPython(
 	try {
 		await limiter.acquire(name);
 
-		// Find the closest pyproject.toml
-		const pyProjectFile = await findAbove(parsed.dir, "pyproject.toml");
+		// Find the closest pyproject.toml, or requirements.txt
+		const pyProjectFile =
+			(await findAbove(parsed.dir, "pyproject.toml")) ??
+			(await findAbove(parsed.dir, "requirements.txt"));
 		if (!pyProjectFile) {
 			return {
 				type: "error",
@@ -169,17 +180,14 @@ export async function buildPython(
 				],
 			};
 		}
+		await fs.mkdir(path.join(out, pyProjectFile), { recursive: true });
 
 		// Copy pyproject.toml to the output directory
-		await fs.copyFile(
-			path.join(pyProjectFile, "pyproject.toml"),
-			path.join(out, path.join(pyProjectFile, "pyproject.toml")),
-		);
-
-		// If uv.lock exists, copy it to the output directory
-		const uvLockFile = path.join(pyProjectFile, "uv.lock");
-		if (fsSync.existsSync(uvLockFile)) {
-			await fs.copyFile(uvLockFile, path.join(out, uvLockFile));
+		if (fsSync.existsSync(path.join(pyProjectFile, "pyproject.toml"))) {
+			await fs.copyFile(
+				path.join(pyProjectFile, "pyproject.toml"),
+				path.join(out, path.join(pyProjectFile, "pyproject.toml")),
+			);
 		}
 
 		// At the same level as the pyproject.toml create resources.json
@@ -195,40 +203,20 @@ export async function buildPython(
 			parsed.dir,
 		);
 
-		// Install Python dependencies
-		// in the output directory we run uv sync to create a virtual environment
-		// first make the output directory the working directory
-		// also need to use sst uv path because it is not guaranteed to be in the path
-		const installCmd = `cd ${path.join(out, pyProjectFile)} && uv sync`;
-
-		// Once the packages are synced, we need to convert the virtual environment to site-packages so that lambda can find the packages
-		const sitePackagesCmd = `cp -r ${path.join(
-			out,
-			pyProjectFile,
-			".venv",
-			"lib",
-			"python3.*",
-			"site-packages",
-			"*",
-		)} ${out}`;
-
-		// Now remove the virtual environment because it does not need to be included in the zip
-		const removeVirtualEnvCmd = `rm -rf ${path.join(
-			out,
+		// Dependencies are installed for Lambda and not this machine, then either
+		// copied next to the handler or put in a layer of their own
+		const dependencies = await installDependencies(
 			pyProjectFile,
-			".venv",
-		)}`;
-
-		const command = `${installCmd} && ${sitePackagesCmd} && ${removeVirtualEnvCmd}`;
-
-		await new Promise<void>((resolve, reject) => {
-			exec(command, { cwd: out }, (error) => {
-				if (error) {
-					return reject(error);
-				}
-				resolve();
+			input.runtime ?? "python3.11",
+			input.architecture ?? "x86_64",
+		);
+		const layer =
+			input.python?.layer ?? dependencies.size > LAYER_THRESHOLD_BYTES;
+		if (!layer) {
+			await fs.cp(path.join(dependencies.dir, "python"), out, {
+				recursive: true,
 			});
-		});
+		}
 
 		return {
 			type: "success",
@@ -237,6 +225,7 @@ export async function buildPython(
 				.join(relativePath, parsed.base)
 				.split(path.sep)
 				.join(path.posix.sep),
+			layer: layer ? dependencies.dir : undefined,
 		};
 	} catch (ex: any) {
 		return {
@@ -372,3 +361,125 @@ async function writeResourcesFile(
 		{ encoding: "utf-8" },
 	);
 }
+
+async function hasCommand(command: string) {
+	try {
+		await execFileAsync(command, ["--version"]);
+		return true;
+	} catch {
+		return false;
+	}
+}
+
+/**
+ * Installs the dependencies of a project into `python/` of a directory in the cache,
+ * which is the layout of a layer. Wheels are picked for the Lambda platform rather
+ * than this machine. The directory is keyed by a hash of the lockfile or requirements,
+ * the runtime, and the architecture, so it is only installed again when they change.
+ */
+async function installDependencies(
+	projectDir: string,
+	runtime: string,
+	architecture: string,
+): Promise<{ dir: string; size: number }> {
+	const pythonVersion = runtime.replace(/^python/, "");
+	const arch = architecture === "arm64" ? "aarch64" : "x86_64";
+	const uv = await hasCommand("uv");
+
+	// the project is resolved to a requirements file by the tool that owns its
+	// lockfile, then installed with uv or pip
+	const exists = (file: string) =>
+		fsSync.existsSync(path.join(projectDir, file));
+	let requirements: string;
+	if (
+		exists("pyproject.toml") &&
+		exists("poetry.lock") &&
+		(await hasCommand("poetry"))
+	) {
+		const result = await execFileAsync(
+			"poetry",
+			["export", "--without-hashes", "--format", "requirements.txt"],
+			{ cwd: projectDir },
+		);
+		requirements = result.stdout;
+	} else if (exists("pyproject.toml") && uv) {
+		const args = ["export", "--no-dev", "--no-hashes", "--no-emit-project"];
+		if (exists("uv.lock")) args.push("--frozen");
+		const result = await execFileAsync("uv", args, { cwd: projectDir });
+		requirements = result.stdout;
+	} else if (exists("requirements.txt")) {
+		requirements = await fs.readFile(
+			path.join(projectDir, "requirements.txt"),
+			"utf-8",
+		);
+	} else {
+		throw new Error(
+			`Install uv or poetry to resolve the dependencies in ${path.join(projectDir, "pyproject.toml")}`,
+		);
+	}
+
+	const hash = crypto.createHash("sha256");
+	hash.update([runtime, architecture, uv ? "uv" : "pip"].join("\n"));
+	hash.update(requirements);
+	const dir = path.join(
+		$cli.paths.work,
+		"python",
+		hash.digest("hex").slice(0, 16),
+	);
+	const complete = path.join(dir, ".complete");
+	if (fsSync.existsSync(complete)) {
+		return { dir, size: Number(await fs.readFile(complete, "utf-8")) };
+	}
+
+	await fs.rm(dir, { recursive: true, force: true });
+	const target = path.join(dir, "python");
+	await fs.mkdir(target, { recursive: true });
+	const file = path.join(dir, "requirements.txt");
+	await fs.writeFile(file, requirements);
+	if (uv) {
+		await execFileAsync("uv", [
+			"pip",
+			"install",
+			"--requirement",
+			file,
+			"--target",
+			target,
+			"--python-platform",
+			`${arch}-manylinux2014`,
+			"--python-version",
+			pythonVersion,
+			"--only-binary",
+			":all:",
+		]);
+	} else {
+		await execFileAsync("pip", [
+			"install",
+			"--requirement",
+			file,
+			"--target",
+			target,
+			"--platform",
+			`manylinux2014_${arch}`,
+			"--implementation",
+			"cp",
+			"--python-version",
+			pythonVersion,
+			"--only-binary=:all:",
+		]);
+	}
+	await fs.rm(file);
+
+	const size = await directorySize(target);
+	await fs.writeFile(complete, String(size));
+	return { dir, size };
+}
+
+async function directorySize(dir: string): Promise<number> {
+	let total = 0;
+	for (const entry of await fs.readdir(dir, { withFileTypes: true })) {
+		const full = path.join(dir, entry.name);
+		if (entry.isDirectory()) total += await directorySize(full);
+		else if (entry.isFile()) total += (await fs.stat(full)).size;
+	}
+	return total;
+}
@@ -0,0 +1,20 @@
+/// <reference path="./.sst/platform/config.d.ts" />
+
+export default $config({
+  app(input) {
+    return {
+      name: "{{.App}}",
+      removal: input?.stage === "production" ? "retain" : "remove",
+      home: "aws",
+    };
+  },
+  async run() {
+    const api = new sst.aws.Function("MyApi", {
+      url: true,
+      handler: "index.handler",
+    });
+    return {
+      api: api.url,
+    };
+  },
+});